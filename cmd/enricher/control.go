@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry/keepalive"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/worker"
+)
+
+// cancelEmailQueryType is the interactive function that cancels one in-flight email.
+const cancelEmailQueryType = "cancelEmail"
+
+// statsQueryType is the interactive function that reports run metrics.
+const statsQueryType = "stats"
+
+type cancelEmailQuery struct {
+	Email string `json:"email"`
+}
+
+type cancelEmailResult struct {
+	Email    string `json:"email"`
+	Canceled bool   `json:"canceled"`
+}
+
+// newControlHandler returns the keepalive job handler. cancelEmail cancels the named email's
+// in-flight enrichment, so it ends as an error row ("item canceled") while the run continues; it
+// answers {"email","canceled"}, where canceled is false when the email was not being enriched.
+// stats answers stats.Snapshot() as JSON, so operators can check module health on demand. Any
+// other job is acknowledged with "ok" so it does not block routing.
+func newControlHandler(canceler *worker.Canceler, stats *app.RunStats) func(context.Context, keepalive.Job) ([]byte, error) {
+	return func(_ context.Context, job keepalive.Job) ([]byte, error) {
+		if job.QueryType == statsQueryType {
+			return json.Marshal(stats.Snapshot())
+		}
+		if job.QueryType != cancelEmailQueryType {
+			return []byte("ok"), nil
+		}
+		var q cancelEmailQuery
+		if err := json.Unmarshal(job.Query, &q); err != nil {
+			return nil, fmt.Errorf("parse %s query: %w", cancelEmailQueryType, err)
+		}
+		email := strings.TrimSpace(q.Email)
+		if email == "" {
+			return nil, fmt.Errorf("%s requires an email", cancelEmailQueryType)
+		}
+		return json.Marshal(cancelEmailResult{Email: email, Canceled: canceler.Cancel(email)})
+	}
+}
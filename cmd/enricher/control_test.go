@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/enrich"
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry/keepalive"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/mockfoundry"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/worker"
+)
+
+// stuckEnricher blocks stuck@example.com until its context ends and answers other emails at once.
+type stuckEnricher struct{ started chan struct{} }
+
+func (e stuckEnricher) Enrich(ctx context.Context, email string) (enrich.Result, error) {
+	if email != "stuck@example.com" {
+		return enrich.Result{Company: "example.com"}, nil
+	}
+	close(e.started)
+	<-ctx.Done()
+	return enrich.Result{}, ctx.Err()
+}
+
+func TestControlHandler_CancelEmailStopsOnlyThatEmail(t *testing.T) {
+	t.Parallel()
+
+	canceler := worker.NewCanceler(app.CancelKey)
+	handle := newControlHandler(canceler, nil)
+	enricher := stuckEnricher{started: make(chan struct{})}
+
+	answers := make(chan string, 1)
+	go func() {
+		<-enricher.started
+		out, err := handle(context.Background(), keepalive.Job{
+			JobID:     "job-1",
+			QueryType: cancelEmailQueryType,
+			Query:     json.RawMessage(`{"email":" Stuck@Example.com "}`),
+		})
+		if err != nil {
+			t.Errorf("cancelEmail: %v", err)
+		}
+		answers <- string(out)
+	}()
+
+	rows, err := pipeline.EnrichEmails(context.Background(),
+		[]string{"alice@example.com", " stuck@example.com", "bob@example.com"},
+		enricher,
+		pipeline.Options{Workers: 2, MaxRetries: 2, FailFast: true, Canceler: canceler},
+	)
+	if err != nil {
+		t.Fatalf("EnrichEmails: %v", err)
+	}
+	if got := <-answers; got != `{"email":"Stuck@Example.com","canceled":true}` {
+		t.Fatalf("unexpected cancelEmail answer: %s", got)
+	}
+	if rows[1].Status != "error" || !strings.Contains(rows[1].Error, worker.ErrItemCanceled.Error()) {
+		t.Fatalf("expected the canceled email to end with a cancellation error, got %#v", rows[1])
+	}
+	if rows[0].Status != "ok" || rows[2].Status != "ok" {
+		t.Fatalf("expected the other emails to complete, got %#v", rows)
+	}
+
+	out, err := handle(context.Background(), keepalive.Job{QueryType: cancelEmailQueryType, Query: json.RawMessage(`{"email":"stuck@example.com"}`)})
+	if err != nil || string(out) != `{"email":"stuck@example.com","canceled":false}` {
+		t.Fatalf("expected canceled=false once the email finished, got %s err=%v", out, err)
+	}
+	if out, err := handle(context.Background(), keepalive.Job{QueryType: "other"}); err != nil || string(out) != "ok" {
+		t.Fatalf("expected other jobs to be acknowledged, got %s err=%v", out, err)
+	}
+}
+
+// failBobEnricher fails bob@example.com and enriches other emails.
+type failBobEnricher struct{}
+
+func (failBobEnricher) Enrich(_ context.Context, email string) (enrich.Result, error) {
+	if email == "bob@example.com" {
+		return enrich.Result{}, errors.New("lookup failed")
+	}
+	return enrich.Result{Company: "example.com", Confidence: "high"}, nil
+}
+
+func TestControlHandler_StatsReportsRunMetrics(t *testing.T) {
+	t.Parallel()
+
+	const (
+		inputRID  = "ri.foundry.main.dataset.11111111-1111-1111-1111-111111111111"
+		outputRID = "ri.foundry.main.dataset.22222222-2222-2222-2222-222222222222"
+	)
+	inputDir := t.TempDir()
+	input := "email\nalice@example.com\nbob@example.com\ncarol@example.com\nalice@example.com\n"
+	if err := os.WriteFile(filepath.Join(inputDir, inputRID+".csv"), []byte(input), 0o644); err != nil {
+		t.Fatalf("write input: %v", err)
+	}
+	ts := httptest.NewServer(mockfoundry.New(inputDir, t.TempDir()).Handler())
+	t.Cleanup(ts.Close)
+	env := foundry.Env{
+		Services: foundry.Services{APIGateway: ts.URL + "/api", StreamProxy: ts.URL + "/stream-proxy/api"},
+		Token:    "dummy-token",
+		Aliases: map[string]foundry.DatasetRef{
+			"input":  {RID: inputRID, Branch: "master"},
+			"output": {RID: outputRID, Branch: "master"},
+		},
+	}
+
+	stats := &app.RunStats{}
+	handle := newControlHandler(worker.NewCanceler(app.CancelKey), stats)
+	query := func() app.RunStatsSnapshot {
+		t.Helper()
+		out, err := handle(context.Background(), keepalive.Job{JobID: "job-stats", QueryType: statsQueryType})
+		if err != nil {
+			t.Fatalf("stats: %v", err)
+		}
+		var snap app.RunStatsSnapshot
+		if err := json.Unmarshal(out, &snap); err != nil {
+			t.Fatalf("parse stats answer %s: %v", out, err)
+		}
+		return snap
+	}
+
+	if snap := query(); snap.Runs != 0 || snap.LastRun != nil {
+		t.Fatalf("expected empty stats before a run, got %+v", snap)
+	}
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+		Stats:           stats,
+	}, pipeline.Options{Workers: 2}, failBobEnricher{}); err != nil {
+		t.Fatalf("RunFoundryWithOptions: %v", err)
+	}
+
+	snap := query()
+	if snap.Running || snap.Runs != 1 || snap.RowsProcessed != 3 || snap.Errors != 1 {
+		t.Fatalf("unexpected counters: %+v", snap)
+	}
+	last := snap.LastRun
+	if last == nil || last.RunID == "" || last.OutputMode != "dataset" || last.Enriched != 3 || last.OK != 2 || last.Errors != 1 || last.RowsWritten != 4 || last.Error != "" {
+		t.Fatalf("unexpected last run summary: %+v", last)
+	}
+}
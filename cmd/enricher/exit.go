@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/redact"
+)
+
+// Process exit codes. 1 and 2 keep their original meaning (run failure, config error); run failures
+// with a known category get their own code so orchestration can tell them apart.
+const (
+	exitOK                 = 0
+	exitRunFailed          = 1
+	exitConfig             = 2
+	exitBudgetExceeded     = 3
+	exitTransientExhausted = 4
+	exitStackReadOnly      = 5
+	exitPermissionDenied   = 6
+	exitThresholdExceeded  = 7
+)
+
+const exitReasonFileUsage = "Write a JSON document {code, reason, error} describing why the process exited to this path (default: none)"
+
+// exitCodes maps each app.FailureReason to its exit code.
+var exitCodes = map[app.FailureReason]int{
+	app.FailureRun:                exitRunFailed,
+	app.FailureConfig:             exitConfig,
+	app.FailureBudgetExceeded:     exitBudgetExceeded,
+	app.FailureTransientExhausted: exitTransientExhausted,
+	app.FailureStackReadOnly:      exitStackReadOnly,
+	app.FailurePermissionDenied:   exitPermissionDenied,
+	app.FailureThresholdExceeded:  exitThresholdExceeded,
+}
+
+// exitCodeFor returns the exit code for a run error; nil maps to exitOK.
+func exitCodeFor(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	return exitCodes[app.ClassifyFailure(err)]
+}
+
+// exitReasonFor names the reason for code. Config errors rejected before a run starts carry no
+// error, so the reason comes from the code rather than the error.
+func exitReasonFor(code int) string {
+	if code == exitOK {
+		return "ok"
+	}
+	for reason, c := range exitCodes {
+		if c == code {
+			return string(reason)
+		}
+	}
+	return string(app.FailureRun)
+}
+
+// exitReason is the --exit-reason-file document.
+type exitReason struct {
+	Code   int    `json:"code"`
+	Reason string `json:"reason"`
+	Error  string `json:"error,omitempty"`
+}
+
+// writeExitReason writes the --exit-reason-file document for code and err; an empty path is a
+// no-op. The file is written via a temp file and rename so readers never see a partial document.
+func writeExitReason(path string, code int, err error) error {
+	if path == "" {
+		return nil
+	}
+	doc := exitReason{Code: code, Reason: exitReasonFor(code)}
+	if err != nil {
+		doc.Error = redact.Secrets(err.Error())
+	}
+	b, merr := json.Marshal(doc)
+	if merr != nil {
+		return merr
+	}
+	tmp, werr := os.CreateTemp(filepath.Dir(path), ".exit-reason-*")
+	if werr != nil {
+		return werr
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, werr := tmp.Write(append(b, '\n')); werr != nil {
+		_ = tmp.Close()
+		return werr
+	}
+	if werr := tmp.Close(); werr != nil {
+		return werr
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// reportExitReason is writeExitReason for deferred use: a write failure is logged, not returned.
+func reportExitReason(path string, code int, err error) {
+	if werr := writeExitReason(path, code, err); werr != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "write exit reason file: %s\n", werr)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/enrich"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	foundryio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/foundry"
+)
+
+// runLocalExit runs the local command with args plus an input CSV, an output path and
+// --exit-reason-file, returning the exit code and the decoded reason document.
+func runLocalExit(t *testing.T, args ...string) (int, exitReason) {
+	t.Helper()
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.csv")
+	if err := os.WriteFile(input, []byte("email\nalice@example.com\n"), 0o644); err != nil {
+		t.Fatalf("write input: %v", err)
+	}
+	reasonPath := filepath.Join(dir, "exit-reason.json")
+	args = append([]string{
+		"--input", input,
+		"--output", filepath.Join(dir, "output.csv"),
+		"--exit-reason-file", reasonPath,
+	}, args...)
+
+	code := runLocal(context.Background(), args)
+
+	b, err := os.ReadFile(reasonPath)
+	if err != nil {
+		t.Fatalf("read exit reason file: %v", err)
+	}
+	var doc exitReason
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatalf("parse exit reason file %q: %v", b, err)
+	}
+	if doc.Code != code {
+		t.Fatalf("exit reason code=%d but process code=%d", doc.Code, code)
+	}
+	return code, doc
+}
+
+func TestRunLocal_ExitCodeSuccess(t *testing.T) {
+	code, doc := runLocalExit(t, "--backend", "echo")
+	if code != exitOK || doc.Reason != "ok" || doc.Error != "" {
+		t.Fatalf("unexpected exit: code=%d reason=%#v", code, doc)
+	}
+}
+
+func TestRunLocal_ExitCodeConfigError(t *testing.T) {
+	code, doc := runLocalExit(t, "--backend", "echo", "--min-completeness", "2")
+	if code != exitConfig || doc.Reason != "config" {
+		t.Fatalf("unexpected exit for invalid flag: code=%d reason=%#v", code, doc)
+	}
+
+	code, doc = runLocalExit(t, "--backend", "echo", "--input-filter", "no-operator")
+	if code != exitConfig || doc.Reason != "config" || doc.Error == "" {
+		t.Fatalf("unexpected exit for invalid run option: code=%d reason=%#v", code, doc)
+	}
+}
+
+func TestRunLocal_ExitCodeTransientExhausted(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = fmt.Fprint(w, `{"error":{"code":503,"message":"overloaded","status":"UNAVAILABLE"}}`)
+	}))
+	t.Cleanup(ts.Close)
+	t.Setenv("GEMINI_API_KEY", "test-key")
+
+	code, doc := runLocalExit(t,
+		"--backend", "gemini",
+		"--gemini-model", "test-model",
+		"--gemini-base-url", ts.URL,
+		"--max-retries", "0",
+		"--fail-fast",
+	)
+	if code != exitTransientExhausted || doc.Reason != "transient_exhausted" || doc.Error == "" {
+		t.Fatalf("unexpected exit: code=%d reason=%#v", code, doc)
+	}
+}
+
+func TestExitCodeFor(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "nil", err: nil, want: exitOK},
+		{name: "generic", err: fmt.Errorf("boom"), want: exitRunFailed},
+		{name: "enrich transient", err: fmt.Errorf("enrich: %w", &enrich.TransientError{Err: fmt.Errorf("503")}), want: exitTransientExhausted},
+		{name: "write budget", err: fmt.Errorf("upload: %w", foundryio.ErrWriteRetryBudgetExhausted), want: exitTransientExhausted},
+		{name: "read-only", err: fmt.Errorf("commit: %w", foundryio.ErrStackReadOnly), want: exitStackReadOnly},
+		{name: "error rate", err: fmt.Errorf("run: %w", app.ErrErrorRateExceeded), want: exitThresholdExceeded},
+		{name: "error count", err: fmt.Errorf("run: %w", app.ErrErrorCountExceeded), want: exitThresholdExceeded},
+	}
+	for _, tc := range cases {
+		if got := exitCodeFor(tc.err); got != tc.want {
+			t.Fatalf("%s: exitCodeFor=%d want %d", tc.name, got, tc.want)
+		}
+	}
+}
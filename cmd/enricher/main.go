@@ -4,22 +4,35 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"math"
 	"os"
+	"os/signal"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/enrich"
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/enrich/echo"
 	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/enrich/gemini"
 	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
 	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
 	internalversion "github.com/palantir/palantir-compute-module-pipeline-search/internal/version"
 	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
 	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry/keepalive"
+	foundryio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/foundry"
+	localio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/local"
 	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/redact"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/schema"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/worker"
 )
 
 func main() {
-	ctx := context.Background()
+	// The first SIGINT/SIGTERM cancels ctx, so a run stops enriching and drains the rows it already
+	// has (see --drain-timeout); restoring the default handling lets a second signal kill it.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	context.AfterFunc(ctx, stop)
 
 	if len(os.Args) < 2 {
 		usage(os.Stderr)
@@ -31,12 +44,14 @@ func main() {
 		usage(os.Stdout)
 		return
 	case "version", "--version":
-		_, _ = fmt.Fprintln(os.Stdout, internalversion.Current)
+		_, _ = fmt.Fprintln(os.Stdout, internalversion.Version)
 		return
 	case "local":
 		os.Exit(runLocal(ctx, os.Args[2:]))
 	case "foundry":
 		os.Exit(runFoundry(ctx, os.Args[2:]))
+	case "verify":
+		os.Exit(runVerify(ctx, os.Args[2:]))
 	default:
 		_, _ = fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", os.Args[1])
 		usage(os.Stderr)
@@ -44,7 +59,11 @@ func main() {
 	}
 }
 
-func runLocal(ctx context.Context, args []string) int {
+func runLocal(ctx context.Context, args []string) (code int) {
+	var exitReasonFile string
+	var runErr error
+	defer func() { reportExitReason(exitReasonFile, code, runErr) }()
+
 	pipeEnv, err := loadPipelineOptionsFromEnv()
 	if err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "config error: %s\n", redact.Secrets(err.Error()))
@@ -60,58 +79,185 @@ func runLocal(ctx context.Context, args []string) int {
 	fs.SetOutput(os.Stderr)
 	var inputPath string
 	var outputPath string
+	var outputFormat string
 	var workers int
 	var maxRetries int
 	var requestTimeout time.Duration
+	var rampInterval time.Duration
+	var retryProfile string
+	var timeoutBackoffFactor float64
 	var rateLimitRPS float64
 	var failFast bool
 	var geminiModel string
 	var geminiBaseURL string
 	var captureAudit bool
+	var backend string
+	var emailColumns string
+	var emailColumn string
+	var captureRawResponse bool
+	var captureUsage bool
+	var minCompleteness float64
+	var omitAuditColumns bool
+	var auditSink string
+	var failOnAnyError bool
+	var failOnErrorCount int
+	var postProcess string
+	var passthroughColumns string
+	var contextColumns string
+	var inputFilter string
+	var domainAllow string
+	var domainDeny string
 
 	fs.StringVar(&inputPath, "input", "", "Input CSV file path (must include an 'email' column)")
-	fs.StringVar(&outputPath, "output", "", "Output CSV file path")
+	fs.StringVar(&outputPath, "output", "", "Output file path, or local-csv://<path> | local-jsonl://<path> | stdout-ndjson:// (one JSON object per row on stdout)")
+	fs.StringVar(&outputFormat, "output-format", app.OutputFormatCSV, outputFormatUsage)
 	fs.IntVar(&workers, "workers", pipeEnv.Workers, "Number of concurrent enrichment workers (env: WORKERS)")
 	fs.IntVar(&maxRetries, "max-retries", pipeEnv.MaxRetries, "Max retries per email for transient failures (env: MAX_RETRIES)")
 	fs.DurationVar(&requestTimeout, "request-timeout", pipeEnv.RequestTimeout, "Per-email request timeout (env: REQUEST_TIMEOUT)")
+	fs.Float64Var(&timeoutBackoffFactor, "timeout-backoff-factor", 1, timeoutBackoffFactorUsage)
+	fs.DurationVar(&rampInterval, "worker-ramp-interval", pipeEnv.RampInterval, workerRampIntervalUsage)
+	fs.StringVar(&retryProfile, "retry-profile", envString("RETRY_PROFILE", ""), retryProfileUsage)
 	fs.Float64Var(&rateLimitRPS, "rate-limit-rps", pipeEnv.RateLimitRPS, "Global request rate limit (RPS), 0 disables (env: RATE_LIMIT_RPS)")
 	fs.BoolVar(&failFast, "fail-fast", pipeEnv.FailFast, "Fail fast on first enrichment error (env: FAIL_FAST)")
 	fs.StringVar(&geminiModel, "gemini-model", gemEnv.Model, "Gemini model name (env: GEMINI_MODEL)")
 	fs.StringVar(&geminiBaseURL, "gemini-base-url", gemEnv.BaseURL, "Gemini API base URL override (env: GEMINI_BASE_URL)")
 	fs.BoolVar(&captureAudit, "capture-audit", gemEnv.CaptureAudit, "Capture sources/queries into output (env: GEMINI_CAPTURE_AUDIT)")
+	fs.BoolVar(&captureRawResponse, "capture-raw-response", gemEnv.CaptureRawResponse, "Capture the redacted, truncated raw model response into a raw_response column (env: GEMINI_CAPTURE_RAW_RESPONSE)")
+	fs.BoolVar(&captureUsage, "capture-usage", false, captureUsageUsage)
+	fs.Float64Var(&minCompleteness, "min-completeness", 0, minCompletenessUsage)
+	fs.BoolVar(&omitAuditColumns, "omit-audit-columns", false, omitAuditColumnsUsage)
+	fs.BoolVar(&failOnAnyError, "fail-on-any-error", false, failOnAnyErrorUsage)
+	fs.IntVar(&failOnErrorCount, "fail-on-error-count", 0, failOnErrorCountUsage)
+	fs.StringVar(&auditSink, "audit-sink", "", "Append one redacted JSON audit record per enriched email (email hashed under PII_HASH_KEY, returned fields, model, timestamp) to this file")
+	fs.StringVar(&backend, "backend", envString("ENRICH_BACKEND", "gemini"), "Enrichment backend: gemini|echo (env: ENRICH_BACKEND)")
+	fs.StringVar(&emailColumns, "email-columns", "", "Comma-separated email columns to fan out into one row each, tagged with source_row (default: email)")
+	fs.StringVar(&emailColumn, "email-column", envString("INPUT_EMAIL_COLUMN", ""), emailColumnUsage)
+	fs.StringVar(&postProcess, "post-process", "", postProcessUsage)
+	fs.StringVar(&passthroughColumns, "passthrough-columns", "", passthroughColumnsUsage)
+	fs.StringVar(&contextColumns, "context-columns", "", contextColumnsUsage)
+	fs.StringVar(&inputFilter, "input-filter", "", inputFilterUsage)
+	fs.StringVar(&domainAllow, "enrich-domain-allow", "", enrichDomainAllowUsage)
+	fs.StringVar(&domainDeny, "enrich-domain-deny", "", enrichDomainDenyUsage)
+	fs.StringVar(&exitReasonFile, "exit-reason-file", "", exitReasonFileUsage)
+	var sampling gemini.Config
+	bindGeminiSamplingFlags(fs, &sampling)
+	var promptDump promptDumpFlags
+	bindPromptDumpFlags(fs, &promptDump)
+	var csvOpts localio.CSVReadOptions
+	bindCSVLimitFlags(fs, &csvOpts.CSVLimits)
+	bindEmailColumnAliasesFlag(fs, &csvOpts)
+	bindSkipRowFlags(fs, &csvOpts)
 	if err := fs.Parse(args); err != nil {
 		return 2
 	}
+	profile, err := applyRetryProfile(fs, retryProfile, os.Getenv)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "config error: %s\n", err)
+		return 2
+	}
 	if inputPath == "" || outputPath == "" {
 		_, _ = fmt.Fprintln(os.Stderr, "local requires --input and --output")
 		return 2
 	}
+	if err := validateMinCompleteness(minCompleteness); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "config error: %s\n", err)
+		return 2
+	}
+	if err := validateTimeoutBackoffFactor(timeoutBackoffFactor); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "config error: %s\n", err)
+		return 2
+	}
+	if err := validateOmitAuditColumns(omitAuditColumns, captureAudit); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "config error: %s\n", err)
+		return 2
+	}
+	postProcessors, err := pipeline.ParsePostProcessors(splitList(postProcess))
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "config error: %s\n", err)
+		return 2
+	}
+	allowDomains, err := domainList(domainAllow, "--enrich-domain-allow")
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "config error: %s\n", err)
+		return 2
+	}
+	denyDomains, err := domainList(domainDeny, "--enrich-domain-deny")
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "config error: %s\n", err)
+		return 2
+	}
 
-	enricher, err := gemini.New(ctx, gemini.Config{
-		APIKey:       gemEnv.APIKey,
-		Model:        geminiModel,
-		BaseURL:      geminiBaseURL,
-		CaptureAudit: captureAudit,
+	promptHook, err := promptDump.hook(piiHashKey())
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "config error: %s\n", err)
+		return 2
+	}
+	enricher, err := newEnricher(ctx, backend, gemini.Config{
+		Model:              geminiModel,
+		BaseURL:            geminiBaseURL,
+		CaptureAudit:       captureAudit,
+		CaptureRawResponse: captureRawResponse,
+		Temperature:        sampling.Temperature,
+		TopK:               sampling.TopK,
+		TopP:               sampling.TopP,
+		Candidates:         sampling.Candidates,
+		DisableSearch:      sampling.DisableSearch,
+		DisableURLContext:  sampling.DisableURLContext,
+		PromptHook:         promptHook,
 	})
 	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "gemini config error: %s\n", redact.Secrets(err.Error()))
+		_, _ = fmt.Fprintf(os.Stderr, "enricher config error: %s\n", redact.Secrets(err.Error()))
 		return 2
 	}
 
-	if err := app.RunLocal(ctx, inputPath, outputPath, pipeline.Options{
-		Workers:        workers,
-		MaxRetries:     maxRetries,
-		RequestTimeout: requestTimeout,
-		RateLimitRPS:   rateLimitRPS,
-		FailFast:       failFast,
-	}, enricher); err != nil {
+	res, err := app.RunLocalWithOptions(ctx, app.LocalOptions{
+		InputPath:          inputPath,
+		OutputPath:         outputPath,
+		OutputFormat:       outputFormat,
+		EmailColumns:       splitList(emailColumns),
+		EmailColumn:        emailColumn,
+		PassthroughColumns: splitList(passthroughColumns),
+		ContextColumns:     splitList(contextColumns),
+		InputFilter:        splitList(inputFilter),
+		EnrichDomainAllow:  allowDomains,
+		EnrichDomainDeny:   denyDomains,
+		CaptureRawResponse: captureRawResponse,
+		OmitAuditColumns:   omitAuditColumns,
+		AuditSink:          auditSink,
+		AuditHashKey:       piiHashKey(),
+		CSVReadOptions:     csvOpts,
+		FailOnAnyError:     failOnAnyError,
+		FailOnErrorCount:   failOnErrorCount,
+	}, pipeline.Options{
+		Workers:              workers,
+		MaxRetries:           maxRetries,
+		RequestTimeout:       requestTimeout,
+		RequestTimeoutFactor: timeoutBackoffFactor,
+		RampInterval:         rampInterval,
+		RetryBackoffInitial:  profile.BackoffInitial,
+		RetryBackoffMax:      profile.BackoffMax,
+		RateLimitRPS:         rateLimitRPS,
+		FailFast:             failFast,
+		PostProcessors:       postProcessors,
+		CaptureUsage:         captureUsage,
+		CaptureCandidate:     sampling.Candidates > 1,
+		MinCompleteness:      minCompleteness,
+	}, enricher)
+	if err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "local run failed: %s\n", redact.Secrets(err.Error()))
-		return 1
+		runErr = err
+		return exitCodeFor(err)
 	}
+	// stdout may carry stdout-ndjson output, so the summary goes to stderr.
+	printRunSummary(os.Stderr, res)
 	return 0
 }
 
-func runFoundry(ctx context.Context, args []string) int {
+func runFoundry(ctx context.Context, args []string) (code int) {
+	var exitReasonFile string
+	var runErr error
+	defer func() { reportExitReason(exitReasonFile, code, runErr) }()
+
 	pipeEnv, err := loadPipelineOptionsFromEnv()
 	if err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "config error: %s\n", redact.Secrets(err.Error()))
@@ -122,24 +268,185 @@ func runFoundry(ctx context.Context, args []string) int {
 		_, _ = fmt.Fprintf(os.Stderr, "config error: %s\n", redact.Secrets(err.Error()))
 		return 2
 	}
+	defaultWriteMode, err := envOutputWriteMode("OUTPUT_WRITE_MODE")
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "config error: %s\n", err)
+		return 2
+	}
 
 	fs := flag.NewFlagSet("foundry", flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
 	inputAlias := fs.String("input-alias", "input", "Alias name for the input dataset in RESOURCE_ALIAS_MAP")
+	inputFile := fs.String("input-file", "", "Read the input from this local CSV instead of the --input-alias dataset, still writing the output to Foundry (for reproducing output-side issues with a fixed input)")
+	extraInputAliases := fs.String("extra-input-aliases", "", "Comma-separated aliases of further input datasets, read concurrently with --input-alias and merged (first occurrence of each email wins)")
 	outputAlias := fs.String("output-alias", "output", "Alias name for the output dataset in RESOURCE_ALIAS_MAP")
-	outputFilename := fs.String("output-filename", "enriched.csv", "Filename to upload into the output dataset transaction (dataset mode only)")
-	outputWriteMode := fs.String("output-write-mode", "auto", "Output write mode: auto|dataset|stream (auto probes stream-proxy first)")
+	output := fs.String("output", "", "Output as foundry-dataset://<alias> | foundry-stream://<alias>; overrides --output-alias and --output-write-mode")
+	outputFilename := fs.String("output-filename", "", "Filename to upload into the output dataset transaction (dataset mode only; default: enriched.csv, or enriched.jsonl with --output-format=jsonl)")
+	outputFormat := fs.String("output-format", app.OutputFormatCSV, outputFormatUsage)
+	outputWriteMode := fs.String("output-write-mode", defaultWriteMode, "Output write mode: auto|dataset|stream|both (auto probes stream-proxy first) (env: OUTPUT_WRITE_MODE)")
+	streamOutputAlias := fs.String("stream-output-alias", "", "Alias of the stream written in --output-write-mode=both (defaults to --output-alias)")
+	streamPartitionKey := fs.String("stream-partition-key", "email", "Record field used as the stream partition key, or none to publish unkeyed")
+	incrementalBaseTxn := fs.String("incremental-base-txn", "", "Seed the incremental cache from the dataset output as of this committed transaction RID instead of the branch head (dataset output only)")
+	streamDelivery := fs.String("stream-delivery", foundryio.StreamDeliveryAtLeastOnce, "Stream publish semantics: at-least-once (retries may duplicate a record) | exactly-once (idempotency keys and acknowledgement checks; the stack must deduplicate by Idempotency-Key)")
+	streamCacheMaxRecords := fs.Int("stream-cache-max-records", app.DefaultStreamCacheMaxRecords, "Max prior stream records read into the incremental cache (stream mode); older records past the cap are dropped and their emails re-enriched. Negative reads all")
+	postRunIdleTimeout := fs.Duration("post-run-idle-timeout", 0, "With the compute module client enabled, exit cleanly once no compute module job has arrived for this long after the run, instead of staying alive forever; 0 keeps the module alive")
+	runID := fs.String("run-id", envString("RUN_ID", ""), "Run id stamped on log lines and stream records, for reproducible records across restarts (env: RUN_ID; default: generated per run)")
+	streamMetaPrefix := fs.String("stream-meta-prefix", "", "Prefix for the run_id/written_at metadata fields on stream records, for example _meta_ (default: no prefix)")
+	streamSchemaFile := fs.String("stream-schema-file", "", streamSchemaFileUsage)
+	verifyStreamWrites := fs.Bool("verify-stream-writes", false, "After publishing, read the stream back and warn if fewer of this run's records are present than were published")
+	verifyStreamWritesWait := fs.Duration("verify-stream-writes-wait", 10*time.Second, "With --verify-stream-writes, how long to poll for records the stream does not show yet before warning (0 reads once)")
+	indexAlias := fs.String("index-alias", "", "Optional alias of a dataset used to persist an incremental index (dataset mode only)")
+	maxUniqueEnrich := fs.Int("max-unique-enrich", 0, "Max distinct emails to enrich per run, 0 disables")
+	onBudgetExceeded := fs.String("on-budget-exceeded", "fail", "Behavior when --max-unique-enrich is exceeded: fail|truncate")
+	maxErrorRate := fs.Float64("max-error-rate", 0, "Dataset output: fail the run when more than this fraction (0-1) of the emails enriched in it end with status=error; 0 disables")
+	onThresholdFailure := fs.String("on-threshold-failure", "abort", "Behavior when --max-error-rate is exceeded: abort (write nothing) or commit-anyway (write, then fail)")
+	failOnAnyError := fs.Bool("fail-on-any-error", false, failOnAnyErrorUsage)
+	failOnErrorCount := fs.Int("fail-on-error-count", 0, failOnErrorCountUsage)
 	workers := fs.Int("workers", pipeEnv.Workers, "Number of concurrent enrichment workers (env: WORKERS)")
 	maxRetries := fs.Int("max-retries", pipeEnv.MaxRetries, "Max retries per email for transient failures (env: MAX_RETRIES)")
 	requestTimeout := fs.Duration("request-timeout", pipeEnv.RequestTimeout, "Per-email request timeout (env: REQUEST_TIMEOUT)")
+	timeoutBackoffFactor := fs.Float64("timeout-backoff-factor", 1, timeoutBackoffFactorUsage)
+	rampInterval := fs.Duration("worker-ramp-interval", pipeEnv.RampInterval, workerRampIntervalUsage)
+	retryProfile := fs.String("retry-profile", envString("RETRY_PROFILE", ""), retryProfileUsage)
 	rateLimitRPS := fs.Float64("rate-limit-rps", pipeEnv.RateLimitRPS, "Global request rate limit (RPS), 0 disables (env: RATE_LIMIT_RPS)")
 	failFast := fs.Bool("fail-fast", pipeEnv.FailFast, "Fail fast on first enrichment error (env: FAIL_FAST)")
+	failFastKeepPartial := fs.Bool("fail-fast-keep-partial", false, "Like --fail-fast, but let in-flight emails finish and write the rows completed so far to the dataset output (emails never started are written as pending) before failing")
 	geminiModel := fs.String("gemini-model", gemEnv.Model, "Gemini model name (env: GEMINI_MODEL)")
 	geminiBaseURL := fs.String("gemini-base-url", gemEnv.BaseURL, "Gemini API base URL override (env: GEMINI_BASE_URL)")
 	captureAudit := fs.Bool("capture-audit", gemEnv.CaptureAudit, "Capture sources/queries into output (env: GEMINI_CAPTURE_AUDIT)")
+	captureRawResponse := fs.Bool("capture-raw-response", gemEnv.CaptureRawResponse, "Capture the redacted, truncated raw model response into a raw_response column (env: GEMINI_CAPTURE_RAW_RESPONSE)")
+	captureUsage := fs.Bool("capture-usage", false, captureUsageUsage)
+	minCompleteness := fs.Float64("min-completeness", 0, minCompletenessUsage)
+	maxPartialAttempts := fs.Int("max-partial-attempts", pipeline.DefaultMaxPartialAttempts, "With --min-completeness, stop re-enriching an email once this many runs in a row left it status=partial, keeping the cached partial row; a negative value retries on every run (0 uses the default)")
+	omitAuditColumns := fs.Bool("omit-audit-columns", false, omitAuditColumnsUsage)
+	auditSink := fs.String("audit-sink", "", "Write one redacted JSON audit record per enriched email (email hashed under PII_HASH_KEY, returned fields, model, timestamp, run id) to a file path or foundry-stream://<alias>")
+	backend := fs.String("backend", envString("ENRICH_BACKEND", "gemini"), "Enrichment backend: gemini|echo (env: ENRICH_BACKEND)")
+	emailColumns := fs.String("email-columns", "", "Comma-separated email columns to fan out into one row each, tagged with source_row (default: email)")
+	emailColumn := fs.String("email-column", envString("INPUT_EMAIL_COLUMN", ""), emailColumnUsage)
+	outputSort := fs.String("output-sort", "none", "Dataset output row order: none (input order) | email")
+	writeMaxAttempts := fs.Int("write-max-attempts", foundryio.DefaultWriteRetryPolicy.MaxAttempts, "Max Foundry calls across the dataset create/upload/commit sequence, including retries")
+	writeMaxElapsed := fs.Duration("write-max-elapsed", foundryio.DefaultWriteRetryPolicy.MaxElapsed, "Max time spent on the dataset create/upload/commit sequence, including retries")
+	allowInputOutputSame := fs.Bool("allow-input-output-same", false, "Allow the output alias to resolve to the input dataset and branch (in-place upserts); by default the run fails before enriching")
+	var clientTimeouts foundry.Timeouts
+	fs.DurationVar(&clientTimeouts.Dial, "foundry-dial-timeout", foundry.DefaultTimeouts.Dial, "Timeout for connecting to Foundry, including DNS")
+	fs.DurationVar(&clientTimeouts.TLSHandshake, "foundry-tls-handshake-timeout", foundry.DefaultTimeouts.TLSHandshake, "Timeout for the TLS handshake with Foundry")
+	fs.DurationVar(&clientTimeouts.ResponseHeader, "foundry-response-header-timeout", foundry.DefaultTimeouts.ResponseHeader, "Timeout for Foundry response headers after a request is sent (0: only --foundry-request-timeout applies)")
+	fs.DurationVar(&clientTimeouts.Overall, "foundry-request-timeout", foundry.DefaultTimeouts.Overall, "Overall timeout for one Foundry request, including reading the response body")
+	operationTimeouts := fs.String("foundry-operation-timeouts", "", fmt.Sprintf("Comma-separated <operation>=<duration> overrides of --foundry-request-timeout, e.g. commitTransaction=10m for slow commits of large datasets (operations: %s)", strings.Join(foundry.OperationNames(), ", ")))
+	inputReadRetries := fs.Int("input-read-retries", foundryio.DefaultRetryPolicy.Attempts-1, "Retries of the input dataset read after a transient failure; each retry re-reads the whole table. Independent of the --write-max-* budget")
+	inputReadTimeout := fs.Duration("input-read-timeout", 0, "Timeout for each input dataset read attempt; a timed-out attempt is retried (0 disables)")
+	publishRetries := fs.Int("publish-retries", foundryio.DefaultRetryPolicy.Attempts-1, "Retries of a stream publish after a transient failure, independent of read and dataset write retries; exactly-once publishes are further capped by --publish-idempotency-window")
+	publishBackoffInitial := fs.Duration("publish-backoff-initial", foundryio.DefaultRetryPolicy.InitialSleep, "Wait before the first stream publish retry; doubles for each further retry up to --publish-backoff-max")
+	publishBackoffMax := fs.Duration("publish-backoff-max", foundryio.DefaultRetryPolicy.MaxSleep, "Max wait between stream publish retries")
+	publishNoUnkeyedRetries := fs.Bool("publish-no-unkeyed-retries", false, "Publish each record once under --stream-delivery=at-least-once, failing the run on a publish error instead of retrying, since a retry may store the record twice")
+	publishIdempotencyWindow := fs.Duration("publish-idempotency-window", foundryio.DefaultPublishIdempotencyWindow, "How long the stack deduplicates idempotency keys; exactly-once publishes stop retrying before their backoff exceeds it")
+	priorOutputNotFoundRetries := fs.Int("prior-output-not-found-retries", app.DefaultPriorOutputNotFoundRetries, "Re-reads of the prior output after a not-found response before the incremental read treats it as absent, for freshly committed outputs on eventually consistent stacks (0 disables)")
+	priorOutputNotFoundBackoff := fs.Duration("prior-output-not-found-backoff", app.DefaultPriorOutputNotFoundBackoff, "Wait before the first prior-output not-found re-read; doubles for each further re-read")
+	readOnlyErrorNames := fs.String("read-only-error-names", strings.Join(foundryio.DefaultReadOnlyErrorNames, ","), "Comma-separated Foundry error names that mean the stack is in read-only maintenance; writes failing with them fail fast without retries (empty disables)")
+	commitEvery := fs.Int("commit-every", 0, commitEveryUsage)
+	tagOutput := fs.Bool("tag-output", false, "Dataset output: after the final commit, create a branch named after the run id pointing at the committed transaction, so downstream can pin this run's output")
+	cleanupOpenTransactions := fs.Bool("cleanup-open-transactions", false, "Dataset output: before the run, abort stale OPEN transactions on the output branch left by crashed runs, keeping only the newest for reuse")
+	drainTimeout := fs.Duration("drain-timeout", app.DefaultDrainTimeout, "On a shutdown signal, keep publishing the stream rows and audit records of emails already enriched for up to this long before exiting; 0 drops them")
+	cleanupOpenTransactionsMax := fs.Int("cleanup-open-transactions-max", app.DefaultCleanupOpenTransactionsMax, "Max stale OPEN transactions --cleanup-open-transactions aborts per run; the rest wait for later runs (must be > 0)")
+	recacheEmptyOK := fs.Bool("recache-empty-ok", false, "Treat a prior ok row with no enrichment fields ("+strings.Join(pipeline.CompletenessFields(), ", ")+") as a cache miss and enrich it again")
+	reenrichAfter := fs.Duration("reenrich-after", 0, "Stamp enriched rows with a written_at time and enrich a prior ok row again once it is at least this old, for example 720h; 0 re-enriches every ok row (default: unset, ok rows stay cached indefinitely)")
+	recacheOnSchemaChange := fs.Bool("recache-on-schema-change", false, "Treat every prior row as a cache miss when the prior dataset output's columns differ from this run's output (dataset output only)")
+	outputChecksum := fs.Bool("output-checksum", false, "Upload a <output-filename>.sha256 sidecar with the hex SHA-256 of the dataset output")
+	postProcess := fs.String("post-process", "", postProcessUsage)
+	passthroughColumns := fs.String("passthrough-columns", "", passthroughColumnsUsage)
+	contextColumns := fs.String("context-columns", "", contextColumnsUsage)
+	inputFilter := fs.String("input-filter", "", inputFilterUsage)
+	domainAllow := fs.String("enrich-domain-allow", "", enrichDomainAllowUsage)
+	domainDeny := fs.String("enrich-domain-deny", "", enrichDomainDenyUsage)
+	fs.StringVar(&exitReasonFile, "exit-reason-file", "", exitReasonFileUsage)
+	ensureHeader := fs.Bool("ensure-header", false, "Treat an input dataset with no data as zero rows and still commit a header-only dataset output")
+	var sampling gemini.Config
+	bindGeminiSamplingFlags(fs, &sampling)
+	var promptDump promptDumpFlags
+	bindPromptDumpFlags(fs, &promptDump)
+	var csvOpts localio.CSVReadOptions
+	bindCSVLimitFlags(fs, &csvOpts.CSVLimits)
+	bindEmailColumnAliasesFlag(fs, &csvOpts)
+	bindSkipRowFlags(fs, &csvOpts)
+	watchInterval := fs.Duration("watch-interval", 0, fmt.Sprintf("Re-run the incremental pipeline on this interval after the first run (min %s; 0 runs once). Ticks are skipped while a run is still executing", minWatchInterval))
 	if err := fs.Parse(args); err != nil {
 		return 2
 	}
+	profile, err := applyRetryProfile(fs, *retryProfile, os.Getenv)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "config error: %s\n", err)
+		return 2
+	}
+	if err := validateMinCompleteness(*minCompleteness); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "config error: %s\n", err)
+		return 2
+	}
+	if err := validateTimeoutBackoffFactor(*timeoutBackoffFactor); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "config error: %s\n", err)
+		return 2
+	}
+	if err := validateOmitAuditColumns(*omitAuditColumns, *captureAudit); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "config error: %s\n", err)
+		return 2
+	}
+	opTimeouts, err := parseOperationTimeouts(*operationTimeouts)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "config error: %s\n", err)
+		return 2
+	}
+	clientTimeouts.Operations = opTimeouts
+	if *inputReadRetries < 0 || *inputReadTimeout < 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "config error: --input-read-retries and --input-read-timeout must be >= 0")
+		return 2
+	}
+	if *publishRetries < 0 || *publishBackoffInitial < 0 || *publishBackoffMax < 0 || *publishIdempotencyWindow < 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "config error: --publish-retries, --publish-backoff-initial, --publish-backoff-max, and --publish-idempotency-window must be >= 0")
+		return 2
+	}
+	if *reenrichAfter < 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "config error: --reenrich-after must be >= 0")
+		return 2
+	}
+	if *priorOutputNotFoundRetries < 0 || *priorOutputNotFoundBackoff <= 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "config error: --prior-output-not-found-retries must be >= 0 and --prior-output-not-found-backoff > 0")
+		return 2
+	}
+	if *postRunIdleTimeout < 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "config error: --post-run-idle-timeout must be >= 0")
+		return 2
+	}
+	if *verifyStreamWritesWait < 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "config error: --verify-stream-writes-wait must be >= 0")
+		return 2
+	}
+	if *drainTimeout < 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "config error: --drain-timeout must be >= 0")
+		return 2
+	}
+	if *cleanupOpenTransactionsMax <= 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "config error: --cleanup-open-transactions-max must be > 0")
+		return 2
+	}
+	postProcessors, err := pipeline.ParsePostProcessors(splitList(*postProcess))
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "config error: %s\n", err)
+		return 2
+	}
+	allowDomains, err := domainList(*domainAllow, "--enrich-domain-allow")
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "config error: %s\n", err)
+		return 2
+	}
+	denyDomains, err := domainList(*domainDeny, "--enrich-domain-deny")
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "config error: %s\n", err)
+		return 2
+	}
+	streamContract, err := loadStreamContract(*streamSchemaFile)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "config error: %s\n", err)
+		return 2
+	}
 
 	env, err := foundry.LoadEnv()
 	if err != nil {
@@ -150,53 +457,185 @@ func runFoundry(ctx context.Context, args []string) int {
 	// Some Foundry stacks expect the compute module to poll the internal runtime (GET_JOB_URI) in order
 	// to be considered responsive. The TypeScript SDK does this automatically in the background.
 	//
-	// In pipeline mode we still run our pipeline logic autonomously; this background loop is only to
-	// satisfy the runtime health expectations and to avoid leaving internal jobs un-acked.
+	// In pipeline mode we still run our pipeline logic autonomously; this background loop satisfies
+	// the runtime health expectations, acks internal jobs, and serves the cancelEmail control
+	// function, which stops one in-flight email of the current run, and the stats function, which
+	// reports the run's metrics (see newControlHandler).
 	cmCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
+	canceler := worker.NewCanceler(app.CancelKey)
+	stats := &app.RunStats{}
 	keepAlive := false
+	keepAliveStatus := &keepalive.Status{}
 	if ccfg, ok, err := keepalive.LoadConfigFromEnv(); err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "compute module client config error: %s\n", redact.Secrets(err.Error()))
 		return 2
 	} else if ok {
 		keepAlive = true
+		ccfg.Status = keepAliveStatus
+		ccfg.PostRetries = profile.KeepalivePostRetries
+		ccfg.PollBackoffMax = profile.KeepalivePollBackoffMax
 		go func() {
-			_ = keepalive.RunLoop(cmCtx, ccfg, func(context.Context, keepalive.Job) ([]byte, error) {
-				// We don't expose any interactive functions; acknowledge any internal jobs so they don't block routing.
-				return []byte("ok"), nil
-			})
+			_ = keepalive.RunLoop(cmCtx, ccfg, newControlHandler(canceler, stats))
 		}()
 	}
 
-	enricher, err := gemini.New(ctx, gemini.Config{
-		APIKey:       gemEnv.APIKey,
-		Model:        *geminiModel,
-		BaseURL:      *geminiBaseURL,
-		CaptureAudit: *captureAudit,
+	promptHook, err := promptDump.hook(piiHashKey())
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "config error: %s\n", err)
+		return 2
+	}
+	enricher, err := newEnricher(ctx, *backend, gemini.Config{
+		Model:              *geminiModel,
+		BaseURL:            *geminiBaseURL,
+		CaptureAudit:       *captureAudit,
+		CaptureRawResponse: *captureRawResponse,
+		Temperature:        sampling.Temperature,
+		TopK:               sampling.TopK,
+		TopP:               sampling.TopP,
+		Candidates:         sampling.Candidates,
+		DisableSearch:      sampling.DisableSearch,
+		DisableURLContext:  sampling.DisableURLContext,
+		PromptHook:         promptHook,
 	})
 	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "gemini config error: %s\n", redact.Secrets(err.Error()))
+		_, _ = fmt.Fprintf(os.Stderr, "enricher config error: %s\n", redact.Secrets(err.Error()))
 		return 2
 	}
 
-	// Pipeline execution: run once on container start.
-	if err := app.RunFoundry(ctx, env, *inputAlias, *outputAlias, *outputFilename, *outputWriteMode, pipeline.Options{
-		Workers:        *workers,
-		MaxRetries:     *maxRetries,
-		RequestTimeout: *requestTimeout,
-		RateLimitRPS:   *rateLimitRPS,
-		FailFast:       *failFast,
-	}, enricher); err != nil {
+	// Pipeline execution: run once on container start, then on each --watch-interval tick.
+	runOnce := func(ctx context.Context) error {
+		res, err := app.RunFoundryWithOptions(ctx, env, app.FoundryOptions{
+			InputAlias:             *inputAlias,
+			InputFile:              *inputFile,
+			ExtraInputAliases:      splitList(*extraInputAliases),
+			OutputAlias:            *outputAlias,
+			OutputFilename:         *outputFilename,
+			OutputFormat:           *outputFormat,
+			Stats:                  stats,
+			OutputWriteMode:        *outputWriteMode,
+			Output:                 *output,
+			IndexAlias:             *indexAlias,
+			MaxUniqueEnrich:        *maxUniqueEnrich,
+			MaxErrorRate:           *maxErrorRate,
+			OnThresholdFailure:     *onThresholdFailure,
+			FailOnAnyError:         *failOnAnyError,
+			FailOnErrorCount:       *failOnErrorCount,
+			OnBudgetExceeded:       *onBudgetExceeded,
+			StreamOutputAlias:      *streamOutputAlias,
+			StreamPartitionKey:     *streamPartitionKey,
+			StreamDelivery:         *streamDelivery,
+			StreamMetaPrefix:       *streamMetaPrefix,
+			StreamContract:         streamContract,
+			RunID:                  *runID,
+			StreamCacheMaxRecords:  *streamCacheMaxRecords,
+			VerifyStreamWrites:     *verifyStreamWrites,
+			VerifyStreamWritesWait: *verifyStreamWritesWait,
+			IncrementalBaseTxn:     *incrementalBaseTxn,
+			RecacheEmptyOK:         *recacheEmptyOK,
+			RecacheOnSchemaChange:  *recacheOnSchemaChange,
+			EmailColumns:           splitList(*emailColumns),
+			EmailColumn:            *emailColumn,
+			PassthroughColumns:     splitList(*passthroughColumns),
+			ContextColumns:         splitList(*contextColumns),
+			InputFilter:            splitList(*inputFilter),
+			EnrichDomainAllow:      allowDomains,
+			EnrichDomainDeny:       denyDomains,
+			CSVReadOptions:         csvOpts,
+			CaptureRawResponse:     *captureRawResponse,
+			OmitAuditColumns:       *omitAuditColumns,
+			AuditSink:              *auditSink,
+			AuditHashKey:           piiHashKey(),
+			OutputSort:             *outputSort,
+			WriteRetryPolicy: foundryio.WriteRetryPolicy{
+				MaxAttempts:        *writeMaxAttempts,
+				MaxElapsed:         *writeMaxElapsed,
+				ReadOnlyErrorNames: readOnlyNames(*readOnlyErrorNames),
+			},
+			ClientTimeouts:       clientTimeouts,
+			AllowInputOutputSame: *allowInputOutputSame,
+			InputReadPolicy: foundryio.ReadRetryPolicy{
+				Attempts:       *inputReadRetries + 1,
+				AttemptTimeout: *inputReadTimeout,
+			},
+			PublishRetryPolicy: foundryio.PublishRetryPolicy{
+				Attempts:          *publishRetries + 1,
+				InitialSleep:      *publishBackoffInitial,
+				MaxSleep:          *publishBackoffMax,
+				NoUnkeyedRetries:  *publishNoUnkeyedRetries,
+				IdempotencyWindow: *publishIdempotencyWindow,
+			},
+			PriorOutputNotFoundRetries: priorOutputNotFoundRetriesOption(*priorOutputNotFoundRetries),
+			PriorOutputNotFoundBackoff: *priorOutputNotFoundBackoff,
+			OutputChecksum:             *outputChecksum,
+			EnsureHeader:               *ensureHeader,
+			CommitEvery:                *commitEvery,
+			CleanupOpenTransactions:    *cleanupOpenTransactions,
+			TagOutput:                  *tagOutput,
+			CleanupOpenTransactionsMax: *cleanupOpenTransactionsMax,
+			DrainTimeout:               *drainTimeout,
+		}, pipeline.Options{
+			Workers:              *workers,
+			MaxRetries:           *maxRetries,
+			RequestTimeout:       *requestTimeout,
+			RequestTimeoutFactor: *timeoutBackoffFactor,
+			RampInterval:         *rampInterval,
+			RetryBackoffInitial:  profile.BackoffInitial,
+			RetryBackoffMax:      profile.BackoffMax,
+			RateLimitRPS:         *rateLimitRPS,
+			FailFast:             *failFast,
+			FailFastKeepPartial:  *failFastKeepPartial,
+			PostProcessors:       postProcessors,
+			CaptureUsage:         *captureUsage,
+			CaptureCandidate:     sampling.Candidates > 1,
+			MinCompleteness:      *minCompleteness,
+			MaxPartialAttempts:   *maxPartialAttempts,
+			ReenrichAfter:        reenrichAfterOption(fs, *reenrichAfter),
+			Canceler:             canceler,
+		}, enricher)
+		if err == nil {
+			printRunSummary(os.Stdout, res)
+		}
+		return err
+	}
+	if err := runOnce(ctx); err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "foundry run failed: %s\n", redact.Secrets(err.Error()))
-		return 1
+		runErr = err
+		return exitCodeFor(err)
+	}
+
+	if *watchInterval > 0 {
+		logf := func(format string, args ...any) {
+			_, _ = fmt.Fprintf(os.Stdout, format+"\n", args...)
+		}
+		scheduler := newWatchScheduler(*watchInterval, minWatchInterval, logf)
+		logf("foundry run complete; re-running every %s", scheduler.interval)
+		_ = scheduler.Run(ctx, func(ctx context.Context) {
+			if err := runOnce(ctx); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "foundry watch run failed: %s\n", redact.Secrets(err.Error()))
+			}
+		})
+		return 0
 	}
 
 	// In Foundry Compute Modules, the container is expected to be long-running. If we exit after
 	// producing output, the module will be restarted and the pipeline may re-run, duplicating stream
 	// records. Keep the process alive when Foundry has injected the internal module endpoints.
+	if keepAlive && *postRunIdleTimeout > 0 {
+		// The orchestrator opted into freeing the module once nobody is using it.
+		_, _ = fmt.Fprintf(os.Stdout, "foundry run complete; keeping module alive until no compute module job arrives for %s\n", *postRunIdleTimeout)
+		// A cancelled ctx (shutdown signal) exits as well.
+		if err := keepAliveStatus.WaitIdle(ctx, time.Now(), *postRunIdleTimeout); err == nil {
+			_, _ = fmt.Fprintf(os.Stdout, "no compute module job for %s (%d received); exiting\n", *postRunIdleTimeout, keepAliveStatus.JobsReceived())
+		}
+		return 0
+	}
 	if keepAlive {
 		_, _ = fmt.Fprintln(os.Stdout, "foundry run complete; keeping module alive")
-		select {}
+		// The deferred write never runs while the module is kept alive, so report success now.
+		reportExitReason(exitReasonFile, exitOK, nil)
+		// Only a shutdown signal ends the wait.
+		<-ctx.Done()
 	}
 	return 0
 }
@@ -209,67 +648,173 @@ Usage:
 
 Commands:
   version  Print the current release version
-  local    Run against a local input CSV (Gemini required)
+  local    Run against a local input CSV (Gemini by default; --backend echo needs no credentials)
   foundry  Run in Foundry/pipeline mode (uses BUILD2_TOKEN + RESOURCE_ALIAS_MAP)
+  verify   Check the output dataset has an ok row for every input email, without enriching or writing
 
 Examples:
   enricher local --input emails.csv --output enriched.csv
+  enricher verify --input-alias input --output-alias output
 
 Environment (foundry):
   FOUNDRY_URL         Foundry base URL (e.g. https://<stack>.palantirfoundry.com)
   BUILD2_TOKEN        File path containing a bearer token
-  RESOURCE_ALIAS_MAP  File path containing alias -> {rid, branch} JSON
+  RESOURCE_ALIAS_MAP  File path containing alias -> {rid|path, branch} JSON
+
+Environment (backend):
+  ENRICH_BACKEND  Enrichment backend: gemini (default) or echo (deterministic, no external calls)
 
 Environment (Gemini):
   GEMINI_API_KEY        Gemini API key (required). Can be the literal key or a file path containing the key.
-  GEMINI_MODEL          Gemini model name (required)
+  GEMINI_MODEL          Gemini model name (required unless the Gemini Source provides one)
   GEMINI_BASE_URL       Optional base URL override (proxies/testing)
   GEMINI_CAPTURE_AUDIT  If set to true/1, include sources/queries in output
+  GEMINI_CAPTURE_RAW_RESPONSE  If set to true/1, include the redacted, truncated raw model response in output
 
 Environment (Foundry Sources, optional):
   SOURCE_CREDENTIALS         File path containing a JSON dictionary of Source credentials (injected by Foundry)
   GEMINI_SOURCE_API_NAME     Source API name to read GEMINI key from SOURCE_CREDENTIALS
   GEMINI_SOURCE_SECRET_NAME  Secret name within that Source (if omitted, this binary will try to infer)
 
+Exit codes (see --exit-reason-file for a JSON copy):
+  0  ok
+  1  run failed
+  2  config error
+  3  enrichment budget exceeded (--on-budget-exceeded=fail)
+  4  transient failure outlasted its retries
+  5  stack read-only (maintenance)
+  6  permission denied
+
 `)
 }
 
+// loadGeminiConfigFromEnv loads the non-secret Gemini settings from the env. The API key, and the
+// GEMINI_MODEL and GEMINI_BASE_URL fallback to the Gemini Source (see resolveGeminiSourceSettings),
+// are resolved by newEnricher only when the gemini backend is selected, so other backends never
+// read SOURCE_CREDENTIALS.
 func loadGeminiConfigFromEnv() (gemini.Config, error) {
-	apiKey, err := loadGeminiAPIKey()
+	captureAudit, err := envBool("GEMINI_CAPTURE_AUDIT")
 	if err != nil {
 		return gemini.Config{}, err
 	}
-
-	captureAudit, err := envBool("GEMINI_CAPTURE_AUDIT")
+	captureRawResponse, err := envBool("GEMINI_CAPTURE_RAW_RESPONSE")
 	if err != nil {
 		return gemini.Config{}, err
 	}
 
 	return gemini.Config{
-		APIKey:       apiKey,
-		Model:        strings.TrimSpace(os.Getenv("GEMINI_MODEL")),
-		BaseURL:      strings.TrimSpace(os.Getenv("GEMINI_BASE_URL")),
-		CaptureAudit: captureAudit,
+		Model:              strings.TrimSpace(os.Getenv("GEMINI_MODEL")),
+		BaseURL:            strings.TrimSpace(os.Getenv("GEMINI_BASE_URL")),
+		CaptureAudit:       captureAudit,
+		CaptureRawResponse: captureRawResponse,
 	}, nil
 }
 
-func loadGeminiAPIKey() (string, error) {
-	// 1) Prefer explicit env var injection.
-	if v := strings.TrimSpace(os.Getenv("GEMINI_API_KEY")); v != "" {
-		key, err := readValueOrFile(v, "GEMINI_API_KEY")
+// geminiSettingNames maps each non-secret Gemini setting a Source may hold to its CamelCase form
+// ("GeminiModel"), which Source secret names use since not every source type allows underscores.
+var geminiSettingNames = map[string]string{
+	"GEMINI_MODEL":    "GeminiModel",
+	"GEMINI_BASE_URL": "GeminiBaseUrl",
+}
+
+// resolveGeminiSourceSettings fills a Model or BaseURL that neither its flag nor its env var set
+// from the Gemini Source in SOURCE_CREDENTIALS: the Source named by GEMINI_SOURCE_API_NAME, or the
+// only configured Source. A Source holds a setting as a secret or additional secret named like the
+// env var or its geminiSettingNames form. Without SOURCE_CREDENTIALS it is a no-op.
+func resolveGeminiSourceSettings(cfg *gemini.Config) error {
+	if strings.TrimSpace(os.Getenv("SOURCE_CREDENTIALS")) == "" {
+		return nil
+	}
+	if cfg.Model != "" && cfg.BaseURL != "" {
+		return nil
+	}
+	creds, err := foundry.LoadSourceCredentialsFromEnv()
+	if err != nil {
+		return err
+	}
+	source := foundry.SourceSecrets{Creds: creds, Source: os.Getenv("GEMINI_SOURCE_API_NAME")}
+	for name, value := range map[string]*string{"GEMINI_MODEL": &cfg.Model, "GEMINI_BASE_URL": &cfg.BaseURL} {
+		if *value != "" {
+			continue
+		}
+		for _, key := range []string{name, geminiSettingNames[name]} {
+			v, ok, err := source.Get(key)
+			if err != nil {
+				return err
+			}
+			if ok {
+				*value = v
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// newEnricher constructs the enrichment backend selected by --backend.
+func newEnricher(ctx context.Context, backend string, cfg gemini.Config) (enrich.Enricher, error) {
+	switch strings.ToLower(strings.TrimSpace(backend)) {
+	case "", "gemini":
+		if err := resolveGeminiSourceSettings(&cfg); err != nil {
+			return nil, err
+		}
+		apiKey, err := loadGeminiAPIKey(geminiSecrets())
 		if err != nil {
-			return "", err
+			return nil, err
+		}
+		cfg.APIKey = apiKey
+		return gemini.New(ctx, cfg)
+	case "echo":
+		return echo.New(), nil
+	default:
+		return nil, fmt.Errorf("unknown backend %q (expected gemini|echo)", backend)
+	}
+}
+
+// geminiSecrets is the provider chain GEMINI_API_KEY resolves through, in precedence order: the
+// explicit env var (a literal key or a file path), then Foundry Sources credentials.
+func geminiSecrets() foundry.SecretProvider {
+	return foundry.ChainSecrets{
+		foundry.EnvSecrets{IsPath: looksLikePath},
+		geminiSourceSecrets{},
+	}
+}
+
+func loadGeminiAPIKey(secrets foundry.SecretProvider) (string, error) {
+	key, ok, err := secrets.Get(geminiAPIKeyName)
+	if err != nil {
+		return "", err
+	}
+	if !ok || strings.TrimSpace(key) == "" {
+		return "", fmt.Errorf("GEMINI_API_KEY is required")
+	}
+	return key, nil
+}
+
+// geminiAPIKeyName is the secret name the Gemini API key resolves under.
+const geminiAPIKeyName = "GEMINI_API_KEY"
+
+// geminiSourceSecrets resolves secrets from SOURCE_CREDENTIALS (recommended by Foundry docs). The
+// Gemini API key is found using GEMINI_SOURCE_API_NAME and GEMINI_SOURCE_SECRET_NAME when set and
+// inferring them otherwise. Any other name is looked up as is in the GEMINI_SOURCE_API_NAME Source,
+// or the only Source when that is unset.
+type geminiSourceSecrets struct{}
+
+func (geminiSourceSecrets) Get(name string) (string, bool, error) {
+	if name != geminiAPIKeyName {
+		if strings.TrimSpace(os.Getenv("SOURCE_CREDENTIALS")) == "" {
+			return "", false, nil
 		}
-		if strings.TrimSpace(key) == "" {
-			return "", fmt.Errorf("GEMINI_API_KEY is required")
+		creds, err := foundry.LoadSourceCredentialsFromEnv()
+		if err != nil {
+			return "", false, err
 		}
-		return key, nil
+		return foundry.SourceSecrets{Creds: creds, Source: os.Getenv("GEMINI_SOURCE_API_NAME")}.Get(name)
 	}
 
-	// 2) Fall back to Foundry Sources credentials (recommended by Foundry docs).
 	creds, err := foundry.LoadSourceCredentialsFromEnv()
 	if err != nil {
-		return "", fmt.Errorf("GEMINI_API_KEY is required (or configure Sources and provide SOURCE_CREDENTIALS): %w", err)
+		return "", false, fmt.Errorf("GEMINI_API_KEY is required (or configure Sources and provide SOURCE_CREDENTIALS): %w", err)
 	}
 
 	sourceAPIName := strings.TrimSpace(os.Getenv("GEMINI_SOURCE_API_NAME"))
@@ -279,12 +824,12 @@ func loadGeminiAPIKey() (string, error) {
 		// Fully specified: source + optional secret name.
 		key, ok, err := pickSecretFromSource(creds, sourceAPIName, secretName)
 		if err != nil {
-			return "", err
+			return "", false, err
 		}
 		if ok {
-			return key, nil
+			return key, true, nil
 		}
-		return "", fmt.Errorf(
+		return "", false, fmt.Errorf(
 			"could not find Gemini API key in SOURCE_CREDENTIALS for source %q (available secrets: %v); set GEMINI_SOURCE_SECRET_NAME or GEMINI_API_KEY",
 			sourceAPIName,
 			creds.SecretNames(sourceAPIName),
@@ -299,12 +844,12 @@ func loadGeminiAPIKey() (string, error) {
 		}
 		key, ok, err := pickSecretFromSource(creds, onlySource, secretName)
 		if err != nil {
-			return "", err
+			return "", false, err
 		}
 		if ok {
-			return key, nil
+			return key, true, nil
 		}
-		return "", fmt.Errorf(
+		return "", false, fmt.Errorf(
 			"could not infer Gemini API key from SOURCE_CREDENTIALS (source %q has secrets %v); set GEMINI_SOURCE_SECRET_NAME or GEMINI_API_KEY",
 			onlySource,
 			creds.SecretNames(onlySource),
@@ -312,30 +857,19 @@ func loadGeminiAPIKey() (string, error) {
 	}
 
 	// Multiple sources: try to find a single unambiguous match.
-	type match struct {
-		source string
-		secret string
-		value  string
-	}
-	var matches []match
+	var matches []string
 	for _, srcName := range creds.SourceNames() {
-		key, ok, _ := pickSecretFromSource(creds, srcName, secretName)
-		if ok {
-			// pickSecretFromSource uses a deterministic preference order; record which key it picked for debugging.
-			picked := secretName
-			if picked == "" {
-				picked = "<inferred>"
-			}
-			matches = append(matches, match{source: srcName, secret: picked, value: key})
+		if key, ok, _ := pickSecretFromSource(creds, srcName, secretName); ok {
+			matches = append(matches, key)
 		}
 	}
 	if len(matches) == 1 {
-		return matches[0].value, nil
+		return matches[0], true, nil
 	}
 	if len(matches) > 1 {
-		return "", fmt.Errorf("multiple Sources in SOURCE_CREDENTIALS could provide the Gemini API key; set GEMINI_SOURCE_API_NAME (available sources: %v)", creds.SourceNames())
+		return "", false, fmt.Errorf("multiple Sources in SOURCE_CREDENTIALS could provide the Gemini API key; set GEMINI_SOURCE_API_NAME (available sources: %v)", creds.SourceNames())
 	}
-	return "", fmt.Errorf("could not infer Gemini API key from SOURCE_CREDENTIALS; set GEMINI_SOURCE_API_NAME and GEMINI_SOURCE_SECRET_NAME (available sources: %v)", creds.SourceNames())
+	return "", false, fmt.Errorf("could not infer Gemini API key from SOURCE_CREDENTIALS; set GEMINI_SOURCE_API_NAME and GEMINI_SOURCE_SECRET_NAME (available sources: %v)", creds.SourceNames())
 }
 
 func pickSecretFromSource(creds foundry.SourceCredentials, sourceAPIName, preferredSecretName string) (string, bool, error) {
@@ -346,18 +880,16 @@ func pickSecretFromSource(creds foundry.SourceCredentials, sourceAPIName, prefer
 	if _, ok := creds[sourceAPIName]; !ok {
 		return "", false, fmt.Errorf("SOURCE_CREDENTIALS missing source %q (available sources: %v)", sourceAPIName, creds.SourceNames())
 	}
+	source := foundry.SourceSecrets{Creds: creds, Source: sourceAPIName}
 
 	// If the user specifies the secret name, respect it.
 	if strings.TrimSpace(preferredSecretName) != "" {
-		if v, ok := creds.GetSecret(sourceAPIName, preferredSecretName); ok {
-			return v, true, nil
-		}
-		return "", false, nil
+		return source.Get(preferredSecretName)
 	}
 
 	// Otherwise try common API key-ish names.
-	for _, candidate := range []string{"GEMINI_API_KEY", "GeminiAPIKey", "apiKey", "api_key", "apikey"} {
-		if v, ok := creds.GetSecret(sourceAPIName, candidate); ok {
+	for _, candidate := range []string{geminiAPIKeyName, "GeminiAPIKey", "apiKey", "api_key", "apikey"} {
+		if v, ok, _ := source.Get(candidate); ok {
 			return v, true, nil
 		}
 	}
@@ -365,9 +897,7 @@ func pickSecretFromSource(creds foundry.SourceCredentials, sourceAPIName, prefer
 	// If there's exactly one secret, assume it's the API key.
 	names := creds.SecretNames(sourceAPIName)
 	if len(names) == 1 {
-		if v, ok := creds.GetSecret(sourceAPIName, names[0]); ok {
-			return v, true, nil
-		}
+		return source.Get(names[0])
 	}
 	return "", false, nil
 }
@@ -415,16 +945,230 @@ func loadPipelineOptionsFromEnv() (pipeline.Options, error) {
 	if err != nil {
 		return pipeline.Options{}, err
 	}
+	rampInterval, err := envDuration("WORKER_RAMP_INTERVAL", 0)
+	if err != nil {
+		return pipeline.Options{}, err
+	}
 
 	return pipeline.Options{
 		Workers:        workers,
 		MaxRetries:     maxRetries,
 		RequestTimeout: requestTimeout,
+		RampInterval:   rampInterval,
 		RateLimitRPS:   rateLimitRPS,
 		FailFast:       failFast,
 	}, nil
 }
 
+const emailColumnUsage = "Input column to read emails from, matched case-insensitively, e.g. contact_email; cannot be combined with --email-columns (env: INPUT_EMAIL_COLUMN; default: email or an --email-column-aliases match)"
+
+const passthroughColumnsUsage = "Comma-separated input columns copied unchanged onto each output row, e.g. customer_id (default: none)"
+
+const outputFormatUsage = "Output file format: csv|jsonl; jsonl writes one JSON object per row in the stream record shape (local file and dataset output only)"
+
+const contextColumnsUsage = "Comma-separated input columns passed to the enricher as extra context for each email, e.g. first_name,last_name,company (default: none)"
+
+const inputFilterUsage = "Comma-separated conditions an input row must match to be enriched, e.g. domain!=gmail.com|yahoo.com,region=eu; other rows get status=skipped, skip_reason=filter (default: none)"
+
+const workerRampIntervalUsage = "Start one worker and add another every interval up to --workers, so load ramps up instead of bursting; 0 starts all at once (env: WORKER_RAMP_INTERVAL)"
+
+const captureUsageUsage = "Add prompt_tokens and response_tokens columns with the provider-reported token usage per row"
+
+var minCompletenessUsage = "Mark successful rows whose completeness (fraction of " + strings.Join(pipeline.CompletenessFields(), ", ") + " filled) is below this 0-1 threshold as status=partial; 0 disables"
+
+func validateMinCompleteness(v float64) error {
+	if v < 0 || v > 1 {
+		return fmt.Errorf("--min-completeness must be between 0 and 1, got %v", v)
+	}
+	return nil
+}
+
+const timeoutBackoffFactorUsage = "Multiply the per-email request timeout by this factor on each retry, so later attempts get longer (> 1) or shorter (< 1) deadlines; 1 keeps it constant (must be > 0)"
+
+func validateTimeoutBackoffFactor(v float64) error {
+	if v <= 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return fmt.Errorf("--timeout-backoff-factor must be a finite number > 0, got %v", v)
+	}
+	return nil
+}
+
+const commitEveryUsage = "Dataset output: also commit a checkpoint of the output every N enriched emails, so a restarted run resumes from the last checkpoint; 0 commits once at the end"
+
+const (
+	failOnAnyErrorUsage   = "Exit non-zero (after writing the output) if any email enriched in this run ended with status=error"
+	failOnErrorCountUsage = "Exit non-zero (after writing the output) if at least this many emails enriched in this run ended with status=error; 0 disables"
+)
+
+const omitAuditColumnsUsage = "Write the lean output schema without the model, sources, and web_search_queries columns (stream records omit those fields); requires --capture-audit=false"
+
+// validateOmitAuditColumns rejects omitting the audit columns while audit data is being captured,
+// since the captured sources and queries would be silently dropped.
+func validateOmitAuditColumns(omit, captureAudit bool) error {
+	if omit && captureAudit {
+		return fmt.Errorf("--omit-audit-columns cannot be combined with --capture-audit")
+	}
+	return nil
+}
+
+const enrichDomainAllowUsage = "Only enrich emails in these domains or their subdomains: a comma-separated list, or a file path with one domain per line; other rows get status=skipped, skip_reason=domain (default: all)"
+
+const enrichDomainDenyUsage = "Do not enrich emails in these domains or their subdomains, e.g. gmail.com,yahoo.com, or a file path with one domain per line; those rows get status=skipped, skip_reason=domain (default: none)"
+
+const streamSchemaFileUsage = "Stream output: a Foundry dataset metadata JSON file declaring the stream's schema; empty values of its non-nullable fields are published as their type's zero value instead of null (default: every empty value is null)"
+
+const postProcessUsage = "Comma-separated result post-processors applied before output: canonical-url, clamp-description=N (default: none)"
+
+// bindGeminiSamplingFlags registers the optional Gemini sampling and tool flags onto cfg. Unset
+// sampling flags leave the model defaults in place.
+func bindGeminiSamplingFlags(fs *flag.FlagSet, cfg *gemini.Config) {
+	fs.Var(optionalFloat32{&cfg.Temperature}, "gemini-temperature", "Gemini sampling temperature, 0-2; lower is more stable (default: model default)")
+	fs.Var(optionalFloat32{&cfg.TopK}, "gemini-top-k", "Gemini top-k sampling, >= 1 (default: model default)")
+	fs.Var(optionalFloat32{&cfg.TopP}, "gemini-top-p", "Gemini top-p (nucleus) sampling, 0-1 (default: model default)")
+	fs.IntVar(&cfg.Candidates, "gemini-candidates", 1, fmt.Sprintf("Gemini response candidates per email, 1-%d; with more than one the highest-confidence, most complete candidate is kept (costs more output tokens)", gemini.MaxCandidates))
+	fs.BoolVar(&cfg.DisableSearch, "gemini-disable-search", false, "Do not give Gemini the Google Search grounding tool")
+	fs.BoolVar(&cfg.DisableURLContext, "gemini-disable-url-context", false, "Do not give Gemini the URL context tool")
+}
+
+// bindSkipRowFlags binds --skip-blank-rows and --skip-comment-rows into opts.
+func bindSkipRowFlags(fs *flag.FlagSet, opts *localio.CSVReadOptions) {
+	fs.BoolVar(&opts.SkipBlankRows, "skip-blank-rows", false, "Drop input rows whose fields are all empty or whitespace instead of writing them as empty-email error rows; a row with other values but no email is still an error row")
+	fs.BoolVar(&opts.SkipCommentRows, "skip-comment-rows", false, "Drop input emails whose cell starts with \"#\" (comment lines)")
+}
+
+// bindEmailColumnAliasesFlag binds --email-column-aliases into opts.EmailColumnAliases.
+func bindEmailColumnAliasesFlag(fs *flag.FlagSet, opts *localio.CSVReadOptions) {
+	usage := fmt.Sprintf("Comma-separated header names accepted as the email column when the input has no \"email\" column, matched ignoring case, spaces, and punctuation; empty disables (default %q)", strings.Join(localio.DefaultEmailColumnAliases, ","))
+	fs.Func("email-column-aliases", usage, func(v string) error {
+		opts.EmailColumnAliases = splitList(v)
+		opts.NoEmailColumnAliases = len(opts.EmailColumnAliases) == 0
+		return nil
+	})
+}
+
+func bindCSVLimitFlags(fs *flag.FlagSet, limits *localio.CSVLimits) {
+	fs.IntVar(&limits.MaxFieldBytes, "csv-max-field-bytes", localio.DefaultMaxFieldBytes, "Max bytes in one CSV field when reading input or prior output; larger fields fail the run")
+	fs.IntVar(&limits.MaxRecordBytes, "csv-max-record-bytes", localio.DefaultMaxRecordBytes, "Max bytes in one CSV record when reading input or prior output; larger records fail the run")
+}
+
+// optionalFloat32 is a flag.Value that stays nil until the flag is set.
+type optionalFloat32 struct{ p **float32 }
+
+func (o optionalFloat32) String() string {
+	if o.p == nil || *o.p == nil {
+		return ""
+	}
+	return strconv.FormatFloat(float64(**o.p), 'g', -1, 32)
+}
+
+func (o optionalFloat32) Set(v string) error {
+	f, err := strconv.ParseFloat(strings.TrimSpace(v), 32)
+	if err != nil {
+		return err
+	}
+	f32 := float32(f)
+	*o.p = &f32
+	return nil
+}
+
+// readOnlyNames parses --read-only-error-names. An empty value disables the check, so it maps to an
+// empty non-nil slice rather than nil (which would select the defaults).
+func readOnlyNames(v string) []string {
+	names := splitList(v)
+	if names == nil {
+		return []string{}
+	}
+	return names
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// domainList resolves a domain list flag: a file path (one domain per line, # comments allowed) or
+// a comma-separated list.
+func domainList(v, flagName string) ([]string, error) {
+	v, err := readValueOrFile(v, flagName)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, line := range strings.Split(v, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		out = append(out, splitList(line)...)
+	}
+	return out, nil
+}
+
+// loadStreamContract reads the --stream-schema-file metadata into a stream contract. An empty path
+// returns nil, so every empty field is published as null.
+func loadStreamContract(path string) (*schema.DatasetContract, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("--stream-schema-file: %w", err)
+	}
+	contract, err := foundryio.ContractFromMetadataJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("--stream-schema-file %s: %w", path, err)
+	}
+	return &contract, nil
+}
+
+// reenrichAfterOption returns pipeline.Options.ReenrichAfter for the --reenrich-after value ttl:
+// nil when the flag was not set, so an explicit 0 can mean "re-enrich every ok row".
+func reenrichAfterOption(fs *flag.FlagSet, ttl time.Duration) *time.Duration {
+	set := false
+	fs.Visit(func(f *flag.Flag) { set = set || f.Name == "reenrich-after" })
+	if !set {
+		return nil
+	}
+	return &ttl
+}
+
+// priorOutputNotFoundRetriesOption returns app.FoundryOptions.PriorOutputNotFoundRetries for the
+// --prior-output-not-found-retries value n, where 0 disables the re-reads rather than taking the
+// library default.
+func priorOutputNotFoundRetriesOption(n int) int {
+	if n == 0 {
+		return -1
+	}
+	return n
+}
+
+// piiHashKey returns the PII_HASH_KEY secret that audit records and --redact-pii hash emails under.
+func piiHashKey() string {
+	return envString("PII_HASH_KEY", "")
+}
+
+func envString(varName string, fallback string) string {
+	v := strings.TrimSpace(os.Getenv(varName))
+	if v == "" {
+		return fallback
+	}
+	return v
+}
+
+// envOutputWriteMode reads the --output-write-mode default from varName ("auto" when unset),
+// accepting the modes the flag does.
+func envOutputWriteMode(varName string) (string, error) {
+	v := strings.ToLower(envString(varName, foundryio.OutputModeAuto))
+	switch v {
+	case foundryio.OutputModeAuto, foundryio.OutputModeDataset, foundryio.OutputModeStream, foundryio.OutputModeBoth:
+		return v, nil
+	default:
+		return "", fmt.Errorf("invalid %s=%q (expected auto|dataset|stream|both)", varName, v)
+	}
+}
+
 func envInt(varName string, fallback int) (int, error) {
 	v := strings.TrimSpace(os.Getenv(varName))
 	if v == "" {
@@ -472,3 +1216,25 @@ func envBool(varName string) (bool, error) {
 	}
 	return out, nil
 }
+
+// parseOperationTimeouts parses --foundry-operation-timeouts into foundry.Timeouts.Operations.
+func parseOperationTimeouts(v string) (map[string]time.Duration, error) {
+	parts := splitList(v)
+	if len(parts) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]time.Duration, len(parts))
+	for _, part := range parts {
+		op, raw, ok := strings.Cut(part, "=")
+		op = strings.TrimSpace(op)
+		if !ok || !slices.Contains(foundry.OperationNames(), op) {
+			return nil, fmt.Errorf("invalid --foundry-operation-timeouts entry %q (expected <operation>=<duration>; operations: %s)", part, strings.Join(foundry.OperationNames(), ", "))
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid --foundry-operation-timeouts duration for %s: %q", op, raw)
+		}
+		out[op] = d
+	}
+	return out, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/mockfoundry"
+)
+
+func TestEnvOutputWriteMode(t *testing.T) {
+	cases := map[string]string{"": "auto", "Stream": "stream", " dataset ": "dataset", "both": "both"}
+	for v, want := range cases {
+		t.Setenv("OUTPUT_WRITE_MODE", v)
+		got, err := envOutputWriteMode("OUTPUT_WRITE_MODE")
+		if err != nil || got != want {
+			t.Fatalf("OUTPUT_WRITE_MODE=%q: want %q, got %q (err=%v)", v, want, got, err)
+		}
+	}
+	t.Setenv("OUTPUT_WRITE_MODE", "streaming")
+	if _, err := envOutputWriteMode("OUTPUT_WRITE_MODE"); err == nil {
+		t.Fatalf("expected an invalid OUTPUT_WRITE_MODE to fail")
+	}
+}
+
+// streamRecordReads runs the foundry command against a mock whose output is a stream and returns
+// how many times it read the output stream's records. The auto-probe is one such read; the
+// incremental cache read is the other.
+func streamRecordReads(t *testing.T, writeMode string) int {
+	t.Helper()
+
+	const (
+		inputRID  = "ri.foundry.main.dataset.11111111-1111-1111-1111-111111111111"
+		outputRID = "ri.foundry.main.stream.22222222-2222-2222-2222-222222222222"
+	)
+	dir := t.TempDir()
+	inputDir := filepath.Join(dir, "inputs")
+	if err := os.MkdirAll(inputDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, inputRID+".csv"), []byte("email\nalice@example.com\n"), 0o644); err != nil {
+		t.Fatalf("write input: %v", err)
+	}
+	mock := mockfoundry.New(inputDir, t.TempDir())
+	mock.CreateStream(outputRID)
+	ts := httptest.NewServer(mock.Handler())
+	t.Cleanup(ts.Close)
+
+	tokenPath := filepath.Join(dir, "token")
+	aliasPath := filepath.Join(dir, "aliases.json")
+	aliases := `{"input":{"rid":"` + inputRID + `","branch":"master"},"output":{"rid":"` + outputRID + `","branch":"master"}}`
+	if err := os.WriteFile(tokenPath, []byte("dummy-token"), 0o600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+	if err := os.WriteFile(aliasPath, []byte(aliases), 0o644); err != nil {
+		t.Fatalf("write alias map: %v", err)
+	}
+	t.Setenv("BUILD2_TOKEN", tokenPath)
+	t.Setenv("RESOURCE_ALIAS_MAP", aliasPath)
+	t.Setenv("FOUNDRY_URL", ts.URL)
+	t.Setenv("ENRICH_BACKEND", "echo")
+	t.Setenv("OUTPUT_WRITE_MODE", writeMode)
+
+	if code := runFoundry(context.Background(), nil); code != 0 {
+		t.Fatalf("runFoundry exit code %d", code)
+	}
+	if got := mock.StreamRecords(outputRID, "master"); len(got) != 1 {
+		t.Fatalf("expected 1 published record, got %d", len(got))
+	}
+	reads := 0
+	for _, call := range mock.Calls() {
+		if call.Method == http.MethodGet && call.Path == "/stream-proxy/api/streams/"+outputRID+"/branches/master/records" {
+			reads++
+		}
+	}
+	return reads
+}
+
+func TestRunFoundry_EnvStreamWriteModeSkipsProbe(t *testing.T) {
+	if got := streamRecordReads(t, ""); got != 2 {
+		t.Fatalf("auto mode: expected probe plus cache read (2 record reads), got %d", got)
+	}
+	if got := streamRecordReads(t, "stream"); got != 1 {
+		t.Fatalf("OUTPUT_WRITE_MODE=stream: expected only the cache read (1 record read), got %d", got)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/enrich/gemini"
+)
+
+// promptDumpFlags holds --dump-prompts, --dump-prompts-max, and --redact-pii.
+type promptDumpFlags struct {
+	dir       string
+	max       int
+	redactPII bool
+}
+
+func bindPromptDumpFlags(fs *flag.FlagSet, f *promptDumpFlags) {
+	fs.StringVar(&f.dir, "dump-prompts", "", "Write the rendered Gemini prompt of a sample of emails to this directory, one redacted file per email, for debugging enrichment quality (default: off)")
+	fs.IntVar(&f.max, "dump-prompts-max", gemini.DefaultPromptDumpMax, "Max prompt files --dump-prompts writes; the first distinct emails enriched are sampled")
+	fs.BoolVar(&f.redactPII, "redact-pii", false, "Replace emails with their HMAC-SHA256 under PII_HASH_KEY, and context column values with <redacted>, in debug artifacts such as --dump-prompts")
+}
+
+// hook returns the gemini.Config.PromptHook for the flags, or nil when --dump-prompts is unset.
+// hashKey is the PII_HASH_KEY value.
+func (f promptDumpFlags) hook(hashKey string) (func(email string, record map[string]string, prompt string), error) {
+	if strings.TrimSpace(f.dir) == "" {
+		return nil, nil
+	}
+	if f.max <= 0 {
+		return nil, fmt.Errorf("--dump-prompts-max must be > 0, got %d", f.max)
+	}
+	if f.redactPII && hashKey == "" {
+		return nil, fmt.Errorf("--redact-pii requires PII_HASH_KEY")
+	}
+	dump, err := gemini.NewPromptDump(f.dir, f.max, f.redactPII, []byte(hashKey))
+	if err != nil {
+		return nil, err
+	}
+	return dump.Record, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+var retryProfileUsage = fmt.Sprintf("Named retry settings applied to every retry layer (worker, Foundry I/O, keepalive): %s; retry flags given on the command line or through their env vars still override it (env: RETRY_PROFILE; default: none)", strings.Join(app.RetryProfileNames(), ", "))
+
+// retryFlagEnv names the env vars that set retry flag defaults (see loadPipelineOptionsFromEnv).
+var retryFlagEnv = map[string]string{
+	"max-retries": "MAX_RETRIES",
+}
+
+// applyRetryProfile resolves --retry-profile once fs is parsed. The retry flags fs defines but
+// neither the command line nor their env var (read through getenv) set are reset to the profile's
+// values, so explicit settings keep precedence; settings without a flag are read from the returned
+// profile. An empty name returns the zero profile, whose zero settings mean the usual defaults.
+func applyRetryProfile(fs *flag.FlagSet, name string, getenv func(string) string) (app.RetryProfile, error) {
+	if strings.TrimSpace(name) == "" {
+		return app.RetryProfile{}, nil
+	}
+	p, err := app.RetryProfileNamed(name)
+	if err != nil {
+		return app.RetryProfile{}, err
+	}
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for flagName, envName := range retryFlagEnv {
+		if strings.TrimSpace(getenv(envName)) != "" {
+			set[flagName] = true
+		}
+	}
+	for flagName, v := range retryProfileFlagValues(p) {
+		if set[flagName] || fs.Lookup(flagName) == nil {
+			continue
+		}
+		if err := fs.Set(flagName, v); err != nil {
+			return app.RetryProfile{}, fmt.Errorf("retry profile %s: set --%s: %w", p.Name, flagName, err)
+		}
+	}
+	return p, nil
+}
+
+// retryProfileFlagValues maps p onto the retry flags that express its settings.
+func retryProfileFlagValues(p app.RetryProfile) map[string]string {
+	return map[string]string{
+		"max-retries":             strconv.Itoa(p.MaxRetries),
+		"input-read-retries":      strconv.Itoa(p.InputRead.Attempts - 1),
+		"write-max-attempts":      strconv.Itoa(p.Write.MaxAttempts),
+		"write-max-elapsed":       p.Write.MaxElapsed.String(),
+		"publish-retries":         strconv.Itoa(p.Publish.Attempts - 1),
+		"publish-backoff-initial": p.Publish.InitialSleep.String(),
+		"publish-backoff-max":     p.Publish.MaxSleep.String(),
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+// retryFlags registers the retry flags the profile maps onto, with their foundry-mode defaults.
+func retryFlags() (*flag.FlagSet, *int, *int, *int, *time.Duration) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	maxRetries := fs.Int("max-retries", 3, "")
+	inputReadRetries := fs.Int("input-read-retries", 7, "")
+	writeMaxAttempts := fs.Int("write-max-attempts", 16, "")
+	writeMaxElapsed := fs.Duration("write-max-elapsed", 2*time.Minute, "")
+	fs.Int("publish-retries", 7, "")
+	fs.Duration("publish-backoff-initial", 200*time.Millisecond, "")
+	fs.Duration("publish-backoff-max", 2*time.Second, "")
+	return fs, maxRetries, inputReadRetries, writeMaxAttempts, writeMaxElapsed
+}
+
+// noEnv is a getenv with no variables set.
+func noEnv(string) string { return "" }
+
+func TestApplyRetryProfile_CompositeSettings(t *testing.T) {
+	t.Parallel()
+
+	type settings struct {
+		maxRetries, inputReadRetries, writeMaxAttempts int
+		writeMaxElapsed                                time.Duration
+		backoffInitial, backoffMax                     time.Duration
+		postRetries                                    int
+		pollBackoffMax                                 time.Duration
+	}
+	cases := []struct {
+		profile string
+		want    settings
+	}{
+		{profile: "", want: settings{3, 7, 16, 2 * time.Minute, 0, 0, 0, 0}},
+		{profile: "aggressive", want: settings{5, 11, 32, 3 * time.Minute, 100 * time.Millisecond, time.Second, 8, 2 * time.Second}},
+		{profile: "balanced", want: settings{3, 7, 16, 2 * time.Minute, 200 * time.Millisecond, 2 * time.Second, 5, 8 * time.Second}},
+		{profile: "Gentle", want: settings{2, 3, 8, 5 * time.Minute, time.Second, 10 * time.Second, 3, 15 * time.Second}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.profile, func(t *testing.T) {
+			t.Parallel()
+
+			fs, maxRetries, inputReadRetries, writeMaxAttempts, writeMaxElapsed := retryFlags()
+			if err := fs.Parse(nil); err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			p, err := applyRetryProfile(fs, tc.profile, noEnv)
+			if err != nil {
+				t.Fatalf("applyRetryProfile: %v", err)
+			}
+			got := settings{
+				*maxRetries, *inputReadRetries, *writeMaxAttempts, *writeMaxElapsed,
+				p.BackoffInitial, p.BackoffMax, p.KeepalivePostRetries, p.KeepalivePollBackoffMax,
+			}
+			if got != tc.want {
+				t.Fatalf("profile %q:\nwant %+v\ngot  %+v", tc.profile, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestApplyRetryProfile_ExplicitFlagsOverride(t *testing.T) {
+	t.Parallel()
+
+	fs, maxRetries, inputReadRetries, writeMaxAttempts, _ := retryFlags()
+	if err := fs.Parse([]string{"--max-retries=9", "--write-max-attempts=4", "--publish-backoff-max=30s"}); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if _, err := applyRetryProfile(fs, "gentle", noEnv); err != nil {
+		t.Fatalf("applyRetryProfile: %v", err)
+	}
+	if *maxRetries != 9 || *writeMaxAttempts != 4 || fs.Lookup("publish-backoff-max").Value.String() != "30s" {
+		t.Fatalf("expected explicit flags to win, got max-retries=%d write-max-attempts=%d publish-backoff-max=%s", *maxRetries, *writeMaxAttempts, fs.Lookup("publish-backoff-max").Value)
+	}
+	if *inputReadRetries != 3 || fs.Lookup("publish-retries").Value.String() != "3" {
+		t.Fatalf("expected unset flags to take the profile values, got input-read-retries=%d publish-retries=%s", *inputReadRetries, fs.Lookup("publish-retries").Value)
+	}
+}
+
+func TestApplyRetryProfile_EnvSettingsOverride(t *testing.T) {
+	t.Parallel()
+
+	// MAX_RETRIES=9 reaches --max-retries as its default, as loadPipelineOptionsFromEnv does.
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	maxRetries := fs.Int("max-retries", 9, "")
+	if err := fs.Parse(nil); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	getenv := func(name string) string {
+		if name == "MAX_RETRIES" {
+			return "9"
+		}
+		return ""
+	}
+	if _, err := applyRetryProfile(fs, "gentle", getenv); err != nil {
+		t.Fatalf("applyRetryProfile: %v", err)
+	}
+	if *maxRetries != 9 {
+		t.Fatalf("expected MAX_RETRIES to win over the profile, got max-retries=%d", *maxRetries)
+	}
+}
+
+func TestApplyRetryProfile_UnknownName(t *testing.T) {
+	t.Parallel()
+
+	fs, _, _, _, _ := retryFlags()
+	if _, err := applyRetryProfile(fs, "reckless", noEnv); app.ClassifyFailure(err) != app.FailureConfig {
+		t.Fatalf("expected a config error, got %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/enrich/gemini"
+)
+
+func TestLoadGeminiAPIKey_EnvTakesPrecedenceOverSources(t *testing.T) {
+	dir := t.TempDir()
+	credsPath := filepath.Join(dir, "creds.json")
+	if err := os.WriteFile(credsPath, []byte(`{"gemini": {"apiKey": "from-sources"}}`), 0o600); err != nil {
+		t.Fatalf("write source credentials: %v", err)
+	}
+	keyPath := filepath.Join(dir, "key")
+	if err := os.WriteFile(keyPath, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatalf("write key file: %v", err)
+	}
+	t.Setenv("SOURCE_CREDENTIALS", credsPath)
+	t.Setenv("GEMINI_SOURCE_API_NAME", "")
+	t.Setenv("GEMINI_SOURCE_SECRET_NAME", "")
+
+	for _, tc := range []struct {
+		env  string
+		want string
+	}{
+		{env: "literal-key", want: "literal-key"},
+		{env: keyPath, want: "from-file"},
+		{env: "", want: "from-sources"},
+	} {
+		t.Setenv("GEMINI_API_KEY", tc.env)
+		got, err := loadGeminiAPIKey(geminiSecrets())
+		if err != nil {
+			t.Fatalf("GEMINI_API_KEY=%q: %v", tc.env, err)
+		}
+		if got != tc.want {
+			t.Fatalf("GEMINI_API_KEY=%q: want %q, got %q", tc.env, tc.want, got)
+		}
+	}
+
+	t.Setenv("GEMINI_API_KEY", "")
+	t.Setenv("SOURCE_CREDENTIALS", "")
+	if _, err := loadGeminiAPIKey(geminiSecrets()); err == nil {
+		t.Fatalf("expected an error with neither GEMINI_API_KEY nor SOURCE_CREDENTIALS")
+	}
+}
+
+func TestResolveGeminiSourceSettings_ModelAndBaseURLFromSource(t *testing.T) {
+	credsPath := filepath.Join(t.TempDir(), "creds.json")
+	creds := `{"gemini": {"apiKey": "k", "GEMINI_MODEL": "gemini-next", "additionalSecretGeminiBaseUrl": "https://proxy.example"}}`
+	if err := os.WriteFile(credsPath, []byte(creds), 0o600); err != nil {
+		t.Fatalf("write source credentials: %v", err)
+	}
+	t.Setenv("SOURCE_CREDENTIALS", credsPath)
+	t.Setenv("GEMINI_SOURCE_API_NAME", "")
+	t.Setenv("GEMINI_MODEL", "")
+	t.Setenv("GEMINI_BASE_URL", "")
+
+	resolve := func() gemini.Config {
+		t.Helper()
+		cfg, err := loadGeminiConfigFromEnv()
+		if err != nil {
+			t.Fatalf("loadGeminiConfigFromEnv: %v", err)
+		}
+		if err := resolveGeminiSourceSettings(&cfg); err != nil {
+			t.Fatalf("resolveGeminiSourceSettings: %v", err)
+		}
+		return cfg
+	}
+	if cfg := resolve(); cfg.Model != "gemini-next" || cfg.BaseURL != "https://proxy.example" {
+		t.Fatalf("expected model and base URL from the Source, got model=%q baseURL=%q", cfg.Model, cfg.BaseURL)
+	}
+
+	// The env vars still take precedence over the Source.
+	t.Setenv("GEMINI_MODEL", "gemini-pinned")
+	if cfg := resolve(); cfg.Model != "gemini-pinned" || cfg.BaseURL != "https://proxy.example" {
+		t.Fatalf("expected the env model over the Source, got model=%q baseURL=%q", cfg.Model, cfg.BaseURL)
+	}
+
+	// Other backends never read SOURCE_CREDENTIALS, so an unreadable file only fails the gemini
+	// backend's resolution.
+	t.Setenv("GEMINI_MODEL", "")
+	t.Setenv("SOURCE_CREDENTIALS", filepath.Join(t.TempDir(), "missing.json"))
+	cfg, err := loadGeminiConfigFromEnv()
+	if err != nil {
+		t.Fatalf("expected the env config to load without reading SOURCE_CREDENTIALS, got %v", err)
+	}
+	if _, err := newEnricher(context.Background(), "echo", cfg); err != nil {
+		t.Fatalf("expected the echo backend to ignore SOURCE_CREDENTIALS, got %v", err)
+	}
+	if err := resolveGeminiSourceSettings(&cfg); err == nil {
+		t.Fatalf("expected an error for an unreadable SOURCE_CREDENTIALS file")
+	}
+}
+
+func TestGeminiSourceSecrets_LooksUpOtherNamesByName(t *testing.T) {
+	credsPath := filepath.Join(t.TempDir(), "creds.json")
+	if err := os.WriteFile(credsPath, []byte(`{"gemini": {"apiKey": "k", "OTHER_TOKEN": "other"}}`), 0o600); err != nil {
+		t.Fatalf("write source credentials: %v", err)
+	}
+	t.Setenv("SOURCE_CREDENTIALS", credsPath)
+	t.Setenv("GEMINI_SOURCE_API_NAME", "")
+	t.Setenv("GEMINI_SOURCE_SECRET_NAME", "")
+
+	secrets := geminiSourceSecrets{}
+	if v, ok, err := secrets.Get(geminiAPIKeyName); err != nil || !ok || v != "k" {
+		t.Fatalf("expected the inferred Gemini API key, got %q ok=%t err=%v", v, ok, err)
+	}
+	if v, ok, err := secrets.Get("OTHER_TOKEN"); err != nil || !ok || v != "other" {
+		t.Fatalf("expected OTHER_TOKEN by name, got %q ok=%t err=%v", v, ok, err)
+	}
+	if v, ok, err := secrets.Get("MISSING"); err != nil || ok {
+		t.Fatalf("expected MISSING not to be found, got %q ok=%t err=%v", v, ok, err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadStreamContract(t *testing.T) {
+	t.Parallel()
+
+	if c, err := loadStreamContract(""); c != nil || err != nil {
+		t.Fatalf("expected no contract without a file, got %+v (err=%v)", c, err)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.json")
+	meta := `{"datasetType":"STREAM","schema":{"fieldSchemaList":[{"name":"email","type":"STRING","nullable":false},{"name":"completeness","type":"DOUBLE","nullable":false}]}}`
+	if err := os.WriteFile(path, []byte(meta), 0644); err != nil {
+		t.Fatalf("write schema file: %v", err)
+	}
+	c, err := loadStreamContract(path)
+	if err != nil {
+		t.Fatalf("loadStreamContract: %v", err)
+	}
+	if f, ok := c.Field("completeness"); !ok || f.Nullable || f.Type != "DOUBLE" {
+		t.Fatalf("expected a non-nullable DOUBLE completeness field, got %+v (found=%t)", f, ok)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"schema":{}}`), 0644); err != nil {
+		t.Fatalf("write bad schema file: %v", err)
+	}
+	if _, err := loadStreamContract(bad); err == nil {
+		t.Fatalf("expected an error for metadata without fields")
+	}
+	if _, err := loadStreamContract(filepath.Join(dir, "missing.json")); err == nil {
+		t.Fatalf("expected an error for a missing file")
+	}
+}
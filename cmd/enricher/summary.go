@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+// printRunSummary writes a one-line summary of a completed run.
+func printRunSummary(w io.Writer, res app.RunResult) {
+	prefix := ""
+	if res.RunID != "" {
+		prefix = "run=" + res.RunID + " "
+	}
+	written := ""
+	if res.OutputTransactionRID != "" {
+		written = fmt.Sprintf(" transaction=%s files=%s", res.OutputTransactionRID, strings.Join(res.OutputFiles, ","))
+	}
+	if res.OutputTag != "" {
+		written += " tag=" + res.OutputTag
+	}
+	_, _ = fmt.Fprintf(
+		w,
+		"%srun summary: mode=%s inputRows=%d cachedRows=%d skippedRows=%d deferredEmails=%d enriched=%d ok=%d error=%d rowsWritten=%d recordsPublished=%d upToDate=%t%s warnings=%d%s duration=%s\n",
+		prefix,
+		res.OutputMode,
+		res.Plan.InputRows,
+		res.Plan.CachedRows,
+		res.Plan.SkippedRows,
+		res.Plan.DeferredEmails,
+		res.Metrics.Enriched,
+		res.Metrics.OK,
+		res.Metrics.Errors,
+		res.RowsWritten,
+		res.RecordsPublished,
+		res.UpToDate,
+		written,
+		len(res.Warnings),
+		warningCounts(res),
+		res.Duration.Round(time.Millisecond),
+	)
+}
+
+// warningCounts renders the run's warning counts by code as " warningCodes=a:1,b:2", sorted by
+// code, or "" when there were none.
+func warningCounts(res app.RunResult) string {
+	counts := res.WarningCounts()
+	if len(counts) == 0 {
+		return ""
+	}
+	codes := slices.Sorted(maps.Keys(counts))
+	parts := make([]string, 0, len(codes))
+	for _, code := range codes {
+		parts = append(parts, fmt.Sprintf("%s:%d", code, counts[code]))
+	}
+	return " warningCodes=" + strings.Join(parts, ",")
+}
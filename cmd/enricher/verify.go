@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+	localio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/local"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/redact"
+)
+
+// runVerify checks a committed output dataset against the current input without enriching or
+// writing. It exits 1 when any input email lacks an ok output row.
+func runVerify(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	inputAlias := fs.String("input-alias", "input", "Alias name for the input dataset in RESOURCE_ALIAS_MAP")
+	outputAlias := fs.String("output-alias", "output", "Alias name for the output dataset in RESOURCE_ALIAS_MAP")
+	emailColumns := fs.String("email-columns", "", "Comma-separated email columns to fan out into one row each (default: email)")
+	emailColumn := fs.String("email-column", envString("INPUT_EMAIL_COLUMN", ""), emailColumnUsage)
+	var csvOpts localio.CSVReadOptions
+	bindCSVLimitFlags(fs, &csvOpts.CSVLimits)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	env, err := foundry.LoadEnv()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "foundry env error: %s\n", redact.Secrets(err.Error()))
+		return 2
+	}
+
+	report, err := app.VerifyFoundry(ctx, env, app.VerifyOptions{
+		InputAlias:     *inputAlias,
+		OutputAlias:    *outputAlias,
+		EmailColumns:   splitList(*emailColumns),
+		EmailColumn:    *emailColumn,
+		CSVReadOptions: csvOpts,
+	})
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "verify failed: %s\n", redact.Secrets(err.Error()))
+		return exitCodeFor(err)
+	}
+	printVerifyReport(os.Stdout, report)
+	if !report.Passed() {
+		return 1
+	}
+	return 0
+}
+
+func printVerifyReport(w io.Writer, report app.VerifyReport) {
+	for _, email := range report.Missing {
+		_, _ = fmt.Fprintf(w, "missing: %s\n", email)
+	}
+	for _, row := range report.Errored {
+		_, _ = fmt.Fprintf(w, "errored: %s status=%q error=%q\n", row.Email, row.Status, redact.Secrets(row.Error))
+	}
+	_, _ = fmt.Fprintf(
+		w,
+		"verify: inputRows=%d okRows=%d missing=%d errored=%d\n",
+		report.InputRows,
+		report.OKRows,
+		len(report.Missing),
+		len(report.Errored),
+	)
+}
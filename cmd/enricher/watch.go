@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// minWatchInterval is the floor applied to --watch-interval so a tiny value cannot hammer Foundry
+// or the enrichment backend with back-to-back runs.
+const minWatchInterval = 30 * time.Second
+
+// watchScheduler re-runs a job on a fixed interval without overlap: a tick that fires while the
+// previous run is still executing is skipped and logged rather than queued.
+type watchScheduler struct {
+	interval time.Duration
+	logf     func(format string, args ...any)
+
+	running atomic.Bool
+	skipped atomic.Int64
+}
+
+// newWatchScheduler returns a scheduler for interval, raised to floor when shorter.
+func newWatchScheduler(interval, floor time.Duration, logf func(format string, args ...any)) *watchScheduler {
+	if interval < floor {
+		logf("watch: interval %s is below the minimum; using %s", interval, floor)
+		interval = floor
+	}
+	return &watchScheduler{interval: interval, logf: logf}
+}
+
+// Run calls run on every tick until ctx is done, then waits for an in-flight run to return.
+func (s *watchScheduler) Run(ctx context.Context, run func(context.Context)) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if !s.running.CompareAndSwap(false, true) {
+			n := s.skipped.Add(1)
+			s.logf("watch: previous run still executing; skipping tick (skipped=%d)", n)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer s.running.Store(false)
+			run(ctx)
+		}()
+	}
+}
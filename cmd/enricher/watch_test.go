@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatchScheduler_SkipsTicksWhileRunIsExecuting(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var logs []string
+	logf := func(format string, args ...any) {
+		mu.Lock()
+		defer mu.Unlock()
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}
+	s := newWatchScheduler(10*time.Millisecond, time.Millisecond, logf)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	var runs, inFlight, maxInFlight atomic.Int32
+	err := s.Run(ctx, func(context.Context) {
+		runs.Add(1)
+		n := inFlight.Add(1)
+		for {
+			prev := maxInFlight.Load()
+			if n <= prev || maxInFlight.CompareAndSwap(prev, n) {
+				break
+			}
+		}
+		time.Sleep(75 * time.Millisecond)
+		inFlight.Add(-1)
+	})
+	if err == nil {
+		t.Fatalf("expected context error when the watch loop stops")
+	}
+
+	if got := maxInFlight.Load(); got != 1 {
+		t.Fatalf("expected runs never to overlap, saw %d concurrent runs", got)
+	}
+	if got := runs.Load(); got < 1 || got > 3 {
+		t.Fatalf("expected 1-3 slow runs in 200ms, got %d", got)
+	}
+	if s.skipped.Load() == 0 {
+		t.Fatalf("expected overlapping ticks to be skipped")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	found := false
+	for _, line := range logs {
+		found = found || strings.Contains(line, "skipping tick")
+	}
+	if !found {
+		t.Fatalf("expected skipped ticks to be logged, got %q", logs)
+	}
+}
+
+func TestNewWatchScheduler_AppliesFloor(t *testing.T) {
+	t.Parallel()
+
+	var logged bool
+	s := newWatchScheduler(time.Second, minWatchInterval, func(string, ...any) { logged = true })
+	if s.interval != minWatchInterval || !logged {
+		t.Fatalf("expected interval raised to %s with a log, got %s (logged=%t)", minWatchInterval, s.interval, logged)
+	}
+	if s := newWatchScheduler(time.Hour, minWatchInterval, func(string, ...any) {}); s.interval != time.Hour {
+		t.Fatalf("expected interval above the floor to be kept, got %s", s.interval)
+	}
+}
@@ -0,0 +1,42 @@
+// Package echo provides a deterministic enricher that makes no external calls.
+//
+// It is intended for validating the end-to-end Foundry write path (permissions, transactions,
+// stream publish, output schema) without any enrichment cost.
+package echo
+
+import (
+	"context"
+	"strings"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/enrich"
+)
+
+// Model is the model name reported on every echo result.
+const Model = "echo"
+
+// Enricher echoes each email back as a Result. It is safe for concurrent use.
+type Enricher struct{}
+
+// New returns an echo Enricher.
+func New() *Enricher {
+	return &Enricher{}
+}
+
+// Enrich returns a Result derived only from the email address.
+func (e *Enricher) Enrich(ctx context.Context, email string) (enrich.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return enrich.Result{}, err
+	}
+
+	email = strings.TrimSpace(email)
+	domain := ""
+	if at := strings.LastIndex(email, "@"); at >= 0 && at+1 < len(email) {
+		domain = strings.ToLower(email[at+1:])
+	}
+	return enrich.Result{
+		Company:     domain,
+		Description: "echo: " + email,
+		Confidence:  "echo",
+		Model:       Model,
+	}, nil
+}
@@ -0,0 +1,66 @@
+package gemini
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/enrich"
+)
+
+func TestEnrich_BlockedResponsesReturnBlockedError(t *testing.T) {
+	cases := []struct {
+		name       string
+		body       map[string]any
+		wantReason string
+	}{
+		{
+			name: "prompt blocked",
+			body: map[string]any{
+				"promptFeedback": map[string]any{"blockReason": "SAFETY"},
+			},
+			wantReason: "SAFETY",
+		},
+		{
+			name: "candidate finished for safety",
+			body: map[string]any{
+				"candidates": []any{
+					map[string]any{"finishReason": "SAFETY"},
+				},
+			},
+			wantReason: "SAFETY",
+		},
+		{
+			name:       "no candidates",
+			body:       map[string]any{"candidates": []any{}},
+			wantReason: "NO_CANDIDATES",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newFakeGeminiResponse(t, tc.body)
+			e, err := New(context.Background(), Config{APIKey: "test-key", Model: "test-model", BaseURL: ts.URL})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			_, err = e.Enrich(context.Background(), "alice@example.com")
+			var blocked *enrich.BlockedError
+			if !errors.As(err, &blocked) {
+				t.Fatalf("expected BlockedError, got %T: %v", err, err)
+			}
+			if blocked.Reason != tc.wantReason {
+				t.Fatalf("reason: want %q, got %q", tc.wantReason, blocked.Reason)
+			}
+			if strings.Contains(err.Error(), "parse structured json") {
+				t.Fatalf("expected a blocked error rather than a parse error, got %v", err)
+			}
+			var transient *enrich.TransientError
+			var limited *enrich.LimitedTransientError
+			if errors.As(err, &transient) || errors.As(err, &limited) {
+				t.Fatalf("blocked responses must not be retryable: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// MaxCandidates is the most candidates the Gemini API returns for one request.
+const MaxCandidates = 8
+
+// confidenceRank orders the prompt's self-reported confidence values; anything else ranks lowest.
+var confidenceRank = map[string]int{"low": 1, "medium": 2, "high": 3}
+
+// parsedCandidate is one response candidate whose text parsed as the structured answer.
+type parsedCandidate struct {
+	index  int
+	text   string
+	answer responseSchema
+}
+
+// score ranks a candidate by self-reported confidence, then by how many profile fields it fills.
+func (c parsedCandidate) score() (confidence, filled int) {
+	return confidenceRank[strings.ToLower(strings.TrimSpace(c.answer.Confidence))], c.answer.result().FilledFields()
+}
+
+// selectCandidate parses every usable candidate in resp and returns the best one: the highest
+// confidence, then the most filled fields, then the earliest. Candidates that are nil or blocked are
+// skipped and ones that fail to parse fall through to the next. When none parses it returns the
+// first usable candidate's text (for raw response capture) and its parse error.
+func selectCandidate(resp *genai.GenerateContentResponse) (parsedCandidate, error) {
+	var (
+		best     parsedCandidate
+		found    bool
+		firstErr error
+		firstRaw string
+	)
+	for i, c := range resp.Candidates {
+		if c == nil || blockReasons[c.FinishReason] {
+			continue
+		}
+		text := candidateText(c)
+		var answer responseSchema
+		if err := json.Unmarshal([]byte(text), &answer); err != nil {
+			if firstErr == nil {
+				firstErr, firstRaw = fmt.Errorf("gemini: parse structured json: %w", err), text
+			}
+			continue
+		}
+		pc := parsedCandidate{index: i, text: text, answer: answer}
+		if !found {
+			best, found = pc, true
+			continue
+		}
+		conf, filled := pc.score()
+		bestConf, bestFilled := best.score()
+		if conf > bestConf || (conf == bestConf && filled > bestFilled) {
+			best = pc
+		}
+	}
+	if !found {
+		return parsedCandidate{text: firstRaw}, firstErr
+	}
+	return best, nil
+}
+
+// candidateText joins the candidate's non-thought text parts, as GenerateContentResponse.Text does
+// for the first candidate.
+func candidateText(c *genai.Candidate) string {
+	if c == nil || c.Content == nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, part := range c.Content.Parts {
+		if part == nil || part.Thought {
+			continue
+		}
+		b.WriteString(part.Text)
+	}
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package gemini
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func textCandidate(text string) map[string]any {
+	return map[string]any{
+		"content": map[string]any{
+			"role":  "model",
+			"parts": []any{map[string]any{"text": text}},
+		},
+	}
+}
+
+func TestEnrich_SelectsBestCandidate(t *testing.T) {
+	ts := newFakeGeminiResponse(t, map[string]any{
+		"candidates": []any{
+			textCandidate(`not json`),
+			textCandidate(`{"linkedin_url":"https://linkedin.com/in/a","company":"Low Co","title":"Eng","description":"d","confidence":"low"}`),
+			map[string]any{"finishReason": "SAFETY"},
+			textCandidate(`{"linkedin_url":"","company":"High Co","title":"","description":"","confidence":"high"}`),
+			textCandidate(`{"linkedin_url":"","company":"Best Co","title":"CTO","description":"","confidence":"High"}`),
+		},
+	})
+	e, err := New(context.Background(), Config{APIKey: "test-key", Model: "test-model", BaseURL: ts.URL, Candidates: 5, CaptureRawResponse: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	res, err := e.Enrich(context.Background(), "alice@example.com")
+	if err != nil {
+		t.Fatalf("Enrich: %v", err)
+	}
+	if res.Company != "Best Co" || res.Candidate != 4 {
+		t.Fatalf("expected candidate 4 (Best Co), got %d (%q)", res.Candidate, res.Company)
+	}
+	if !strings.Contains(res.RawResponse, "Best Co") {
+		t.Fatalf("expected the chosen candidate's raw response, got %q", res.RawResponse)
+	}
+}
+
+func TestEnrich_CandidatesFallBackPastParseFailures(t *testing.T) {
+	ts := newFakeGeminiResponse(t, map[string]any{
+		"candidates": []any{
+			textCandidate(`{"company":`),
+			textCandidate(`{"linkedin_url":"","company":"Example","title":"","description":"","confidence":"low"}`),
+		},
+	})
+	e, err := New(context.Background(), Config{APIKey: "test-key", Model: "test-model", BaseURL: ts.URL, Candidates: 2})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	res, err := e.Enrich(context.Background(), "alice@example.com")
+	if err != nil {
+		t.Fatalf("Enrich: %v", err)
+	}
+	if res.Company != "Example" || res.Candidate != 1 {
+		t.Fatalf("expected the parseable candidate 1, got %d (%q)", res.Candidate, res.Company)
+	}
+}
+
+func TestEnrich_AllCandidatesUnparseable(t *testing.T) {
+	ts := newFakeGeminiResponse(t, map[string]any{
+		"candidates": []any{textCandidate(`nope`), textCandidate(`{"company":`)},
+	})
+	e, err := New(context.Background(), Config{APIKey: "test-key", Model: "test-model", BaseURL: ts.URL, Candidates: 2, CaptureRawResponse: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	res, err := e.Enrich(context.Background(), "alice@example.com")
+	if err == nil || !strings.Contains(err.Error(), "parse structured json") {
+		t.Fatalf("expected a parse error, got %v", err)
+	}
+	if res.RawResponse != "nope" {
+		t.Fatalf("expected the first candidate's raw response, got %q", res.RawResponse)
+	}
+}
+
+func TestNew_RejectsInvalidCandidates(t *testing.T) {
+	for _, n := range []int{-1, MaxCandidates + 1} {
+		if _, err := New(context.Background(), Config{APIKey: "test-key", Model: "test-model", Candidates: n}); err == nil {
+			t.Fatalf("expected candidates=%d to be rejected", n)
+		}
+	}
+}
@@ -2,13 +2,19 @@ package gemini
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"net"
+	"net/http"
+	"slices"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/enrich"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/redact"
 	"google.golang.org/genai"
 )
 
@@ -21,15 +27,95 @@ type Config struct {
 
 	// CaptureAudit controls whether sources/queries are extracted into the output.
 	CaptureAudit bool
+
+	// CaptureRawResponse stores the raw model response text (redacted and truncated to
+	// RawResponseMaxBytes) on each result. It is independent of CaptureAudit.
+	CaptureRawResponse  bool
+	RawResponseMaxBytes int
+
+	// Temperature, TopK, and TopP override the model's sampling defaults when non-nil. A low
+	// temperature gives more stable enrichment across runs.
+	Temperature *float32
+	TopK        *float32
+	TopP        *float32
+
+	// Candidates is how many response candidates to request per email (1 to MaxCandidates; zero
+	// means 1). With more than one, Enrich keeps the candidate with the highest self-reported
+	// confidence, then the most filled fields, and records its index in Result.Candidate.
+	// Candidates that are blocked or fail to parse are skipped.
+	Candidates int
+
+	// DisableSearch and DisableURLContext drop the Google Search and URL context tools from the request.
+	DisableSearch     bool
+	DisableURLContext bool
+
+	// HTTPClient overrides the HTTP client used for Gemini requests (for example a custom transport).
+	// Nil uses the genai default.
+	HTTPClient *http.Client
+
+	// PromptHook, when set, is called with each email, its context record (nil from Enrich), and
+	// the rendered prompt before the request is sent, including on retries. PromptDump.Record is
+	// the usual hook.
+	PromptHook func(email string, record map[string]string, prompt string)
 }
 
+// DefaultRawResponseMaxBytes bounds captured raw responses when RawResponseMaxBytes is unset.
+const DefaultRawResponseMaxBytes = 2048
+
+// Enricher enriches emails with Gemini. It is safe for concurrent use, including Reconfigure.
 type Enricher struct {
+	mu    sync.RWMutex
+	state *enricherState
+}
+
+// enricherState is the client and request settings built from one Config. It is never mutated;
+// Reconfigure swaps in a new one.
+type enricherState struct {
 	client       *genai.Client
 	model        string
 	captureAudit bool
+
+	captureRaw  bool
+	rawMaxBytes int
+
+	temperature *float32
+	topK        *float32
+	topP        *float32
+	candidates  int32
+	tools       []*genai.Tool
+	promptHook  func(email string, record map[string]string, prompt string)
 }
 
 func New(ctx context.Context, cfg Config) (*Enricher, error) {
+	st, err := newEnricherState(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Enricher{state: st}, nil
+}
+
+// Reconfigure rebuilds the Gemini client from cfg, for example to rotate the API key without a
+// process restart. Enrich calls already in flight finish with the previous client; later calls use
+// the new one. An invalid cfg returns an error and leaves the current configuration in place.
+func (e *Enricher) Reconfigure(ctx context.Context, cfg Config) error {
+	st, err := newEnricherState(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.state = st
+	return nil
+}
+
+// current returns the configuration Enrich calls should use.
+func (e *Enricher) current() *enricherState {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.state
+}
+
+func newEnricherState(ctx context.Context, cfg Config) (*enricherState, error) {
 	if strings.TrimSpace(cfg.APIKey) == "" {
 		return nil, fmt.Errorf("GEMINI_API_KEY is required")
 	}
@@ -37,9 +123,27 @@ func New(ctx context.Context, cfg Config) (*Enricher, error) {
 		return nil, fmt.Errorf("GEMINI_MODEL is required")
 	}
 
+	if cfg.Temperature != nil && (*cfg.Temperature < 0 || *cfg.Temperature > 2) {
+		return nil, fmt.Errorf("invalid gemini temperature %v (expected 0-2)", *cfg.Temperature)
+	}
+	if cfg.TopK != nil && *cfg.TopK < 1 {
+		return nil, fmt.Errorf("invalid gemini top-k %v (expected >= 1)", *cfg.TopK)
+	}
+	if cfg.TopP != nil && (*cfg.TopP < 0 || *cfg.TopP > 1) {
+		return nil, fmt.Errorf("invalid gemini top-p %v (expected 0-1)", *cfg.TopP)
+	}
+	candidates := cfg.Candidates
+	if candidates == 0 {
+		candidates = 1
+	}
+	if candidates < 1 || candidates > MaxCandidates {
+		return nil, fmt.Errorf("invalid gemini candidates %d (expected 1-%d)", cfg.Candidates, MaxCandidates)
+	}
+
 	cc := &genai.ClientConfig{
-		APIKey:  strings.TrimSpace(cfg.APIKey),
-		Backend: genai.BackendGeminiAPI,
+		APIKey:     strings.TrimSpace(cfg.APIKey),
+		Backend:    genai.BackendGeminiAPI,
+		HTTPClient: cfg.HTTPClient,
 	}
 	if strings.TrimSpace(cfg.BaseURL) != "" {
 		cc.HTTPOptions.BaseURL = strings.TrimSpace(cfg.BaseURL)
@@ -49,10 +153,29 @@ func New(ctx context.Context, cfg Config) (*Enricher, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Enricher{
+	rawMaxBytes := cfg.RawResponseMaxBytes
+	if rawMaxBytes <= 0 {
+		rawMaxBytes = DefaultRawResponseMaxBytes
+	}
+	var tools []*genai.Tool
+	if !cfg.DisableSearch {
+		tools = append(tools, &genai.Tool{GoogleSearch: &genai.GoogleSearch{}})
+	}
+	if !cfg.DisableURLContext {
+		tools = append(tools, &genai.Tool{URLContext: &genai.URLContext{}})
+	}
+	return &enricherState{
 		client:       client,
 		model:        strings.TrimSpace(cfg.Model),
 		captureAudit: cfg.CaptureAudit,
+		captureRaw:   cfg.CaptureRawResponse,
+		rawMaxBytes:  rawMaxBytes,
+		temperature:  cfg.Temperature,
+		topK:         cfg.TopK,
+		topP:         cfg.TopP,
+		candidates:   int32(candidates),
+		tools:        tools,
+		promptHook:   cfg.PromptHook,
 	}, nil
 }
 
@@ -64,6 +187,17 @@ type responseSchema struct {
 	Confidence  string `json:"confidence"`
 }
 
+// result returns the answer's fields as an enrich.Result, trimmed.
+func (s responseSchema) result() enrich.Result {
+	return enrich.Result{
+		LinkedInURL: strings.TrimSpace(s.LinkedInURL),
+		Company:     strings.TrimSpace(s.Company),
+		Title:       strings.TrimSpace(s.Title),
+		Description: strings.TrimSpace(s.Description),
+		Confidence:  strings.TrimSpace(s.Confidence),
+	}
+}
+
 var outputSchema = &genai.Schema{
 	Type: genai.TypeObject,
 	Properties: map[string]*genai.Schema{
@@ -83,23 +217,34 @@ var outputSchema = &genai.Schema{
 }
 
 func (e *Enricher) Enrich(ctx context.Context, email string) (enrich.Result, error) {
+	return e.EnrichRecord(ctx, email, nil)
+}
+
+// EnrichRecord is Enrich with other input row fields (for example first_name and last_name) added
+// to the prompt as known details, which helps the model pick the right person for a shared or
+// generic address. It implements enrich.RecordEnricher; empty values are left out.
+func (e *Enricher) EnrichRecord(ctx context.Context, email string, record map[string]string) (enrich.Result, error) {
+	st := e.current()
 	email = strings.TrimSpace(email)
-	base := enrich.Result{Model: e.model}
+	base := enrich.Result{Model: st.model}
 	if email == "" {
 		return base, errors.New("empty email")
 	}
 
-	prompt := buildPrompt(email)
-	resp, err := e.client.Models.GenerateContent(
+	prompt := buildPrompt(email, record)
+	if st.promptHook != nil {
+		st.promptHook(email, record, prompt)
+	}
+	resp, err := st.client.Models.GenerateContent(
 		ctx,
-		e.model,
+		st.model,
 		genai.Text(prompt),
 		&genai.GenerateContentConfig{
-			Tools: []*genai.Tool{
-				{GoogleSearch: &genai.GoogleSearch{}},
-				{URLContext: &genai.URLContext{}},
-			},
-			CandidateCount:   1,
+			Tools:            st.tools,
+			Temperature:      st.temperature,
+			TopK:             st.topK,
+			TopP:             st.topP,
+			CandidateCount:   st.candidates,
 			ResponseMIMEType: "application/json",
 			ResponseSchema:   outputSchema,
 		},
@@ -107,33 +252,95 @@ func (e *Enricher) Enrich(ctx context.Context, email string) (enrich.Result, err
 	if err != nil {
 		return base, classifyErr(err)
 	}
-
-	var parsed responseSchema
-	if err := json.Unmarshal([]byte(resp.Text()), &parsed); err != nil {
-		return base, fmt.Errorf("gemini: parse structured json: %w", err)
+	if reason, ok := blockedReason(resp); ok {
+		return base, fmt.Errorf("gemini: %w", &enrich.BlockedError{Reason: reason})
 	}
 
-	out := enrich.Result{
-		LinkedInURL: strings.TrimSpace(parsed.LinkedInURL),
-		Company:     strings.TrimSpace(parsed.Company),
-		Title:       strings.TrimSpace(parsed.Title),
-		Description: strings.TrimSpace(parsed.Description),
-		Confidence:  strings.TrimSpace(parsed.Confidence),
-		Model:       e.model,
+	// Usage is billed even when the answer fails to parse, so record it before parsing.
+	base.Usage = extractUsage(resp)
+
+	chosen, err := selectCandidate(resp)
+	if st.captureRaw {
+		// Keep the raw text on parse failures too: that is when it is most useful.
+		base.RawResponse = truncateRaw(redact.Secrets(chosen.text), st.rawMaxBytes)
+	}
+	if err != nil {
+		return base, err
 	}
 
-	if e.captureAudit {
-		out.Sources = extractSources(resp)
-		out.WebSearchQueries = extractWebSearchQueries(resp)
+	out := chosen.answer.result()
+	out.Model = st.model
+	out.RawResponse = base.RawResponse
+	out.Usage = base.Usage
+	out.Candidate = chosen.index
+
+	if st.captureAudit {
+		c := resp.Candidates[chosen.index]
+		out.Sources = extractSources(c)
+		out.WebSearchQueries = extractWebSearchQueries(c)
 	}
 
 	return out, nil
 }
 
-func buildPrompt(email string) string {
+// blockReasons are candidate finish reasons meaning the model withheld its answer.
+var blockReasons = map[genai.FinishReason]bool{
+	genai.FinishReasonSafety:                 true,
+	genai.FinishReasonRecitation:             true,
+	genai.FinishReasonBlocklist:              true,
+	genai.FinishReasonProhibitedContent:      true,
+	genai.FinishReasonSPII:                   true,
+	genai.FinishReasonImageSafety:            true,
+	genai.FinishReasonImageProhibitedContent: true,
+	genai.FinishReasonImageRecitation:        true,
+}
+
+// blockedReason reports whether the response was blocked (prompt feedback, no candidates, or a
+// blocking finish reason on every candidate) and why. Blocked responses carry no usable JSON, so
+// parsing them would only produce a misleading parse error. With several candidates the first
+// candidate's reason is reported.
+func blockedReason(resp *genai.GenerateContentResponse) (string, bool) {
+	if resp == nil {
+		return "EMPTY_RESPONSE", true
+	}
+	if fb := resp.PromptFeedback; fb != nil && fb.BlockReason != "" {
+		return string(fb.BlockReason), true
+	}
+	reason := ""
+	for _, c := range resp.Candidates {
+		if c == nil {
+			continue
+		}
+		if !blockReasons[c.FinishReason] {
+			return "", false
+		}
+		if reason == "" {
+			reason = string(c.FinishReason)
+		}
+	}
+	if reason == "" {
+		return "NO_CANDIDATES", true
+	}
+	return reason, true
+}
+
+// truncateRaw limits s to max bytes without splitting a UTF-8 sequence, marking truncation with "...".
+func truncateRaw(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "..."
+}
+
+func buildPrompt(email string, record map[string]string) string {
 	// Keep this prompt public-safe: do not include any secrets, and avoid embedding
-	// unnecessary PII beyond the email itself (required input to enrichment).
-	return strings.TrimSpace(`
+	// unnecessary PII beyond the email itself (required input to enrichment) and the record
+	// fields the caller chose to pass.
+	prompt := strings.TrimSpace(`
 You are a data enrichment tool. Given an email address, use web search and URL context to find likely public profile/company information.
 
 Return ONLY a single JSON object with these keys:
@@ -149,6 +356,26 @@ Rules:
 
 Email: ` + email + `
 `)
+	var details []string
+	for _, k := range slices.Sorted(maps.Keys(record)) {
+		if line, ok := detailLine(k, record[k]); ok {
+			details = append(details, line)
+		}
+	}
+	if len(details) == 0 {
+		return prompt
+	}
+	return prompt + "\n\nKnown details about this person (use them to pick the right profile):\n" + strings.Join(details, "\n")
+}
+
+// detailLine renders one record field as a known-details prompt line. Empty values and the email
+// column are left out.
+func detailLine(k, v string) (string, bool) {
+	k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+	if v == "" || strings.EqualFold(k, "email") {
+		return "", false
+	}
+	return "- " + k + ": " + v, true
 }
 
 func classifyErr(err error) error {
@@ -164,7 +391,7 @@ func classifyErr(err error) error {
 			}
 		}
 		if apiErr.Code == 429 || apiErr.Code/100 == 5 {
-			return &enrich.TransientError{Err: err}
+			return &enrich.TransientError{Err: err, RetryAfter: retryDelay(apiErr)}
 		}
 		return err
 	}
@@ -175,11 +402,39 @@ func classifyErr(err error) error {
 	return err
 }
 
-func extractSources(resp *genai.GenerateContentResponse) []string {
-	if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0] == nil {
+// retryDelay extracts the google.rpc.RetryInfo retryDelay (e.g. "30s") from an API error, if any.
+func retryDelay(apiErr genai.APIError) time.Duration {
+	for _, detail := range apiErr.Details {
+		typ, _ := detail["@type"].(string)
+		if !strings.HasSuffix(typ, "google.rpc.RetryInfo") {
+			continue
+		}
+		raw, _ := detail["retryDelay"].(string)
+		d, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err == nil && d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// extractUsage reports billed token counts: tool-use prompt tokens count as prompt tokens and
+// thinking tokens as response tokens. It returns nil when the response carries no usage metadata.
+func extractUsage(resp *genai.GenerateContentResponse) *enrich.Usage {
+	if resp == nil || resp.UsageMetadata == nil {
+		return nil
+	}
+	m := resp.UsageMetadata
+	return &enrich.Usage{
+		PromptTokens:   int(m.PromptTokenCount + m.ToolUsePromptTokenCount),
+		ResponseTokens: int(m.CandidatesTokenCount + m.ThoughtsTokenCount),
+	}
+}
+
+func extractSources(c *genai.Candidate) []string {
+	if c == nil {
 		return nil
 	}
-	c := resp.Candidates[0]
 
 	var out []string
 	if c.GroundingMetadata != nil {
@@ -206,12 +461,8 @@ func extractSources(resp *genai.GenerateContentResponse) []string {
 	return dedupePreserveOrder(out)
 }
 
-func extractWebSearchQueries(resp *genai.GenerateContentResponse) []string {
-	if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0] == nil {
-		return nil
-	}
-	c := resp.Candidates[0]
-	if c.GroundingMetadata == nil {
+func extractWebSearchQueries(c *genai.Candidate) []string {
+	if c == nil || c.GroundingMetadata == nil {
 		return nil
 	}
 	return dedupePreserveOrder(c.GroundingMetadata.WebSearchQueries)
@@ -3,6 +3,7 @@ package gemini
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/enrich"
 	"google.golang.org/genai"
@@ -50,3 +51,21 @@ func TestClassifyErr(t *testing.T) {
 		})
 	}
 }
+
+func TestClassifyErr_RetryInfoSetsRetryAfter(t *testing.T) {
+	in := genai.APIError{
+		Code:   429,
+		Status: "RESOURCE_EXHAUSTED",
+		Details: []map[string]any{
+			{"@type": "type.googleapis.com/google.rpc.QuotaFailure"},
+			{"@type": "type.googleapis.com/google.rpc.RetryInfo", "retryDelay": "31s"},
+		},
+	}
+	var te *enrich.TransientError
+	if !errors.As(classifyErr(in), &te) {
+		t.Fatalf("expected transient error")
+	}
+	if te.RetryAfter != 31*time.Second {
+		t.Fatalf("RetryAfter=%s want 31s", te.RetryAfter)
+	}
+}
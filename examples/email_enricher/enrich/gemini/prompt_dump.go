@@ -0,0 +1,81 @@
+package gemini
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/redact"
+)
+
+// DefaultPromptDumpMax bounds how many prompt files a PromptDump writes when Max is unset.
+const DefaultPromptDumpMax = 20
+
+// PromptDump writes the rendered prompt of the first Max distinct emails to Dir, one file per
+// email, for debugging enrichment quality. Use its Record method as Config.PromptHook. Prompts
+// are passed through redact.Secrets; with RedactPII the email is replaced by its redact.HashEmail
+// under the hash key (as in the audit sink), and every context record value by "<redacted>". File
+// names are always derived from the hash, never
+// the email. It is safe for concurrent use.
+type PromptDump struct {
+	dir       string
+	max       int
+	redactPII bool
+	hashKey   []byte
+
+	mu     sync.Mutex
+	seen   map[string]bool
+	failed bool
+}
+
+// NewPromptDump creates dir (mode 0700) and returns a dump writing at most max prompts there;
+// max <= 0 means DefaultPromptDumpMax. redactPII requires a hashKey.
+func NewPromptDump(dir string, max int, redactPII bool, hashKey []byte) (*PromptDump, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return nil, fmt.Errorf("prompt dump directory is required")
+	}
+	if redactPII && len(hashKey) == 0 {
+		return nil, fmt.Errorf("redacting PII in prompt dumps requires a hash key")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create prompt dump directory: %w", err)
+	}
+	if max <= 0 {
+		max = DefaultPromptDumpMax
+	}
+	return &PromptDump{dir: dir, max: max, redactPII: redactPII, hashKey: hashKey, seen: map[string]bool{}}, nil
+}
+
+// Record writes prompt for email and its context record unless the cap is reached or email was
+// already recorded, so retries do not use up the sample. A write failure is logged once and stops
+// further dumps; it never fails the enrichment.
+func (d *PromptDump) Record(email string, record map[string]string, prompt string) {
+	hash := redact.HashEmail(d.hashKey, email)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.failed || d.seen[hash] || len(d.seen) >= d.max {
+		return
+	}
+	d.seen[hash] = true
+
+	text := prompt
+	if d.redactPII {
+		for k, v := range record {
+			if line, ok := detailLine(k, v); ok {
+				redacted, _ := detailLine(k, "<redacted>")
+				text = strings.ReplaceAll(text, line, redacted)
+			}
+		}
+		text = strings.ReplaceAll(text, strings.TrimSpace(email), "hmac:"+hash)
+	}
+	text = redact.Secrets(text)
+	path := filepath.Join(d.dir, "prompt-"+hash[:16]+".txt")
+	if err := os.WriteFile(path, []byte(text+"\n"), 0o600); err != nil {
+		d.failed = true
+		log.Printf("warning: dump prompt: %s; no further prompts are dumped", err)
+	}
+}
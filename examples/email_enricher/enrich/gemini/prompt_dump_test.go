@@ -0,0 +1,86 @@
+package gemini
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/redact"
+)
+
+func TestEnrich_PromptDumpWritesRedactedSample(t *testing.T) {
+	ts := newFakeGemini(t, `{"linkedin_url":"","company":"Example","title":"","description":"","confidence":"low"}`)
+	dir := filepath.Join(t.TempDir(), "prompts")
+	key := []byte("test-hash-key")
+
+	for _, tc := range []struct {
+		name      string
+		redactPII bool
+		email     string
+		forbid    string
+	}{
+		{name: "pii hashed", redactPII: true, email: "alice@example.com", forbid: "alice@example.com"},
+		{name: "secrets redacted", email: "api_key=sk-leak", forbid: "sk-leak"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dumpDir := filepath.Join(dir, strings.ReplaceAll(tc.name, " ", "-"))
+			dump, err := NewPromptDump(dumpDir, 1, tc.redactPII, key)
+			if err != nil {
+				t.Fatalf("NewPromptDump: %v", err)
+			}
+			e, err := New(context.Background(), Config{APIKey: "test-key", Model: "test-model", BaseURL: ts.URL, PromptHook: dump.Record})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			// The second email is past the cap of one, and the repeat is already sampled.
+			record := map[string]string{"first_name": "Alicia", "last_name": "Smithers"}
+			for _, email := range []string{tc.email, "bob@example.com", tc.email} {
+				if _, err := e.EnrichRecord(context.Background(), email, record); err != nil {
+					t.Fatalf("Enrich(%q): %v", email, err)
+				}
+			}
+
+			entries, err := os.ReadDir(dumpDir)
+			if err != nil {
+				t.Fatalf("read dump dir: %v", err)
+			}
+			if len(entries) != 1 {
+				t.Fatalf("expected 1 prompt file, got %d", len(entries))
+			}
+			name := entries[0].Name()
+			if want := "prompt-" + redact.HashEmail(key, tc.email)[:16] + ".txt"; name != want {
+				t.Fatalf("expected %s, got %s", want, name)
+			}
+			b, err := os.ReadFile(filepath.Join(dumpDir, name))
+			if err != nil {
+				t.Fatalf("read prompt file: %v", err)
+			}
+			text := string(b)
+			if !strings.Contains(text, "Return ONLY a single JSON object") {
+				t.Fatalf("expected the rendered prompt, got %q", text)
+			}
+			if strings.Contains(text, tc.forbid) {
+				t.Fatalf("expected %q to be redacted, got %q", tc.forbid, text)
+			}
+			if tc.redactPII && !strings.Contains(text, "hmac:"+redact.HashEmail(key, tc.email)) {
+				t.Fatalf("expected the hashed email in the prompt, got %q", text)
+			}
+			for _, v := range []string{"Alicia", "Smithers"} {
+				if got := strings.Contains(text, v); got == tc.redactPII {
+					t.Fatalf("expected context value %q in the prompt only without redactPII, got %q", v, text)
+				}
+			}
+			if tc.redactPII && !strings.Contains(text, "- first_name: <redacted>\n- last_name: <redacted>") {
+				t.Fatalf("expected redacted context details, got %q", text)
+			}
+		})
+	}
+}
+
+func TestNewPromptDump_RedactPIIRequiresHashKey(t *testing.T) {
+	if _, err := NewPromptDump(t.TempDir(), 1, true, nil); err == nil {
+		t.Fatalf("expected an error without a hash key")
+	}
+}
@@ -0,0 +1,90 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newFakeGemini serves generateContent with a single candidate whose text is text.
+func newFakeGemini(t *testing.T, text string) *httptest.Server {
+	t.Helper()
+	return newFakeGeminiResponse(t, map[string]any{
+		"candidates": []any{
+			map[string]any{
+				"content": map[string]any{
+					"role":  "model",
+					"parts": []any{map[string]any{"text": text}},
+				},
+			},
+		},
+	})
+}
+
+// newFakeGeminiResponse serves generateContent with body as the JSON response.
+func newFakeGeminiResponse(t *testing.T, body map[string]any) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, ":generateContent") {
+			http.Error(w, "unexpected request "+r.Method+" "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestEnrich_CapturesRawResponseTruncatedAndRedacted(t *testing.T) {
+	text := `{"linkedin_url":"","company":"Example","title":"","description":"api_key=sk-secret ` +
+		strings.Repeat("x", 200) + `","confidence":"low"}`
+	ts := newFakeGemini(t, text)
+
+	e, err := New(context.Background(), Config{
+		APIKey:              "test-key",
+		Model:               "test-model",
+		BaseURL:             ts.URL,
+		CaptureRawResponse:  true,
+		RawResponseMaxBytes: 64,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	out, err := e.Enrich(context.Background(), "alice@example.com")
+	if err != nil {
+		t.Fatalf("Enrich: %v", err)
+	}
+	if out.Company != "Example" {
+		t.Fatalf("expected parsed company, got %#v", out)
+	}
+	if !strings.HasSuffix(out.RawResponse, "...") || len(out.RawResponse) != 64+len("...") {
+		t.Fatalf("expected raw response truncated to 64 bytes plus marker, got %d bytes: %q", len(out.RawResponse), out.RawResponse)
+	}
+	if strings.Contains(out.RawResponse, "sk-secret") {
+		t.Fatalf("expected raw response to be redacted, got %q", out.RawResponse)
+	}
+	if !strings.HasPrefix(out.RawResponse, `{"linkedin_url":"","company":"Example"`) {
+		t.Fatalf("unexpected raw response prefix: %q", out.RawResponse)
+	}
+}
+
+func TestEnrich_RawResponseNotCapturedByDefault(t *testing.T) {
+	ts := newFakeGemini(t, `{"linkedin_url":"","company":"Example","title":"","description":"","confidence":"low"}`)
+
+	e, err := New(context.Background(), Config{APIKey: "test-key", Model: "test-model", BaseURL: ts.URL})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	out, err := e.Enrich(context.Background(), "alice@example.com")
+	if err != nil {
+		t.Fatalf("Enrich: %v", err)
+	}
+	if out.RawResponse != "" {
+		t.Fatalf("expected no raw response without CaptureRawResponse, got %q", out.RawResponse)
+	}
+}
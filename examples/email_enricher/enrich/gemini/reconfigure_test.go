@@ -0,0 +1,121 @@
+package gemini
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeTransport answers every generateContent request with a single candidate and records the API
+// key each request carried. When release is non-nil, requests block until it is closed.
+type fakeTransport struct {
+	company string
+	started chan struct{}
+	release chan struct{}
+
+	mu   sync.Mutex
+	keys []string
+}
+
+func (f *fakeTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	f.keys = append(f.keys, r.Header.Get("x-goog-api-key"))
+	f.mu.Unlock()
+	if f.started != nil {
+		f.started <- struct{}{}
+	}
+	if f.release != nil {
+		<-f.release
+	}
+	body := `{"candidates":[{"content":{"role":"model","parts":[{"text":` +
+		`"{\"linkedin_url\":\"\",\"company\":\"` + f.company + `\",\"title\":\"\",\"description\":\"\",\"confidence\":\"low\"}"` +
+		`}]}}]}`
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    r,
+	}, nil
+}
+
+func (f *fakeTransport) seenKeys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.keys...)
+}
+
+func TestEnricher_ReconfigureSwapsClient(t *testing.T) {
+	ctx := context.Background()
+	before := &fakeTransport{company: "Before"}
+	after := &fakeTransport{company: "After"}
+
+	e, err := New(ctx, Config{APIKey: "old-key", Model: "test-model", HTTPClient: &http.Client{Transport: before}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	out, err := e.Enrich(ctx, "alice@example.com")
+	if err != nil || out.Company != "Before" {
+		t.Fatalf("enrich before reconfigure: out=%#v err=%v", out, err)
+	}
+
+	if err := e.Reconfigure(ctx, Config{APIKey: "new-key", Model: "test-model-2", HTTPClient: &http.Client{Transport: after}}); err != nil {
+		t.Fatalf("Reconfigure: %v", err)
+	}
+	out, err = e.Enrich(ctx, "alice@example.com")
+	if err != nil || out.Company != "After" || out.Model != "test-model-2" {
+		t.Fatalf("enrich after reconfigure: out=%#v err=%v", out, err)
+	}
+
+	if got := before.seenKeys(); len(got) != 1 || got[0] != "old-key" {
+		t.Fatalf("unexpected keys before reconfigure: %v", got)
+	}
+	if got := after.seenKeys(); len(got) != 1 || got[0] != "new-key" {
+		t.Fatalf("unexpected keys after reconfigure: %v", got)
+	}
+
+	if err := e.Reconfigure(ctx, Config{Model: "test-model"}); err == nil {
+		t.Fatalf("expected Reconfigure without an API key to fail")
+	}
+	out, err = e.Enrich(ctx, "alice@example.com")
+	if err != nil || out.Company != "After" {
+		t.Fatalf("a failed Reconfigure must keep the current client: out=%#v err=%v", out, err)
+	}
+}
+
+func TestEnricher_ReconfigureLetsInFlightCallsFinish(t *testing.T) {
+	ctx := context.Background()
+	before := &fakeTransport{company: "Before", started: make(chan struct{}, 1), release: make(chan struct{})}
+	after := &fakeTransport{company: "After"}
+
+	e, err := New(ctx, Config{APIKey: "old-key", Model: "test-model", HTTPClient: &http.Client{Transport: before}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	type result struct {
+		company string
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		out, err := e.Enrich(ctx, "alice@example.com")
+		done <- result{out.Company, err}
+	}()
+	<-before.started
+
+	// Reconfigure must not wait for the in-flight call.
+	if err := e.Reconfigure(ctx, Config{APIKey: "new-key", Model: "test-model", HTTPClient: &http.Client{Transport: after}}); err != nil {
+		t.Fatalf("Reconfigure: %v", err)
+	}
+	if out, err := e.Enrich(ctx, "bob@example.com"); err != nil || out.Company != "After" {
+		t.Fatalf("enrich after reconfigure: out=%#v err=%v", out, err)
+	}
+
+	close(before.release)
+	if res := <-done; res.err != nil || res.company != "Before" {
+		t.Fatalf("in-flight call should finish on the previous client: %#v", res)
+	}
+}
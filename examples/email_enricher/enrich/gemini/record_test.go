@@ -0,0 +1,44 @@
+package gemini
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEnrichRecord_PromptIncludesContext(t *testing.T) {
+	ts := newFakeGemini(t, `{"linkedin_url":"","company":"Acme","title":"","description":"","confidence":"low"}`)
+	var prompts []string
+	e, err := New(context.Background(), Config{
+		APIKey:     "test-key",
+		Model:      "test-model",
+		BaseURL:    ts.URL,
+		PromptHook: func(_ string, _ map[string]string, prompt string) { prompts = append(prompts, prompt) },
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	record := map[string]string{"last_name": "Smith", "first_name": "Alice", "email": "alice@example.com", "company": " "}
+	if _, err := e.EnrichRecord(context.Background(), "alice@example.com", record); err != nil {
+		t.Fatalf("EnrichRecord: %v", err)
+	}
+	if _, err := e.Enrich(context.Background(), "bob@example.com"); err != nil {
+		t.Fatalf("Enrich: %v", err)
+	}
+	if len(prompts) != 2 {
+		t.Fatalf("expected 2 prompts, got %d", len(prompts))
+	}
+
+	// Details are sorted by key; empty values and the email column are left out.
+	want := "Known details about this person (use them to pick the right profile):\n- first_name: Alice\n- last_name: Smith"
+	if !strings.HasSuffix(prompts[0], want) {
+		t.Fatalf("expected prompt to end with %q, got %q", want, prompts[0])
+	}
+	if strings.Contains(prompts[0], "- company:") || strings.Contains(prompts[0], "- email:") {
+		t.Fatalf("unexpected detail in prompt: %q", prompts[0])
+	}
+	if strings.Contains(prompts[1], "Known details") {
+		t.Fatalf("expected no details for a plain Enrich, got %q", prompts[1])
+	}
+}
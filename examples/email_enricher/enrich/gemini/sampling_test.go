@@ -0,0 +1,138 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// generateContentRequest is the subset of the generateContent request body these tests inspect.
+type generateContentRequest struct {
+	GenerationConfig struct {
+		Temperature    *float32 `json:"temperature"`
+		TopK           *float32 `json:"topK"`
+		TopP           *float32 `json:"topP"`
+		CandidateCount int      `json:"candidateCount"`
+	} `json:"generationConfig"`
+	Tools []map[string]any `json:"tools"`
+}
+
+// newRecordingGemini serves a fixed structured response and records each generateContent request body.
+func newRecordingGemini(t *testing.T) (*httptest.Server, func() []generateContentRequest) {
+	t.Helper()
+	var (
+		mu   sync.Mutex
+		reqs []generateContentRequest
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req generateContentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		reqs = append(reqs, req)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"candidates": []any{map[string]any{
+				"content": map[string]any{
+					"role":  "model",
+					"parts": []any{map[string]any{"text": `{"linkedin_url":"","company":"Example","title":"","description":"","confidence":"low"}`}},
+				},
+			}},
+		})
+	}))
+	t.Cleanup(ts.Close)
+	return ts, func() []generateContentRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]generateContentRequest(nil), reqs...)
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestEnrich_ForwardsSamplingConfigAndToolToggles(t *testing.T) {
+	ts, requests := newRecordingGemini(t)
+
+	e, err := New(context.Background(), Config{
+		APIKey:        "test-key",
+		Model:         "test-model",
+		BaseURL:       ts.URL,
+		Temperature:   ptr[float32](0.1),
+		TopK:          ptr[float32](8),
+		TopP:          ptr[float32](0.5),
+		DisableSearch: true,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := e.Enrich(context.Background(), "alice@example.com"); err != nil {
+		t.Fatalf("Enrich: %v", err)
+	}
+
+	reqs := requests()
+	if len(reqs) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(reqs))
+	}
+	gc := reqs[0].GenerationConfig
+	if gc.Temperature == nil || *gc.Temperature != 0.1 {
+		t.Fatalf("expected temperature 0.1, got %v", gc.Temperature)
+	}
+	if gc.TopK == nil || *gc.TopK != 8 {
+		t.Fatalf("expected topK 8, got %v", gc.TopK)
+	}
+	if gc.TopP == nil || *gc.TopP != 0.5 {
+		t.Fatalf("expected topP 0.5, got %v", gc.TopP)
+	}
+	if gc.CandidateCount != 1 {
+		t.Fatalf("expected candidateCount 1, got %d", gc.CandidateCount)
+	}
+	if len(reqs[0].Tools) != 1 {
+		t.Fatalf("expected only the URL context tool, got %v", reqs[0].Tools)
+	}
+	if _, ok := reqs[0].Tools[0]["urlContext"]; !ok {
+		t.Fatalf("expected urlContext tool, got %v", reqs[0].Tools[0])
+	}
+}
+
+func TestEnrich_OmitsSamplingConfigByDefault(t *testing.T) {
+	ts, requests := newRecordingGemini(t)
+
+	e, err := New(context.Background(), Config{APIKey: "test-key", Model: "test-model", BaseURL: ts.URL})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := e.Enrich(context.Background(), "alice@example.com"); err != nil {
+		t.Fatalf("Enrich: %v", err)
+	}
+
+	reqs := requests()
+	if len(reqs) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(reqs))
+	}
+	gc := reqs[0].GenerationConfig
+	if gc.Temperature != nil || gc.TopK != nil || gc.TopP != nil {
+		t.Fatalf("expected no sampling overrides, got temperature=%v topK=%v topP=%v", gc.Temperature, gc.TopK, gc.TopP)
+	}
+	if len(reqs[0].Tools) != 2 {
+		t.Fatalf("expected search and URL context tools, got %v", reqs[0].Tools)
+	}
+}
+
+func TestNew_RejectsOutOfRangeSampling(t *testing.T) {
+	for name, cfg := range map[string]Config{
+		"temperature": {Temperature: ptr[float32](2.5)},
+		"topK":        {TopK: ptr[float32](0)},
+		"topP":        {TopP: ptr[float32](1.5)},
+	} {
+		cfg.APIKey, cfg.Model = "test-key", "test-model"
+		if _, err := New(context.Background(), cfg); err == nil {
+			t.Fatalf("%s: expected out-of-range value to be rejected", name)
+		}
+	}
+}
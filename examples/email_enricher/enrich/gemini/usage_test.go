@@ -0,0 +1,57 @@
+package gemini
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEnrich_ExtractsUsageMetadata(t *testing.T) {
+	ts := newFakeGeminiResponse(t, map[string]any{
+		"candidates": []any{
+			map[string]any{
+				"content": map[string]any{
+					"role":  "model",
+					"parts": []any{map[string]any{"text": `{"linkedin_url":"","company":"Example","title":"","description":"","confidence":"low"}`}},
+				},
+			},
+		},
+		"usageMetadata": map[string]any{
+			"promptTokenCount":        100,
+			"toolUsePromptTokenCount": 20,
+			"candidatesTokenCount":    30,
+			"thoughtsTokenCount":      5,
+			"totalTokenCount":         155,
+		},
+	})
+
+	e, err := New(context.Background(), Config{APIKey: "test-key", Model: "test-model", BaseURL: ts.URL})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	out, err := e.Enrich(context.Background(), "alice@example.com")
+	if err != nil {
+		t.Fatalf("Enrich: %v", err)
+	}
+	if out.Usage == nil {
+		t.Fatalf("expected usage to be populated, got %#v", out)
+	}
+	if out.Usage.PromptTokens != 120 || out.Usage.ResponseTokens != 35 {
+		t.Fatalf("expected prompt=120 response=35, got %#v", *out.Usage)
+	}
+}
+
+func TestEnrich_UsageNilWhenMetadataAbsent(t *testing.T) {
+	ts := newFakeGemini(t, `{"linkedin_url":"","company":"Example","title":"","description":"","confidence":"low"}`)
+
+	e, err := New(context.Background(), Config{APIKey: "test-key", Model: "test-model", BaseURL: ts.URL})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	out, err := e.Enrich(context.Background(), "alice@example.com")
+	if err != nil {
+		t.Fatalf("Enrich: %v", err)
+	}
+	if out.Usage != nil {
+		t.Fatalf("expected nil usage without usageMetadata, got %#v", *out.Usage)
+	}
+}
@@ -2,6 +2,7 @@ package enrich
 
 import (
 	"context"
+	"strings"
 
 	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/core"
 )
@@ -20,6 +21,44 @@ type Result struct {
 	Model            string
 	Sources          []string
 	WebSearchQueries []string
+
+	// RawResponse optionally holds the redacted, truncated model response text for debugging.
+	RawResponse string
+
+	// Usage is the provider-reported token usage for the request, or nil when none was reported.
+	Usage *Usage
+
+	// Candidate is the index of the response candidate the fields were taken from when the backend
+	// requested several and picked the best (gemini.Config.Candidates); otherwise 0.
+	Candidate int
+}
+
+// FieldNames names the enrichment fields of a Result, in the order Fields returns their values.
+// Completeness and empty-result checks count these.
+func FieldNames() []string {
+	return []string{"linkedin_url", "company", "title", "description"}
+}
+
+// Fields returns r's enrichment field values in FieldNames order.
+func (r Result) Fields() []string {
+	return []string{r.LinkedInURL, r.Company, r.Title, r.Description}
+}
+
+// FilledFields returns how many of r's enrichment fields are non-empty.
+func (r Result) FilledFields() int {
+	filled := 0
+	for _, v := range r.Fields() {
+		if strings.TrimSpace(v) != "" {
+			filled++
+		}
+	}
+	return filled
+}
+
+// Usage is the token usage of one enrichment request, for cost attribution.
+type Usage struct {
+	PromptTokens   int
+	ResponseTokens int
 }
 
 // Enricher enriches a single email address.
@@ -27,6 +66,37 @@ type Enricher interface {
 	Enrich(ctx context.Context, email string) (Result, error)
 }
 
+// RecordEnricher is an Enricher that can also use other fields of the input row, for example a
+// known first and last name, to improve match confidence. record maps input column names to
+// values; it holds only the columns the caller chose to pass and may be empty.
+type RecordEnricher interface {
+	Enricher
+	EnrichRecord(ctx context.Context, email string, record map[string]string) (Result, error)
+}
+
+// EnrichRecord enriches email with record when e is a RecordEnricher, and with Enrich otherwise, so
+// string-only enrichers keep working unchanged. An empty record always uses Enrich.
+func EnrichRecord(ctx context.Context, e Enricher, email string, record map[string]string) (Result, error) {
+	if re, ok := e.(RecordEnricher); ok && len(record) > 0 {
+		return re.EnrichRecord(ctx, email, record)
+	}
+	return e.Enrich(ctx, email)
+}
+
+// BlockedError reports that the provider declined to answer, for example a safety block. It is not
+// retryable: the same email would be blocked again. Output rows for blocked emails get status=blocked.
+type BlockedError struct {
+	// Reason is the provider's block or finish reason (for example "SAFETY").
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	if e == nil || e.Reason == "" {
+		return "response blocked by provider"
+	}
+	return "response blocked by provider (reason=" + e.Reason + ")"
+}
+
 // TransientError marks an error as retryable by pipeline workers.
 type TransientError = core.TransientError
 
@@ -0,0 +1,97 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/redact"
+)
+
+// AuditRecord is the redacted audit trail entry for one enrichment decision. It never carries the
+// raw email: EmailHMAC identifies it, and Fields holds only the non-empty profile fields the
+// enricher returned.
+type AuditRecord struct {
+	RunID     string            `json:"run_id,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+	EmailHMAC string            `json:"email_hmac"`
+	Status    string            `json:"status"`
+	Error     string            `json:"error,omitempty"`
+	Model     string            `json:"model,omitempty"`
+	Fields    map[string]string `json:"fields"`
+}
+
+// NewAuditRecord builds the audit record for an enriched row, identifying the email by its
+// redact.HashEmail under hashKey. The row's error is already redacted by the pipeline.
+func NewAuditRecord(row Row, runID string, at time.Time, hashKey []byte) AuditRecord {
+	fields := map[string]string{}
+	for col, v := range map[string]string{
+		"linkedin_url": row.LinkedInURL,
+		"company":      row.Company,
+		"title":        row.Title,
+		"description":  row.Description,
+		"confidence":   row.Confidence,
+	} {
+		if strings.TrimSpace(v) != "" {
+			fields[col] = v
+		}
+	}
+	return AuditRecord{
+		RunID:     runID,
+		Timestamp: at.UTC(),
+		EmailHMAC: redact.HashEmail(hashKey, row.Email),
+		Status:    row.Status,
+		Error:     row.Error,
+		Model:     row.Model,
+		Fields:    fields,
+	}
+}
+
+// AuditSink receives one AuditRecord per enriched email, separately from the output. Record is
+// called serially; an error fails the run, so enrichment never outpaces its audit trail.
+type AuditSink interface {
+	Record(ctx context.Context, rec AuditRecord) error
+	Close() error
+}
+
+// FileAuditSink appends audit records as newline-delimited JSON to a file. Existing records are
+// never rewritten.
+type FileAuditSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenFileAuditSink opens path for appending, creating it (mode 0600) if needed.
+func OpenFileAuditSink(path string) (*FileAuditSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileAuditSink{f: f}, nil
+}
+
+// Record appends rec as one JSON line.
+func (s *FileAuditSink) Record(_ context.Context, rec AuditRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.f.Write(append(b, '\n'))
+	return err
+}
+
+// Close syncs and closes the file.
+func (s *FileAuditSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.f.Sync(); err != nil {
+		_ = s.f.Close()
+		return err
+	}
+	return s.f.Close()
+}
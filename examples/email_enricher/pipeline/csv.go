@@ -4,29 +4,45 @@ import (
 	"encoding/csv"
 	"fmt"
 	"io"
+	"slices"
 	"strings"
+
+	localio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/local"
 )
 
 // WriteCSV writes rows as a CSV with the stable Header() ordering.
 func WriteCSV(w io.Writer, rows []Row) error {
+	return WriteCSVWithColumns(w, rows, nil)
+}
+
+// WriteCSVWithColumns writes rows with the stable Header() ordering followed by extraColumns,
+// whose values are taken from Row.Extra (missing values are written as empty strings).
+func WriteCSVWithColumns(w io.Writer, rows []Row, extraColumns []string) error {
+	return WriteCSVWithSchema(w, rows, DefaultRowSchema(), extraColumns)
+}
+
+// WriteLeanCSVWithColumns is WriteCSVWithColumns with LeanHeader() in place of Header(), for
+// outputs written without audit columns. ReadCSV reads the result back.
+func WriteLeanCSVWithColumns(w io.Writer, rows []Row, extraColumns []string) error {
+	return WriteCSVWithSchema(w, rows, DefaultRowSchema().Lean(), extraColumns)
+}
+
+// WriteCSVWithSchema is WriteCSVWithColumns with schema's columns in place of Header().
+func WriteCSVWithSchema(w io.Writer, rows []Row, schema RowSchema, extraColumns []string) error {
+	cols := schema.orDefault().Columns
 	cw := csv.NewWriter(w)
-	if err := cw.Write(Header()); err != nil {
+	if err := cw.Write(append(schema.Header(), extraColumns...)); err != nil {
 		return err
 	}
 	for _, r := range rows {
-		if err := cw.Write([]string{
-			r.Email,
-			r.LinkedInURL,
-			r.Company,
-			r.Title,
-			r.Description,
-			r.Confidence,
-			r.Status,
-			r.Error,
-			r.Model,
-			r.Sources,
-			r.WebSearchQueries,
-		}); err != nil {
+		rec := make([]string, 0, len(cols)+len(extraColumns))
+		for _, col := range cols {
+			rec = append(rec, col.get(r))
+		}
+		for _, col := range extraColumns {
+			rec = append(rec, r.Extra[col])
+		}
+		if err := cw.Write(rec); err != nil {
 			return err
 		}
 	}
@@ -36,58 +52,96 @@ func WriteCSV(w io.Writer, rows []Row) error {
 
 // ReadCSV reads rows from a CSV using the stable Header() contract.
 //
-// Extra columns are ignored. Required columns from Header() must exist.
+// Extra columns are ignored. Required columns from Header() must exist; the AuditColumns,
+// completeness and skip_reason may be missing and read as empty (see DefaultRowSchema). Field and
+// record sizes are capped by the default localio.CSVLimits.
 func ReadCSV(r io.Reader) ([]Row, error) {
-	cr := csv.NewReader(r)
-	cr.FieldsPerRecord = -1
+	return ReadCSVWithLimits(r, localio.CSVLimits{})
+}
+
+// ReadCSVWithLimits is ReadCSV with explicit field and record size limits.
+func ReadCSVWithLimits(r io.Reader, limits localio.CSVLimits) ([]Row, error) {
+	cr, err := NewCSVRowReader(r, limits)
+	if err != nil {
+		return nil, err
+	}
+	var rows []Row
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+// CSVRowReader reads output rows one at a time, as ReadCSVWithLimits does, so callers can process
+// a large CSV without holding every row.
+type CSVRowReader struct {
+	cr     *localio.CSVReader
+	schema RowSchema
+	header []string
+	index  map[string]int
+}
+
+// NewCSVRowReader reads and checks the header of r; see ReadCSV.
+func NewCSVRowReader(r io.Reader, limits localio.CSVLimits) (*CSVRowReader, error) {
+	return NewCSVRowReaderWithSchema(r, limits, DefaultRowSchema())
+}
+
+// NewCSVRowReaderWithSchema is NewCSVRowReader reading schema's columns in place of Header():
+// columns that are not Optional must exist, and columns outside the schema are ignored.
+func NewCSVRowReaderWithSchema(r io.Reader, limits localio.CSVLimits, schema RowSchema) (*CSVRowReader, error) {
+	schema = schema.orDefault()
+	cr := localio.NewCSVReader(r, limits)
 
 	header, err := cr.Read()
 	if err != nil {
 		return nil, err
 	}
 	index := make(map[string]int, len(header))
+	names := make([]string, len(header))
 	for i, name := range header {
 		if i == 0 {
 			name = strings.TrimPrefix(name, "\uFEFF")
 		}
-		index[strings.TrimSpace(name)] = i
+		names[i] = strings.TrimSpace(name)
+		index[names[i]] = i
 	}
-	for _, name := range Header() {
-		if _, ok := index[name]; !ok {
-			return nil, fmt.Errorf("missing required column %q", name)
+	for _, col := range schema.Columns {
+		if _, ok := index[col.Name]; !ok && !col.Optional {
+			return nil, fmt.Errorf("missing required column %q", col.Name)
 		}
 	}
+	return &CSVRowReader{cr: cr, schema: schema, header: names, index: index}, nil
+}
 
-	var rows []Row
-	for {
-		rec, err := cr.Read()
-		if err == io.EOF {
-			return rows, nil
-		}
-		if err != nil {
-			return nil, err
-		}
+// Header returns the CSV's column names, trimmed and without a byte order mark.
+func (r *CSVRowReader) Header() []string {
+	return slices.Clone(r.header)
+}
 
-		get := func(col string) string {
-			i := index[col]
-			if i < 0 || i >= len(rec) {
-				return ""
-			}
-			return rec[i]
-		}
+// Read returns the next row, or io.EOF after the last one.
+func (r *CSVRowReader) Read() (Row, error) {
+	rec, err := r.cr.Read()
+	if err != nil {
+		return Row{}, err
+	}
 
-		rows = append(rows, Row{
-			Email:            get("email"),
-			LinkedInURL:      get("linkedin_url"),
-			Company:          get("company"),
-			Title:            get("title"),
-			Description:      get("description"),
-			Confidence:       get("confidence"),
-			Status:           get("status"),
-			Error:            get("error"),
-			Model:            get("model"),
-			Sources:          get("sources"),
-			WebSearchQueries: get("web_search_queries"),
-		})
+	var row Row
+	for _, col := range r.schema.Columns {
+		i, ok := r.index[col.Name]
+		if !ok {
+			continue
+		}
+		v := ""
+		if i < len(rec) {
+			v = rec[i]
+		}
+		col.set(&row, v)
 	}
+	return row, nil
 }
@@ -0,0 +1,108 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// WriteJSONL writes rows as newline-delimited JSON, one object per row in the stream record shape
+// (see RowToStreamRecord): empty nullable fields are null, and of the row's Extra values only
+// extraColumns are included, as WriteCSVWithSchema writes them. Keys are written in sorted order,
+// so the same rows always render the same bytes.
+func WriteJSONL(w io.Writer, rows []Row, extraColumns []string) error {
+	return writeJSONL(w, rows, extraColumns, false)
+}
+
+func writeJSONL(w io.Writer, rows []Row, extraColumns []string, omitAudit bool) error {
+	enc := json.NewEncoder(w)
+	for _, row := range rows {
+		rec := RowToStreamRecord(row, extraColumns)
+		if omitAudit {
+			OmitAuditFields(rec)
+		}
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadJSONL reads rows written by WriteJSONL. Fields outside Header() are read back into Row.Extra;
+// null fields read as empty. Blank lines are skipped.
+func ReadJSONL(r io.Reader) ([]Row, error) {
+	jr := NewJSONLRowReader(r)
+	var rows []Row
+	for {
+		row, err := jr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+// JSONLRowReader reads rows from newline-delimited JSON one at a time, as CSVRowReader does for
+// CSV.
+type JSONLRowReader struct {
+	dec    *json.Decoder
+	header []string
+	line   int
+}
+
+// NewJSONLRowReader returns a reader of the JSONL rows in r.
+func NewJSONLRowReader(r io.Reader) *JSONLRowReader {
+	return &JSONLRowReader{dec: json.NewDecoder(bufio.NewReader(r)), header: Header()}
+}
+
+// Read returns the next row, or io.EOF after the last one.
+func (jr *JSONLRowReader) Read() (Row, error) {
+	var rec map[string]any
+	if err := jr.dec.Decode(&rec); err != nil {
+		if err == io.EOF {
+			return Row{}, io.EOF
+		}
+		return Row{}, fmt.Errorf("jsonl record %d: %w", jr.line+1, err)
+	}
+	jr.line++
+	row := RowFromStreamRecord(rec)
+	for k, v := range rec {
+		if s, ok := v.(string); ok && !slices.Contains(jr.header, k) {
+			row = row.WithExtra(k, s)
+		}
+	}
+	return row, nil
+}
+
+// JSONLFileOutput writes rows to a local file with WriteJSONL and ExtraColumns. OmitAuditColumns
+// drops the audit fields (see OmitAuditFields). It implements core.OutputAdapter[Row].
+type JSONLFileOutput struct {
+	Path             string
+	ExtraColumns     []string
+	OmitAuditColumns bool
+}
+
+func (o JSONLFileOutput) Store(_ context.Context, rows []Row) error {
+	f, err := os.Create(o.Path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	w := bufio.NewWriter(f)
+	if err := writeJSONL(w, rows, o.ExtraColumns, o.OmitAuditColumns); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,48 @@
+package pipeline
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// CSVFileOutput writes rows to a local CSV file with Schema's columns (Header() when zero)
+// followed by ExtraColumns. OmitAuditColumns drops the AuditColumns. It implements
+// core.OutputAdapter[Row].
+type CSVFileOutput struct {
+	Path             string
+	Schema           RowSchema
+	ExtraColumns     []string
+	OmitAuditColumns bool
+}
+
+func (o CSVFileOutput) Store(_ context.Context, rows []Row) error {
+	f, err := os.Create(o.Path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	schema := o.Schema
+	if o.OmitAuditColumns {
+		schema = schema.Lean()
+	}
+	if err := WriteCSVWithSchema(f, rows, schema, o.ExtraColumns); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// NDJSONOutput writes rows as newline-delimited JSON, one object per row in the stream record
+// shape (see WriteJSONL) with ExtraColumns. OmitAuditColumns drops the audit fields (see
+// OmitAuditFields). It implements core.OutputAdapter[Row].
+type NDJSONOutput struct {
+	W                io.Writer
+	ExtraColumns     []string
+	OmitAuditColumns bool
+}
+
+func (o NDJSONOutput) Store(_ context.Context, rows []Row) error {
+	return writeJSONL(o.W, rows, o.ExtraColumns, o.OmitAuditColumns)
+}
@@ -5,6 +5,10 @@ import (
 	"context"
 	"encoding/csv"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
 	"slices"
 	"strings"
 	"sync"
@@ -13,6 +17,9 @@ import (
 
 	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/enrich"
 	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	localio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/local"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/redact"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/schema"
 )
 
 type testEnricher struct{}
@@ -21,6 +28,9 @@ func (testEnricher) Enrich(_ context.Context, email string) (enrich.Result, erro
 	if strings.HasSuffix(strings.ToLower(strings.TrimSpace(email)), "@error.test") {
 		return enrich.Result{}, errors.New("test enricher: forced error")
 	}
+	if strings.HasSuffix(strings.ToLower(strings.TrimSpace(email)), "@blocked.test") {
+		return enrich.Result{}, fmt.Errorf("test enricher: %w", &enrich.BlockedError{Reason: "SAFETY"})
+	}
 	domain := ""
 	if at := strings.LastIndex(email, "@"); at >= 0 && at+1 < len(email) {
 		domain = email[at+1:]
@@ -33,12 +43,12 @@ func (testEnricher) Enrich(_ context.Context, email string) (enrich.Result, erro
 }
 
 func TestEnrichEmails(t *testing.T) {
-	rows, err := pipeline.EnrichEmails(context.Background(), []string{" alice@example.com ", "bob@error.test", ""}, testEnricher{}, pipeline.Options{})
+	rows, err := pipeline.EnrichEmails(context.Background(), []string{" alice@example.com ", "bob@error.test", "", "carol@blocked.test"}, testEnricher{}, pipeline.Options{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if len(rows) != 3 {
-		t.Fatalf("expected 3 rows, got %d", len(rows))
+	if len(rows) != 4 {
+		t.Fatalf("expected 4 rows, got %d", len(rows))
 	}
 
 	if rows[0].Email != "alice@example.com" || rows[0].Status != "ok" || rows[0].Company != "example.com" {
@@ -50,6 +60,9 @@ func TestEnrichEmails(t *testing.T) {
 	if rows[2].Status != "error" || rows[2].Error != "empty email" {
 		t.Fatalf("unexpected row[2]: %#v", rows[2])
 	}
+	if rows[3].Status != pipeline.StatusBlocked || !strings.Contains(rows[3].Error, "reason=SAFETY") {
+		t.Fatalf("unexpected row[3]: %#v", rows[3])
+	}
 }
 
 type blockingEnricher struct {
@@ -178,6 +191,36 @@ func TestWriteCSV(t *testing.T) {
 	}
 }
 
+func TestWriteCSVWithColumns(t *testing.T) {
+	var buf bytes.Buffer
+	row := pipeline.Row{Email: "alice@example.com", Status: "ok"}
+	err := pipeline.WriteCSVWithColumns(&buf, []pipeline.Row{
+		row.WithExtra(pipeline.SourceRowColumn, "3"),
+		row,
+	}, []string{pipeline.SourceRowColumn})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(buf.Bytes())).ReadAll()
+	if err != nil {
+		t.Fatalf("parse csv: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected header + 2 rows, got %d records", len(records))
+	}
+	last := len(pipeline.Header())
+	if len(records[0]) != last+1 || records[0][last] != pipeline.SourceRowColumn {
+		t.Fatalf("unexpected header: %#v", records[0])
+	}
+	if records[1][last] != "3" || records[2][last] != "" {
+		t.Fatalf("unexpected source_row values: %q %q", records[1][last], records[2][last])
+	}
+	if row.Extra != nil {
+		t.Fatalf("WithExtra must not mutate the receiver: %#v", row.Extra)
+	}
+}
+
 func TestReadCSV(t *testing.T) {
 	in := strings.Join([]string{
 		strings.Join(pipeline.Header(), ","),
@@ -218,6 +261,24 @@ func TestReadCSV_WithBOMHeader(t *testing.T) {
 	}
 }
 
+func TestReadCSVWithLimits_OversizedFieldFails(t *testing.T) {
+	in := strings.Join([]string{
+		strings.Join(pipeline.Header(), ","),
+		"alice@example.com,,Example,,desc,high,ok,,gemini,,",
+		"bob@corp.test,,Example,," + strings.Repeat("d", 100) + ",high,ok,,gemini,,",
+		"",
+	}, "\n")
+
+	_, err := pipeline.ReadCSVWithLimits(strings.NewReader(in), localio.CSVLimits{MaxFieldBytes: 64})
+	if !errors.Is(err, localio.ErrCSVLimitExceeded) {
+		t.Fatalf("expected field limit error, got %v", err)
+	}
+	rows, err := pipeline.ReadCSVWithLimits(strings.NewReader(in), localio.CSVLimits{MaxFieldBytes: 128})
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("expected 2 rows under a larger limit, got %d rows, err=%v", len(rows), err)
+	}
+}
+
 func TestStreamRecordCodec(t *testing.T) {
 	row := pipeline.Row{
 		Email:       " alice@example.com ",
@@ -231,7 +292,7 @@ func TestStreamRecordCodec(t *testing.T) {
 		Sources:     `["source"]`,
 	}
 
-	rec := pipeline.RowToStreamRecord(row)
+	rec := pipeline.RowToStreamRecord(row, nil)
 	if rec["email"] != row.Email {
 		t.Fatalf("email not preserved: %#v", rec)
 	}
@@ -256,12 +317,63 @@ func TestStreamRecordCodec(t *testing.T) {
 	}
 }
 
+func TestRowToStreamRecordEmitsOnlyListedExtraColumns(t *testing.T) {
+	row := pipeline.Row{Email: "alice@example.com", Status: "ok"}.
+		WithExtra("customer_id", "c-1").
+		WithExtra(pipeline.RawResponseColumn, "{}").
+		WithExtra(pipeline.PromptTokensColumn, "120")
+
+	rec := pipeline.RowToStreamRecord(row, []string{"customer_id"})
+	if rec["customer_id"] != "c-1" {
+		t.Fatalf("expected the listed extra column, got %#v", rec)
+	}
+	for _, col := range []string{pipeline.RawResponseColumn, pipeline.PromptTokensColumn} {
+		if _, ok := rec[col]; ok {
+			t.Fatalf("expected unlisted extra column %s to be omitted, got %#v", col, rec)
+		}
+	}
+}
+
+func TestRowToStreamRecordWithContract(t *testing.T) {
+	row := pipeline.Row{Email: "alice@example.com", Company: "Example", Status: "ok"}
+	contract := &schema.DatasetContract{
+		Mode: schema.DatasetModeStream,
+		Fields: []schema.Field{
+			{Name: "email", Type: "string"},
+			{Name: "company", Type: "string", Nullable: true},
+			{Name: "title", Type: "string"},
+			{Name: "error", Type: "string", Nullable: true},
+			{Name: "completeness", Type: "DOUBLE"},
+			{Name: "confidence", Type: "TIMESTAMP"},
+		},
+	}
+
+	rec := pipeline.RowToStreamRecordWithContract(row, nil, contract)
+	for key, want := range map[string]any{
+		"email":        "alice@example.com",
+		"company":      "Example",
+		"title":        "",  // non-nullable and empty
+		"completeness": 0,   // non-nullable numeric and empty
+		"confidence":   nil, // non-nullable, but its type has no zero value
+		"error":        nil, // nullable and empty
+		"model":        nil, // not in the contract
+	} {
+		if got, ok := rec[key]; !ok || got != want {
+			t.Fatalf("%s: got %#v (present=%t), want %#v", key, got, ok, want)
+		}
+	}
+
+	if got := pipeline.RowToStreamRecordWithContract(row, nil, nil)["title"]; got != nil {
+		t.Fatalf("without a contract empty title should encode as nil, got %#v", got)
+	}
+}
+
 func TestWriteStreamRecordsCSV(t *testing.T) {
 	rec := pipeline.RowToStreamRecord(pipeline.Row{
 		Email:   "alice@example.com",
 		Company: "Example",
 		Status:  "ok",
-	})
+	}, nil)
 	rec["run_id"] = "run-123"
 	rec["written_at"] = "2026-04-23T00:00:00Z"
 
@@ -296,3 +408,415 @@ func TestWriteStreamRecordsCSV(t *testing.T) {
 		t.Fatalf("unexpected stream csv row: %#v", records[1])
 	}
 }
+
+type profileEnricher struct{}
+
+func (profileEnricher) Enrich(_ context.Context, _ string) (enrich.Result, error) {
+	return enrich.Result{
+		LinkedInURL: "LinkedIn.com/in/alice-example/?trk=public_profile#about",
+		Company:     "Example",
+		Description: "Builds data pipelines at Example.",
+		Confidence:  "high",
+	}, nil
+}
+
+func TestEnrichEmails_PostProcessorsApplied(t *testing.T) {
+	post, err := pipeline.ParsePostProcessors([]string{"canonical-url", "clamp-description=6"})
+	if err != nil {
+		t.Fatalf("ParsePostProcessors: %v", err)
+	}
+	rows, err := pipeline.EnrichEmails(context.Background(), []string{"alice@example.com"}, profileEnricher{}, pipeline.Options{PostProcessors: post})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := rows[0].LinkedInURL, "https://www.linkedin.com/in/alice-example"; got != want {
+		t.Fatalf("linkedin url: want %q, got %q", want, got)
+	}
+	if got, want := rows[0].Description, "Builds"; got != want {
+		t.Fatalf("description: want %q, got %q", want, got)
+	}
+
+	rows, err = pipeline.EnrichEmails(context.Background(), []string{"alice@example.com"}, profileEnricher{}, pipeline.Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rows[0].LinkedInURL != "LinkedIn.com/in/alice-example/?trk=public_profile#about" || rows[0].Description != "Builds data pipelines at Example." {
+		t.Fatalf("expected identity without post-processors, got %#v", rows[0])
+	}
+}
+
+func TestCanonicalizeLinkedInURL(t *testing.T) {
+	cases := map[string]string{
+		"https://uk.linkedin.com/in/bob/":   "https://www.linkedin.com/in/bob",
+		"http://www.linkedin.com/company/x": "https://www.linkedin.com/company/x",
+		"https://example.com/in/bob/":       "https://example.com/in/bob/",
+		"":                                  "",
+	}
+	for in, want := range cases {
+		if got := pipeline.CanonicalizeLinkedInURL(enrich.Result{LinkedInURL: in}).LinkedInURL; got != want {
+			t.Errorf("CanonicalizeLinkedInURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParsePostProcessors_RejectsUnknown(t *testing.T) {
+	for _, spec := range []string{"lowercase", "clamp-description", "clamp-description=0", "canonical-url=1"} {
+		if _, err := pipeline.ParsePostProcessors([]string{spec}); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+}
+
+type usageEnricher struct{}
+
+func (usageEnricher) Enrich(_ context.Context, email string) (enrich.Result, error) {
+	if strings.HasPrefix(email, "nousage") {
+		return enrich.Result{Company: "example.com"}, nil
+	}
+	return enrich.Result{Company: "example.com", Usage: &enrich.Usage{PromptTokens: 120, ResponseTokens: 30}}, nil
+}
+
+func TestEnrichEmails_CaptureUsage(t *testing.T) {
+	emails := []string{"alice@example.com", "nousage@example.com"}
+	rows, err := pipeline.EnrichEmails(context.Background(), emails, usageEnricher{}, pipeline.Options{CaptureUsage: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := rows[0].Extra; got[pipeline.PromptTokensColumn] != "120" || got[pipeline.ResponseTokensColumn] != "30" {
+		t.Fatalf("expected usage columns, got %#v", got)
+	}
+	if _, ok := rows[1].Extra[pipeline.PromptTokensColumn]; ok {
+		t.Fatalf("expected no usage columns without reported usage, got %#v", rows[1].Extra)
+	}
+
+	rows, err = pipeline.EnrichEmails(context.Background(), emails, usageEnricher{}, pipeline.Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rows[0].Extra != nil {
+		t.Fatalf("expected no usage columns without CaptureUsage, got %#v", rows[0].Extra)
+	}
+}
+
+type candidateEnricher struct{}
+
+func (candidateEnricher) Enrich(_ context.Context, email string) (enrich.Result, error) {
+	if strings.HasPrefix(email, "fail") {
+		return enrich.Result{}, errors.New("boom")
+	}
+	return enrich.Result{Company: "example.com", Candidate: 2}, nil
+}
+
+func TestEnrichEmails_CaptureCandidate(t *testing.T) {
+	emails := []string{"alice@example.com", "fail@example.com"}
+	rows, err := pipeline.EnrichEmails(context.Background(), emails, candidateEnricher{}, pipeline.Options{CaptureCandidate: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := rows[0].Extra[pipeline.CandidateColumn]; got != "2" {
+		t.Fatalf("expected the chosen candidate index, got %#v", rows[0].Extra)
+	}
+	if _, ok := rows[1].Extra[pipeline.CandidateColumn]; ok {
+		t.Fatalf("expected no candidate on a failed row, got %#v", rows[1].Extra)
+	}
+
+	rows, err = pipeline.EnrichEmails(context.Background(), emails[:1], candidateEnricher{}, pipeline.Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rows[0].Extra != nil {
+		t.Fatalf("expected no candidate column without CaptureCandidate, got %#v", rows[0].Extra)
+	}
+}
+
+type completenessEnricher struct{}
+
+func (completenessEnricher) Enrich(_ context.Context, email string) (enrich.Result, error) {
+	switch {
+	case strings.HasPrefix(email, "full"):
+		return enrich.Result{LinkedInURL: "https://www.linkedin.com/in/full", Company: "Example", Title: "CTO", Description: "desc"}, nil
+	case strings.HasPrefix(email, "half"):
+		return enrich.Result{Company: "Example", Title: " CTO "}, nil
+	case strings.HasPrefix(email, "error"):
+		return enrich.Result{}, errors.New("forced error")
+	default:
+		return enrich.Result{Confidence: "low"}, nil
+	}
+}
+
+func TestEnrichEmails_Completeness(t *testing.T) {
+	emails := []string{"full@example.com", "half@example.com", "empty@example.com", "error@example.com"}
+	cases := []struct {
+		name            string
+		minCompleteness float64
+		wantStatus      []string
+	}{
+		{name: "no threshold", wantStatus: []string{"ok", "ok", "ok", "error"}},
+		{name: "threshold", minCompleteness: 0.75, wantStatus: []string{"ok", pipeline.StatusPartial, pipeline.StatusPartial, "error"}},
+		{name: "threshold met exactly", minCompleteness: 0.5, wantStatus: []string{"ok", "ok", pipeline.StatusPartial, "error"}},
+	}
+	wantCompleteness := []string{"1.00", "0.50", "0.00", ""}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rows, err := pipeline.EnrichEmails(context.Background(), emails, completenessEnricher{}, pipeline.Options{MinCompleteness: tc.minCompleteness})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for i, row := range rows {
+				if row.Completeness != wantCompleteness[i] || row.Status != tc.wantStatus[i] {
+					t.Fatalf("row[%d] %s: want completeness=%q status=%q, got completeness=%q status=%q",
+						i, row.Email, wantCompleteness[i], tc.wantStatus[i], row.Completeness, row.Status)
+				}
+			}
+		})
+	}
+}
+
+func TestEnrichEmails_PartialAttemptsContinuePriorCount(t *testing.T) {
+	rows, err := pipeline.EnrichEmails(context.Background(), []string{"half@example.com", "full@example.com"}, completenessEnricher{}, pipeline.Options{
+		MinCompleteness:      0.75,
+		PriorPartialAttempts: func(string) int { return 2 },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := rows[0].Extra[pipeline.PartialAttemptsColumn]; rows[0].Status != pipeline.StatusPartial || got != "3" {
+		t.Fatalf("expected a partial row on its third attempt, got status=%q attempts=%q", rows[0].Status, got)
+	}
+	if _, ok := rows[1].Extra[pipeline.PartialAttemptsColumn]; ok {
+		t.Fatalf("expected no attempt count on an ok row, got %#v", rows[1].Extra)
+	}
+}
+
+func TestReadCSV_WithoutCompletenessColumn(t *testing.T) {
+	header := pipeline.Header()
+	// Outputs written before completeness and skip_reason were added end at web_search_queries.
+	in := strings.Join(header[:len(header)-2], ",") + "\n" +
+		"alice@example.com,,Example,,,high,ok,,gemini,,\n"
+
+	rows, err := pipeline.ReadCSV(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Status != "ok" || rows[0].Completeness != "" {
+		t.Fatalf("unexpected rows: %#v", rows)
+	}
+}
+
+func TestSkippedRow_RoundTripsSkipReason(t *testing.T) {
+	row := pipeline.SkippedRow(" bob@gmail.com ", pipeline.SkipReasonDomain)
+	if row.Email != "bob@gmail.com" || row.Status != pipeline.StatusSkipped || row.SkipReason != pipeline.SkipReasonDomain {
+		t.Fatalf("unexpected skipped row: %#v", row)
+	}
+
+	var buf bytes.Buffer
+	if err := pipeline.WriteCSV(&buf, []pipeline.Row{row, {Email: "alice@example.com", Status: "ok"}}); err != nil {
+		t.Fatalf("write csv: %v", err)
+	}
+	rows, err := pipeline.ReadCSV(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if len(rows) != 2 || rows[0].SkipReason != pipeline.SkipReasonDomain || rows[1].SkipReason != "" {
+		t.Fatalf("skip_reason not round-tripped through csv: %#v", rows)
+	}
+
+	rec := pipeline.RowToStreamRecord(row, nil)
+	if rec[pipeline.SkipReasonColumn] != "domain" {
+		t.Fatalf("skip_reason not encoded in stream record: %#v", rec)
+	}
+	if got := pipeline.RowFromStreamRecord(rec); got.SkipReason != pipeline.SkipReasonDomain {
+		t.Fatalf("skip_reason not decoded from stream record: %#v", got)
+	}
+	if rec := pipeline.RowToStreamRecord(rows[1], nil); rec[pipeline.SkipReasonColumn] != nil {
+		t.Fatalf("empty skip_reason should encode as nil: %#v", rec)
+	}
+}
+
+func TestWriteLeanCSVWithColumns_RoundTrip(t *testing.T) {
+	rows := []pipeline.Row{{
+		Email:            "alice@example.com",
+		Company:          "Example",
+		Status:           "ok",
+		Model:            "gemini",
+		Sources:          `["https://example.com"]`,
+		WebSearchQueries: `["alice"]`,
+		Completeness:     "0.25",
+		Extra:            map[string]string{"source_row": "1"},
+	}}
+	var buf bytes.Buffer
+	if err := pipeline.WriteLeanCSVWithColumns(&buf, rows, []string{"source_row"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	header := strings.SplitN(buf.String(), "\n", 2)[0]
+	want := strings.Join(append(pipeline.LeanHeader(), "source_row"), ",")
+	if header != want {
+		t.Fatalf("unexpected header:\nwant=%s\ngot=%s", want, header)
+	}
+	for _, col := range pipeline.AuditColumns() {
+		if strings.Contains(header, col) {
+			t.Fatalf("expected lean header without %q, got %s", col, header)
+		}
+	}
+
+	got, err := pipeline.ReadCSV(&buf)
+	if err != nil {
+		t.Fatalf("read lean csv: %v", err)
+	}
+	wantRow := pipeline.Row{Email: "alice@example.com", Company: "Example", Status: "ok", Completeness: "0.25"}
+	if len(got) != 1 || !reflect.DeepEqual(got[0], wantRow) {
+		t.Fatalf("unexpected rows: %#v", got)
+	}
+}
+
+func TestOmitAuditFields(t *testing.T) {
+	rec := pipeline.OmitAuditFields(pipeline.RowToStreamRecord(pipeline.Row{Email: "a@x.test", Status: "ok", Model: "gemini"}, nil))
+	for _, col := range pipeline.AuditColumns() {
+		if _, ok := rec[col]; ok {
+			t.Fatalf("expected %q omitted, got %#v", col, rec)
+		}
+	}
+	if rec["status"] != "ok" {
+		t.Fatalf("expected data fields kept, got %#v", rec)
+	}
+}
+
+func TestNewAuditRecord_HashesEmailAndKeepsReturnedFields(t *testing.T) {
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	rec := pipeline.NewAuditRecord(pipeline.Row{
+		Email:   " Alice@Example.com ",
+		Company: "Example",
+		Status:  "ok",
+		Model:   "gemini",
+		Sources: `["https://example.com"]`,
+	}, "run-1", at, []byte("key-1"))
+
+	if rec.EmailHMAC != redact.HashEmail([]byte("key-1"), "alice@example.com") || len(rec.EmailHMAC) != 64 {
+		t.Fatalf("expected the normalized email hash, got %q", rec.EmailHMAC)
+	}
+	if other := pipeline.NewAuditRecord(pipeline.Row{Email: "alice@example.com"}, "", at, []byte("key-2")); other.EmailHMAC == rec.EmailHMAC {
+		t.Fatalf("expected the hash to depend on the key, got %q for both", rec.EmailHMAC)
+	}
+	want := map[string]string{"company": "Example"}
+	if !reflect.DeepEqual(rec.Fields, want) {
+		t.Fatalf("expected only non-empty returned fields %v, got %v", want, rec.Fields)
+	}
+	if rec.RunID != "run-1" || !rec.Timestamp.Equal(at) || rec.Model != "gemini" {
+		t.Fatalf("unexpected record: %#v", rec)
+	}
+}
+
+func TestFileAuditSink_AppendsAcrossOpens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.ndjson")
+	for _, email := range []string{"a@x.test", "b@x.test"} {
+		sink, err := pipeline.OpenFileAuditSink(path)
+		if err != nil {
+			t.Fatalf("OpenFileAuditSink: %v", err)
+		}
+		if err := sink.Record(context.Background(), pipeline.NewAuditRecord(pipeline.Row{Email: email, Status: "ok"}, "", time.Now(), []byte("key"))); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], redact.HashEmail([]byte("key"), "a@x.test")) || !strings.Contains(lines[1], redact.HashEmail([]byte("key"), "b@x.test")) {
+		t.Fatalf("expected both records appended in order, got:\n%s", b)
+	}
+}
+
+func TestRowSchema_CustomColumnsRoundTrip(t *testing.T) {
+	schema := pipeline.DefaultRowSchema().WithColumns(
+		pipeline.ColumnSpec{Name: "phone"},
+		pipeline.ColumnSpec{Name: "country", Optional: true},
+	)
+	rows := []pipeline.Row{{
+		Email:  "alice@example.com",
+		Status: "ok",
+		Extra:  map[string]string{"phone": "+1 555 0100", "country": "US"},
+	}}
+	var buf bytes.Buffer
+	if err := pipeline.WriteCSVWithSchema(&buf, rows, schema, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	header := strings.SplitN(buf.String(), "\n", 2)[0]
+	if want := strings.Join(append(pipeline.Header(), "phone", "country"), ","); header != want {
+		t.Fatalf("unexpected header:\nwant=%s\ngot=%s", want, header)
+	}
+
+	cr, err := pipeline.NewCSVRowReaderWithSchema(&buf, localio.CSVLimits{}, schema)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := cr.Read()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, rows[0]) {
+		t.Fatalf("unexpected row:\nwant=%#v\ngot=%#v", rows[0], got)
+	}
+
+	// The default reader ignores the custom columns; a required custom column must be present.
+	if _, err := pipeline.NewCSVRowReaderWithSchema(strings.NewReader(strings.Join(pipeline.Header(), ",")+"\n"), localio.CSVLimits{}, schema); err == nil || !strings.Contains(err.Error(), `"phone"`) {
+		t.Fatalf("expected missing phone column error, got %v", err)
+	}
+}
+
+func TestRowSchema_ZeroValueIsDefault(t *testing.T) {
+	var buf, want bytes.Buffer
+	rows := []pipeline.Row{{Email: "alice@example.com", Status: "ok", Completeness: "0.5", SkipReason: pipeline.SkipReason("excluded")}}
+	if err := pipeline.WriteCSVWithSchema(&buf, rows, pipeline.RowSchema{}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipeline.WriteCSV(&want, rows); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf.String() != want.String() {
+		t.Fatalf("unexpected csv:\nwant=%s\ngot=%s", want.String(), buf.String())
+	}
+	if !slices.Equal(pipeline.RowSchema{}.Header(), pipeline.Header()) {
+		t.Fatalf("unexpected zero schema header: %v", pipeline.RowSchema{}.Header())
+	}
+}
+
+func TestWriteJSONL_RoundTrip(t *testing.T) {
+	rows := []pipeline.Row{
+		{Email: "alice@example.com", Company: "Acme", Status: "ok", Model: "m"},
+		pipeline.Row{Email: "bob@corp.test", Status: "error", Error: "boom"}.WithExtra("customer_id", "c-2").WithExtra("raw_response", "{}"),
+	}
+	var buf bytes.Buffer
+	if err := pipeline.WriteJSONL(&buf, rows, []string{"customer_id"}); err != nil {
+		t.Fatalf("WriteJSONL: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %q", len(lines), buf.String())
+	}
+	// Empty nullable fields are null, and keys are sorted.
+	if !strings.Contains(lines[0], `"company":"Acme","completeness":null,"confidence":null,"description":null,"email":"alice@example.com"`) {
+		t.Fatalf("unexpected first line: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], `"customer_id":"c-2"`) || strings.Contains(lines[1], "raw_response") {
+		t.Fatalf("expected only the configured extra column in second line: %s", lines[1])
+	}
+
+	got, err := pipeline.ReadJSONL(strings.NewReader(buf.String() + "\n"))
+	if err != nil {
+		t.Fatalf("ReadJSONL: %v", err)
+	}
+	want := []pipeline.Row{rows[0], pipeline.Row{Email: "bob@corp.test", Status: "error", Error: "boom"}.WithExtra("customer_id", "c-2")}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("round trip mismatch:\nwant=%#v\ngot=%#v", want, got)
+	}
+
+	if _, err := pipeline.ReadJSONL(strings.NewReader("{\"email\":\"a@b.c\"}\nnot json\n")); err == nil || !strings.Contains(err.Error(), "jsonl record 2") {
+		t.Fatalf("expected record 2 parse error, got %v", err)
+	}
+}
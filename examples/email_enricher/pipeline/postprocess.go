@@ -0,0 +1,83 @@
+package pipeline
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/enrich"
+)
+
+// ResultPostProcessor transforms a successful enrichment result before it becomes an output row.
+type ResultPostProcessor func(enrich.Result) enrich.Result
+
+// ChainPostProcessors applies processors in order. An empty chain is the identity.
+func ChainPostProcessors(processors ...ResultPostProcessor) ResultPostProcessor {
+	return func(r enrich.Result) enrich.Result {
+		for _, p := range processors {
+			if p != nil {
+				r = p(r)
+			}
+		}
+		return r
+	}
+}
+
+// CanonicalizeLinkedInURL rewrites LinkedInURL to https://www.linkedin.com/<path>, dropping the
+// query, fragment and trailing slash. Values that are not LinkedIn URLs are left unchanged.
+func CanonicalizeLinkedInURL(r enrich.Result) enrich.Result {
+	raw := strings.TrimSpace(r.LinkedInURL)
+	if raw == "" {
+		return r
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return r
+	}
+	host := strings.ToLower(u.Hostname())
+	if host != "linkedin.com" && !strings.HasSuffix(host, ".linkedin.com") {
+		return r
+	}
+	path := strings.TrimRight(u.EscapedPath(), "/")
+	r.LinkedInURL = "https://www.linkedin.com" + path
+	return r
+}
+
+// ClampDescription returns a post-processor that truncates Description to at most n runes.
+func ClampDescription(n int) ResultPostProcessor {
+	return func(r enrich.Result) enrich.Result {
+		runes := []rune(r.Description)
+		if len(runes) > n {
+			r.Description = strings.TrimSpace(string(runes[:n]))
+		}
+		return r
+	}
+}
+
+// ParsePostProcessors resolves --post-process names into processors, in order:
+//
+//   - canonical-url: CanonicalizeLinkedInURL
+//   - clamp-description=N: ClampDescription(N)
+func ParsePostProcessors(names []string) ([]ResultPostProcessor, error) {
+	var out []ResultPostProcessor
+	for _, name := range names {
+		key, arg, hasArg := strings.Cut(strings.TrimSpace(name), "=")
+		switch {
+		case key == "canonical-url" && !hasArg:
+			out = append(out, CanonicalizeLinkedInURL)
+		case key == "clamp-description" && hasArg:
+			n, err := strconv.Atoi(strings.TrimSpace(arg))
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid post-process %q (expected clamp-description=N with N > 0)", name)
+			}
+			out = append(out, ClampDescription(n))
+		default:
+			return nil, fmt.Errorf("invalid post-process %q (expected canonical-url|clamp-description=N)", name)
+		}
+	}
+	return out, nil
+}
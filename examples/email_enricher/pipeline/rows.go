@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"strconv"
 	"strings"
 	"time"
 
@@ -26,50 +27,190 @@ type Row struct {
 	Model            string
 	Sources          string
 	WebSearchQueries string
+
+	// Completeness is the fraction of enrichment fields (see CompletenessFields) that are non-empty,
+	// formatted with two decimals. It is empty for rows that were not enriched successfully.
+	Completeness string
+
+	// SkipReason says why a StatusSkipped row was not enriched; it is empty for other rows.
+	SkipReason SkipReason
+
+	// Extra holds optional columns written after Header() by WriteCSVWithColumns and included in
+	// stream records (for example SourceRowColumn). It is nil unless a feature opts in.
+	Extra map[string]string
+}
+
+// SourceRowColumn is the optional output column carrying the 0-based index of the input row an
+// email was read from when multiple email columns are fanned out.
+const SourceRowColumn = "source_row"
+
+// RawResponseColumn is the optional output column carrying the enricher's raw response text.
+const RawResponseColumn = "raw_response"
+
+// PromptTokensColumn and ResponseTokensColumn are the optional output columns carrying the
+// enricher's reported token usage (see Options.CaptureUsage). They are empty for rows without usage.
+const (
+	PromptTokensColumn   = "prompt_tokens"
+	ResponseTokensColumn = "response_tokens"
+)
+
+// CandidateColumn is the optional output column carrying the index of the response candidate an
+// ok row's fields were taken from (enrich.Result.Candidate; see Options.CaptureCandidate).
+const CandidateColumn = "candidate"
+
+// WrittenAtColumn is the optional output column carrying the RFC 3339 time a row was enriched (see
+// Options.ReenrichAfter). Stream records carry the same field as run metadata (StreamMeta).
+const WrittenAtColumn = "written_at"
+
+// PartialAttemptsColumn is the optional output column counting the runs in a row that enriched an
+// email to StatusPartial (see Options.MaxPartialAttempts).
+const PartialAttemptsColumn = "partial_attempts"
+
+// DefaultMaxPartialAttempts is the Options.MaxPartialAttempts default.
+const DefaultMaxPartialAttempts = 3
+
+// WithExtra returns a copy of r with an extra column set. The Extra map is copied so rows that
+// share a cached value can be tagged independently.
+func (r Row) WithExtra(column, value string) Row {
+	extra := make(map[string]string, len(r.Extra)+1)
+	for k, v := range r.Extra {
+		extra[k] = v
+	}
+	extra[column] = value
+	r.Extra = extra
+	return r
 }
 
 type Options struct {
 	Workers        int
 	MaxRetries     int
 	RequestTimeout time.Duration
-	RateLimitRPS   float64
-	FailFast       bool
+	// RequestTimeoutFactor is passed to worker.Options.RequestTimeoutFactor: each retry's timeout is
+	// the previous one times the factor. Zero keeps RequestTimeout constant.
+	RequestTimeoutFactor float64
+	RateLimitRPS         float64
+	FailFast             bool
+	// FailFastKeepPartial fails fast like FailFast, but lets in-flight emails finish and returns
+	// the rows enriched so far (in input order) alongside the error instead of discarding them.
+	FailFastKeepPartial bool
+
+	// RampInterval, when positive, starts workers one at a time, one per interval, up to Workers.
+	RampInterval time.Duration
+
+	// OnRetry is passed to worker.Options.OnRetry: it is called with the backoff chosen before each
+	// retry of a transient failure.
+	OnRetry func(attempt int, delay time.Duration, err error)
+
+	// RetryBackoffInitial and RetryBackoffMax are passed to worker.Options.BackoffInitial and
+	// BackoffMax. Zero uses the worker defaults (200ms and 2s).
+	RetryBackoffInitial time.Duration
+	RetryBackoffMax     time.Duration
+
+	// Canceler is passed to worker.Options.Canceler so a single in-flight email can be canceled by
+	// its key (the email as given); a canceled email becomes an error row.
+	Canceler *worker.Canceler
+
+	// PostProcessors transform each successful result, in order, before it becomes a row.
+	// Nil keeps results unchanged.
+	PostProcessors []ResultPostProcessor
+
+	// CaptureUsage sets PromptTokensColumn and ResponseTokensColumn on rows whose result reports usage.
+	CaptureUsage bool
+
+	// CaptureCandidate sets CandidateColumn on successful rows, recording which response candidate
+	// the backend chose when it requested several.
+	CaptureCandidate bool
+
+	// MinCompleteness, when positive, downgrades successful rows whose completeness is below it to
+	// StatusPartial. Partial rows are not ok, so incremental runs enrich them again, up to
+	// MaxPartialAttempts times.
+	MinCompleteness float64
+
+	// MaxPartialAttempts bounds the runs that enrich an email which keeps coming back partial: each
+	// partial row counts its attempts in PartialAttemptsColumn, and incremental runs keep a cached
+	// partial row once the count reaches the limit. Zero uses DefaultMaxPartialAttempts; negative
+	// enriches partial rows again on every run.
+	MaxPartialAttempts int
+
+	// PriorPartialAttempts, when set, returns the PartialAttemptsColumn count of the cached row for
+	// email (0 when there is none), so a partial row's count continues from the previous run's.
+	PriorPartialAttempts func(email string) int
+
+	// InputRecord, when set, returns the input row fields passed with email to an
+	// enrich.RecordEnricher (see enrich.EnrichRecord); nil or an empty record uses Enrich.
+	InputRecord func(email string) map[string]string
+
+	// Schema is the output column set written to CSV outputs and read back from prior outputs. The
+	// zero value is DefaultRowSchema.
+	Schema RowSchema
+
+	// ReenrichAfter, when set, stamps each enriched row's WrittenAtColumn, and incremental runs
+	// enrich a cached ok row again once its written_at is at least ReenrichAfter old (or missing), so
+	// zero re-enriches every ok row. Nil keeps ok rows cached indefinitely.
+	ReenrichAfter *time.Duration
 }
 
-// Header returns the stable CSV header for Row.
+// StatusPartial marks a successful result with too few enrichment fields (see Options.MinCompleteness).
+const StatusPartial = "partial"
+
+// StatusBlocked marks an email the provider declined to answer (see enrich.BlockedError).
+const StatusBlocked = "blocked"
+
+// CompletenessFields names the enrichment fields counted by Completeness (enrich.FieldNames).
+func CompletenessFields() []string {
+	return enrich.FieldNames()
+}
+
+// completeness returns the fraction of CompletenessFields that r fills.
+func completeness(r enrich.Result) float64 {
+	return float64(r.FilledFields()) / float64(len(CompletenessFields()))
+}
+
+// FilledFields returns how many of r's CompletenessFields are non-empty.
+func (r Row) FilledFields() int {
+	return enrich.Result{LinkedInURL: r.LinkedInURL, Company: r.Company, Title: r.Title, Description: r.Description}.FilledFields()
+}
+
+// Header returns the stable CSV header for Row: DefaultRowSchema's columns.
 func Header() []string {
-	return []string{
-		"email",
-		"linkedin_url",
-		"company",
-		"title",
-		"description",
-		"confidence",
-		"status",
-		"error",
-		"model",
-		"sources",
-		"web_search_queries",
-	}
+	return DefaultRowSchema().Header()
+}
+
+// AuditColumns returns the Header() columns that record how a row was enriched. Outputs written
+// without audit capture can omit them; see LeanHeader.
+func AuditColumns() []string {
+	return []string{"model", "sources", "web_search_queries"}
+}
+
+// LeanHeader returns Header() without AuditColumns, in Header() order.
+func LeanHeader() []string {
+	return DefaultRowSchema().Lean().Header()
 }
 
 // EnrichEmails runs the enricher over all emails and returns stable output rows.
 //
-// Errors from enrichment are recorded per-row and do not fail the full run.
+// Errors from enrichment are recorded per-row and do not fail the full run. With
+// Options.FailFastKeepPartial, a failed run also returns the rows that completed.
 func EnrichEmails(ctx context.Context, emails []string, enricher enrich.Enricher, opts Options) ([]Row, error) {
 	workerOpts := workerOptions(opts)
-	processor := emailProcessor(enricher)
+	processor := emailProcessor(enricher, opts.InputRecord)
+	post := ChainPostProcessors(opts.PostProcessors...)
 
 	out, err := worker.ProcessAll(ctx, emails, processor, workerOpts)
-	if err != nil {
+	return workerRows(out, err, post, opts)
+}
+
+// workerRows converts worker results to rows. Results returned alongside an error (see
+// worker.FailurePolicyFailFastKeepPartial) are kept with the error.
+func workerRows(out []worker.Result[string, enrich.Result], err error, post ResultPostProcessor, opts Options) ([]Row, error) {
+	if err != nil && out == nil {
 		return nil, err
 	}
-
 	rows := make([]Row, 0, len(out))
 	for _, item := range out {
-		rows = append(rows, rowFromWorkerResult(item))
+		rows = append(rows, rowFromWorkerResult(item, post, opts))
 	}
-	return rows, nil
+	return rows, err
 }
 
 // EnrichEmailsStream runs enrichment and calls onRow as each item completes.
@@ -83,13 +224,14 @@ func EnrichEmailsStream(
 	onRow func(Row) error,
 ) error {
 	workerOpts := workerOptions(opts)
-	processor := emailProcessor(enricher)
+	processor := emailProcessor(enricher, opts.InputRecord)
+	post := ChainPostProcessors(opts.PostProcessors...)
 
 	_, err := worker.ProcessAllWithCallback(ctx, emails, processor, func(item worker.Result[string, enrich.Result]) error {
 		if onRow == nil {
 			return nil
 		}
-		return onRow(rowFromWorkerResult(item))
+		return onRow(rowFromWorkerResult(item, post, opts))
 	}, workerOpts)
 	if err != nil {
 		return err
@@ -97,40 +239,77 @@ func EnrichEmailsStream(
 	return nil
 }
 
+// EnrichEmailsWithCallback runs enrichment, calls onRow as each item completes, and returns all rows
+// in input order once the run finishes. Like EnrichEmails, it returns the completed rows with the
+// error under Options.FailFastKeepPartial.
+func EnrichEmailsWithCallback(
+	ctx context.Context,
+	emails []string,
+	enricher enrich.Enricher,
+	opts Options,
+	onRow func(Row) error,
+) ([]Row, error) {
+	workerOpts := workerOptions(opts)
+	processor := emailProcessor(enricher, opts.InputRecord)
+	post := ChainPostProcessors(opts.PostProcessors...)
+
+	out, err := worker.ProcessAllWithCallback(ctx, emails, processor, func(item worker.Result[string, enrich.Result]) error {
+		if onRow == nil {
+			return nil
+		}
+		return onRow(rowFromWorkerResult(item, post, opts))
+	}, workerOpts)
+	return workerRows(out, err, post, opts)
+}
+
 func workerOptions(opts Options) worker.Options {
 	policy := worker.FailurePolicyPartialOutput
 	if opts.FailFast {
 		policy = worker.FailurePolicyFailFast
 	}
+	if opts.FailFastKeepPartial {
+		policy = worker.FailurePolicyFailFastKeepPartial
+	}
 
 	return worker.Options{
-		Workers:           opts.Workers,
-		MaxRetries:        opts.MaxRetries,
-		RequestTimeout:    opts.RequestTimeout,
-		RateLimitRPS:      opts.RateLimitRPS,
-		FailurePolicy:     policy,
-		BackoffInitial:    200 * time.Millisecond,
-		BackoffMax:        2 * time.Second,
-		BackoffJitterFrac: 0.2,
+		Workers:              opts.Workers,
+		MaxRetries:           opts.MaxRetries,
+		RequestTimeout:       opts.RequestTimeout,
+		RequestTimeoutFactor: opts.RequestTimeoutFactor,
+		RateLimitRPS:         opts.RateLimitRPS,
+		FailurePolicy:        policy,
+		BackoffInitial:       opts.RetryBackoffInitial,
+		BackoffMax:           opts.RetryBackoffMax,
+		BackoffJitterFrac:    0.2,
+		RampInterval:         opts.RampInterval,
+		OnRetry:              opts.OnRetry,
+		Canceler:             opts.Canceler,
 	}
 }
 
-func emailProcessor(enricher enrich.Enricher) func(context.Context, string) (enrich.Result, error) {
+func emailProcessor(enricher enrich.Enricher, inputRecord func(string) map[string]string) func(context.Context, string) (enrich.Result, error) {
 	return func(reqCtx context.Context, raw string) (enrich.Result, error) {
 		email := strings.TrimSpace(raw)
 		if email == "" {
 			return enrich.Result{}, errors.New("empty email")
 		}
-		return enricher.Enrich(reqCtx, email)
+		if inputRecord == nil {
+			return enricher.Enrich(reqCtx, email)
+		}
+		return enrich.EnrichRecord(reqCtx, enricher, email, inputRecord(email))
 	}
 }
 
-func rowFromWorkerResult(item worker.Result[string, enrich.Result]) Row {
+func rowFromWorkerResult(item worker.Result[string, enrich.Result], post ResultPostProcessor, opts Options) Row {
+	if item.Err == nil {
+		item.Output = post(item.Output)
+	}
 	sources := jsonArrayOrEmpty(item.Output.Sources)
 	queries := jsonArrayOrEmpty(item.Output.WebSearchQueries)
 
+	var row Row
 	if item.Err != nil {
-		return Row{
+		row = Row{
 			Email:            strings.TrimSpace(item.Input),
 			Status:           "error",
 			Error:            redact.Secrets(item.Err.Error()),
@@ -138,21 +317,49 @@ func rowFromWorkerResult(item worker.Result[string, enrich.Result]) Row {
 			Sources:          sources,
 			WebSearchQueries: queries,
 		}
+	} else {
+		row = Row{
+			Email:            strings.TrimSpace(item.Input),
+			LinkedInURL:      item.Output.LinkedInURL,
+			Company:          item.Output.Company,
+			Title:            item.Output.Title,
+			Description:      item.Output.Description,
+			Confidence:       item.Output.Confidence,
+			Status:           "ok",
+			Error:            "",
+			Model:            item.Output.Model,
+			Sources:          sources,
+			WebSearchQueries: queries,
+		}
+		c := completeness(item.Output)
+		row.Completeness = strconv.FormatFloat(c, 'f', 2, 64)
+		if opts.MinCompleteness > 0 && c < opts.MinCompleteness {
+			row.Status = StatusPartial
+			attempts := 1
+			if opts.PriorPartialAttempts != nil {
+				attempts += opts.PriorPartialAttempts(row.Email)
+			}
+			row = row.WithExtra(PartialAttemptsColumn, strconv.Itoa(attempts))
+		}
 	}
-
-	return Row{
-		Email:            strings.TrimSpace(item.Input),
-		LinkedInURL:      item.Output.LinkedInURL,
-		Company:          item.Output.Company,
-		Title:            item.Output.Title,
-		Description:      item.Output.Description,
-		Confidence:       item.Output.Confidence,
-		Status:           "ok",
-		Error:            "",
-		Model:            item.Output.Model,
-		Sources:          sources,
-		WebSearchQueries: queries,
+	var blocked *enrich.BlockedError
+	if errors.As(item.Err, &blocked) {
+		row.Status = StatusBlocked
+	}
+	if item.Output.RawResponse != "" {
+		row = row.WithExtra(RawResponseColumn, item.Output.RawResponse)
+	}
+	if u := item.Output.Usage; u != nil && opts.CaptureUsage {
+		row = row.WithExtra(PromptTokensColumn, strconv.Itoa(u.PromptTokens))
+		row = row.WithExtra(ResponseTokensColumn, strconv.Itoa(u.ResponseTokens))
+	}
+	if opts.CaptureCandidate && item.Err == nil {
+		row = row.WithExtra(CandidateColumn, strconv.Itoa(item.Output.Candidate))
+	}
+	if opts.ReenrichAfter != nil {
+		row = row.WithExtra(WrittenAtColumn, time.Now().UTC().Format(time.RFC3339Nano))
 	}
+	return row
 }
 
 func jsonArrayOrEmpty(vals []string) string {
@@ -0,0 +1,95 @@
+package pipeline
+
+import "slices"
+
+// ColumnSpec is one CSV output column of a RowSchema.
+type ColumnSpec struct {
+	Name string
+	// Getter returns the row's value for the column. Nil reads Row.Extra[Name], so a column can be
+	// added without a Row field.
+	Getter func(Row) string
+	// Setter stores a value read back from the column (ReadCSV, the incremental cache). Nil stores
+	// it in Row.Extra[Name].
+	Setter func(*Row, string)
+	// Optional columns may be missing from a CSV read back with the schema; they read as empty.
+	// Reading fails on a missing column that is not optional.
+	Optional bool
+}
+
+func (c ColumnSpec) get(r Row) string {
+	if c.Getter == nil {
+		return r.Extra[c.Name]
+	}
+	return c.Getter(r)
+}
+
+func (c ColumnSpec) set(r *Row, v string) {
+	if c.Setter != nil {
+		c.Setter(r, v)
+		return
+	}
+	*r = r.WithExtra(c.Name, v)
+}
+
+// RowSchema is the ordered column set WriteCSVWithSchema writes and NewCSVRowReaderWithSchema
+// reads. The zero value means DefaultRowSchema; programs that add enrichment fields extend it with
+// WithColumns and pass it as Options.Schema.
+type RowSchema struct {
+	Columns []ColumnSpec
+}
+
+// DefaultRowSchema returns the stable output schema (see Header). completeness and skip_reason
+// were added after outputs were first written, and lean outputs omit the AuditColumns, so those
+// are optional when reading.
+func DefaultRowSchema() RowSchema {
+	return RowSchema{Columns: []ColumnSpec{
+		{Name: "email", Getter: func(r Row) string { return r.Email }, Setter: func(r *Row, v string) { r.Email = v }},
+		{Name: "linkedin_url", Getter: func(r Row) string { return r.LinkedInURL }, Setter: func(r *Row, v string) { r.LinkedInURL = v }},
+		{Name: "company", Getter: func(r Row) string { return r.Company }, Setter: func(r *Row, v string) { r.Company = v }},
+		{Name: "title", Getter: func(r Row) string { return r.Title }, Setter: func(r *Row, v string) { r.Title = v }},
+		{Name: "description", Getter: func(r Row) string { return r.Description }, Setter: func(r *Row, v string) { r.Description = v }},
+		{Name: "confidence", Getter: func(r Row) string { return r.Confidence }, Setter: func(r *Row, v string) { r.Confidence = v }},
+		{Name: "status", Getter: func(r Row) string { return r.Status }, Setter: func(r *Row, v string) { r.Status = v }},
+		{Name: "error", Getter: func(r Row) string { return r.Error }, Setter: func(r *Row, v string) { r.Error = v }},
+		{Name: "model", Getter: func(r Row) string { return r.Model }, Setter: func(r *Row, v string) { r.Model = v }, Optional: true},
+		{Name: "sources", Getter: func(r Row) string { return r.Sources }, Setter: func(r *Row, v string) { r.Sources = v }, Optional: true},
+		{Name: "web_search_queries", Getter: func(r Row) string { return r.WebSearchQueries }, Setter: func(r *Row, v string) { r.WebSearchQueries = v }, Optional: true},
+		{Name: "completeness", Getter: func(r Row) string { return r.Completeness }, Setter: func(r *Row, v string) { r.Completeness = v }, Optional: true},
+		{Name: SkipReasonColumn, Getter: func(r Row) string { return string(r.SkipReason) }, Setter: func(r *Row, v string) { r.SkipReason = SkipReason(v) }, Optional: true},
+	}}
+}
+
+// orDefault returns s, or DefaultRowSchema when s has no columns.
+func (s RowSchema) orDefault() RowSchema {
+	if len(s.Columns) == 0 {
+		return DefaultRowSchema()
+	}
+	return s
+}
+
+// Header returns the schema's column names in order.
+func (s RowSchema) Header() []string {
+	cols := s.orDefault().Columns
+	header := make([]string, len(cols))
+	for i, c := range cols {
+		header[i] = c.Name
+	}
+	return header
+}
+
+// WithColumns returns a copy of the schema with cols appended.
+func (s RowSchema) WithColumns(cols ...ColumnSpec) RowSchema {
+	return RowSchema{Columns: append(slices.Clone(s.orDefault().Columns), cols...)}
+}
+
+// Lean returns the schema without AuditColumns, for outputs written without audit columns.
+func (s RowSchema) Lean() RowSchema {
+	audit := AuditColumns()
+	var cols []ColumnSpec
+	for _, c := range s.orDefault().Columns {
+		if !slices.Contains(audit, c.Name) {
+			cols = append(cols, c)
+		}
+	}
+	return RowSchema{Columns: cols}
+}
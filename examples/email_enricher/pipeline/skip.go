@@ -0,0 +1,28 @@
+package pipeline
+
+import "strings"
+
+// StatusSkipped marks an output row the run deliberately did not enrich. Its SkipReason, written
+// to SkipReasonColumn, says why. Skipped rows are not ok, so a later run considers them again.
+const StatusSkipped = "skipped"
+
+// SkipReasonColumn is the output column holding a skipped row's SkipReason.
+const SkipReasonColumn = "skip_reason"
+
+// SkipReason says why a row has StatusSkipped. Every skip path uses one of the constants below so
+// downstream consumers can filter on a fixed set of values.
+type SkipReason string
+
+const (
+	// SkipReasonBudget: the max-unique-enrich budget deferred the email to a later run.
+	SkipReasonBudget SkipReason = "budget"
+	// SkipReasonDomain: the enrich domain allow/deny lists excluded the email.
+	SkipReasonDomain SkipReason = "domain"
+	// SkipReasonFilter: the input filter excluded the row.
+	SkipReasonFilter SkipReason = "filter"
+)
+
+// SkippedRow returns the output row for an email skipped for reason.
+func SkippedRow(email string, reason SkipReason) Row {
+	return Row{Email: strings.TrimSpace(email), Status: StatusSkipped, SkipReason: reason}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"strings"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/schema"
 )
 
 // StreamMetadataHeader returns stream-only metadata columns that may be present
@@ -14,6 +16,34 @@ func StreamMetadataHeader() []string {
 	return []string{"run_id", "written_at"}
 }
 
+// StreamMeta names the metadata fields stamped on published stream records. Prefix namespaces
+// them (for example "_meta_" gives "_meta_run_id") so consumers with strict schemas can tell them
+// apart from data fields; control fields take the same prefix via Key. The zero value uses the
+// unprefixed names of StreamMetadataHeader.
+type StreamMeta struct {
+	Prefix string
+}
+
+// Key returns the record field name for the metadata or control field name.
+func (m StreamMeta) Key(name string) string {
+	return m.Prefix + name
+}
+
+// RunIDKey is the field holding the run id of the run that published the record.
+func (m StreamMeta) RunIDKey() string {
+	return m.Key("run_id")
+}
+
+// WrittenAtKey is the field holding the RFC 3339 publish time.
+func (m StreamMeta) WrittenAtKey() string {
+	return m.Key("written_at")
+}
+
+// Header returns the metadata columns under this prefix, in StreamMetadataHeader order.
+func (m StreamMeta) Header() []string {
+	return []string{m.RunIDKey(), m.WrittenAtKey()}
+}
+
 // StreamTableHeader returns the CSV table projection used when stream records
 // are exposed through a dataset-style readTable view.
 func StreamTableHeader() []string {
@@ -63,13 +93,17 @@ func RowFromStreamRecord(rec map[string]any) Row {
 		Model:            get("model"),
 		Sources:          get("sources"),
 		WebSearchQueries: get("web_search_queries"),
+		Completeness:     get("completeness"),
+		SkipReason:       SkipReason(get(SkipReasonColumn)),
 	}
 }
 
 // RowToStreamRecord converts Row into the legacy stream-proxy JSON record
 // shape. Empty optional values are emitted as nil so nullable string columns
-// behave like missing values rather than empty strings.
-func RowToStreamRecord(r Row) map[string]any {
+// behave like missing values rather than empty strings. Of the row's Extra
+// values only extraColumns are emitted, so columns a run did not configure for
+// this output (raw_response, token usage) never reach a strict schema.
+func RowToStreamRecord(r Row, extraColumns []string) map[string]any {
 	rec := map[string]any{
 		"email": r.Email,
 	}
@@ -83,6 +117,46 @@ func RowToStreamRecord(r Row) map[string]any {
 	assignNullable(rec, "model", r.Model)
 	assignNullable(rec, "sources", r.Sources)
 	assignNullable(rec, "web_search_queries", r.WebSearchQueries)
+	assignNullable(rec, "completeness", r.Completeness)
+	assignNullable(rec, SkipReasonColumn, string(r.SkipReason))
+	for _, col := range extraColumns {
+		if v, ok := r.Extra[col]; ok {
+			assignNullable(rec, col, v)
+		}
+	}
+	return rec
+}
+
+// RowToStreamRecordWithContract is RowToStreamRecord matched to the stream's schema contract: an
+// empty value of a field the contract declares non-nullable is emitted as the zero value of its
+// declared type (see schema.Field.Zero) instead of null. Fields the contract does not declare,
+// types without a zero value, and every field when contract is nil keep null.
+func RowToStreamRecordWithContract(r Row, extraColumns []string, contract *schema.DatasetContract) map[string]any {
+	rec := RowToStreamRecord(r, extraColumns)
+	if contract == nil {
+		return rec
+	}
+	for key, v := range rec {
+		if v != nil {
+			continue
+		}
+		f, ok := contract.Field(key)
+		if !ok || f.Nullable {
+			continue
+		}
+		if zero, ok := f.Zero(); ok {
+			rec[key] = zero
+		}
+	}
+	return rec
+}
+
+// OmitAuditFields removes the AuditColumns fields from a stream record, for outputs published
+// without audit columns, and returns rec.
+func OmitAuditFields(rec map[string]any) map[string]any {
+	for _, col := range AuditColumns() {
+		delete(rec, col)
+	}
 	return rec
 }
 
@@ -0,0 +1,59 @@
+package app_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+)
+
+func TestRunFoundry_ResolvesPathAliases(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	mock.SetDatasetPath("/Org/Project/emails", testInputRID)
+	mock.SetDatasetPath("/Org/Project/enriched", testOutputRID)
+	env.Aliases = map[string]foundry.DatasetRef{
+		"input":  {Path: "/Org/Project/emails"},
+		"output": {Path: "/Org/Project/enriched", Branch: "master"},
+	}
+
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+	}, pipeline.Options{}, testEnricher{}); err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+
+	uploads := mock.Uploads()
+	if len(uploads) != 1 || uploads[0].DatasetRID != testOutputRID {
+		t.Fatalf("expected one upload to the resolved output rid, got %#v", uploads)
+	}
+	if !strings.Contains(string(uploads[0].Bytes), "alice@example.com") {
+		t.Fatalf("expected resolved input to be enriched, got %q", uploads[0].Bytes)
+	}
+}
+
+func TestRunFoundry_UnknownPathAliasFailsBeforeEnrichment(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	env.Aliases["input"] = foundry.DatasetRef{Path: "/Org/Project/missing"}
+
+	enricher := &countingEnricher{}
+	_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+	}, pipeline.Options{}, enricher)
+	if err == nil || !strings.Contains(err.Error(), `resolve alias "input" path "/Org/Project/missing"`) {
+		t.Fatalf("expected path resolution error, got %v", err)
+	}
+	if len(enricher.calls) != 0 || len(mock.Uploads()) != 0 {
+		t.Fatalf("expected no enrichment or uploads, got calls=%v uploads=%d", enricher.calls, len(mock.Uploads()))
+	}
+}
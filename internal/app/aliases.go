@@ -0,0 +1,38 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+)
+
+// resolveDatasetRef returns ref with its RID looked up when the alias map gave only a path. Refs
+// with a RID are returned unchanged without any API call. Resolution happens at each run start, so
+// a path re-pointed at another dataset is picked up by the next watch tick.
+func resolveDatasetRef(ctx context.Context, client *foundry.Client, alias string, ref foundry.DatasetRef) (foundry.DatasetRef, error) {
+	if strings.TrimSpace(ref.RID) != "" || strings.TrimSpace(ref.Path) == "" {
+		return ref, nil
+	}
+	rid, err := client.ResolveDatasetRID(ctx, ref.Path)
+	if err != nil {
+		return ref, fmt.Errorf("resolve alias %q path %q: %w", alias, ref.Path, err)
+	}
+	ref.RID = rid
+	return ref, nil
+}
+
+// checkOutputNotInput fails when an output resolves to the input dataset on the same branch, which
+// would make the run overwrite (or publish into) its own input.
+func checkOutputNotInput(input foundry.DatasetRef, outputAlias string, output foundry.DatasetRef) error {
+	if strings.TrimSpace(input.RID) != strings.TrimSpace(output.RID) || defaultBranch(input.Branch) != defaultBranch(output.Branch) {
+		return nil
+	}
+	return invalidConfig(fmt.Errorf(
+		"output alias %q resolves to the input dataset %s@%s; the run would overwrite its own input (use --allow-input-output-same to override)",
+		outputAlias,
+		output.RID,
+		defaultBranch(output.Branch),
+	))
+}
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+	foundryio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/foundry"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/output"
+)
+
+// AuditSinkFile is the audit sink scheme for a local NDJSON file ("file://<path>"); a value
+// without a scheme is a file path. Foundry mode also accepts "foundry-stream://<alias>".
+const AuditSinkFile = "file"
+
+// openAuditSink opens the --audit-sink value, which requires a hashKey. openStream opens a
+// foundry-stream alias; it is nil in local mode, where only files are accepted. An empty value
+// returns a nil sink.
+func openAuditSink(v, hashKey string, openStream func(alias string) (pipeline.AuditSink, error)) (pipeline.AuditSink, error) {
+	if strings.TrimSpace(v) == "" {
+		return nil, nil
+	}
+	if hashKey == "" {
+		return nil, invalidConfig(fmt.Errorf("audit sink requires an audit hash key"))
+	}
+	t := output.ParseTarget(v, AuditSinkFile)
+	location := strings.TrimSpace(t.Location)
+	switch {
+	case t.Scheme == AuditSinkFile && location != "":
+		return pipeline.OpenFileAuditSink(location)
+	case t.Scheme == OutputFoundryStream && openStream == nil:
+		return nil, invalidConfig(fmt.Errorf("%s audit sink requires foundry mode", OutputFoundryStream))
+	case t.Scheme == OutputFoundryStream && location != "":
+		return openStream(location)
+	default:
+		return nil, invalidConfig(fmt.Errorf("invalid audit sink %q (expected <path>|%s://<path>|%s://<alias>)", v, AuditSinkFile, OutputFoundryStream))
+	}
+}
+
+// closeAuditSink closes sink (if any), reporting a close failure through *err unless it already
+// holds an error.
+func closeAuditSink(sink pipeline.AuditSink, err *error) {
+	if sink == nil {
+		return
+	}
+	if cerr := sink.Close(); cerr != nil && *err == nil {
+		*err = fmt.Errorf("close audit sink: %w", cerr)
+	}
+}
+
+// streamAuditSink publishes audit records to a Foundry stream, one record per enrichment.
+type streamAuditSink struct {
+	backend foundryio.StreamBackend
+	ref     foundry.DatasetRef
+}
+
+func (s streamAuditSink) Record(ctx context.Context, rec pipeline.AuditRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	return s.backend.PublishRecord(ctx, s.ref, m)
+}
+
+func (streamAuditSink) Close() error { return nil }
+
+// auditRows returns a row callback recording each row to sink, its email hashed under hashKey, or
+// nil when sink is nil.
+func auditRows(ctx context.Context, sink pipeline.AuditSink, runID, hashKey string) func(pipeline.Row) error {
+	if sink == nil {
+		return nil
+	}
+	return func(row pipeline.Row) error {
+		if err := sink.Record(ctx, pipeline.NewAuditRecord(row, runID, time.Now(), []byte(hashKey))); err != nil {
+			return fmt.Errorf("audit sink: %w", err)
+		}
+		return nil
+	}
+}
+
+// chainRowCallbacks returns a callback calling each non-nil callback in order, or nil when all are
+// nil.
+func chainRowCallbacks(callbacks ...func(pipeline.Row) error) func(pipeline.Row) error {
+	var fns []func(pipeline.Row) error
+	for _, fn := range callbacks {
+		if fn != nil {
+			fns = append(fns, fn)
+		}
+	}
+	if len(fns) == 0 {
+		return nil
+	}
+	return func(row pipeline.Row) error {
+		for _, fn := range fns {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,57 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+func TestRunFoundry_OmitAuditColumnsLeanOutputServesIncrementalCache(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email\nalice@example.com\nbob@corp.test\n")
+	fopts := app.FoundryOptions{
+		InputAlias:       "input",
+		OutputAlias:      "output",
+		OutputWriteMode:  "dataset",
+		OmitAuditColumns: true,
+	}
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{}, testEnricher{}); err != nil {
+		t.Fatalf("first run failed: %v", err)
+	}
+
+	uploads := mock.Uploads()
+	if len(uploads) != 1 {
+		t.Fatalf("expected 1 upload, got %d", len(uploads))
+	}
+	header := strings.SplitN(string(uploads[0].Bytes), "\n", 2)[0]
+	if got := strings.Split(header, ","); !slices.Equal(got, pipeline.LeanHeader()) {
+		t.Fatalf("expected lean header %v, got %v", pipeline.LeanHeader(), got)
+	}
+
+	enricher := &countingEnricher{}
+	res, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{}, enricher)
+	if err != nil {
+		t.Fatalf("second run failed: %v", err)
+	}
+	if len(enricher.calls) != 0 {
+		t.Fatalf("expected the lean prior output to serve every email from cache, enriched %v", enricher.calls)
+	}
+	if res.Plan.CachedRows != 2 {
+		t.Fatalf("expected 2 cached rows, got %d", res.Plan.CachedRows)
+	}
+
+	uploads = mock.Uploads()
+	rows, err := pipeline.ReadCSV(bytes.NewReader(uploads[len(uploads)-1].Bytes))
+	if err != nil {
+		t.Fatalf("parse uploaded csv: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Status != "ok" || rows[0].Model != "" {
+		t.Fatalf("unexpected rows: %#v", rows)
+	}
+}
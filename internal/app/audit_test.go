@@ -0,0 +1,141 @@
+package app_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/redact"
+)
+
+const testAuditHashKey = "test-audit-key"
+
+func TestRunLocal_AuditSinkRecordsEachEnrichedEmail(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.csv")
+	auditPath := filepath.Join(dir, "audit.ndjson")
+	// The duplicate is enriched once, so it is audited once.
+	if err := os.WriteFile(inputPath, []byte("email\nalice@example.com\nbob@corp.test\nalice@example.com\n"), 0644); err != nil {
+		t.Fatalf("write input csv: %v", err)
+	}
+
+	if _, err := app.RunLocalWithOptions(context.Background(), app.LocalOptions{
+		InputPath:    inputPath,
+		OutputPath:   filepath.Join(dir, "output.csv"),
+		AuditSink:    auditPath,
+		AuditHashKey: testAuditHashKey,
+	}, pipeline.Options{}, testEnricher{}); err != nil {
+		t.Fatalf("RunLocalWithOptions failed: %v", err)
+	}
+
+	raw, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	if strings.Contains(string(raw), "@") {
+		t.Fatalf("expected no raw emails in the audit log, got:\n%s", raw)
+	}
+
+	byHash := map[string]pipeline.AuditRecord{}
+	sc := bufio.NewScanner(strings.NewReader(string(raw)))
+	for sc.Scan() {
+		var rec pipeline.AuditRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("parse audit record %q: %v", sc.Text(), err)
+		}
+		byHash[rec.EmailHMAC] = rec
+	}
+	if len(byHash) != 2 || strings.Count(string(raw), "\n") != 2 {
+		t.Fatalf("expected one audit record per enriched email, got:\n%s", raw)
+	}
+	rec, ok := byHash[redact.HashEmail([]byte(testAuditHashKey), "alice@example.com")]
+	if !ok {
+		t.Fatalf("missing audit record for alice: %v", byHash)
+	}
+	if rec.Status != "ok" || rec.Model != "test-model" || rec.Fields["company"] != "example.com" || rec.Timestamp.IsZero() {
+		t.Fatalf("unexpected audit record: %#v", rec)
+	}
+}
+
+func TestRunFoundry_AuditSinkPublishesToStream(t *testing.T) {
+	t.Parallel()
+
+	auditRID := "ri.foundry.main.dataset.55555555-5555-5555-5555-555555555555"
+	mock, env := newMockFoundryEnv(t, "email\nalice@example.com\nbob@corp.test\n")
+	mock.CreateStream(auditRID)
+	env.Aliases["audit"] = foundry.DatasetRef{RID: auditRID, Branch: "master"}
+
+	res, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+		AuditSink:       "foundry-stream://audit",
+		AuditHashKey:    testAuditHashKey,
+	}, pipeline.Options{}, testEnricher{})
+	if err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+
+	recs := mock.StreamRecords(auditRID, "master")
+	if len(recs) != 2 {
+		t.Fatalf("expected 2 audit records, got %d: %#v", len(recs), recs)
+	}
+	for _, rec := range recs {
+		if _, ok := rec["email"]; ok {
+			t.Fatalf("expected no raw email field, got %#v", rec)
+		}
+		if rec["run_id"] != res.RunID || rec["status"] != "ok" || rec["model"] != "test-model" {
+			t.Fatalf("unexpected audit record: %#v", rec)
+		}
+		hash, _ := rec["email_hmac"].(string)
+		if hash != redact.HashEmail([]byte(testAuditHashKey), "alice@example.com") && hash != redact.HashEmail([]byte(testAuditHashKey), "bob@corp.test") {
+			t.Fatalf("unexpected email hash in %#v", rec)
+		}
+	}
+}
+
+func TestRunLocal_StreamAuditSinkRequiresFoundryMode(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.csv")
+	if err := os.WriteFile(inputPath, []byte("email\nalice@example.com\n"), 0644); err != nil {
+		t.Fatalf("write input csv: %v", err)
+	}
+	_, err := app.RunLocalWithOptions(context.Background(), app.LocalOptions{
+		InputPath:    inputPath,
+		OutputPath:   filepath.Join(dir, "output.csv"),
+		AuditSink:    "foundry-stream://audit",
+		AuditHashKey: testAuditHashKey,
+	}, pipeline.Options{}, testEnricher{})
+	if app.ClassifyFailure(err) != app.FailureConfig {
+		t.Fatalf("expected a config error, got %v", err)
+	}
+}
+
+func TestRunLocal_AuditSinkRequiresHashKey(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	enricher := &countingEnricher{}
+	_, err := app.RunLocalWithOptions(context.Background(), app.LocalOptions{
+		InputPath:  writeLocalInput(t, "email\nalice@example.com\n"),
+		OutputPath: filepath.Join(dir, "output.csv"),
+		AuditSink:  filepath.Join(dir, "audit.ndjson"),
+	}, pipeline.Options{}, enricher)
+	if app.ClassifyFailure(err) != app.FailureConfig {
+		t.Fatalf("expected a config error, got %v", err)
+	}
+	if got := enricher.count("alice@example.com"); got != 0 {
+		t.Fatalf("expected no enrichment, got %d calls", got)
+	}
+}
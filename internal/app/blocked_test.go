@@ -0,0 +1,62 @@
+package app_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/enrich"
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+// countingBlockEnricher counts calls like countingEnricher and blocks emails starting with
+// "blocked@".
+type countingBlockEnricher struct {
+	countingEnricher
+}
+
+func (c *countingBlockEnricher) Enrich(ctx context.Context, email string) (enrich.Result, error) {
+	res, err := c.countingEnricher.Enrich(ctx, email)
+	if strings.HasPrefix(email, "blocked@") {
+		return enrich.Result{}, &enrich.BlockedError{Reason: "SAFETY"}
+	}
+	return res, err
+}
+
+func TestRunFoundry_CachesBlockedRows(t *testing.T) {
+	t.Parallel()
+
+	for _, mode := range []string{"dataset", "stream"} {
+		t.Run(mode, func(t *testing.T) {
+			t.Parallel()
+
+			mock, env := newMockFoundryEnv(t, "email\nalice@example.com\nblocked@corp.test\n")
+			if mode == "stream" {
+				mock.CreateStream(testOutputRID)
+			}
+			enricher := &countingBlockEnricher{}
+			run := func() app.RunResult {
+				t.Helper()
+				res, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+					InputAlias:      "input",
+					OutputAlias:     "output",
+					OutputWriteMode: mode,
+				}, pipeline.Options{}, enricher)
+				if err != nil {
+					t.Fatalf("RunFoundryWithOptions failed: %v", err)
+				}
+				return res
+			}
+
+			run()
+			// A provider block is deterministic, so the second run reuses the blocked row.
+			if res := run(); res.Plan.CachedRows != 2 {
+				t.Fatalf("expected the ok and blocked rows cached, got %+v", res.Plan)
+			}
+			if got := enricher.count("blocked@corp.test"); got != 1 {
+				t.Fatalf("expected the blocked email enriched once, got %d", got)
+			}
+		})
+	}
+}
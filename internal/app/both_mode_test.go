@@ -0,0 +1,74 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+)
+
+func TestRunFoundry_BothModeWritesStreamAndDataset(t *testing.T) {
+	t.Parallel()
+
+	streamRID := "ri.foundry.main.dataset.44444444-4444-4444-4444-444444444444"
+
+	mock, env := newMockFoundryEnv(t, "email\nalice@example.com\nbob@corp.test\ncarol@new.test\n")
+	mock.CreateStream(streamRID)
+	env.Aliases["stream"] = foundry.DatasetRef{RID: streamRID, Branch: "master"}
+
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:        "input",
+		OutputAlias:       "output",
+		OutputWriteMode:   "both",
+		StreamOutputAlias: "stream",
+	}, pipeline.Options{Workers: 2}, testEnricher{}); err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+
+	recs := mock.StreamRecords(streamRID, "master")
+	if len(recs) != 3 {
+		t.Fatalf("expected 3 stream records, got %d: %#v", len(recs), recs)
+	}
+	published := map[string]bool{}
+	for _, rec := range recs {
+		email, _ := rec["email"].(string)
+		published[email] = true
+		if _, ok := rec["run_id"]; !ok {
+			t.Fatalf("stream record missing run_id: %#v", rec)
+		}
+	}
+
+	keys := mock.StreamPartitionKeys(streamRID, "master")
+	for i, rec := range recs {
+		if email, _ := rec["email"].(string); keys[i] != email {
+			t.Fatalf("record[%d]: expected partition key %q, got %q", i, email, keys[i])
+		}
+	}
+
+	uploads := mock.Uploads()
+	if len(uploads) != 1 {
+		t.Fatalf("expected 1 dataset upload, got %d: %#v", len(uploads), uploads)
+	}
+	if uploads[0].DatasetRID != testOutputRID {
+		t.Fatalf("dataset upload rid: want %q, got %q", testOutputRID, uploads[0].DatasetRID)
+	}
+	rows, err := pipeline.ReadCSV(bytes.NewReader(uploads[0].Bytes))
+	if err != nil {
+		t.Fatalf("parse uploaded csv: %v", err)
+	}
+	wantEmails := []string{"alice@example.com", "bob@corp.test", "carol@new.test"}
+	if len(rows) != len(wantEmails) {
+		t.Fatalf("expected %d dataset rows, got %d: %#v", len(wantEmails), len(rows), rows)
+	}
+	for i, want := range wantEmails {
+		if rows[i].Email != want || rows[i].Status != "ok" {
+			t.Fatalf("dataset row[%d]: want ok row for %q, got %#v", i, want, rows[i])
+		}
+		if !published[want] {
+			t.Fatalf("expected %q to be published to the stream (published=%v)", want, published)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/mockfoundry"
+)
+
+const (
+	testInputRID  = "ri.foundry.main.dataset.11111111-1111-1111-1111-111111111111"
+	testOutputRID = "ri.foundry.main.dataset.22222222-2222-2222-2222-222222222222"
+)
+
+// newMockFoundryEnv starts a mockfoundry server seeded with inputCSV for the "input" alias and
+// returns it together with an env wired to "input" and "output" aliases.
+func newMockFoundryEnv(t *testing.T, inputCSV string) (*mockfoundry.Server, foundry.Env) {
+	t.Helper()
+
+	inputDir := t.TempDir()
+	uploadDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, testInputRID+".csv"), []byte(inputCSV), 0644); err != nil {
+		t.Fatalf("write input csv: %v", err)
+	}
+
+	mock := mockfoundry.New(inputDir, uploadDir)
+	mock.RequireBearerToken("dummy-token")
+	ts := httptest.NewServer(mock.Handler())
+	t.Cleanup(ts.Close)
+
+	return mock, foundry.Env{
+		Services: foundry.Services{
+			APIGateway:  ts.URL + "/api",
+			StreamProxy: ts.URL + "/stream-proxy/api",
+		},
+		Token: "dummy-token",
+		Aliases: map[string]foundry.DatasetRef{
+			"input":  {RID: testInputRID, Branch: "master"},
+			"output": {RID: testOutputRID, Branch: "master"},
+		},
+	}
+}
+
+func TestRunFoundry_MaxUniqueEnrichBudget(t *testing.T) {
+	t.Parallel()
+
+	input := "email\nalice@example.com\nbob@corp.test\nalice@example.com\n"
+
+	cases := []struct {
+		name          string
+		maxUnique     int
+		mode          string
+		wantErr       string
+		wantCalls     map[string]int
+		wantStatuses  []string
+		wantNoUploads bool
+	}{
+		{
+			name:         "at boundary enriches all",
+			maxUnique:    2,
+			mode:         "fail",
+			wantCalls:    map[string]int{"alice@example.com": 1, "bob@corp.test": 1},
+			wantStatuses: []string{"ok", "ok", "ok"},
+		},
+		{
+			name:          "over budget fails before enrichment",
+			maxUnique:     1,
+			mode:          "fail",
+			wantErr:       "enrichment budget exceeded: 2 unique emails pending, max-unique-enrich=1",
+			wantCalls:     map[string]int{"alice@example.com": 0, "bob@corp.test": 0},
+			wantNoUploads: true,
+		},
+		{
+			name:         "over budget truncates and defers the rest",
+			maxUnique:    1,
+			mode:         "truncate",
+			wantCalls:    map[string]int{"alice@example.com": 1, "bob@corp.test": 0},
+			wantStatuses: []string{"ok", "skipped:budget", "ok"},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock, env := newMockFoundryEnv(t, input)
+			enricher := &countingEnricher{}
+			_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+				InputAlias:       "input",
+				OutputAlias:      "output",
+				OutputWriteMode:  "dataset",
+				MaxUniqueEnrich:  tc.maxUnique,
+				OnBudgetExceeded: tc.mode,
+			}, pipeline.Options{}, enricher)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("RunFoundryWithOptions failed: %v", err)
+			}
+
+			for email, want := range tc.wantCalls {
+				if got := enricher.count(email); got != want {
+					t.Fatalf("enrich calls for %s: want %d, got %d", email, want, got)
+				}
+			}
+
+			uploads := mock.Uploads()
+			if tc.wantNoUploads {
+				if len(uploads) != 0 {
+					t.Fatalf("expected no uploads, got %#v", uploads)
+				}
+				return
+			}
+			if len(uploads) != 1 {
+				t.Fatalf("expected 1 upload, got %d", len(uploads))
+			}
+			rows, err := pipeline.ReadCSV(bytes.NewReader(uploads[0].Bytes))
+			if err != nil {
+				t.Fatalf("parse uploaded csv: %v", err)
+			}
+			if len(rows) != len(tc.wantStatuses) {
+				t.Fatalf("expected %d rows, got %d: %#v", len(tc.wantStatuses), len(rows), rows)
+			}
+			for i, want := range tc.wantStatuses {
+				if got := rowOutcome(rows[i]); got != want {
+					t.Fatalf("row[%d] status: want %q, got %q (row=%#v)", i, want, got, rows[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRunFoundryWithOptions_InvalidBudgetMode(t *testing.T) {
+	t.Parallel()
+
+	_, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:       "input",
+		OutputAlias:      "output",
+		OnBudgetExceeded: "skip",
+	}, pipeline.Options{}, testEnricher{})
+	if err == nil || !strings.Contains(err.Error(), "fail|truncate") {
+		t.Fatalf("expected invalid mode error, got %v", err)
+	}
+}
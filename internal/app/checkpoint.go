@@ -0,0 +1,143 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+)
+
+// enrichedRows returns a copy of the plan's rows with the enriched rows in done (keyed by emailKey)
+// applied, as applyEnrichedRows would, and the indexes of the rows it filled in ascending order.
+// Rows of pending emails not in done are left zero; dropUnstartedRows removes them once the rows are
+// tagged.
+func (p *incrementalPlan) enrichedRows(done map[string]pipeline.Row) ([]pipeline.Row, []int) {
+	rows := slices.Clone(p.rows)
+	var filled []int
+	for key, idxs := range p.pendingIdx {
+		row, ok := done[key]
+		if !ok {
+			continue
+		}
+		row.Email = key
+		for _, idx := range idxs {
+			rows[idx] = row
+			filled = append(filled, idx)
+		}
+	}
+	slices.Sort(filled)
+	return rows, filled
+}
+
+// dropUnstartedRows removes the zero rows enrichedRows leaves for emails that were never enriched, so
+// the next run finds no output row for them and enriches them.
+func dropUnstartedRows(rows []pipeline.Row) []pipeline.Row {
+	return slices.DeleteFunc(rows, func(row pipeline.Row) bool {
+		return strings.TrimSpace(row.Status) == ""
+	})
+}
+
+// checkpointFilename returns the file a checkpoint is appended as: the output filename with the run
+// ID, attempt nonce, and sequence number before its extension. The attempt nonce is generated per
+// run attempt, so checkpoints of a restarted run never collide with the files earlier attempts
+// appended, even under a fixed run ID.
+func checkpointFilename(outputFilename, runID, attempt string, seq int) string {
+	ext := path.Ext(outputFilename)
+	runID = strings.NewReplacer("/", "_", " ", "_").Replace(runID)
+	return fmt.Sprintf("%s.checkpoint-%s-%s-%04d%s", strings.TrimSuffix(outputFilename, ext), runID, attempt, seq, ext)
+}
+
+// chunkCommitter appends the rows enriched since the last checkpoint to the dataset output every
+// `every` enriched emails (--commit-every), so a run that dies midway keeps its progress: the next
+// run reads the prior output plus the appended chunks as its incremental cache and only enriches
+// what is left. The run's final write is a SNAPSHOT that replaces the chunks.
+type chunkCommitter struct {
+	every int
+	plan  *incrementalPlan
+	// tag tags a full-length copy of the plan's rows (source rows, passthrough columns) before the
+	// chunk is picked out of it.
+	tag func(rows []pipeline.Row)
+	// commit appends chunk as checkpoint number seq.
+	commit func(chunk []pipeline.Row, seq int) error
+	logf   func(format string, args ...any)
+	warn   *warningCollector
+
+	chunk   map[string]pipeline.Row
+	since   int
+	done    int
+	commits int
+}
+
+func newChunkCommitter(
+	every int,
+	plan *incrementalPlan,
+	tag func(rows []pipeline.Row),
+	commit func(chunk []pipeline.Row, seq int) error,
+	logf func(format string, args ...any),
+	warn *warningCollector,
+) *chunkCommitter {
+	return &chunkCommitter{
+		every:  every,
+		plan:   plan,
+		tag:    tag,
+		commit: commit,
+		logf:   logf,
+		warn:   warn,
+		chunk:  make(map[string]pipeline.Row, every),
+	}
+}
+
+// add records an enriched row and appends a checkpoint once every rows have accumulated since the
+// last one. The final chunk is left to the run's own write. A failed checkpoint is recorded as a
+// WarningCheckpoint warning and its rows are carried into the next one; the run only loses durability until then. add is called from
+// the enrichment callback, which runs serially.
+func (c *chunkCommitter) add(row pipeline.Row) error {
+	c.chunk[emailKey(row.Email)] = row
+	c.since++
+	c.done++
+	if c.since < c.every || c.done >= len(c.plan.pendingEmails) {
+		return nil
+	}
+	c.since = 0
+	rows, filled := c.plan.enrichedRows(c.chunk)
+	c.tag(rows)
+	chunk := make([]pipeline.Row, 0, len(filled))
+	for _, idx := range filled {
+		chunk = append(chunk, rows[idx])
+	}
+	if err := c.commit(chunk, c.commits+1); err != nil {
+		c.warn.warnf(WarningCheckpoint, "dataset checkpoint failed; continuing: enriched=%d/%d: %s", c.done, len(c.plan.pendingEmails), err)
+		return nil
+	}
+	c.commits++
+	clear(c.chunk)
+	c.logf("dataset checkpoint appended: rows=%d enriched=%d/%d", len(chunk), c.done, len(c.plan.pendingEmails))
+	return nil
+}
+
+// checkpointsCommit reports whether checkpoints can be committed on their own. A pre-created OPEN
+// transaction (pipeline builds) would take the checkpoint files instead and commit them with the
+// final output, duplicating their rows, so checkpoints are skipped when one exists or the check fails.
+func checkpointsCommit(ctx context.Context, client *foundry.Client, outputRef foundry.DatasetRef, logf func(format string, args ...any)) bool {
+	txn, open, err := client.FindLatestOpenTransactionForBranch(ctx, outputRef.RID, defaultBranch(outputRef.Branch))
+	switch {
+	case err != nil:
+		logf("commit-every: checking for an open output transaction failed; skipping checkpoints: %s", err)
+		return false
+	case open:
+		logf("commit-every: output transaction %s is already open and commits only with the final write; skipping checkpoints", txn)
+		return false
+	}
+	return true
+}
+
+func validateCommitEvery(n int) error {
+	if n < 0 {
+		return fmt.Errorf("commit-every must be >= 0, got %d", n)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/enrich"
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+)
+
+const checkpointInput = "email\na@one.test\nb@two.test\nc@three.test\nd@four.test\ne@five.test\n"
+
+// dyingEnricher enriches like testEnricher until its call limit, then fails every call, standing
+// in for a run that dies partway through.
+type dyingEnricher struct {
+	mu    sync.Mutex
+	limit int
+	calls int
+}
+
+func (d *dyingEnricher) Enrich(ctx context.Context, email string) (enrich.Result, error) {
+	d.mu.Lock()
+	d.calls++
+	dead := d.calls > d.limit
+	d.mu.Unlock()
+	if dead {
+		return enrich.Result{}, errors.New("enricher died")
+	}
+	return testEnricher{}.Enrich(ctx, email)
+}
+
+func TestRunFoundry_CommitEveryCommitsCheckpoints(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, checkpointInput)
+	res, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+		CommitEvery:     2,
+	}, pipeline.Options{Workers: 1}, testEnricher{})
+	if err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+	// Checkpoints after 2 and 4 emails; the fifth is left to the final write.
+	if res.Checkpoints != 2 {
+		t.Fatalf("expected 2 checkpoints, got %d", res.Checkpoints)
+	}
+	uploads := mock.Uploads()
+	if len(uploads) != 3 {
+		t.Fatalf("expected 3 commits (2 checkpoints + final), got %d", len(uploads))
+	}
+
+	// Each checkpoint appends only its own chunk, as a file of its own.
+	for i, want := range [][]string{{"a@one.test", "b@two.test"}, {"c@three.test", "d@four.test"}} {
+		if uploads[i].FilePath == uploads[2].FilePath || (i > 0 && uploads[i].FilePath == uploads[i-1].FilePath) {
+			t.Fatalf("expected checkpoint %d in its own file, got %q", i+1, uploads[i].FilePath)
+		}
+		chunk, err := pipeline.ReadCSV(bytes.NewReader(uploads[i].Bytes))
+		if err != nil {
+			t.Fatalf("parse checkpoint %d: %v", i+1, err)
+		}
+		var got []string
+		for _, row := range chunk {
+			if row.Status != "ok" {
+				t.Fatalf("expected only enriched rows in checkpoint %d, got %#v", i+1, row)
+			}
+			got = append(got, row.Email)
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("expected checkpoint %d to hold %v, got %v", i+1, want, got)
+		}
+	}
+
+	final, err := pipeline.ReadCSV(bytes.NewReader(uploads[2].Bytes))
+	if err != nil {
+		t.Fatalf("parse final output: %v", err)
+	}
+	if len(final) != 5 {
+		t.Fatalf("expected the final snapshot to hold all 5 rows, got %d", len(final))
+	}
+	for _, row := range final {
+		if row.Status != "ok" {
+			t.Fatalf("expected every final row ok, got %#v", row)
+		}
+	}
+}
+
+func TestRunFoundry_CommitEveryRestartResumesFromLastCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, checkpointInput)
+	fopts := app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+		CommitEvery:     2,
+	}
+	// The run dies on the third email, after the first checkpoint and before the second.
+	_, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{Workers: 1, FailFast: true}, &dyingEnricher{limit: 2})
+	if err == nil {
+		t.Fatalf("expected the first run to fail")
+	}
+	if got := len(mock.Uploads()); got != 1 {
+		t.Fatalf("expected 1 checkpoint commit before the failure, got %d", got)
+	}
+
+	enricher := &countingEnricher{}
+	res, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{Workers: 1}, enricher)
+	if err != nil {
+		t.Fatalf("restarted run failed: %v", err)
+	}
+	if res.Plan.CachedRows != 2 {
+		t.Fatalf("expected the 2 checkpointed rows cached, got %d", res.Plan.CachedRows)
+	}
+	got := slices.Sorted(maps.Keys(enricher.calls))
+	want := []string{"c@three.test", "d@four.test", "e@five.test"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected the restart to enrich %v, got %v", want, got)
+	}
+}
+
+func TestRunFoundry_CommitEverySkipsCheckpointsInOpenBuildTransaction(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, checkpointInput)
+	client, err := foundry.NewClient(env.Services.APIGateway, env.Services.StreamProxy, env.Token, "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	// Foundry opened the output transaction for the build; checkpoint files would be committed
+	// with the final output.
+	if _, err := client.CreateTransaction(context.Background(), testOutputRID, "master"); err != nil {
+		t.Fatalf("create build transaction: %v", err)
+	}
+
+	res, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+		CommitEvery:     2,
+	}, pipeline.Options{Workers: 1}, testEnricher{})
+	if err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+	if res.Checkpoints != 0 {
+		t.Fatalf("expected no checkpoints, got %d", res.Checkpoints)
+	}
+	uploads := mock.Uploads()
+	if len(uploads) != 1 || uploads[0].FilePath != res.OutputFile {
+		t.Fatalf("expected only the final output uploaded, got %d uploads", len(uploads))
+	}
+}
+
+func TestRunFoundry_CommitEveryRestartsWithFixedRunID(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, checkpointInput)
+	fopts := app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+		CommitEvery:     2,
+		RunID:           "fixed-run",
+	}
+	// The first two attempts each checkpoint two emails and die on the third; the restarts reuse
+	// the run id, so their checkpoint files (sequence 1 both times) must not collide.
+	for attempt := 1; attempt <= 2; attempt++ {
+		res, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{Workers: 1, FailFast: true}, &dyingEnricher{limit: 2})
+		if err == nil {
+			t.Fatalf("attempt %d: expected the run to fail", attempt)
+		}
+		if res.Checkpoints != 1 || len(res.Warnings) != 0 {
+			t.Fatalf("attempt %d: expected 1 checkpoint and no warnings, got %d checkpoints, warnings %v", attempt, res.Checkpoints, res.Warnings)
+		}
+	}
+
+	enricher := &countingEnricher{}
+	res, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{Workers: 1}, enricher)
+	if err != nil {
+		t.Fatalf("final attempt failed: %v", err)
+	}
+	if res.Plan.CachedRows != 4 {
+		t.Fatalf("expected the 4 checkpointed rows cached, got %+v", res.Plan)
+	}
+	if got := slices.Sorted(maps.Keys(enricher.calls)); !slices.Equal(got, []string{"e@five.test"}) {
+		t.Fatalf("expected only the last email enriched, got %v", got)
+	}
+	uploads := mock.Uploads()
+	final, err := pipeline.ReadCSV(bytes.NewReader(uploads[len(uploads)-1].Bytes))
+	if err != nil {
+		t.Fatalf("parse final output: %v", err)
+	}
+	if len(final) != 5 {
+		t.Fatalf("expected the final snapshot to hold all 5 rows, got %d", len(final))
+	}
+}
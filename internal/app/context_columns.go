@@ -0,0 +1,42 @@
+package app
+
+import (
+	localio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/local"
+)
+
+// inputRecords maps emailKey to the ContextColumns values of the first input row holding that
+// email. It is passed to the enricher through pipeline.Options.InputRecord.
+type inputRecords map[string]map[string]string
+
+// recordsFromItems builds inputRecords from items whose Passthrough holds the context column
+// values starting at offset, in columns order. It returns nil when no columns are requested.
+func recordsFromItems(items []localio.EmailItem, columns []string, offset int) inputRecords {
+	if len(columns) == 0 {
+		return nil
+	}
+	out := inputRecords{}
+	for _, item := range items {
+		key := emailKey(item.Email)
+		if _, ok := out[key]; ok || key == "" {
+			continue
+		}
+		rec := make(map[string]string, len(columns))
+		for j, col := range columns {
+			if offset+j < len(item.Passthrough) {
+				rec[col] = item.Passthrough[offset+j]
+			}
+		}
+		out[key] = rec
+	}
+	return out
+}
+
+// apply sets opts.InputRecord to look up r, leaving opts unchanged when r is nil.
+func (r inputRecords) apply(inputRecord *func(string) map[string]string) {
+	if r == nil {
+		return
+	}
+	*inputRecord = func(email string) map[string]string {
+		return r[emailKey(email)]
+	}
+}
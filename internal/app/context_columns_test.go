@@ -0,0 +1,73 @@
+package app_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/enrich"
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+// recordEnricher records the context each email was enriched with.
+type recordEnricher struct {
+	testEnricher
+	mu      sync.Mutex
+	records map[string]map[string]string
+}
+
+func (r *recordEnricher) EnrichRecord(ctx context.Context, email string, record map[string]string) (enrich.Result, error) {
+	r.mu.Lock()
+	if r.records == nil {
+		r.records = map[string]map[string]string{}
+	}
+	r.records[email] = record
+	r.mu.Unlock()
+	return r.Enrich(ctx, email)
+}
+
+func TestRunLocal_ContextColumnsReachRecordEnricher(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.csv")
+	input := "customer_id,email,first_name,last_name\n" +
+		"c-1,alice@example.com,Alice,Smith\n" +
+		"c-2,bob@corp.test,Bob,\n" +
+		"c-3,alice@example.com,Alicia,Other\n"
+	if err := os.WriteFile(inputPath, []byte(input), 0644); err != nil {
+		t.Fatalf("write input csv: %v", err)
+	}
+
+	enricher := &recordEnricher{}
+	if _, err := app.RunLocalWithOptions(context.Background(), app.LocalOptions{
+		InputPath:          inputPath,
+		OutputPath:         filepath.Join(dir, "output.csv"),
+		PassthroughColumns: []string{"customer_id"},
+		ContextColumns:     []string{"first_name", "last_name"},
+	}, pipeline.Options{Workers: 2}, enricher); err != nil {
+		t.Fatalf("RunLocalWithOptions failed: %v", err)
+	}
+
+	// A duplicate email is enriched once, with the context of its first row.
+	want := map[string]map[string]string{
+		"alice@example.com": {"first_name": "Alice", "last_name": "Smith"},
+		"bob@corp.test":     {"first_name": "Bob", "last_name": ""},
+	}
+	if !reflect.DeepEqual(enricher.records, want) {
+		t.Fatalf("unexpected records:\nwant=%v\ngot=%v", want, enricher.records)
+	}
+
+	// A string-only enricher still runs with context columns configured.
+	if _, err := app.RunLocalWithOptions(context.Background(), app.LocalOptions{
+		InputPath:      inputPath,
+		OutputPath:     filepath.Join(dir, "output-plain.csv"),
+		ContextColumns: []string{"first_name"},
+	}, pipeline.Options{Workers: 1}, testEnricher{}); err != nil {
+		t.Fatalf("RunLocalWithOptions with a plain enricher failed: %v", err)
+	}
+}
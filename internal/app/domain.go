@@ -0,0 +1,87 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+)
+
+// emailDomain returns the lowercased domain of email (the part after the last "@"), or "" when
+// email has no domain.
+func emailDomain(email string) string {
+	email = strings.TrimSpace(email)
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(email[at+1:]))
+}
+
+// domainPolicy restricts enrichment by email domain. A listed domain also matches its subdomains.
+type domainPolicy struct {
+	allow []string
+	deny  []string
+}
+
+// newDomainPolicy normalizes the --enrich-domain-allow and --enrich-domain-deny lists.
+func newDomainPolicy(allow, deny []string) (domainPolicy, error) {
+	var p domainPolicy
+	var err error
+	if p.allow, err = normalizeDomains(allow); err != nil {
+		return domainPolicy{}, err
+	}
+	if p.deny, err = normalizeDomains(deny); err != nil {
+		return domainPolicy{}, err
+	}
+	return p, nil
+}
+
+func normalizeDomains(domains []string) ([]string, error) {
+	var out []string
+	for _, d := range domains {
+		norm := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@"))
+		if norm == "" || strings.ContainsAny(norm, "@ \t") || strings.HasPrefix(norm, ".") {
+			return nil, fmt.Errorf("invalid enrich domain %q (expected a domain such as example.com)", d)
+		}
+		out = append(out, norm)
+	}
+	return out, nil
+}
+
+// keep reports, per input email, whether its domain may be enriched: it must match the allow list
+// (when set) and must not match the deny list. It returns nil when neither list is set.
+func (p domainPolicy) keep(emails []string) []bool {
+	if len(p.allow) == 0 && len(p.deny) == 0 {
+		return nil
+	}
+	out := make([]bool, len(emails))
+	for i, email := range emails {
+		domain := emailDomain(email)
+		out[i] = (len(p.allow) == 0 || domainListed(p.allow, domain)) && !domainListed(p.deny, domain)
+	}
+	return out
+}
+
+func domainListed(list []string, domain string) bool {
+	for _, d := range list {
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// skipExcludedRows applies the domain lists and then the input filter to plan. A row both exclude
+// keeps skip_reason=domain. It returns the number of rows skipped.
+func skipExcludedRows(plan *incrementalPlan, emails []string, domainKeep, filterKeep []bool, logf func(format string, args ...any)) int {
+	byDomain := plan.skip(emails, domainKeep, pipeline.SkipReasonDomain)
+	if byDomain > 0 {
+		logf("enrich domain lists: skipped %d of %d input rows", byDomain, len(emails))
+	}
+	byFilter := plan.skip(emails, filterKeep, pipeline.SkipReasonFilter)
+	if byFilter > 0 {
+		logf("input filter: skipped %d of %d input rows", byFilter, len(emails))
+	}
+	return byDomain + byFilter
+}
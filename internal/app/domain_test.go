@@ -0,0 +1,140 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+func TestRunLocal_EnrichDomainLists(t *testing.T) {
+	t.Parallel()
+
+	const input = "email\nalice@corp.test\nbob@eu.corp.test\ncarol@gmail.com\ndave@other.test\n"
+	cases := []struct {
+		name  string
+		allow []string
+		deny  []string
+		want  map[string]string
+	}{
+		{
+			name:  "allow only",
+			allow: []string{"corp.test"},
+			want: map[string]string{
+				"alice@corp.test":  "ok",
+				"bob@eu.corp.test": "ok",
+				"carol@gmail.com":  "skipped:domain",
+				"dave@other.test":  "skipped:domain",
+			},
+		},
+		{
+			name: "deny only",
+			deny: []string{"@Gmail.com"},
+			want: map[string]string{
+				"alice@corp.test":  "ok",
+				"bob@eu.corp.test": "ok",
+				"carol@gmail.com":  "skipped:domain",
+				"dave@other.test":  "ok",
+			},
+		},
+		{
+			name:  "allow and deny",
+			allow: []string{"corp.test", "gmail.com"},
+			deny:  []string{"eu.corp.test", "gmail.com"},
+			want: map[string]string{
+				"alice@corp.test":  "ok",
+				"bob@eu.corp.test": "skipped:domain",
+				"carol@gmail.com":  "skipped:domain",
+				"dave@other.test":  "skipped:domain",
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			inputPath := filepath.Join(dir, "input.csv")
+			outputPath := filepath.Join(dir, "output.csv")
+			if err := os.WriteFile(inputPath, []byte(input), 0644); err != nil {
+				t.Fatalf("write input csv: %v", err)
+			}
+
+			enricher := &countingEnricher{}
+			if _, err := app.RunLocalWithOptions(context.Background(), app.LocalOptions{
+				InputPath:         inputPath,
+				OutputPath:        outputPath,
+				EnrichDomainAllow: tc.allow,
+				EnrichDomainDeny:  tc.deny,
+			}, pipeline.Options{}, enricher); err != nil {
+				t.Fatalf("RunLocalWithOptions failed: %v", err)
+			}
+
+			b, err := os.ReadFile(outputPath)
+			if err != nil {
+				t.Fatalf("read output: %v", err)
+			}
+			rows, err := pipeline.ReadCSV(bytes.NewReader(b))
+			if err != nil {
+				t.Fatalf("parse output csv: %v", err)
+			}
+			assertStatuses(t, rows, tc.want)
+			for email, status := range tc.want {
+				if status == "skipped:domain" && enricher.count(email) != 0 {
+					t.Fatalf("expected excluded %s not to be enriched, got %d calls", email, enricher.count(email))
+				}
+			}
+		})
+	}
+}
+
+func TestRunFoundry_EnrichDomainDenyTakesPrecedenceOverInputFilter(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email,region\nalice@corp.test,eu\nbob@gmail.com,us\ncarol@corp.test,us\n")
+	enricher := &countingEnricher{}
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:       "input",
+		OutputAlias:      "output",
+		OutputWriteMode:  "dataset",
+		InputFilter:      []string{"region=eu"},
+		EnrichDomainDeny: []string{"gmail.com"},
+	}, pipeline.Options{}, enricher); err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+
+	uploads := mock.Uploads()
+	if len(uploads) != 1 {
+		t.Fatalf("expected 1 upload, got %d", len(uploads))
+	}
+	rows, err := pipeline.ReadCSV(bytes.NewReader(uploads[0].Bytes))
+	if err != nil {
+		t.Fatalf("parse uploaded csv: %v", err)
+	}
+	assertStatuses(t, rows, map[string]string{
+		"alice@corp.test": "ok",
+		"bob@gmail.com":   "skipped:domain",
+		"carol@corp.test": "skipped:filter",
+	})
+	if got := enricher.count("bob@gmail.com"); got != 0 {
+		t.Fatalf("expected denied email not to be enriched, got %d calls", got)
+	}
+}
+
+func TestRunLocalWithOptions_InvalidEnrichDomainFails(t *testing.T) {
+	t.Parallel()
+
+	_, err := app.RunLocalWithOptions(context.Background(), app.LocalOptions{
+		InputPath:         filepath.Join(t.TempDir(), "missing.csv"),
+		OutputPath:        filepath.Join(t.TempDir(), "output.csv"),
+		EnrichDomainAllow: []string{"alice@corp.test"},
+	}, pipeline.Options{}, &countingEnricher{})
+	if err == nil || !strings.Contains(err.Error(), "invalid enrich domain") {
+		t.Fatalf("expected an invalid domain error, got %v", err)
+	}
+}
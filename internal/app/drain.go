@@ -0,0 +1,57 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+)
+
+// DefaultDrainTimeout is the --drain-timeout default: long enough to publish the few rows the
+// workers finished as the run was cancelled, short enough to exit within a typical shutdown grace
+// period.
+const DefaultDrainTimeout = 10 * time.Second
+
+// drainContext returns the context row publishes and audit records use. It outlives ctx by timeout,
+// so rows that completed before a cancellation (a shutdown signal, say) are still delivered instead
+// of failing on the cancelled run context. A timeout <= 0 returns ctx itself. stop releases the
+// context.
+func drainContext(ctx context.Context, timeout time.Duration) (drainCtx context.Context, stop context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	drainCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stopAfter := context.AfterFunc(ctx, func() {
+		time.AfterFunc(timeout, cancel)
+	})
+	return drainCtx, func() {
+		stopAfter()
+		cancel()
+	}
+}
+
+// cancelledRow reports whether row should be dropped instead of drained: once ctx is cancelled,
+// an error row failed by a context error is the in-flight enrichment the cancellation interrupted,
+// and a run that caches it would only have to re-enrich it. Completed partial, blocked, and skipped
+// rows are valid results and still drain.
+func cancelledRow(ctx context.Context, row pipeline.Row) bool {
+	if ctx.Err() == nil || !strings.EqualFold(strings.TrimSpace(row.Status), "error") {
+		return false
+	}
+	return strings.Contains(row.Error, context.Canceled.Error()) || strings.Contains(row.Error, context.DeadlineExceeded.Error())
+}
+
+// drainRows wraps fn so that rows dropped by cancelledRow never reach it. It returns nil when fn is
+// nil.
+func drainRows(ctx context.Context, fn func(pipeline.Row) error) func(pipeline.Row) error {
+	if fn == nil {
+		return nil
+	}
+	return func(row pipeline.Row) error {
+		if cancelledRow(ctx, row) {
+			return nil
+		}
+		return fn(row)
+	}
+}
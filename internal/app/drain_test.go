@@ -0,0 +1,87 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/enrich"
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+)
+
+// cancelAfterEnricher enriches like testEnricher, then cancels the run once it has enriched
+// cancelAfter, as a shutdown signal arriving mid-run would.
+type cancelAfterEnricher struct {
+	cancelAfter string
+	cancel      context.CancelFunc
+}
+
+func (e cancelAfterEnricher) Enrich(ctx context.Context, email string) (enrich.Result, error) {
+	res, err := testEnricher{}.Enrich(ctx, email)
+	if email == e.cancelAfter {
+		e.cancel()
+	}
+	return res, err
+}
+
+func TestRunFoundry_DrainsCompletedRowsWhenCancelled(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name            string
+		drainTimeout    time.Duration
+		minCompleteness float64
+		wantDrained     int
+		wantStatus      string
+	}{
+		{name: "drain", drainTimeout: time.Minute, wantDrained: 1, wantStatus: "ok"},
+		// testEnricher fills only company, so alice's completed row is partial and still drains.
+		{name: "drain partial", drainTimeout: time.Minute, minCompleteness: 0.5, wantDrained: 1, wantStatus: pipeline.StatusPartial},
+		{name: "no drain", drainTimeout: 0, wantDrained: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock, env := newMockFoundryEnv(t, "email\nalice@example.com\nbob@corp.test\n")
+			mock.CreateStream(testOutputRID)
+			auditRID := "ri.foundry.main.dataset.55555555-5555-5555-5555-555555555555"
+			mock.CreateStream(auditRID)
+			env.Aliases["audit"] = foundry.DatasetRef{RID: auditRID, Branch: "master"}
+			stats := &app.RunStats{}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			_, err := app.RunFoundryWithOptions(ctx, env, app.FoundryOptions{
+				InputAlias:   "input",
+				OutputAlias:  "output",
+				AuditSink:    "foundry-stream://audit",
+				AuditHashKey: testAuditHashKey,
+				Stats:        stats,
+				DrainTimeout: tc.drainTimeout,
+			}, pipeline.Options{Workers: 1, MinCompleteness: tc.minCompleteness}, cancelAfterEnricher{cancelAfter: "alice@example.com", cancel: cancel})
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("expected the run to fail with context.Canceled, got %v", err)
+			}
+
+			// bob is never enriched: the run stops dispatching at the cancellation.
+			recs := mock.StreamRecords(testOutputRID, "master")
+			if len(recs) != tc.wantDrained {
+				t.Fatalf("expected %d drained stream records, got %d: %#v", tc.wantDrained, len(recs), recs)
+			}
+			if tc.wantDrained > 0 && (recs[0]["email"] != "alice@example.com" || recs[0]["status"] != tc.wantStatus) {
+				t.Fatalf("expected alice's %s row to be drained, got %#v", tc.wantStatus, recs[0])
+			}
+			if audited := mock.StreamRecords(auditRID, "master"); len(audited) != tc.wantDrained {
+				t.Fatalf("expected %d drained audit records, got %d: %#v", tc.wantDrained, len(audited), audited)
+			}
+
+			snap := stats.Snapshot()
+			if snap.RowsProcessed != tc.wantDrained || snap.LastRun == nil || snap.LastRun.RecordsPublished != tc.wantDrained || snap.LastRun.Error == "" {
+				t.Fatalf("expected the failed run's metrics to cover the drained rows, got %#v (last=%#v)", snap, snap.LastRun)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/enrich/echo"
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+func TestRunFoundry_EchoBackendWritesDeterministicRows(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email\nalice@example.com\n Bob@Corp.Test \n")
+
+	if _, err := app.RunFoundry(context.Background(), env, "input", "output", "enriched.csv", "dataset", pipeline.Options{}, echo.New()); err != nil {
+		t.Fatalf("RunFoundry failed: %v", err)
+	}
+
+	uploads := mock.Uploads()
+	if len(uploads) != 1 {
+		t.Fatalf("expected 1 upload, got %d: %#v", len(uploads), uploads)
+	}
+	rows, err := pipeline.ReadCSV(bytes.NewReader(uploads[0].Bytes))
+	if err != nil {
+		t.Fatalf("parse uploaded csv: %v", err)
+	}
+
+	want := []pipeline.Row{
+		{
+			Email:        "alice@example.com",
+			Company:      "example.com",
+			Description:  "echo: alice@example.com",
+			Confidence:   "echo",
+			Status:       "ok",
+			Model:        "echo",
+			Completeness: "0.50",
+		},
+		{
+			Email:        "Bob@Corp.Test",
+			Company:      "corp.test",
+			Description:  "echo: Bob@Corp.Test",
+			Confidence:   "echo",
+			Status:       "ok",
+			Model:        "echo",
+			Completeness: "0.50",
+		},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("unexpected echo rows:\nwant=%#v\ngot=%#v", want, rows)
+	}
+}
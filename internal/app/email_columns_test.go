@@ -0,0 +1,166 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+const twoEmailColumnInput = "id,primary_email,secondary_email\n" +
+	"1,alice@example.com,alice@home.test\n" +
+	"2,bob@corp.test,\n" +
+	"3,carol@new.test,alice@example.com\n"
+
+// wantFannedOut lists (email, source_row) pairs expected for twoEmailColumnInput.
+var wantFannedOut = [][2]string{
+	{"alice@example.com", "0"},
+	{"alice@home.test", "0"},
+	{"bob@corp.test", "1"},
+	{"carol@new.test", "2"},
+	{"alice@example.com", "2"},
+}
+
+func assertFannedOutCSV(t *testing.T, b []byte) {
+	t.Helper()
+
+	records, err := csv.NewReader(bytes.NewReader(b)).ReadAll()
+	if err != nil {
+		t.Fatalf("parse output csv: %v", err)
+	}
+	wantHeader := append(pipeline.Header(), pipeline.SourceRowColumn)
+	if !slices.Equal(records[0], wantHeader) {
+		t.Fatalf("unexpected header:\nwant=%v\ngot=%v", wantHeader, records[0])
+	}
+	if len(records)-1 != len(wantFannedOut) {
+		t.Fatalf("expected %d output rows, got %d: %v", len(wantFannedOut), len(records)-1, records[1:])
+	}
+	sourceIdx := len(wantHeader) - 1
+	for i, want := range wantFannedOut {
+		rec := records[i+1]
+		if rec[0] != want[0] || rec[sourceIdx] != want[1] {
+			t.Fatalf("row[%d]: want email=%q source_row=%q, got email=%q source_row=%q", i, want[0], want[1], rec[0], rec[sourceIdx])
+		}
+		if rec[6] != "ok" {
+			t.Fatalf("row[%d]: expected ok status, got %q", i, rec[6])
+		}
+	}
+}
+
+func TestRunFoundry_EmailColumnsFanOutWithSourceRow(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, twoEmailColumnInput)
+	enricher := &countingEnricher{}
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+		EmailColumns:    []string{"primary_email", "secondary_email"},
+	}, pipeline.Options{}, enricher); err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+
+	if got := enricher.count("alice@example.com"); got != 1 {
+		t.Fatalf("expected duplicate alice to be enriched once, got %d calls", got)
+	}
+	uploads := mock.Uploads()
+	if len(uploads) != 1 {
+		t.Fatalf("expected 1 upload, got %d", len(uploads))
+	}
+	assertFannedOutCSV(t, uploads[0].Bytes)
+}
+
+func TestRunLocal_EmailColumnsFanOutWithSourceRow(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.csv")
+	outputPath := filepath.Join(dir, "output.csv")
+	if err := os.WriteFile(inputPath, []byte(twoEmailColumnInput), 0644); err != nil {
+		t.Fatalf("write input csv: %v", err)
+	}
+
+	enricher := &countingEnricher{}
+	if _, err := app.RunLocalWithOptions(context.Background(), app.LocalOptions{
+		InputPath:    inputPath,
+		OutputPath:   outputPath,
+		EmailColumns: []string{"primary_email", "secondary_email"},
+	}, pipeline.Options{}, enricher); err != nil {
+		t.Fatalf("RunLocalWithOptions failed: %v", err)
+	}
+
+	if got := enricher.count("alice@example.com"); got != 1 {
+		t.Fatalf("expected duplicate alice to be enriched once, got %d calls", got)
+	}
+	b, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	assertFannedOutCSV(t, b)
+}
+
+func TestRunFoundry_EmailColumn(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "id,Contact_Email\n1,alice@example.com\n2,bob@corp.test\n")
+	enricher := &countingEnricher{}
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+		EmailColumn:     "contact_email",
+	}, pipeline.Options{}, enricher); err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+	if enricher.count("alice@example.com") != 1 || enricher.count("bob@corp.test") != 1 {
+		t.Fatal("expected both contact_email values to be enriched")
+	}
+	uploads := mock.Uploads()
+	if len(uploads) != 1 {
+		t.Fatalf("expected 1 upload, got %d", len(uploads))
+	}
+	rows, err := pipeline.ReadCSV(bytes.NewReader(uploads[0].Bytes))
+	if err != nil {
+		t.Fatalf("parse output csv: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Email != "alice@example.com" || rows[1].Email != "bob@corp.test" {
+		t.Fatalf("unexpected rows: %#v", rows)
+	}
+}
+
+func TestRunLocal_EmailColumnValidation(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.csv")
+	if err := os.WriteFile(inputPath, []byte("id,email\n1,alice@example.com\n"), 0644); err != nil {
+		t.Fatalf("write input csv: %v", err)
+	}
+
+	_, err := app.RunLocalWithOptions(context.Background(), app.LocalOptions{
+		InputPath:    inputPath,
+		OutputPath:   filepath.Join(dir, "output.csv"),
+		EmailColumn:  "email",
+		EmailColumns: []string{"email"},
+	}, pipeline.Options{}, &countingEnricher{})
+	if app.ClassifyFailure(err) != app.FailureConfig {
+		t.Fatalf("expected a config error combining email column and columns, got %v", err)
+	}
+
+	_, err = app.RunLocalWithOptions(context.Background(), app.LocalOptions{
+		InputPath:   inputPath,
+		OutputPath:  filepath.Join(dir, "output.csv"),
+		EmailColumn: "contact_email",
+	}, pipeline.Options{}, &countingEnricher{})
+	if err == nil || !strings.Contains(err.Error(), `missing required column "contact_email"`) {
+		t.Fatalf("expected missing column error, got %v", err)
+	}
+}
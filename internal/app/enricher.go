@@ -1,16 +1,23 @@
 package app
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"maps"
 	"net"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/enrich"
@@ -18,11 +25,122 @@ import (
 	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
 	foundryio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/foundry"
 	localio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/local"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/output"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/schema"
+	"golang.org/x/sync/errgroup"
 )
 
 // RunLocal reads a local input CSV of emails and writes a local output CSV of enriched rows.
-func RunLocal(ctx context.Context, inputPath, outputPath string, opts pipeline.Options, enricher enrich.Enricher) error {
-	inF, err := os.Open(inputPath)
+func RunLocal(ctx context.Context, inputPath, outputPath string, opts pipeline.Options, enricher enrich.Enricher) (RunResult, error) {
+	return RunLocalWithOptions(ctx, LocalOptions{InputPath: inputPath, OutputPath: outputPath}, opts, enricher)
+}
+
+// LocalOptions configures local-mode runs beyond the worker settings in pipeline.Options.
+type LocalOptions struct {
+	InputPath string
+	// OutputPath is a local CSV path or a URI-like output value: "local-csv://<path>",
+	// "local-jsonl://<path>", or "stdout-ndjson://" (one JSON object per row on Stdout).
+	OutputPath string
+	// OutputFormat is the format of a plain OutputPath: OutputFormatCSV (the default) or
+	// OutputFormatJSONL, which writes it as local-jsonl.
+	OutputFormat string
+	// Stdout receives stdout-ndjson output. Nil uses os.Stdout.
+	Stdout io.Writer
+
+	// EmailColumns optionally names several input columns to read emails from. Each non-empty
+	// email becomes its own output row tagged with a source_row column. When empty, the single
+	// "email" column is read.
+	EmailColumns []string
+	// EmailColumn names the single input column emails are read from in place of "email" (matched
+	// case-insensitively), for inputs that call it, for example, "contact_email". Empty reads
+	// "email" or one of the CSVReadOptions email column aliases. It cannot be combined with EmailColumns.
+	EmailColumn string
+
+	// PassthroughColumns names input columns copied unchanged onto each output row (after
+	// source_row), so consumers can join output back to input, for example on customer_id.
+	PassthroughColumns []string
+
+	// ContextColumns names input columns passed with each email to an enrich.RecordEnricher, for
+	// example first_name and last_name to improve match confidence. The first input row of an
+	// email supplies its values. Enrichers that only implement Enrich ignore them.
+	ContextColumns []string
+
+	// InputFilter holds conditions (column=v1|v2 or column!=v1|v2, where column "domain" is the
+	// email's domain) that every enriched row must match. Other rows are written with
+	// status=skipped and skip_reason=filter and are not enriched.
+	InputFilter []string
+
+	// EnrichDomainAllow, when set, limits enrichment to emails in these domains (or their
+	// subdomains). EnrichDomainDeny excludes emails in its domains. Excluded rows are written with
+	// status=skipped and skip_reason=domain and are not enriched.
+	EnrichDomainAllow []string
+	EnrichDomainDeny  []string
+
+	// CaptureRawResponse adds the raw_response column to the output. The enricher must be
+	// configured to capture raw responses for the column to be populated.
+	CaptureRawResponse bool
+
+	// OmitAuditColumns writes the lean schema (pipeline.LeanHeader) without the model, sources,
+	// and web_search_queries columns. Use it when the enricher does not capture audit data.
+	OmitAuditColumns bool
+
+	// CSVReadOptions configures reading the input CSV: field and record size limits and email column
+	// aliases. Zero values use the localio defaults.
+	CSVReadOptions localio.CSVReadOptions
+
+	// AuditSink, when set, names a file ("<path>" or "file://<path>") that receives one redacted
+	// pipeline.AuditRecord per enriched email, appended as NDJSON.
+	AuditSink string
+
+	// AuditHashKey is the secret key audit records hash emails under (see redact.HashEmail). It is
+	// required with AuditSink.
+	AuditHashKey string
+
+	// FailOnAnyError fails the run, after its output is written, when any email enriched in it ended
+	// with status=error; partial, blocked and skipped rows do not count. FailOnErrorCount does the
+	// same once at least that many did (0 disables). The two cannot be combined; the error wraps
+	// ErrErrorCountExceeded.
+	FailOnAnyError   bool
+	FailOnErrorCount int
+}
+
+// RunLocalWithOptions runs the local-mode pipeline using LocalOptions.
+func RunLocalWithOptions(ctx context.Context, lopts LocalOptions, opts pipeline.Options, enricher enrich.Enricher) (RunResult, error) {
+	var res RunResult
+	start := time.Now()
+	warn := &warningCollector{logf: log.Printf}
+	err := runLocal(ctx, lopts, opts, enricher, warn, &res)
+	res.Warnings = warn.warnings()
+	if err == nil {
+		err = checkErrorCount(res.Metrics, lopts.FailOnAnyError, lopts.FailOnErrorCount)
+	}
+	res.Duration = time.Since(start)
+	return res, err
+}
+
+func runLocal(ctx context.Context, lopts LocalOptions, opts pipeline.Options, enricher enrich.Enricher, warn *warningCollector, res *RunResult) (err error) {
+	if err := validateErrorCount(lopts.FailOnAnyError, lopts.FailOnErrorCount, 0); err != nil {
+		return invalidConfig(err)
+	}
+	if err := validateEmailColumn(lopts.EmailColumn, lopts.EmailColumns); err != nil {
+		return invalidConfig(err)
+	}
+	if err := validatePassthroughColumns(lopts.PassthroughColumns, pipeline.StreamMeta{}); err != nil {
+		return invalidConfig(err)
+	}
+	filter, err := parseInputFilter(lopts.InputFilter)
+	if err != nil {
+		return invalidConfig(err)
+	}
+	domains, err := newDomainPolicy(lopts.EnrichDomainAllow, lopts.EnrichDomainDeny)
+	if err != nil {
+		return invalidConfig(err)
+	}
+	format, err := normalizeOutputFormat(lopts.OutputFormat)
+	if err != nil {
+		return invalidConfig(err)
+	}
+	inF, err := os.Open(lopts.InputPath)
 	if err != nil {
 		return err
 	}
@@ -30,28 +148,294 @@ func RunLocal(ctx context.Context, inputPath, outputPath string, opts pipeline.O
 		_ = inF.Close()
 	}()
 
-	emails, err := localio.ReadEmailsCSV(inF)
+	var emails []string
+	var sourceRows inputSourceRows
+	var passthrough inputPassthrough
+	var keep []bool
+	if readColumns := slices.Concat(lopts.PassthroughColumns, filter.columns(), lopts.ContextColumns); len(lopts.EmailColumns) > 0 || len(readColumns) > 0 {
+		items, err := readLocalEmailItems(inF, lopts.EmailColumn, lopts.EmailColumns, readColumns, lopts.CSVReadOptions)
+		if err != nil {
+			return err
+		}
+		recordsFromItems(items, lopts.ContextColumns, len(lopts.PassthroughColumns)+len(filter.columns())).apply(&opts.InputRecord)
+		emails, sourceRows = splitEmailItems(items)
+		if len(lopts.EmailColumns) == 0 {
+			sourceRows = nil
+		}
+		passthrough = passthroughFromItems(items, lopts.PassthroughColumns)
+		keep = filter.keep(emails, items, len(lopts.PassthroughColumns))
+	} else {
+		emails, err = localio.ReadColumnCSVWithOptions(inF, lopts.EmailColumn, lopts.CSVReadOptions)
+		if err != nil {
+			return err
+		}
+		keep = filter.keep(emails, nil, 0)
+	}
+
+	target := output.ParseTarget(lopts.OutputPath, localOutputScheme(format))
+	if target.Scheme == OutputLocalJSONL || target.Scheme == OutputStdoutNDJSON {
+		if err := validateJSONLOutput(opts.Schema, false, 0); err != nil {
+			return invalidConfig(err)
+		}
+	}
+	out, err := outputs(opts.Schema, outputExtraColumns(sourceRows, passthrough, lopts.CaptureRawResponse, opts.CaptureUsage, opts.CaptureCandidate, false, opts.ReenrichAfter != nil), lopts.OmitAuditColumns, lopts.Stdout).Open(target)
 	if err != nil {
 		return err
 	}
-
-	rows, err := pipeline.EnrichEmails(ctx, emails, enricher, opts)
+	if fo, ok := out.(foundryOutput); ok {
+		return invalidConfig(fmt.Errorf("%s output requires foundry mode", fo.scheme))
+	}
+	res.OutputMode = target.Scheme
+	audit, err := openAuditSink(lopts.AuditSink, lopts.AuditHashKey, nil)
 	if err != nil {
 		return err
 	}
+	defer closeAuditSink(audit, &err)
 
-	outF, err := os.Create(outputPath)
+	// Duplicate emails are enriched once and fanned back out to every input row.
+	plan := buildIncrementalPlan(emails, nil, 0)
+	skipped := skipExcludedRows(&plan, emails, domains.keep(emails), keep, func(string, ...any) {})
+	res.Plan = planSummary(plan, len(emails), skipped, len(plan.pendingEmails))
+	freshRows, err := pipeline.EnrichEmailsWithCallback(ctx, plan.pendingEmails, enricher, opts, auditRows(ctx, audit, "", lopts.AuditHashKey))
 	if err != nil {
 		return err
 	}
-	defer func() {
-		_ = outF.Close()
-	}()
-
-	if err := pipeline.WriteCSV(outF, rows); err != nil {
+	res.Metrics = enrichedMetrics(freshRows)
+	if err := plan.applyEnrichedRows(freshRows); err != nil {
 		return err
 	}
-	return outF.Close()
+	rows := plan.rows
+	sourceRows.tagRows(rows)
+	passthrough.tagRows(rows)
+	if err := out.Store(ctx, rows); err != nil {
+		return err
+	}
+	if target.Scheme == OutputLocalCSV || target.Scheme == OutputLocalJSONL {
+		res.OutputFile = target.Location
+	}
+	res.RowsWritten = len(rows)
+	return nil
+}
+
+// FoundryOptions configures Foundry pipeline-mode runs beyond the worker settings in pipeline.Options.
+type FoundryOptions struct {
+	InputAlias      string
+	OutputAlias     string
+	OutputFilename  string
+	OutputWriteMode string
+	// OutputFormat is the dataset output file format: OutputFormatCSV (the default) or
+	// OutputFormatJSONL. An empty OutputFilename becomes "enriched.<format>".
+	OutputFormat string
+
+	// Stats, when set, counts rows as they are enriched and records a summary of each finished run,
+	// for readers outside the run such as the keepalive stats function.
+	Stats *RunStats
+
+	// Output optionally selects the output as "foundry-dataset://<alias>" or
+	// "foundry-stream://<alias>". When set it takes precedence over OutputAlias and OutputWriteMode.
+	Output string
+
+	// IndexAlias optionally names a dataset alias used to persist a compact incremental index
+	// (email -> row hash, status) after each dataset-mode run. When the index is fresh for the
+	// current output head and no input rows need enrichment, the full readTable of the prior
+	// output is skipped.
+	IndexAlias string
+
+	// IncrementalBaseTxn pins the prior-output read that seeds the incremental cache to this
+	// committed output transaction RID instead of the branch head, for reproducible runs. It must
+	// exist, applies only to dataset output, and bypasses the IndexAlias shortcut (the index tracks
+	// the head).
+	IncrementalBaseTxn string
+
+	// RecacheEmptyOK treats a prior ok row with none of pipeline.CompletenessFields populated as a
+	// cache miss, so emails the model found nothing for are enriched again. It bypasses the
+	// IndexAlias shortcut (the index does not record which fields are filled).
+	RecacheEmptyOK bool
+
+	// RecacheOnSchemaChange treats every prior row as a cache miss when the prior dataset output's
+	// columns differ from the ones this run writes (for example after an upgrade added a column),
+	// so cached rows do not keep empty values for the new columns forever. The difference is logged
+	// either way. Dataset output only; it bypasses the IndexAlias shortcut.
+	RecacheOnSchemaChange bool
+
+	// MaxUniqueEnrich caps the number of distinct emails enriched in a single run (0 disables).
+	// OnBudgetExceeded selects what happens when the incremental plan exceeds it: "fail" (default)
+	// aborts before any enrichment, "truncate" enriches only the first MaxUniqueEnrich emails and
+	// defers the rest to a later run.
+	MaxUniqueEnrich  int
+	OnBudgetExceeded string
+
+	// MaxErrorRate fails a dataset-mode run when more than this fraction (0-1, 0 disables) of the
+	// emails enriched in the run end with status=error. OnThresholdFailure selects what happens to the output:
+	// "abort" (default) fails before the final write opens a transaction and aborts a pre-created
+	// OPEN one, so nothing is committed and none is left open; it cannot be combined with
+	// CommitEvery. "commit-anyway" writes the output and then fails the run.
+	MaxErrorRate       float64
+	OnThresholdFailure string
+
+	// FailOnAnyError and FailOnErrorCount fail the run by its count of status=error enriched emails once
+	// the output is written, in any output mode; see LocalOptions. Neither combines with MaxErrorRate.
+	FailOnAnyError   bool
+	FailOnErrorCount int
+
+	// StreamOutputAlias optionally names the stream alias used when OutputWriteMode is "both".
+	// When empty, OutputAlias is used for both the stream publish and the dataset write.
+	StreamOutputAlias string
+
+	// StreamPartitionKey names the record field used as the stream partition key, so records for
+	// the same key keep their order. Empty uses "email"; "none" publishes records unkeyed.
+	StreamPartitionKey string
+
+	// StreamDelivery selects stream publish semantics: "" or "at-least-once" (retries may store a
+	// record twice) or "exactly-once" (idempotency keys plus acknowledgement checks; requires a
+	// stack that deduplicates publishes by idempotency key).
+	StreamDelivery string
+
+	// PublishRetryPolicy sets the attempts and backoff of stream publishes (stream output, the stream
+	// half of both, and a stream audit sink), independently of the reads and dataset writes. It also
+	// chooses whether at-least-once publishes retry, and caps exactly-once retries to the stack's
+	// idempotency window. Zero fields use the foundryio defaults.
+	PublishRetryPolicy foundryio.PublishRetryPolicy
+
+	// StreamCacheMaxRecords caps the prior stream records read into the incremental cache in stream
+	// mode. Only the newest records up to the cap are kept, with a warning, and emails only found in
+	// older records are enriched again. Zero uses DefaultStreamCacheMaxRecords; negative reads every record.
+	StreamCacheMaxRecords int
+
+	// StreamMetaPrefix namespaces the run_id and written_at metadata fields (and control fields)
+	// stamped on stream records, for example "_meta_". Empty keeps the unprefixed names.
+	StreamMetaPrefix string
+
+	// StreamContract is the stream output's schema contract (see
+	// foundryio.ContractFromMetadataJSON; the CLI loads it from --stream-schema-file). When set,
+	// published records encode empty values of non-nullable fields as their type's zero value
+	// rather than null; nil encodes every empty value as null.
+	StreamContract *schema.DatasetContract
+
+	// RunID overrides the generated run id ("run-<unix nanos>") that stamps log lines, stream records,
+	// and audit records, so reruns of the same job (for example a restarted container) publish
+	// reproducible records. Empty generates one.
+	RunID string
+
+	// VerifyStreamWrites reads the stream back after publishing and logs a warning when fewer of the
+	// run's records (matched by run_id) are present than were published.
+	VerifyStreamWrites bool
+
+	// VerifyStreamWritesWait bounds how long verification polls an eventually consistent stream for
+	// missing records before warning. Zero verifies with a single read.
+	VerifyStreamWritesWait time.Duration
+
+	// EmailColumns optionally names several input columns to read emails from; see LocalOptions.
+	EmailColumns []string
+	// EmailColumn names the single input email column; see LocalOptions.
+	EmailColumn string
+
+	// PassthroughColumns copies input columns onto output rows and stream records; see LocalOptions.
+	PassthroughColumns []string
+
+	// ContextColumns passes input columns to the enricher with each email; see LocalOptions.
+	ContextColumns []string
+
+	// InputFilter excludes input rows from enrichment; see LocalOptions. Stream output omits
+	// filtered rows.
+	InputFilter []string
+
+	// EnrichDomainAllow and EnrichDomainDeny restrict enrichment by email domain; see LocalOptions.
+	// Stream output omits excluded rows.
+	EnrichDomainAllow []string
+	EnrichDomainDeny  []string
+
+	// CaptureRawResponse adds the raw_response column to dataset output; see LocalOptions.
+	CaptureRawResponse bool
+
+	// OmitAuditColumns drops the audit columns from dataset output and the audit fields from
+	// published stream records; see LocalOptions. Prior outputs of either schema are read back.
+	OmitAuditColumns bool
+
+	// CSVReadOptions configures reading the input CSV; see LocalOptions. Its size limits also apply
+	// to the prior output CSV.
+	CSVReadOptions localio.CSVReadOptions
+
+	// OutputSort orders dataset output rows before the write: "" or "none" keeps input order,
+	// "email" sorts by normalized email so repeated runs produce diff-friendly output.
+	OutputSort string
+
+	// WriteRetryPolicy bounds total attempts and elapsed time across the dataset output's
+	// create/upload/commit sequence. Zero fields use foundryio.DefaultWriteRetryPolicy. Its
+	// ReadOnlyErrorNames also apply to stream publishes.
+	WriteRetryPolicy foundryio.WriteRetryPolicy
+
+	// AllowInputOutputSame skips the check that the output (and stream output) do not resolve to
+	// the input dataset on the same branch, for deliberate in-place upserts.
+	AllowInputOutputSame bool
+
+	// ClientTimeouts sets the Foundry client's dial, TLS handshake, response header, and overall
+	// request timeouts. Zero fields keep foundry.DefaultTimeouts.
+	ClientTimeouts foundry.Timeouts
+
+	// PriorOutputNotFoundRetries re-reads the prior output (dataset or stream) after a not-found
+	// response before the incremental read concludes there is none, since a freshly committed output
+	// can briefly 404 on eventually consistent stacks. Reads are PriorOutputNotFoundBackoff apart,
+	// doubling each time. This is separate from transient-error retries. Zero uses
+	// DefaultPriorOutputNotFoundRetries and a negative value disables the re-reads, which spares a
+	// first run, whose output genuinely does not exist yet, the wait. A zero backoff uses
+	// DefaultPriorOutputNotFoundBackoff.
+	PriorOutputNotFoundRetries int
+	PriorOutputNotFoundBackoff time.Duration
+
+	// InputReadPolicy sets the attempts and per-attempt timeout of the input dataset read. It is
+	// independent of WriteRetryPolicy: read attempts never count against the write budget.
+	InputReadPolicy foundryio.ReadRetryPolicy
+
+	// ExtraInputAliases names further input datasets read alongside InputAlias. All inputs are read
+	// concurrently, each under InputReadPolicy, and merged in order keeping the first occurrence of
+	// each email. Only their email column is read, so they cannot be combined with EmailColumns,
+	// PassthroughColumns, or a column InputFilter.
+	ExtraInputAliases []string
+
+	// EnsureHeader treats an input dataset with no data (no committed view, or an empty table
+	// without a header) as zero rows instead of failing, so dataset output is still committed with
+	// its header row and establishes the schema.
+	EnsureHeader bool
+
+	// OutputChecksum uploads a "<output filename>.sha256" sidecar holding the hex SHA-256 of the
+	// dataset output, in the same transaction as the output itself.
+	OutputChecksum bool
+
+	// AuditSink, when set, receives one redacted pipeline.AuditRecord per enriched email: a file
+	// as in LocalOptions, or "foundry-stream://<alias>" to publish the records to a stream.
+	AuditSink string
+
+	// AuditHashKey is as in LocalOptions.
+	AuditHashKey string
+
+	// CommitEvery, when > 0, appends a checkpoint to the dataset output every CommitEvery enriched
+	// emails, in addition to the final write. Each checkpoint is an APPEND transaction holding the
+	// rows enriched since the previous one; the final SNAPSHOT write replaces them. A restarted run
+	// resumes from the checkpoints through the incremental cache. Dataset and both modes only.
+	CommitEvery int
+
+	// CleanupOpenTransactions aborts stale OPEN transactions on the output branch before the run,
+	// keeping only the newest for the write to reuse, so dangling transactions from crashed runs do
+	// not pile up. At most CleanupOpenTransactionsMax are aborted per run (zero uses
+	// DefaultCleanupOpenTransactionsMax). Dataset and both modes only.
+	CleanupOpenTransactions    bool
+	CleanupOpenTransactionsMax int
+
+	// TagOutput creates a branch named after the run id on the output dataset, pointing at the
+	// final committed output transaction, so downstream consumers can pin that version. Checkpoints
+	// are not tagged, and a tagging failure is a warning. Dataset and both modes only.
+	TagOutput bool
+
+	// DrainTimeout keeps publishing stream rows and recording audit records for up to this long
+	// after ctx is cancelled, so rows the workers completed before the cancellation are not lost.
+	// Rows that failed once ctx was cancelled are dropped. Zero stops both at the cancellation.
+	DrainTimeout time.Duration
+
+	// InputFile reads the input from this local CSV instead of the InputAlias dataset, while the
+	// output is still written to Foundry, so output-side bugs can be reproduced with a fixed input.
+	// InputAlias is then ignored. It cannot be combined with ExtraInputAliases.
+	InputFile string
 }
 
 // RunFoundry runs the pipeline-mode orchestration against the minimal dataset API surface.
@@ -64,9 +448,138 @@ func RunFoundry(
 	outputWriteMode string,
 	opts pipeline.Options,
 	enricher enrich.Enricher,
-) error {
+) (RunResult, error) {
+	return RunFoundryWithOptions(ctx, env, FoundryOptions{
+		InputAlias:      inputAlias,
+		OutputAlias:     outputAlias,
+		OutputFilename:  outputFilename,
+		OutputWriteMode: outputWriteMode,
+	}, opts, enricher)
+}
+
+// RunFoundryWithOptions runs the pipeline-mode orchestration using FoundryOptions.
+func RunFoundryWithOptions(
+	ctx context.Context,
+	env foundry.Env,
+	fopts FoundryOptions,
+	opts pipeline.Options,
+	enricher enrich.Enricher,
+) (RunResult, error) {
+	var res RunResult
+	start := time.Now()
+	fopts.Stats.start()
+	err := runFoundry(ctx, env, fopts, opts, enricher, &res)
+	if err == nil {
+		err = checkErrorCount(res.Metrics, fopts.FailOnAnyError, fopts.FailOnErrorCount)
+	}
+	res.Duration = time.Since(start)
+	fopts.Stats.finish(res, err, time.Now())
+	return res, err
+}
+
+func runFoundry(
+	ctx context.Context,
+	env foundry.Env,
+	fopts FoundryOptions,
+	opts pipeline.Options,
+	enricher enrich.Enricher,
+	res *RunResult,
+) (err error) {
+	inputAlias := fopts.InputAlias
+	outputAlias := fopts.OutputAlias
+	outputFilename := fopts.OutputFilename
+	outputWriteMode := fopts.OutputWriteMode
+	if strings.TrimSpace(fopts.Output) != "" {
+		alias, mode, err := resolveFoundryOutput(fopts.Output)
+		if err != nil {
+			return invalidConfig(err)
+		}
+		outputAlias, outputWriteMode = alias, mode
+	}
+
+	budgetMode, err := normalizeBudgetExceededMode(fopts.OnBudgetExceeded)
+	if err != nil {
+		return invalidConfig(err)
+	}
+	if err := validateMaxErrorRate(fopts.MaxErrorRate); err != nil {
+		return invalidConfig(err)
+	}
+	if err := validateEmailColumn(fopts.EmailColumn, fopts.EmailColumns); err != nil {
+		return invalidConfig(err)
+	}
+	if err := validateErrorCount(fopts.FailOnAnyError, fopts.FailOnErrorCount, fopts.MaxErrorRate); err != nil {
+		return invalidConfig(err)
+	}
+	thresholdMode, err := normalizeThresholdFailureMode(fopts.OnThresholdFailure)
+	if err != nil {
+		return invalidConfig(err)
+	}
+	if fopts.MaxErrorRate > 0 && thresholdMode == thresholdFailureAbort && fopts.CommitEvery > 0 {
+		// Checkpoints are committed before the rate is known, so abort could not keep them out.
+		return invalidConfig(fmt.Errorf("max-error-rate with on-threshold-failure=abort cannot be combined with commit-every"))
+	}
+	outputSort, err := normalizeOutputSort(fopts.OutputSort)
+	if err != nil {
+		return invalidConfig(err)
+	}
+	streamDelivery, err := normalizeStreamDelivery(fopts.StreamDelivery)
+	if err != nil {
+		return invalidConfig(err)
+	}
+	streamMeta, err := parseStreamMetaPrefix(fopts.StreamMetaPrefix)
+	if err != nil {
+		return invalidConfig(err)
+	}
+	if err := validatePassthroughColumns(fopts.PassthroughColumns, streamMeta); err != nil {
+		return invalidConfig(err)
+	}
+	filter, err := parseInputFilter(fopts.InputFilter)
+	if err != nil {
+		return invalidConfig(err)
+	}
+	domains, err := newDomainPolicy(fopts.EnrichDomainAllow, fopts.EnrichDomainDeny)
+	if err != nil {
+		return invalidConfig(err)
+	}
+	if err := validateCommitEvery(fopts.CommitEvery); err != nil {
+		return invalidConfig(err)
+	}
+	if err := validateReenrichAfter(opts.ReenrichAfter); err != nil {
+		return invalidConfig(err)
+	}
+	outputFormat, err := normalizeOutputFormat(fopts.OutputFormat)
+	if err != nil {
+		return invalidConfig(err)
+	}
+	if outputFormat == OutputFormatJSONL {
+		if err := validateJSONLOutput(opts.Schema, fopts.RecacheOnSchemaChange, fopts.CommitEvery); err != nil {
+			return invalidConfig(err)
+		}
+	}
+	if fopts.CleanupOpenTransactionsMax < 0 {
+		return invalidConfig(fmt.Errorf("cleanup-open-transactions-max must be >= 0, got %d", fopts.CleanupOpenTransactionsMax))
+	}
+	if fopts.DrainTimeout < 0 {
+		return invalidConfig(fmt.Errorf("drain-timeout must be >= 0, got %s", fopts.DrainTimeout))
+	}
+	inputFile := strings.TrimSpace(fopts.InputFile)
+	if inputFile != "" && len(fopts.ExtraInputAliases) > 0 {
+		return invalidConfig(fmt.Errorf("an input file replaces the input dataset and cannot be combined with extra input aliases"))
+	}
+	if len(fopts.ExtraInputAliases) > 0 && (len(fopts.EmailColumns) > 0 || len(fopts.PassthroughColumns) > 0 || len(fopts.ContextColumns) > 0 || len(filter.columns()) > 0) {
+		return invalidConfig(fmt.Errorf("extra input aliases read only the email column and cannot be combined with email columns, passthrough columns, context columns, or a column input filter"))
+	}
+	if fopts.CommitEvery > 0 && strings.TrimSpace(fopts.IncrementalBaseTxn) != "" {
+		// A restart would read the pinned base transaction again, not the checkpoints.
+		return invalidConfig(fmt.Errorf("commit-every cannot be combined with an incremental base transaction"))
+	}
+
 	logger := log.New(os.Stdout, "", log.LstdFlags)
-	runID := fmt.Sprintf("run-%d", time.Now().UnixNano())
+	runID := strings.TrimSpace(fopts.RunID)
+	if runID == "" {
+		runID = fmt.Sprintf("run-%d", time.Now().UnixNano())
+	}
+	res.RunID = runID
 	logf := func(format string, args ...any) {
 		prefix := make([]any, 0, len(args)+1)
 		prefix = append(prefix, runID)
@@ -74,14 +587,26 @@ func RunFoundry(
 		logger.Printf("run=%s "+format, prefix...)
 	}
 	runStart := time.Now()
+	// attempt tells this attempt's checkpoint files apart from earlier attempts under a fixed RunID.
+	attempt := strconv.FormatInt(runStart.UnixNano(), 36)
+	opts.OnRetry = logRetries(opts.OnRetry, logf)
+	warn := &warningCollector{logf: logf}
+	defer func() { res.Warnings = warn.warnings() }()
 
-	inputRef, ok := env.Aliases[inputAlias]
-	if !ok {
-		return fmt.Errorf("missing alias %q in RESOURCE_ALIAS_MAP", inputAlias)
+	var inputRef foundry.DatasetRef
+	if inputFile == "" {
+		var ok bool
+		if inputRef, ok = env.Aliases[inputAlias]; !ok {
+			return invalidConfig(fmt.Errorf("missing alias %q in RESOURCE_ALIAS_MAP", inputAlias))
+		}
 	}
 	outputRef, ok := env.Aliases[outputAlias]
 	if !ok {
-		return fmt.Errorf("missing alias %q in RESOURCE_ALIAS_MAP", outputAlias)
+		return invalidConfig(fmt.Errorf("missing alias %q in RESOURCE_ALIAS_MAP", outputAlias))
+	}
+	if fopts.CommitEvery > 0 && strings.EqualFold(strings.TrimSpace(outputRef.TransactionType), foundry.TransactionTypeAppend) {
+		// The final write would append every row on top of the checkpoints instead of replacing them.
+		return invalidConfig(fmt.Errorf("commit-every requires SNAPSHOT output transactions, but the output uses %s", foundry.TransactionTypeAppend))
 	}
 	inputBranch := strings.TrimSpace(inputRef.Branch)
 	if inputBranch == "" {
@@ -91,10 +616,87 @@ func RunFoundry(
 	if outputBranch == "" {
 		outputBranch = "master"
 	}
+	streamRef := outputRef
+	if alias := strings.TrimSpace(fopts.StreamOutputAlias); alias != "" {
+		streamRef, ok = env.Aliases[alias]
+		if !ok {
+			return invalidConfig(fmt.Errorf("missing alias %q in RESOURCE_ALIAS_MAP", alias))
+		}
+	}
+	extraInputs := make([]namedInput, 0, len(fopts.ExtraInputAliases))
+	for _, alias := range fopts.ExtraInputAliases {
+		ref, ok := env.Aliases[alias]
+		if !ok {
+			return invalidConfig(fmt.Errorf("missing alias %q in RESOURCE_ALIAS_MAP", alias))
+		}
+		extraInputs = append(extraInputs, namedInput{alias: alias, ref: ref})
+	}
+	var indexRef foundry.DatasetRef
+	useIndex := strings.TrimSpace(fopts.IndexAlias) != ""
+	if useIndex {
+		indexRef, ok = env.Aliases[fopts.IndexAlias]
+		if !ok {
+			return invalidConfig(fmt.Errorf("missing alias %q in RESOURCE_ALIAS_MAP", fopts.IndexAlias))
+		}
+	}
+
+	client, err := foundry.NewClient(env.Services.APIGateway, env.Services.StreamProxy, env.Token, env.DefaultCAPath)
+	if err != nil {
+		return err
+	}
+	client = client.WithTimeouts(fopts.ClientTimeouts).WithLogger(logger).WithWarningHandler(func(msg string) {
+		warn.warnf(WarningFoundryClient, "%s", msg)
+	})
+	if env.TokenPath != "" {
+		client = client.WithTokenFile(env.TokenPath, foundry.DefaultTokenRefreshInterval)
+	}
+	// Path aliases resolve now that a client exists; an unresolvable path fails before any spend.
+	if inputFile == "" {
+		if inputRef, err = resolveDatasetRef(ctx, client, inputAlias, inputRef); err != nil {
+			return err
+		}
+	}
+	for i := range extraInputs {
+		if extraInputs[i].ref, err = resolveDatasetRef(ctx, client, extraInputs[i].alias, extraInputs[i].ref); err != nil {
+			return err
+		}
+	}
+	if outputRef, err = resolveDatasetRef(ctx, client, outputAlias, outputRef); err != nil {
+		return err
+	}
+	if alias := strings.TrimSpace(fopts.StreamOutputAlias); alias == "" {
+		streamRef = outputRef
+	} else if streamRef, err = resolveDatasetRef(ctx, client, alias, streamRef); err != nil {
+		return err
+	}
+	if useIndex {
+		if indexRef, err = resolveDatasetRef(ctx, client, fopts.IndexAlias, indexRef); err != nil {
+			return err
+		}
+	}
+	if !fopts.AllowInputOutputSame {
+		inputs := extraInputs
+		if inputFile == "" {
+			inputs = append([]namedInput{{alias: inputAlias, ref: inputRef}}, extraInputs...)
+		}
+		for _, in := range inputs {
+			if err := checkOutputNotInput(in.ref, outputAlias, outputRef); err != nil {
+				return err
+			}
+			if alias := strings.TrimSpace(fopts.StreamOutputAlias); alias != "" {
+				if err := checkOutputNotInput(in.ref, alias, streamRef); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	inputDesc := inputRef.RID + "@" + inputBranch
+	if inputFile != "" {
+		inputDesc = "file:" + inputFile
+	}
 	logf(
-		"foundry run start: input=%s@%s output=%s@%s writeMode=%s workers=%d maxRetries=%d timeout=%s rateLimitRPS=%g failFast=%t",
-		inputRef.RID,
-		inputBranch,
+		"foundry run start: input=%s output=%s@%s writeMode=%s workers=%d maxRetries=%d timeout=%s rateLimitRPS=%g failFast=%t",
+		inputDesc,
 		outputRef.RID,
 		outputBranch,
 		outputWriteMode,
@@ -105,40 +707,173 @@ func RunFoundry(
 		opts.FailFast,
 	)
 	if outputFilename == "" {
-		outputFilename = "enriched.csv"
+		outputFilename = "enriched." + outputFormat
 	}
 
-	client, err := foundry.NewClient(env.Services.APIGateway, env.Services.StreamProxy, env.Token, env.DefaultCAPath)
+	streamBackend := foundryio.NewLegacyStreamProxyBackend(client).
+		WithPartitionKey(streamPartitionKey(fopts.StreamPartitionKey)).
+		WithRetryPolicy(foundryio.RetryPolicy{ReadOnlyErrorNames: fopts.WriteRetryPolicy.ReadOnlyErrorNames}).
+		WithPublishRetryPolicy(fopts.PublishRetryPolicy)
+	if streamDelivery == foundryio.StreamDeliveryExactlyOnce {
+		streamBackend = streamBackend.WithExactlyOnce()
+	}
+	audit, err := openAuditSink(fopts.AuditSink, fopts.AuditHashKey, func(alias string) (pipeline.AuditSink, error) {
+		ref, ok := env.Aliases[alias]
+		if !ok {
+			return nil, invalidConfig(fmt.Errorf("missing alias %q in RESOURCE_ALIAS_MAP", alias))
+		}
+		ref, err := resolveDatasetRef(ctx, client, alias, ref)
+		if err != nil {
+			return nil, err
+		}
+		backend := foundryio.NewLegacyStreamProxyBackend(client).
+			WithPartitionKey(foundryio.PartitionKeyFromField("email_hmac")).
+			WithRetryPolicy(foundryio.RetryPolicy{ReadOnlyErrorNames: fopts.WriteRetryPolicy.ReadOnlyErrorNames}).
+			WithPublishRetryPolicy(fopts.PublishRetryPolicy)
+		return streamAuditSink{backend: backend, ref: ref}, nil
+	})
 	if err != nil {
 		return err
 	}
-	streamBackend := foundryio.NewLegacyStreamProxyBackend(client)
+	defer closeAuditSink(audit, &err)
+	// Stream publishes and audit records drain past a cancellation (see FoundryOptions.DrainTimeout).
+	drainCtx, stopDrain := drainContext(ctx, fopts.DrainTimeout)
+	defer stopDrain()
+	// auditRow also counts rows into fopts.Stats; it is nil when neither is set.
+	auditRow := chainRowCallbacks(drainRows(ctx, auditRows(drainCtx, audit, runID, fopts.AuditHashKey)), fopts.Stats.rowCallback(), logPartialRows(opts, logf))
 
-	readStart := time.Now()
-	emails, err := foundryio.ReadInputEmails(ctx, client, inputRef)
-	if err != nil {
+	// Reading the input and resolving the output mode are independent, so overlap them to cut
+	// cold-start latency on slow stacks. The first error cancels the other step.
+	var emails []string
+	var sourceRows inputSourceRows
+	var passthrough inputPassthrough
+	var keep []bool
+	isBoth := strings.EqualFold(strings.TrimSpace(outputWriteMode), foundryio.OutputModeBoth)
+	isStream := false
+	startup, startupCtx := errgroup.WithContext(ctx)
+	startup.Go(func() error {
+		readStart := time.Now()
+		if len(extraInputs) > 0 {
+			inputs := append([]namedInput{{alias: inputAlias, ref: inputRef}}, extraInputs...)
+			var err error
+			emails, err = readMergedInputEmails(startupCtx, client, inputs, fopts.EmailColumn, fopts.CSVReadOptions, fopts.InputReadPolicy, fopts.EnsureHeader, logf)
+			if err != nil {
+				return err
+			}
+			keep = filter.keep(emails, nil, 0)
+		} else if readColumns := slices.Concat(fopts.PassthroughColumns, filter.columns(), fopts.ContextColumns); len(fopts.EmailColumns) > 0 || len(readColumns) > 0 {
+			read := func() ([]localio.EmailItem, error) {
+				if inputFile != "" {
+					return readInputFileItems(inputFile, fopts.EmailColumn, fopts.EmailColumns, readColumns, fopts.CSVReadOptions)
+				}
+				if len(fopts.EmailColumns) > 0 {
+					return foundryio.ReadInputEmailItemsWithPolicy(startupCtx, client, inputRef, fopts.EmailColumns, readColumns, fopts.CSVReadOptions, fopts.InputReadPolicy)
+				}
+				return foundryio.ReadInputColumnItemsWithPolicy(startupCtx, client, inputRef, fopts.EmailColumn, readColumns, fopts.CSVReadOptions, fopts.InputReadPolicy)
+			}
+			items, err := read()
+			if err != nil {
+				if !fopts.EnsureHeader || !isEmptyInputError(err) {
+					return err
+				}
+				logf("input dataset has no data; writing a header-only output: %s", err)
+			}
+			emails, sourceRows = splitEmailItems(items)
+			if len(fopts.EmailColumns) == 0 {
+				sourceRows = nil
+			}
+			passthrough = passthroughFromItems(items, fopts.PassthroughColumns)
+			keep = filter.keep(emails, items, len(fopts.PassthroughColumns))
+			recordsFromItems(items, fopts.ContextColumns, len(fopts.PassthroughColumns)+len(filter.columns())).apply(&opts.InputRecord)
+		} else {
+			var err error
+			if inputFile != "" {
+				emails, err = readInputFileColumn(inputFile, fopts.EmailColumn, fopts.CSVReadOptions)
+			} else {
+				emails, err = foundryio.ReadInputColumnWithPolicy(startupCtx, client, inputRef, fopts.EmailColumn, fopts.CSVReadOptions, fopts.InputReadPolicy)
+			}
+			if err != nil {
+				if !fopts.EnsureHeader || !isEmptyInputError(err) {
+					return err
+				}
+				logf("input dataset has no data; writing a header-only output: %s", err)
+			}
+			keep = filter.keep(emails, nil, 0)
+		}
+		source := "input dataset"
+		if inputFile != "" {
+			source = "input file"
+		}
+		logf("loaded %d emails from %s in %s", len(emails), source, time.Since(readStart).Round(time.Millisecond))
+		return nil
+	})
+	startup.Go(func() error {
+		modeStart := time.Now()
+		if !isBoth {
+			var err error
+			isStream, err = foundryio.ResolveOutputModeWithBackend(startupCtx, streamBackend, outputRef, outputWriteMode)
+			if err != nil {
+				return err
+			}
+		}
+		mode := "dataset"
+		if isStream {
+			mode = "stream"
+		}
+		if isBoth {
+			mode = foundryio.OutputModeBoth
+		}
+		logf("resolved output mode=%s in %s", mode, time.Since(modeStart).Round(time.Millisecond))
+		res.OutputMode = mode
+		return nil
+	})
+	if err := startup.Wait(); err != nil {
 		return err
 	}
-	logf("loaded %d emails from input dataset in %s", len(emails), time.Since(readStart).Round(time.Millisecond))
+	tagSourceRow := sourceRows.streamTagger(emails)
+	tagPassthrough := passthrough.streamTagger(emails)
+	tagStreamRow := func(row pipeline.Row) pipeline.Row { return tagPassthrough(tagSourceRow(row)) }
+	// Stream records carry the source row, passthrough and partial_attempts columns, but not the
+	// dataset-only raw_response, token usage, candidate and written_at columns.
+	streamExtraColumns := outputExtraColumns(sourceRows, passthrough, false, false, false, opts.MinCompleteness > 0, false)
 
-	modeStart := time.Now()
-	isStream, err := foundryio.ResolveOutputModeWithBackend(ctx, streamBackend, outputRef, outputWriteMode)
-	if err != nil {
-		return err
+	baseTxn := strings.TrimSpace(fopts.IncrementalBaseTxn)
+	if isStream && baseTxn != "" {
+		return invalidConfig(fmt.Errorf("incremental base transaction %s applies only to dataset output, but output mode is stream", baseTxn))
 	}
-	mode := "dataset"
-	if isStream {
-		mode = "stream"
+	if isStream && fopts.CommitEvery > 0 {
+		return invalidConfig(fmt.Errorf("commit-every applies only to dataset output, but output mode is stream"))
+	}
+	if isStream && fopts.RecacheOnSchemaChange {
+		return invalidConfig(fmt.Errorf("recache-on-schema-change applies only to dataset output, but output mode is stream"))
+	}
+	if isStream && fopts.MaxErrorRate > 0 {
+		return invalidConfig(fmt.Errorf("max-error-rate applies only to dataset output, but output mode is stream"))
+	}
+	if isStream && fopts.CleanupOpenTransactions {
+		return invalidConfig(fmt.Errorf("cleanup-open-transactions applies only to dataset output, but output mode is stream"))
+	}
+	if isStream && fopts.TagOutput {
+		return invalidConfig(fmt.Errorf("tag-output applies only to dataset output, but output mode is stream"))
+	}
+	if isStream && outputFormat != OutputFormatCSV {
+		return invalidConfig(fmt.Errorf("output format %s applies only to dataset output, but output mode is stream", outputFormat))
+	}
+	if fopts.CleanupOpenTransactions {
+		cleanupOpenTransactions(ctx, client, outputRef, cleanupOpenTransactionsMax(fopts.CleanupOpenTransactionsMax), warn)
 	}
-	logf("resolved output mode=%s in %s", mode, time.Since(modeStart).Round(time.Millisecond))
 
 	enrichStart := time.Now()
 	if isStream {
-		existingByEmail, err := readExistingStreamRows(ctx, streamBackend, outputRef, logger, runID)
+		existingByEmail, err := readExistingStreamRows(ctx, streamBackend, outputRef, streamCacheMaxRecords(fopts.StreamCacheMaxRecords), priorOutputNotFoundRetry(fopts), streamMeta, logger, runID, warn)
 		if err != nil {
 			return err
 		}
-		plan := buildIncrementalPlan(emails, existingByEmail)
+		recacheEmptyOKRows(existingByEmail, fopts.RecacheEmptyOK, logf)
+		reenrichStaleRows(existingByEmail, opts.ReenrichAfter, logf)
+		plan := buildIncrementalPlan(emails, existingByEmail, partialAttemptsLimit(opts.MaxPartialAttempts))
+		opts = withPriorPartialAttempts(opts, existingByEmail)
+		skipped := skipExcludedRows(&plan, emails, domains.keep(emails), keep, logf)
 		logf(
 			"incremental plan (stream): inputRows=%d cachedRows=%d rowsToEnrich=%d uniqueEmailsToEnrich=%d",
 			len(emails),
@@ -146,14 +881,23 @@ func RunFoundry(
 			plan.pendingRows,
 			len(plan.pendingEmails),
 		)
+		unique := len(plan.pendingEmails)
+		if err := enforceEnrichBudget(&plan, fopts.MaxUniqueEnrich, budgetMode, logf); err != nil {
+			return err
+		}
+		res.Plan = planSummary(plan, len(emails), skipped, unique)
 
 		if len(plan.pendingEmails) == 0 {
+			res.UpToDate = true
 			logf(
 				"foundry run complete: stream output is up-to-date (no rows to enrich) totalDuration=%s",
 				time.Since(runStart).Round(time.Millisecond),
 			)
 			return nil
 		}
+		if err := preflightStreamWrite(ctx, streamBackend, outputRef, logf, warn); err != nil {
+			return err
+		}
 
 		writeStart := time.Now()
 		logf("publishing rows to stream-proxy (%s@%s)", outputRef.RID, outputBranch)
@@ -162,13 +906,17 @@ func RunFoundry(
 		publishedRows := 0
 		okRows := 0
 		errorRows := 0
-		err = pipeline.EnrichEmailsStream(ctx, plan.pendingEmails, newTracedEnricher(enricher, logger, runID, opts), opts, func(row pipeline.Row) error {
-			processedRows++
-			if strings.EqualFold(strings.TrimSpace(row.Status), "ok") {
-				okRows++
-			} else {
-				errorRows++
+		traced := newTracedEnricher(enricher, logger, runID, opts)
+		err = pipeline.EnrichEmailsStream(ctx, plan.pendingEmails, traced, opts, func(row pipeline.Row) error {
+			if auditRow != nil {
+				if err := auditRow(row); err != nil {
+					return err
+				}
 			}
+			processedRows++
+			rowOK, rowErrors := countStatuses([]pipeline.Row{row})
+			okRows += rowOK
+			errorRows += rowErrors
 
 			logf(
 				"stream row enriched: email=%q status=%q completed=%d/%d enrichElapsed=%s",
@@ -179,17 +927,17 @@ func RunFoundry(
 				time.Since(enrichStart).Round(time.Millisecond),
 			)
 
-			writtenAt := time.Now().UTC().Format(time.RFC3339Nano)
-			rec := pipeline.RowToStreamRecord(row)
-			rec["run_id"] = runID
-			rec["written_at"] = writtenAt
-
+			if cancelledRow(ctx, row) {
+				return nil
+			}
 			publishStart := time.Now()
-			if err := streamBackend.PublishRecord(ctx, outputRef, rec); err != nil {
+			writtenAt, err := publishStreamRow(drainCtx, streamBackend, outputRef, streamMeta, runID, tagStreamRow(row), streamExtraColumns, fopts.StreamContract, fopts.OmitAuditColumns)
+			if err != nil {
 				return err
 			}
 
 			publishedRows++
+			res.RecordsPublished = publishedRows
 			logf(
 				"stream row published: email=%q status=%q writtenAt=%q publishDuration=%s published=%d/%d",
 				row.Email,
@@ -201,6 +949,7 @@ func RunFoundry(
 			)
 			return nil
 		})
+		res.Metrics = traced.metrics(processedRows, okRows, errorRows)
 		if err != nil {
 			return err
 		}
@@ -211,6 +960,10 @@ func RunFoundry(
 			errorRows,
 			time.Since(enrichStart).Round(time.Millisecond),
 		)
+		traced.logUsage(logf)
+		if fopts.VerifyStreamWrites {
+			verifyStreamWrites(ctx, streamBackend, outputRef, streamMeta, runID, runStart, publishedRows, fopts.VerifyStreamWritesWait, warn)
+		}
 		logf(
 			"foundry run complete: stream publish finished writeDuration=%s totalDuration=%s",
 			time.Since(writeStart).Round(time.Millisecond),
@@ -219,11 +972,45 @@ func RunFoundry(
 		return nil
 	}
 
-	existingByEmail, err := readExistingOutputRows(ctx, client, outputRef, logger, runID)
+	digest := ""
+	if useIndex {
+		digest = inputDigest(emails, domains.keep(emails), keep, sourceRows, passthrough)
+	}
+	if useIndex && baseTxn == "" && !fopts.RecacheEmptyOK && !fopts.RecacheOnSchemaChange && opts.ReenrichAfter == nil && indexShowsOutputUpToDate(ctx, client, outputRef, indexRef, emails, digest, logger, runID) {
+		res.Plan = PlanSummary{InputRows: len(emails), CachedRows: len(emails)}
+		res.UpToDate = true
+		logf(
+			"foundry run complete: dataset output is up-to-date per incremental index (no rows to enrich) totalDuration=%s",
+			time.Since(runStart).Round(time.Millisecond),
+		)
+		return nil
+	}
+
+	priorSchema := opts.Schema
+	if opts.MinCompleteness > 0 {
+		// Read each cached partial row's attempt count back so the next attempt continues it.
+		priorSchema = priorSchema.WithColumns(pipeline.ColumnSpec{Name: pipeline.PartialAttemptsColumn, Optional: true})
+	}
+	if opts.ReenrichAfter != nil {
+		// Read each cached row's timestamp back so rewrites keep it.
+		priorSchema = priorSchema.WithColumns(pipeline.ColumnSpec{Name: pipeline.WrittenAtColumn, Optional: true})
+	}
+	jsonlFile := ""
+	if outputFormat == OutputFormatJSONL {
+		jsonlFile = outputFilename
+	}
+	prior, err := readExistingOutputRows(ctx, client, outputRef, baseTxn, jsonlFile, fopts.CSVReadOptions.CSVLimits, priorSchema, priorOutputNotFoundRetry(fopts), logger, runID, warn)
 	if err != nil {
 		return err
 	}
-	plan := buildIncrementalPlan(emails, existingByEmail)
+	existingByEmail := prior.rows
+	extraColumns := outputExtraColumns(sourceRows, passthrough, fopts.CaptureRawResponse, opts.CaptureUsage, opts.CaptureCandidate, opts.MinCompleteness > 0, opts.ReenrichAfter != nil)
+	recacheOnSchemaChange(existingByEmail, prior.header, outputHeader(opts.Schema, fopts.OmitAuditColumns, extraColumns), fopts.RecacheOnSchemaChange, warn)
+	recacheEmptyOKRows(existingByEmail, fopts.RecacheEmptyOK, logf)
+	reenrichStaleRows(existingByEmail, opts.ReenrichAfter, logf)
+	plan := buildIncrementalPlan(emails, existingByEmail, partialAttemptsLimit(opts.MaxPartialAttempts))
+	opts = withPriorPartialAttempts(opts, existingByEmail)
+	skipped := skipExcludedRows(&plan, emails, domains.keep(emails), keep, logf)
 	logf(
 		"incremental plan: inputRows=%d cachedRows=%d rowsToEnrich=%d uniqueEmailsToEnrich=%d",
 		len(emails),
@@ -231,16 +1018,137 @@ func RunFoundry(
 		plan.pendingRows,
 		len(plan.pendingEmails),
 	)
+	unique := len(plan.pendingEmails)
+	if err := enforceEnrichBudget(&plan, fopts.MaxUniqueEnrich, budgetMode, logf); err != nil {
+		return err
+	}
+	res.Plan = planSummary(plan, len(emails), skipped, unique)
+	traced := newTracedEnricher(enricher, logger, runID, opts)
+
+	renderOutput := func(rows []pipeline.Row) ([]byte, error) {
+		var outBuf bytes.Buffer
+		schema := opts.Schema
+		if fopts.OmitAuditColumns {
+			schema = schema.Lean()
+		}
+		if outputFormat == OutputFormatJSONL {
+			err := pipeline.NDJSONOutput{W: &outBuf, ExtraColumns: extraColumns, OmitAuditColumns: fopts.OmitAuditColumns}.Store(ctx, rows)
+			return outBuf.Bytes(), err
+		}
+		if err := pipeline.WriteCSVWithSchema(&outBuf, rows, schema, extraColumns); err != nil {
+			return nil, err
+		}
+		return outBuf.Bytes(), nil
+	}
+	uploadRendered := func(b []byte) (foundryio.UploadResult, error) {
+		outputFiles := []foundryio.DatasetFile{{Path: outputFilename, Bytes: b}}
+		if outputFormat == OutputFormatJSONL {
+			outputFiles[0].ContentType = JSONLContentType
+		}
+		if fopts.OutputChecksum {
+			outputFiles = append(outputFiles, foundryio.ChecksumSidecar(outputFilename, b))
+		}
+		return foundryio.UploadDatasetFilesWithResult(ctx, client, outputRef, outputFiles, fopts.WriteRetryPolicy)
+	}
+
+	// partialErr holds the first enrichment error under FailFastKeepPartial, returned once the
+	// completed rows are written.
+	var partialErr error
 	if len(plan.pendingEmails) > 0 {
-		freshRows, err := pipeline.EnrichEmails(ctx, plan.pendingEmails, newTracedEnricher(enricher, logger, runID, opts), opts)
-		if err != nil {
+		// Check the outputs are writable before paying for enrichment.
+		if isBoth {
+			if err := preflightStreamWrite(ctx, streamBackend, streamRef, logf, warn); err != nil {
+				return err
+			}
+		}
+		if err := preflightDatasetWrite(ctx, client, outputRef); err != nil {
 			return err
 		}
-		if err := plan.applyEnrichedRows(freshRows); err != nil {
+
+		// onRow is nil unless rows are audited, counted, or checkpointed as they complete.
+		var onRow func(pipeline.Row) error
+		if fopts.CommitEvery > 0 && checkpointsCommit(ctx, client, outputRef, logf) {
+			checkpointRef := outputRef
+			checkpointRef.TransactionType = foundry.TransactionTypeAppend
+			committer := newChunkCommitter(fopts.CommitEvery, &plan, func(rows []pipeline.Row) {
+				sourceRows.tagRows(rows)
+				passthrough.tagRows(rows)
+			}, func(chunk []pipeline.Row, seq int) error {
+				sortOutputRows(chunk, outputSort)
+				b, err := renderOutput(chunk)
+				if err != nil {
+					return err
+				}
+				// validateJSONLOutput rejects CommitEvery for JSONL, so checkpoints are always CSV.
+				file := foundryio.DatasetFile{Path: checkpointFilename(outputFilename, runID, attempt, seq), Bytes: b}
+				_, err = foundryio.UploadDatasetFilesWithResult(ctx, client, checkpointRef, []foundryio.DatasetFile{file}, fopts.WriteRetryPolicy)
+				return err
+			}, logf, warn)
+			onRow = committer.add
+			defer func() { res.Checkpoints = committer.commits }()
+		}
+		onRow = chainRowCallbacks(auditRow, onRow)
+
+		var freshRows []pipeline.Row
+		if isBoth {
+			logf("publishing rows to stream-proxy (%s@%s) before the dataset write", streamRef.RID, defaultBranch(streamRef.Branch))
+			publishedRows := 0
+			freshRows, err = pipeline.EnrichEmailsWithCallback(ctx, plan.pendingEmails, traced, opts, func(row pipeline.Row) error {
+				if cancelledRow(ctx, row) {
+					return nil
+				}
+				if _, err := publishStreamRow(drainCtx, streamBackend, streamRef, streamMeta, runID, tagStreamRow(row), streamExtraColumns, fopts.StreamContract, fopts.OmitAuditColumns); err != nil {
+					return err
+				}
+				publishedRows++
+				res.RecordsPublished = publishedRows
+				logf(
+					"stream row published: email=%q status=%q published=%d/%d",
+					row.Email,
+					strings.TrimSpace(row.Status),
+					publishedRows,
+					len(plan.pendingEmails),
+				)
+				if onRow != nil {
+					return onRow(row)
+				}
+				return nil
+			})
+			if err == nil && fopts.VerifyStreamWrites {
+				verifyStreamWrites(ctx, streamBackend, streamRef, streamMeta, runID, runStart, publishedRows, fopts.VerifyStreamWritesWait, warn)
+			}
+		} else if onRow != nil {
+			freshRows, err = pipeline.EnrichEmailsWithCallback(ctx, plan.pendingEmails, traced, opts, onRow)
+		} else {
+			freshRows, err = pipeline.EnrichEmails(ctx, plan.pendingEmails, traced, opts)
+		}
+		if err != nil {
+			if !opts.FailFastKeepPartial || freshRows == nil {
+				return err
+			}
+			partialErr = err
+			logf("fail-fast: enrichment stopped at the first error; writing the %d rows completed: %s", len(freshRows), err)
+		}
+		m := enrichedMetrics(freshRows)
+		res.Metrics = traced.metrics(m.Enriched, m.OK, m.Errors)
+		if partialErr != nil {
+			// Emails never started get no output row, so the next run enriches them.
+			done := make(map[string]pipeline.Row, len(freshRows))
+			for _, row := range freshRows {
+				done[emailKey(row.Email)] = row
+			}
+			plan.rows, _ = plan.enrichedRows(done)
+		} else if err := plan.applyEnrichedRows(freshRows); err != nil {
 			return err
 		}
 	}
 	rows := plan.rows
+	sourceRows.tagRows(rows)
+	passthrough.tagRows(rows)
+	if partialErr != nil {
+		rows = dropUnstartedRows(rows)
+	}
+	sortOutputRows(rows, outputSort)
 	okRows, errorRows := countStatuses(rows)
 	logf(
 		"enrichment complete: produced=%d ok=%d error=%d duration=%s",
@@ -249,36 +1157,192 @@ func RunFoundry(
 		errorRows,
 		time.Since(enrichStart).Round(time.Millisecond),
 	)
+	traced.logUsage(logf)
+
+	// The final write has not opened a transaction yet, so aborting here leaves none behind.
+	thresholdErr := checkErrorRate(res.Metrics, fopts.MaxErrorRate)
+	if thresholdErr != nil {
+		if thresholdMode == thresholdFailureAbort {
+			logf("error rate threshold exceeded; aborting without writing the dataset output: %s", thresholdErr)
+			abortOpenOutputTransaction(ctx, client, outputRef, logf)
+			return runError(partialErr, thresholdErr)
+		}
+		logf("error rate threshold exceeded; writing the dataset output anyway: %s", thresholdErr)
+	}
 
 	writeStart := time.Now()
-	var outBuf bytes.Buffer
-	if err := pipeline.WriteCSV(&outBuf, rows); err != nil {
+	rendered, err := renderOutput(rows)
+	if err != nil {
 		return err
 	}
-	if err := foundryio.UploadDatasetCSV(ctx, client, outputRef, outputFilename, outBuf.Bytes()); err != nil {
+	if outputUnchanged(ctx, client, outputRef, prior.digest, rendered, jsonlFile != "", fopts.CSVReadOptions.CSVLimits, priorSchema, logf) {
+		res.UpToDate = true
+		logf(
+			"foundry run complete: dataset output unchanged totalDuration=%s",
+			time.Since(runStart).Round(time.Millisecond),
+		)
+		return runError(partialErr, thresholdErr)
+	}
+	headBefore := ""
+	if useIndex {
+		headBefore, err = client.GetBranchTransactionRID(ctx, outputRef.RID, outputBranch)
+		if err != nil && !isNotFoundError(err) {
+			logf("incremental index: read output branch head failed; index will not be written: %s", err)
+			useIndex = false
+		}
+	}
+	upload, err := uploadRendered(rendered)
+	if err != nil {
 		return err
 	}
+	res.OutputFile = outputFilename
+	res.OutputTransactionRID = upload.TransactionRID
+	res.OutputFiles = upload.Files
+	res.RowsWritten = len(rows)
+	if fopts.TagOutput {
+		res.OutputTag = tagOutput(ctx, client, outputRef, upload, runID, warn)
+	}
+	if useIndex {
+		if err := writeIncrementalIndex(ctx, client, outputRef, indexRef, headBefore, rows, digest, logger, runID); err != nil {
+			logf("incremental index: write failed; next run will fall back to a full read: %s", err)
+		}
+	}
 	logf(
-		"foundry run complete: dataset output finished writeDuration=%s totalDuration=%s",
+		"foundry run complete: dataset output finished transaction=%s files=%s writeDuration=%s totalDuration=%s",
+		upload.TransactionRID,
+		strings.Join(upload.Files, ","),
 		time.Since(writeStart).Round(time.Millisecond),
 		time.Since(runStart).Round(time.Millisecond),
 	)
-	return nil
+	return runError(partialErr, thresholdErr)
+}
+
+// outputExtraColumns returns the optional columns written after pipeline.Header() in dataset output.
+func outputExtraColumns(sourceRows inputSourceRows, passthrough inputPassthrough, captureRawResponse, captureUsage, captureCandidate, partialAttempts, writtenAt bool) []string {
+	cols := append(sourceRows.extraColumns(), passthrough.extraColumns()...)
+	if captureRawResponse {
+		cols = append(cols, pipeline.RawResponseColumn)
+	}
+	if captureUsage {
+		cols = append(cols, pipeline.PromptTokensColumn, pipeline.ResponseTokensColumn)
+	}
+	if captureCandidate {
+		cols = append(cols, pipeline.CandidateColumn)
+	}
+	if partialAttempts {
+		cols = append(cols, pipeline.PartialAttemptsColumn)
+	}
+	if writtenAt {
+		cols = append(cols, pipeline.WrittenAtColumn)
+	}
+	return cols
+}
+
+// streamPartitionKey resolves the StreamPartitionKey option into a partition key derivation.
+func streamPartitionKey(field string) foundryio.PartitionKeyFunc {
+	field = strings.TrimSpace(field)
+	switch {
+	case field == "":
+		return foundryio.DefaultPartitionKey
+	case strings.EqualFold(field, "none"):
+		return nil
+	default:
+		return foundryio.PartitionKeyFromField(field)
+	}
+}
+
+func normalizeStreamDelivery(mode string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", foundryio.StreamDeliveryAtLeastOnce:
+		return foundryio.StreamDeliveryAtLeastOnce, nil
+	case foundryio.StreamDeliveryExactlyOnce:
+		return foundryio.StreamDeliveryExactlyOnce, nil
+	default:
+		return "", fmt.Errorf("invalid stream delivery %q (expected at-least-once|exactly-once)", mode)
+	}
+}
+
+// parseStreamMetaPrefix validates StreamMetaPrefix. The prefix becomes part of record field names,
+// so it is limited to letters, digits and underscores.
+func parseStreamMetaPrefix(prefix string) (pipeline.StreamMeta, error) {
+	prefix = strings.TrimSpace(prefix)
+	for _, r := range prefix {
+		if r != '_' && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return pipeline.StreamMeta{}, fmt.Errorf("invalid stream meta prefix %q (expected letters, digits and underscores)", prefix)
+		}
+	}
+	return pipeline.StreamMeta{Prefix: prefix}, nil
+}
+
+// publishStreamRow publishes one enriched row, stamped with run metadata under meta's field names,
+// and returns its written_at value. Only extraColumns of the row's extra values are published.
+// contract, when non-nil, decides how empty fields are encoded (see
+// pipeline.RowToStreamRecordWithContract); omitAudit drops the audit fields from the record.
+func publishStreamRow(
+	ctx context.Context,
+	streamBackend foundryio.StreamBackend,
+	ref foundry.DatasetRef,
+	meta pipeline.StreamMeta,
+	runID string,
+	row pipeline.Row,
+	extraColumns []string,
+	contract *schema.DatasetContract,
+	omitAudit bool,
+) (string, error) {
+	writtenAt := time.Now().UTC().Format(time.RFC3339Nano)
+	rec := pipeline.RowToStreamRecordWithContract(row, extraColumns, contract)
+	if omitAudit {
+		pipeline.OmitAuditFields(rec)
+	}
+	// The record's own written_at metadata stands in for the row's enrichment time.
+	delete(rec, pipeline.WrittenAtColumn)
+	rec[meta.RunIDKey()] = runID
+	rec[meta.WrittenAtKey()] = writtenAt
+	if err := streamBackend.PublishRecord(ctx, ref, rec); err != nil {
+		return "", err
+	}
+	return writtenAt, nil
+}
+
+// DefaultStreamCacheMaxRecords bounds the stream cache read so a huge stream cannot exhaust memory.
+const DefaultStreamCacheMaxRecords = 1_000_000
+
+func streamCacheMaxRecords(n int) int {
+	if n == 0 {
+		return DefaultStreamCacheMaxRecords
+	}
+	return n
 }
 
+// readExistingStreamRows loads the prior stream records, keeping the newest maxRecords of them
+// (maxRecords <= 0 keeps all) and re-reading a not-found stream as notFound allows. Each row's
+// pipeline.WrittenAtColumn is taken from the record's meta written_at field.
 func readExistingStreamRows(
 	ctx context.Context,
 	streamBackend foundryio.StreamBackend,
 	outputRef foundry.DatasetRef,
+	maxRecords int,
+	notFound notFoundRetry,
+	meta pipeline.StreamMeta,
 	logger *log.Logger,
 	runID string,
+	warn *warningCollector,
 ) (map[string]pipeline.Row, error) {
 	branch := strings.TrimSpace(outputRef.Branch)
 	if branch == "" {
 		branch = "master"
 	}
 
-	recs, err := streamBackend.ReadRecords(ctx, outputRef)
+	var (
+		recs      []map[string]any
+		truncated bool
+	)
+	err := notFound.read(ctx, func() (err error) {
+		recs, truncated, err = foundryio.ReadRecordsLimit(ctx, streamBackend, outputRef, maxRecords)
+		return err
+	}, func(retry int, wait time.Duration) {
+		logger.Printf("run=%s incremental: prior stream %s@%s not found; re-checking in %s (retry %d/%d)", runID, outputRef.RID, branch, wait, retry, notFound.retries)
+	})
 	if err != nil {
 		if isNotFoundError(err) {
 			logger.Printf("run=%s incremental: no prior stream snapshot found for %s@%s", runID, outputRef.RID, branch)
@@ -295,10 +1359,26 @@ func readExistingStreamRows(
 		}
 		return nil, fmt.Errorf("read prior stream snapshot: %w", err)
 	}
+	if truncated {
+		warn.warnf(
+			WarningStreamCacheTruncated,
+			"incremental: kept only the newest %d records of the prior stream snapshot for %s@%s (stream cache max records); emails only in older records will be re-enriched",
+			maxRecords,
+			outputRef.RID,
+			branch,
+		)
+	}
 
 	out := make(map[string]pipeline.Row, len(recs))
 	for _, rec := range recs {
 		row := pipeline.RowFromStreamRecord(rec)
+		fields := pipeline.NormalizeStreamRecord(rec)
+		if writtenAt, ok := fields[meta.WrittenAtKey()].(string); ok {
+			row = row.WithExtra(pipeline.WrittenAtColumn, writtenAt)
+		}
+		if attempts, ok := fields[pipeline.PartialAttemptsColumn].(string); ok {
+			row = row.WithExtra(pipeline.PartialAttemptsColumn, attempts)
+		}
 		key := emailKey(row.Email)
 		if key == "" {
 			continue
@@ -314,6 +1394,18 @@ func readExistingStreamRows(
 	return out, nil
 }
 
+// logRetries returns an OnRetry hook that logs the backoff chosen before each enrichment retry and
+// then calls next, if set. The worker does not know which email failed; the preceding "enrich
+// response" line with the same attempt and willRetry=true names it.
+func logRetries(next func(int, time.Duration, error), logf func(format string, args ...any)) func(int, time.Duration, error) {
+	return func(attempt int, delay time.Duration, err error) {
+		logf("enrich retry scheduled: attempt=%d backoff=%s error=%q", attempt, delay.Round(time.Millisecond), err.Error())
+		if next != nil {
+			next(attempt, delay, err)
+		}
+	}
+}
+
 type tracedEnricher struct {
 	next           enrich.Enricher
 	logger         *log.Logger
@@ -323,6 +1415,10 @@ type tracedEnricher struct {
 
 	mu       sync.Mutex
 	attempts map[string]int
+
+	// usage totals provider-reported token usage across all attempts, including failed ones.
+	usage         enrich.Usage
+	usageAttempts int
 }
 
 func newTracedEnricher(next enrich.Enricher, logger *log.Logger, runID string, opts pipeline.Options) *tracedEnricher {
@@ -337,11 +1433,22 @@ func newTracedEnricher(next enrich.Enricher, logger *log.Logger, runID string, o
 }
 
 func (t *tracedEnricher) Enrich(ctx context.Context, email string) (enrich.Result, error) {
+	return t.EnrichRecord(ctx, email, nil)
+}
+
+// EnrichRecord traces one attempt like Enrich, passing record on when the wrapped enricher is an
+// enrich.RecordEnricher, so tracing does not hide that capability.
+func (t *tracedEnricher) EnrichRecord(ctx context.Context, email string, record map[string]string) (enrich.Result, error) {
 	email = strings.TrimSpace(email)
 	attempt := t.nextAttempt(email)
-	reqJSON, _ := json.Marshal(map[string]any{
+	req := map[string]any{
 		"email": email,
-	})
+	}
+	if len(record) > 0 {
+		// Context values are PII (names, employers); only the column names are logged.
+		req["record_columns"] = slices.Sorted(maps.Keys(record))
+	}
+	reqJSON, _ := json.Marshal(req)
 
 	deadlineIn := "none"
 	if d, ok := ctx.Deadline(); ok {
@@ -358,8 +1465,9 @@ func (t *tracedEnricher) Enrich(ctx context.Context, email string) (enrich.Resul
 	)
 
 	start := time.Now()
-	out, err := t.next.Enrich(ctx, email)
+	out, err := enrich.EnrichRecord(ctx, t.next, email, record)
 	elapsed := time.Since(start).Round(time.Millisecond)
+	t.addUsage(out.Usage)
 
 	respJSON, _ := json.Marshal(map[string]any{
 		"linkedin_url":       out.LinkedInURL,
@@ -402,6 +1510,32 @@ func (t *tracedEnricher) Enrich(ctx context.Context, email string) (enrich.Resul
 	return out, nil
 }
 
+func (t *tracedEnricher) addUsage(u *enrich.Usage) {
+	if u == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.usage.PromptTokens += u.PromptTokens
+	t.usage.ResponseTokens += u.ResponseTokens
+	t.usageAttempts++
+}
+
+// logUsage logs the run's total token usage, if the enricher reported any.
+func (t *tracedEnricher) logUsage(logf func(format string, args ...any)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.usageAttempts == 0 {
+		return
+	}
+	logf(
+		"token usage: promptTokens=%d responseTokens=%d attemptsWithUsage=%d",
+		t.usage.PromptTokens,
+		t.usage.ResponseTokens,
+		t.usageAttempts,
+	)
+}
+
 func (t *tracedEnricher) nextAttempt(email string) int {
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -445,30 +1579,95 @@ func isRetryableError(err error) bool {
 	if errors.Is(err, context.DeadlineExceeded) {
 		return true
 	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && (dnsErr.IsTimeout || dnsErr.IsTemporary) {
+		return true
+	}
 	var netErr net.Error
-	if errors.As(err, &netErr) {
-		return netErr.Timeout() || netErr.Temporary()
+	if errors.As(err, &netErr) && (netErr.Timeout() || netErr.Temporary()) {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
 	}
-	return false
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// priorOutput is what readExistingOutputRows read from the dataset output.
+type priorOutput struct {
+	// rows is the incremental cache, keyed by emailKey.
+	rows map[string]pipeline.Row
+	// digest is the outputDigest of the branch-head output for the unchanged-output check; nil when
+	// there was no prior output or the read was pinned to a base transaction.
+	digest []byte
+	// header is the prior output's CSV header; nil when there was no prior output.
+	header []string
 }
 
 func readExistingOutputRows(
 	ctx context.Context,
 	client *foundry.Client,
 	outputRef foundry.DatasetRef,
+	baseTxn string,
+	jsonlFile string,
+	limits localio.CSVLimits,
+	schema pipeline.RowSchema,
+	notFound notFoundRetry,
 	logger *log.Logger,
 	runID string,
-) (map[string]pipeline.Row, error) {
+	warn *warningCollector,
+) (priorOutput, error) {
 	branch := strings.TrimSpace(outputRef.Branch)
 	if branch == "" {
 		branch = "master"
 	}
 
-	b, err := client.ReadTableCSV(ctx, outputRef.RID, branch)
+	// A JSONL output is not tabular CSV, so it is read back as its raw file rather than through
+	// readTable, which serves a re-serialized table on real stacks.
+	open := func(txnRID string) (io.ReadCloser, error) {
+		switch {
+		case jsonlFile != "":
+			return client.OpenFileContent(ctx, outputRef.RID, branch, txnRID, jsonlFile)
+		case txnRID != "":
+			return client.OpenTableCSVAtTransaction(ctx, outputRef.RID, branch, txnRID)
+		default:
+			return client.OpenTableCSV(ctx, outputRef.RID, branch)
+		}
+	}
+	jsonl := jsonlFile != ""
+
+	if baseTxn != "" {
+		body, err := open(baseTxn)
+		if err != nil {
+			if isNotFoundError(err) {
+				return priorOutput{}, fmt.Errorf("incremental base transaction %s not found on output %s@%s: %w", baseTxn, outputRef.RID, branch, err)
+			}
+			return priorOutput{}, fmt.Errorf("read prior output at incremental base transaction %s: %w", baseTxn, err)
+		}
+		defer func() {
+			_ = body.Close()
+		}()
+		out, header, err := scanRowsByEmail(body, jsonl, limits, schema, nil)
+		if err != nil {
+			return priorOutput{}, err
+		}
+		logger.Printf("run=%s incremental: loaded %d prior output rows from %s@%s pinned to transaction %s", runID, len(out), outputRef.RID, branch, baseTxn)
+		warnEmptyPriorOutput(out, outputRef.RID, branch, warn)
+		// The pinned version is not the head, so it is not returned for the unchanged-output check.
+		return priorOutput{rows: out, header: header}, nil
+	}
+
+	var body io.ReadCloser
+	err := notFound.read(ctx, func() (err error) {
+		body, err = open("")
+		return err
+	}, func(retry int, wait time.Duration) {
+		logger.Printf("run=%s incremental: prior output %s@%s not found; re-checking in %s (retry %d/%d)", runID, outputRef.RID, branch, wait, retry, notFound.retries)
+	})
 	if err != nil {
 		if isNotFoundError(err) {
 			logger.Printf("run=%s incremental: no prior output snapshot found for %s@%s", runID, outputRef.RID, branch)
-			return map[string]pipeline.Row{}, nil
+			return priorOutput{rows: map[string]pipeline.Row{}}, nil
 		}
 		if isPermissionDeniedError(err) {
 			logger.Printf(
@@ -477,18 +1676,134 @@ func readExistingOutputRows(
 				outputRef.RID,
 				branch,
 			)
-			return map[string]pipeline.Row{}, nil
+			return priorOutput{rows: map[string]pipeline.Row{}}, nil
 		}
-		return nil, fmt.Errorf("read prior output dataset snapshot: %w", err)
+		return priorOutput{}, fmt.Errorf("read prior output dataset snapshot: %w", err)
 	}
+	defer func() {
+		_ = body.Close()
+	}()
 
-	rows, err := pipeline.ReadCSV(bytes.NewReader(b))
+	// The body is parsed as it arrives and only its rows are hashed, never held, so peak memory is
+	// the cache rather than the raw CSV plus every parsed row.
+	digest := sha256.New()
+	out, header, err := scanRowsByEmail(body, jsonl, limits, schema, digest)
 	if err != nil {
-		return nil, fmt.Errorf("parse prior output csv: %w", err)
+		return priorOutput{}, err
 	}
+	logger.Printf("run=%s incremental: loaded %d prior output rows from %s@%s", runID, len(out), outputRef.RID, branch)
+	warnEmptyPriorOutput(out, outputRef.RID, branch, warn)
+	return priorOutput{rows: out, digest: digest.Sum(nil), header: header}, nil
+}
 
-	out := make(map[string]pipeline.Row, len(rows))
-	for _, row := range rows {
+// warnEmptyPriorOutput records a WarningEmptyPriorOutput when a prior output was read but held no
+// rows: a previously written output that is now empty usually means it was cleared, and every input
+// row will be enriched again.
+func warnEmptyPriorOutput(rows map[string]pipeline.Row, rid, branch string, warn *warningCollector) {
+	if len(rows) > 0 {
+		return
+	}
+	warn.warnf(WarningEmptyPriorOutput, "incremental: prior output %s@%s has no rows; every input row will be enriched", rid, branch)
+}
+
+// outputDigest returns the SHA-256 of an output's header and parsed rows, in order, as
+// scanRowsByEmail computes it while reading the prior output. Hashing parsed rows rather than bytes
+// makes an output compare equal to the prior head however readTable re-encodes it (quoting, line
+// endings).
+func outputDigest(rendered []byte, jsonl bool, limits localio.CSVLimits, schema pipeline.RowSchema) ([]byte, error) {
+	digest := sha256.New()
+	if _, _, err := scanRowsByEmail(bytes.NewReader(rendered), jsonl, limits, schema, digest); err != nil {
+		return nil, err
+	}
+	return digest.Sum(nil), nil
+}
+
+// outputUnchanged reports whether rendered parses to the same header and rows as the prior output
+// read from the branch head (whose outputDigest is priorDigest), so the write can be skipped instead
+// of committing an identical transaction. A pre-created OPEN transaction (pipeline builds) must
+// still receive the full output, so it reports false when one exists or the check fails.
+func outputUnchanged(
+	ctx context.Context,
+	client *foundry.Client,
+	outputRef foundry.DatasetRef,
+	priorDigest, rendered []byte,
+	jsonl bool,
+	limits localio.CSVLimits,
+	schema pipeline.RowSchema,
+	logf func(format string, args ...any),
+) bool {
+	if priorDigest == nil {
+		return false
+	}
+	sum, err := outputDigest(rendered, jsonl, limits, schema)
+	if err != nil {
+		logf("unchanged output: parse rendered output failed; writing output: %s", err)
+		return false
+	}
+	if !bytes.Equal(priorDigest, sum) {
+		return false
+	}
+	openTxn, open, err := client.FindLatestOpenTransactionForBranch(ctx, outputRef.RID, defaultBranch(outputRef.Branch))
+	if err != nil {
+		logf("unchanged output: list open transactions failed; writing output: %s", err)
+		return false
+	}
+	if open {
+		logf("unchanged output: open output transaction %s exists; writing output", openTxn)
+		return false
+	}
+	logf("no changes; skipping commit (output %s@%s matches the prior head)", outputRef.RID, defaultBranch(outputRef.Branch))
+	return true
+}
+
+// existingRowsByEmail parses a prior output CSV into the incremental cache, keeping the best row per email.
+func existingRowsByEmail(b []byte, limits localio.CSVLimits) (map[string]pipeline.Row, error) {
+	out, _, err := scanRowsByEmail(bytes.NewReader(b), false, limits, pipeline.RowSchema{}, nil)
+	return out, err
+}
+
+// scanRowsByEmail is existingRowsByEmail reading r one row at a time, so only the cache is held. It
+// also returns the CSV header. Rows are read with schema, so custom columns survive the cache. With
+// jsonl, r is a JSONL output (see pipeline.WriteJSONL) and the header is nil. A non-nil digest
+// receives the header and every row read, in order, for outputDigest.
+func scanRowsByEmail(r io.Reader, jsonl bool, limits localio.CSVLimits, schema pipeline.RowSchema, digest io.Writer) (map[string]pipeline.Row, []string, error) {
+	if digest == nil {
+		digest = io.Discard
+	}
+	br := bufio.NewReader(r)
+	if jsonl {
+		jr := pipeline.NewJSONLRowReader(br)
+		out, err := collectRowsByEmail(jr.Read, digest)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parse prior output jsonl: %w", err)
+		}
+		return out, nil, nil
+	}
+	cr, err := pipeline.NewCSVRowReaderWithSchema(br, limits, schema)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse prior output csv: %w", err)
+	}
+	_, _ = fmt.Fprintf(digest, "%q\n", cr.Header())
+	out, err := collectRowsByEmail(cr.Read, digest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse prior output csv: %w", err)
+	}
+	return out, cr.Header(), nil
+}
+
+// collectRowsByEmail reads rows until io.EOF, keeping the best row per email. Each row's rowHash
+// is written to digest in read order.
+func collectRowsByEmail(read func() (pipeline.Row, error), digest io.Writer) (map[string]pipeline.Row, error) {
+	out := map[string]pipeline.Row{}
+	for {
+		row, err := read()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		_, _ = fmt.Fprintln(digest, rowHash(row))
 		key := emailKey(row.Email)
 		if key == "" {
 			continue
@@ -500,8 +1815,6 @@ func readExistingOutputRows(
 		}
 		out[key] = chooseBestIncrementalRow(prev, row)
 	}
-	logger.Printf("run=%s incremental: loaded %d prior output rows from %s@%s", runID, len(out), outputRef.RID, branch)
-	return out, nil
 }
 
 func isNotFoundError(err error) bool {
@@ -509,6 +1822,16 @@ func isNotFoundError(err error) bool {
 	return errors.As(err, &he) && he.StatusCode == 404
 }
 
+// isEmptyInputError reports whether reading the input failed only because it holds no data yet:
+// the dataset exists but has no committed view, or its table lacks even a header row.
+func isEmptyInputError(err error) bool {
+	if errors.Is(err, io.EOF) {
+		return true
+	}
+	var he *foundry.HTTPError
+	return errors.As(err, &he) && he.StatusCode == 404 && he.ErrorName == "DatasetViewNotFound"
+}
+
 func isPermissionDeniedError(err error) bool {
 	var he *foundry.HTTPError
 	return errors.As(err, &he) && he.StatusCode == 403
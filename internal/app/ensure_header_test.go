@@ -0,0 +1,60 @@
+package app_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+func TestRunFoundry_EnsureHeaderCommitsHeaderOnlyOutputForEmptyInput(t *testing.T) {
+	t.Parallel()
+
+	// The mock serves an empty input file as a dataset with no committed view.
+	mock, env := newMockFoundryEnv(t, "")
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+		EnsureHeader:    true,
+	}, pipeline.Options{}, testEnricher{}); err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+
+	uploads := mock.Uploads()
+	if len(uploads) != 1 {
+		t.Fatalf("expected 1 upload, got %d", len(uploads))
+	}
+	want := strings.Join(pipeline.Header(), ",") + "\n"
+	if got := string(uploads[0].Bytes); got != want {
+		t.Fatalf("expected header-only output %q, got %q", want, got)
+	}
+	committed := false
+	for _, call := range mock.Calls() {
+		if strings.HasSuffix(call.Path, "/transactions/"+uploads[0].TxnID+"/commit") {
+			committed = true
+		}
+	}
+	if !committed {
+		t.Fatalf("expected header-only output to be committed")
+	}
+}
+
+func TestRunFoundry_EmptyInputFailsWithoutEnsureHeader(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "")
+	_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+	}, pipeline.Options{}, testEnricher{})
+	if err == nil {
+		t.Fatalf("expected empty input to fail without EnsureHeader")
+	}
+	if n := len(mock.Uploads()); n != 0 {
+		t.Fatalf("expected no uploads, got %d", n)
+	}
+}
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+)
+
+// ErrErrorRateExceeded is wrapped by the error of a run whose share of status=error enriched rows
+// exceeded FoundryOptions.MaxErrorRate.
+var ErrErrorRateExceeded = errors.New("enrichment error rate exceeded")
+
+// ErrErrorCountExceeded is wrapped by the error of a run that wrote its output but had too many
+// status=error enriched rows for FailOnAnyError or FailOnErrorCount.
+var ErrErrorCountExceeded = errors.New("enrichment error count exceeded")
+
+const (
+	thresholdFailureAbort        = "abort"
+	thresholdFailureCommitAnyway = "commit-anyway"
+)
+
+func normalizeThresholdFailureMode(mode string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", thresholdFailureAbort:
+		return thresholdFailureAbort, nil
+	case thresholdFailureCommitAnyway:
+		return thresholdFailureCommitAnyway, nil
+	default:
+		return "", fmt.Errorf("invalid on-threshold-failure mode %q (expected abort|commit-anyway)", mode)
+	}
+}
+
+func validateMaxErrorRate(rate float64) error {
+	if rate < 0 || rate > 1 {
+		return fmt.Errorf("invalid max-error-rate %v (expected 0-1, 0 disables)", rate)
+	}
+	return nil
+}
+
+// checkErrorRate returns an ErrErrorRateExceeded error when more than maxRate of the emails
+// enriched this run ended with status=error. maxRate <= 0 disables the check; cached rows do not count.
+func checkErrorRate(m RunMetrics, maxRate float64) error {
+	if maxRate <= 0 || m.Enriched == 0 {
+		return nil
+	}
+	rate := float64(m.Errors) / float64(m.Enriched)
+	if rate <= maxRate {
+		return nil
+	}
+	return fmt.Errorf("%w: %d of %d enriched emails failed (%.2f > max-error-rate %.2f)", ErrErrorRateExceeded, m.Errors, m.Enriched, rate, maxRate)
+}
+
+// abortOpenOutputTransaction aborts the OPEN transaction Foundry pre-created on the output for a
+// pipeline build, so an aborted run commits nothing even when the build would otherwise commit
+// the transaction it opened. Failures are logged; the run is failing already.
+func abortOpenOutputTransaction(ctx context.Context, client *foundry.Client, outputRef foundry.DatasetRef, logf func(format string, args ...any)) {
+	ctx = context.WithoutCancel(ctx)
+	txn, open, err := client.FindLatestOpenTransactionForBranch(ctx, outputRef.RID, defaultBranch(outputRef.Branch))
+	if err != nil {
+		logf("error rate threshold: list open output transactions failed; none aborted: %s", err)
+		return
+	}
+	if !open {
+		return
+	}
+	if err := client.AbortTransaction(ctx, outputRef.RID, txn); err != nil {
+		logf("error rate threshold: abort open output transaction %s failed: %s", txn, err)
+		return
+	}
+	logf("error rate threshold: aborted open output transaction %s", txn)
+}
+
+// validateErrorCount rejects a negative FailOnErrorCount and combinations of the count-based
+// thresholds with each other or with the rate-based one, which would disagree about when to fail.
+func validateErrorCount(failOnAnyError bool, failOnErrorCount int, maxErrorRate float64) error {
+	switch {
+	case failOnErrorCount < 0:
+		return fmt.Errorf("invalid fail-on-error-count %d (expected >= 0, 0 disables)", failOnErrorCount)
+	case failOnAnyError && failOnErrorCount > 0:
+		return fmt.Errorf("fail-on-any-error and fail-on-error-count cannot be combined")
+	case (failOnAnyError || failOnErrorCount > 0) && maxErrorRate > 0:
+		return fmt.Errorf("fail-on-any-error and fail-on-error-count cannot be combined with max-error-rate")
+	}
+	return nil
+}
+
+// checkErrorCount returns an ErrErrorCountExceeded error when at least failOnErrorCount (or, with
+// failOnAnyError, any) of the emails enriched this run ended with status=error. It runs after the output is
+// written, so it only decides the run's outcome; cached rows do not count.
+func checkErrorCount(m RunMetrics, failOnAnyError bool, failOnErrorCount int) error {
+	limit := failOnErrorCount
+	if failOnAnyError {
+		limit = 1
+	}
+	if limit <= 0 || m.Errors < limit {
+		return nil
+	}
+	return fmt.Errorf("%w: %d of %d enriched emails failed (limit %d)", ErrErrorCountExceeded, m.Errors, m.Enriched, limit)
+}
+
+// runError combines the errors a dataset run returns after still writing its output: the first
+// enrichment error under FailFastKeepPartial and an error rate breach under commit-anyway.
+func runError(partialErr, thresholdErr error) error {
+	switch {
+	case partialErr == nil:
+		return thresholdErr
+	case thresholdErr == nil:
+		return partialErr
+	default:
+		return errors.Join(partialErr, thresholdErr)
+	}
+}
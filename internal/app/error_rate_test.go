@@ -0,0 +1,250 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/enrich"
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+)
+
+func TestRunFoundry_MaxErrorRate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		maxRate     float64
+		mode        string
+		wantErr     bool
+		wantUploads int
+	}{
+		{name: "exceeded aborts without committing", maxRate: 0.25, mode: "abort", wantErr: true, wantUploads: 0},
+		{name: "exceeded defaults to abort", maxRate: 0.25, wantErr: true, wantUploads: 0},
+		{name: "exceeded commit-anyway writes then fails", maxRate: 0.25, mode: "commit-anyway", wantErr: true, wantUploads: 1},
+		{name: "at threshold commits", maxRate: 0.5, mode: "abort", wantUploads: 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock, env := newMockFoundryEnv(t, "email\nalice@example.com\nfail@corp.test\n")
+			_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+				InputAlias:         "input",
+				OutputAlias:        "output",
+				OutputWriteMode:    "dataset",
+				MaxErrorRate:       tc.maxRate,
+				OnThresholdFailure: tc.mode,
+			}, pipeline.Options{}, failOnPrefixEnricher{})
+			if got := errors.Is(err, app.ErrErrorRateExceeded); got != tc.wantErr {
+				t.Fatalf("want error rate failure=%t, got %v", tc.wantErr, err)
+			}
+			if tc.wantErr && app.ClassifyFailure(err) != app.FailureThresholdExceeded {
+				t.Fatalf("expected FailureThresholdExceeded, got %q", app.ClassifyFailure(err))
+			}
+			if got := len(mock.Uploads()); got != tc.wantUploads {
+				t.Fatalf("expected %d uploads, got %d", tc.wantUploads, got)
+			}
+
+			client, err := foundry.NewClient(env.Services.APIGateway, env.Services.StreamProxy, env.Token, "")
+			if err != nil {
+				t.Fatalf("NewClient: %v", err)
+			}
+			txn, open, err := client.FindLatestOpenTransactionForBranch(context.Background(), testOutputRID, "master")
+			if err != nil {
+				t.Fatalf("list transactions: %v", err)
+			}
+			if open {
+				t.Fatalf("expected no open output transaction, found %s", txn)
+			}
+		})
+	}
+}
+
+func TestRunFoundry_MaxErrorRateAbortsPreCreatedTransaction(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email\nalice@example.com\nfail@corp.test\n")
+	client, err := foundry.NewClient(env.Services.APIGateway, env.Services.StreamProxy, env.Token, "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	// Foundry opened the output transaction for the build.
+	buildTxn, err := client.CreateTransaction(context.Background(), testOutputRID, "master")
+	if err != nil {
+		t.Fatalf("create build transaction: %v", err)
+	}
+
+	_, err = app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+		MaxErrorRate:    0.25,
+	}, pipeline.Options{}, failOnPrefixEnricher{})
+	if !errors.Is(err, app.ErrErrorRateExceeded) {
+		t.Fatalf("expected an error rate failure, got %v", err)
+	}
+	if got := len(mock.Uploads()); got != 0 {
+		t.Fatalf("expected nothing uploaded, got %d uploads", got)
+	}
+	txn, open, err := client.FindLatestOpenTransactionForBranch(context.Background(), testOutputRID, "master")
+	if err != nil {
+		t.Fatalf("list transactions: %v", err)
+	}
+	if open {
+		t.Fatalf("expected build transaction %s aborted, found open %s", buildTxn, txn)
+	}
+}
+
+func TestRunFoundry_MaxErrorRateAbortRejectsCommitEvery(t *testing.T) {
+	t.Parallel()
+
+	_, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+		MaxErrorRate:    0.25,
+		CommitEvery:     1,
+	}, pipeline.Options{}, testEnricher{})
+	if app.ClassifyFailure(err) != app.FailureConfig {
+		t.Fatalf("expected a config error, got %v", err)
+	}
+}
+
+func TestRunFoundry_FailOnErrorCount(t *testing.T) {
+	t.Parallel()
+
+	// Two of the three enriched emails fail.
+	cases := []struct {
+		name     string
+		anyError bool
+		count    int
+		mode     string
+		// minCompleteness makes alice's row partial, which must not count as an error.
+		minCompleteness float64
+		wantErr         bool
+	}{
+		{name: "fail-on-any-error", anyError: true, mode: "dataset", wantErr: true},
+		{name: "count reached", count: 2, mode: "dataset", wantErr: true},
+		{name: "count not reached", count: 3, mode: "dataset"},
+		{name: "partial rows not counted", count: 3, mode: "dataset", minCompleteness: 0.5},
+		{name: "disabled", mode: "dataset"},
+		{name: "stream fail-on-any-error", anyError: true, mode: "stream", wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock, env := newMockFoundryEnv(t, "email\nalice@example.com\nfail@corp.test\nfail@new.test\n")
+			if tc.mode == "stream" {
+				mock.CreateStream(testOutputRID)
+			}
+			res, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+				InputAlias:       "input",
+				OutputAlias:      "output",
+				OutputWriteMode:  tc.mode,
+				FailOnAnyError:   tc.anyError,
+				FailOnErrorCount: tc.count,
+			}, pipeline.Options{MinCompleteness: tc.minCompleteness}, failOnPrefixEnricher{})
+			if got := errors.Is(err, app.ErrErrorCountExceeded); got != tc.wantErr {
+				t.Fatalf("want error count failure=%t, got %v", tc.wantErr, err)
+			}
+			if res.Metrics.Errors != 2 {
+				t.Fatalf("expected 2 failed emails, got %+v", res.Metrics)
+			}
+			// The output is written either way.
+			if tc.mode == "stream" {
+				if got := len(mock.StreamRecords(testOutputRID, "master")); got != 3 {
+					t.Fatalf("expected 3 stream records, got %d", got)
+				}
+			} else if got := len(mock.Uploads()); got != 1 {
+				t.Fatalf("expected 1 upload, got %d", got)
+			}
+		})
+	}
+}
+
+func TestRunLocal_FailOnAnyError(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.csv")
+	outputPath := filepath.Join(dir, "output.csv")
+	if err := os.WriteFile(inputPath, []byte("email\nalice@example.com\nfail@corp.test\n"), 0644); err != nil {
+		t.Fatalf("write input csv: %v", err)
+	}
+	_, err := app.RunLocalWithOptions(context.Background(), app.LocalOptions{
+		InputPath:      inputPath,
+		OutputPath:     outputPath,
+		FailOnAnyError: true,
+	}, pipeline.Options{}, failOnPrefixEnricher{})
+	if !errors.Is(err, app.ErrErrorCountExceeded) {
+		t.Fatalf("expected ErrErrorCountExceeded, got %v", err)
+	}
+	if _, err := os.Stat(outputPath); err != nil {
+		t.Fatalf("expected the output to be written: %v", err)
+	}
+}
+
+func TestRunFoundry_InvalidThresholdOptionsFail(t *testing.T) {
+	t.Parallel()
+
+	for _, fopts := range []app.FoundryOptions{
+		{MaxErrorRate: 1.5},
+		{MaxErrorRate: 0.1, OnThresholdFailure: "retry"},
+		{FailOnErrorCount: -1},
+		{FailOnAnyError: true, FailOnErrorCount: 2},
+		{MaxErrorRate: 0.1, FailOnAnyError: true},
+	} {
+		_, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+		fopts.InputAlias, fopts.OutputAlias, fopts.OutputWriteMode = "input", "output", "dataset"
+		_, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{}, testEnricher{})
+		if app.ClassifyFailure(err) != app.FailureConfig {
+			t.Fatalf("%+v: expected a config error, got %v", fopts, err)
+		}
+	}
+}
+
+// blockOnPrefixEnricher blocks emails starting with "blocked@", as a provider safety block would,
+// and enriches the rest like testEnricher.
+type blockOnPrefixEnricher struct{}
+
+func (blockOnPrefixEnricher) Enrich(ctx context.Context, email string) (enrich.Result, error) {
+	if strings.HasPrefix(email, "blocked@") {
+		return enrich.Result{}, &enrich.BlockedError{Reason: "SAFETY"}
+	}
+	return testEnricher{}.Enrich(ctx, email)
+}
+
+func TestRunFoundry_FailOnAnyErrorIgnoresPartialAndBlockedStreamRows(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email\nalice@example.com\nblocked@corp.test\n")
+	mock.CreateStream(testOutputRID)
+	// minCompleteness makes alice's row partial.
+	res, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "stream",
+		FailOnAnyError:  true,
+	}, pipeline.Options{MinCompleteness: 0.5}, blockOnPrefixEnricher{})
+	if err != nil {
+		t.Fatalf("expected partial and blocked rows not to fail the run, got %v", err)
+	}
+	if res.Metrics.Errors != 0 {
+		t.Fatalf("expected no failed emails, got %+v", res.Metrics)
+	}
+	statuses := map[string]string{}
+	for _, rec := range mock.StreamRecords(testOutputRID, "master") {
+		statuses[rec["email"].(string)], _ = rec["status"].(string)
+	}
+	if statuses["alice@example.com"] != pipeline.StatusPartial || statuses["blocked@corp.test"] != pipeline.StatusBlocked {
+		t.Fatalf("expected a partial and a blocked stream record, got %v", statuses)
+	}
+}
@@ -0,0 +1,172 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/mockfoundry"
+	foundryio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/foundry"
+)
+
+const (
+	testSecondInputRID = "ri.foundry.main.dataset.33333333-3333-3333-3333-333333333333"
+	testThirdInputRID  = "ri.foundry.main.dataset.44444444-4444-4444-4444-444444444444"
+)
+
+// newMultiInputEnv serves three input datasets. Input readTable requests wait (up to a timeout)
+// until all three are in flight, so maxInFlight reaches 3 only if the reads are concurrent.
+func newMultiInputEnv(t *testing.T, inputs map[string]string) (*mockfoundry.Server, foundry.Env, func() int) {
+	t.Helper()
+
+	inputDir := t.TempDir()
+	for rid, csv := range inputs {
+		if err := os.WriteFile(filepath.Join(inputDir, rid+".csv"), []byte(csv), 0644); err != nil {
+			t.Fatalf("write input csv: %v", err)
+		}
+	}
+	mock := mockfoundry.New(inputDir, t.TempDir())
+	mock.RequireBearerToken("dummy-token")
+
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	allIn := make(chan struct{})
+	handler := mock.Handler()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		isInputRead := strings.Contains(r.URL.Path, "readTable") && !strings.Contains(r.URL.Path, testOutputRID)
+		if isInputRead {
+			mu.Lock()
+			inFlight++
+			maxInFlight = max(maxInFlight, inFlight)
+			if inFlight == len(inputs) {
+				close(allIn)
+			}
+			mu.Unlock()
+			select {
+			case <-allIn:
+			case <-time.After(2 * time.Second):
+			}
+			defer func() {
+				mu.Lock()
+				inFlight--
+				mu.Unlock()
+			}()
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	return mock, foundry.Env{
+		Services: foundry.Services{
+			APIGateway:  ts.URL + "/api",
+			StreamProxy: ts.URL + "/stream-proxy/api",
+		},
+		Token: "dummy-token",
+		Aliases: map[string]foundry.DatasetRef{
+			"input":  {RID: testInputRID, Branch: "master"},
+			"second": {RID: testSecondInputRID, Branch: "master"},
+			"third":  {RID: testThirdInputRID, Branch: "master"},
+			"output": {RID: testOutputRID, Branch: "master"},
+		},
+	}, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return maxInFlight
+	}
+}
+
+func TestRunFoundry_ExtraInputAliasesReadConcurrentlyAndMerge(t *testing.T) {
+	t.Parallel()
+
+	mock, env, maxInFlight := newMultiInputEnv(t, map[string]string{
+		testInputRID:       "email\nalice@example.com\nbob@corp.test\n",
+		testSecondInputRID: "email\n bob@corp.test\ncarol@new.test\n",
+		testThirdInputRID:  "email\ncarol@new.test\ndave@z.test\nalice@example.com\n",
+	})
+	enricher := &countingEnricher{}
+	res, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:        "input",
+		ExtraInputAliases: []string{"second", "third"},
+		OutputAlias:       "output",
+		OutputWriteMode:   "dataset",
+	}, pipeline.Options{}, enricher)
+	if err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+	if got := maxInFlight(); got != 3 {
+		t.Fatalf("expected 3 concurrent input reads, got max %d", got)
+	}
+
+	uploads := mock.Uploads()
+	if len(uploads) != 1 {
+		t.Fatalf("expected 1 upload, got %d", len(uploads))
+	}
+	rows, err := pipeline.ReadCSV(bytes.NewReader(uploads[0].Bytes))
+	if err != nil {
+		t.Fatalf("parse uploaded csv: %v", err)
+	}
+	var got []string
+	for _, row := range rows {
+		got = append(got, row.Email)
+	}
+	want := []string{"alice@example.com", "bob@corp.test", "carol@new.test", "dave@z.test"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("unexpected merged emails:\nwant=%v\ngot=%v", want, got)
+	}
+	if res.Plan.InputRows != len(want) {
+		t.Fatalf("expected %d input rows, got %d", len(want), res.Plan.InputRows)
+	}
+	for _, email := range want {
+		if n := enricher.count(email); n != 1 {
+			t.Fatalf("%s: expected 1 enrich call, got %d", email, n)
+		}
+	}
+}
+
+func TestRunFoundry_ExtraInputAliasReadFailureNamesAlias(t *testing.T) {
+	t.Parallel()
+
+	// The third input has no seeded data, so its read fails.
+	_, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	env.Aliases["third"] = foundry.DatasetRef{RID: testThirdInputRID, Branch: "master"}
+	enricher := &countingEnricher{}
+	_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:        "input",
+		ExtraInputAliases: []string{"third"},
+		OutputAlias:       "output",
+		OutputWriteMode:   "dataset",
+		InputReadPolicy:   foundryio.ReadRetryPolicy{Attempts: 1},
+	}, pipeline.Options{}, enricher)
+	if err == nil || !strings.Contains(err.Error(), `input alias "third"`) {
+		t.Fatalf("expected a read error naming alias third, got %v", err)
+	}
+	if len(enricher.calls) != 0 {
+		t.Fatalf("expected no enrichment after a failed input read, got %v", enricher.calls)
+	}
+}
+
+func TestRunFoundry_ExtraInputAliasesRejectEmailColumns(t *testing.T) {
+	t.Parallel()
+
+	_, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:        "input",
+		ExtraInputAliases: []string{"input"},
+		OutputAlias:       "output",
+		EmailColumns:      []string{"email"},
+	}, pipeline.Options{}, testEnricher{})
+	if app.ClassifyFailure(err) != app.FailureConfig {
+		t.Fatalf("expected a config error, got %v", err)
+	}
+}
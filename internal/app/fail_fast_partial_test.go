@@ -0,0 +1,38 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+func TestRunFoundry_FailFastKeepPartialWritesCompletedRows(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email\nalice@example.com\nfail@corp.test\ncarol@new.test\n")
+	_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+	}, pipeline.Options{Workers: 1, FailFastKeepPartial: true}, failOnPrefixEnricher{})
+	if err == nil {
+		t.Fatalf("expected the first enrichment error to fail the run")
+	}
+
+	uploads := mock.Uploads()
+	if len(uploads) != 1 {
+		t.Fatalf("expected the completed rows to be written, got %d uploads", len(uploads))
+	}
+	rows, err := pipeline.ReadCSV(bytes.NewReader(uploads[0].Bytes))
+	if err != nil {
+		t.Fatalf("parse uploaded csv: %v", err)
+	}
+	// carol was never started, so she gets no row and the next run enriches her.
+	assertStatuses(t, rows, map[string]string{
+		"alice@example.com": "ok",
+		"fail@corp.test":    "error",
+	})
+}
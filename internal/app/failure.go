@@ -0,0 +1,73 @@
+package app
+
+import (
+	"errors"
+
+	foundryio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/foundry"
+)
+
+// FailureReason categorizes why a run failed, so orchestration can react without parsing messages.
+type FailureReason string
+
+const (
+	// FailureRun is any failure not covered by a more specific reason.
+	FailureRun FailureReason = "run_failed"
+	// FailureConfig means the options or alias map were invalid; retrying cannot help.
+	FailureConfig FailureReason = "config"
+	// FailureBudgetExceeded means --max-unique-enrich was exceeded with --on-budget-exceeded=fail.
+	FailureBudgetExceeded FailureReason = "budget_exceeded"
+	// FailureTransientExhausted means a transient failure outlasted its retries; a later run may pass.
+	FailureTransientExhausted FailureReason = "transient_exhausted"
+	// FailureStackReadOnly means Foundry rejected writes during read-only maintenance.
+	FailureStackReadOnly FailureReason = "stack_read_only"
+	// FailurePermissionDenied means Foundry denied access to an input or output.
+	FailurePermissionDenied FailureReason = "permission_denied"
+	// FailureThresholdExceeded means too many emails enriched in the run ended with status=error
+	// for --max-error-rate, --fail-on-any-error or --fail-on-error-count.
+	FailureThresholdExceeded FailureReason = "partial_threshold_exceeded"
+)
+
+// ErrInvalidConfig matches (via errors.Is) run errors caused by invalid options or a missing alias.
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+// ErrBudgetExceeded is wrapped by the error of a run that stopped because --max-unique-enrich was
+// exceeded with --on-budget-exceeded=fail.
+var ErrBudgetExceeded = errors.New("enrichment budget exceeded")
+
+// configError marks err as ErrInvalidConfig without changing its message.
+type configError struct{ err error }
+
+func (e configError) Error() string        { return e.err.Error() }
+func (e configError) Unwrap() error        { return e.err }
+func (e configError) Is(target error) bool { return target == ErrInvalidConfig }
+
+// invalidConfig wraps a validation error so ClassifyFailure reports FailureConfig.
+func invalidConfig(err error) error {
+	if err == nil {
+		return nil
+	}
+	return configError{err: err}
+}
+
+// ClassifyFailure returns the FailureReason for an error returned by a Run or Verify function. A
+// nil error has no reason and returns "".
+func ClassifyFailure(err error) FailureReason {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrInvalidConfig):
+		return FailureConfig
+	case errors.Is(err, ErrBudgetExceeded):
+		return FailureBudgetExceeded
+	case errors.Is(err, ErrErrorRateExceeded), errors.Is(err, ErrErrorCountExceeded):
+		return FailureThresholdExceeded
+	case errors.Is(err, foundryio.ErrStackReadOnly):
+		return FailureStackReadOnly
+	case isPermissionDeniedError(err):
+		return FailurePermissionDenied
+	case errors.Is(err, foundryio.ErrWriteRetryBudgetExhausted), foundryio.IsTransient(err), isRetryableError(err):
+		return FailureTransientExhausted
+	default:
+		return FailureRun
+	}
+}
@@ -0,0 +1,64 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+func TestClassifyFailure_RunErrors(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name  string
+		fopts app.FoundryOptions
+		deny  bool
+		want  app.FailureReason
+	}{
+		{name: "invalid option", fopts: app.FoundryOptions{OutputSort: "company"}, want: app.FailureConfig},
+		{name: "missing alias", fopts: app.FoundryOptions{IndexAlias: "missing"}, want: app.FailureConfig},
+		{name: "budget exceeded", fopts: app.FoundryOptions{MaxUniqueEnrich: 1, OnBudgetExceeded: "fail"}, want: app.FailureBudgetExceeded},
+		{name: "permission denied", deny: true, want: app.FailurePermissionDenied},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock, env := newMockFoundryEnv(t, "email\nalice@example.com\nbob@corp.test\n")
+			if tc.deny {
+				mock.DenyWrites(testOutputRID)
+			}
+			fopts := tc.fopts
+			fopts.InputAlias, fopts.OutputAlias, fopts.OutputWriteMode = "input", "output", "dataset"
+			_, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{}, testEnricher{})
+			if err == nil {
+				t.Fatalf("expected run to fail")
+			}
+			if got := app.ClassifyFailure(err); got != tc.want {
+				t.Fatalf("ClassifyFailure=%q want %q (err=%v)", got, tc.want, err)
+			}
+		})
+	}
+}
+
+func TestClassifyFailure_KeepsMessages(t *testing.T) {
+	t.Parallel()
+
+	_, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:  "input",
+		OutputAlias: "missing",
+	}, pipeline.Options{}, testEnricher{})
+	if !errors.Is(err, app.ErrInvalidConfig) {
+		t.Fatalf("expected ErrInvalidConfig, got %v", err)
+	}
+	if err.Error() != `missing alias "missing" in RESOURCE_ALIAS_MAP` {
+		t.Fatalf("config classification must not change the message, got %q", err.Error())
+	}
+	if got := app.ClassifyFailure(nil); got != "" {
+		t.Fatalf("ClassifyFailure(nil)=%q want empty", got)
+	}
+}
@@ -0,0 +1,101 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	localio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/local"
+)
+
+// filterDomainColumn is the pseudo-column holding the domain of the row's email.
+const filterDomainColumn = "domain"
+
+// inputFilter selects which input rows are enriched. A row is kept when it matches every condition;
+// an empty filter keeps all rows.
+type inputFilter []filterCondition
+
+// filterCondition matches when the column's value equals one of values (or, when negate is set,
+// none of them). Values are compared case-insensitively after trimming.
+type filterCondition struct {
+	column string
+	values []string
+	negate bool
+}
+
+// parseInputFilter parses --input-filter conditions of the form column=v1|v2 or column!=v1|v2.
+// The column "domain" refers to the email's domain rather than an input column.
+func parseInputFilter(specs []string) (inputFilter, error) {
+	var f inputFilter
+	for _, spec := range specs {
+		col, vals, ok := strings.Cut(spec, "=")
+		negate := strings.HasSuffix(col, "!")
+		col = strings.TrimSpace(strings.TrimSuffix(col, "!"))
+		if !ok || col == "" || strings.TrimSpace(vals) == "" {
+			return nil, fmt.Errorf("invalid input filter %q (expected column=value[|value...] or column!=value[|value...])", spec)
+		}
+		var values []string
+		for _, v := range strings.Split(vals, "|") {
+			values = append(values, strings.TrimSpace(v))
+		}
+		f = append(f, filterCondition{column: col, values: values, negate: negate})
+	}
+	return f, nil
+}
+
+// columns returns the input columns the filter reads, excluding the domain pseudo-column.
+func (f inputFilter) columns() []string {
+	var cols []string
+	for _, c := range f {
+		if strings.EqualFold(c.column, filterDomainColumn) {
+			continue
+		}
+		dup := false
+		for _, seen := range cols {
+			dup = dup || strings.EqualFold(seen, c.column)
+		}
+		if !dup {
+			cols = append(cols, c.column)
+		}
+	}
+	return cols
+}
+
+// keep reports, per input email, whether the row passes the filter. Column values are read from
+// items[i].Passthrough starting at offset, in columns() order. It returns nil for an empty filter.
+func (f inputFilter) keep(emails []string, items []localio.EmailItem, offset int) []bool {
+	if len(f) == 0 {
+		return nil
+	}
+	cols := f.columns()
+	out := make([]bool, len(emails))
+	for i, email := range emails {
+		out[i] = true
+		for _, c := range f {
+			var value string
+			if strings.EqualFold(c.column, filterDomainColumn) {
+				value = emailDomain(email)
+			} else if i < len(items) {
+				for j, col := range cols {
+					if strings.EqualFold(col, c.column) && offset+j < len(items[i].Passthrough) {
+						value = items[i].Passthrough[offset+j]
+					}
+				}
+			}
+			if !c.matches(value) {
+				out[i] = false
+				break
+			}
+		}
+	}
+	return out
+}
+
+func (c filterCondition) matches(value string) bool {
+	value = strings.TrimSpace(value)
+	for _, v := range c.values {
+		if strings.EqualFold(value, v) {
+			return !c.negate
+		}
+	}
+	return c.negate
+}
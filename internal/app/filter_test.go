@@ -0,0 +1,120 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+// rowOutcome returns the row's status, followed by ":<skip_reason>" for skipped rows.
+func rowOutcome(row pipeline.Row) string {
+	if row.Status == pipeline.StatusSkipped {
+		return row.Status + ":" + string(row.SkipReason)
+	}
+	return row.Status
+}
+
+func assertStatuses(t *testing.T, rows []pipeline.Row, want map[string]string) {
+	t.Helper()
+	if len(rows) != len(want) {
+		t.Fatalf("expected %d rows, got %d: %#v", len(want), len(rows), rows)
+	}
+	for _, row := range rows {
+		if got := rowOutcome(row); got != want[row.Email] {
+			t.Fatalf("row %s: want status %q, got %q", row.Email, want[row.Email], got)
+		}
+	}
+}
+
+func TestRunLocal_InputFilterExcludesDomains(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.csv")
+	outputPath := filepath.Join(dir, "output.csv")
+	input := "email\nalice@example.com\nbob@Gmail.com\ncarol@yahoo.com\ndave@corp.test\n"
+	if err := os.WriteFile(inputPath, []byte(input), 0644); err != nil {
+		t.Fatalf("write input csv: %v", err)
+	}
+
+	enricher := &countingEnricher{}
+	if _, err := app.RunLocalWithOptions(context.Background(), app.LocalOptions{
+		InputPath:   inputPath,
+		OutputPath:  outputPath,
+		InputFilter: []string{"domain!=gmail.com|yahoo.com"},
+	}, pipeline.Options{}, enricher); err != nil {
+		t.Fatalf("RunLocalWithOptions failed: %v", err)
+	}
+
+	for _, email := range []string{"bob@Gmail.com", "carol@yahoo.com"} {
+		if got := enricher.count(email); got != 0 {
+			t.Fatalf("expected filtered %s not to be enriched, got %d calls", email, got)
+		}
+	}
+	b, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	rows, err := pipeline.ReadCSV(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("parse output csv: %v", err)
+	}
+	assertStatuses(t, rows, map[string]string{
+		"alice@example.com": "ok",
+		"bob@Gmail.com":     "skipped:filter",
+		"carol@yahoo.com":   "skipped:filter",
+		"dave@corp.test":    "ok",
+	})
+}
+
+func TestRunFoundry_InputFilterColumnEquality(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email,region\nalice@example.com,EU\nbob@corp.test,us\ncarol@new.test,eu\n")
+	enricher := &countingEnricher{}
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+		InputFilter:     []string{"region=eu"},
+	}, pipeline.Options{}, enricher); err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+
+	if got := enricher.count("bob@corp.test"); got != 0 {
+		t.Fatalf("expected filtered bob not to be enriched, got %d calls", got)
+	}
+	uploads := mock.Uploads()
+	if len(uploads) != 1 {
+		t.Fatalf("expected 1 upload, got %d", len(uploads))
+	}
+	rows, err := pipeline.ReadCSV(bytes.NewReader(uploads[0].Bytes))
+	if err != nil {
+		t.Fatalf("parse uploaded csv: %v", err)
+	}
+	assertStatuses(t, rows, map[string]string{
+		"alice@example.com": "ok",
+		"bob@corp.test":     "skipped:filter",
+		"carol@new.test":    "ok",
+	})
+}
+
+func TestRunFoundry_InvalidInputFilterFails(t *testing.T) {
+	t.Parallel()
+
+	_, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+		InputFilter:     []string{"region"},
+	}, pipeline.Options{}, testEnricher{})
+	if err == nil {
+		t.Fatalf("expected invalid input filter to fail")
+	}
+}
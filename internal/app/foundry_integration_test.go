@@ -72,13 +72,21 @@ func TestRunFoundry_EndToEndAgainstMock(t *testing.T) {
 		},
 	}
 
-	if err := app.RunFoundry(context.Background(), env, "input", "output", "enriched.csv", "auto", pipeline.Options{}, testEnricher{}); err != nil {
-		t.Fatalf("RunFoundry failed: %v", err)
-	}
-
-	calls := mock.Calls()
-	if len(calls) != 8 {
-		t.Fatalf("expected 8 calls, got %d: %#v", len(calls), calls)
+	// The output has never been committed, so the sequence below skips the prior-output not-found
+	// re-reads a first run would otherwise make.
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:                 "input",
+		OutputAlias:                "output",
+		OutputFilename:             "enriched.csv",
+		OutputWriteMode:            "auto",
+		PriorOutputNotFoundRetries: -1,
+	}, pipeline.Options{}, testEnricher{}); err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+
+	calls := startupInOrder(mock.Calls(), outputRID)
+	if len(calls) != 10 {
+		t.Fatalf("expected 10 calls, got %d: %#v", len(calls), calls)
 	}
 	if calls[0].Path != "/api/v2/datasets/"+inputRID+"/branches/master" {
 		t.Fatalf("call[0] path: want %q, got %q (all calls=%#v)", "/api/v2/datasets/"+inputRID+"/branches/master", calls[0].Path, calls)
@@ -96,23 +104,31 @@ func TestRunFoundry_EndToEndAgainstMock(t *testing.T) {
 	if calls[4].Path != "/api/v2/datasets/"+outputRID+"/readTable" {
 		t.Fatalf("call[4] path: want %q, got %q (all calls=%#v)", "/api/v2/datasets/"+outputRID+"/readTable", calls[4].Path, calls)
 	}
+	// Write-permission preflight: open and abort a probe transaction before enriching.
 	if calls[5].Path != "/api/v2/datasets/"+outputRID+"/transactions" {
 		t.Fatalf("call[5] path: want %q, got %q (all calls=%#v)", "/api/v2/datasets/"+outputRID+"/transactions", calls[5].Path, calls)
 	}
+	txnPrefix := "/api/v2/datasets/" + outputRID + "/transactions/"
+	if !strings.HasPrefix(calls[6].Path, txnPrefix) || !strings.HasSuffix(calls[6].Path, "/abort") {
+		t.Fatalf("call[6] path: expected probe transaction abort, got %q (all calls=%#v)", calls[6].Path, calls)
+	}
+	if calls[7].Path != "/api/v2/datasets/"+outputRID+"/transactions" {
+		t.Fatalf("call[7] path: want %q, got %q (all calls=%#v)", "/api/v2/datasets/"+outputRID+"/transactions", calls[7].Path, calls)
+	}
 
 	wantUploadPath := "/api/v2/datasets/" + outputRID + "/files/enriched.csv/upload"
-	if calls[6].Path != wantUploadPath {
-		t.Fatalf("call[6] path: want %q, got %q (all calls=%#v)", wantUploadPath, calls[6].Path, calls)
+	if calls[8].Path != wantUploadPath {
+		t.Fatalf("call[8] path: want %q, got %q (all calls=%#v)", wantUploadPath, calls[8].Path, calls)
 	}
 
-	commitPrefix := "/api/v2/datasets/" + outputRID + "/transactions/"
+	commitPrefix := txnPrefix
 	commitSuffix := "/commit"
-	if !strings.HasPrefix(calls[7].Path, commitPrefix) || !strings.HasSuffix(calls[7].Path, commitSuffix) {
-		t.Fatalf("call[7] path: expected prefix %q and suffix %q, got %q (all calls=%#v)", commitPrefix, commitSuffix, calls[7].Path, calls)
+	if !strings.HasPrefix(calls[9].Path, commitPrefix) || !strings.HasSuffix(calls[9].Path, commitSuffix) {
+		t.Fatalf("call[9] path: expected prefix %q and suffix %q, got %q (all calls=%#v)", commitPrefix, commitSuffix, calls[9].Path, calls)
 	}
-	txnID := strings.TrimSuffix(strings.TrimPrefix(calls[7].Path, commitPrefix), commitSuffix)
+	txnID := strings.TrimSuffix(strings.TrimPrefix(calls[9].Path, commitPrefix), commitSuffix)
 	if strings.TrimSpace(txnID) == "" {
-		t.Fatalf("call[7] path: failed to extract transaction id from %q", calls[7].Path)
+		t.Fatalf("call[9] path: failed to extract transaction id from %q", calls[9].Path)
 	}
 
 	uploads := mock.Uploads()
@@ -166,14 +182,14 @@ func TestRunFoundry_EndToEndAgainstMock(t *testing.T) {
 
 	// Verify the extra readTable call was recorded.
 	calls = mock.Calls()
-	if len(calls) != 10 {
-		t.Fatalf("expected 10 calls after readTable, got %d: %#v", len(calls), calls)
+	if len(calls) != 12 {
+		t.Fatalf("expected 12 calls after readTable, got %d: %#v", len(calls), calls)
 	}
-	if calls[8].Path != "/api/v2/datasets/"+outputRID+"/branches/master" {
-		t.Fatalf("call[8] path: want %q, got %q (all calls=%#v)", "/api/v2/datasets/"+outputRID+"/branches/master", calls[8].Path, calls)
+	if calls[10].Path != "/api/v2/datasets/"+outputRID+"/branches/master" {
+		t.Fatalf("call[10] path: want %q, got %q (all calls=%#v)", "/api/v2/datasets/"+outputRID+"/branches/master", calls[10].Path, calls)
 	}
-	if calls[9].Path != "/api/v2/datasets/"+outputRID+"/readTable" {
-		t.Fatalf("call[9] path: want %q, got %q (all calls=%#v)", "/api/v2/datasets/"+outputRID+"/readTable", calls[9].Path, calls)
+	if calls[11].Path != "/api/v2/datasets/"+outputRID+"/readTable" {
+		t.Fatalf("call[11] path: want %q, got %q (all calls=%#v)", "/api/v2/datasets/"+outputRID+"/readTable", calls[11].Path, calls)
 	}
 }
 
@@ -230,7 +246,7 @@ func TestRunFoundry_StreamMode_ContinuesWhenPriorOutputReadForbidden(t *testing.
 		},
 	}
 
-	if err := app.RunFoundry(context.Background(), env, "input", "output", "", "auto", pipeline.Options{}, testEnricher{}); err != nil {
+	if _, err := app.RunFoundry(context.Background(), env, "input", "output", "", "auto", pipeline.Options{}, testEnricher{}); err != nil {
 		t.Fatalf("RunFoundry failed: %v", err)
 	}
 
@@ -330,7 +346,7 @@ func TestRunFoundry_StreamMode_UsesStreamCacheWhenDatasetReadForbidden(t *testin
 		t.Fatalf("seed stream record: %v", err)
 	}
 
-	if err := app.RunFoundry(context.Background(), env, "input", "output", "", "auto", pipeline.Options{}, testEnricher{}); err != nil {
+	if _, err := app.RunFoundry(context.Background(), env, "input", "output", "", "auto", pipeline.Options{}, testEnricher{}); err != nil {
 		t.Fatalf("RunFoundry failed: %v", err)
 	}
 
@@ -434,7 +450,7 @@ func TestRunFoundry_StreamMode_ParsesWrappedStreamRecordsResponse(t *testing.T)
 	}
 
 	enricher := &countingEnricher{}
-	if err := app.RunFoundry(context.Background(), env, "input", "output", "", "auto", pipeline.Options{}, enricher); err != nil {
+	if _, err := app.RunFoundry(context.Background(), env, "input", "output", "", "auto", pipeline.Options{}, enricher); err != nil {
 		t.Fatalf("RunFoundry failed: %v", err)
 	}
 	if enricher.count("alice@example.com") != 0 {
@@ -490,13 +506,21 @@ func TestRunFoundry_UsesExistingOpenTransactionWhenCreateConflicts(t *testing.T)
 		},
 	}
 
-	if err := app.RunFoundry(context.Background(), env, "input", "output", "enriched.csv", "auto", pipeline.Options{}, testEnricher{}); err != nil {
-		t.Fatalf("RunFoundry failed: %v", err)
+	// The output has never been committed, so the sequence below skips the prior-output not-found
+	// re-reads a first run would otherwise make.
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:                 "input",
+		OutputAlias:                "output",
+		OutputFilename:             "enriched.csv",
+		OutputWriteMode:            "auto",
+		PriorOutputNotFoundRetries: -1,
+	}, pipeline.Options{}, testEnricher{}); err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
 	}
 
-	calls := mock.Calls()[beforeCalls:]
-	if len(calls) != 8 {
-		t.Fatalf("expected 8 calls, got %d: %#v", len(calls), calls)
+	calls := startupInOrder(mock.Calls()[beforeCalls:], outputRID)
+	if len(calls) != 9 {
+		t.Fatalf("expected 9 calls, got %d: %#v", len(calls), calls)
 	}
 	if calls[0].Method != "GET" || calls[0].Path != "/api/v2/datasets/"+inputRID+"/branches/master" {
 		t.Fatalf("call[0] mismatch: %#v (all calls=%#v)", calls[0], calls)
@@ -514,16 +538,20 @@ func TestRunFoundry_UsesExistingOpenTransactionWhenCreateConflicts(t *testing.T)
 	if calls[4].Method != "GET" || calls[4].Path != "/api/v2/datasets/"+outputRID+"/readTable" {
 		t.Fatalf("call[4] mismatch: %#v (all calls=%#v)", calls[4], calls)
 	}
+	// The write-permission preflight create conflicts with the build's transaction, which counts as writable.
 	if calls[5].Method != "POST" || calls[5].Path != "/api/v2/datasets/"+outputRID+"/transactions" {
 		t.Fatalf("call[5] mismatch: %#v (all calls=%#v)", calls[5], calls)
 	}
-	if calls[6].Method != "GET" || calls[6].Path != "/api/v2/datasets/"+outputRID+"/transactions" {
+	if calls[6].Method != "POST" || calls[6].Path != "/api/v2/datasets/"+outputRID+"/transactions" {
 		t.Fatalf("call[6] mismatch: %#v (all calls=%#v)", calls[6], calls)
 	}
+	if calls[7].Method != "GET" || calls[7].Path != "/api/v2/datasets/"+outputRID+"/transactions" {
+		t.Fatalf("call[7] mismatch: %#v (all calls=%#v)", calls[7], calls)
+	}
 
 	wantUploadPath := "/api/v2/datasets/" + outputRID + "/files/enriched.csv/upload"
-	if calls[7].Method != "POST" || calls[7].Path != wantUploadPath {
-		t.Fatalf("call[7] mismatch: %#v (all calls=%#v)", calls[7], calls)
+	if calls[8].Method != "POST" || calls[8].Path != wantUploadPath {
+		t.Fatalf("call[8] mismatch: %#v (all calls=%#v)", calls[8], calls)
 	}
 
 	uploads := mock.Uploads()
@@ -559,6 +587,24 @@ func (c *countingEnricher) Enrich(_ context.Context, email string) (enrich.Resul
 	}, nil
 }
 
+// startupInOrder returns calls with the output-mode probe moved after the input read. The run reads
+// the input and probes the output concurrently, so the first three calls interleave arbitrarily.
+func startupInOrder(calls []mockfoundry.Call, outputRID string) []mockfoundry.Call {
+	if len(calls) < 3 {
+		return calls
+	}
+	probePath := "/stream-proxy/api/streams/" + outputRID + "/branches/master/records"
+	var input, probe []mockfoundry.Call
+	for _, call := range calls[:3] {
+		if call.Path == probePath {
+			probe = append(probe, call)
+		} else {
+			input = append(input, call)
+		}
+	}
+	return slices.Concat(input, probe, calls[3:])
+}
+
 func (c *countingEnricher) count(email string) int {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -601,7 +647,7 @@ func TestRunFoundry_IncrementalDatasetSkipsCachedRows(t *testing.T) {
 
 	enricher := &countingEnricher{}
 
-	if err := app.RunFoundry(context.Background(), env, "input", "output", "enriched.csv", "dataset", pipeline.Options{}, enricher); err != nil {
+	if _, err := app.RunFoundry(context.Background(), env, "input", "output", "enriched.csv", "dataset", pipeline.Options{}, enricher); err != nil {
 		t.Fatalf("first RunFoundry failed: %v", err)
 	}
 	if enricher.count("alice@example.com") != 1 || enricher.count("bob@corp.test") != 1 {
@@ -610,7 +656,7 @@ func TestRunFoundry_IncrementalDatasetSkipsCachedRows(t *testing.T) {
 
 	writeInput("email\nalice@example.com\nbob@corp.test\ncarol@new.test\n")
 
-	if err := app.RunFoundry(context.Background(), env, "input", "output", "enriched.csv", "dataset", pipeline.Options{}, enricher); err != nil {
+	if _, err := app.RunFoundry(context.Background(), env, "input", "output", "enriched.csv", "dataset", pipeline.Options{}, enricher); err != nil {
 		t.Fatalf("second RunFoundry failed: %v", err)
 	}
 
@@ -662,7 +708,7 @@ func TestRunFoundry_IncrementalStreamSkipsCachedRows(t *testing.T) {
 
 	enricher := &countingEnricher{}
 
-	if err := app.RunFoundry(context.Background(), env, "input", "output", "enriched.csv", "auto", pipeline.Options{}, enricher); err != nil {
+	if _, err := app.RunFoundry(context.Background(), env, "input", "output", "enriched.csv", "auto", pipeline.Options{}, enricher); err != nil {
 		t.Fatalf("first RunFoundry failed: %v", err)
 	}
 	if enricher.count("alice@example.com") != 1 || enricher.count("bob@corp.test") != 1 {
@@ -672,7 +718,7 @@ func TestRunFoundry_IncrementalStreamSkipsCachedRows(t *testing.T) {
 		t.Fatalf("expected 2 stream records after first run, got %d: %#v", len(recs), recs)
 	}
 
-	if err := app.RunFoundry(context.Background(), env, "input", "output", "enriched.csv", "auto", pipeline.Options{}, enricher); err != nil {
+	if _, err := app.RunFoundry(context.Background(), env, "input", "output", "enriched.csv", "auto", pipeline.Options{}, enricher); err != nil {
 		t.Fatalf("second RunFoundry failed: %v", err)
 	}
 	if enricher.count("alice@example.com") != 1 {
@@ -686,7 +732,7 @@ func TestRunFoundry_IncrementalStreamSkipsCachedRows(t *testing.T) {
 	}
 
 	writeInput("email\nalice@example.com\nbob@corp.test\ncarol@new.test\n")
-	if err := app.RunFoundry(context.Background(), env, "input", "output", "enriched.csv", "auto", pipeline.Options{}, enricher); err != nil {
+	if _, err := app.RunFoundry(context.Background(), env, "input", "output", "enriched.csv", "auto", pipeline.Options{}, enricher); err != nil {
 		t.Fatalf("third RunFoundry failed: %v", err)
 	}
 	if enricher.count("carol@new.test") != 1 {
@@ -732,13 +778,13 @@ func TestRunFoundry_WritesToStreamProxyWhenOutputIsStream(t *testing.T) {
 		},
 	}
 
-	if err := app.RunFoundry(context.Background(), env, "input", "output", "enriched.csv", "auto", pipeline.Options{}, testEnricher{}); err != nil {
+	if _, err := app.RunFoundry(context.Background(), env, "input", "output", "enriched.csv", "auto", pipeline.Options{}, testEnricher{}); err != nil {
 		t.Fatalf("RunFoundry failed: %v", err)
 	}
 
-	calls := mock.Calls()
-	if len(calls) != 6 {
-		t.Fatalf("expected 6 calls, got %d: %#v", len(calls), calls)
+	calls := startupInOrder(mock.Calls(), outputRID)
+	if len(calls) != 7 {
+		t.Fatalf("expected 7 calls, got %d: %#v", len(calls), calls)
 	}
 	if calls[0].Method != "GET" || calls[0].Path != "/api/v2/datasets/"+inputRID+"/branches/master" {
 		t.Fatalf("call[0] mismatch: %#v (all calls=%#v)", calls[0], calls)
@@ -754,13 +800,18 @@ func TestRunFoundry_WritesToStreamProxyWhenOutputIsStream(t *testing.T) {
 	if calls[3].Method != "GET" || calls[3].Path != wantProbePath {
 		t.Fatalf("call[3] mismatch: %#v (all calls=%#v)", calls[3], calls)
 	}
-	wantPublishPath := "/stream-proxy/api/streams/" + outputRID + "/branches/master/jsonRecord"
-	if calls[4].Method != "POST" || calls[4].Path != wantPublishPath {
+	// Write-permission preflight: an empty batch publish.
+	wantProbePublishPath := "/stream-proxy/api/streams/" + outputRID + "/branches/master/jsonRecords"
+	if calls[4].Method != "POST" || calls[4].Path != wantProbePublishPath {
 		t.Fatalf("call[4] mismatch: %#v (all calls=%#v)", calls[4], calls)
 	}
+	wantPublishPath := "/stream-proxy/api/streams/" + outputRID + "/branches/master/jsonRecord"
 	if calls[5].Method != "POST" || calls[5].Path != wantPublishPath {
 		t.Fatalf("call[5] mismatch: %#v (all calls=%#v)", calls[5], calls)
 	}
+	if calls[6].Method != "POST" || calls[6].Path != wantPublishPath {
+		t.Fatalf("call[6] mismatch: %#v (all calls=%#v)", calls[6], calls)
+	}
 
 	recs := mock.StreamRecords(outputRID, "master")
 	if len(recs) != 2 {
@@ -864,7 +915,7 @@ func TestRunFoundry_StreamPublishesBeforeAllRowsFinish(t *testing.T) {
 	startedSlow := make(chan struct{})
 	done := make(chan error, 1)
 	go func() {
-		done <- app.RunFoundry(
+		_, err := app.RunFoundry(
 			context.Background(),
 			env,
 			"input",
@@ -874,6 +925,7 @@ func TestRunFoundry_StreamPublishesBeforeAllRowsFinish(t *testing.T) {
 			pipeline.Options{Workers: 2},
 			&blockingStreamEnricher{releaseSlow: releaseSlow, startedSlow: startedSlow},
 		)
+		done <- err
 	}()
 
 	select {
@@ -65,7 +65,7 @@ func TestRunLocal_RealGemini_EndToEnd(t *testing.T) {
 			t.Fatalf("write input: %v", err)
 		}
 
-		if err := app.RunLocal(ctx, inputPath, outputPath, pipeline.Options{
+		if _, err := app.RunLocal(ctx, inputPath, outputPath, pipeline.Options{
 			Workers:        1,
 			MaxRetries:     2,
 			RequestTimeout: 30 * time.Second,
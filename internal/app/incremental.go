@@ -2,7 +2,10 @@ package app
 
 import (
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
 )
@@ -15,7 +18,9 @@ type incrementalPlan struct {
 	pendingRows   int
 }
 
-func buildIncrementalPlan(inputEmails []string, existingByEmail map[string]pipeline.Row) incrementalPlan {
+// buildIncrementalPlan reuses the rows of existingByEmail that cachedRow keeps, with maxPartial as
+// the resolved partialAttemptsLimit, and plans the remaining emails for enrichment.
+func buildIncrementalPlan(inputEmails []string, existingByEmail map[string]pipeline.Row, maxPartial int) incrementalPlan {
 	plan := incrementalPlan{
 		rows:       make([]pipeline.Row, len(inputEmails)),
 		pendingIdx: make(map[string][]int),
@@ -24,7 +29,7 @@ func buildIncrementalPlan(inputEmails []string, existingByEmail map[string]pipel
 		email := strings.TrimSpace(raw)
 		key := emailKey(email)
 
-		if prev, ok := existingByEmail[key]; ok && strings.EqualFold(strings.TrimSpace(prev.Status), "ok") {
+		if prev, ok := existingByEmail[key]; ok && cachedRow(prev, maxPartial) {
 			prev.Email = email
 			plan.rows[i] = prev
 			plan.cachedRows++
@@ -40,6 +45,138 @@ func buildIncrementalPlan(inputEmails []string, existingByEmail map[string]pipel
 	return plan
 }
 
+// cachedRow reports whether a prior row is reused instead of enriched again: ok rows, blocked rows
+// (a provider block is deterministic for an email, so enriching it again would only be billed
+// again), and partial rows whose pipeline.PartialAttemptsColumn count has reached maxPartial (when
+// positive).
+func cachedRow(row pipeline.Row, maxPartial int) bool {
+	status := strings.TrimSpace(row.Status)
+	if strings.EqualFold(status, "ok") || strings.EqualFold(status, pipeline.StatusBlocked) {
+		return true
+	}
+	return maxPartial > 0 && strings.EqualFold(status, pipeline.StatusPartial) && partialAttempts(row) >= maxPartial
+}
+
+// partialAttempts returns row's pipeline.PartialAttemptsColumn count, or 0 when it is missing or
+// unparseable.
+func partialAttempts(row pipeline.Row) int {
+	n, err := strconv.Atoi(strings.TrimSpace(row.Extra[pipeline.PartialAttemptsColumn]))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// partialAttemptsLimit resolves pipeline.Options.MaxPartialAttempts: zero is
+// pipeline.DefaultMaxPartialAttempts, and a negative value is 0, for no limit.
+func partialAttemptsLimit(n int) int {
+	switch {
+	case n == 0:
+		return pipeline.DefaultMaxPartialAttempts
+	case n < 0:
+		return 0
+	}
+	return n
+}
+
+// withPriorPartialAttempts sets opts.PriorPartialAttempts from the incremental cache, so partial rows
+// enriched again carry their count forward.
+func withPriorPartialAttempts(opts pipeline.Options, existingByEmail map[string]pipeline.Row) pipeline.Options {
+	if opts.PriorPartialAttempts != nil {
+		return opts
+	}
+	opts.PriorPartialAttempts = func(email string) int {
+		prev, ok := existingByEmail[emailKey(email)]
+		if !ok || !strings.EqualFold(strings.TrimSpace(prev.Status), pipeline.StatusPartial) {
+			return 0
+		}
+		return partialAttempts(prev)
+	}
+	return opts
+}
+
+// logPartialRows returns a row callback logging each row the run marks partial, or nil when
+// opts.MinCompleteness is off.
+func logPartialRows(opts pipeline.Options, logf func(format string, args ...any)) func(pipeline.Row) error {
+	if opts.MinCompleteness <= 0 {
+		return nil
+	}
+	return func(row pipeline.Row) error {
+		if strings.EqualFold(strings.TrimSpace(row.Status), pipeline.StatusPartial) {
+			logf(
+				"partial enrichment: email=%q completeness=%s minCompleteness=%v attempts=%s",
+				row.Email,
+				row.Completeness,
+				opts.MinCompleteness,
+				row.Extra[pipeline.PartialAttemptsColumn],
+			)
+		}
+		return nil
+	}
+}
+
+// dropEmptyOKRows removes ok rows that fill none of pipeline.CompletenessFields from the incremental
+// cache, so those emails are enriched again. It returns the number of rows removed.
+func dropEmptyOKRows(existingByEmail map[string]pipeline.Row) int {
+	dropped := 0
+	for key, row := range existingByEmail {
+		if !strings.EqualFold(strings.TrimSpace(row.Status), "ok") {
+			continue
+		}
+		if row.FilledFields() == 0 {
+			delete(existingByEmail, key)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// recacheEmptyOKRows applies FoundryOptions.RecacheEmptyOK to the incremental cache.
+func recacheEmptyOKRows(existingByEmail map[string]pipeline.Row, enabled bool, logf func(format string, args ...any)) {
+	if !enabled {
+		return
+	}
+	if n := dropEmptyOKRows(existingByEmail); n > 0 {
+		logf("recache-empty-ok: re-enriching %d prior ok rows with no enrichment fields", n)
+	}
+}
+
+// dropStaleOKRows removes ok rows whose pipeline.WrittenAtColumn is at least ttl old at now, or is
+// missing or unparseable, from the incremental cache, so those emails are enriched again. It
+// returns the number of rows removed.
+func dropStaleOKRows(existingByEmail map[string]pipeline.Row, ttl time.Duration, now time.Time) int {
+	dropped := 0
+	for key, row := range existingByEmail {
+		if !strings.EqualFold(strings.TrimSpace(row.Status), "ok") {
+			continue
+		}
+		writtenAt, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(row.Extra[pipeline.WrittenAtColumn]))
+		if err != nil || now.Sub(writtenAt) >= ttl {
+			delete(existingByEmail, key)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// reenrichStaleRows applies pipeline.Options.ReenrichAfter to the incremental cache. A nil ttl
+// disables it.
+func reenrichStaleRows(existingByEmail map[string]pipeline.Row, ttl *time.Duration, logf func(format string, args ...any)) {
+	if ttl == nil {
+		return
+	}
+	if n := dropStaleOKRows(existingByEmail, *ttl, time.Now()); n > 0 {
+		logf("reenrich-after: re-enriching %d prior ok rows written at least %s ago (or without written_at)", n, *ttl)
+	}
+}
+
+func validateReenrichAfter(ttl *time.Duration) error {
+	if ttl != nil && *ttl < 0 {
+		return fmt.Errorf("reenrich-after must be >= 0, got %s", *ttl)
+	}
+	return nil
+}
+
 func (p *incrementalPlan) applyEnrichedRows(rows []pipeline.Row) error {
 	if len(rows) != len(p.pendingEmails) {
 		return fmt.Errorf("incremental enrichment mismatch: got %d rows for %d pending emails", len(rows), len(p.pendingEmails))
@@ -59,6 +196,132 @@ func (p *incrementalPlan) applyEnrichedRows(rows []pipeline.Row) error {
 	return nil
 }
 
+// truncatePending limits the plan to its first max pending emails (in input order).
+//
+// Rows for the deferred emails are marked skipped with pipeline.SkipReasonBudget so they are written
+// (dataset mode) without being treated as cached, and are picked up again by the next run. It
+// returns the deferred emails.
+func (p *incrementalPlan) truncatePending(max int) []string {
+	if max < 0 || len(p.pendingEmails) <= max {
+		return nil
+	}
+	deferred := append([]string(nil), p.pendingEmails[max:]...)
+	p.pendingEmails = p.pendingEmails[:max]
+	for _, email := range deferred {
+		key := emailKey(email)
+		for _, idx := range p.pendingIdx[key] {
+			p.rows[idx] = pipeline.SkippedRow(email, pipeline.SkipReasonBudget)
+			p.pendingRows--
+		}
+		delete(p.pendingIdx, key)
+	}
+	return deferred
+}
+
+// skip marks rows excluded from enrichment (keep[i] false) as skipped for reason and drops them
+// from the pending work, even when a cached row exists. keep is aligned with inputEmails; nil keeps
+// all rows. Rows an earlier skip already excluded keep their first reason. It returns the number of
+// rows newly skipped.
+func (p *incrementalPlan) skip(inputEmails []string, keep []bool, reason pipeline.SkipReason) int {
+	skipped := 0
+	for i, ok := range keep {
+		if ok || i >= len(p.rows) || i >= len(inputEmails) || p.rows[i].Status == pipeline.StatusSkipped {
+			continue
+		}
+		email := strings.TrimSpace(inputEmails[i])
+		key := emailKey(email)
+		if idxs, pending := p.pendingIdx[key]; pending && slices.Contains(idxs, i) {
+			idxs = slices.DeleteFunc(idxs, func(idx int) bool { return idx == i })
+			p.pendingRows--
+			if len(idxs) == 0 {
+				delete(p.pendingIdx, key)
+				p.pendingEmails = slices.DeleteFunc(p.pendingEmails, func(e string) bool { return emailKey(e) == key })
+			} else {
+				p.pendingIdx[key] = idxs
+			}
+		} else {
+			p.cachedRows--
+		}
+		p.rows[i] = pipeline.SkippedRow(email, reason)
+		skipped++
+	}
+	return skipped
+}
+
+const (
+	budgetExceededFail     = "fail"
+	budgetExceededTruncate = "truncate"
+)
+
+func normalizeBudgetExceededMode(mode string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", budgetExceededFail:
+		return budgetExceededFail, nil
+	case budgetExceededTruncate:
+		return budgetExceededTruncate, nil
+	default:
+		return "", fmt.Errorf("invalid on-budget-exceeded mode %q (expected fail|truncate)", mode)
+	}
+}
+
+// enforceEnrichBudget applies the max-unique-enrich guardrail to the plan. maxUnique <= 0 disables it.
+func enforceEnrichBudget(
+	p *incrementalPlan,
+	maxUnique int,
+	mode string,
+	logf func(format string, args ...any),
+) error {
+	if maxUnique <= 0 || len(p.pendingEmails) <= maxUnique {
+		return nil
+	}
+	if mode != budgetExceededTruncate {
+		return fmt.Errorf(
+			"%w: %d unique emails pending, max-unique-enrich=%d",
+			ErrBudgetExceeded,
+			len(p.pendingEmails),
+			maxUnique,
+		)
+	}
+	pending := len(p.pendingEmails)
+	deferred := p.truncatePending(maxUnique)
+	logf(
+		"enrichment budget exceeded: pending=%d maxUniqueEnrich=%d enriching=%d deferred=%d firstDeferred=%q",
+		pending,
+		maxUnique,
+		len(p.pendingEmails),
+		len(deferred),
+		deferred[0],
+	)
+	return nil
+}
+
+const (
+	outputSortNone  = "none"
+	outputSortEmail = "email"
+)
+
+func normalizeOutputSort(mode string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", outputSortNone:
+		return outputSortNone, nil
+	case outputSortEmail:
+		return outputSortEmail, nil
+	default:
+		return "", fmt.Errorf("invalid output-sort %q (expected none|email)", mode)
+	}
+}
+
+// sortOutputRows orders dataset output rows in place. The email sort is stable, so rows sharing an
+// email (fanned-out duplicates) keep their input order.
+func sortOutputRows(rows []pipeline.Row, mode string) {
+	if mode != outputSortEmail {
+		return
+	}
+	slices.SortStableFunc(rows, func(a, b pipeline.Row) int {
+		return strings.Compare(strings.ToLower(emailKey(a.Email)), strings.ToLower(emailKey(b.Email)))
+	})
+}
+
 func chooseBestIncrementalRow(a, b pipeline.Row) pipeline.Row {
 	aOk := strings.EqualFold(strings.TrimSpace(a.Status), "ok")
 	bOk := strings.EqualFold(strings.TrimSpace(b.Status), "ok")
@@ -78,13 +341,24 @@ func emailKey(email string) string {
 	return strings.TrimSpace(email)
 }
 
+// CancelKey normalizes an email for a worker.Canceler: emailKey, lower-cased, so a cancelEmail
+// request matches the in-flight email regardless of surrounding spaces or letter case.
+func CancelKey(email string) string {
+	return strings.ToLower(emailKey(email))
+}
+
+// countStatuses counts the rows with status=ok and status=error. Other statuses (partial, blocked,
+// skipped) count as neither.
 func countStatuses(rows []pipeline.Row) (okRows int, errorRows int) {
 	for _, row := range rows {
-		if strings.EqualFold(strings.TrimSpace(row.Status), "ok") {
+		switch strings.ToLower(strings.TrimSpace(row.Status)) {
+		case "ok":
 			okRows++
-			continue
+		case "error":
+			errorRows++
+		case pipeline.StatusPartial, pipeline.StatusBlocked, pipeline.StatusSkipped:
+			// Valid results, or rows deliberately not enriched: not errors.
 		}
-		errorRows++
 	}
 	return okRows, errorRows
 }
@@ -0,0 +1,102 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+)
+
+// commitOutputVersion commits rows as a new output transaction and returns its RID.
+func commitOutputVersion(t *testing.T, client *foundry.Client, rows []pipeline.Row) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := pipeline.WriteCSV(&buf, rows); err != nil {
+		t.Fatalf("write output csv: %v", err)
+	}
+	ctx := context.Background()
+	txnID, err := client.CreateTransaction(ctx, testOutputRID, "master")
+	if err != nil {
+		t.Fatalf("create output transaction: %v", err)
+	}
+	if err := client.UploadFile(ctx, testOutputRID, txnID, "enriched.csv", "text/csv", buf.Bytes()); err != nil {
+		t.Fatalf("upload output csv: %v", err)
+	}
+	if err := client.CommitTransaction(ctx, testOutputRID, txnID); err != nil {
+		t.Fatalf("commit output transaction: %v", err)
+	}
+	return txnID
+}
+
+func TestRunFoundry_IncrementalBaseTxnPinsCacheToEarlierVersion(t *testing.T) {
+	t.Parallel()
+
+	_, env := newMockFoundryEnv(t, "email\nalice@example.com\nbob@corp.test\ncarol@new.test\n")
+	client, err := foundry.NewClient(env.Services.APIGateway, env.Services.StreamProxy, env.Token, "")
+	if err != nil {
+		t.Fatalf("new foundry client: %v", err)
+	}
+
+	first := commitOutputVersion(t, client, []pipeline.Row{
+		{Email: "alice@example.com", Company: "Pinned", Status: "ok"},
+	})
+	commitOutputVersion(t, client, []pipeline.Row{
+		{Email: "alice@example.com", Company: "Latest", Status: "ok"},
+		{Email: "bob@corp.test", Company: "Latest", Status: "ok"},
+	})
+
+	enricher := &countingEnricher{}
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:         "input",
+		OutputAlias:        "output",
+		OutputWriteMode:    "dataset",
+		IncrementalBaseTxn: first,
+	}, pipeline.Options{}, enricher); err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+
+	for email, want := range map[string]int{"alice@example.com": 0, "bob@corp.test": 1, "carol@new.test": 1} {
+		if got := enricher.count(email); got != want {
+			t.Fatalf("%s: expected %d enrich calls against the pinned version, got %d", email, want, got)
+		}
+	}
+
+	b, err := client.ReadTableCSV(context.Background(), testOutputRID, "master")
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	rows, err := pipeline.ReadCSV(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("parse output csv: %v", err)
+	}
+	if len(rows) != 3 || rows[0].Email != "alice@example.com" || rows[0].Company != "Pinned" {
+		t.Fatalf("expected alice carried over from the pinned version, got %#v", rows)
+	}
+}
+
+func TestRunFoundry_IncrementalBaseTxnUnknownFails(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	enricher := &countingEnricher{}
+	_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:         "input",
+		OutputAlias:        "output",
+		OutputWriteMode:    "dataset",
+		IncrementalBaseTxn: "ri.foundry.main.transaction.does-not-exist",
+	}, pipeline.Options{}, enricher)
+	if err == nil || !strings.Contains(err.Error(), "incremental base transaction ri.foundry.main.transaction.does-not-exist not found") {
+		t.Fatalf("expected unknown base transaction error, got %v", err)
+	}
+	if got := enricher.count("alice@example.com"); got != 0 {
+		t.Fatalf("expected no enrichment, got %d calls", got)
+	}
+	if len(mock.Uploads()) != 0 {
+		t.Fatalf("expected no uploads, got %d", len(mock.Uploads()))
+	}
+}
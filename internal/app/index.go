@@ -0,0 +1,330 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+	foundryio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/foundry"
+)
+
+// incrementalIndexFilename is the file written into the index dataset transaction.
+const incrementalIndexFilename = "incremental_index.csv"
+
+// incrementalIndex is a compact side-index of a committed dataset output. It records, per email key,
+// a hash of the written row and its status, plus the output transaction the index was built against
+// so stale indexes can be detected cheaply via the branch head. inputDigest (see inputDigest) and
+// outputRows describe the input the output was written for and how many rows it holds, so an input
+// that changed in any way other than its ok emails does not take the shortcut.
+type incrementalIndex struct {
+	outputTxnRID string
+	inputDigest  string
+	outputRows   int
+	entries      map[string]incrementalIndexEntry
+}
+
+type incrementalIndexEntry struct {
+	rowHash string
+	status  string
+}
+
+func incrementalIndexHeader() []string {
+	return []string{"email_key", "row_hash", "status", "output_transaction_rid", "input_digest", "output_rows"}
+}
+
+// inputDigest returns a digest of everything about the input that shapes the dataset output: the
+// ordered email keys and the row count, which input rows the domain lists and the input filter keep,
+// and the source row and passthrough columns each row is tagged with. A nil keep mask keeps every
+// row.
+func inputDigest(emails []string, domainKeep, filterKeep []bool, sourceRows inputSourceRows, passthrough inputPassthrough) string {
+	rows := make([]pipeline.Row, len(emails))
+	for i, email := range emails {
+		rows[i] = pipeline.Row{Email: email}
+	}
+	sourceRows.tagRows(rows)
+	passthrough.tagRows(rows)
+
+	kept := func(mask []bool, i int) bool { return mask == nil || (i < len(mask) && mask[i]) }
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "rows=%d\n", len(emails))
+	for i, row := range rows {
+		_, _ = fmt.Fprintf(h, "%q %t %t", emailKey(row.Email), kept(domainKeep, i), kept(filterKeep, i))
+		writeExtraDigest(h, row.Extra)
+		_, _ = io.WriteString(h, "\n")
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeExtraDigest writes extra to h in key order.
+func writeExtraDigest(h io.Writer, extra map[string]string) {
+	keys := make([]string, 0, len(extra))
+	for k := range extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		_, _ = fmt.Fprintf(h, " %q=%q", k, extra[k])
+	}
+}
+
+func buildIncrementalIndex(rows []pipeline.Row, outputTxnRID, digest string) incrementalIndex {
+	best := make(map[string]pipeline.Row, len(rows))
+	for _, row := range rows {
+		key := emailKey(row.Email)
+		if key == "" {
+			continue
+		}
+		if prev, ok := best[key]; ok {
+			best[key] = chooseBestIncrementalRow(prev, row)
+			continue
+		}
+		best[key] = row
+	}
+
+	idx := incrementalIndex{
+		outputTxnRID: strings.TrimSpace(outputTxnRID),
+		inputDigest:  digest,
+		outputRows:   len(rows),
+		entries:      make(map[string]incrementalIndexEntry, len(best)),
+	}
+	for key, row := range best {
+		idx.entries[key] = incrementalIndexEntry{
+			rowHash: rowHash(row),
+			status:  strings.TrimSpace(row.Status),
+		}
+	}
+	return idx
+}
+
+// rowHash returns a short, stable digest of a row's CSV encoding and its extra columns.
+func rowHash(row pipeline.Row) string {
+	h := sha256.New()
+	_ = pipeline.WriteCSV(h, []pipeline.Row{row})
+	writeExtraDigest(h, row.Extra)
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// pendingEmails returns the number of distinct input emails without an ok entry in the index.
+func (idx incrementalIndex) pendingEmails(inputEmails []string) int {
+	seen := make(map[string]struct{}, len(inputEmails))
+	pending := 0
+	for _, raw := range inputEmails {
+		key := emailKey(raw)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		entry, ok := idx.entries[key]
+		if ok && strings.EqualFold(entry.status, "ok") {
+			continue
+		}
+		pending++
+	}
+	return pending
+}
+
+func writeIncrementalIndexCSV(w io.Writer, idx incrementalIndex) error {
+	keys := make([]string, 0, len(idx.entries))
+	for k := range idx.entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(incrementalIndexHeader()); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		e := idx.entries[k]
+		if err := cw.Write([]string{k, e.rowHash, e.status, idx.outputTxnRID, idx.inputDigest, strconv.Itoa(idx.outputRows)}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func readIncrementalIndexCSV(r io.Reader) (incrementalIndex, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return incrementalIndex{}, fmt.Errorf("read index header: %w", err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.TrimSpace(strings.TrimPrefix(name, "\uFEFF"))] = i
+	}
+	// Indexes written before input_digest and output_rows existed still parse, but never match.
+	for _, name := range incrementalIndexHeader()[:4] {
+		if _, ok := index[name]; !ok {
+			return incrementalIndex{}, fmt.Errorf("index missing required column %q", name)
+		}
+	}
+
+	idx := incrementalIndex{entries: make(map[string]incrementalIndexEntry)}
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return idx, nil
+		}
+		if err != nil {
+			return incrementalIndex{}, fmt.Errorf("read index row: %w", err)
+		}
+		get := func(col string) string {
+			i, ok := index[col]
+			if !ok || i >= len(rec) {
+				return ""
+			}
+			return strings.TrimSpace(rec[i])
+		}
+		key := emailKey(get("email_key"))
+		if key == "" {
+			continue
+		}
+		idx.entries[key] = incrementalIndexEntry{rowHash: get("row_hash"), status: get("status")}
+		if txn := get("output_transaction_rid"); txn != "" {
+			idx.outputTxnRID = txn
+		}
+		if digest := get("input_digest"); digest != "" {
+			idx.inputDigest = digest
+		}
+		if n, err := strconv.Atoi(get("output_rows")); err == nil {
+			idx.outputRows = n
+		}
+	}
+}
+
+// indexShowsOutputUpToDate reports whether the incremental index proves the committed output already
+// covers every input email, so the run can skip reading and rewriting the prior output. That takes an
+// index written for the same input (digest, see inputDigest) with one output row per input row, and
+// an ok entry for every email. A shrunken input, a new duplicate row, or a changed filter, domain
+// list, or passthrough value changes the digest and falls back to the full read.
+//
+// Any failure along this path falls back to the full read by returning false: the index is an
+// optimization and must never fail a run on its own.
+func indexShowsOutputUpToDate(
+	ctx context.Context,
+	client *foundry.Client,
+	outputRef foundry.DatasetRef,
+	indexRef foundry.DatasetRef,
+	inputEmails []string,
+	digest string,
+	logger *log.Logger,
+	runID string,
+) bool {
+	outputBranch := defaultBranch(outputRef.Branch)
+	headTxn, err := client.GetBranchTransactionRID(ctx, outputRef.RID, outputBranch)
+	if err != nil {
+		logger.Printf("run=%s incremental index: read output branch head failed; falling back to full read: %s", runID, err)
+		return false
+	}
+
+	b, err := client.ReadTableCSV(ctx, indexRef.RID, defaultBranch(indexRef.Branch))
+	if err != nil {
+		if isNotFoundError(err) {
+			logger.Printf("run=%s incremental index: no index found for %s; falling back to full read", runID, indexRef.RID)
+			return false
+		}
+		logger.Printf("run=%s incremental index: read failed; falling back to full read: %s", runID, err)
+		return false
+	}
+	idx, err := readIncrementalIndexCSV(bytes.NewReader(b))
+	if err != nil {
+		logger.Printf("run=%s incremental index: parse failed; falling back to full read: %s", runID, err)
+		return false
+	}
+	if idx.outputTxnRID == "" || idx.outputTxnRID != headTxn {
+		logger.Printf(
+			"run=%s incremental index: stale (index txn=%q output head=%q); falling back to full read",
+			runID,
+			idx.outputTxnRID,
+			headTxn,
+		)
+		return false
+	}
+
+	if idx.inputDigest != digest || idx.outputRows != len(inputEmails) {
+		logger.Printf(
+			"run=%s incremental index: input changed since the index was written (index rows=%d input rows=%d); falling back to full read",
+			runID,
+			idx.outputRows,
+			len(inputEmails),
+		)
+		return false
+	}
+
+	if pending := idx.pendingEmails(inputEmails); pending > 0 {
+		logger.Printf("run=%s incremental index: %d emails pending; reading prior output for merge", runID, pending)
+		return false
+	}
+
+	// A pre-created OPEN transaction (pipeline builds) must still receive the full output.
+	openTxn, open, err := client.FindLatestOpenTransactionForBranch(ctx, outputRef.RID, outputBranch)
+	if err != nil {
+		logger.Printf("run=%s incremental index: list transactions failed; falling back to full read: %s", runID, err)
+		return false
+	}
+	if open {
+		logger.Printf("run=%s incremental index: open output transaction %s exists; writing full output", runID, openTxn)
+		return false
+	}
+
+	logger.Printf("run=%s incremental index: loaded %d entries; output head %s covers all input emails", runID, len(idx.entries), headTxn)
+	return true
+}
+
+// writeIncrementalIndex persists the index for rows that were just committed to the output.
+//
+// headBefore is the output branch head observed before the upload. If the head did not advance, the
+// rows were written into a transaction this run does not commit (for example one pre-created by a
+// pipeline build), so no index is written: it would describe contents that are not yet visible.
+func writeIncrementalIndex(
+	ctx context.Context,
+	client *foundry.Client,
+	outputRef foundry.DatasetRef,
+	indexRef foundry.DatasetRef,
+	headBefore string,
+	rows []pipeline.Row,
+	digest string,
+	logger *log.Logger,
+	runID string,
+) error {
+	headAfter, err := client.GetBranchTransactionRID(ctx, outputRef.RID, defaultBranch(outputRef.Branch))
+	if err != nil {
+		return fmt.Errorf("read output branch head: %w", err)
+	}
+	if headAfter == "" || headAfter == headBefore {
+		logger.Printf("run=%s incremental index: output head did not advance; skipping index write", runID)
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := writeIncrementalIndexCSV(&buf, buildIncrementalIndex(rows, headAfter, digest)); err != nil {
+		return err
+	}
+	if err := foundryio.UploadDatasetCSV(ctx, client, indexRef, incrementalIndexFilename, buf.Bytes()); err != nil {
+		return fmt.Errorf("upload incremental index: %w", err)
+	}
+	logger.Printf("run=%s incremental index: wrote index for output head %s", runID, headAfter)
+	return nil
+}
+
+func defaultBranch(branch string) string {
+	branch = strings.TrimSpace(branch)
+	if branch == "" {
+		return "master"
+	}
+	return branch
+}
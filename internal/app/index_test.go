@@ -0,0 +1,255 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/mockfoundry"
+)
+
+func TestRunFoundry_IncrementalIndexSkipsPriorOutputRead(t *testing.T) {
+	t.Parallel()
+
+	inputRID := "ri.foundry.main.dataset.11111111-1111-1111-1111-111111111111"
+	outputRID := "ri.foundry.main.dataset.22222222-2222-2222-2222-222222222222"
+	indexRID := "ri.foundry.main.dataset.33333333-3333-3333-3333-333333333333"
+
+	inputDir := t.TempDir()
+	uploadDir := t.TempDir()
+
+	writeInput := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(inputDir, inputRID+".csv"), []byte(content), 0644); err != nil {
+			t.Fatalf("write input csv: %v", err)
+		}
+	}
+	writeInput("email\nalice@example.com\nbob@corp.test\n")
+
+	mock := mockfoundry.New(inputDir, uploadDir)
+	mock.RequireBearerToken("dummy-token")
+	ts := httptest.NewServer(mock.Handler())
+	defer ts.Close()
+
+	env := foundry.Env{
+		Services: foundry.Services{
+			APIGateway:  ts.URL + "/api",
+			StreamProxy: ts.URL + "/stream-proxy/api",
+		},
+		Token: "dummy-token",
+		Aliases: map[string]foundry.DatasetRef{
+			"input":  {RID: inputRID, Branch: "master"},
+			"output": {RID: outputRID, Branch: "master"},
+			"index":  {RID: indexRID, Branch: "master"},
+		},
+	}
+	fopts := app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputFilename:  "enriched.csv",
+		OutputWriteMode: "dataset",
+		IndexAlias:      "index",
+	}
+
+	enricher := &countingEnricher{}
+	countPaths := func(calls []mockfoundry.Call, path string) int {
+		n := 0
+		for _, c := range calls {
+			if c.Path == path {
+				n++
+			}
+		}
+		return n
+	}
+	outputReadPath := "/api/v2/datasets/" + outputRID + "/readTable"
+	outputUploadPath := "/api/v2/datasets/" + outputRID + "/files/enriched.csv/upload"
+	indexUploadPath := "/api/v2/datasets/" + indexRID + "/files/incremental_index.csv/upload"
+
+	// First run: no index yet, full read + write, index persisted.
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{}, enricher); err != nil {
+		t.Fatalf("first RunFoundryWithOptions failed: %v", err)
+	}
+	calls := mock.Calls()
+	if got := countPaths(calls, indexUploadPath); got != 1 {
+		t.Fatalf("expected 1 index upload after first run, got %d (calls=%#v)", got, calls)
+	}
+	firstCalls := len(calls)
+
+	// Second run with identical input: the index is fresh, so the prior output is not read or rewritten.
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{}, enricher); err != nil {
+		t.Fatalf("second RunFoundryWithOptions failed: %v", err)
+	}
+	second := mock.Calls()[firstCalls:]
+	if got := countPaths(second, outputReadPath); got != 0 {
+		t.Fatalf("expected no output readTable on second run, got %d (calls=%#v)", got, second)
+	}
+	if got := countPaths(second, outputUploadPath); got != 0 {
+		t.Fatalf("expected no output upload on second run, got %d (calls=%#v)", got, second)
+	}
+	if enricher.count("alice@example.com") != 1 || enricher.count("bob@corp.test") != 1 {
+		t.Fatalf("unexpected second-run call counts: alice=%d bob=%d", enricher.count("alice@example.com"), enricher.count("bob@corp.test"))
+	}
+	secondCalls := len(mock.Calls())
+
+	// Third run adds an email: falls back to the full read and only enriches the new row.
+	writeInput("email\nalice@example.com\nbob@corp.test\ncarol@new.test\n")
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{}, enricher); err != nil {
+		t.Fatalf("third RunFoundryWithOptions failed: %v", err)
+	}
+	third := mock.Calls()[secondCalls:]
+	if got := countPaths(third, outputReadPath); got != 1 {
+		t.Fatalf("expected output readTable on third run, got %d (calls=%#v)", got, third)
+	}
+	if got := countPaths(third, indexUploadPath); got != 1 {
+		t.Fatalf("expected index rewrite on third run, got %d (calls=%#v)", got, third)
+	}
+	if enricher.count("alice@example.com") != 1 || enricher.count("bob@corp.test") != 1 {
+		t.Fatalf("expected cached rows on third run: alice=%d bob=%d", enricher.count("alice@example.com"), enricher.count("bob@corp.test"))
+	}
+	if enricher.count("carol@new.test") != 1 {
+		t.Fatalf("expected carol to be enriched once, got %d calls", enricher.count("carol@new.test"))
+	}
+
+	var indexCSV string
+	for _, u := range mock.Uploads() {
+		if u.DatasetRID != indexRID {
+			continue
+		}
+		indexCSV = string(u.Bytes)
+	}
+	if !strings.HasPrefix(indexCSV, "email_key,row_hash,status,output_transaction_rid,input_digest,output_rows\n") {
+		t.Fatalf("unexpected index header: %q", indexCSV)
+	}
+	if !strings.Contains(indexCSV, "carol@new.test,") {
+		t.Fatalf("expected carol in latest index, got %q", indexCSV)
+	}
+}
+
+func TestRunFoundry_IncrementalIndexFallsBackWhenInputChanges(t *testing.T) {
+	t.Parallel()
+
+	const baseline = "email\nalice@example.com\nbob@corp.test\n"
+	for _, tc := range []struct {
+		name       string
+		input      string
+		filter     []string
+		wantEmails []string
+		wantStatus map[string]string
+	}{
+		{
+			name:       "shrunken input",
+			input:      "email\nalice@example.com\n",
+			wantEmails: []string{"alice@example.com"},
+		},
+		{
+			name:       "new duplicate row",
+			input:      "email\nalice@example.com\nbob@corp.test\nalice@example.com\n",
+			wantEmails: []string{"alice@example.com", "bob@corp.test", "alice@example.com"},
+		},
+		{
+			name:       "filter change",
+			input:      baseline,
+			filter:     []string{"domain!=corp.test"},
+			wantEmails: []string{"alice@example.com", "bob@corp.test"},
+			wantStatus: map[string]string{"bob@corp.test": "skipped"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			inputDir := t.TempDir()
+			writeInput := func(content string) {
+				t.Helper()
+				if err := os.WriteFile(filepath.Join(inputDir, testInputRID+".csv"), []byte(content), 0644); err != nil {
+					t.Fatalf("write input csv: %v", err)
+				}
+			}
+			writeInput(baseline)
+			mock := mockfoundry.New(inputDir, t.TempDir())
+			ts := httptest.NewServer(mock.Handler())
+			defer ts.Close()
+			env := foundry.Env{
+				Services: foundry.Services{APIGateway: ts.URL + "/api", StreamProxy: ts.URL + "/stream-proxy/api"},
+				Token:    "dummy-token",
+				Aliases: map[string]foundry.DatasetRef{
+					"input":  {RID: testInputRID, Branch: "master"},
+					"output": {RID: testOutputRID, Branch: "master"},
+					"index":  {RID: "ri.foundry.main.dataset.33333333-3333-3333-3333-333333333333", Branch: "master"},
+				},
+			}
+			fopts := app.FoundryOptions{
+				InputAlias:      "input",
+				OutputAlias:     "output",
+				OutputWriteMode: "dataset",
+				IndexAlias:      "index",
+			}
+			enricher := &countingEnricher{}
+			if _, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{}, enricher); err != nil {
+				t.Fatalf("first RunFoundryWithOptions failed: %v", err)
+			}
+
+			writeInput(tc.input)
+			fopts.InputFilter = tc.filter
+			res, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{}, enricher)
+			if err != nil {
+				t.Fatalf("second RunFoundryWithOptions failed: %v", err)
+			}
+			if res.UpToDate {
+				t.Fatalf("expected the changed input to rewrite the output, got an up-to-date run")
+			}
+
+			uploads := mock.Uploads()
+			var last []byte
+			for _, u := range uploads {
+				if u.DatasetRID == testOutputRID {
+					last = u.Bytes
+				}
+			}
+			rows, err := pipeline.ReadCSV(bytes.NewReader(last))
+			if err != nil {
+				t.Fatalf("parse output: %v", err)
+			}
+			var emails []string
+			for _, row := range rows {
+				emails = append(emails, row.Email)
+				want := tc.wantStatus[row.Email]
+				if want == "" {
+					want = "ok"
+				}
+				if row.Status != want {
+					t.Fatalf("%s: expected status %q, got %#v", row.Email, want, row)
+				}
+			}
+			if !slices.Equal(emails, tc.wantEmails) {
+				t.Fatalf("expected output emails %v, got %v", tc.wantEmails, emails)
+			}
+		})
+	}
+}
+
+func TestRunFoundryWithOptions_MissingIndexAlias(t *testing.T) {
+	t.Parallel()
+
+	env := foundry.Env{
+		Aliases: map[string]foundry.DatasetRef{
+			"input":  {RID: "ri.foundry.main.dataset.in"},
+			"output": {RID: "ri.foundry.main.dataset.out"},
+		},
+	}
+	_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:  "input",
+		OutputAlias: "output",
+		IndexAlias:  "index",
+	}, pipeline.Options{}, testEnricher{})
+	if err == nil || !strings.Contains(err.Error(), `"index"`) {
+		t.Fatalf("expected missing index alias error, got %v", err)
+	}
+}
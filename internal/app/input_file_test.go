@@ -0,0 +1,74 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+)
+
+func TestRunFoundry_InputFileReplacesInputDataset(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email\nignored@dataset.test\n")
+	// The input alias is not needed once the input comes from a file.
+	delete(env.Aliases, "input")
+	inputPath := writeLocalInput(t, "email\nalice@example.com\nbob@corp.test\n")
+
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		InputFile:       inputPath,
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+	}, pipeline.Options{}, testEnricher{}); err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+
+	for _, c := range mock.Calls() {
+		if strings.Contains(c.Path, testInputRID) {
+			t.Fatalf("expected the input dataset not to be read, got call %s %s", c.Method, c.Path)
+		}
+	}
+
+	client, err := foundry.NewClient(env.Services.APIGateway, env.Services.StreamProxy, env.Token, "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	b, err := client.ReadTableCSV(context.Background(), testOutputRID, "master")
+	if err != nil {
+		t.Fatalf("read committed output: %v", err)
+	}
+	rows, err := pipeline.ReadCSV(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("parse committed output: %v", err)
+	}
+	var emails []string
+	for _, row := range rows {
+		if row.Status != "ok" {
+			t.Fatalf("expected ok rows, got %#v", row)
+		}
+		emails = append(emails, row.Email)
+	}
+	if want := []string{"alice@example.com", "bob@corp.test"}; !slices.Equal(emails, want) {
+		t.Fatalf("expected the committed rows to match the input file, got %v want %v", emails, want)
+	}
+}
+
+func TestRunFoundry_InputFileRejectsExtraInputAliases(t *testing.T) {
+	t.Parallel()
+
+	_, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputFile:         writeLocalInput(t, "email\nalice@example.com\n"),
+		ExtraInputAliases: []string{"input"},
+		OutputAlias:       "output",
+	}, pipeline.Options{}, testEnricher{})
+	if app.ClassifyFailure(err) != app.FailureConfig {
+		t.Fatalf("expected a config error, got %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package app_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/mockfoundry"
+	foundryio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/foundry"
+)
+
+// newFlakyInputEnv is newMockFoundryEnv with the first failures input readTable calls answered
+// with a 503. It returns the number of input reads seen.
+func newFlakyInputEnv(t *testing.T, failures int32) (*mockfoundry.Server, foundry.Env, *atomic.Int32) {
+	t.Helper()
+
+	inputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, testInputRID+".csv"), []byte("email\nalice@example.com\n"), 0644); err != nil {
+		t.Fatalf("write input csv: %v", err)
+	}
+	mock := mockfoundry.New(inputDir, t.TempDir())
+	base := mock.Handler()
+
+	var reads atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, testInputRID) && strings.HasSuffix(r.URL.Path, "/readTable") && reads.Add(1) <= failures {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		base.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	return mock, foundry.Env{
+		Services: foundry.Services{
+			APIGateway:  ts.URL + "/api",
+			StreamProxy: ts.URL + "/stream-proxy/api",
+		},
+		Token: "dummy-token",
+		Aliases: map[string]foundry.DatasetRef{
+			"input":  {RID: testInputRID, Branch: "master"},
+			"output": {RID: testOutputRID, Branch: "master"},
+		},
+	}, &reads
+}
+
+func TestRunFoundry_InputReadPolicyIsIndependentOfWritePolicy(t *testing.T) {
+	t.Parallel()
+
+	fopts := app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+	}
+
+	// A single read attempt fails the run, however generous the write budget is.
+	_, env, reads := newFlakyInputEnv(t, 1)
+	fopts.InputReadPolicy = foundryio.ReadRetryPolicy{Attempts: 1}
+	fopts.WriteRetryPolicy = foundryio.WriteRetryPolicy{MaxAttempts: 100, MaxElapsed: time.Minute}
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{}, testEnricher{}); err == nil {
+		t.Fatalf("expected the run to fail on the unretried input read")
+	}
+	if got := reads.Load(); got != 1 {
+		t.Fatalf("expected 1 input read, got %d", got)
+	}
+
+	// Read retries do not spend the write budget, which covers exactly the create, upload, and
+	// commit calls.
+	mock, env, reads := newFlakyInputEnv(t, 2)
+	fopts.InputReadPolicy = foundryio.ReadRetryPolicy{Attempts: 3}
+	fopts.WriteRetryPolicy = foundryio.WriteRetryPolicy{MaxAttempts: 3, MaxElapsed: time.Minute}
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{}, testEnricher{}); err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+	if got := reads.Load(); got != 3 {
+		t.Fatalf("expected 3 input reads, got %d", got)
+	}
+	if len(mock.Uploads()) != 1 {
+		t.Fatalf("expected 1 upload, got %d", len(mock.Uploads()))
+	}
+}
@@ -0,0 +1,101 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+	foundryio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/foundry"
+	localio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/local"
+)
+
+// maxConcurrentInputReads bounds how many input datasets are read at once when
+// FoundryOptions.ExtraInputAliases is set.
+const maxConcurrentInputReads = 4
+
+// namedInput is an input dataset with the alias it was configured under, for error messages.
+type namedInput struct {
+	alias string
+	ref   foundry.DatasetRef
+}
+
+// readMergedInputEmails reads the email column (column; see localio.ReadColumnCSV) of every input
+// concurrently, each read under policy, and merges them in input order keeping the first occurrence
+// of each email. A failed read cancels the others and names its alias. With ensureHeader, an input
+// with no data contributes no emails instead of failing.
+func readMergedInputEmails(
+	ctx context.Context,
+	client *foundry.Client,
+	inputs []namedInput,
+	column string,
+	opts localio.CSVReadOptions,
+	policy foundryio.ReadRetryPolicy,
+	ensureHeader bool,
+	logf func(format string, args ...any),
+) ([]string, error) {
+	perInput := make([][]string, len(inputs))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(maxConcurrentInputReads)
+	for i, in := range inputs {
+		g.Go(func() error {
+			emails, err := foundryio.ReadInputColumnWithPolicy(gctx, client, in.ref, column, opts, policy)
+			if err != nil {
+				if !ensureHeader || !isEmptyInputError(err) {
+					return fmt.Errorf("read input alias %q (%s@%s): %w", in.alias, in.ref.RID, defaultBranch(in.ref.Branch), err)
+				}
+				logf("input alias %q has no data; contributing no emails: %s", in.alias, err)
+			}
+			perInput[i] = emails
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	var merged []string
+	seen := map[string]bool{}
+	total := 0
+	for _, emails := range perInput {
+		total += len(emails)
+		for _, email := range emails {
+			key := emailKey(email)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, strings.TrimSpace(email))
+		}
+	}
+	logf("merged %d input aliases: readRows=%d uniqueEmails=%d", len(inputs), total, len(merged))
+	return merged, nil
+}
+
+// readInputFileItems reads email items from the local CSV of FoundryOptions.InputFile, as a local
+// run reads its input.
+func readInputFileItems(path, column string, emailColumns, passthrough []string, opts localio.CSVReadOptions) ([]localio.EmailItem, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	return readLocalEmailItems(f, column, emailColumns, passthrough, opts)
+}
+
+// readInputFileColumn reads the email column of the local CSV of FoundryOptions.InputFile.
+func readInputFileColumn(path, column string, opts localio.CSVReadOptions) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	return localio.ReadColumnCSVWithOptions(f, column, opts)
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"context"
+	"time"
+)
+
+// Defaults for --prior-output-not-found-retries and --prior-output-not-found-backoff: a couple of
+// quick re-checks, so a first run (where the output really is absent) pays well under a second.
+const (
+	DefaultPriorOutputNotFoundRetries = 2
+	DefaultPriorOutputNotFoundBackoff = 250 * time.Millisecond
+)
+
+// notFoundRetry re-attempts a read that failed with not-found, for outputs that may not be visible
+// yet right after a commit.
+type notFoundRetry struct {
+	retries int
+	backoff time.Duration
+}
+
+// priorOutputNotFoundRetry resolves the prior-output not-found retry options.
+func priorOutputNotFoundRetry(fopts FoundryOptions) notFoundRetry {
+	r := notFoundRetry{retries: fopts.PriorOutputNotFoundRetries, backoff: fopts.PriorOutputNotFoundBackoff}
+	switch {
+	case r.retries == 0:
+		r.retries = DefaultPriorOutputNotFoundRetries
+	case r.retries < 0:
+		r.retries = 0
+	}
+	if r.backoff <= 0 {
+		r.backoff = DefaultPriorOutputNotFoundBackoff
+	}
+	return r
+}
+
+// read calls fn, calling it again up to r.retries times while it fails with not-found. onRetry is
+// called before each wait. Any other error, or the last not-found, is returned as is.
+func (r notFoundRetry) read(ctx context.Context, fn func() error, onRetry func(retry int, wait time.Duration)) error {
+	wait := r.backoff
+	for retry := 1; ; retry++ {
+		err := fn()
+		if err == nil || !isNotFoundError(err) || retry > r.retries {
+			return err
+		}
+		onRetry(retry, wait)
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		wait *= 2
+	}
+}
@@ -0,0 +1,83 @@
+package app_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/mockfoundry"
+)
+
+func TestRunFoundry_PriorOutputNotFoundIsRetriedBeforeDroppingCache(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, testInputRID+".csv"), []byte("email\nalice@example.com\nbob@corp.test\n"), 0644); err != nil {
+		t.Fatalf("write input csv: %v", err)
+	}
+	mock := mockfoundry.New(inputDir, t.TempDir())
+	mock.RequireBearerToken("dummy-token")
+
+	// The first output read 404s as if the committed output were not visible yet.
+	var outputReads atomic.Int32
+	base := mock.Handler()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/api/v2/datasets/"+testOutputRID+"/readTable" && outputReads.Add(1) == 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"errorCode": "NOT_FOUND",
+				"errorName": "DatasetViewNotFound",
+			})
+			return
+		}
+		base.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	env := foundry.Env{
+		Services: foundry.Services{APIGateway: ts.URL + "/api", StreamProxy: ts.URL + "/stream-proxy/api"},
+		Token:    "dummy-token",
+		Aliases: map[string]foundry.DatasetRef{
+			"input":  {RID: testInputRID, Branch: "master"},
+			"output": {RID: testOutputRID, Branch: "master"},
+		},
+	}
+	client, err := foundry.NewClient(env.Services.APIGateway, env.Services.StreamProxy, env.Token, "")
+	if err != nil {
+		t.Fatalf("new foundry client: %v", err)
+	}
+	commitOutputVersion(t, client, []pipeline.Row{
+		{Email: "alice@example.com", Company: "Example", Status: "ok"},
+		{Email: "bob@corp.test", Company: "Corp", Status: "ok"},
+	})
+
+	enricher := &countingEnricher{}
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:                 "input",
+		OutputAlias:                "output",
+		OutputWriteMode:            "dataset",
+		PriorOutputNotFoundRetries: 2,
+		PriorOutputNotFoundBackoff: time.Millisecond,
+	}, pipeline.Options{}, enricher); err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+
+	if got := outputReads.Load(); got < 2 {
+		t.Fatalf("expected the not-found output read to be retried, got %d reads", got)
+	}
+	for _, email := range []string{"alice@example.com", "bob@corp.test"} {
+		if n := enricher.count(email); n != 0 {
+			t.Fatalf("expected %s to come from the prior output cache, got %d enrich calls", email, n)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"context"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+)
+
+// DefaultCleanupOpenTransactionsMax bounds how many stale OPEN transactions one run aborts when
+// FoundryOptions.CleanupOpenTransactionsMax is zero.
+const DefaultCleanupOpenTransactionsMax = 10
+
+func cleanupOpenTransactionsMax(n int) int {
+	if n == 0 {
+		return DefaultCleanupOpenTransactionsMax
+	}
+	return n
+}
+
+// cleanupOpenTransactions aborts the OPEN transactions on the output branch other than the newest,
+// oldest first and at most max of them, and returns how many were aborted. The newest is left for
+// the write to reuse (it may be the transaction a pipeline build pre-created). Cleanup never fails
+// the run: a failed listing or abort is recorded as a WarningOpenTransactionCleanup warning.
+func cleanupOpenTransactions(
+	ctx context.Context,
+	client *foundry.Client,
+	outputRef foundry.DatasetRef,
+	max int,
+	warn *warningCollector,
+) int {
+	branch := defaultBranch(outputRef.Branch)
+	open, err := client.ListOpenTransactionsForBranch(ctx, outputRef.RID, branch)
+	if err != nil {
+		warn.warnf(WarningOpenTransactionCleanup, "open transaction cleanup skipped: list transactions on %s@%s failed: %s", outputRef.RID, branch, err)
+		return 0
+	}
+	if len(open) <= 1 {
+		return 0
+	}
+	stale := open[1:]
+	if len(stale) > max {
+		warn.warnf(WarningOpenTransactionCleanup, "open transaction cleanup: %d stale open transactions on %s@%s; aborting the oldest %d this run", len(stale), outputRef.RID, branch, max)
+		stale = stale[len(stale)-max:]
+	}
+	aborted := 0
+	for i := len(stale) - 1; i >= 0; i-- {
+		txnID := stale[i]
+		if err := client.AbortTransaction(ctx, outputRef.RID, txnID); err != nil {
+			warn.warnf(WarningOpenTransactionCleanup, "open transaction cleanup: abort %s on %s@%s failed: %s", txnID, outputRef.RID, branch, err)
+			continue
+		}
+		aborted++
+	}
+	warn.logf("open transaction cleanup: aborted %d stale open transactions on %s@%s; keeping %s", aborted, outputRef.RID, branch, open[0])
+	return aborted
+}
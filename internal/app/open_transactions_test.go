@@ -0,0 +1,84 @@
+package app_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+func TestRunFoundry_CleanupOpenTransactionsAbortsStaleAndReusesNewest(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		max         int
+		wantAborted []int // indexes into the stale transactions, oldest first
+		wantWarning bool
+	}{
+		{name: "aborts every stale transaction", wantAborted: []int{0, 1, 2}},
+		{name: "bounded per run aborts the oldest", max: 2, wantAborted: []int{0, 1}, wantWarning: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+			var stale []string
+			for range 3 {
+				stale = append(stale, mock.AddOpenTransaction(testOutputRID, "master"))
+			}
+			newest := mock.AddOpenTransaction(testOutputRID, "master")
+
+			res, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+				InputAlias:                 "input",
+				OutputAlias:                "output",
+				OutputWriteMode:            "dataset",
+				CleanupOpenTransactions:    true,
+				CleanupOpenTransactionsMax: tc.max,
+			}, pipeline.Options{}, testEnricher{})
+			if err != nil {
+				t.Fatalf("RunFoundryWithOptions: %v", err)
+			}
+			if res.OutputTransactionRID != newest {
+				t.Fatalf("expected the newest open transaction %s to be reused, got %s", newest, res.OutputTransactionRID)
+			}
+
+			var aborted []string
+			for _, c := range mock.Calls() {
+				if c.Method == "POST" && strings.HasSuffix(c.Path, "/abort") {
+					aborted = append(aborted, c.Path)
+				}
+			}
+			var want []string
+			for _, i := range tc.wantAborted {
+				want = append(want, "/api/v2/datasets/"+testOutputRID+"/transactions/"+stale[i]+"/abort")
+			}
+			if !slices.Equal(aborted, want) {
+				t.Fatalf("expected aborts %v, got %v", want, aborted)
+			}
+			if got := res.WarningCounts()[app.WarningOpenTransactionCleanup] == 1; got != tc.wantWarning {
+				t.Fatalf("want cleanup cap warning=%t, got warnings %+v", tc.wantWarning, res.Warnings)
+			}
+		})
+	}
+}
+
+func TestRunFoundry_CleanupOpenTransactionsRejectedForStream(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	mock.CreateStream(testOutputRID)
+	_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:              "input",
+		OutputAlias:             "output",
+		OutputWriteMode:         "stream",
+		CleanupOpenTransactions: true,
+	}, pipeline.Options{}, testEnricher{})
+	if app.ClassifyFailure(err) != app.FailureConfig {
+		t.Fatalf("expected a config failure, got %v", err)
+	}
+}
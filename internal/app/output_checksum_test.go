@@ -0,0 +1,52 @@
+package app_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+func TestRunFoundry_OutputChecksumUploadsSidecarInSameTransaction(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email\nalice@example.com\nbob@corp.test\n")
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+		OutputFilename:  "enriched.csv",
+		OutputChecksum:  true,
+	}, pipeline.Options{}, testEnricher{}); err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+
+	uploads := mock.Uploads()
+	if len(uploads) != 2 {
+		t.Fatalf("expected output and sidecar uploads, got %d", len(uploads))
+	}
+	main, sidecar := uploads[0], uploads[1]
+	if main.FilePath != "enriched.csv" || sidecar.FilePath != "enriched.csv.sha256" {
+		t.Fatalf("unexpected upload paths %q, %q", main.FilePath, sidecar.FilePath)
+	}
+	if main.TxnID != sidecar.TxnID {
+		t.Fatalf("expected sidecar in the output transaction %q, got %q", main.TxnID, sidecar.TxnID)
+	}
+	sum := sha256.Sum256(main.Bytes)
+	if got := strings.TrimSpace(string(sidecar.Bytes)); got != hex.EncodeToString(sum[:]) {
+		t.Fatalf("sidecar digest %q does not match output digest %x", got, sum)
+	}
+	committed := false
+	for _, call := range mock.Calls() {
+		if strings.HasSuffix(call.Path, "/transactions/"+main.TxnID+"/commit") {
+			committed = true
+		}
+	}
+	if !committed {
+		t.Fatalf("expected output transaction %s to be committed", main.TxnID)
+	}
+}
@@ -0,0 +1,173 @@
+package app_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+func TestRunLocal_OutputFormatJSONL(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.csv")
+	outputPath := filepath.Join(dir, "output.jsonl")
+	if err := os.WriteFile(inputPath, []byte("email\nalice@example.com\nbob@corp.test\n"), 0644); err != nil {
+		t.Fatalf("write input csv: %v", err)
+	}
+
+	res, err := app.RunLocalWithOptions(context.Background(), app.LocalOptions{
+		InputPath:    inputPath,
+		OutputPath:   outputPath,
+		OutputFormat: "JSONL",
+	}, pipeline.Options{Workers: 1}, testEnricher{})
+	if err != nil {
+		t.Fatalf("RunLocalWithOptions failed: %v", err)
+	}
+	if res.OutputFile != outputPath {
+		t.Fatalf("expected OutputFile %q, got %q", outputPath, res.OutputFile)
+	}
+	f, err := os.Open(outputPath)
+	if err != nil {
+		t.Fatalf("open output: %v", err)
+	}
+	defer f.Close()
+	rows, err := pipeline.ReadJSONL(f)
+	if err != nil {
+		t.Fatalf("ReadJSONL: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Email != "alice@example.com" || rows[1].Company != "corp.test" || rows[1].Status != "ok" {
+		t.Fatalf("unexpected rows: %#v", rows)
+	}
+
+	_, err = app.RunLocalWithOptions(context.Background(), app.LocalOptions{
+		InputPath:    inputPath,
+		OutputPath:   outputPath,
+		OutputFormat: "parquet",
+	}, pipeline.Options{}, testEnricher{})
+	if app.ClassifyFailure(err) != app.FailureConfig {
+		t.Fatalf("expected config error for an unknown format, got %v", err)
+	}
+}
+
+func TestRunFoundry_OutputFormatJSONLUploadsAndReadsBack(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email\nalice@example.com\nbob@corp.test\n")
+	fopts := app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+		OutputFormat:    app.OutputFormatJSONL,
+	}
+	enricher := &countingEnricher{}
+	res, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{}, enricher)
+	if err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+	if res.OutputFile != "enriched.jsonl" {
+		t.Fatalf("expected default filename enriched.jsonl, got %q", res.OutputFile)
+	}
+	uploads := mock.Uploads()
+	if len(uploads) != 1 {
+		t.Fatalf("expected 1 upload, got %d", len(uploads))
+	}
+	if u := uploads[0]; u.FilePath != "enriched.jsonl" || u.ContentType != app.JSONLContentType {
+		t.Fatalf("unexpected upload path=%q content-type=%q", u.FilePath, u.ContentType)
+	}
+	rows, err := pipeline.ReadJSONL(strings.NewReader(string(uploads[0].Bytes)))
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("expected 2 JSONL rows, got %d (%v)", len(rows), err)
+	}
+
+	// The committed JSONL output seeds the next run's cache, and the unchanged output is not
+	// rewritten.
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{}, enricher); err != nil {
+		t.Fatalf("second RunFoundryWithOptions failed: %v", err)
+	}
+	if got := enricher.count("alice@example.com"); got != 1 {
+		t.Fatalf("expected alice to be enriched once across runs, got %d", got)
+	}
+	if got := len(mock.Uploads()); got != 1 {
+		t.Fatalf("expected the unchanged output to be skipped, got %d uploads", got)
+	}
+	// The prior output is read as its raw file through the files API, not through readTable.
+	var readContent bool
+	for _, c := range mock.Calls() {
+		if !strings.Contains(c.Path, testOutputRID) {
+			continue
+		}
+		if strings.HasSuffix(c.Path, "/readTable") {
+			t.Fatalf("expected the JSONL output not to be read through readTable, got %s %s", c.Method, c.Path)
+		}
+		readContent = readContent || strings.HasSuffix(c.Path, "/files/enriched.jsonl/content")
+	}
+	if !readContent {
+		t.Fatalf("expected the prior JSONL output to be read through the files API")
+	}
+}
+
+func TestRunFoundry_OutputFormatJSONLRejectsUnsupportedOptions(t *testing.T) {
+	t.Parallel()
+
+	custom := pipeline.DefaultRowSchema().WithColumns(pipeline.ColumnSpec{Name: "segment"})
+	for _, tc := range []struct {
+		name  string
+		fopts app.FoundryOptions
+		opts  pipeline.Options
+	}{
+		{name: "custom schema", opts: pipeline.Options{Schema: custom}},
+		{name: "recache on schema change", fopts: app.FoundryOptions{RecacheOnSchemaChange: true}},
+		{name: "commit every", fopts: app.FoundryOptions{CommitEvery: 1}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+			fopts := tc.fopts
+			fopts.InputAlias, fopts.OutputAlias = "input", "output"
+			fopts.OutputWriteMode, fopts.OutputFormat = "dataset", app.OutputFormatJSONL
+			_, err := app.RunFoundryWithOptions(context.Background(), env, fopts, tc.opts, testEnricher{})
+			if app.ClassifyFailure(err) != app.FailureConfig || !strings.Contains(err.Error(), "jsonl output cannot be combined") {
+				t.Fatalf("expected a jsonl config error, got %v", err)
+			}
+			if len(mock.Uploads()) != 0 {
+				t.Fatalf("expected nothing written, got %d uploads", len(mock.Uploads()))
+			}
+		})
+	}
+
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.csv")
+	if err := os.WriteFile(inputPath, []byte("email\nalice@example.com\n"), 0644); err != nil {
+		t.Fatalf("write input csv: %v", err)
+	}
+	_, err := app.RunLocalWithOptions(context.Background(), app.LocalOptions{
+		InputPath:    inputPath,
+		OutputPath:   filepath.Join(dir, "output.jsonl"),
+		OutputFormat: app.OutputFormatJSONL,
+	}, pipeline.Options{Schema: custom}, testEnricher{})
+	if app.ClassifyFailure(err) != app.FailureConfig {
+		t.Fatalf("expected a config error for a local jsonl output with a custom schema, got %v", err)
+	}
+}
+
+func TestRunFoundry_OutputFormatJSONLRejectedForStream(t *testing.T) {
+	t.Parallel()
+
+	_, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "stream",
+		OutputFormat:    app.OutputFormatJSONL,
+	}, pipeline.Options{}, testEnricher{})
+	if app.ClassifyFailure(err) != app.FailureConfig || !strings.Contains(err.Error(), "output format jsonl") {
+		t.Fatalf("expected output format config error, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+func TestRunFoundry_OutputSortEmailOrdersDatasetRows(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email\ncarol@new.test\n Alice@example.com\ndave@z.test\nbob@corp.test\n")
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+		OutputSort:      "email",
+	}, pipeline.Options{Workers: 3}, testEnricher{}); err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+
+	uploads := mock.Uploads()
+	if len(uploads) != 1 {
+		t.Fatalf("expected 1 upload, got %d", len(uploads))
+	}
+	rows, err := pipeline.ReadCSV(bytes.NewReader(uploads[0].Bytes))
+	if err != nil {
+		t.Fatalf("parse uploaded csv: %v", err)
+	}
+	got := make([]string, 0, len(rows))
+	for _, row := range rows {
+		got = append(got, row.Email)
+	}
+	want := []string{"Alice@example.com", "bob@corp.test", "carol@new.test", "dave@z.test"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("unexpected row order:\nwant=%v\ngot=%v", want, got)
+	}
+}
+
+func TestRunFoundry_InvalidOutputSortFails(t *testing.T) {
+	t.Parallel()
+
+	_, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+		OutputSort:      "company",
+	}, pipeline.Options{}, testEnricher{})
+	if err == nil {
+		t.Fatalf("expected invalid output-sort to fail")
+	}
+}
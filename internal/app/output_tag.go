@@ -0,0 +1,33 @@
+package app
+
+import (
+	"context"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+	foundryio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/foundry"
+)
+
+// tagOutput creates a branch named runID on the output dataset at the transaction the run just
+// committed, so downstream consumers can pin this run's output by name, and returns the tag. An
+// upload into a transaction Foundry opened for the build is not committed yet and is not tagged.
+// Tagging never fails the run: the output is already committed, so a failure is recorded as a
+// WarningOutputTag warning and "" is returned.
+func tagOutput(
+	ctx context.Context,
+	client *foundry.Client,
+	outputRef foundry.DatasetRef,
+	upload foundryio.UploadResult,
+	runID string,
+	warn *warningCollector,
+) string {
+	if !upload.Committed {
+		warn.warnf(WarningOutputTag, "tag output: transaction %s is committed by the build, not this run; not tagged", upload.TransactionRID)
+		return ""
+	}
+	if err := client.CreateBranch(ctx, outputRef.RID, runID, upload.TransactionRID); err != nil {
+		warn.warnf(WarningOutputTag, "tag output: create branch %q at transaction %s failed: %s", runID, upload.TransactionRID, err)
+		return ""
+	}
+	warn.logf("tag output: branch %q now points at transaction %s", runID, upload.TransactionRID)
+	return runID
+}
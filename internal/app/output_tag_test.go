@@ -0,0 +1,100 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+)
+
+func TestRunFoundry_TagOutputCreatesBranchAtCommittedTransaction(t *testing.T) {
+	t.Parallel()
+
+	_, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	fopts := app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+		RunID:           "run-tag-1",
+		TagOutput:       true,
+	}
+	res, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{}, testEnricher{})
+	if err != nil {
+		t.Fatalf("RunFoundryWithOptions: %v", err)
+	}
+	if res.OutputTag != "run-tag-1" {
+		t.Fatalf("expected output tag run-tag-1, got %q (warnings %v)", res.OutputTag, res.Warnings)
+	}
+
+	client, err := foundry.NewClient(env.Services.APIGateway, env.Services.StreamProxy, env.Token, "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	head, err := client.GetBranchTransactionRID(context.Background(), testOutputRID, "run-tag-1")
+	if err != nil {
+		t.Fatalf("GetBranchTransactionRID: %v", err)
+	}
+	if head == "" || head != res.OutputTransactionRID {
+		t.Fatalf("expected tag branch at committed transaction %s, got %s", res.OutputTransactionRID, head)
+	}
+	b, err := client.ReadTableCSV(context.Background(), testOutputRID, "run-tag-1")
+	if err != nil {
+		t.Fatalf("ReadTableCSV: %v", err)
+	}
+	rows, err := pipeline.ReadCSV(bytes.NewReader(b))
+	if err != nil || len(rows) != 1 || rows[0].Email != "alice@example.com" {
+		t.Fatalf("unexpected tagged output rows %#v: %v", rows, err)
+	}
+
+}
+
+func TestRunFoundry_TagOutputExistingBranchWarns(t *testing.T) {
+	t.Parallel()
+
+	_, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	client, err := foundry.NewClient(env.Services.APIGateway, env.Services.StreamProxy, env.Token, "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	first := commitOutputVersion(t, client, []pipeline.Row{{Email: "bob@example.com", Status: "ok"}})
+	if err := client.CreateBranch(context.Background(), testOutputRID, "run-tag-2", first); err != nil {
+		t.Fatalf("CreateBranch: %v", err)
+	}
+
+	// A reused run id cannot move the existing tag: the run still succeeds, with a warning.
+	res, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+		RunID:           "run-tag-2",
+		TagOutput:       true,
+	}, pipeline.Options{}, testEnricher{})
+	if err != nil {
+		t.Fatalf("RunFoundryWithOptions: %v", err)
+	}
+	if res.OutputTag != "" || res.WarningCounts()[app.WarningOutputTag] != 1 {
+		t.Fatalf("expected an output_tag warning and no tag, got tag=%q warnings=%v", res.OutputTag, res.Warnings)
+	}
+	head, err := client.GetBranchTransactionRID(context.Background(), testOutputRID, "run-tag-2")
+	if err != nil || head != first {
+		t.Fatalf("expected the existing tag to stay at %s, got %s (%v)", first, head, err)
+	}
+}
+
+func TestRunFoundry_TagOutputRejectsStreamOutput(t *testing.T) {
+	t.Parallel()
+
+	_, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "stream",
+		TagOutput:       true,
+	}, pipeline.Options{}, testEnricher{})
+	if app.ClassifyFailure(err) != app.FailureConfig {
+		t.Fatalf("expected a config error, got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package app_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+func TestRunFoundry_ReportsCommittedTransactionAndFiles(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	res, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+		OutputChecksum:  true,
+	}, pipeline.Options{}, testEnricher{})
+	if err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+
+	uploads := mock.Uploads()
+	if len(uploads) != 2 {
+		t.Fatalf("expected output and sidecar uploads, got %d", len(uploads))
+	}
+	if res.OutputTransactionRID == "" || res.OutputTransactionRID != uploads[0].TxnID {
+		t.Fatalf("expected transaction %q, got %q", uploads[0].TxnID, res.OutputTransactionRID)
+	}
+	want := []string{uploads[0].FilePath, uploads[1].FilePath}
+	if !slices.Equal(res.OutputFiles, want) {
+		t.Fatalf("expected files %v, got %v", want, res.OutputFiles)
+	}
+
+	committed := false
+	for _, c := range mock.Calls() {
+		if c.Method == "POST" && strings.HasSuffix(c.Path, "/transactions/"+res.OutputTransactionRID+"/commit") {
+			committed = true
+		}
+	}
+	if !committed {
+		t.Fatalf("expected transaction %s to be committed", res.OutputTransactionRID)
+	}
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/core"
+	foundryio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/foundry"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/output"
+)
+
+// Output schemes accepted by URI-like output values ("<scheme>://<location>").
+const (
+	OutputLocalCSV       = "local-csv"
+	OutputLocalJSONL     = "local-jsonl"
+	OutputStdoutNDJSON   = "stdout-ndjson"
+	OutputFoundryDataset = "foundry-dataset"
+	OutputFoundryStream  = "foundry-stream"
+)
+
+// Output formats for files written by a run (--output-format).
+const (
+	OutputFormatCSV   = "csv"
+	OutputFormatJSONL = "jsonl"
+)
+
+// JSONLContentType is the content type of JSONL dataset output uploads.
+const JSONLContentType = "application/x-ndjson"
+
+// normalizeOutputFormat validates an output format, defaulting empty to OutputFormatCSV.
+func normalizeOutputFormat(v string) (string, error) {
+	switch f := strings.ToLower(strings.TrimSpace(v)); f {
+	case "":
+		return OutputFormatCSV, nil
+	case OutputFormatCSV, OutputFormatJSONL:
+		return f, nil
+	default:
+		return "", fmt.Errorf("invalid output format %q (expected %s|%s)", v, OutputFormatCSV, OutputFormatJSONL)
+	}
+}
+
+// validateJSONLOutput rejects options a JSONL output cannot honour. JSONL rows take the stream
+// record shape, so a custom schema's columns (and their getters) would be silently dropped. In
+// Foundry mode a JSONL output also has no header for RecacheOnSchemaChange to compare, and its prior
+// output is read back as the one output file, so checkpoint chunk files would never be read.
+func validateJSONLOutput(schema pipeline.RowSchema, recacheOnSchemaChange bool, commitEvery int) error {
+	switch {
+	case len(schema.Columns) > 0:
+		return fmt.Errorf("%s output cannot be combined with a custom output schema", OutputFormatJSONL)
+	case recacheOnSchemaChange:
+		return fmt.Errorf("%s output cannot be combined with recache-on-schema-change: it has no header to compare", OutputFormatJSONL)
+	case commitEvery > 0:
+		return fmt.Errorf("%s output cannot be combined with commit-every", OutputFormatJSONL)
+	}
+	return nil
+}
+
+// localOutputScheme returns the scheme of a plain local --output path in format.
+func localOutputScheme(format string) string {
+	if format == OutputFormatJSONL {
+		return OutputLocalJSONL
+	}
+	return OutputLocalCSV
+}
+
+// outputs returns the registry every --output value resolves through. The local schemes open row
+// sinks local mode writes through: a plain --output path is a local-csv location, or local-jsonl
+// with --output-format=jsonl. CSV outputs are written with schema, and every local output with
+// extraColumns; omitAudit writes them without the audit columns. The Foundry schemes open a
+// foundryOutput, which Foundry mode writes itself.
+func outputs(schema pipeline.RowSchema, extraColumns []string, omitAudit bool, stdout io.Writer) *output.Registry[pipeline.Row] {
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	r := output.NewRegistry[pipeline.Row]()
+	r.Register(OutputLocalCSV, func(location string) (core.OutputAdapter[pipeline.Row], error) {
+		if location == "" {
+			return nil, fmt.Errorf("%s output requires a file path", OutputLocalCSV)
+		}
+		return pipeline.CSVFileOutput{Path: location, Schema: schema, ExtraColumns: extraColumns, OmitAuditColumns: omitAudit}, nil
+	})
+	r.Register(OutputLocalJSONL, func(location string) (core.OutputAdapter[pipeline.Row], error) {
+		if location == "" {
+			return nil, fmt.Errorf("%s output requires a file path", OutputLocalJSONL)
+		}
+		return pipeline.JSONLFileOutput{Path: location, ExtraColumns: extraColumns, OmitAuditColumns: omitAudit}, nil
+	})
+	r.Register(OutputStdoutNDJSON, func(string) (core.OutputAdapter[pipeline.Row], error) {
+		return pipeline.NDJSONOutput{W: stdout, ExtraColumns: extraColumns, OmitAuditColumns: omitAudit}, nil
+	})
+	for scheme, mode := range map[string]string{
+		OutputFoundryDataset: foundryio.OutputModeDataset,
+		OutputFoundryStream:  foundryio.OutputModeStream,
+	} {
+		r.Register(scheme, func(location string) (core.OutputAdapter[pipeline.Row], error) {
+			alias := strings.TrimSpace(location)
+			if alias == "" {
+				return nil, fmt.Errorf("%s output requires an alias", scheme)
+			}
+			return foundryOutput{scheme: scheme, alias: alias, mode: mode}, nil
+		})
+	}
+	return r
+}
+
+// foundryOutput is the adapter the Foundry schemes open. Foundry outputs are not plain row sinks: a
+// run reads the prior output, merges, and commits or publishes incrementally, so
+// RunFoundryWithOptions takes the alias and write mode from it and writes the output itself. Store
+// is reached only when a Foundry scheme is used outside Foundry mode, and fails.
+type foundryOutput struct {
+	scheme string
+	alias  string
+	mode   string
+}
+
+func (o foundryOutput) Store(context.Context, []pipeline.Row) error {
+	return fmt.Errorf("%s output requires foundry mode", o.scheme)
+}
+
+// resolveFoundryOutput resolves a "foundry-dataset://<alias>" or "foundry-stream://<alias>" value
+// through the output registry into an output alias and write mode.
+func resolveFoundryOutput(v string) (alias, mode string, err error) {
+	out, err := outputs(pipeline.RowSchema{}, nil, false, nil).Open(output.ParseTarget(v, ""))
+	if err != nil {
+		return "", "", fmt.Errorf("invalid foundry output %q: %w", v, err)
+	}
+	fo, ok := out.(foundryOutput)
+	if !ok {
+		return "", "", fmt.Errorf("invalid foundry output %q (expected %s://<alias>|%s://<alias>)", v, OutputFoundryDataset, OutputFoundryStream)
+	}
+	return fo.alias, fo.mode, nil
+}
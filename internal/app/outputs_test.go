@@ -0,0 +1,149 @@
+package app_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+func writeLocalInput(t *testing.T, csv string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "input.csv")
+	if err := os.WriteFile(p, []byte(csv), 0644); err != nil {
+		t.Fatalf("write input csv: %v", err)
+	}
+	return p
+}
+
+func TestRunLocal_StdoutNDJSONOutput(t *testing.T) {
+	t.Parallel()
+
+	var stdout bytes.Buffer
+	if _, err := app.RunLocalWithOptions(context.Background(), app.LocalOptions{
+		InputPath:          writeLocalInput(t, "customer_id,email\nc-1,alice@example.com\nc-2,bob@corp.test\n"),
+		OutputPath:         "stdout-ndjson://",
+		Stdout:             &stdout,
+		PassthroughColumns: []string{"customer_id"},
+	}, pipeline.Options{}, testEnricher{}); err != nil {
+		t.Fatalf("RunLocalWithOptions failed: %v", err)
+	}
+
+	var recs []map[string]any
+	sc := bufio.NewScanner(&stdout)
+	for sc.Scan() {
+		var rec map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("line %d is not JSON: %v: %q", len(recs)+1, err, sc.Text())
+		}
+		recs = append(recs, rec)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("expected 2 NDJSON lines, got %d", len(recs))
+	}
+	for i, want := range []struct{ email, customerID, company string }{
+		{"alice@example.com", "c-1", "example.com"},
+		{"bob@corp.test", "c-2", "corp.test"},
+	} {
+		rec := recs[i]
+		if rec["email"] != want.email || rec["customer_id"] != want.customerID || rec["company"] != want.company || rec["status"] != "ok" {
+			t.Fatalf("line %d: unexpected record %v", i+1, rec)
+		}
+		if v, ok := rec["error"]; !ok || v != nil {
+			t.Fatalf("line %d: expected empty error as null, got %v", i+1, rec)
+		}
+	}
+}
+
+func TestRunLocal_LocalCSVOutputURI(t *testing.T) {
+	t.Parallel()
+
+	outputPath := filepath.Join(t.TempDir(), "output.csv")
+	if _, err := app.RunLocalWithOptions(context.Background(), app.LocalOptions{
+		InputPath:  writeLocalInput(t, "email\nalice@example.com\n"),
+		OutputPath: "local-csv://" + outputPath,
+	}, pipeline.Options{}, testEnricher{}); err != nil {
+		t.Fatalf("RunLocalWithOptions failed: %v", err)
+	}
+	b, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	rows, err := pipeline.ReadCSV(bytes.NewReader(b))
+	if err != nil || len(rows) != 1 || rows[0].Email != "alice@example.com" {
+		t.Fatalf("unexpected output rows %#v (err=%v)", rows, err)
+	}
+}
+
+func TestRunLocal_InvalidOutputSchemeFailsBeforeEnrichment(t *testing.T) {
+	t.Parallel()
+
+	for _, out := range []string{"s3://bucket/key", "foundry-dataset://output"} {
+		enricher := &countingEnricher{}
+		_, err := app.RunLocalWithOptions(context.Background(), app.LocalOptions{
+			InputPath:  writeLocalInput(t, "email\nalice@example.com\n"),
+			OutputPath: out,
+		}, pipeline.Options{}, enricher)
+		if err == nil {
+			t.Fatalf("%s: expected error", out)
+		}
+		if got := enricher.count("alice@example.com"); got != 0 {
+			t.Fatalf("%s: expected no enrichment, got %d calls", out, got)
+		}
+	}
+}
+
+func TestRunFoundry_OutputURISelectsWriteMode(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	mock.CreateStream(testOutputRID)
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "ignored",
+		OutputWriteMode: "dataset",
+		Output:          "foundry-stream://output",
+	}, pipeline.Options{}, testEnricher{}); err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+	if recs := mock.StreamRecords(testOutputRID, "master"); len(recs) != 1 {
+		t.Fatalf("expected 1 stream record, got %d", len(recs))
+	}
+
+	for _, out := range []string{"stdout-ndjson://", "foundry-dataset://", "s3://bucket/key"} {
+		_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+			InputAlias: "input",
+			Output:     out,
+		}, pipeline.Options{}, testEnricher{})
+		if err == nil || !strings.Contains(err.Error(), "invalid foundry output") || app.ClassifyFailure(err) != app.FailureConfig {
+			t.Fatalf("%s: expected an invalid foundry output config error, got %v", out, err)
+		}
+	}
+}
+
+func TestRunLocal_CaptureCandidateWritesCandidateColumn(t *testing.T) {
+	t.Parallel()
+
+	outputPath := filepath.Join(t.TempDir(), "output.csv")
+	if _, err := app.RunLocalWithOptions(context.Background(), app.LocalOptions{
+		InputPath:  writeLocalInput(t, "email\nalice@example.com\n"),
+		OutputPath: outputPath,
+	}, pipeline.Options{CaptureCandidate: true}, testEnricher{}); err != nil {
+		t.Fatalf("RunLocalWithOptions failed: %v", err)
+	}
+	b, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], ","+pipeline.CandidateColumn) || !strings.HasSuffix(lines[1], ",0") {
+		t.Fatalf("expected a trailing %s column holding the chosen candidate, got:\n%s", pipeline.CandidateColumn, b)
+	}
+}
@@ -0,0 +1,86 @@
+package app_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+func TestRunFoundry_MaxPartialAttempts(t *testing.T) {
+	t.Parallel()
+
+	for _, mode := range []string{"dataset", "stream"} {
+		t.Run(mode, func(t *testing.T) {
+			t.Parallel()
+
+			mock, env := newMockFoundryEnv(t, "email\nalice@example.com\nbob@corp.test\n")
+			if mode == "stream" {
+				mock.CreateStream(testOutputRID)
+			}
+			// countingEnricher fills only company, so every row is partial below 0.5.
+			enricher := &countingEnricher{}
+			run := func() app.RunResult {
+				t.Helper()
+				res, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+					InputAlias:      "input",
+					OutputAlias:     "output",
+					OutputWriteMode: mode,
+				}, pipeline.Options{MinCompleteness: 0.5, MaxPartialAttempts: 2}, enricher)
+				if err != nil {
+					t.Fatalf("RunFoundryWithOptions failed: %v", err)
+				}
+				return res
+			}
+			assertCalls := func(want int) {
+				t.Helper()
+				for _, email := range []string{"alice@example.com", "bob@corp.test"} {
+					if got := enricher.count(email); got != want {
+						t.Fatalf("%s: expected %d enrich calls, got %d", email, want, got)
+					}
+				}
+			}
+			lastAttempts := func() string {
+				t.Helper()
+				if mode == "stream" {
+					recs := mock.StreamRecords(testOutputRID, "master")
+					v, _ := recs[len(recs)-1][pipeline.PartialAttemptsColumn].(string)
+					return v
+				}
+				uploads := mock.Uploads()
+				lines := strings.Split(strings.TrimSpace(string(uploads[len(uploads)-1].Bytes)), "\n")
+				header := strings.Split(lines[0], ",")
+				last := strings.Split(lines[len(lines)-1], ",")
+				for i, col := range header {
+					if col == pipeline.PartialAttemptsColumn {
+						return last[i]
+					}
+				}
+				t.Fatalf("expected a %s column in the dataset output, got %q", pipeline.PartialAttemptsColumn, lines[0])
+				return ""
+			}
+
+			// The first two runs enrich the partial rows and count the attempts.
+			run()
+			assertCalls(1)
+			if got := lastAttempts(); got != "1" {
+				t.Fatalf("expected attempts=1 after the first run, got %q", got)
+			}
+			if res := run(); res.Plan.CachedRows != 0 {
+				t.Fatalf("expected partial rows below the limit to be enriched again, got %+v", res.Plan)
+			}
+			assertCalls(2)
+			if got := lastAttempts(); got != "2" {
+				t.Fatalf("expected attempts=2 after the second run, got %q", got)
+			}
+
+			// At the limit the partial rows stay cached.
+			if res := run(); res.Plan.CachedRows != 2 {
+				t.Fatalf("expected partial rows at the limit to be cached, got %+v", res.Plan)
+			}
+			assertCalls(2)
+		})
+	}
+}
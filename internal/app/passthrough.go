@@ -0,0 +1,97 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	localio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/local"
+)
+
+// inputPassthrough carries, per fanned-out input email, the values of the passthrough columns read
+// from its input row. The zero value means no passthrough columns were requested.
+type inputPassthrough struct {
+	columns []string
+	values  [][]string
+}
+
+// validatePassthroughColumns rejects passthrough columns that would overwrite an output column or
+// a stream metadata field named by meta.
+func validatePassthroughColumns(columns []string, meta pipeline.StreamMeta) error {
+	reserved := append(pipeline.Header(), pipeline.SourceRowColumn, pipeline.RawResponseColumn, pipeline.PromptTokensColumn, pipeline.ResponseTokensColumn, pipeline.CandidateColumn, pipeline.PartialAttemptsColumn, pipeline.WrittenAtColumn)
+	for _, key := range meta.Header() {
+		reserved = append(reserved, strings.ToLower(key))
+	}
+	seen := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		key := strings.ToLower(strings.TrimSpace(col))
+		for _, r := range reserved {
+			if key == r {
+				return fmt.Errorf("passthrough column %q collides with an output column", col)
+			}
+		}
+		if seen[key] {
+			return fmt.Errorf("duplicate passthrough column %q", col)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+func passthroughFromItems(items []localio.EmailItem, columns []string) inputPassthrough {
+	if len(columns) == 0 {
+		return inputPassthrough{}
+	}
+	values := make([][]string, len(items))
+	for i, item := range items {
+		values[i] = item.Passthrough
+	}
+	return inputPassthrough{columns: columns, values: values}
+}
+
+func (p inputPassthrough) extraColumns() []string {
+	return p.columns
+}
+
+// tagRows tags rows aligned with the input emails (as in incrementalPlan.rows).
+func (p inputPassthrough) tagRows(rows []pipeline.Row) {
+	if len(p.columns) == 0 {
+		return
+	}
+	for i := range rows {
+		if i >= len(p.values) {
+			return
+		}
+		rows[i] = p.tag(rows[i], p.values[i])
+	}
+}
+
+// streamTagger returns a func that tags a deduplicated row with the passthrough values of the first
+// input row its email was read from, matching inputSourceRows.streamTagger.
+func (p inputPassthrough) streamTagger(emails []string) func(pipeline.Row) pipeline.Row {
+	if len(p.columns) == 0 {
+		return func(row pipeline.Row) pipeline.Row { return row }
+	}
+	first := make(map[string][]string, len(emails))
+	for i, email := range emails {
+		key := emailKey(email)
+		if _, ok := first[key]; !ok && i < len(p.values) {
+			first[key] = p.values[i]
+		}
+	}
+	return func(row pipeline.Row) pipeline.Row {
+		if values, ok := first[emailKey(row.Email)]; ok {
+			return p.tag(row, values)
+		}
+		return row
+	}
+}
+
+func (p inputPassthrough) tag(row pipeline.Row, values []string) pipeline.Row {
+	for j, col := range p.columns {
+		if j < len(values) {
+			row = row.WithExtra(col, values[j])
+		}
+	}
+	return row
+}
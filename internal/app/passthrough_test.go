@@ -0,0 +1,130 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+)
+
+const passthroughInput = "customer_id,email\n" +
+	"c-1,alice@example.com\n" +
+	"c-2,bob@corp.test\n" +
+	"c-3,alice@example.com\n"
+
+func assertPassthroughCSV(t *testing.T, b []byte) {
+	t.Helper()
+
+	records, err := csv.NewReader(bytes.NewReader(b)).ReadAll()
+	if err != nil {
+		t.Fatalf("parse output csv: %v", err)
+	}
+	wantHeader := append(pipeline.Header(), "customer_id")
+	if !slices.Equal(records[0], wantHeader) {
+		t.Fatalf("unexpected header:\nwant=%v\ngot=%v", wantHeader, records[0])
+	}
+	want := [][2]string{
+		{"alice@example.com", "c-1"},
+		{"bob@corp.test", "c-2"},
+		{"alice@example.com", "c-3"},
+	}
+	if len(records)-1 != len(want) {
+		t.Fatalf("expected %d output rows, got %d: %v", len(want), len(records)-1, records[1:])
+	}
+	idx := len(wantHeader) - 1
+	for i, w := range want {
+		rec := records[i+1]
+		if rec[0] != w[0] || rec[idx] != w[1] {
+			t.Fatalf("row[%d]: want email=%q customer_id=%q, got email=%q customer_id=%q", i, w[0], w[1], rec[0], rec[idx])
+		}
+	}
+}
+
+func TestRunLocal_PassthroughColumnsAlignedToEmail(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.csv")
+	outputPath := filepath.Join(dir, "output.csv")
+	if err := os.WriteFile(inputPath, []byte(passthroughInput), 0644); err != nil {
+		t.Fatalf("write input csv: %v", err)
+	}
+
+	enricher := &countingEnricher{}
+	if _, err := app.RunLocalWithOptions(context.Background(), app.LocalOptions{
+		InputPath:          inputPath,
+		OutputPath:         outputPath,
+		PassthroughColumns: []string{"customer_id"},
+	}, pipeline.Options{Workers: 2}, enricher); err != nil {
+		t.Fatalf("RunLocalWithOptions failed: %v", err)
+	}
+
+	if got := enricher.count("alice@example.com"); got != 1 {
+		t.Fatalf("expected duplicate alice to be enriched once, got %d calls", got)
+	}
+	b, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	assertPassthroughCSV(t, b)
+}
+
+func TestRunFoundry_PassthroughColumnsInDatasetAndStream(t *testing.T) {
+	t.Parallel()
+
+	streamRID := "ri.foundry.main.dataset.55555555-5555-5555-5555-555555555555"
+	mock, env := newMockFoundryEnv(t, passthroughInput)
+	mock.CreateStream(streamRID)
+	env.Aliases["stream"] = foundry.DatasetRef{RID: streamRID, Branch: "master"}
+
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:         "input",
+		OutputAlias:        "output",
+		OutputWriteMode:    "both",
+		StreamOutputAlias:  "stream",
+		PassthroughColumns: []string{"customer_id"},
+	}, pipeline.Options{Workers: 2}, testEnricher{}); err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+
+	uploads := mock.Uploads()
+	if len(uploads) != 1 {
+		t.Fatalf("expected 1 upload, got %d", len(uploads))
+	}
+	assertPassthroughCSV(t, uploads[0].Bytes)
+
+	// Stream output has one record per distinct email, tagged from its first input row.
+	want := map[string]string{"alice@example.com": "c-1", "bob@corp.test": "c-2"}
+	recs := mock.StreamRecords(streamRID, "master")
+	if len(recs) != len(want) {
+		t.Fatalf("expected %d stream records, got %d: %#v", len(want), len(recs), recs)
+	}
+	for _, rec := range recs {
+		email, _ := rec["email"].(string)
+		if got, _ := rec["customer_id"].(string); got != want[email] {
+			t.Fatalf("stream record for %s: want customer_id=%q, got %#v", email, want[email], rec)
+		}
+	}
+}
+
+func TestRunFoundry_PassthroughColumnCollisionFails(t *testing.T) {
+	t.Parallel()
+
+	_, env := newMockFoundryEnv(t, "email,company\nalice@example.com,Example\n")
+	_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:         "input",
+		OutputAlias:        "output",
+		OutputWriteMode:    "dataset",
+		PassthroughColumns: []string{"company"},
+	}, pipeline.Options{}, testEnricher{})
+	if err == nil {
+		t.Fatalf("expected passthrough column colliding with output schema to fail")
+	}
+}
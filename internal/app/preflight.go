@@ -0,0 +1,49 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+	foundryio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/foundry"
+)
+
+// preflightDatasetWrite checks that the dataset output is writable before any enrichment spend by
+// opening and aborting a probe transaction.
+func preflightDatasetWrite(ctx context.Context, client *foundry.Client, ref foundry.DatasetRef) error {
+	err := foundryio.CheckDatasetWritePermission(ctx, client, ref)
+	if err == nil {
+		return nil
+	}
+	if isPermissionDeniedError(err) {
+		return fmt.Errorf("write permission check failed: no permission to write dataset output %s@%s: %w", ref.RID, defaultBranch(ref.Branch), err)
+	}
+	return fmt.Errorf("write permission check failed for dataset output %s@%s: %w", ref.RID, defaultBranch(ref.Branch), err)
+}
+
+// preflightStreamWrite checks that the stream output accepts publishes before any enrichment spend.
+// Only a permission denial fails the run; other probe errors (for example a stack without the batch
+// publish endpoint) are logged and the run proceeds. A backend without foundryio.PublishProber is not
+// probed; that is recorded as a WarningStreamProbeSkipped warning.
+func preflightStreamWrite(
+	ctx context.Context,
+	backend foundryio.StreamBackend,
+	ref foundry.DatasetRef,
+	logf func(format string, args ...any),
+	warn *warningCollector,
+) error {
+	prober, ok := backend.(foundryio.PublishProber)
+	if !ok {
+		warn.warnf(WarningStreamProbeSkipped, "write permission check: stream backend %T cannot probe publishes to %s@%s; skipped", backend, ref.RID, defaultBranch(ref.Branch))
+		return nil
+	}
+	err := prober.ProbePublish(ctx, ref)
+	if err == nil {
+		return nil
+	}
+	if isPermissionDeniedError(err) {
+		return fmt.Errorf("write permission check failed: no permission to publish to stream output %s@%s: %w", ref.RID, defaultBranch(ref.Branch), err)
+	}
+	logf("write permission check: stream publish probe for %s@%s failed; proceeding: %s", ref.RID, defaultBranch(ref.Branch), err)
+	return nil
+}
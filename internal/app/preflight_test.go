@@ -0,0 +1,52 @@
+package app
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/mockfoundry"
+	foundryio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/foundry"
+)
+
+// baseStreamBackend hides the optional extensions of the backend it wraps.
+type baseStreamBackend struct {
+	foundryio.StreamBackend
+}
+
+func TestPreflightStreamWrite_SkipsBackendWithoutProber(t *testing.T) {
+	t.Parallel()
+
+	const streamRID = "ri.foundry.main.dataset.33333333-3333-3333-3333-333333333333"
+	mock := mockfoundry.New(t.TempDir(), t.TempDir())
+	mock.CreateStream(streamRID)
+	ts := httptest.NewServer(mock.Handler())
+	t.Cleanup(ts.Close)
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("new foundry client: %v", err)
+	}
+	ref := foundry.DatasetRef{RID: streamRID, Branch: "master"}
+	logf := func(string, ...any) {}
+
+	warn := &warningCollector{logf: logf}
+	if err := preflightStreamWrite(context.Background(), foundryio.NewLegacyStreamProxyBackend(client), ref, logf, warn); err != nil {
+		t.Fatalf("preflightStreamWrite: %v", err)
+	}
+	if got := len(mock.Calls()); got != 1 || len(warn.warnings()) != 0 {
+		t.Fatalf("expected one probe call and no warnings, got %d calls, warnings %v", got, warn.warnings())
+	}
+
+	warn = &warningCollector{logf: logf}
+	backend := baseStreamBackend{foundryio.NewLegacyStreamProxyBackend(client)}
+	if err := preflightStreamWrite(context.Background(), backend, ref, logf, warn); err != nil {
+		t.Fatalf("preflightStreamWrite: %v", err)
+	}
+	if got := len(mock.Calls()); got != 1 {
+		t.Fatalf("expected no probe call without a PublishProber, got %d calls", got)
+	}
+	if got := warn.warnings(); len(got) != 1 || got[0].Code != WarningStreamProbeSkipped {
+		t.Fatalf("expected one %s warning, got %v", WarningStreamProbeSkipped, got)
+	}
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	localio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/local"
+)
+
+func TestScanRowsByEmailMatchesBufferedParse(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := pipeline.WriteCSV(&buf, []pipeline.Row{
+		{Email: "alice@example.com", Status: "error", Error: "timeout"},
+		{Email: "bob@corp.test", Company: "Corp", Status: "ok"},
+		{Email: " alice@example.com ", Company: "Example", Status: "ok"},
+		{Email: "", Status: "ok"},
+		{Email: "bob@corp.test", Status: "error", Error: "later failure"},
+		{Email: "carol@new.test", Status: "skipped", SkipReason: "filtered"},
+	}); err != nil {
+		t.Fatalf("write csv: %v", err)
+	}
+	b := buf.Bytes()
+
+	// The buffered reference: parse every row, then deduplicate.
+	rows, err := pipeline.ReadCSVWithLimits(bytes.NewReader(b), localio.CSVLimits{})
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	want := map[string]pipeline.Row{}
+	for _, row := range rows {
+		key := emailKey(row.Email)
+		if key == "" {
+			continue
+		}
+		if prev, ok := want[key]; ok {
+			row = chooseBestIncrementalRow(prev, row)
+		}
+		want[key] = row
+	}
+
+	got, header, err := scanRowsByEmail(iotest.OneByteReader(bytes.NewReader(b)), false, localio.CSVLimits{}, pipeline.RowSchema{}, nil)
+	if err != nil {
+		t.Fatalf("scanRowsByEmail: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("streamed cache differs from buffered:\n got %+v\nwant %+v", got, want)
+	}
+	if !reflect.DeepEqual(header, pipeline.Header()) {
+		t.Fatalf("expected header %v, got %v", pipeline.Header(), header)
+	}
+	if len(got) != 3 || got["alice@example.com"].Status != "ok" {
+		t.Fatalf("expected 3 deduplicated emails preferring ok rows, got %+v", got)
+	}
+}
+
+func TestOutputDigestIgnoresEncoding(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	rows := []pipeline.Row{
+		{Email: "alice@example.com", Company: "Example", Status: "ok"},
+		{Email: "bob@corp.test", Status: "ok"},
+	}
+	if err := pipeline.WriteCSV(&buf, rows); err != nil {
+		t.Fatalf("write csv: %v", err)
+	}
+	written := buf.String()
+	// The same rows as readTable might serve them: every field quoted, CRLF line endings.
+	var served strings.Builder
+	for _, line := range strings.Split(strings.TrimSuffix(written, "\n"), "\n") {
+		fields := strings.Split(line, ",")
+		for i, f := range fields {
+			fields[i] = strconv.Quote(f)
+		}
+		served.WriteString(strings.Join(fields, ",") + "\r\n")
+	}
+	changed := strings.Replace(written, "Example", "Example Inc", 1)
+
+	digest := func(s string) []byte {
+		t.Helper()
+		sum, err := outputDigest([]byte(s), false, localio.CSVLimits{}, pipeline.RowSchema{})
+		if err != nil {
+			t.Fatalf("outputDigest: %v", err)
+		}
+		return sum
+	}
+	if !bytes.Equal(digest(written), digest(served.String())) {
+		t.Fatalf("expected re-encoded rows to digest equal")
+	}
+	if bytes.Equal(digest(written), digest(changed)) {
+		t.Fatalf("expected a changed row to change the digest")
+	}
+}
@@ -0,0 +1,57 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+)
+
+func TestRunFoundry_RecacheEmptyOK(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name      string
+		recache   bool
+		wantEmpty int
+	}{
+		{name: "default caches empty ok", recache: false, wantEmpty: 0},
+		{name: "recache-empty-ok re-enriches empty ok", recache: true, wantEmpty: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, env := newMockFoundryEnv(t, "email\nfull@example.com\nempty@example.com\n")
+			client, err := foundry.NewClient(env.Services.APIGateway, env.Services.StreamProxy, env.Token, "")
+			if err != nil {
+				t.Fatalf("new foundry client: %v", err)
+			}
+			commitOutputVersion(t, client, []pipeline.Row{
+				{Email: "full@example.com", Company: "Example", Status: "ok"},
+				{Email: "empty@example.com", Confidence: "0.1", Status: "ok"},
+			})
+
+			enricher := &countingEnricher{}
+			res, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+				InputAlias:      "input",
+				OutputAlias:     "output",
+				OutputWriteMode: "dataset",
+				RecacheEmptyOK:  tc.recache,
+			}, pipeline.Options{}, enricher)
+			if err != nil {
+				t.Fatalf("RunFoundryWithOptions failed: %v", err)
+			}
+			if got := enricher.count("full@example.com"); got != 0 {
+				t.Fatalf("expected populated ok row to stay cached, got %d enrich calls", got)
+			}
+			if got := enricher.count("empty@example.com"); got != tc.wantEmpty {
+				t.Fatalf("expected %d enrich calls for empty ok row, got %d", tc.wantEmpty, got)
+			}
+			if want := 2 - tc.wantEmpty; res.Plan.CachedRows != want {
+				t.Fatalf("expected %d cached rows, got %d", want, res.Plan.CachedRows)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package app_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+func TestRunFoundry_ReenrichAfter(t *testing.T) {
+	t.Parallel()
+
+	for _, mode := range []string{"dataset", "stream"} {
+		t.Run(mode, func(t *testing.T) {
+			t.Parallel()
+
+			mock, env := newMockFoundryEnv(t, "email\nalice@example.com\nbob@corp.test\n")
+			if mode == "stream" {
+				mock.CreateStream(testOutputRID)
+			}
+			enricher := &countingEnricher{}
+			run := func(ttl *time.Duration) app.RunResult {
+				t.Helper()
+				res, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+					InputAlias:      "input",
+					OutputAlias:     "output",
+					OutputWriteMode: mode,
+				}, pipeline.Options{ReenrichAfter: ttl}, enricher)
+				if err != nil {
+					t.Fatalf("RunFoundryWithOptions(ReenrichAfter=%v) failed: %v", ttl, err)
+				}
+				return res
+			}
+			assertCalls := func(want int) {
+				t.Helper()
+				for _, email := range []string{"alice@example.com", "bob@corp.test"} {
+					if got := enricher.count(email); got != want {
+						t.Fatalf("%s: expected %d enrich calls, got %d", email, want, got)
+					}
+				}
+			}
+
+			ttl := func(d time.Duration) *time.Duration { return &d }
+
+			// Unset, rows are not stamped and stay cached.
+			run(nil)
+			if res := run(nil); res.Plan.CachedRows != 2 {
+				t.Fatalf("expected every row cached without a TTL, got %+v", res.Plan)
+			}
+			assertCalls(1)
+
+			// Dataset rows written without the TTL have no written_at, so they are stale under any
+			// TTL. Stream records always carry written_at as run metadata and stay cached.
+			run(ttl(24 * time.Hour))
+			calls := 1
+			if mode == "dataset" {
+				calls++
+				assertCalls(calls)
+				uploads := mock.Uploads()
+				if body := string(uploads[len(uploads)-1].Bytes); !strings.Contains(strings.SplitN(body, "\n", 2)[0], pipeline.WrittenAtColumn) {
+					t.Fatalf("expected a %s column in the dataset output, got %q", pipeline.WrittenAtColumn, body)
+				}
+			}
+
+			// A TTL shorter than the rows' age re-enriches every ok row.
+			time.Sleep(time.Millisecond)
+			if res := run(ttl(time.Nanosecond)); res.Plan.CachedRows != 0 {
+				t.Fatalf("expected no cached rows with an expired TTL, got %+v", res.Plan)
+			}
+			calls++
+			assertCalls(calls)
+
+			// A zero TTL re-enriches every ok row, however fresh.
+			if res := run(ttl(0)); res.Plan.CachedRows != 0 {
+				t.Fatalf("expected no cached rows with a zero TTL, got %+v", res.Plan)
+			}
+			calls++
+			assertCalls(calls)
+
+			// A long TTL keeps the re-enriched rows, whose timestamps survived the rewrite.
+			if res := run(ttl(24 * time.Hour)); !res.UpToDate || res.Plan.CachedRows != 2 {
+				t.Fatalf("expected every row cached under a long TTL, got UpToDate=%t %+v", res.UpToDate, res.Plan)
+			}
+			assertCalls(calls)
+		})
+	}
+}
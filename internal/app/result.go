@@ -0,0 +1,98 @@
+package app
+
+import (
+	"time"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+)
+
+// RunResult summarizes a run for programs and tests that embed the pipeline. On error it holds what
+// was determined before the failure.
+type RunResult struct {
+	// RunID stamps Foundry-mode log lines and stream records. It is empty in local mode.
+	RunID string
+	// OutputMode is "dataset", "stream" or "both" in Foundry mode, or the output scheme
+	// (OutputLocalCSV, OutputStdoutNDJSON) in local mode.
+	OutputMode string
+
+	Plan    PlanSummary
+	Metrics RunMetrics
+
+	// UpToDate reports that nothing needed enrichment and the write was skipped.
+	UpToDate bool
+	// OutputFile is the dataset file written (Foundry dataset and both modes) or the local CSV path.
+	OutputFile string
+	// OutputTransactionRID is the dataset transaction the final output was uploaded into, and
+	// OutputFiles the paths written there (the output file and any checksum sidecar). Both are empty
+	// when the dataset write was skipped.
+	OutputTransactionRID string
+	OutputFiles          []string
+	// OutputTag is the branch FoundryOptions.TagOutput created at OutputTransactionRID; empty when
+	// tagging was off or failed.
+	OutputTag string
+	// RowsWritten counts rows written to the dataset or local output.
+	RowsWritten int
+	// RecordsPublished counts records published to the stream (stream and both modes).
+	RecordsPublished int
+	// Checkpoints counts the --commit-every dataset checkpoints committed before the final write.
+	Checkpoints int
+
+	// Warnings lists the non-fatal conditions logged with a "warning: " prefix, in the order they
+	// were noticed; WarningCounts groups them by code.
+	Warnings []Warning
+
+	Duration time.Duration
+}
+
+// PlanSummary holds the incremental plan counts logged as "incremental plan: ...".
+type PlanSummary struct {
+	InputRows int
+	// CachedRows reuse an ok row from the prior output.
+	CachedRows int
+	// SkippedRows were excluded by the domain lists or the input filter.
+	SkippedRows          int
+	RowsToEnrich         int
+	UniqueEmailsToEnrich int
+	// DeferredEmails were left for a later run by --on-budget-exceeded=truncate.
+	DeferredEmails int
+}
+
+// RunMetrics counts the emails enriched in this run.
+type RunMetrics struct {
+	Enriched int
+	OK       int
+	Errors   int
+	// PromptTokens and ResponseTokens total provider-reported usage across attempts (Foundry mode).
+	PromptTokens   int
+	ResponseTokens int
+}
+
+func planSummary(p incrementalPlan, inputRows, skipped, uniqueBeforeBudget int) PlanSummary {
+	return PlanSummary{
+		InputRows:            inputRows,
+		CachedRows:           p.cachedRows,
+		SkippedRows:          skipped,
+		RowsToEnrich:         p.pendingRows,
+		UniqueEmailsToEnrich: len(p.pendingEmails),
+		DeferredEmails:       uniqueBeforeBudget - len(p.pendingEmails),
+	}
+}
+
+// enrichedMetrics counts freshly enriched rows by status.
+func enrichedMetrics(rows []pipeline.Row) RunMetrics {
+	ok, errs := countStatuses(rows)
+	return RunMetrics{Enriched: len(rows), OK: ok, Errors: errs}
+}
+
+// metrics returns the enrichment counts with the usage totals t collected.
+func (t *tracedEnricher) metrics(enriched, ok, errs int) RunMetrics {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return RunMetrics{
+		Enriched:       enriched,
+		OK:             ok,
+		Errors:         errs,
+		PromptTokens:   t.usage.PromptTokens,
+		ResponseTokens: t.usage.ResponseTokens,
+	}
+}
@@ -0,0 +1,101 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry/keepalive"
+	foundryio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/foundry"
+)
+
+// RetryProfile is a named set of retry settings that agree across every retry layer: per-email
+// enrichment retries in the worker pool, Foundry I/O reads and writes, and the compute module
+// keepalive client.
+type RetryProfile struct {
+	Name string
+
+	// MaxRetries, BackoffInitial and BackoffMax set pipeline.Options MaxRetries,
+	// RetryBackoffInitial and RetryBackoffMax.
+	MaxRetries     int
+	BackoffInitial time.Duration
+	BackoffMax     time.Duration
+
+	// InputRead and Write set FoundryOptions.InputReadPolicy.Attempts and
+	// FoundryOptions.WriteRetryPolicy; Publish sets FoundryOptions.PublishRetryPolicy attempts and
+	// backoff.
+	InputRead foundryio.ReadRetryPolicy
+	Write     foundryio.WriteRetryPolicy
+	Publish   foundryio.PublishRetryPolicy
+
+	// KeepalivePostRetries and KeepalivePollBackoffMax set keepalive.Config PostRetries and
+	// PollBackoffMax.
+	KeepalivePostRetries    int
+	KeepalivePollBackoffMax time.Duration
+}
+
+// Retry profile names accepted by RetryProfileNamed.
+const (
+	RetryProfileAggressive = "aggressive"
+	RetryProfileBalanced   = "balanced"
+	RetryProfileGentle     = "gentle"
+)
+
+// retryProfiles holds the built-in profiles. balanced matches the defaults used without a profile;
+// aggressive retries more often with shorter sleeps, for flaky networks and short runs; gentle
+// retries less with longer sleeps, to back off from rate-limited providers and busy stacks.
+var retryProfiles = map[string]RetryProfile{
+	RetryProfileAggressive: {
+		Name:                    RetryProfileAggressive,
+		MaxRetries:              5,
+		BackoffInitial:          100 * time.Millisecond,
+		BackoffMax:              1 * time.Second,
+		InputRead:               foundryio.ReadRetryPolicy{Attempts: 12},
+		Write:                   foundryio.WriteRetryPolicy{MaxAttempts: 32, MaxElapsed: 3 * time.Minute},
+		Publish:                 foundryio.PublishRetryPolicy{Attempts: 12, InitialSleep: 100 * time.Millisecond, MaxSleep: 1 * time.Second},
+		KeepalivePostRetries:    8,
+		KeepalivePollBackoffMax: 2 * time.Second,
+	},
+	RetryProfileBalanced: {
+		Name:                    RetryProfileBalanced,
+		MaxRetries:              3,
+		BackoffInitial:          200 * time.Millisecond,
+		BackoffMax:              2 * time.Second,
+		InputRead:               foundryio.ReadRetryPolicy{Attempts: foundryio.DefaultRetryPolicy.Attempts},
+		Write:                   foundryio.DefaultWriteRetryPolicy,
+		Publish:                 foundryio.PublishRetryPolicy{Attempts: foundryio.DefaultRetryPolicy.Attempts, InitialSleep: foundryio.DefaultRetryPolicy.InitialSleep, MaxSleep: foundryio.DefaultRetryPolicy.MaxSleep},
+		KeepalivePostRetries:    keepalive.DefaultPostRetries,
+		KeepalivePollBackoffMax: keepalive.DefaultPollBackoffMax,
+	},
+	RetryProfileGentle: {
+		Name:                    RetryProfileGentle,
+		MaxRetries:              2,
+		BackoffInitial:          1 * time.Second,
+		BackoffMax:              10 * time.Second,
+		InputRead:               foundryio.ReadRetryPolicy{Attempts: 4},
+		Write:                   foundryio.WriteRetryPolicy{MaxAttempts: 8, MaxElapsed: 5 * time.Minute},
+		Publish:                 foundryio.PublishRetryPolicy{Attempts: 4, InitialSleep: 1 * time.Second, MaxSleep: 10 * time.Second},
+		KeepalivePostRetries:    3,
+		KeepalivePollBackoffMax: 15 * time.Second,
+	},
+}
+
+// RetryProfileNames returns the built-in profile names, sorted.
+func RetryProfileNames() []string {
+	names := make([]string, 0, len(retryProfiles))
+	for name := range retryProfiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// RetryProfileNamed returns the built-in profile called name (case-insensitively).
+func RetryProfileNamed(name string) (RetryProfile, error) {
+	p, ok := retryProfiles[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return RetryProfile{}, invalidConfig(fmt.Errorf("unknown retry profile %q (expected one of %s)", name, strings.Join(RetryProfileNames(), ", ")))
+	}
+	return p, nil
+}
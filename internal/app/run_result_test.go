@@ -0,0 +1,109 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/enrich"
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+)
+
+// failOnPrefixEnricher fails emails starting with "fail@" and enriches the rest like testEnricher.
+type failOnPrefixEnricher struct{}
+
+func (failOnPrefixEnricher) Enrich(ctx context.Context, email string) (enrich.Result, error) {
+	if strings.HasPrefix(email, "fail@") {
+		return enrich.Result{}, errors.New("lookup failed")
+	}
+	return testEnricher{}.Enrich(ctx, email)
+}
+
+const mixedRunInput = "email\n" +
+	"alice@example.com\n" +
+	"bob@corp.test\n" +
+	"bob@corp.test\n" +
+	"fail@corp.test\n" +
+	"dan@deny.test\n"
+
+func TestRunFoundry_ReturnsRunResultForMixedRun(t *testing.T) {
+	t.Parallel()
+
+	_, env := newMockFoundryEnv(t, mixedRunInput)
+	client, err := foundry.NewClient(env.Services.APIGateway, env.Services.StreamProxy, env.Token, "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	commitOutputVersion(t, client, []pipeline.Row{
+		{Email: "alice@example.com", Company: "Cached", Status: "ok"},
+	})
+
+	res, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:       "input",
+		OutputAlias:      "output",
+		OutputWriteMode:  "dataset",
+		EnrichDomainDeny: []string{"deny.test"},
+	}, pipeline.Options{}, failOnPrefixEnricher{})
+	if err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+
+	if !strings.HasPrefix(res.RunID, "run-") {
+		t.Fatalf("expected run id, got %q", res.RunID)
+	}
+	if res.OutputMode != "dataset" {
+		t.Fatalf("expected output mode dataset, got %q", res.OutputMode)
+	}
+	wantPlan := app.PlanSummary{InputRows: 5, CachedRows: 1, SkippedRows: 1, RowsToEnrich: 3, UniqueEmailsToEnrich: 2}
+	if res.Plan != wantPlan {
+		t.Fatalf("unexpected plan:\nwant=%+v\ngot=%+v", wantPlan, res.Plan)
+	}
+	wantMetrics := app.RunMetrics{Enriched: 2, OK: 1, Errors: 1}
+	if res.Metrics != wantMetrics {
+		t.Fatalf("unexpected metrics:\nwant=%+v\ngot=%+v", wantMetrics, res.Metrics)
+	}
+	if res.UpToDate {
+		t.Fatalf("expected a written run, got UpToDate")
+	}
+	if res.OutputFile != "enriched.csv" || res.RowsWritten != 5 {
+		t.Fatalf("expected 5 rows written to enriched.csv, got %d to %q", res.RowsWritten, res.OutputFile)
+	}
+	if res.RecordsPublished != 0 {
+		t.Fatalf("expected no stream records in dataset mode, got %d", res.RecordsPublished)
+	}
+}
+
+func TestRunLocal_ReturnsRunResultForMixedRun(t *testing.T) {
+	t.Parallel()
+
+	inputPath := writeLocalInput(t, mixedRunInput)
+	outputPath := filepath.Join(t.TempDir(), "output.csv")
+
+	res, err := app.RunLocalWithOptions(context.Background(), app.LocalOptions{
+		InputPath:        inputPath,
+		OutputPath:       outputPath,
+		EnrichDomainDeny: []string{"deny.test"},
+	}, pipeline.Options{}, failOnPrefixEnricher{})
+	if err != nil {
+		t.Fatalf("RunLocalWithOptions failed: %v", err)
+	}
+
+	if res.RunID != "" || res.OutputMode != app.OutputLocalCSV {
+		t.Fatalf("unexpected run id/mode: %q/%q", res.RunID, res.OutputMode)
+	}
+	wantPlan := app.PlanSummary{InputRows: 5, SkippedRows: 1, RowsToEnrich: 4, UniqueEmailsToEnrich: 3}
+	if res.Plan != wantPlan {
+		t.Fatalf("unexpected plan:\nwant=%+v\ngot=%+v", wantPlan, res.Plan)
+	}
+	wantMetrics := app.RunMetrics{Enriched: 3, OK: 2, Errors: 1}
+	if res.Metrics != wantMetrics {
+		t.Fatalf("unexpected metrics:\nwant=%+v\ngot=%+v", wantMetrics, res.Metrics)
+	}
+	if res.OutputFile != outputPath || res.RowsWritten != 5 {
+		t.Fatalf("expected 5 rows written to %q, got %d to %q", outputPath, res.RowsWritten, res.OutputFile)
+	}
+}
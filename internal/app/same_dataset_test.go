@@ -0,0 +1,78 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+)
+
+func TestRunFoundry_OutputSameAsInputFailsBeforeEnriching(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	env.Aliases["output"] = foundry.DatasetRef{RID: testInputRID}
+
+	enricher := &countingEnricher{}
+	_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+	}, pipeline.Options{}, enricher)
+	if app.ClassifyFailure(err) != app.FailureConfig || !strings.Contains(err.Error(), "overwrite its own input") {
+		t.Fatalf("expected a config error about overwriting the input, got %v", err)
+	}
+	if len(enricher.calls) != 0 || len(mock.Uploads()) != 0 {
+		t.Fatalf("expected no enrichment or writes, got calls=%v uploads=%d", enricher.calls, len(mock.Uploads()))
+	}
+}
+
+// upsertInput is an input already in the output schema, as an in-place upsert needs: the run reads
+// it back as the prior output too.
+func upsertInput(t *testing.T) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := pipeline.WriteCSV(&buf, []pipeline.Row{{Email: "alice@example.com"}}); err != nil {
+		t.Fatalf("write input csv: %v", err)
+	}
+	return buf.String()
+}
+
+func TestRunFoundry_OutputSameAsInputOnOtherBranchIsAllowed(t *testing.T) {
+	t.Parallel()
+
+	_, env := newMockFoundryEnv(t, upsertInput(t))
+	env.Aliases["output"] = foundry.DatasetRef{RID: testInputRID, Branch: "enriched"}
+
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+	}, pipeline.Options{}, testEnricher{}); err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+}
+
+func TestRunFoundry_AllowInputOutputSameBypassesGuard(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, upsertInput(t))
+	env.Aliases["output"] = foundry.DatasetRef{RID: testInputRID, Branch: "master"}
+
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:           "input",
+		OutputAlias:          "output",
+		OutputWriteMode:      "dataset",
+		AllowInputOutputSame: true,
+	}, pipeline.Options{}, testEnricher{}); err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+	uploads := mock.Uploads()
+	if len(uploads) != 1 || uploads[0].DatasetRID != testInputRID {
+		t.Fatalf("expected one upload to the input dataset, got %#v", uploads)
+	}
+}
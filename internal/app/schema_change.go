@@ -0,0 +1,51 @@
+package app
+
+import (
+	"slices"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+)
+
+// outputHeader returns the dataset output header a run writes: the schema's header (lean with
+// OmitAuditColumns) followed by extra.
+func outputHeader(schema pipeline.RowSchema, omitAuditColumns bool, extra []string) []string {
+	if omitAuditColumns {
+		schema = schema.Lean()
+	}
+	return append(schema.Header(), extra...)
+}
+
+// headerDiff returns the columns of want missing from got (added) and of got missing from want
+// (removed). Column order is ignored: it does not change what a row holds.
+func headerDiff(got, want []string) (added, removed []string) {
+	for _, col := range want {
+		if !slices.Contains(got, col) {
+			added = append(added, col)
+		}
+	}
+	for _, col := range got {
+		if !slices.Contains(want, col) {
+			removed = append(removed, col)
+		}
+	}
+	return added, removed
+}
+
+// recacheOnSchemaChange records a WarningSchemaChange when the prior output's header differs from
+// header and, when enabled (FoundryOptions.RecacheOnSchemaChange), empties the incremental cache so
+// every row is enriched again. A nil priorHeader means there was no prior output.
+func recacheOnSchemaChange(existingByEmail map[string]pipeline.Row, priorHeader, header []string, enabled bool, warn *warningCollector) {
+	if priorHeader == nil {
+		return
+	}
+	added, removed := headerDiff(priorHeader, header)
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+	if !enabled {
+		warn.warnf(WarningSchemaChange, "prior output schema differs from this run's output (added=%v removed=%v); cached rows keep empty values for added columns (set --recache-on-schema-change to re-enrich them)", added, removed)
+		return
+	}
+	warn.warnf(WarningSchemaChange, "recache-on-schema-change: prior output schema differs (added=%v removed=%v); re-enriching all %d prior rows", added, removed, len(existingByEmail))
+	clear(existingByEmail)
+}
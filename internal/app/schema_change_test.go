@@ -0,0 +1,83 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+)
+
+func TestRunFoundry_RecacheOnSchemaChange(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name      string
+		recache   bool
+		wantCalls int
+	}{
+		{name: "default keeps old-schema rows cached", recache: false, wantCalls: 0},
+		{name: "recache-on-schema-change re-enriches old-schema rows", recache: true, wantCalls: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, env := newMockFoundryEnv(t, "email\nalice@example.com\nbob@corp.test\n")
+			client, err := foundry.NewClient(env.Services.APIGateway, env.Services.StreamProxy, env.Token, "")
+			if err != nil {
+				t.Fatalf("new foundry client: %v", err)
+			}
+			// An output written before the later audit columns existed.
+			old := "email,linkedin_url,company,title,description,confidence,status,error,model\n" +
+				"alice@example.com,,Example,,,0.9,ok,,old-model\n" +
+				"bob@corp.test,,Corp,,,0.8,ok,,old-model\n"
+			ctx := context.Background()
+			txnID, err := client.CreateTransaction(ctx, testOutputRID, "master")
+			if err != nil {
+				t.Fatalf("create output transaction: %v", err)
+			}
+			if err := client.UploadFile(ctx, testOutputRID, txnID, "enriched.csv", "text/csv", []byte(old)); err != nil {
+				t.Fatalf("upload output csv: %v", err)
+			}
+			if err := client.CommitTransaction(ctx, testOutputRID, txnID); err != nil {
+				t.Fatalf("commit output transaction: %v", err)
+			}
+
+			enricher := &countingEnricher{}
+			res, err := app.RunFoundryWithOptions(ctx, env, app.FoundryOptions{
+				InputAlias:            "input",
+				OutputAlias:           "output",
+				OutputWriteMode:       "dataset",
+				RecacheOnSchemaChange: tc.recache,
+			}, pipeline.Options{}, enricher)
+			if err != nil {
+				t.Fatalf("RunFoundryWithOptions failed: %v", err)
+			}
+			for _, email := range []string{"alice@example.com", "bob@corp.test"} {
+				if got := enricher.count(email); got != tc.wantCalls {
+					t.Fatalf("expected %d enrich calls for %s, got %d", tc.wantCalls, email, got)
+				}
+			}
+			if want := 2 - 2*tc.wantCalls; res.Plan.CachedRows != want {
+				t.Fatalf("expected %d cached rows, got %d", want, res.Plan.CachedRows)
+			}
+		})
+	}
+}
+
+func TestRunFoundry_RecacheOnSchemaChangeRejectsStream(t *testing.T) {
+	t.Parallel()
+
+	_, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:            "input",
+		OutputAlias:           "output",
+		OutputWriteMode:       "stream",
+		RecacheOnSchemaChange: true,
+	}, pipeline.Options{}, &countingEnricher{})
+	if !errors.Is(err, app.ErrInvalidConfig) {
+		t.Fatalf("expected ErrInvalidConfig, got %v", err)
+	}
+}
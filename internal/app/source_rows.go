@@ -0,0 +1,82 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	localio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/local"
+)
+
+// inputSourceRows records, per fanned-out input email, the input row it was read from. A nil value
+// means email columns were not fanned out and output rows are not tagged.
+type inputSourceRows []int
+
+// validateEmailColumn rejects a single email column together with fanned-out email columns.
+func validateEmailColumn(column string, emailColumns []string) error {
+	if strings.TrimSpace(column) != "" && len(emailColumns) > 0 {
+		return fmt.Errorf("email column %q cannot be combined with email columns %q", column, emailColumns)
+	}
+	return nil
+}
+
+// readLocalEmailItems reads emailColumns, or the single column when there are none.
+func readLocalEmailItems(r io.Reader, column string, emailColumns, passthrough []string, opts localio.CSVReadOptions) ([]localio.EmailItem, error) {
+	if len(emailColumns) > 0 {
+		return localio.ReadEmailItemsCSVWithOptions(r, emailColumns, passthrough, opts)
+	}
+	return localio.ReadColumnItemsCSVWithOptions(r, column, passthrough, opts)
+}
+
+func splitEmailItems(items []localio.EmailItem) ([]string, inputSourceRows) {
+	emails := make([]string, len(items))
+	rows := make(inputSourceRows, len(items))
+	for i, item := range items {
+		emails[i] = item.Email
+		rows[i] = item.SourceRow
+	}
+	return emails, rows
+}
+
+func (s inputSourceRows) extraColumns() []string {
+	if s == nil {
+		return nil
+	}
+	return []string{pipeline.SourceRowColumn}
+}
+
+// tagRows tags rows aligned with the input emails (as in incrementalPlan.rows).
+func (s inputSourceRows) tagRows(rows []pipeline.Row) {
+	if s == nil {
+		return
+	}
+	for i := range rows {
+		if i >= len(s) {
+			return
+		}
+		rows[i] = rows[i].WithExtra(pipeline.SourceRowColumn, strconv.Itoa(s[i]))
+	}
+}
+
+// streamTagger returns a func that tags a deduplicated row with the first input row its email was
+// read from. Stream output has one record per distinct email, so later occurrences are not listed.
+func (s inputSourceRows) streamTagger(emails []string) func(pipeline.Row) pipeline.Row {
+	if s == nil {
+		return func(row pipeline.Row) pipeline.Row { return row }
+	}
+	first := make(map[string]int, len(emails))
+	for i, email := range emails {
+		key := emailKey(email)
+		if _, ok := first[key]; !ok && i < len(s) {
+			first[key] = s[i]
+		}
+	}
+	return func(row pipeline.Row) pipeline.Row {
+		if idx, ok := first[emailKey(row.Email)]; ok {
+			return row.WithExtra(pipeline.SourceRowColumn, strconv.Itoa(idx))
+		}
+		return row
+	}
+}
@@ -0,0 +1,67 @@
+package app_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+func TestRunFoundry_ReadsInputAndProbesOutputModeAtStartup(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "auto",
+	}, pipeline.Options{}, testEnricher{}); err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+
+	readTablePath := "/api/v2/datasets/" + testInputRID + "/readTable"
+	probePath := "/stream-proxy/api/streams/" + testOutputRID + "/branches/master/records"
+	var readTable, probe bool
+	for _, call := range mock.Calls()[:3] {
+		readTable = readTable || call.Path == readTablePath
+		probe = probe || call.Path == probePath
+	}
+	if !readTable || !probe {
+		t.Fatalf("expected input readTable and output probe among the startup calls, got %#v", mock.Calls())
+	}
+	if len(mock.Uploads()) != 1 {
+		t.Fatalf("expected 1 upload, got %d", len(mock.Uploads()))
+	}
+}
+
+func TestRunFoundry_StartupErrorsSurface(t *testing.T) {
+	t.Parallel()
+
+	t.Run("input read", func(t *testing.T) {
+		t.Parallel()
+		_, env := newMockFoundryEnv(t, "")
+		_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+			InputAlias:      "input",
+			OutputAlias:     "output",
+			OutputWriteMode: "auto",
+		}, pipeline.Options{}, testEnricher{})
+		if err == nil || !strings.Contains(err.Error(), "DatasetViewNotFound") {
+			t.Fatalf("expected the input read error, got %v", err)
+		}
+	})
+
+	t.Run("output mode", func(t *testing.T) {
+		t.Parallel()
+		_, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+		_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+			InputAlias:      "input",
+			OutputAlias:     "output",
+			OutputWriteMode: "bogus",
+		}, pipeline.Options{}, testEnricher{})
+		if err == nil || !strings.Contains(err.Error(), "invalid output write mode") {
+			t.Fatalf("expected the output mode error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/redact"
+)
+
+// RunStats collects run metrics where other goroutines, such as the keepalive stats function, can
+// read them while a run is in progress. It is safe for concurrent use; the zero value is ready, and
+// a nil *RunStats records nothing. Counters accumulate across the runs that share it.
+type RunStats struct {
+	mu            sync.Mutex
+	running       bool
+	runs          int
+	rowsProcessed int
+	errors        int
+	last          *RunStatsSummary
+}
+
+// RunStatsSnapshot is the JSON view of RunStats. RowsProcessed and Errors count the rows enriched
+// so far, in the run in progress included.
+type RunStatsSnapshot struct {
+	Running       bool             `json:"running"`
+	Runs          int              `json:"runs"`
+	RowsProcessed int              `json:"rows_processed"`
+	Errors        int              `json:"errors"`
+	LastRun       *RunStatsSummary `json:"last_run"`
+}
+
+// RunStatsSummary summarizes the last finished run, as the run summary line does.
+type RunStatsSummary struct {
+	RunID            string    `json:"run_id"`
+	OutputMode       string    `json:"output_mode"`
+	Enriched         int       `json:"enriched"`
+	OK               int       `json:"ok"`
+	Errors           int       `json:"errors"`
+	RowsWritten      int       `json:"rows_written"`
+	RecordsPublished int       `json:"records_published"`
+	UpToDate         bool      `json:"up_to_date"`
+	DurationMS       int64     `json:"duration_ms"`
+	FinishedAt       time.Time `json:"finished_at"`
+	// Error is the run's redacted error, empty on success.
+	Error string `json:"error,omitempty"`
+}
+
+// Snapshot returns the current counters and last run summary.
+func (s *RunStats) Snapshot() RunStatsSnapshot {
+	if s == nil {
+		return RunStatsSnapshot{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := RunStatsSnapshot{Running: s.running, Runs: s.runs, RowsProcessed: s.rowsProcessed, Errors: s.errors}
+	if s.last != nil {
+		last := *s.last
+		snap.LastRun = &last
+	}
+	return snap
+}
+
+func (s *RunStats) start() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = true
+}
+
+// rowCallback returns a row callback counting each enriched row, or nil for a nil s.
+func (s *RunStats) rowCallback() func(pipeline.Row) error {
+	if s == nil {
+		return nil
+	}
+	return func(row pipeline.Row) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.rowsProcessed++
+		if !strings.EqualFold(strings.TrimSpace(row.Status), "ok") {
+			s.errors++
+		}
+		return nil
+	}
+}
+
+func (s *RunStats) finish(res RunResult, err error, at time.Time) {
+	if s == nil {
+		return
+	}
+	summary := &RunStatsSummary{
+		RunID:            res.RunID,
+		OutputMode:       res.OutputMode,
+		Enriched:         res.Metrics.Enriched,
+		OK:               res.Metrics.OK,
+		Errors:           res.Metrics.Errors,
+		RowsWritten:      res.RowsWritten,
+		RecordsPublished: res.RecordsPublished,
+		UpToDate:         res.UpToDate,
+		DurationMS:       res.Duration.Milliseconds(),
+		FinishedAt:       at.UTC(),
+	}
+	if err != nil {
+		summary.Error = redact.Secrets(err.Error())
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = false
+	s.runs++
+	s.last = summary
+}
@@ -0,0 +1,192 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/enrich"
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/mockfoundry"
+	foundryio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/foundry"
+)
+
+func TestReadExistingStreamRows_KeepsNewestAtCapAndWarns(t *testing.T) {
+	t.Parallel()
+
+	const streamRID = "ri.foundry.main.dataset.33333333-3333-3333-3333-333333333333"
+	mock := mockfoundry.New(t.TempDir(), t.TempDir())
+	mock.CreateStream(streamRID)
+	ts := httptest.NewServer(mock.Handler())
+	t.Cleanup(ts.Close)
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("new foundry client: %v", err)
+	}
+	backend := foundryio.NewLegacyStreamProxyBackend(client)
+	ref := foundry.DatasetRef{RID: streamRID, Branch: "master"}
+
+	ctx := context.Background()
+	for _, email := range []string{"alice@example.com", "bob@corp.test", "carol@new.test"} {
+		if err := backend.PublishRecord(ctx, ref, map[string]any{"email": email, "status": "ok"}); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	var logs bytes.Buffer
+	logger := log.New(&logs, "", 0)
+	warn := &warningCollector{logf: logger.Printf}
+	rows, err := readExistingStreamRows(ctx, backend, ref, 2, notFoundRetry{}, pipeline.StreamMeta{}, logger, "run-1", warn)
+	if err != nil {
+		t.Fatalf("readExistingStreamRows: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 cached rows under the cap, got %d: %v", len(rows), rows)
+	}
+	if _, ok := rows["alice@example.com"]; ok {
+		t.Fatalf("expected the oldest record not to be cached")
+	}
+	if _, ok := rows["carol@new.test"]; !ok {
+		t.Fatalf("expected the newest record to be cached, got %v", rows)
+	}
+	if !strings.Contains(logs.String(), "warning: incremental: kept only the newest 2 records of the prior stream snapshot") {
+		t.Fatalf("expected a truncation warning, got %q", logs.String())
+	}
+	if got := warn.warnings(); len(got) != 1 || got[0].Code != WarningStreamCacheTruncated {
+		t.Fatalf("expected one %s warning, got %v", WarningStreamCacheTruncated, got)
+	}
+}
+
+func TestReadExistingStreamRows_RetriesNotFound(t *testing.T) {
+	t.Parallel()
+
+	const streamRID = "ri.foundry.main.dataset.34343434-3434-3434-3434-343434343434"
+	mock := mockfoundry.New(t.TempDir(), t.TempDir())
+	mock.CreateStream(streamRID)
+	// The first read 404s as if the stream's records were not visible yet.
+	var reads atomic.Int32
+	base := mock.Handler()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/records") && reads.Add(1) == 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errorCode":"NOT_FOUND","errorName":"StreamNotFound"}`))
+			return
+		}
+		base.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("new foundry client: %v", err)
+	}
+	backend := foundryio.NewLegacyStreamProxyBackend(client)
+	ref := foundry.DatasetRef{RID: streamRID, Branch: "master"}
+	ctx := context.Background()
+	if err := backend.PublishRecord(ctx, ref, map[string]any{"email": "alice@example.com", "status": "ok"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	var logs bytes.Buffer
+	logger := log.New(&logs, "", 0)
+	warn := &warningCollector{logf: logger.Printf}
+	rows, err := readExistingStreamRows(ctx, backend, ref, 0, notFoundRetry{retries: 2, backoff: time.Millisecond}, pipeline.StreamMeta{}, logger, "run-1", warn)
+	if err != nil {
+		t.Fatalf("readExistingStreamRows: %v", err)
+	}
+	if _, ok := rows["alice@example.com"]; !ok || reads.Load() != 2 {
+		t.Fatalf("expected the not-found read to be retried into the cache, got rows=%v reads=%d", rows, reads.Load())
+	}
+	if !strings.Contains(logs.String(), "prior stream "+streamRID+"@master not found; re-checking") {
+		t.Fatalf("expected a re-check log line, got %q", logs.String())
+	}
+}
+
+const streamRunOutputRID = "ri.foundry.main.dataset.35353535-3535-3535-3535-353535353535"
+
+// newStreamRunEnv starts a mockfoundry server seeded with inputCSV for the "input" alias and an
+// empty stream for the "output" alias, and returns it together with an env wired to both.
+func newStreamRunEnv(t *testing.T, inputCSV string) (*mockfoundry.Server, foundry.Env) {
+	t.Helper()
+
+	const inputRID = "ri.foundry.main.dataset.11111111-1111-1111-1111-111111111111"
+	inputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, inputRID+".csv"), []byte(inputCSV), 0644); err != nil {
+		t.Fatalf("write input csv: %v", err)
+	}
+	mock := mockfoundry.New(inputDir, t.TempDir())
+	mock.CreateStream(streamRunOutputRID)
+	ts := httptest.NewServer(mock.Handler())
+	t.Cleanup(ts.Close)
+
+	return mock, foundry.Env{
+		Services: foundry.Services{
+			APIGateway:  ts.URL + "/api",
+			StreamProxy: ts.URL + "/stream-proxy/api",
+		},
+		Token: "dummy-token",
+		Aliases: map[string]foundry.DatasetRef{
+			"input":  {RID: inputRID, Branch: "master"},
+			"output": {RID: streamRunOutputRID, Branch: "master"},
+		},
+	}
+}
+
+// callCountEnricher counts Enrich calls per email.
+type callCountEnricher struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (c *callCountEnricher) Enrich(_ context.Context, email string) (enrich.Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.calls == nil {
+		c.calls = make(map[string]int)
+	}
+	c.calls[email]++
+	return enrich.Result{Company: "Example"}, nil
+}
+
+func TestRunFoundry_StreamCacheMaxRecordsReEnrichesOlderRecords(t *testing.T) {
+	t.Parallel()
+
+	_, env := newStreamRunEnv(t, "email\nalice@example.com\nbob@corp.test\ncarol@new.test\n")
+	fopts := FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "stream",
+	}
+	enricher := &callCountEnricher{}
+	if _, err := RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{Workers: 1}, enricher); err != nil {
+		t.Fatalf("first run failed: %v", err)
+	}
+
+	fopts.StreamCacheMaxRecords = 1
+	res, err := RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{Workers: 1}, enricher)
+	if err != nil {
+		t.Fatalf("capped run failed: %v", err)
+	}
+	if res.Plan.CachedRows != 1 || res.Plan.UniqueEmailsToEnrich != 2 {
+		t.Fatalf("expected 1 cached row and 2 emails to re-enrich, got %+v", res.Plan)
+	}
+	// One worker publishes in input order, so carol's record is the newest and the only one kept.
+	want := map[string]int{"alice@example.com": 2, "bob@corp.test": 2, "carol@new.test": 1}
+	enricher.mu.Lock()
+	defer enricher.mu.Unlock()
+	for email, n := range want {
+		if got := enricher.calls[email]; got != n {
+			t.Fatalf("expected %s to be enriched %d times, got %d", email, n, got)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+func TestRunFoundry_StreamDeliveryWithLostPublishAck(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		delivery    string
+		wantRecords int
+	}{
+		// The retried publish is stored again.
+		{delivery: "at-least-once", wantRecords: 3},
+		// The retry carries the same idempotency key and is acknowledged as a duplicate.
+		{delivery: "exactly-once", wantRecords: 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.delivery, func(t *testing.T) {
+			t.Parallel()
+
+			mock, env := newMockFoundryEnv(t, "email\nalice@example.com\nbob@corp.test\n")
+			mock.CreateStream(testOutputRID)
+			mock.LoseNextPublishAcks(1)
+			if _, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+				InputAlias:      "input",
+				OutputAlias:     "output",
+				OutputWriteMode: "stream",
+				StreamDelivery:  tc.delivery,
+			}, pipeline.Options{Workers: 1}, testEnricher{}); err != nil {
+				t.Fatalf("RunFoundryWithOptions failed: %v", err)
+			}
+
+			recs := mock.StreamRecords(testOutputRID, "master")
+			if len(recs) != tc.wantRecords {
+				t.Fatalf("expected %d stream records, got %d: %v", tc.wantRecords, len(recs), recs)
+			}
+		})
+	}
+}
+
+func TestRunFoundryWithOptions_InvalidStreamDelivery(t *testing.T) {
+	t.Parallel()
+
+	_, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "stream",
+		StreamDelivery:  "at-most-once",
+	}, pipeline.Options{}, testEnricher{})
+	if err == nil {
+		t.Fatalf("expected invalid stream delivery to fail")
+	}
+}
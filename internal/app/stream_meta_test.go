@@ -0,0 +1,106 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+func TestRunFoundry_StreamMetaPrefixNamespacesMetadataAndKeepsDedup(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email\nalice@example.com\nbob@corp.test\n")
+	mock.CreateStream(testOutputRID)
+	fopts := app.FoundryOptions{
+		InputAlias:         "input",
+		OutputAlias:        "output",
+		OutputWriteMode:    "stream",
+		StreamMetaPrefix:   "_meta_",
+		VerifyStreamWrites: true,
+	}
+	enricher := &countingEnricher{}
+	first, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{Workers: 1}, enricher)
+	if err != nil {
+		t.Fatalf("first run failed: %v", err)
+	}
+
+	recs := mock.StreamRecords(testOutputRID, "master")
+	if len(recs) != 2 {
+		t.Fatalf("expected 2 stream records, got %d: %v", len(recs), recs)
+	}
+	for _, rec := range recs {
+		if rec["_meta_run_id"] != first.RunID {
+			t.Fatalf("expected _meta_run_id=%q, got %v", first.RunID, rec)
+		}
+		if s, _ := rec["_meta_written_at"].(string); s == "" {
+			t.Fatalf("expected _meta_written_at to be set, got %v", rec)
+		}
+		for _, key := range []string{"run_id", "written_at"} {
+			if _, ok := rec[key]; ok {
+				t.Fatalf("expected no unprefixed %s field, got %v", key, rec)
+			}
+		}
+	}
+
+	// The second run reads the prefixed records back as its cache and has nothing to enrich.
+	second, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{Workers: 1}, enricher)
+	if err != nil {
+		t.Fatalf("second run failed: %v", err)
+	}
+	if !second.UpToDate {
+		t.Fatalf("expected the second run to be up-to-date, got %+v", second)
+	}
+	for _, email := range []string{"alice@example.com", "bob@corp.test"} {
+		if got := enricher.count(email); got != 1 {
+			t.Fatalf("expected %s to be enriched once across both runs, got %d", email, got)
+		}
+	}
+	if got := len(mock.StreamRecords(testOutputRID, "master")); got != 2 {
+		t.Fatalf("expected no new stream records, got %d", got)
+	}
+}
+
+func TestRunFoundryWithOptions_InvalidStreamMetaPrefix(t *testing.T) {
+	t.Parallel()
+
+	_, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	_, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:       "input",
+		OutputAlias:      "output",
+		OutputWriteMode:  "stream",
+		StreamMetaPrefix: "meta.",
+	}, pipeline.Options{}, testEnricher{})
+	if err == nil {
+		t.Fatalf("expected invalid stream meta prefix to fail")
+	}
+}
+
+func TestRunFoundry_RunIDOverrideStampsStreamRecords(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email\nalice@example.com\nbob@corp.test\n")
+	mock.CreateStream(testOutputRID)
+	res, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "stream",
+		RunID:           " nightly-2026-10-16 ",
+	}, pipeline.Options{Workers: 1}, testEnricher{})
+	if err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+	if res.RunID != "nightly-2026-10-16" {
+		t.Fatalf("expected RunID %q, got %q", "nightly-2026-10-16", res.RunID)
+	}
+	recs := mock.StreamRecords(testOutputRID, "master")
+	if len(recs) != 2 {
+		t.Fatalf("expected 2 stream records, got %d: %v", len(recs), recs)
+	}
+	for _, rec := range recs {
+		if rec["run_id"] != "nightly-2026-10-16" {
+			t.Fatalf("expected run_id=%q, got %v", "nightly-2026-10-16", rec)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+	foundryio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/foundry"
+)
+
+// verifyStreamWrites reads the stream back after a publish and checks that the records stamped with
+// runID (under meta's run id field) and written since this attempt started are present; a fixed
+// run ID is shared with earlier attempts, whose records must not count. A shortfall is polled for up to wait (see
+// foundryio.WaitForRecords), since the stream may not show a record right after its publish. It
+// never fails the run: a failed read-back or a remaining shortfall is recorded as a
+// WarningStreamVerify warning.
+func verifyStreamWrites(
+	ctx context.Context,
+	backend foundryio.StreamBackend,
+	ref foundry.DatasetRef,
+	meta pipeline.StreamMeta,
+	runID string,
+	since time.Time,
+	published int,
+	wait time.Duration,
+	warn *warningCollector,
+) {
+	if published == 0 {
+		return
+	}
+	recs, err := backend.ReadRecords(ctx, ref)
+	if err != nil {
+		warn.warnf(WarningStreamVerify, "stream write verification skipped: read back %s@%s failed: %s", ref.RID, defaultBranch(ref.Branch), err)
+		return
+	}
+	found := countRunRecords(recs, meta, runID, since)
+	if found < published && wait > 0 {
+		// Every missing record adds one to the stream, so wait for the total to grow by the shortfall.
+		recs, err = foundryio.WaitForRecords(ctx, backend, ref, len(recs)+published-found, wait)
+		if recs != nil {
+			found = countRunRecords(recs, meta, runID, since)
+		}
+		if err != nil && !errors.Is(err, foundry.ErrStreamRecordsNotSettled) {
+			warn.warnf(WarningStreamVerify, "stream write verification: waiting for records on %s@%s failed: %s", ref.RID, defaultBranch(ref.Branch), err)
+		}
+	}
+	if found < published {
+		warn.warnf(WarningStreamVerify, "stream write verification: found %d of %d published records for this run on %s@%s", found, published, ref.RID, defaultBranch(ref.Branch))
+		return
+	}
+	warn.logf("stream write verification: found %d of %d published records on %s@%s", found, published, ref.RID, defaultBranch(ref.Branch))
+}
+
+// countRunRecords counts the records stamped with runID under meta's run id field whose written_at
+// field is not before since.
+func countRunRecords(recs []map[string]any, meta pipeline.StreamMeta, runID string, since time.Time) int {
+	n := 0
+	for _, rec := range recs {
+		if strings.TrimSpace(fmt.Sprint(rec[meta.RunIDKey()])) != runID {
+			continue
+		}
+		writtenAt, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(fmt.Sprint(rec[meta.WrittenAtKey()])))
+		if err != nil || writtenAt.Before(since) {
+			continue
+		}
+		n++
+	}
+	return n
+}
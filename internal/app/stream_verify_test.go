@@ -0,0 +1,145 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/mockfoundry"
+	foundryio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/foundry"
+)
+
+func TestVerifyStreamWrites(t *testing.T) {
+	t.Parallel()
+
+	const streamRID = "ri.foundry.main.dataset.33333333-3333-3333-3333-333333333333"
+	cases := []struct {
+		name     string
+		dropped  int
+		wantWarn bool
+	}{
+		{name: "all records present", dropped: 0},
+		{name: "dropped record warns", dropped: 1, wantWarn: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock := mockfoundry.New(t.TempDir(), t.TempDir())
+			mock.CreateStream(streamRID)
+			ts := httptest.NewServer(mock.Handler())
+			t.Cleanup(ts.Close)
+			client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+			if err != nil {
+				t.Fatalf("new foundry client: %v", err)
+			}
+			backend := foundryio.NewLegacyStreamProxyBackend(client)
+			ref := foundry.DatasetRef{RID: streamRID, Branch: "master"}
+
+			ctx := context.Background()
+			since := time.Now()
+			earlier := since.Add(-time.Hour).UTC().Format(time.RFC3339Nano)
+			// Records from an earlier run, or an earlier attempt under the same run id, must not
+			// count towards this attempt.
+			for _, rec := range []map[string]any{
+				{"email": "old@example.com", "run_id": "run-0", "written_at": since.UTC().Format(time.RFC3339Nano)},
+				{"email": "retry@example.com", "run_id": "run-1", "written_at": earlier},
+			} {
+				if err := backend.PublishRecord(ctx, ref, rec); err != nil {
+					t.Fatalf("publish: %v", err)
+				}
+			}
+			mock.DropNextPublishes(tc.dropped)
+			for _, email := range []string{"alice@example.com", "bob@corp.test"} {
+				rec := map[string]any{"email": email, "run_id": "run-1", "written_at": time.Now().UTC().Format(time.RFC3339Nano)}
+				if err := backend.PublishRecord(ctx, ref, rec); err != nil {
+					t.Fatalf("publish: %v", err)
+				}
+			}
+
+			var logs []string
+			verifyStreamWrites(ctx, backend, ref, pipeline.StreamMeta{}, "run-1", since, 2, 0, &warningCollector{logf: func(format string, args ...any) {
+				logs = append(logs, fmt.Sprintf(format, args...))
+			}})
+			if len(logs) != 1 {
+				t.Fatalf("expected one log line, got %q", logs)
+			}
+			if got := strings.HasPrefix(logs[0], "warning:"); got != tc.wantWarn {
+				t.Fatalf("warning=%v, want %v: %q", got, tc.wantWarn, logs[0])
+			}
+			want := "found 2 of 2"
+			if tc.wantWarn {
+				want = "found 1 of 2"
+			}
+			if !strings.Contains(logs[0], want) {
+				t.Fatalf("expected %q in %q", want, logs[0])
+			}
+		})
+	}
+}
+
+func TestVerifyStreamWrites_WaitsForDelayedRecords(t *testing.T) {
+	t.Parallel()
+
+	const streamRID = "ri.foundry.main.dataset.33333333-3333-3333-3333-333333333333"
+	mock := mockfoundry.New(t.TempDir(), t.TempDir())
+	mock.CreateStream(streamRID)
+	ts := httptest.NewServer(mock.Handler())
+	t.Cleanup(ts.Close)
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("new foundry client: %v", err)
+	}
+	backend := foundryio.NewLegacyStreamProxyBackend(client)
+	ref := foundry.DatasetRef{RID: streamRID, Branch: "master"}
+
+	ctx := context.Background()
+	since := time.Now()
+	if err := backend.PublishRecord(ctx, ref, map[string]any{"email": "old@example.com", "run_id": "run-0"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	mock.SetStreamVisibilityDelay(300 * time.Millisecond)
+	for _, email := range []string{"alice@example.com", "bob@corp.test"} {
+		rec := map[string]any{"email": email, "run_id": "run-1", "written_at": time.Now().UTC().Format(time.RFC3339Nano)}
+		if err := backend.PublishRecord(ctx, ref, rec); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	var logs []string
+	verifyStreamWrites(ctx, backend, ref, pipeline.StreamMeta{}, "run-1", since, 2, 5*time.Second, &warningCollector{logf: func(format string, args ...any) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}})
+	if len(logs) != 1 || strings.HasPrefix(logs[0], "warning:") || !strings.Contains(logs[0], "found 2 of 2") {
+		t.Fatalf("expected verification to wait for both records, got %q", logs)
+	}
+}
+
+func TestRunFoundry_VerifyStreamWritesDoesNotFailOnShortfall(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newStreamRunEnv(t, "email\nalice@example.com\nbob@corp.test\n")
+	mock.DropNextPublishes(1)
+	if _, err := RunFoundryWithOptions(context.Background(), env, FoundryOptions{
+		InputAlias:         "input",
+		OutputAlias:        "output",
+		OutputWriteMode:    "stream",
+		VerifyStreamWrites: true,
+	}, pipeline.Options{Workers: 1}, stubEnricher{}); err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+
+	calls := mock.Calls()
+	last := calls[len(calls)-1]
+	if last.Method != "GET" || last.Path != "/stream-proxy/api/streams/"+streamRunOutputRID+"/branches/master/records" {
+		t.Fatalf("expected the run to end with a stream read-back, got %#v", last)
+	}
+	if recs := mock.StreamRecords(streamRunOutputRID, "master"); len(recs) != 1 {
+		t.Fatalf("expected the dropped publish to leave 1 stream record, got %d", len(recs))
+	}
+}
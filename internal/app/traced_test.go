@@ -0,0 +1,38 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/enrich"
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+)
+
+type stubEnricher struct{}
+
+func (stubEnricher) Enrich(context.Context, string) (enrich.Result, error) {
+	return enrich.Result{Company: "Example"}, nil
+}
+
+func TestTracedEnricher_LogsOnlyContextColumnNames(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	traced := newTracedEnricher(stubEnricher{}, log.New(&buf, "", 0), "run-1", pipeline.Options{})
+	if _, err := traced.EnrichRecord(context.Background(), "alice@example.com", map[string]string{"last_name": "Smith", "first_name": "Alice"}); err != nil {
+		t.Fatalf("EnrichRecord: %v", err)
+	}
+
+	logged := buf.String()
+	if !strings.Contains(logged, `"record_columns":["first_name","last_name"]`) {
+		t.Fatalf("expected the context column names in the request log, got:\n%s", logged)
+	}
+	for _, v := range []string{"Alice", "Smith"} {
+		if strings.Contains(logged, v) {
+			t.Fatalf("expected context value %q not to be logged, got:\n%s", v, logged)
+		}
+	}
+}
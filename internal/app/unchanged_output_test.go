@@ -0,0 +1,58 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+)
+
+func TestRunFoundry_UnchangedOutputSkipsCommit(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email\nalice@example.com\nbob@corp.test\n")
+	fopts := app.FoundryOptions{InputAlias: "input", OutputAlias: "output", OutputWriteMode: "dataset"}
+
+	first, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{}, testEnricher{})
+	if err != nil {
+		t.Fatalf("first run failed: %v", err)
+	}
+	if first.UpToDate || len(mock.Uploads()) != 1 {
+		t.Fatalf("expected the first run to upload once, got upToDate=%v uploads=%d", first.UpToDate, len(mock.Uploads()))
+	}
+
+	second, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{}, testEnricher{})
+	if err != nil {
+		t.Fatalf("second run failed: %v", err)
+	}
+	if !second.UpToDate || second.RowsWritten != 0 {
+		t.Fatalf("expected the second run to skip the write, got upToDate=%v rowsWritten=%d", second.UpToDate, second.RowsWritten)
+	}
+	if got := len(mock.Uploads()); got != 1 {
+		t.Fatalf("expected no upload on the second run, got %d uploads total", got)
+	}
+}
+
+func TestRunFoundry_ChangedOutputIsCommitted(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	fopts := app.FoundryOptions{InputAlias: "input", OutputAlias: "output", OutputWriteMode: "dataset"}
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{}, testEnricher{}); err != nil {
+		t.Fatalf("first run failed: %v", err)
+	}
+
+	// Every row is cached, but the lean schema changes the bytes, so the output is still written.
+	fopts.OmitAuditColumns = true
+	res, err := app.RunFoundryWithOptions(context.Background(), env, fopts, pipeline.Options{}, testEnricher{})
+	if err != nil {
+		t.Fatalf("second run failed: %v", err)
+	}
+	if res.UpToDate || res.RowsWritten != 1 {
+		t.Fatalf("expected the second run to write, got upToDate=%v rowsWritten=%d", res.UpToDate, res.RowsWritten)
+	}
+	if got := len(mock.Uploads()); got != 2 {
+		t.Fatalf("expected 2 uploads, got %d", got)
+	}
+}
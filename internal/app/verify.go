@@ -0,0 +1,109 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+	foundryio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/foundry"
+	localio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/local"
+)
+
+// VerifyOptions configures VerifyFoundry.
+type VerifyOptions struct {
+	InputAlias  string
+	OutputAlias string
+
+	// EmailColumns, EmailColumn, and CSVReadOptions read the input as in FoundryOptions.
+	EmailColumns   []string
+	EmailColumn    string
+	CSVReadOptions localio.CSVReadOptions
+}
+
+// VerifyReport compares the current input against a committed dataset output.
+type VerifyReport struct {
+	InputRows int
+	// OKRows counts input rows the output has an ok row for.
+	OKRows int
+	// Missing lists distinct input emails with no output row, in input order.
+	Missing []string
+	// Errored holds the output row of each distinct input email whose best output row is not ok.
+	Errored []pipeline.Row
+}
+
+// Passed reports whether every input email has an ok output row.
+func (r VerifyReport) Passed() bool {
+	return len(r.Missing) == 0 && len(r.Errored) == 0
+}
+
+// VerifyFoundry checks that the dataset output has an ok row for every current input email. It
+// reads the input and output and builds the incremental plan a run would, but never enriches or
+// writes: the plan's pending emails are exactly the ones a run would have to enrich.
+func VerifyFoundry(ctx context.Context, env foundry.Env, vopts VerifyOptions) (VerifyReport, error) {
+	inputRef, ok := env.Aliases[vopts.InputAlias]
+	if !ok {
+		return VerifyReport{}, invalidConfig(fmt.Errorf("missing alias %q in RESOURCE_ALIAS_MAP", vopts.InputAlias))
+	}
+	outputRef, ok := env.Aliases[vopts.OutputAlias]
+	if !ok {
+		return VerifyReport{}, invalidConfig(fmt.Errorf("missing alias %q in RESOURCE_ALIAS_MAP", vopts.OutputAlias))
+	}
+
+	client, err := foundry.NewClient(env.Services.APIGateway, env.Services.StreamProxy, env.Token, env.DefaultCAPath)
+	if err != nil {
+		return VerifyReport{}, err
+	}
+	if env.TokenPath != "" {
+		client = client.WithTokenFile(env.TokenPath, foundry.DefaultTokenRefreshInterval)
+	}
+	if inputRef, err = resolveDatasetRef(ctx, client, vopts.InputAlias, inputRef); err != nil {
+		return VerifyReport{}, err
+	}
+	if outputRef, err = resolveDatasetRef(ctx, client, vopts.OutputAlias, outputRef); err != nil {
+		return VerifyReport{}, err
+	}
+
+	if err := validateEmailColumn(vopts.EmailColumn, vopts.EmailColumns); err != nil {
+		return VerifyReport{}, invalidConfig(err)
+	}
+	var emails []string
+	if len(vopts.EmailColumns) > 0 {
+		items, err := foundryio.ReadInputEmailItemsWithPolicy(ctx, client, inputRef, vopts.EmailColumns, nil, vopts.CSVReadOptions, foundryio.ReadRetryPolicy{})
+		if err != nil {
+			return VerifyReport{}, err
+		}
+		emails, _ = splitEmailItems(items)
+	} else {
+		emails, err = foundryio.ReadInputColumnWithPolicy(ctx, client, inputRef, vopts.EmailColumn, vopts.CSVReadOptions, foundryio.ReadRetryPolicy{})
+		if err != nil {
+			return VerifyReport{}, err
+		}
+	}
+
+	// Unlike a run, an unreadable output is an error rather than an empty cache.
+	existing := map[string]pipeline.Row{}
+	b, err := client.ReadTableCSV(ctx, outputRef.RID, defaultBranch(outputRef.Branch))
+	if err != nil && !isNotFoundError(err) {
+		return VerifyReport{}, fmt.Errorf("read output %s@%s: %w", outputRef.RID, defaultBranch(outputRef.Branch), err)
+	}
+	if err == nil {
+		existing, err = existingRowsByEmail(b, vopts.CSVReadOptions.CSVLimits)
+		if err != nil {
+			return VerifyReport{}, err
+		}
+	}
+
+	plan := buildIncrementalPlan(emails, existing, 0)
+	report := VerifyReport{InputRows: len(emails), OKRows: plan.cachedRows}
+	for _, email := range plan.pendingEmails {
+		row, ok := existing[emailKey(email)]
+		if !ok {
+			report.Missing = append(report.Missing, strings.TrimSpace(email))
+			continue
+		}
+		report.Errored = append(report.Errored, row)
+	}
+	return report, nil
+}
@@ -0,0 +1,72 @@
+package app_test
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+)
+
+func TestVerifyFoundry_ReportsMissingAndErroredEmails(t *testing.T) {
+	t.Parallel()
+
+	mock, env := newMockFoundryEnv(t, "email\nalice@example.com\nbob@corp.test\ncarol@new.test\nalice@example.com\ndave@z.test\n")
+	client, err := foundry.NewClient(env.Services.APIGateway, env.Services.StreamProxy, env.Token, "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	commitOutputVersion(t, client, []pipeline.Row{
+		{Email: "alice@example.com", Company: "Example", Status: "ok"},
+		{Email: "bob@corp.test", Status: "error", Error: "lookup failed"},
+		{Email: "erin@stale.test", Company: "Stale", Status: "ok"},
+	})
+	callsBefore := len(mock.Calls())
+
+	report, err := app.VerifyFoundry(context.Background(), env, app.VerifyOptions{InputAlias: "input", OutputAlias: "output"})
+	if err != nil {
+		t.Fatalf("VerifyFoundry failed: %v", err)
+	}
+
+	if report.Passed() {
+		t.Fatalf("expected verification to fail for a partial output")
+	}
+	if report.InputRows != 5 || report.OKRows != 2 {
+		t.Fatalf("expected 5 input rows with 2 ok, got %+v", report)
+	}
+	if want := []string{"carol@new.test", "dave@z.test"}; !slices.Equal(report.Missing, want) {
+		t.Fatalf("unexpected missing emails:\nwant=%v\ngot=%v", want, report.Missing)
+	}
+	if len(report.Errored) != 1 || report.Errored[0].Email != "bob@corp.test" || report.Errored[0].Status != "error" {
+		t.Fatalf("expected bob to be reported as errored, got %+v", report.Errored)
+	}
+	for _, c := range mock.Calls()[callsBefore:] {
+		if c.Method != http.MethodGet {
+			t.Fatalf("expected verify to only read, got %s %s", c.Method, c.Path)
+		}
+	}
+}
+
+func TestVerifyFoundry_PassesWhenEveryEmailIsOK(t *testing.T) {
+	t.Parallel()
+
+	_, env := newMockFoundryEnv(t, "email\nalice@example.com\nbob@corp.test\n")
+	if _, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:      "input",
+		OutputAlias:     "output",
+		OutputWriteMode: "dataset",
+	}, pipeline.Options{}, testEnricher{}); err != nil {
+		t.Fatalf("RunFoundryWithOptions failed: %v", err)
+	}
+
+	report, err := app.VerifyFoundry(context.Background(), env, app.VerifyOptions{InputAlias: "input", OutputAlias: "output"})
+	if err != nil {
+		t.Fatalf("VerifyFoundry failed: %v", err)
+	}
+	if !report.Passed() || report.OKRows != 2 {
+		t.Fatalf("expected verification to pass, got %+v", report)
+	}
+}
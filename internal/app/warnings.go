@@ -0,0 +1,79 @@
+package app
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Warning codes recorded in RunResult.Warnings.
+const (
+	// WarningEmptyPriorOutput: the prior dataset output exists but holds no rows, so nothing was
+	// cached.
+	WarningEmptyPriorOutput = "empty_prior_output"
+	// WarningStreamCacheTruncated: reading the prior stream stopped at the stream cache cap.
+	WarningStreamCacheTruncated = "stream_cache_truncated"
+	// WarningStreamVerify: stream write verification failed or found fewer records than published.
+	WarningStreamVerify = "stream_verify"
+	// WarningOpenTransactionCleanup: cleaning up stale OPEN output transactions failed or hit its
+	// per-run cap.
+	WarningOpenTransactionCleanup = "open_transaction_cleanup"
+	// WarningOutputTag: tagging the committed output transaction with the run id failed or was not
+	// possible.
+	WarningOutputTag = "output_tag"
+	// WarningSchemaChange: the prior dataset output's header differs from this run's output header.
+	WarningSchemaChange = "schema_change"
+	// WarningStreamProbeSkipped: the stream backend does not implement foundryio.PublishProber, so
+	// the stream write-permission check was skipped.
+	WarningStreamProbeSkipped = "stream_probe_skipped"
+	// WarningCheckpoint: committing a --commit-every checkpoint failed; its rows were carried into
+	// the next checkpoint or the final write.
+	WarningCheckpoint = "checkpoint"
+	// WarningFoundryClient: the Foundry client noticed a response it could not fully use, such as a
+	// stream records page naming itself as the next page.
+	WarningFoundryClient = "foundry_client"
+)
+
+// Warning is a non-fatal condition noticed during a run. Code groups warnings of one kind; Message
+// is the text that was logged.
+type Warning struct {
+	Code    string
+	Message string
+}
+
+// warningCollector logs each warning with a "warning: " prefix through logf and keeps it for
+// RunResult.Warnings. It is safe for concurrent use.
+type warningCollector struct {
+	logf func(format string, args ...any)
+
+	mu   sync.Mutex
+	list []Warning
+}
+
+func (w *warningCollector) warnf(code, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	w.mu.Lock()
+	w.list = append(w.list, Warning{Code: code, Message: msg})
+	w.mu.Unlock()
+	if w.logf != nil {
+		w.logf("warning: %s", msg)
+	}
+}
+
+// warnings returns a copy of the warnings recorded so far, in order.
+func (w *warningCollector) warnings() []Warning {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.list) == 0 {
+		return nil
+	}
+	return append([]Warning(nil), w.list...)
+}
+
+// WarningCounts returns the number of warnings recorded per code.
+func (r RunResult) WarningCounts() map[string]int {
+	counts := make(map[string]int, len(r.Warnings))
+	for _, w := range r.Warnings {
+		counts[w.Code]++
+	}
+	return counts
+}
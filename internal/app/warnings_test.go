@@ -0,0 +1,42 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/app"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+)
+
+func TestRunFoundry_RecordsWarningsInResult(t *testing.T) {
+	t.Parallel()
+
+	_, env := newMockFoundryEnv(t, "email\nalice@example.com\n")
+	client, err := foundry.NewClient(env.Services.APIGateway, env.Services.StreamProxy, env.Token, "")
+	if err != nil {
+		t.Fatalf("new foundry client: %v", err)
+	}
+	// A prior output with a header but no rows, and without the raw_response column this run adds.
+	commitOutputVersion(t, client, nil)
+
+	res, err := app.RunFoundryWithOptions(context.Background(), env, app.FoundryOptions{
+		InputAlias:         "input",
+		OutputAlias:        "output",
+		OutputWriteMode:    "dataset",
+		CaptureRawResponse: true,
+	}, pipeline.Options{}, testEnricher{})
+	if err != nil {
+		t.Fatalf("RunFoundryWithOptions: %v", err)
+	}
+	if len(res.Warnings) != 2 {
+		t.Fatalf("expected 2 warnings, got %+v", res.Warnings)
+	}
+	counts := res.WarningCounts()
+	if counts[app.WarningSchemaChange] != 1 || counts[app.WarningEmptyPriorOutput] != 1 {
+		t.Fatalf("expected one schema change and one empty prior output warning, got %v", counts)
+	}
+	if res.Metrics.OK != 1 {
+		t.Fatalf("expected the run to enrich despite the warnings, got %+v", res.Metrics)
+	}
+}
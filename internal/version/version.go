@@ -2,3 +2,14 @@ package version
 
 // Current is the canonical module release version (without a leading "v").
 const Current = "0.0.38"
+
+// Version is the version reported at runtime, for example in the User-Agent. It defaults to Current
+// and can be overridden at build time:
+//
+//	go build -ldflags "-X github.com/palantir/palantir-compute-module-pipeline-search/internal/version.Version=0.0.38-dev" ./cmd/enricher
+var Version = Current
+
+// UserAgent returns the User-Agent sent on outbound Foundry and compute-module runtime requests.
+func UserAgent() string {
+	return "palantir-compute-module-pipeline/" + Version + " (enricher)"
+}
@@ -2,29 +2,54 @@ package foundry
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"maps"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/version"
 )
 
+// UserAgent is sent on every request made by Client. It identifies this module and its build
+// version (see internal/version) and may be overridden by embedding programs.
+var UserAgent = version.UserAgent()
+
 // Client is a minimal HTTP client for the dataset endpoints used by this module.
 //
 // Note: This is intentionally minimal to support local harness + smoke tests.
 type Client struct {
 	apiBaseURL    *url.URL
 	streamBaseURL *url.URL
-	token         string
+	auth          *tokenSource
 	http          *http.Client
+
+	// unmarshalRecords, when set, replaces the stream records envelope heuristics.
+	unmarshalRecords StreamRecordUnmarshaler
+	// logger receives client warnings; nil uses the standard logger.
+	logger *log.Logger
+	// onWarning, when set, receives client warnings instead of logger.
+	onWarning func(msg string)
+	// opTimeouts overrides the overall timeout per operation; see Timeouts.Operations.
+	opTimeouts map[string]time.Duration
+	// allowHTML disables the readTable HTML response check; see WithHTMLResponseCheck.
+	allowHTML bool
+	// lastRequestID is shared with copies; see LastRequestID.
+	lastRequestID *lastRequestID
 }
 
 type branchResponse struct {
@@ -50,14 +75,13 @@ func (c *Client) GetBranchTransactionRID(ctx context.Context, datasetRID, branch
 		url.PathEscape(branch),
 	))
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	req, err := c.newRequest(ctx, "getBranch", http.MethodGet, u.String(), nil)
 	if err != nil {
 		return "", err
 	}
-	req.Header.Set("Authorization", "Bearer "+c.token)
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := c.http.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return "", err
 	}
@@ -80,6 +104,104 @@ func (c *Client) GetBranchTransactionRID(ctx context.Context, datasetRID, branch
 	return strings.TrimSpace(out.TransactionRID), nil
 }
 
+type createBranchRequest struct {
+	Name           string `json:"name"`
+	TransactionRID string `json:"transactionRid,omitempty"`
+}
+
+// CreateBranch creates branch name on the dataset with its head at the committed transaction
+// txnRID, so downstream readers can pin that version by name. An existing branch is not moved: the
+// call fails with a 409 HTTPError.
+func (c *Client) CreateBranch(ctx context.Context, datasetRID, name, txnRID string) error {
+	datasetRID = strings.TrimSpace(datasetRID)
+	name = strings.TrimSpace(name)
+	if datasetRID == "" {
+		return fmt.Errorf("dataset rid is required")
+	}
+	if name == "" {
+		return fmt.Errorf("branch name is required")
+	}
+	b, err := json.Marshal(createBranchRequest{Name: name, TransactionRID: strings.TrimSpace(txnRID)})
+	if err != nil {
+		return err
+	}
+
+	u := c.resolveAPI(fmt.Sprintf("v2/datasets/%s/branches", url.PathEscape(datasetRID)))
+	req, err := c.newRequest(ctx, "createBranch", http.MethodPost, u.String(), bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	rb, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return newHTTPError("createBranch", resp, rb)
+	}
+	return nil
+}
+
+type resourceByPathResponse struct {
+	RID string `json:"rid"`
+}
+
+// ResolveDatasetRID looks up the RID of the dataset at a Foundry path such as
+// "/Org/Project/emails". A path with no resource fails with a 404 HTTPError.
+func (c *Client) ResolveDatasetRID(ctx context.Context, path string) (string, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return "", fmt.Errorf("dataset path is required")
+	}
+
+	u := c.resolveAPI("v2/filesystem/resources/getByPath")
+	q := url.Values{}
+	q.Set("path", path)
+	q.Set("preview", "true")
+	u.RawQuery = q.Encode()
+
+	req, err := c.newRequest(ctx, "getResourceByPath", http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode/100 != 2 {
+		return "", newHTTPError("getResourceByPath", resp, b)
+	}
+
+	var out resourceByPathResponse
+	if err := json.Unmarshal(b, &out); err != nil {
+		return "", fmt.Errorf("parse get resource by path response: %w", err)
+	}
+	rid := strings.TrimSpace(out.RID)
+	if rid == "" {
+		return "", fmt.Errorf("get resource by path response missing rid")
+	}
+	return rid, nil
+}
+
 // NewClient constructs a client for Foundry service base URLs.
 //
 // apiGatewayURL should look like "https://<stack>.palantirfoundry.com/api".
@@ -104,11 +226,178 @@ func NewClient(apiGatewayURL, streamProxyURL, token, defaultCAPath string) (*Cli
 	return &Client{
 		apiBaseURL:    apiBase,
 		streamBaseURL: streamBase,
-		token:         strings.TrimSpace(token),
+		auth:          &tokenSource{token: strings.TrimSpace(token)},
 		http:          hc,
+		lastRequestID: &lastRequestID{},
 	}, nil
 }
 
+// WithTokenFile returns a copy of the client that re-reads its bearer token from path: on first
+// use, every refreshInterval (DefaultTokenRefreshInterval when <= 0), and after a 401 response,
+// in which case the request is retried once with the new token. The token passed to NewClient is
+// used until the file has been read successfully.
+func (c *Client) WithTokenFile(path string, refreshInterval time.Duration) *Client {
+	if refreshInterval <= 0 {
+		refreshInterval = DefaultTokenRefreshInterval
+	}
+	cp := *c
+	cp.auth = &tokenSource{
+		token:    c.auth.current(time.Now()),
+		path:     strings.TrimSpace(path),
+		interval: refreshInterval,
+	}
+	return &cp
+}
+
+// WithResponseCompression returns a copy of the client that does (enabled) or does not advertise
+// gzip for responses. Compression is on by default: Go's transport then sends
+// "Accept-Encoding: gzip" and decompresses transparently, which cuts transfer time for large
+// readTable bodies. That only holds while requests leave Accept-Encoding unset, so this client never
+// sets it. Responses that arrive gzip-encoded anyway (for example from a proxy, or with compression
+// disabled) are decoded by the client as well; see decodeContentEncoding.
+func (c *Client) WithResponseCompression(enabled bool) *Client {
+	cp := *c
+	hc := *c.http
+	if tr, ok := hc.Transport.(*http.Transport); ok {
+		tr = tr.Clone()
+		tr.DisableCompression = !enabled
+		hc.Transport = tr
+	}
+	cp.http = &hc
+	return &cp
+}
+
+// StreamRecordUnmarshaler parses a stream-proxy records response body into records, for stacks
+// whose envelope the built-in heuristics do not understand.
+type StreamRecordUnmarshaler func(body []byte) ([]map[string]any, error)
+
+// WithStreamRecordUnmarshaler returns a copy of the client that parses stream records responses
+// with fn instead of the built-in envelope heuristics. The body is then always read whole; a
+// ReadStreamRecordsLimit cap is applied to fn's result. A nil fn restores the heuristics.
+func (c *Client) WithStreamRecordUnmarshaler(fn StreamRecordUnmarshaler) *Client {
+	cp := *c
+	cp.unmarshalRecords = fn
+	return &cp
+}
+
+// WithHTMLResponseCheck returns a copy of the client that does (enabled, the default) or does not
+// fail readTable responses that are HTML pages with ErrUnexpectedHTML. A response is HTML when its
+// Content-Type is text/html or application/xhtml+xml, or its body opens with "<!DOCTYPE html" or
+// "<html". Disable it only for a stack that serves CSV under an HTML content type.
+func (c *Client) WithHTMLResponseCheck(enabled bool) *Client {
+	cp := *c
+	cp.allowHTML = !enabled
+	return &cp
+}
+
+// WithLogger returns a copy of the client that writes warnings to logger instead of the standard
+// logger.
+func (c *Client) WithLogger(logger *log.Logger) *Client {
+	cp := *c
+	cp.logger = logger
+	return &cp
+}
+
+// WithWarningHandler returns a copy of the client that passes each warning to fn instead of
+// logging it, so a caller can collect them.
+func (c *Client) WithWarningHandler(fn func(msg string)) *Client {
+	cp := *c
+	cp.onWarning = fn
+	return &cp
+}
+
+func (c *Client) warnf(format string, args ...any) {
+	if c.onWarning != nil {
+		c.onWarning(fmt.Sprintf(format, args...))
+		return
+	}
+	if c.logger == nil {
+		log.Printf("warning: "+format, args...)
+		return
+	}
+	c.logger.Printf("warning: "+format, args...)
+}
+
+// Timeouts bounds the phases of a request separately from the overall Timeout, for networks where
+// connecting is slow but responses are fast, or the reverse.
+type Timeouts struct {
+	// Dial bounds establishing the TCP connection, including DNS resolution.
+	Dial time.Duration
+	// TLSHandshake bounds the TLS handshake once connected.
+	TLSHandshake time.Duration
+	// ResponseHeader bounds the wait for response headers after the request is written; zero in
+	// DefaultTimeouts means only Overall applies, since a large readTable may start slowly.
+	ResponseHeader time.Duration
+	// Overall bounds the whole request, including reading the response body.
+	Overall time.Duration
+	// Operations replaces Overall for the named operations, keyed by the operation names HTTPError
+	// reports (for example "commitTransaction", which can take much longer than a read while the
+	// stack materializes a large snapshot). The override is applied as a deadline on the request
+	// context. A nil map keeps the client's current overrides.
+	Operations map[string]time.Duration
+}
+
+// OperationNames lists the operations Timeouts.Operations accepts.
+func OperationNames() []string {
+	return []string{
+		"getBranch",
+		"createBranch",
+		"getResourceByPath",
+		"readTable",
+		"getFileContent",
+		"probeStream",
+		"readStreamRecords",
+		"publishStreamJSONRecord",
+		"probeStreamPublish",
+		"createTransaction",
+		"listTransactions",
+		"uploadFile",
+		"commitTransaction",
+		"abortTransaction",
+	}
+}
+
+// DefaultTimeouts are the timeouts NewClient starts with.
+var DefaultTimeouts = Timeouts{
+	Dial:         30 * time.Second,
+	TLSHandshake: 10 * time.Second,
+	Overall:      60 * time.Second,
+}
+
+// WithTimeouts returns a copy of the client using t's non-zero timeouts; zero fields keep the
+// client's current setting.
+func (c *Client) WithTimeouts(t Timeouts) *Client {
+	cp := *c
+	hc := *c.http
+	if t.Overall > 0 {
+		hc.Timeout = t.Overall
+	}
+	if t.Operations != nil {
+		cp.opTimeouts = maps.Clone(t.Operations)
+	}
+	if tr, ok := hc.Transport.(*http.Transport); ok {
+		tr = tr.Clone()
+		applyTransportTimeouts(tr, t)
+		hc.Transport = tr
+	}
+	cp.http = &hc
+	return &cp
+}
+
+// applyTransportTimeouts sets tr's dial, TLS handshake, and response header timeouts from t's
+// non-zero fields.
+func applyTransportTimeouts(tr *http.Transport, t Timeouts) {
+	if t.Dial > 0 {
+		tr.DialContext = (&net.Dialer{Timeout: t.Dial, KeepAlive: 30 * time.Second}).DialContext
+	}
+	if t.TLSHandshake > 0 {
+		tr.TLSHandshakeTimeout = t.TLSHandshake
+	}
+	if t.ResponseHeader > 0 {
+		tr.ResponseHeaderTimeout = t.ResponseHeader
+	}
+}
+
 func parseBaseURL(raw string, name string) (*url.URL, error) {
 	raw = strings.TrimSpace(raw)
 	if raw == "" {
@@ -133,6 +422,7 @@ func parseBaseURL(raw string, name string) (*url.URL, error) {
 
 func newHTTPClient(defaultCAPath string) (*http.Client, error) {
 	tr := http.DefaultTransport.(*http.Transport).Clone()
+	applyTransportTimeouts(tr, DefaultTimeouts)
 	if strings.TrimSpace(defaultCAPath) != "" {
 		b, err := os.ReadFile(strings.TrimSpace(defaultCAPath))
 		if err != nil {
@@ -146,12 +436,25 @@ func newHTTPClient(defaultCAPath string) (*http.Client, error) {
 	}
 	return &http.Client{
 		Transport: tr,
-		Timeout:   60 * time.Second,
+		Timeout:   DefaultTimeouts.Overall,
 	}, nil
 }
 
 // ReadTableCSV reads the dataset as CSV bytes from the (mock) readTable endpoint.
 func (c *Client) ReadTableCSV(ctx context.Context, datasetRID, branch string) ([]byte, error) {
+	body, err := c.OpenTableCSV(ctx, datasetRID, branch)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = body.Close()
+	}()
+	return io.ReadAll(body)
+}
+
+// OpenTableCSV opens the dataset's readTable CSV body for incremental reading. The caller must close
+// it. Cancelling ctx mid-stream makes further reads fail with the context's error.
+func (c *Client) OpenTableCSV(ctx context.Context, datasetRID, branch string) (io.ReadCloser, error) {
 	branch = strings.TrimSpace(branch)
 	if branch == "" {
 		branch = "master"
@@ -163,7 +466,37 @@ func (c *Client) ReadTableCSV(ctx context.Context, datasetRID, branch string) ([
 	if err != nil {
 		return nil, err
 	}
+	return c.openTableCSV(ctx, datasetRID, branch, txnRID)
+}
+
+// ReadTableCSVAtTransaction reads the dataset as of a specific committed transaction rather than the
+// branch head. An unknown transaction fails with a 404 HTTPError.
+func (c *Client) ReadTableCSVAtTransaction(ctx context.Context, datasetRID, branch, txnRID string) ([]byte, error) {
+	body, err := c.OpenTableCSVAtTransaction(ctx, datasetRID, branch, txnRID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = body.Close()
+	}()
+	return io.ReadAll(body)
+}
 
+// OpenTableCSVAtTransaction is OpenTableCSV pinned to txnRID, as ReadTableCSVAtTransaction reads.
+// The caller must close the body.
+func (c *Client) OpenTableCSVAtTransaction(ctx context.Context, datasetRID, branch, txnRID string) (io.ReadCloser, error) {
+	branch = strings.TrimSpace(branch)
+	if branch == "" {
+		branch = "master"
+	}
+	txnRID = strings.TrimSpace(txnRID)
+	if txnRID == "" {
+		return nil, fmt.Errorf("transaction rid is required")
+	}
+	return c.openTableCSV(ctx, datasetRID, branch, txnRID)
+}
+
+func (c *Client) openTableCSV(ctx context.Context, datasetRID, branch, txnRID string) (io.ReadCloser, error) {
 	q := url.Values{}
 	q.Set("branchName", branch)
 	if strings.TrimSpace(txnRID) != "" {
@@ -175,29 +508,83 @@ func (c *Client) ReadTableCSV(ctx context.Context, datasetRID, branch string) ([
 	u := c.resolveAPI(fmt.Sprintf("v2/datasets/%s/readTable", url.PathEscape(datasetRID)))
 	u.RawQuery = q.Encode()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	req, err := c.newRequest(ctx, "readTable", http.MethodGet, u.String(), nil)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", "Bearer "+c.token)
 	req.Header.Set("Accept", "text/csv")
 
-	resp, err := c.http.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		_ = resp.Body.Close()
-	}()
+	if resp.StatusCode/100 != 2 {
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return nil, newHTTPError("readTable", resp, b)
+	}
+	if !c.allowHTML {
+		if err := rejectHTMLResponse("readTable", resp); err != nil {
+			_ = resp.Body.Close()
+			return nil, err
+		}
+	}
+	return resp.Body, nil
+}
 
-	b, err := io.ReadAll(resp.Body)
+// OpenFileContent opens the raw bytes of filePath in the dataset's view on branch, through the files
+// API rather than readTable, for files that are not tabular CSV. A non-empty txnRID reads the view
+// as of that committed transaction. A missing file fails with a 404 HTTPError. The caller must
+// close the body.
+func (c *Client) OpenFileContent(ctx context.Context, datasetRID, branch, txnRID, filePath string) (io.ReadCloser, error) {
+	branch = strings.TrimSpace(branch)
+	if branch == "" {
+		branch = "master"
+	}
+	q := url.Values{}
+	q.Set("branchName", branch)
+	if txnRID = strings.TrimSpace(txnRID); txnRID != "" {
+		q.Set("endTransactionRid", txnRID)
+	}
+	u := c.resolveAPI(fmt.Sprintf(
+		"v2/datasets/%s/files/%s/content",
+		url.PathEscape(datasetRID),
+		escapeURLPath(filePath),
+	))
+	u.RawQuery = q.Encode()
+
+	req, err := c.newRequest(ctx, "getFileContent", http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/octet-stream")
+
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
 	if resp.StatusCode/100 != 2 {
-		return nil, newHTTPError("readTable", resp, b)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return nil, newHTTPError("getFileContent", resp, b)
 	}
-	return b, nil
+	if !c.allowHTML {
+		if err := rejectHTMLResponse("getFileContent", resp); err != nil {
+			_ = resp.Body.Close()
+			return nil, err
+		}
+	}
+	return resp.Body, nil
 }
 
 // ProbeStream checks whether the given RID is accessible as a stream via the stream-proxy API.
@@ -223,14 +610,13 @@ func (c *Client) ProbeStream(ctx context.Context, streamRID, branch string) (boo
 		url.PathEscape(branch),
 	))
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	req, err := c.newRequest(ctx, "probeStream", http.MethodGet, u.String(), nil)
 	if err != nil {
 		return false, err
 	}
-	req.Header.Set("Authorization", "Bearer "+c.token)
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := c.http.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return false, err
 	}
@@ -250,60 +636,195 @@ func (c *Client) ProbeStream(ctx context.Context, streamRID, branch string) (boo
 	return true, nil
 }
 
-// ReadStreamRecords reads stream records for a stream branch via stream-proxy.
+// ReadStreamRecords reads stream records for a stream branch via stream-proxy, following
+// nextPageToken across pages.
 //
 // Note: this endpoint returns the full record list in this minimal client.
-// In real deployments, streams can be large; callers should treat this as best-effort.
+// In real deployments, streams can be large; callers should treat this as best-effort, or use
+// ReadStreamRecordsLimit to bound memory.
 func (c *Client) ReadStreamRecords(ctx context.Context, streamRID, branch string) ([]map[string]any, error) {
+	recs, _, err := c.ReadStreamRecordsLimit(ctx, streamRID, branch, 0)
+	return recs, err
+}
+
+// maxStreamRecordPages bounds the pages one ReadStreamRecordsLimit call follows, so a stack that
+// never stops returning nextPageToken cannot loop forever.
+const maxStreamRecordPages = 10000
+
+// nextPageTokenKey is the response envelope key naming the next page of stream records.
+const nextPageTokenKey = "nextPageToken"
+
+// ReadStreamRecordsLimit is ReadStreamRecords with a cap on the records kept. With maxRecords > 0
+// every page is still read, but decoded incrementally keeping only the newest (last) maxRecords
+// records, so memory stays bounded; truncated reports that older records were dropped.
+// maxRecords <= 0 reads every record.
+//
+// An envelope response carrying a non-empty nextPageToken is followed by a request for that page
+// (pageToken query parameter), and the pages' records are concatenated before the cap applies. Reading more than
+// maxStreamRecordPages pages fails; a page repeating the token it was requested with ends the read
+// with a warning. A WithStreamRecordUnmarshaler response is always read as a single page.
+func (c *Client) ReadStreamRecordsLimit(ctx context.Context, streamRID, branch string, maxRecords int) (recs []map[string]any, truncated bool, err error) {
 	streamRID = strings.TrimSpace(streamRID)
 	branch = strings.TrimSpace(branch)
 	if streamRID == "" {
-		return nil, fmt.Errorf("stream rid is required")
+		return nil, false, fmt.Errorf("stream rid is required")
 	}
 	if branch == "" {
 		branch = "master"
 	}
 
+	var elements int
+	pageToken := ""
+	for page := 0; ; page++ {
+		if page >= maxStreamRecordPages {
+			return nil, false, fmt.Errorf("read stream records for %s@%s: more than %d pages", streamRID, branch, maxStreamRecordPages)
+		}
+		pageRecs, pageTruncated, pageElements, next, err := c.readStreamRecordsPage(ctx, streamRID, branch, pageToken, maxRecords)
+		if err != nil {
+			return nil, false, err
+		}
+		recs = append(recs, pageRecs...)
+		if maxRecords > 0 && len(recs) > maxRecords {
+			recs = slices.Clone(recs[len(recs)-maxRecords:])
+			pageTruncated = true
+		}
+		truncated = truncated || pageTruncated
+		elements += pageElements
+		if next == "" {
+			break
+		}
+		if next == pageToken {
+			c.warnf("stream records page %q for %s@%s names itself as the next page; stopping", pageToken, streamRID, branch)
+			break
+		}
+		pageToken = next
+	}
+	if len(recs) == 0 && elements > 0 {
+		// Data came back but none of it looked like records: likely an envelope this client does
+		// not know, which would silently empty the incremental cache.
+		c.warnf(
+			"stream records response for %s@%s has %d array elements but no records were extracted; the response shape may not match this stack (see WithStreamRecordUnmarshaler)",
+			streamRID, branch, elements,
+		)
+	}
+	return recs, truncated, nil
+}
+
+// readStreamRecordsPage reads one page of stream records, keeping the newest maxRecords when it is
+// positive. pageToken is empty for the first page; next is the response's nextPageToken.
+func (c *Client) readStreamRecordsPage(ctx context.Context, streamRID, branch, pageToken string, maxRecords int) (recs []map[string]any, truncated bool, elements int, next string, err error) {
 	u := c.resolveStream(fmt.Sprintf(
 		"streams/%s/branches/%s/records",
 		url.PathEscape(streamRID),
 		url.PathEscape(branch),
 	))
+	if pageToken != "" {
+		u.RawQuery = url.Values{"pageToken": {pageToken}}.Encode()
+	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	req, err := c.newRequest(ctx, "readStreamRecords", http.MethodGet, u.String(), nil)
 	if err != nil {
-		return nil, err
+		return nil, false, 0, "", err
 	}
-	req.Header.Set("Authorization", "Bearer "+c.token)
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := c.http.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return nil, err
+		return nil, false, 0, "", err
 	}
 	defer func() {
 		_ = resp.Body.Close()
 	}()
 
+	if resp.StatusCode/100 != 2 {
+		rb, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, false, 0, "", err
+		}
+		return nil, false, 0, "", newHTTPError("readStreamRecords", resp, rb)
+	}
+
+	if c.unmarshalRecords != nil {
+		rb, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, false, 0, "", err
+		}
+		recs, err = c.unmarshalRecords(rb)
+		if err != nil {
+			return nil, false, 0, "", fmt.Errorf("parse stream records response: %w", err)
+		}
+		if maxRecords > 0 && len(recs) > maxRecords {
+			return recs[len(recs)-maxRecords:], true, 0, "", nil
+		}
+		return recs, false, 0, "", nil
+	}
+
+	if maxRecords > 0 {
+		recs, truncated, elements, next, err = decodeStreamRecordsLimit(resp.Body, maxRecords)
+		if err != nil {
+			return nil, false, 0, "", fmt.Errorf("parse stream records response: %w", err)
+		}
+		return recs, truncated, elements, next, nil
+	}
 	rb, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, false, 0, "", err
 	}
-	if resp.StatusCode/100 != 2 {
-		return nil, newHTTPError("readStreamRecords", resp, rb)
+	recs, elements, next, err = parseStreamRecordsResponse(rb)
+	if err != nil {
+		return nil, false, 0, "", fmt.Errorf("parse stream records response: %w", err)
 	}
+	return recs, false, elements, next, nil
+}
 
-	recs, err := parseStreamRecordsResponse(rb)
-	if err != nil {
-		return nil, fmt.Errorf("parse stream records response: %w", err)
+// ErrStreamRecordsNotSettled reports that WaitForStreamRecords timed out before the stream showed
+// the records it waited for.
+var ErrStreamRecordsNotSettled = errors.New("stream records not settled")
+
+// Polling bounds for WaitForStreamRecords: the first poll waits streamSettleMinInterval and each
+// later one doubles it, up to streamSettleMaxInterval.
+const (
+	streamSettleMinInterval = 50 * time.Millisecond
+	streamSettleMaxInterval = 2 * time.Second
+)
+
+// WaitForStreamRecords polls ReadStreamRecords with backoff until the stream branch shows at least
+// want records or timeout elapses, for stream-proxy stacks where a read right after a publish may
+// not show it yet. It returns the last records read; on timeout the error wraps
+// ErrStreamRecordsNotSettled. A failed read or a cancelled ctx stops the wait.
+func (c *Client) WaitForStreamRecords(ctx context.Context, streamRID, branch string, want int, timeout time.Duration) ([]map[string]any, error) {
+	deadline := time.Now().Add(timeout)
+	interval := streamSettleMinInterval
+	for {
+		recs, err := c.ReadStreamRecords(ctx, streamRID, branch)
+		if err != nil {
+			return nil, err
+		}
+		if len(recs) >= want {
+			return recs, nil
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return recs, fmt.Errorf("%w: %d of %d records visible on %s@%s after %s", ErrStreamRecordsNotSettled, len(recs), want, streamRID, branch, timeout)
+		}
+		t := time.NewTimer(min(interval, remaining))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return recs, ctx.Err()
+		case <-t.C:
+		}
+		interval = min(interval*2, streamSettleMaxInterval)
 	}
-	return recs, nil
 }
 
-func parseStreamRecordsResponse(body []byte) ([]map[string]any, error) {
+// parseStreamRecordsResponse extracts the record list from a records response body. elements
+// counts the array elements the heuristics inspected (see arrayElements); next is an object body's
+// nextPageToken.
+func parseStreamRecordsResponse(body []byte) (recs []map[string]any, elements int, next string, err error) {
 	var top any
 	if err := json.Unmarshal(body, &top); err != nil {
-		return nil, err
+		return nil, 0, "", err
 	}
 
 	// Stream-proxy response shapes vary by stack/version.
@@ -314,9 +835,44 @@ func parseStreamRecordsResponse(body []byte) ([]map[string]any, error) {
 	// - { "values": [ {"record": {..}}, ... ] }
 	//
 	// We keep this permissive and best-effort.
-	return extractRecordList(top)
+	recs, err = extractRecordList(top)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	if m, ok := top.(map[string]any); ok {
+		next, _ = m[nextPageTokenKey].(string)
+	}
+	return recs, arrayElements(top), next, nil
+}
+
+// arrayElements counts the elements of the arrays the record list heuristics inspect: v itself,
+// arrays directly under an object, and arrays under known list keys' nested objects. A non-zero
+// count with no extracted records means the body held data in an unrecognized shape.
+func arrayElements(v any) int {
+	switch t := v.(type) {
+	case []any:
+		return len(t)
+	case map[string]any:
+		n := 0
+		for key, inner := range t {
+			switch inner := inner.(type) {
+			case []any:
+				n += len(inner)
+			case map[string]any:
+				if slices.Contains(streamRecordListKeys, key) {
+					n += arrayElements(inner)
+				}
+			}
+		}
+		return n
+	default:
+		return 0
+	}
 }
 
+// streamRecordListKeys are the object keys known to hold the record list, in preference order.
+var streamRecordListKeys = []string{"records", "values", "data", "items", "result"}
+
 func extractRecordList(v any) ([]map[string]any, error) {
 	switch t := v.(type) {
 	case []any:
@@ -332,7 +888,7 @@ func extractRecordList(v any) ([]map[string]any, error) {
 		return out, nil
 	case map[string]any:
 		// Prefer well-known paging keys.
-		for _, key := range []string{"records", "values", "data", "items", "result"} {
+		for _, key := range streamRecordListKeys {
 			if inner, ok := t[key]; ok {
 				if recs, err := extractRecordList(inner); err == nil {
 					return recs, nil
@@ -359,8 +915,38 @@ func extractRecordList(v any) ([]map[string]any, error) {
 	}
 }
 
+// PartitionKeyHeader carries the partition key of a published stream record. Records sharing a key
+// land on the same partition, so consumers see them in publish order.
+const PartitionKeyHeader = "X-Partition-Key"
+
 // PublishStreamJSONRecord publishes one JSON object to a stream branch via stream-proxy.
 func (c *Client) PublishStreamJSONRecord(ctx context.Context, streamRID, branch string, record map[string]any) error {
+	return c.PublishStreamJSONRecordWithKey(ctx, streamRID, branch, record, "")
+}
+
+// IdempotencyKeyHeader carries a publish's idempotency key. A stack that deduplicates publishes
+// stores a record once per key and acknowledges repeats with status "duplicate".
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// StreamPublishOptions tunes a single stream publish.
+type StreamPublishOptions struct {
+	// PartitionKey is sent in PartitionKeyHeader; empty publishes the record unkeyed.
+	PartitionKey string
+	// IdempotencyKey is sent in IdempotencyKeyHeader; empty sends none.
+	IdempotencyKey string
+	// VerifyAck requires the response body to acknowledge the record with status "ok" or
+	// "duplicate" instead of accepting any 2xx.
+	VerifyAck bool
+}
+
+// PublishStreamJSONRecordWithKey is PublishStreamJSONRecord with an explicit partition key sent in
+// PartitionKeyHeader. An empty key publishes the record unkeyed.
+func (c *Client) PublishStreamJSONRecordWithKey(ctx context.Context, streamRID, branch string, record map[string]any, partitionKey string) error {
+	return c.PublishStreamJSONRecordWithOptions(ctx, streamRID, branch, record, StreamPublishOptions{PartitionKey: partitionKey})
+}
+
+// PublishStreamJSONRecordWithOptions publishes one JSON object to a stream branch via stream-proxy.
+func (c *Client) PublishStreamJSONRecordWithOptions(ctx context.Context, streamRID, branch string, record map[string]any, opts StreamPublishOptions) error {
 	streamRID = strings.TrimSpace(streamRID)
 	branch = strings.TrimSpace(branch)
 	if streamRID == "" {
@@ -380,15 +966,20 @@ func (c *Client) PublishStreamJSONRecord(ctx context.Context, streamRID, branch
 		url.PathEscape(branch),
 	))
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(b))
+	req, err := c.newRequest(ctx, "publishStreamJSONRecord", http.MethodPost, u.String(), bytes.NewReader(b))
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Authorization", "Bearer "+c.token)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
+	if key := strings.TrimSpace(opts.PartitionKey); key != "" {
+		req.Header.Set(PartitionKeyHeader, key)
+	}
+	if key := strings.TrimSpace(opts.IdempotencyKey); key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
 
-	resp, err := c.http.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
@@ -402,6 +993,56 @@ func (c *Client) PublishStreamJSONRecord(ctx context.Context, streamRID, branch
 	if resp.StatusCode/100 != 2 {
 		return newHTTPError("publishStreamJSONRecord", resp, rb)
 	}
+	if opts.VerifyAck {
+		var ack struct {
+			Status string `json:"status"`
+		}
+		if err := json.Unmarshal(rb, &ack); err != nil || (ack.Status != "ok" && ack.Status != "duplicate") {
+			return fmt.Errorf("publishStreamJSONRecord: record not acknowledged (response %q)", redactAndTruncate(rb))
+		}
+	}
+	return nil
+}
+
+// ProbeStreamPublish checks publish permission on a stream branch by posting an empty batch to the
+// stream-proxy jsonRecords endpoint. No records are written.
+func (c *Client) ProbeStreamPublish(ctx context.Context, streamRID, branch string) error {
+	streamRID = strings.TrimSpace(streamRID)
+	branch = strings.TrimSpace(branch)
+	if streamRID == "" {
+		return fmt.Errorf("stream rid is required")
+	}
+	if branch == "" {
+		branch = "master"
+	}
+
+	u := c.resolveStream(fmt.Sprintf(
+		"streams/%s/branches/%s/jsonRecords",
+		url.PathEscape(streamRID),
+		url.PathEscape(branch),
+	))
+
+	req, err := c.newRequest(ctx, "probeStreamPublish", http.MethodPost, u.String(), strings.NewReader("[]"))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	rb, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return newHTTPError("probeStreamPublish", resp, rb)
+	}
 	return nil
 }
 
@@ -413,9 +1054,26 @@ type createTxnResponse struct {
 	RID string `json:"rid"`
 }
 
-// CreateTransaction creates a dataset transaction and returns the transaction id.
+// Transaction types for CreateTransactionOfType. A SNAPSHOT transaction replaces the dataset view
+// with its files; an APPEND transaction adds its files to the current view.
+const (
+	TransactionTypeSnapshot = "SNAPSHOT"
+	TransactionTypeAppend   = "APPEND"
+)
+
+// CreateTransaction creates a SNAPSHOT dataset transaction and returns the transaction id.
 func (c *Client) CreateTransaction(ctx context.Context, datasetRID, branch string) (string, error) {
-	body := createTxnRequest{TransactionType: "SNAPSHOT"}
+	return c.CreateTransactionOfType(ctx, datasetRID, branch, TransactionTypeSnapshot)
+}
+
+// CreateTransactionOfType creates a dataset transaction of txType and returns the transaction id. An
+// empty txType creates a SNAPSHOT transaction.
+func (c *Client) CreateTransactionOfType(ctx context.Context, datasetRID, branch, txType string) (string, error) {
+	txType = strings.ToUpper(strings.TrimSpace(txType))
+	if txType == "" {
+		txType = TransactionTypeSnapshot
+	}
+	body := createTxnRequest{TransactionType: txType}
 	b, err := json.Marshal(body)
 	if err != nil {
 		return "", err
@@ -427,15 +1085,14 @@ func (c *Client) CreateTransaction(ctx context.Context, datasetRID, branch strin
 		q.Set("branchName", branch)
 	}
 	u.RawQuery = q.Encode()
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(b))
+	req, err := c.newRequest(ctx, "createTransaction", http.MethodPost, u.String(), bytes.NewReader(b))
 	if err != nil {
 		return "", err
 	}
-	req.Header.Set("Authorization", "Bearer "+c.token)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := c.http.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return "", err
 	}
@@ -493,14 +1150,13 @@ func (c *Client) ListTransactions(ctx context.Context, datasetRID string, pageSi
 	}
 	u.RawQuery = q.Encode()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	req, err := c.newRequest(ctx, "listTransactions", http.MethodGet, u.String(), nil)
 	if err != nil {
 		return nil, "", err
 	}
-	req.Header.Set("Authorization", "Bearer "+c.token)
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := c.http.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, "", err
 	}
@@ -536,6 +1192,13 @@ func (c *Client) FindLatestOpenTransaction(ctx context.Context, datasetRID strin
 // When branchName is present, this method filters on it so branch-scoped open transaction conflicts do
 // not accidentally reuse an OPEN transaction from a different branch.
 func (c *Client) FindLatestOpenTransactionForBranch(ctx context.Context, datasetRID, branch string) (string, bool, error) {
+	txn, ok, err := c.LatestOpenTransactionForBranch(ctx, datasetRID, branch)
+	return strings.TrimSpace(txn.RID), ok, err
+}
+
+// LatestOpenTransactionForBranch is FindLatestOpenTransactionForBranch returning the whole transaction,
+// so callers reusing it can check its type.
+func (c *Client) LatestOpenTransactionForBranch(ctx context.Context, datasetRID, branch string) (Transaction, bool, error) {
 	branch = strings.TrimSpace(branch)
 	if branch == "" {
 		branch = "master"
@@ -544,14 +1207,14 @@ func (c *Client) FindLatestOpenTransactionForBranch(ctx context.Context, dataset
 	for i := 0; i < 5; i++ {
 		txns, next, err := c.ListTransactions(ctx, datasetRID, 100, pageToken)
 		if err != nil {
-			return "", false, err
+			return Transaction{}, false, err
 		}
 		for _, t := range txns {
 			if strings.TrimSpace(t.BranchName) != "" && !strings.EqualFold(strings.TrimSpace(t.BranchName), branch) {
 				continue
 			}
 			if strings.EqualFold(strings.TrimSpace(t.Status), "OPEN") && strings.TrimSpace(t.RID) != "" {
-				return strings.TrimSpace(t.RID), true, nil
+				return t, true, nil
 			}
 		}
 		if next == "" {
@@ -559,7 +1222,38 @@ func (c *Client) FindLatestOpenTransactionForBranch(ctx context.Context, dataset
 		}
 		pageToken = next
 	}
-	return "", false, nil
+	return Transaction{}, false, nil
+}
+
+// ListOpenTransactionsForBranch returns the RIDs of every OPEN transaction for a dataset branch,
+// newest first. Branch filtering follows FindLatestOpenTransactionForBranch, and so does the cap of
+// five listing pages.
+func (c *Client) ListOpenTransactionsForBranch(ctx context.Context, datasetRID, branch string) ([]string, error) {
+	branch = strings.TrimSpace(branch)
+	if branch == "" {
+		branch = "master"
+	}
+	var out []string
+	pageToken := ""
+	for i := 0; i < 5; i++ {
+		txns, next, err := c.ListTransactions(ctx, datasetRID, 100, pageToken)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range txns {
+			if strings.TrimSpace(t.BranchName) != "" && !strings.EqualFold(strings.TrimSpace(t.BranchName), branch) {
+				continue
+			}
+			if strings.EqualFold(strings.TrimSpace(t.Status), "OPEN") && strings.TrimSpace(t.RID) != "" {
+				out = append(out, strings.TrimSpace(t.RID))
+			}
+		}
+		if next == "" {
+			break
+		}
+		pageToken = next
+	}
+	return out, nil
 }
 
 // UploadFile uploads file bytes to a transaction path.
@@ -576,16 +1270,15 @@ func (c *Client) UploadFile(ctx context.Context, datasetRID, txnID, filePath str
 	}
 	u.RawQuery = q.Encode()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(b))
+	req, err := c.newRequest(ctx, "uploadFile", http.MethodPost, u.String(), bytes.NewReader(b))
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Authorization", "Bearer "+c.token)
 	if contentType != "" {
 		req.Header.Set("Content-Type", contentType)
 	}
 
-	resp, err := c.http.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
@@ -610,14 +1303,13 @@ func (c *Client) CommitTransaction(ctx context.Context, datasetRID, txnID string
 		url.PathEscape(txnID),
 	))
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), nil)
+	req, err := c.newRequest(ctx, "commitTransaction", http.MethodPost, u.String(), nil)
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Authorization", "Bearer "+c.token)
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := c.http.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
@@ -634,6 +1326,179 @@ func (c *Client) CommitTransaction(ctx context.Context, datasetRID, txnID string
 	return nil
 }
 
+// AbortTransaction aborts an open transaction, discarding any staged files.
+func (c *Client) AbortTransaction(ctx context.Context, datasetRID, txnID string) error {
+	u := c.resolveAPI(fmt.Sprintf(
+		"v2/datasets/%s/transactions/%s/abort",
+		url.PathEscape(datasetRID),
+		url.PathEscape(txnID),
+	))
+
+	req, err := c.newRequest(ctx, "abortTransaction", http.MethodPost, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	rb, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return newHTTPError("abortTransaction", resp, rb)
+	}
+	return nil
+}
+
+// do sends req with the bearer token (see doAuthorized) and returns a response whose body is never
+// content-encoded.
+//
+// A Timeouts.Operations deadline for the request's operation is applied here, when the request is
+// sent, and released once the response body is closed.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	hc := c.http
+	var cancel context.CancelFunc
+	if d := c.opTimeouts[requestOperation(req.Context())]; d > 0 {
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(req.Context(), d)
+		req = req.WithContext(ctx)
+		// The operation's own deadline replaces the client-wide Timeout.
+		cp := *c.http
+		cp.Timeout = 0
+		hc = &cp
+	}
+	resp, err := c.doAuthorized(hc, req)
+	if cancel != nil {
+		if err != nil {
+			cancel()
+		} else {
+			resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := decodeContentEncoding(resp); err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// decodeContentEncoding unwraps a gzip body the transport did not decode itself (it only does so
+// for requests where it added Accept-Encoding), so callers see the same bytes either way.
+func decodeContentEncoding(resp *http.Response) error {
+	if resp.Uncompressed || !strings.EqualFold(strings.TrimSpace(resp.Header.Get("Content-Encoding")), "gzip") {
+		return nil
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	switch {
+	case errors.Is(err, io.EOF):
+		// An empty body (for example a 204) carries no gzip header.
+		_ = resp.Body.Close()
+		resp.Body = http.NoBody
+	case err != nil:
+		return fmt.Errorf("decode gzip response body: %w", err)
+	default:
+		resp.Body = &gzipBody{Reader: zr, raw: resp.Body}
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// gzipBody closes both the gzip reader and the underlying response body.
+type gzipBody struct {
+	*gzip.Reader
+	raw io.ReadCloser
+}
+
+func (b *gzipBody) Close() error {
+	_ = b.Reader.Close()
+	return b.raw.Close()
+}
+
+// doAuthorized sends req through hc with the current bearer token. When the token is file-backed and the server
+// answers 401, the token file is re-read and, if it changed, the request is retried once with the new
+// token; a request whose body cannot be replayed is not retried.
+func (c *Client) doAuthorized(hc *http.Client, req *http.Request) (*http.Response, error) {
+	token := c.auth.current(time.Now())
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := hc.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !c.auth.rotates() {
+		return resp, err
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+	fresh, changed := c.auth.refresh(token, time.Now())
+	if !changed {
+		return resp, nil
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	retry.Header.Set("Authorization", "Bearer "+fresh)
+	return hc.Do(retry)
+}
+
+// operationKey holds, in a request context, the operation name newRequest was given, so do can
+// apply its Timeouts.Operations deadline.
+type operationKey struct{}
+
+func requestOperation(ctx context.Context) string {
+	op, _ := ctx.Value(operationKey{}).(string)
+	return op
+}
+
+// cancelOnClose releases an operation deadline when the response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// newRequest builds a request carrying the module User-Agent. op names the operation, as HTTPError
+// reports it, so a Timeouts.Operations override can bound the request instead of Overall.
+func (c *Client) newRequest(ctx context.Context, op, method, rawURL string, body io.Reader) (*http.Request, error) {
+	if _, ok := c.opTimeouts[op]; ok {
+		ctx = context.WithValue(ctx, operationKey{}, op)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	id := newRequestID()
+	req.Header.Set(RequestIDHeader, id)
+	if c.lastRequestID != nil {
+		c.lastRequestID.id.Store(&id)
+	}
+	return req, nil
+}
+
 func (c *Client) resolveAPI(relPath string) *url.URL {
 	relPath = strings.TrimPrefix(relPath, "/")
 	rel := &url.URL{Path: relPath}
@@ -0,0 +1,685 @@
+package foundry_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/mockfoundry"
+)
+
+// tokenServer accepts only the current token and records the bearer token and body of each request.
+type tokenServer struct {
+	mu     sync.Mutex
+	valid  string
+	tokens []string
+	bodies []string
+	*httptest.Server
+}
+
+func newTokenServer(t *testing.T, valid string) *tokenServer {
+	t.Helper()
+	s := &tokenServer{valid: valid}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		got := r.Header.Get("Authorization")
+		s.tokens = append(s.tokens, got)
+		s.bodies = append(s.bodies, string(b))
+		ok := got == "Bearer "+s.valid
+		s.mu.Unlock()
+		if !ok {
+			http.Error(w, `{"errorCode":"UNAUTHORIZED"}`, http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"master","transactionRid":"ri.txn.1"}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *tokenServer) rotate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.valid = token
+}
+
+func (s *tokenServer) seen() ([]string, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.tokens), slices.Clone(s.bodies)
+}
+
+func writeToken(t *testing.T, path, token string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		t.Fatalf("write token file: %v", err)
+	}
+}
+
+func TestClient_TokenFileRotationRetriesAfter401(t *testing.T) {
+	t.Parallel()
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	writeToken(t, tokenPath, "old-token")
+	ts := newTokenServer(t, "old-token")
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "old-token", "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	client = client.WithTokenFile(tokenPath, time.Hour)
+
+	ctx := context.Background()
+	if _, err := client.GetBranchTransactionRID(ctx, "ri.foundry.main.dataset.in", "master"); err != nil {
+		t.Fatalf("GetBranchTransactionRID before rotation: %v", err)
+	}
+
+	// Rotate mid-session: the server stops accepting the old token before the refresh interval.
+	writeToken(t, tokenPath, "new-token")
+	ts.rotate("new-token")
+
+	if err := client.PublishStreamJSONRecord(ctx, "ri.foundry.main.dataset.out", "master", map[string]any{"email": "alice@example.com"}); err != nil {
+		t.Fatalf("PublishStreamJSONRecord after rotation: %v", err)
+	}
+	if _, err := client.GetBranchTransactionRID(ctx, "ri.foundry.main.dataset.in", "master"); err != nil {
+		t.Fatalf("GetBranchTransactionRID after rotation: %v", err)
+	}
+
+	tokens, bodies := ts.seen()
+	wantTokens := []string{"Bearer old-token", "Bearer old-token", "Bearer new-token", "Bearer new-token"}
+	if !slices.Equal(tokens, wantTokens) {
+		t.Fatalf("tokens: want %q, got %q", wantTokens, tokens)
+	}
+	if bodies[1] == "" || bodies[2] != bodies[1] {
+		t.Fatalf("expected retried publish to resend its body, got %q then %q", bodies[1], bodies[2])
+	}
+}
+
+func TestClient_TokenFileRefreshedPeriodically(t *testing.T) {
+	t.Parallel()
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	writeToken(t, tokenPath, "file-token")
+	ts := newTokenServer(t, "file-token")
+
+	// The startup token is already stale; the file is read on first use.
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "startup-token", "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	client = client.WithTokenFile(tokenPath, time.Nanosecond)
+
+	ctx := context.Background()
+	if _, err := client.GetBranchTransactionRID(ctx, "ri.foundry.main.dataset.in", "master"); err != nil {
+		t.Fatalf("GetBranchTransactionRID: %v", err)
+	}
+	writeToken(t, tokenPath, "next-token")
+	ts.rotate("next-token")
+	if _, err := client.GetBranchTransactionRID(ctx, "ri.foundry.main.dataset.in", "master"); err != nil {
+		t.Fatalf("GetBranchTransactionRID after rotation: %v", err)
+	}
+
+	tokens, _ := ts.seen()
+	want := []string{"Bearer file-token", "Bearer next-token"}
+	if !slices.Equal(tokens, want) {
+		t.Fatalf("expected periodic refresh without a 401: want %q, got %q", want, tokens)
+	}
+}
+
+func TestClient_WithoutTokenFileDoesNotRetry401(t *testing.T) {
+	t.Parallel()
+
+	ts := newTokenServer(t, "other-token")
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "static-token", "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if _, err := client.GetBranchTransactionRID(context.Background(), "ri.foundry.main.dataset.in", "master"); err == nil {
+		t.Fatalf("expected 401 error")
+	}
+	if tokens, _ := ts.seen(); len(tokens) != 1 {
+		t.Fatalf("expected a single request, got %d", len(tokens))
+	}
+}
+
+func TestClient_PublishVerifyAckRequiresAcknowledgement(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu      sync.Mutex
+		idemKey string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		idemKey = r.Header.Get(foundry.IdempotencyKeyHeader)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	record := map[string]any{"email": "alice@example.com"}
+	if err := client.PublishStreamJSONRecordWithOptions(context.Background(), "ri.stream", "master", record, foundry.StreamPublishOptions{}); err != nil {
+		t.Fatalf("expected an empty 2xx body to succeed without VerifyAck, got %v", err)
+	}
+	err = client.PublishStreamJSONRecordWithOptions(context.Background(), "ri.stream", "master", record, foundry.StreamPublishOptions{
+		IdempotencyKey: "key-1",
+		VerifyAck:      true,
+	})
+	if err == nil {
+		t.Fatalf("expected an unacknowledged publish to fail with VerifyAck")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if idemKey != "key-1" {
+		t.Fatalf("expected idempotency key header %q, got %q", "key-1", idemKey)
+	}
+}
+
+func TestClient_WaitForStreamRecordsPollsUntilVisible(t *testing.T) {
+	t.Parallel()
+
+	const streamRID = "ri.foundry.main.dataset.44444444-4444-4444-4444-444444444444"
+	mock := mockfoundry.New(t.TempDir(), t.TempDir())
+	mock.CreateStream(streamRID)
+	mock.SetStreamVisibilityDelay(300 * time.Millisecond)
+	ts := httptest.NewServer(mock.Handler())
+	defer ts.Close()
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	ctx := context.Background()
+	for _, email := range []string{"alice@example.com", "bob@corp.test"} {
+		if err := client.PublishStreamJSONRecord(ctx, streamRID, "master", map[string]any{"email": email}); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+	recs, err := client.ReadStreamRecords(ctx, streamRID, "master")
+	if err != nil {
+		t.Fatalf("ReadStreamRecords: %v", err)
+	}
+	if len(recs) != 0 {
+		t.Fatalf("expected no records visible right after publish, got %d", len(recs))
+	}
+
+	recs, err = client.WaitForStreamRecords(ctx, streamRID, "master", 2, 5*time.Second)
+	if err != nil {
+		t.Fatalf("WaitForStreamRecords: %v", err)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(recs))
+	}
+
+	recs, err = client.WaitForStreamRecords(ctx, streamRID, "master", 3, 100*time.Millisecond)
+	if !errors.Is(err, foundry.ErrStreamRecordsNotSettled) {
+		t.Fatalf("expected ErrStreamRecordsNotSettled, got %v", err)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("expected the last read's 2 records on timeout, got %d", len(recs))
+	}
+}
+
+func TestClient_ReadStreamRecordsFollowsNextPageToken(t *testing.T) {
+	t.Parallel()
+
+	const streamRID = "ri.foundry.main.dataset.55555555-5555-5555-5555-555555555555"
+	mock := mockfoundry.New(t.TempDir(), t.TempDir())
+	mock.CreateStream(streamRID)
+	mock.SetStreamPageSize(2)
+	ts := httptest.NewServer(mock.Handler())
+	defer ts.Close()
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	ctx := context.Background()
+	want := []string{"a@example.com", "b@example.com", "c@example.com"}
+	for _, email := range want {
+		if err := client.PublishStreamJSONRecord(ctx, streamRID, "master", map[string]any{"email": email}); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+	emailsOf := func(recs []map[string]any) []string {
+		var out []string
+		for _, rec := range recs {
+			inner, _ := rec["record"].(map[string]any)
+			email, _ := inner["email"].(string)
+			out = append(out, email)
+		}
+		return out
+	}
+
+	pageReads := func() int {
+		n := 0
+		for _, c := range mock.Calls() {
+			if c.Method == http.MethodGet && strings.HasSuffix(c.Path, "/records") {
+				n++
+			}
+		}
+		return n
+	}
+
+	recs, err := client.ReadStreamRecords(ctx, streamRID, "master")
+	if err != nil {
+		t.Fatalf("ReadStreamRecords: %v", err)
+	}
+	if got := emailsOf(recs); !slices.Equal(got, want) {
+		t.Fatalf("expected records from both pages %v, got %v", want, got)
+	}
+	// The mock's tokens are opaque; the second page is only served for the token the first returned.
+	if got := pageReads(); got != 2 {
+		t.Fatalf("expected 2 page reads, got %d", got)
+	}
+
+	recs, truncated, err := client.ReadStreamRecordsLimit(ctx, streamRID, "master", 3)
+	if err != nil {
+		t.Fatalf("ReadStreamRecordsLimit: %v", err)
+	}
+	if got := emailsOf(recs); !slices.Equal(got, want) || truncated {
+		t.Fatalf("expected %v untruncated with an exact cap, got %v truncated=%t", want, got, truncated)
+	}
+
+	recs, truncated, err = client.ReadStreamRecordsLimit(ctx, streamRID, "master", 2)
+	if err != nil {
+		t.Fatalf("ReadStreamRecordsLimit: %v", err)
+	}
+	if got := emailsOf(recs); !slices.Equal(got, want[1:]) || !truncated {
+		t.Fatalf("expected the newest records %v truncated, got %v truncated=%t", want[1:], got, truncated)
+	}
+}
+
+func TestClient_ReadStreamRecordsLimitKeepsNewest(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name          string
+		body          string
+		max           int
+		wantEmails    []string
+		wantTruncated bool
+	}{
+		{
+			name:          "top-level array over cap",
+			body:          `[{"email":"a"},{"email":"b"},{"email":"c"}]`,
+			max:           2,
+			wantEmails:    []string{"b", "c"},
+			wantTruncated: true,
+		},
+		{
+			name:       "top-level array at cap",
+			body:       `[{"email":"a"},{"email":"b"}]`,
+			max:        2,
+			wantEmails: []string{"a", "b"},
+		},
+		{
+			name:          "values envelope with page token",
+			body:          `{"nextPageToken":"x","meta":{"n":3},"values":[{"record":{"email":"a"}},{"record":{"email":"b"}},{"record":{"email":"c"}}]}`,
+			max:           1,
+			wantEmails:    []string{"c"},
+			wantTruncated: true,
+		},
+		{
+			name:          "fallback array of objects",
+			body:          `{"rows":[1,{"email":"a"},{"email":"b"}]}`,
+			max:           1,
+			wantEmails:    []string{"b"},
+			wantTruncated: true,
+		},
+		{
+			name:       "no cap",
+			body:       `{"records":[{"email":"a"},{"email":"b"}]}`,
+			max:        0,
+			wantEmails: []string{"a", "b"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = io.WriteString(w, tc.body)
+			}))
+			t.Cleanup(ts.Close)
+			client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "token", "")
+			if err != nil {
+				t.Fatalf("NewClient: %v", err)
+			}
+
+			recs, truncated, err := client.ReadStreamRecordsLimit(context.Background(), "ri.stream", "master", tc.max)
+			if err != nil {
+				t.Fatalf("ReadStreamRecordsLimit: %v", err)
+			}
+			var got []string
+			for _, rec := range recs {
+				if inner, ok := rec["record"].(map[string]any); ok {
+					rec = inner
+				}
+				got = append(got, rec["email"].(string))
+			}
+			if !slices.Equal(got, tc.wantEmails) || truncated != tc.wantTruncated {
+				t.Fatalf("want %v truncated=%t, got %v truncated=%t", tc.wantEmails, tc.wantTruncated, got, truncated)
+			}
+		})
+	}
+}
+
+// newStreamRecordsServer serves body for every stream records request.
+func newStreamRecordsServer(t *testing.T, body string) *foundry.Client {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(ts.Close)
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "token", "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client
+}
+
+func TestClient_StreamRecordUnmarshalerReplacesHeuristics(t *testing.T) {
+	t.Parallel()
+
+	// An envelope the heuristics reject: no array anywhere.
+	body := `{"page":{"rows":{"0":{"email":"a"},"1":{"email":"b"},"2":{"email":"c"}}}}`
+	client := newStreamRecordsServer(t, body)
+	if _, err := client.ReadStreamRecords(context.Background(), "ri.stream", "master"); err == nil {
+		t.Fatalf("expected the heuristics to reject the custom envelope")
+	}
+
+	client = client.WithStreamRecordUnmarshaler(func(b []byte) ([]map[string]any, error) {
+		var env struct {
+			Page struct {
+				Rows map[string]map[string]any `json:"rows"`
+			} `json:"page"`
+		}
+		if err := json.Unmarshal(b, &env); err != nil {
+			return nil, err
+		}
+		var recs []map[string]any
+		for _, key := range []string{"0", "1", "2"} {
+			recs = append(recs, env.Page.Rows[key])
+		}
+		return recs, nil
+	})
+	recs, err := client.ReadStreamRecords(context.Background(), "ri.stream", "master")
+	if err != nil || len(recs) != 3 || recs[2]["email"] != "c" {
+		t.Fatalf("expected 3 records from the custom unmarshaler, got %v (err=%v)", recs, err)
+	}
+	recs, truncated, err := client.ReadStreamRecordsLimit(context.Background(), "ri.stream", "master", 2)
+	if err != nil || len(recs) != 2 || !truncated {
+		t.Fatalf("expected the cap applied to the unmarshaler result, got %d records truncated=%t (err=%v)", len(recs), truncated, err)
+	}
+}
+
+func TestClient_ReadStreamRecordsWarnsWhenNothingExtracted(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		body     string
+		wantWarn bool
+	}{
+		{name: "array of non-objects", body: `["a","b"]`, wantWarn: true},
+		{name: "empty known list beside unknown data", body: `{"records":[],"entries":[{"email":"a"}]}`, wantWarn: true},
+		{name: "genuinely empty", body: `{"records":[],"nextPageToken":"x"}`},
+		{name: "records found", body: `{"values":[{"email":"a"}]}`},
+	}
+	for _, tc := range cases {
+		for _, max := range []int{0, 10} {
+			t.Run(fmt.Sprintf("%s/max=%d", tc.name, max), func(t *testing.T) {
+				t.Parallel()
+
+				var buf bytes.Buffer
+				client := newStreamRecordsServer(t, tc.body).WithLogger(log.New(&buf, "", 0))
+				if _, _, err := client.ReadStreamRecordsLimit(context.Background(), "ri.stream", "master", max); err != nil {
+					t.Fatalf("ReadStreamRecordsLimit: %v", err)
+				}
+				warned := strings.Contains(buf.String(), "no records were extracted")
+				if warned != tc.wantWarn {
+					t.Fatalf("want warning=%t, got log %q", tc.wantWarn, buf.String())
+				}
+			})
+		}
+	}
+}
+
+func TestClient_WithWarningHandlerReceivesWarnings(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	var got []string
+	client := newStreamRecordsServer(t, `["a","b"]`).
+		WithLogger(log.New(&buf, "", 0)).
+		WithWarningHandler(func(msg string) { got = append(got, msg) })
+	if _, err := client.ReadStreamRecords(context.Background(), "ri.stream", "master"); err != nil {
+		t.Fatalf("ReadStreamRecords: %v", err)
+	}
+	if len(got) != 1 || !strings.Contains(got[0], "no records were extracted") {
+		t.Fatalf("expected the handler to receive the warning, got %q", got)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected a handled warning not to be logged, got %q", buf.String())
+	}
+}
+
+func TestClient_ReadTableDecodesGzipResponses(t *testing.T) {
+	t.Parallel()
+
+	csv := "email\n" + strings.Repeat("someone@example.com\n", 10000)
+	cases := []struct {
+		name         string
+		compression  bool
+		alwaysGzip   bool
+		wantAccepted bool
+	}{
+		// The transport advertises gzip and decompresses transparently.
+		{name: "transparent", compression: true, wantAccepted: true},
+		// Nothing is advertised, but a proxy gzips anyway; the client decodes it itself.
+		{name: "unrequested gzip", compression: false, alwaysGzip: true},
+		{name: "identity", compression: false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var mu sync.Mutex
+			var accepted string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				accepted = r.Header.Get("Accept-Encoding")
+				mu.Unlock()
+				w.Header().Set("Content-Type", "text/csv")
+				if !tc.alwaysGzip && !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+					_, _ = io.WriteString(w, csv)
+					return
+				}
+				w.Header().Set("Content-Encoding", "gzip")
+				zw := gzip.NewWriter(w)
+				_, _ = io.WriteString(zw, csv)
+				_ = zw.Close()
+			}))
+			t.Cleanup(ts.Close)
+
+			client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "token", "")
+			if err != nil {
+				t.Fatalf("NewClient: %v", err)
+			}
+			client = client.WithResponseCompression(tc.compression)
+
+			got, err := client.ReadTableCSVAtTransaction(context.Background(), "ri.dataset", "master", "ri.txn")
+			if err != nil {
+				t.Fatalf("ReadTableCSVAtTransaction: %v", err)
+			}
+			if string(got) != csv {
+				t.Fatalf("expected the decoded %d-byte table, got %d bytes starting %q", len(csv), len(got), got[:min(len(got), 20)])
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if gotAccepted := strings.Contains(accepted, "gzip"); gotAccepted != tc.wantAccepted {
+				t.Fatalf("Accept-Encoding=%q, want gzip advertised=%t", accepted, tc.wantAccepted)
+			}
+		})
+	}
+}
+
+func TestClient_ReadTableRejectsHTMLLoginPage(t *testing.T) {
+	t.Parallel()
+
+	const page = "<!DOCTYPE html>\n<html><body><form action=\"/login\">Sign in</form></body></html>\n"
+	mock := mockfoundry.New(t.TempDir(), t.TempDir())
+	mock.SetReadTableHTML(page)
+	ts := httptest.NewServer(mock.Handler())
+	defer ts.Close()
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	ctx := context.Background()
+	_, err = client.ReadTableCSVAtTransaction(ctx, "ri.dataset", "master", "ri.txn")
+	if !errors.Is(err, foundry.ErrUnexpectedHTML) || !strings.Contains(err.Error(), "likely an auth/proxy redirect") {
+		t.Fatalf("expected the friendly HTML error, got %v", err)
+	}
+
+	got, err := client.WithHTMLResponseCheck(false).ReadTableCSVAtTransaction(ctx, "ri.dataset", "master", "ri.txn")
+	if err != nil || string(got) != page {
+		t.Fatalf("with the check disabled expected the page back, got %q err=%v", got, err)
+	}
+
+	// A login page mislabelled as CSV is caught by its body.
+	for body, wantHTML := range map[string]bool{
+		"\r\n  <!doctype HTML><html></html>": true,
+		"<html lang=\"en\"></html>":          true,
+		"email\n<html>@example.com\n":        false,
+	} {
+		raw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/csv")
+			_, _ = io.WriteString(w, body)
+		}))
+		client, err := foundry.NewClient(raw.URL+"/api", raw.URL+"/stream-proxy/api", "dummy-token", "")
+		if err != nil {
+			t.Fatalf("new client: %v", err)
+		}
+		got, err := client.ReadTableCSVAtTransaction(ctx, "ri.dataset", "master", "ri.txn")
+		raw.Close()
+		if wantHTML != errors.Is(err, foundry.ErrUnexpectedHTML) {
+			t.Fatalf("body %q: want HTML error=%t, got %v", body, wantHTML, err)
+		}
+		if !wantHTML && string(got) != body {
+			t.Fatalf("body %q: expected the CSV back intact, got %q", body, got)
+		}
+	}
+}
+
+func TestClient_WithTimeoutsShortDialFailsFast(t *testing.T) {
+	t.Parallel()
+
+	// 10.255.255.1 is unroutable: a connect attempt hangs until the dial timeout (or fails at once
+	// on hosts without a route).
+	client, err := foundry.NewClient("http://10.255.255.1/api", "http://10.255.255.1/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	client = client.WithTimeouts(foundry.Timeouts{Dial: 200 * time.Millisecond, Overall: time.Minute})
+
+	start := time.Now()
+	_, err = client.ReadTableCSV(context.Background(), "ri.foundry.main.dataset.x", "master")
+	if err == nil {
+		t.Fatalf("expected the dial to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the dial timeout to fail fast despite the long overall timeout, took %s: %v", elapsed, err)
+	}
+}
+
+func TestClient_OperationTimeoutOverridesOverallForCommit(t *testing.T) {
+	t.Parallel()
+
+	// Every endpoint answers after 300ms: longer than the overall timeout, shorter than the
+	// commit override.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(300 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"master","transactionRid":"ri.txn"}`))
+	}))
+	defer ts.Close()
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	client = client.WithTimeouts(foundry.Timeouts{
+		Overall:    100 * time.Millisecond,
+		Operations: map[string]time.Duration{"commitTransaction": 10 * time.Second},
+	})
+
+	if err := client.CommitTransaction(context.Background(), "ri.foundry.main.dataset.x", "ri.txn"); err != nil {
+		t.Fatalf("expected the commit override to outlast the overall timeout: %v", err)
+	}
+	if _, err := client.GetBranchTransactionRID(context.Background(), "ri.foundry.main.dataset.x", "master"); err == nil {
+		t.Fatalf("expected the short overall timeout to fail the read")
+	}
+
+	// The override is a deadline too: a commit slower than it fails.
+	client = client.WithTimeouts(foundry.Timeouts{Operations: map[string]time.Duration{"commitTransaction": 100 * time.Millisecond}})
+	if err := client.CommitTransaction(context.Background(), "ri.foundry.main.dataset.x", "ri.txn"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the commit to hit its own deadline, got %v", err)
+	}
+}
+
+func TestClient_WithTimeoutsResponseHeaderTimeout(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	client = client.WithTimeouts(foundry.Timeouts{ResponseHeader: 100 * time.Millisecond, Overall: time.Minute})
+
+	start := time.Now()
+	if _, err := client.ReadTableCSV(context.Background(), "ri.foundry.main.dataset.x", "master"); err == nil {
+		t.Fatalf("expected the response header timeout to fail the request")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the response header timeout to fail fast, took %s", elapsed)
+	}
+}
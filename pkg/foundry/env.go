@@ -11,6 +11,13 @@ import (
 type DatasetRef struct {
 	RID    string
 	Branch string
+	// Path is the dataset's Foundry path when the alias map gave a path instead of a RID. RID is
+	// empty until the path is resolved with Client.ResolveDatasetRID.
+	Path string
+	// TransactionType is the type of the transactions dataset writes create (see
+	// TransactionTypeSnapshot and TransactionTypeAppend); empty means SNAPSHOT. The alias map never
+	// sets it.
+	TransactionType string
 }
 
 // Env is the runtime configuration needed to run in Foundry pipeline mode.
@@ -21,7 +28,10 @@ type Env struct {
 	// In Foundry compute modules, this is provided via DEFAULT_CA_PATH.
 	DefaultCAPath string
 	Token         string
-	Aliases       map[string]DatasetRef
+	// TokenPath is the file Token was read from (BUILD2_TOKEN). Clients re-read it so a rotated
+	// token is picked up by long-lived processes; see Client.WithTokenFile.
+	TokenPath string
+	Aliases   map[string]DatasetRef
 }
 
 // LoadEnv reads required pipeline-mode env vars.
@@ -30,16 +40,26 @@ type Env struct {
 //   - BUILD2_TOKEN (file path)
 //   - RESOURCE_ALIAS_MAP (file path)
 func LoadEnv() (Env, error) {
+	return LoadEnvWithSecrets(FileSecrets{})
+}
+
+// LoadEnvWithSecrets is LoadEnv resolving the BUILD2_TOKEN secret through secrets. TokenPath is
+// still taken from the BUILD2_TOKEN variable.
+func LoadEnvWithSecrets(secrets SecretProvider) (Env, error) {
 	services, err := loadServicesFromEnv()
 	if err != nil {
 		return Env{}, err
 	}
 	defaultCAPath := strings.TrimSpace(os.Getenv("DEFAULT_CA_PATH"))
 
-	token, err := readFileEnv("BUILD2_TOKEN")
+	token, ok, err := secrets.Get("BUILD2_TOKEN")
 	if err != nil {
 		return Env{}, err
 	}
+	if !ok {
+		return Env{}, fmt.Errorf("BUILD2_TOKEN is required")
+	}
+	tokenPath := strings.TrimSpace(os.Getenv("BUILD2_TOKEN"))
 
 	aliases, err := readAliasMapEnv("RESOURCE_ALIAS_MAP")
 	if err != nil {
@@ -50,6 +70,7 @@ func LoadEnv() (Env, error) {
 		Services:      services,
 		DefaultCAPath: defaultCAPath,
 		Token:         token,
+		TokenPath:     tokenPath,
 		Aliases:       aliases,
 	}, nil
 }
@@ -75,20 +96,9 @@ func loadServicesFromEnv() (Services, error) {
 	}, nil
 }
 
-func readFileEnv(varName string) (string, error) {
-	path := strings.TrimSpace(os.Getenv(varName))
-	if path == "" {
-		return "", fmt.Errorf("%s is required", varName)
-	}
-	b, err := os.ReadFile(path)
-	if err != nil {
-		return "", fmt.Errorf("read %s file: %w", varName, err)
-	}
-	return strings.TrimSpace(string(b)), nil
-}
-
 type aliasEntry struct {
 	RID    string  `json:"rid"`
+	Path   string  `json:"path"`
 	Branch *string `json:"branch"`
 }
 
@@ -108,17 +118,22 @@ func readAliasMapEnv(varName string) (map[string]DatasetRef, error) {
 	}
 	out := make(map[string]DatasetRef, len(raw))
 	for k, v := range raw {
-		if strings.TrimSpace(v.RID) == "" {
-			return nil, fmt.Errorf("alias %q: rid is required", k)
+		if strings.TrimSpace(v.RID) == "" && strings.TrimSpace(v.Path) == "" {
+			return nil, fmt.Errorf("alias %q: rid or path is required", k)
 		}
 		branch := ""
 		if v.Branch != nil && strings.TrimSpace(*v.Branch) != "" {
 			branch = strings.TrimSpace(*v.Branch)
 		}
-		out[k] = DatasetRef{
+		ref := DatasetRef{
 			RID:    v.RID,
 			Branch: branch,
 		}
+		// A RID wins over a path, so a resolved entry never costs a lookup.
+		if strings.TrimSpace(v.RID) == "" {
+			ref.Path = strings.TrimSpace(v.Path)
+		}
+		out[k] = ref
 	}
 	return out, nil
 }
@@ -0,0 +1,52 @@
+package foundry_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+)
+
+func TestLoadEnv_AliasEntriesByRIDOrPath(t *testing.T) {
+	dir := t.TempDir()
+	tokenPath := filepath.Join(dir, "token")
+	aliasPath := filepath.Join(dir, "aliases.json")
+	if err := os.WriteFile(tokenPath, []byte("dummy-token\n"), 0o600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+	aliases := `{
+		"input": {"rid": "ri.foundry.main.dataset.in", "branch": "dev"},
+		"output": {"path": " /Org/Project/enriched "},
+		"both": {"rid": "ri.foundry.main.dataset.both", "path": "/Org/Project/ignored"}
+	}`
+	if err := os.WriteFile(aliasPath, []byte(aliases), 0o600); err != nil {
+		t.Fatalf("write alias map: %v", err)
+	}
+	t.Setenv("FOUNDRY_SERVICE_DISCOVERY_V2", "")
+	t.Setenv("FOUNDRY_URL", "https://stack.example.test")
+	t.Setenv("BUILD2_TOKEN", tokenPath)
+	t.Setenv("RESOURCE_ALIAS_MAP", aliasPath)
+
+	env, err := foundry.LoadEnv()
+	if err != nil {
+		t.Fatalf("LoadEnv: %v", err)
+	}
+	want := map[string]foundry.DatasetRef{
+		"input":  {RID: "ri.foundry.main.dataset.in", Branch: "dev"},
+		"output": {Path: "/Org/Project/enriched"},
+		"both":   {RID: "ri.foundry.main.dataset.both"},
+	}
+	for alias, ref := range want {
+		if env.Aliases[alias] != ref {
+			t.Fatalf("alias %q: got %#v want %#v", alias, env.Aliases[alias], ref)
+		}
+	}
+
+	if err := os.WriteFile(aliasPath, []byte(`{"input": {"branch": "master"}}`), 0o600); err != nil {
+		t.Fatalf("write alias map: %v", err)
+	}
+	if _, err := foundry.LoadEnv(); err == nil {
+		t.Fatalf("expected an entry without rid or path to fail")
+	}
+}
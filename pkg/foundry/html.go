@@ -0,0 +1,64 @@
+package foundry
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// ErrUnexpectedHTML reports a 2xx response that is an HTML page instead of the requested data. SSO
+// proxies in front of some stacks answer an unauthenticated request with a 200 login page, which
+// would otherwise be parsed as CSV.
+var ErrUnexpectedHTML = errors.New("unexpected HTML response; likely an auth/proxy redirect")
+
+// htmlSniffBytes bounds how much of a body is inspected for an HTML document.
+const htmlSniffBytes = 512
+
+// htmlMarkers are the lowercased openings of an HTML document.
+var htmlMarkers = [][]byte{[]byte("<!doctype html"), []byte("<html")}
+
+// rejectHTMLResponse fails with ErrUnexpectedHTML when resp is declared as HTML or its body starts
+// like an HTML document. Otherwise it replaces resp.Body with one that still yields every byte.
+func rejectHTMLResponse(op string, resp *http.Response) error {
+	ct := resp.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(ct); err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml") {
+		return fmt.Errorf("%s: %w (content type %q)", op, ErrUnexpectedHTML, ct)
+	}
+	br := bufio.NewReaderSize(resp.Body, htmlSniffBytes)
+	if sniffHTML(br) {
+		return fmt.Errorf("%s: %w (body starts with an HTML document)", op, ErrUnexpectedHTML)
+	}
+	resp.Body = peekedBody{Reader: br, Closer: resp.Body}
+	return nil
+}
+
+// sniffHTML reports whether the body buffered by br opens an HTML document. It reads only until
+// the bytes seen decide it, so a body streamed in small, slow chunks is not held up; a read error
+// ends the sniff and resurfaces on the caller's next Read.
+func sniffHTML(br *bufio.Reader) bool {
+	for {
+		head, err := br.Peek(br.Buffered() + 1)
+		head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+		head = bytes.ToLower(bytes.TrimLeft(head, " \t\r\n"))
+		undecided := len(head) == 0
+		for _, marker := range htmlMarkers {
+			if bytes.HasPrefix(head, marker) {
+				return true
+			}
+			undecided = undecided || bytes.HasPrefix(marker, head)
+		}
+		if !undecided || err != nil {
+			return false
+		}
+	}
+}
+
+// peekedBody reads through the buffered reader that inspected the body and closes the original.
+type peekedBody struct {
+	*bufio.Reader
+	io.Closer
+}
@@ -27,6 +27,9 @@ type HTTPError struct {
 	ErrorName       string
 	ErrorCode       string
 	ErrorInstanceID string
+	// RequestID is the RequestIDHeader value the client sent, empty for requests not built by
+	// Client.
+	RequestID string
 
 	// Snippet is a redacted, truncated hint for non-Conjure responses.
 	Snippet string
@@ -48,6 +51,9 @@ func (e *HTTPError) Error() string {
 	if strings.TrimSpace(e.ErrorInstanceID) != "" {
 		parts = append(parts, "instance="+strings.TrimSpace(e.ErrorInstanceID))
 	}
+	if strings.TrimSpace(e.RequestID) != "" {
+		parts = append(parts, "requestId="+strings.TrimSpace(e.RequestID))
+	}
 	if strings.TrimSpace(e.Snippet) != "" {
 		parts = append(parts, "body="+strings.TrimSpace(e.Snippet))
 	}
@@ -63,6 +69,9 @@ func newHTTPError(op string, resp *http.Response, body []byte) error {
 	if resp != nil {
 		h.StatusCode = resp.StatusCode
 		h.Status = resp.Status
+		if resp.Request != nil {
+			h.RequestID = resp.Request.Header.Get(RequestIDHeader)
+		}
 	}
 
 	// Best effort: parse Conjure error envelope.
@@ -14,8 +14,10 @@ import (
 	"os"
 	"path"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
 	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/redact"
 )
 
@@ -38,9 +40,105 @@ type Config struct {
 	PostResultURI   string
 	ModuleAuthToken string
 	DefaultCAPath   string
+
+	// PostGracePeriod bounds how long an in-flight result post may continue after ctx is cancelled.
+	// Zero uses DefaultPostGracePeriod.
+	PostGracePeriod time.Duration
+
+	// PostRetries is how many times a failed result post is retried, sleeping 1s, 2s, ... between
+	// attempts. Zero uses DefaultPostRetries; a negative value disables retries.
+	PostRetries int
+	// PollBackoffMax caps the doubling sleep (from 500ms) after failed job polls. Zero uses
+	// DefaultPollBackoffMax.
+	PollBackoffMax time.Duration
+
+	// Status, when set, records the jobs RunLoop receives.
+	Status *Status
+}
+
+// Status reports keepalive loop activity, for callers deciding whether the module is still in use.
+// It is safe for concurrent use; the zero value is ready.
+type Status struct {
+	mu        sync.Mutex
+	jobs      int
+	active    int
+	lastJobAt time.Time
+}
+
+// JobsReceived returns the number of jobs RunLoop has received.
+func (s *Status) JobsReceived() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobs
+}
+
+// LastJobAt returns when RunLoop last received or finished a job; zero before the first.
+func (s *Status) LastJobAt() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastJobAt
+}
+
+func (s *Status) startJob(at time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs++
+	s.active++
+	s.lastJobAt = at
+}
+
+func (s *Status) finishJob(at time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active--
+	s.lastJobAt = at
 }
 
+// WaitIdle returns nil once no job has been received or in flight for idle, counting from since or
+// the last job activity, whichever is later. It returns ctx's error if ctx is done first.
+func (s *Status) WaitIdle(ctx context.Context, since time.Time, idle time.Duration) error {
+	for {
+		s.mu.Lock()
+		last, active := since, s.active > 0
+		if s.lastJobAt.After(last) {
+			last = s.lastJobAt
+		}
+		s.mu.Unlock()
+		wait := time.Until(last.Add(idle))
+		if active {
+			wait = idle
+		}
+		if wait <= 0 {
+			return nil
+		}
+		if err := sleepCtx(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// DefaultPostGracePeriod is the shutdown grace given to an in-flight result post.
+const DefaultPostGracePeriod = 2 * time.Second
+
+// DefaultPostRetries is the result post retry count when Config.PostRetries is zero.
+const DefaultPostRetries = 5
+
+// DefaultPollBackoffMax caps the job poll backoff when Config.PollBackoffMax is zero.
+const DefaultPollBackoffMax = 8 * time.Second
+
 func LoadConfigFromEnv() (Config, bool, error) {
+	return LoadConfigFromEnvWithSecrets(foundry.EnvSecrets{IsPath: isFilePath})
+}
+
+// LoadConfigFromEnvWithSecrets is LoadConfigFromEnv resolving the MODULE_AUTH_TOKEN secret through
+// secrets.
+func LoadConfigFromEnvWithSecrets(secrets foundry.SecretProvider) (Config, bool, error) {
 	getJob, err := normalizeLocalhostURI(strings.TrimSpace(os.Getenv("GET_JOB_URI")))
 	if err != nil {
 		return Config{}, false, fmt.Errorf("invalid GET_JOB_URI: %w", err)
@@ -53,7 +151,7 @@ func LoadConfigFromEnv() (Config, bool, error) {
 		return Config{}, false, nil
 	}
 
-	modTok, err := readValueOrFile(strings.TrimSpace(os.Getenv("MODULE_AUTH_TOKEN")), "MODULE_AUTH_TOKEN")
+	modTok, _, err := secrets.Get("MODULE_AUTH_TOKEN")
 	if err != nil {
 		return Config{}, false, err
 	}
@@ -109,6 +207,10 @@ func RunLoop(ctx context.Context, cfg Config, handleJob func(context.Context, Jo
 
 	logger.Printf("compute module client enabled; polling GET_JOB_URI=%s", cfg.GetJobURI)
 
+	pollBackoffMax := cfg.PollBackoffMax
+	if pollBackoffMax <= 0 {
+		pollBackoffMax = DefaultPollBackoffMax
+	}
 	sleep := 500 * time.Millisecond
 	for {
 		if err := ctx.Err(); err != nil {
@@ -117,27 +219,35 @@ func RunLoop(ctx context.Context, cfg Config, handleJob func(context.Context, Jo
 
 		job, ok, err := getNextJob(ctx, hc, cfg.GetJobURI, cfg.ModuleAuthToken)
 		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 			logger.Printf("compute module client: get job failed: %s", redact.Secrets(err.Error()))
-			time.Sleep(sleep)
-			if sleep < 5*time.Second {
-				sleep *= 2
+			if err := sleepCtx(ctx, sleep); err != nil {
+				return err
 			}
+			sleep = min(sleep*2, pollBackoffMax)
 			continue
 		}
 		sleep = 500 * time.Millisecond
 		if !ok {
-			time.Sleep(500 * time.Millisecond)
+			if err := sleepCtx(ctx, 500*time.Millisecond); err != nil {
+				return err
+			}
 			continue
 		}
 
 		jobID := strings.TrimSpace(job.JobID)
 		if jobID == "" {
 			logger.Printf("compute module client: received job without jobId; skipping")
-			time.Sleep(500 * time.Millisecond)
+			if err := sleepCtx(ctx, 500*time.Millisecond); err != nil {
+				return err
+			}
 			continue
 		}
 
 		logger.Printf("compute module client: received jobId=%s queryType=%s", jobID, strings.TrimSpace(job.QueryType))
+		cfg.Status.startJob(time.Now())
 		result, jobErr := handleJob(ctx, job)
 		if jobErr != nil {
 			logger.Printf("compute module client: jobId=%s failed: %s", jobID, redact.Secrets(jobErr.Error()))
@@ -148,16 +258,70 @@ func RunLoop(ctx context.Context, cfg Config, handleJob func(context.Context, Jo
 			result = []byte("ok")
 		}
 
-		if err := postResult(ctx, hc, cfg.PostResultURI, cfg.ModuleAuthToken, jobID, result); err != nil {
-			logger.Printf("compute module client: post result failed for jobId=%s: %s", jobID, redact.Secrets(err.Error()))
-			for i := 0; i < 5; i++ {
-				time.Sleep(time.Duration(i+1) * time.Second)
-				if err := postResult(ctx, hc, cfg.PostResultURI, cfg.ModuleAuthToken, jobID, result); err == nil {
-					break
-				}
-			}
+		postErr := postResultWithRetry(ctx, hc, cfg, jobID, result, logger)
+		cfg.Status.finishJob(time.Now())
+		if postErr != nil {
+			return postErr
+		}
+	}
+}
+
+// postResultWithRetry posts a job result, retrying with context-aware backoff. Each post runs under a
+// context that outlives ctx by the grace period so a shutdown does not abort a nearly-complete post.
+// It returns ctx's error only when cancellation interrupts the retries.
+func postResultWithRetry(ctx context.Context, hc *http.Client, cfg Config, jobID string, result []byte, logger *log.Logger) error {
+	grace := cfg.PostGracePeriod
+	if grace <= 0 {
+		grace = DefaultPostGracePeriod
+	}
+	post := func() error {
+		postCtx, cancel := graceContext(ctx, grace)
+		defer cancel()
+		return postResult(postCtx, hc, cfg.PostResultURI, cfg.ModuleAuthToken, jobID, result)
+	}
+
+	err := post()
+	if err == nil {
+		return nil
+	}
+	logger.Printf("compute module client: post result failed for jobId=%s: %s", jobID, redact.Secrets(err.Error()))
+	retries := cfg.PostRetries
+	if retries == 0 {
+		retries = DefaultPostRetries
+	}
+	for i := 0; i < retries; i++ {
+		if err := sleepCtx(ctx, time.Duration(i+1)*time.Second); err != nil {
+			return err
+		}
+		if err := post(); err == nil {
+			return nil
 		}
 	}
+	return nil
+}
+
+// graceContext returns a context that is cancelled grace after ctx is done.
+func graceContext(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	out, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		time.AfterFunc(grace, cancel)
+	})
+	return out, func() {
+		stop()
+		cancel()
+	}
+}
+
+// sleepCtx waits for d or until ctx is done, whichever comes first.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
 }
 
 func newHTTPClient(caPath string) (*http.Client, error) {
@@ -183,6 +347,7 @@ func getNextJob(ctx context.Context, hc *http.Client, getJobURI, moduleAuthToken
 	}
 	req.Header.Set("Module-Auth-Token", moduleAuthToken)
 	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", foundry.UserAgent)
 
 	resp, err := hc.Do(req)
 	if err != nil {
@@ -218,6 +383,7 @@ func postResult(ctx context.Context, hc *http.Client, postResultURI, moduleAuthT
 	}
 	req.Header.Set("Module-Auth-Token", moduleAuthToken)
 	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("User-Agent", foundry.UserAgent)
 
 	resp, err := hc.Do(req)
 	if err != nil {
@@ -231,20 +397,12 @@ func postResult(ctx context.Context, hc *http.Client, postResultURI, moduleAuthT
 	return nil
 }
 
-func readValueOrFile(v string, varName string) (string, error) {
-	v = strings.TrimSpace(v)
-	if v == "" {
-		return "", nil
-	}
+// isFilePath reports whether a MODULE_AUTH_TOKEN value names an existing file rather than being
+// the token itself.
+func isFilePath(v string) bool {
 	if strings.Contains(v, "\n") || strings.Contains(v, "\r") {
-		return strings.TrimSpace(v), nil
-	}
-	if fi, err := os.Stat(v); err == nil && !fi.IsDir() {
-		b, err := os.ReadFile(v)
-		if err != nil {
-			return "", fmt.Errorf("read %s file: %w", varName, err)
-		}
-		return strings.TrimSpace(string(b)), nil
+		return false
 	}
-	return v, nil
+	fi, err := os.Stat(v)
+	return err == nil && !fi.IsDir()
 }
@@ -0,0 +1,223 @@
+package keepalive_test
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry/keepalive"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry/keepalive/mockruntime"
+)
+
+// newRuntimeServer starts a TLS server that always hands out one job and fails every result post.
+// It returns the server, a CA file trusting it, a channel signalled on each post, and the User-Agent
+// of the most recent request.
+func newRuntimeServer(t *testing.T) (*httptest.Server, string, <-chan struct{}, *atomic.Value) {
+	t.Helper()
+
+	posted := make(chan struct{}, 16)
+	var userAgent atomic.Value
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent.Store(r.UserAgent())
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"computeModuleJobV1":{"jobId":"job-1","queryType":"ping"}}`))
+		case http.MethodPost:
+			select {
+			case posted <- struct{}{}:
+			default:
+			}
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts, writeServerCA(t, ts), posted, &userAgent
+}
+
+// writeServerCA writes a PEM file trusting the TLS test server and returns its path.
+func writeServerCA(t *testing.T, ts *httptest.Server) string {
+	t.Helper()
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	if err := os.WriteFile(caPath, caPEM, 0600); err != nil {
+		t.Fatalf("write ca: %v", err)
+	}
+	return caPath
+}
+
+func TestRunLoop_ReturnsPromptlyWhenCancelledDuringPostBackoff(t *testing.T) {
+	t.Parallel()
+
+	ts, caPath, posted, userAgent := newRuntimeServer(t)
+	grace := 300 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var handled atomic.Int32
+	done := make(chan error, 1)
+	go func() {
+		done <- keepalive.RunLoop(ctx, keepalive.Config{
+			GetJobURI:       ts.URL + "/job",
+			PostResultURI:   ts.URL + "/result",
+			ModuleAuthToken: "module-token",
+			DefaultCAPath:   caPath,
+			PostGracePeriod: grace,
+		}, func(context.Context, keepalive.Job) ([]byte, error) {
+			handled.Add(1)
+			return []byte("ok"), nil
+		})
+	}()
+
+	select {
+	case <-posted:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for the first result post")
+	}
+	// The first post failed, so RunLoop is now in a (1s) retry backoff.
+	time.Sleep(50 * time.Millisecond)
+	cancelledAt := time.Now()
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+		if elapsed := time.Since(cancelledAt); elapsed > grace {
+			t.Fatalf("RunLoop returned %s after cancel, want within grace %s", elapsed, grace)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("RunLoop did not return after context cancellation")
+	}
+	if handled.Load() != 1 {
+		t.Fatalf("expected exactly 1 handled job, got %d", handled.Load())
+	}
+	if got, _ := userAgent.Load().(string); got != foundry.UserAgent {
+		t.Fatalf("User-Agent: want %q, got %q", foundry.UserAgent, got)
+	}
+}
+
+func TestRunLoop_HandlesQueuedJobsAndPostsResults(t *testing.T) {
+	t.Parallel()
+
+	runtime := mockruntime.New("module-token")
+	ts := httptest.NewTLSServer(runtime.Handler())
+	t.Cleanup(ts.Close)
+
+	runtime.EnqueueJob(keepalive.Job{JobID: "job-1", QueryType: "echo", Query: json.RawMessage(`"hello"`)})
+	runtime.EnqueueJob(keepalive.Job{JobID: "job-2", QueryType: "fail"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- keepalive.RunLoop(ctx, runtime.Config(ts.URL, writeServerCA(t, ts)), func(_ context.Context, job keepalive.Job) ([]byte, error) {
+			if job.QueryType == "fail" {
+				return nil, errors.New("unsupported query type")
+			}
+			return job.Query, nil
+		})
+	}()
+
+	waitCtx, waitCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer waitCancel()
+	results, err := runtime.WaitForResults(waitCtx, 2)
+	if err != nil {
+		t.Fatalf("WaitForResults: %v (results=%#v)", err, results)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	if results[0].JobID != "job-1" || string(results[0].Body) != `"hello"` {
+		t.Fatalf("unexpected result for job-1: %#v", results[0])
+	}
+	if results[1].JobID != "job-2" || string(results[1].Body) != "unsupported query type" {
+		t.Fatalf("unexpected result for job-2: %#v", results[1])
+	}
+	if results[0].ContentType != "application/octet-stream" {
+		t.Fatalf("unexpected result content type %q", results[0].ContentType)
+	}
+	if runtime.Pending() != 0 {
+		t.Fatalf("expected queue to be drained, %d jobs pending", runtime.Pending())
+	}
+}
+
+func TestStatus_WaitIdle(t *testing.T) {
+	t.Parallel()
+
+	runtime := mockruntime.New("module-token")
+	ts := httptest.NewTLSServer(runtime.Handler())
+	t.Cleanup(ts.Close)
+
+	status := &keepalive.Status{}
+	cfg := runtime.Config(ts.URL, writeServerCA(t, ts))
+	cfg.Status = status
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = keepalive.RunLoop(ctx, cfg, func(context.Context, keepalive.Job) ([]byte, error) {
+			return []byte("ok"), nil
+		})
+	}()
+
+	// With no jobs, WaitIdle returns after the idle window.
+	idle := 200 * time.Millisecond
+	start := time.Now()
+	if err := status.WaitIdle(ctx, start, idle); err != nil {
+		t.Fatalf("WaitIdle: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < idle {
+		t.Fatalf("WaitIdle returned after %s, before the %s idle window", elapsed, idle)
+	}
+	if got := status.JobsReceived(); got != 0 {
+		t.Fatalf("expected no jobs, got %d", got)
+	}
+
+	// Jobs arriving within the window keep it open until they stop. The window outlasts the loop's
+	// 500ms idle poll plus the gap between jobs.
+	idle = 1200 * time.Millisecond
+	const gap = 300 * time.Millisecond
+	start = time.Now()
+	stopJobs := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			runtime.EnqueueJob(keepalive.Job{JobID: fmt.Sprintf("job-%d", i), QueryType: "echo"})
+			select {
+			case <-time.After(gap):
+			case <-stopJobs:
+				return
+			}
+		}
+	}()
+	defer close(stopJobs)
+	if err := status.WaitIdle(ctx, start, idle); err != nil {
+		t.Fatalf("WaitIdle: %v", err)
+	}
+	if got := status.JobsReceived(); got != 3 {
+		t.Fatalf("expected WaitIdle to stay open for all 3 jobs, got %d", got)
+	}
+	if elapsed := time.Since(start); elapsed < 2*gap+idle {
+		t.Fatalf("WaitIdle returned after %s, before the jobs stopped and the window elapsed", elapsed)
+	}
+
+	cancel()
+	if err := status.WaitIdle(ctx, time.Now(), idle); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled after cancel, got %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+// Package mockruntime is a minimal stand-in for the Foundry compute-module runtime endpoints
+// (GET_JOB_URI / POST_RESULT_URI) that keepalive.RunLoop polls.
+package mockruntime
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry/keepalive"
+)
+
+const (
+	// GetJobPath serves the next queued job, or 204 when the queue is empty.
+	GetJobPath = "/job"
+	// PostResultPath accepts results at PostResultPath + "/{jobId}".
+	PostResultPath = "/result"
+)
+
+// Result records a job result posted to the mock runtime.
+type Result struct {
+	JobID       string
+	ContentType string
+	Body        []byte
+}
+
+// Server implements the compute-module runtime job queue.
+type Server struct {
+	mu              sync.Mutex
+	moduleAuthToken string
+	queue           []keepalive.Job
+	results         []Result
+	polls           int
+	// posted is closed and replaced whenever a result is recorded.
+	posted chan struct{}
+}
+
+// New constructs a mock runtime. When moduleAuthToken is non-empty, requests must carry it in the
+// Module-Auth-Token header.
+func New(moduleAuthToken string) *Server {
+	return &Server{
+		moduleAuthToken: strings.TrimSpace(moduleAuthToken),
+		posted:          make(chan struct{}),
+	}
+}
+
+// Config returns a keepalive.Config pointing at a server for this runtime running at baseURL.
+func (s *Server) Config(baseURL, caPath string) keepalive.Config {
+	baseURL = strings.TrimRight(baseURL, "/")
+	return keepalive.Config{
+		GetJobURI:       baseURL + GetJobPath,
+		PostResultURI:   baseURL + PostResultPath,
+		ModuleAuthToken: s.moduleAuthToken,
+		DefaultCAPath:   caPath,
+	}
+}
+
+// EnqueueJob appends a job to the queue served by GET.
+func (s *Server) EnqueueJob(job keepalive.Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = append(s.queue, job)
+}
+
+// Pending returns the number of queued jobs not yet handed out.
+func (s *Server) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+// Polls returns the number of authorized GET requests served.
+func (s *Server) Polls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.polls
+}
+
+// Results returns a snapshot of posted results, in post order.
+func (s *Server) Results() []Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Result, len(s.results))
+	copy(out, s.results)
+	return out
+}
+
+// WaitForResults blocks until at least n results have been posted or ctx is done, and returns a
+// snapshot of the results.
+func (s *Server) WaitForResults(ctx context.Context, n int) ([]Result, error) {
+	for {
+		s.mu.Lock()
+		if len(s.results) >= n {
+			out := make([]Result, len(s.results))
+			copy(out, s.results)
+			s.mu.Unlock()
+			return out, nil
+		}
+		posted := s.posted
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return s.Results(), ctx.Err()
+		case <-posted:
+		}
+	}
+}
+
+// Handler returns an http.Handler that serves the runtime endpoints.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(GetJobPath, s.handleGetJob)
+	mux.HandleFunc(PostResultPath+"/", s.handlePostResult)
+	return mux
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if s.moduleAuthToken == "" || r.Header.Get("Module-Auth-Token") == s.moduleAuthToken {
+		return true
+	}
+	http.Error(w, "invalid Module-Auth-Token", http.StatusUnauthorized)
+	return false
+}
+
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorize(w, r) {
+		return
+	}
+
+	s.mu.Lock()
+	s.polls++
+	if len(s.queue) == 0 {
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	job := s.queue[0]
+	s.queue = s.queue[1:]
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]keepalive.Job{"computeModuleJobV1": job})
+}
+
+func (s *Server) handlePostResult(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorize(w, r) {
+		return
+	}
+	jobID := strings.TrimPrefix(r.URL.Path, PostResultPath+"/")
+	if jobID == "" || strings.Contains(jobID, "/") {
+		http.Error(w, "expected "+PostResultPath+"/{jobId}", http.StatusNotFound)
+		return
+	}
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "read body", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.results = append(s.results, Result{
+		JobID:       jobID,
+		ContentType: r.Header.Get("Content-Type"),
+		Body:        b,
+	})
+	close(s.posted)
+	s.posted = make(chan struct{})
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,85 @@
+package mockruntime_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry/keepalive"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry/keepalive/mockruntime"
+)
+
+func doRequest(t *testing.T, method, url, token string, body []byte) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Module-Auth-Token", token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+func TestMockRuntime_ServesQueuedJobsThen204(t *testing.T) {
+	t.Parallel()
+
+	srv := mockruntime.New("module-token")
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	srv.EnqueueJob(keepalive.Job{JobID: "job-1", QueryType: "ping", Query: json.RawMessage(`{"n":1}`)})
+
+	resp := doRequest(t, http.MethodGet, ts.URL+mockruntime.GetJobPath, "module-token", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET job: expected 200, got %d", resp.StatusCode)
+	}
+	var env struct {
+		Job keepalive.Job `json:"computeModuleJobV1"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		t.Fatalf("decode job envelope: %v", err)
+	}
+	if env.Job.JobID != "job-1" || env.Job.QueryType != "ping" || string(env.Job.Query) != `{"n":1}` {
+		t.Fatalf("unexpected job: %#v", env.Job)
+	}
+
+	if resp := doRequest(t, http.MethodGet, ts.URL+mockruntime.GetJobPath, "module-token", nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("GET job on empty queue: expected 204, got %d", resp.StatusCode)
+	}
+	if got := srv.Polls(); got != 2 {
+		t.Fatalf("expected 2 polls, got %d", got)
+	}
+}
+
+func TestMockRuntime_RecordsPostedResults(t *testing.T) {
+	t.Parallel()
+
+	srv := mockruntime.New("module-token")
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	if resp := doRequest(t, http.MethodPost, ts.URL+mockruntime.PostResultPath+"/job-1", "wrong-token", []byte("x")); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("POST with wrong token: expected 401, got %d", resp.StatusCode)
+	}
+	if resp := doRequest(t, http.MethodPost, ts.URL+mockruntime.PostResultPath+"/job-1", "module-token", []byte("done")); resp.StatusCode/100 != 2 {
+		t.Fatalf("POST result: expected 2xx, got %d", resp.StatusCode)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	results, err := srv.WaitForResults(ctx, 1)
+	if err != nil {
+		t.Fatalf("WaitForResults: %v", err)
+	}
+	if len(results) != 1 || results[0].JobID != "job-1" || string(results[0].Body) != "done" {
+		t.Fatalf("unexpected results: %#v", results)
+	}
+}
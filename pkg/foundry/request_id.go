@@ -0,0 +1,36 @@
+package foundry
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync/atomic"
+)
+
+// RequestIDHeader carries the id Client generates for each request. HTTPError reports it, so a
+// failed call can be found in the stack's request logs even when the response has no
+// errorInstanceId.
+const RequestIDHeader = "X-Request-Id"
+
+// newRequestID returns a random 128-bit id in hex.
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// lastRequestID holds the id of the most recent request built by a client and its copies.
+type lastRequestID struct {
+	id atomic.Pointer[string]
+}
+
+// LastRequestID returns the RequestIDHeader value of the most recent request the client (or a
+// copy made with one of its With methods) sent, or "" before the first one.
+func (c *Client) LastRequestID() string {
+	if c.lastRequestID == nil {
+		return ""
+	}
+	if id := c.lastRequestID.id.Load(); id != nil {
+		return *id
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+package foundry
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SecretProvider resolves named secrets. ok reports that the provider holds name (the value may
+// still be empty, for example an empty token file); err reports a secret that exists but could not
+// be read. Callers chain providers with ChainSecrets to keep a resolution precedence.
+type SecretProvider interface {
+	Get(name string) (value string, ok bool, err error)
+}
+
+// EnvSecrets resolves a secret from the environment variable of the same name. When IsPath reports
+// that the value names a file, the file's trimmed contents are returned instead; a nil IsPath always
+// returns the value itself.
+type EnvSecrets struct {
+	IsPath func(v string) bool
+}
+
+func (p EnvSecrets) Get(name string) (string, bool, error) {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return "", false, nil
+	}
+	if p.IsPath == nil || !p.IsPath(v) {
+		return v, true, nil
+	}
+	return readSecretFile(name, v)
+}
+
+// FileSecrets resolves a secret from the file named by the environment variable of the same name,
+// as Foundry injects BUILD2_TOKEN.
+type FileSecrets struct{}
+
+func (FileSecrets) Get(name string) (string, bool, error) {
+	path := strings.TrimSpace(os.Getenv(name))
+	if path == "" {
+		return "", false, nil
+	}
+	return readSecretFile(name, path)
+}
+
+func readSecretFile(name, path string) (string, bool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("read %s file: %w", name, err)
+	}
+	return strings.TrimSpace(string(b)), true, nil
+}
+
+// SourceSecrets resolves secrets by secret name from one Source in SOURCE_CREDENTIALS (see
+// SourceCredentials.GetSecret). An empty Source uses the only configured Source, and finds nothing
+// when there are several.
+type SourceSecrets struct {
+	Creds  SourceCredentials
+	Source string
+}
+
+func (p SourceSecrets) Get(name string) (string, bool, error) {
+	source := strings.TrimSpace(p.Source)
+	if source == "" {
+		names := p.Creds.SourceNames()
+		if len(names) != 1 {
+			return "", false, nil
+		}
+		source = names[0]
+	}
+	v, ok := p.Creds.GetSecret(source, name)
+	return v, ok, nil
+}
+
+// ChainSecrets tries each provider in order and returns the first one holding the secret. An error
+// stops the chain, so a secret that is configured but unreadable never falls through to a
+// lower-precedence provider.
+type ChainSecrets []SecretProvider
+
+func (c ChainSecrets) Get(name string) (string, bool, error) {
+	for _, p := range c {
+		v, ok, err := p.Get(name)
+		if err != nil || ok {
+			return v, ok, err
+		}
+	}
+	return "", false, nil
+}
@@ -0,0 +1,148 @@
+package foundry_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+)
+
+func writeSecretFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write secret file: %v", err)
+	}
+	return path
+}
+
+func getSecret(t *testing.T, p foundry.SecretProvider, name string) (string, bool) {
+	t.Helper()
+	v, ok, err := p.Get(name)
+	if err != nil {
+		t.Fatalf("Get(%q) failed: %v", name, err)
+	}
+	return v, ok
+}
+
+func TestEnvSecrets(t *testing.T) {
+	path := writeSecretFile(t, " from-file \n")
+	isPath := func(v string) bool { return strings.HasPrefix(v, "/") }
+
+	t.Setenv("TEST_SECRET", " literal ")
+	if v, ok := getSecret(t, foundry.EnvSecrets{IsPath: isPath}, "TEST_SECRET"); !ok || v != "literal" {
+		t.Fatalf("literal: got %q ok=%t", v, ok)
+	}
+
+	t.Setenv("TEST_SECRET", path)
+	if v, ok := getSecret(t, foundry.EnvSecrets{IsPath: isPath}, "TEST_SECRET"); !ok || v != "from-file" {
+		t.Fatalf("file: got %q ok=%t", v, ok)
+	}
+	if v, ok := getSecret(t, foundry.EnvSecrets{}, "TEST_SECRET"); !ok || v != path {
+		t.Fatalf("nil IsPath: got %q ok=%t", v, ok)
+	}
+
+	t.Setenv("TEST_SECRET", "")
+	if _, ok := getSecret(t, foundry.EnvSecrets{IsPath: isPath}, "TEST_SECRET"); ok {
+		t.Fatalf("expected an unset variable not to be found")
+	}
+
+	t.Setenv("TEST_SECRET", filepath.Join(t.TempDir(), "missing"))
+	if _, _, err := (foundry.EnvSecrets{IsPath: isPath}).Get("TEST_SECRET"); err == nil || !strings.Contains(err.Error(), "read TEST_SECRET file") {
+		t.Fatalf("expected a read error for a missing file, got %v", err)
+	}
+}
+
+func TestFileSecrets(t *testing.T) {
+	t.Setenv("TEST_TOKEN", writeSecretFile(t, "token-value\n"))
+	if v, ok := getSecret(t, foundry.FileSecrets{}, "TEST_TOKEN"); !ok || v != "token-value" {
+		t.Fatalf("got %q ok=%t", v, ok)
+	}
+
+	t.Setenv("TEST_TOKEN", "")
+	if _, ok := getSecret(t, foundry.FileSecrets{}, "TEST_TOKEN"); ok {
+		t.Fatalf("expected an unset variable not to be found")
+	}
+
+	t.Setenv("TEST_TOKEN", filepath.Join(t.TempDir(), "missing"))
+	if _, _, err := (foundry.FileSecrets{}).Get("TEST_TOKEN"); err == nil {
+		t.Fatalf("expected a read error for a missing file")
+	}
+}
+
+func TestSourceSecrets(t *testing.T) {
+	t.Parallel()
+
+	one := foundry.SourceCredentials{"gemini": {"apiKey": "k1", "additionalSecretToken": "t1"}}
+	if v, ok := getSecret(t, foundry.SourceSecrets{Creds: one}, "apiKey"); !ok || v != "k1" {
+		t.Fatalf("only source: got %q ok=%t", v, ok)
+	}
+	if v, ok := getSecret(t, foundry.SourceSecrets{Creds: one}, "Token"); !ok || v != "t1" {
+		t.Fatalf("additionalSecret prefix: got %q ok=%t", v, ok)
+	}
+
+	two := foundry.SourceCredentials{"a": {"apiKey": "ka"}, "b": {"apiKey": "kb"}}
+	if _, ok := getSecret(t, foundry.SourceSecrets{Creds: two}, "apiKey"); ok {
+		t.Fatalf("expected no implicit source among several")
+	}
+	if v, ok := getSecret(t, foundry.SourceSecrets{Creds: two, Source: "b"}, "apiKey"); !ok || v != "kb" {
+		t.Fatalf("named source: got %q ok=%t", v, ok)
+	}
+	if _, ok := getSecret(t, foundry.SourceSecrets{Creds: two, Source: "b"}, "missing"); ok {
+		t.Fatalf("expected a missing secret not to be found")
+	}
+
+	settings := foundry.SourceCredentials{"gemini": {"GEMINI_MODEL": "m1", "additionalSecretGeminiBaseUrl": "https://proxy.example"}}
+	if v, ok := getSecret(t, foundry.SourceSecrets{Creds: settings}, "GEMINI_MODEL"); !ok || v != "m1" {
+		t.Fatalf("env-style name: got %q ok=%t", v, ok)
+	}
+	// Only the caller knows which names have a CamelCase form; GetSecret matches names as given.
+	if _, ok := getSecret(t, foundry.SourceSecrets{Creds: settings}, "GEMINI_BASE_URL"); ok {
+		t.Fatalf("expected an env-style name not to match its CamelCase form")
+	}
+}
+
+type staticSecrets map[string]string
+
+func (s staticSecrets) Get(name string) (string, bool, error) {
+	v, ok := s[name]
+	return v, ok, nil
+}
+
+type failingSecrets struct{ err error }
+
+func (f failingSecrets) Get(string) (string, bool, error) { return "", false, f.err }
+
+func TestChainSecrets_FirstProviderHoldingTheSecretWins(t *testing.T) {
+	t.Parallel()
+
+	chain := foundry.ChainSecrets{
+		staticSecrets{"A": "first"},
+		staticSecrets{"A": "second", "B": "fallback", "EMPTY": "ignored"},
+	}
+	if v, ok := getSecret(t, chain, "A"); !ok || v != "first" {
+		t.Fatalf("precedence: got %q ok=%t", v, ok)
+	}
+	if v, ok := getSecret(t, chain, "B"); !ok || v != "fallback" {
+		t.Fatalf("fallback: got %q ok=%t", v, ok)
+	}
+	if _, ok := getSecret(t, chain, "C"); ok {
+		t.Fatalf("expected an unknown secret not to be found")
+	}
+
+	// A held-but-empty value still stops the chain.
+	chain = foundry.ChainSecrets{staticSecrets{"EMPTY": ""}, staticSecrets{"EMPTY": "later"}}
+	if v, ok := getSecret(t, chain, "EMPTY"); !ok || v != "" {
+		t.Fatalf("empty value: got %q ok=%t", v, ok)
+	}
+
+	// An error stops the chain instead of falling through.
+	boom := errors.New("boom")
+	chain = foundry.ChainSecrets{failingSecrets{err: boom}, staticSecrets{"A": "later"}}
+	if _, _, err := chain.Get("A"); !errors.Is(err, boom) {
+		t.Fatalf("expected the provider error, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package foundry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// decodeStreamRecordsLimit decodes a stream-proxy records response token by token, keeping the
+// newest (last) max records. It accepts the shapes parseStreamRecordsResponse does, except that the
+// first non-empty known list key in the object wins (rather than the first in streamRecordListKeys
+// order). truncated reports that older records were dropped. elements counts the array elements
+// inspected, as arrayElements does. next is the object's nextPageToken.
+func decodeStreamRecordsLimit(r io.Reader, max int) (recs []map[string]any, truncated bool, elements int, next string, err error) {
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, false, 0, "", err
+	}
+	switch tok {
+	case json.Delim('['):
+		recs, truncated, _, err := decodeRecordArray(dec, max, &elements)
+		return recs, truncated, elements, "", err
+	case json.Delim('{'):
+		recs, truncated, found, err := decodeRecordObject(dec, max, &elements, &next)
+		if err != nil {
+			return nil, false, 0, "", err
+		}
+		if !found {
+			return nil, false, 0, "", fmt.Errorf("unexpected json object shape")
+		}
+		return recs, truncated, elements, next, nil
+	default:
+		return nil, false, 0, "", fmt.Errorf("unexpected json type %T", tok)
+	}
+}
+
+// decodeRecordArray decodes array elements after the opening '[' through ']', keeping the last max
+// objects; truncated reports that earlier objects were dropped. At most 2*max objects are held at a
+// time. ok reports that at least one element was an object. Each element decoded is added to
+// *elements.
+func decodeRecordArray(dec *json.Decoder, max int, elements *int) (recs []map[string]any, truncated, ok bool, err error) {
+	for dec.More() {
+		var item any
+		if err := dec.Decode(&item); err != nil {
+			return nil, false, false, err
+		}
+		*elements++
+		m, isObject := item.(map[string]any)
+		if !isObject {
+			// Ignore non-object items.
+			continue
+		}
+		ok = true
+		if len(recs) == 2*max {
+			recs = append(recs[:0], recs[max:]...)
+			truncated = true
+		}
+		recs = append(recs, m)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, false, false, err
+	}
+	if len(recs) > max {
+		recs = recs[len(recs)-max:]
+		truncated = true
+	}
+	return recs, truncated, ok, nil
+}
+
+// decodeRecordObject finds the record list in an object after its opening '{'. A known list key
+// returns as soon as its list yields a record. An empty known list is the result unless a later
+// known list yields records, but the rest of the object is still read so *elements covers it. Any
+// other array of objects is kept as a fallback. found reports that a list was found.
+//
+// A non-nil next receives the object's nextPageToken. Then a known list that yields records no
+// longer returns early: the rest of the object is skipped over to find the token.
+func decodeRecordObject(dec *json.Decoder, max int, elements *int, next *string) (recs []map[string]any, truncated, found bool, err error) {
+	var fallback, result []map[string]any
+	fallbackTruncated, haveFallback, emptyKnown, haveResult, resultTruncated := false, false, false, false, false
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			return nil, false, false, err
+		}
+		key, _ := keyTok.(string)
+		known := slices.Contains(streamRecordListKeys, key)
+
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, false, false, err
+		}
+		if next != nil && key == nextPageTokenKey {
+			if s, ok := tok.(string); ok {
+				*next = s
+			}
+		}
+		if haveResult {
+			if tok == json.Delim('[') || tok == json.Delim('{') {
+				if err := skipRest(dec); err != nil {
+					return nil, false, false, err
+				}
+			}
+			continue
+		}
+		switch tok {
+		case json.Delim('['):
+			recs, truncated, ok, err := decodeRecordArray(dec, max, elements)
+			if err != nil {
+				return nil, false, false, err
+			}
+			if known && len(recs) > 0 {
+				if next == nil {
+					return recs, truncated, true, nil
+				}
+				result, resultTruncated, haveResult = recs, truncated, true
+				continue
+			}
+			if known {
+				emptyKnown = true
+				continue
+			}
+			if ok && !haveFallback {
+				fallback, fallbackTruncated, haveFallback = recs, truncated, true
+			}
+		case json.Delim('{'):
+			if !known {
+				if err := skipRest(dec); err != nil {
+					return nil, false, false, err
+				}
+				continue
+			}
+			// A nested object only returns early once it has found a list.
+			recs, truncated, ok, err := decodeRecordObject(dec, max, elements, nil)
+			if err != nil {
+				return nil, false, false, err
+			}
+			if ok && len(recs) > 0 {
+				if next == nil {
+					return recs, truncated, true, nil
+				}
+				result, resultTruncated, haveResult = recs, truncated, true
+				continue
+			}
+			emptyKnown = emptyKnown || ok
+		}
+		// Scalars were consumed whole by Token.
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, false, false, err
+	}
+	if haveResult {
+		return result, resultTruncated, true, nil
+	}
+	if emptyKnown {
+		return nil, false, true, nil
+	}
+	return fallback, fallbackTruncated, haveFallback, nil
+}
+
+// skipRest consumes the rest of an object or array whose opening delimiter was already read.
+func skipRest(dec *json.Decoder) error {
+	for depth := 1; depth > 0; {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package foundry
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTokenRefreshInterval is how often a client configured with WithTokenFile re-reads the
+// token file before a request. A 401 response always triggers an immediate re-read.
+const DefaultTokenRefreshInterval = time.Minute
+
+// tokenSource holds the bearer token sent by Client. When path is set the token is re-read from
+// that file periodically and on 401, so a rotated token (for example a Kubernetes projected service
+// account token) is picked up without restarting the process.
+type tokenSource struct {
+	mu       sync.Mutex
+	token    string
+	path     string
+	interval time.Duration
+	readAt   time.Time
+}
+
+func (s *tokenSource) rotates() bool {
+	return s.path != ""
+}
+
+// current returns the token to send, re-reading the file once the refresh interval has elapsed.
+// A failed read keeps the previous token.
+func (s *tokenSource) current(now time.Time) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.path != "" && now.Sub(s.readAt) >= s.interval {
+		s.readLocked(now)
+	}
+	return s.token
+}
+
+// refresh re-reads the token file after stale was rejected. It reports whether the returned token
+// differs from stale, i.e. whether retrying the request can help.
+func (s *tokenSource) refresh(stale string, now time.Time) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token == stale {
+		s.readLocked(now)
+	}
+	return s.token, s.token != stale
+}
+
+func (s *tokenSource) readLocked(now time.Time) {
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		// Rotation may truncate the file before writing the new token.
+		return
+	}
+	s.token = token
+	s.readAt = now
+}
@@ -4,33 +4,42 @@ import (
 	"bytes"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/csv"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
 )
 
 // Call records a request made to the mock service.
 type Call struct {
-	Method string
-	Path   string
+	Method    string
+	Path      string
+	UserAgent string
+	// RequestID echoes the request's X-Request-Id header.
+	RequestID string
 }
 
 // Upload records a file upload into a dataset transaction.
 type Upload struct {
-	DatasetRID string
-	TxnID      string
-	FilePath   string
-	Bytes      []byte
+	DatasetRID  string
+	TxnID       string
+	FilePath    string
+	ContentType string
+	Bytes       []byte
 }
 
 // Server implements a minimal "Foundry-like" dataset API surface.
@@ -44,6 +53,12 @@ type Server struct {
 
 	expectedAuthorization string
 
+	// writeDenied lists dataset/stream RIDs whose write endpoints respond 403.
+	writeDenied map[string]bool
+
+	// datasetPaths maps Foundry paths to RIDs for the getByPath endpoint.
+	datasetPaths map[string]string
+
 	nextTxn int
 	txns    map[string]txnState
 
@@ -55,7 +70,74 @@ type Server struct {
 	// streams tracks stream-proxy records per stream RID and branch.
 	// A RID is considered a "stream" if it exists as a key in this map.
 	streams               map[string]map[string][]map[string]any
+	streamKeys            map[string]map[string][]string
 	streamReadTableHeader []string
+	// streamVisibleAt holds when each stored record (aligned with streams) becomes visible to the
+	// stream-proxy records endpoint; see SetStreamVisibilityDelay.
+	streamVisibleAt       map[string]map[string][]time.Time
+	streamVisibilityDelay time.Duration
+	// streamPageSize, when positive, pages the stream-proxy records endpoint; see
+	// SetStreamPageSize.
+	streamPageSize int
+
+	// readTableChunkBytes, when positive, makes readTable write its body in flushed chunks of this
+	// size, sleeping readTableChunkDelay between chunks.
+	readTableChunkBytes int
+	readTableChunkDelay time.Duration
+	// readTableHTML, when set, replaces every readTable body; see SetReadTableHTML.
+	readTableHTML string
+
+	// publishedIdempotencyKeys records the idempotency keys of stored single-record publishes, so a
+	// repeat is acknowledged as a duplicate instead of stored again.
+	publishedIdempotencyKeys map[streamPublishKey]bool
+	// lostPublishAcks counts upcoming single-record publishes that store the record but respond 503.
+	lostPublishAcks int
+	// droppedPublishes counts upcoming single-record publishes that are acknowledged but not stored.
+	droppedPublishes int
+}
+
+type streamPublishKey struct {
+	streamRID      string
+	branch         string
+	idempotencyKey string
+}
+
+// LoseNextPublishAcks makes the next n single-record stream publishes store their record and then
+// respond 503, as if the acknowledgement were lost. A client that retries publishes the record
+// again; only publishes carrying a repeated idempotency key are deduplicated.
+func (s *Server) LoseNextPublishAcks(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lostPublishAcks = n
+}
+
+// DropNextPublishes makes the next n single-record stream publishes respond ok without storing their
+// record, as if the stack lost it after acknowledging.
+func (s *Server) DropNextPublishes(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.droppedPublishes = n
+}
+
+// SetStreamVisibilityDelay hides each stream record published from now on from the stream-proxy
+// records endpoint until delay after it was stored, as an eventually consistent stack does.
+// StreamRecords still returns every stored record.
+func (s *Server) SetStreamVisibilityDelay(delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streamVisibilityDelay = delay
+}
+
+// SetStreamPageSize makes the stream-proxy records endpoint answer with the wrapped envelope
+// {"values":[{"record":{..}}, ...], "nextPageToken":"..."} holding at most n records per page. A
+// request's pageSize query parameter asks for smaller pages (or, with n <= 0, for paging at all),
+// and its pageToken parameter names the page to return: an opaque token taken from a previous
+// response, stable for a given page. n <= 0 restores the plain array of every record for requests
+// without pageSize.
+func (s *Server) SetStreamPageSize(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streamPageSize = n
 }
 
 // SetStreamReadTableHeader configures the column projection used when a stream
@@ -67,10 +149,29 @@ func (s *Server) SetStreamReadTableHeader(header []string) {
 	s.streamReadTableHeader = copyNonEmptyStrings(header)
 }
 
+// SetReadTableChunking makes readTable responses stream the CSV body in flushed chunks of
+// bytesPerChunk bytes with delay between chunks, so tests can exercise clients that consume the body
+// incrementally or cancel mid-stream. bytesPerChunk <= 0 restores single-write responses.
+func (s *Server) SetReadTableChunking(bytesPerChunk int, delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readTableChunkBytes = bytesPerChunk
+	s.readTableChunkDelay = delay
+}
+
+// SetReadTableHTML makes readTable answer 200 with page as a text/html body, as an SSO proxy serving
+// its login page to an unauthenticated request does. An empty page restores CSV responses.
+func (s *Server) SetReadTableHTML(page string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readTableHTML = page
+}
+
 type txnState struct {
 	datasetRID string
 	branch     string
 	committed  bool
+	aborted    bool
 
 	txType    string
 	createdAt time.Time
@@ -78,6 +179,29 @@ type txnState struct {
 
 	// files are staged uploads for the transaction keyed by file path.
 	files map[string][]byte
+
+	// snapshot is the tabular content committed by this transaction (checksum sidecars excluded).
+	// readTable serves it when a request pins startTransactionRid/endTransactionRid to this RID.
+	snapshot []byte
+	// viewFiles are the files in the dataset view once this transaction committed, keyed by path.
+	// The files content endpoint serves them when a request pins endTransactionRid to this RID.
+	viewFiles map[string][]byte
+}
+
+// open reports whether the transaction still accepts uploads.
+func (t txnState) open() bool {
+	return !t.committed && !t.aborted
+}
+
+func (t txnState) status() string {
+	switch {
+	case t.committed:
+		return "COMMITTED"
+	case t.aborted:
+		return "ABORTED"
+	default:
+		return "OPEN"
+	}
 }
 
 type datasetBranchKey struct {
@@ -88,20 +212,82 @@ type datasetBranchKey struct {
 type datasetView struct {
 	txnID string
 	csv   []byte
+	// files are the view's files keyed by path, as the files content endpoint serves them.
+	files map[string][]byte
 }
 
 // New constructs a new mock server.
 func New(inputDir, uploadDir string) *Server {
 	return &Server{
-		inputDir:  inputDir,
-		uploadDir: uploadDir,
-		nextTxn:   1,
-		txns:      make(map[string]txnState),
-		heads:     make(map[datasetBranchKey]datasetView),
-		streams:   make(map[string]map[string][]map[string]any),
+		inputDir:    inputDir,
+		uploadDir:   uploadDir,
+		nextTxn:     1,
+		txns:        make(map[string]txnState),
+		heads:       make(map[datasetBranchKey]datasetView),
+		streams:     make(map[string]map[string][]map[string]any),
+		streamKeys:  make(map[string]map[string][]string),
+		writeDenied: make(map[string]bool),
+
+		streamVisibleAt:          make(map[string]map[string][]time.Time),
+		datasetPaths:             make(map[string]string),
+		publishedIdempotencyKeys: make(map[streamPublishKey]bool),
 	}
 }
 
+// SetDatasetPath makes the filesystem getByPath endpoint resolve path to rid.
+func (s *Server) SetDatasetPath(path, rid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.datasetPaths[strings.TrimSpace(path)] = strings.TrimSpace(rid)
+}
+
+// DenyWrites makes every write endpoint for the RID (transaction create/abort/commit, file upload,
+// and stream publish) respond 403, simulating an output the caller cannot write to. Reads still work.
+func (s *Server) DenyWrites(rid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writeDenied[strings.TrimSpace(rid)] = true
+}
+
+// rejectDeniedWrite writes a 403 and returns true when writes to rid are denied.
+func (s *Server) rejectDeniedWrite(w http.ResponseWriter, rid string) bool {
+	s.mu.Lock()
+	denied := s.writeDenied[rid]
+	s.mu.Unlock()
+	if !denied {
+		return false
+	}
+	writeAPIError(w, http.StatusForbidden, "Default:PermissionDenied", "PERMISSION_DENIED", map[string]any{
+		"rid": rid,
+	})
+	return true
+}
+
+// AddOpenTransaction adds an OPEN transaction on the dataset branch and returns its RID. Unlike the
+// create endpoint it never conflicts with a transaction that is already open, so tests can simulate
+// the dangling transactions left behind by crashed runs. Each one is created after every existing
+// transaction, so the last added is the newest.
+func (s *Server) AddOpenTransaction(datasetRID, branch string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	createdAt := time.Now().UTC()
+	for _, t := range s.txns {
+		if !t.createdAt.Before(createdAt) {
+			createdAt = t.createdAt.Add(time.Nanosecond)
+		}
+	}
+	txnID := fmt.Sprintf("ri.foundry.main.transaction.txn-%06d", s.nextTxn)
+	s.nextTxn++
+	s.txns[txnID] = txnState{
+		datasetRID: strings.TrimSpace(datasetRID),
+		branch:     normalizeBranch(branch),
+		txType:     "SNAPSHOT",
+		createdAt:  createdAt,
+		files:      make(map[string][]byte),
+	}
+	return txnID
+}
+
 // CreateStream registers a RID as a stream accessible via the stream-proxy endpoints.
 func (s *Server) CreateStream(streamRID string) {
 	s.mu.Lock()
@@ -140,6 +326,102 @@ func (s *Server) StreamRecords(streamRID, branch string) []map[string]any {
 	return out
 }
 
+// StreamPartitionKeys returns the partition key sent with each record of a stream branch, aligned
+// with StreamRecords. Records published without a key have an empty entry.
+func (s *Server) StreamPartitionKeys(streamRID, branch string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	branch = strings.TrimSpace(branch)
+	if branch == "" {
+		branch = "master"
+	}
+	return append([]string(nil), s.streamKeys[streamRID][branch]...)
+}
+
+// appendStreamRecordsLocked stores records and their partition key. s.mu must be held.
+func (s *Server) appendStreamRecordsLocked(streamRID, branch, partitionKey string, recs ...map[string]any) {
+	if s.streams[streamRID] == nil {
+		s.streams[streamRID] = make(map[string][]map[string]any)
+	}
+	if s.streamKeys[streamRID] == nil {
+		s.streamKeys[streamRID] = make(map[string][]string)
+	}
+	if s.streamVisibleAt[streamRID] == nil {
+		s.streamVisibleAt[streamRID] = make(map[string][]time.Time)
+	}
+	visibleAt := time.Now().Add(s.streamVisibilityDelay)
+	s.streams[streamRID][branch] = append(s.streams[streamRID][branch], recs...)
+	for range recs {
+		s.streamKeys[streamRID][branch] = append(s.streamKeys[streamRID][branch], partitionKey)
+		s.streamVisibleAt[streamRID][branch] = append(s.streamVisibleAt[streamRID][branch], visibleAt)
+	}
+}
+
+// visibleStreamRecords is StreamRecords without the records still hidden by
+// SetStreamVisibilityDelay.
+func (s *Server) visibleStreamRecords(streamRID, branch string) []map[string]any {
+	recs := s.StreamRecords(streamRID, branch)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	visibleAt := s.streamVisibleAt[streamRID][strings.TrimSpace(branch)]
+	now := time.Now()
+	out := recs[:0]
+	for i, rec := range recs {
+		if i < len(visibleAt) && visibleAt[i].After(now) {
+			continue
+		}
+		out = append(out, rec)
+	}
+	return out
+}
+
+// streamPageTokenPrefix marks a mock stream records page token; the token is the base64 of the
+// prefix and the page's record offset.
+const streamPageTokenPrefix = "mockfoundry-stream-offset:"
+
+func encodeStreamPageToken(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(streamPageTokenPrefix + strconv.Itoa(offset)))
+}
+
+func decodeStreamPageToken(tok string) (int, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(tok)
+	if err != nil {
+		return 0, false
+	}
+	v, ok := strings.CutPrefix(string(b), streamPageTokenPrefix)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(v)
+	return n, err == nil && n >= 0
+}
+
+// writeStreamRecordsPage writes the page of recs named by the pageToken query parameter (empty is
+// the first page) in the wrapped envelope shape.
+func writeStreamRecordsPage(w http.ResponseWriter, r *http.Request, recs []map[string]any, pageSize int) {
+	offset := 0
+	if tok := r.URL.Query().Get("pageToken"); tok != "" {
+		n, ok := decodeStreamPageToken(tok)
+		if !ok || n > len(recs) {
+			writeAPIError(w, http.StatusBadRequest, "InvalidArgument", "BAD_REQUEST", map[string]any{"pageToken": tok})
+			return
+		}
+		offset = n
+	}
+	end := min(offset+pageSize, len(recs))
+	values := make([]map[string]any, 0, end-offset)
+	for _, rec := range recs[offset:end] {
+		values = append(values, map[string]any{"record": rec})
+	}
+	body := map[string]any{"values": values}
+	if end < len(recs) {
+		body["nextPageToken"] = encodeStreamPageToken(end)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
 // RequireBearerToken enforces that requests include an Authorization header matching the token.
 // If token is empty, authorization is not enforced.
 func (s *Server) RequireBearerToken(token string) {
@@ -161,6 +443,7 @@ func (s *Server) Handler() http.Handler {
 	mux.HandleFunc("/__debug/uploads", s.handleDebugUploads)
 	mux.HandleFunc("/__debug/streams", s.handleDebugStreams)
 	mux.HandleFunc("/api/v2/datasets/", s.handleV2Datasets)
+	mux.HandleFunc("/api/v2/filesystem/resources/getByPath", s.handleGetResourceByPath)
 	mux.HandleFunc("/stream-proxy/api/streams/", s.handleStreamProxy)
 	return mux
 }
@@ -230,7 +513,7 @@ func (s *Server) Uploads() []Upload {
 func (s *Server) recordCall(r *http.Request) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.calls = append(s.calls, Call{Method: r.Method, Path: r.URL.Path})
+	s.calls = append(s.calls, Call{Method: r.Method, Path: r.URL.Path, UserAgent: r.UserAgent(), RequestID: r.Header.Get("X-Request-Id")})
 }
 
 type apiError struct {
@@ -296,6 +579,7 @@ func (s *Server) handleStreamProxy(w http.ResponseWriter, r *http.Request) {
 
 	// /stream-proxy/api/streams/{rid}/branches/{branch}/records
 	// /stream-proxy/api/streams/{rid}/branches/{branch}/jsonRecord
+	// /stream-proxy/api/streams/{rid}/branches/{branch}/jsonRecords
 	rest := strings.TrimPrefix(r.URL.Path, "/stream-proxy/api/streams/")
 	parts := strings.Split(rest, "/")
 	if len(parts) != 4 || parts[1] != "branches" {
@@ -328,7 +612,24 @@ func (s *Server) handleStreamProxy(w http.ResponseWriter, r *http.Request) {
 			writeAPIError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "METHOD_NOT_ALLOWED", nil)
 			return
 		}
-		recs := s.StreamRecords(streamRID, branch)
+		recs := s.visibleStreamRecords(streamRID, branch)
+		s.mu.Lock()
+		pageSize := s.streamPageSize
+		s.mu.Unlock()
+		if v := r.URL.Query().Get("pageSize"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				writeAPIError(w, http.StatusBadRequest, "InvalidArgument", "BAD_REQUEST", map[string]any{"pageSize": v})
+				return
+			}
+			if pageSize <= 0 || n < pageSize {
+				pageSize = n
+			}
+		}
+		if pageSize > 0 {
+			writeStreamRecordsPage(w, r, recs, pageSize)
+			return
+		}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		_ = json.NewEncoder(w).Encode(recs)
@@ -338,6 +639,9 @@ func (s *Server) handleStreamProxy(w http.ResponseWriter, r *http.Request) {
 			writeAPIError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "METHOD_NOT_ALLOWED", nil)
 			return
 		}
+		if s.rejectDeniedWrite(w, streamRID) {
+			return
+		}
 		b, err := io.ReadAll(r.Body)
 		if err != nil {
 			writeAPIError(w, http.StatusBadRequest, "InvalidArgument", "BAD_REQUEST", map[string]any{"message": "read body"})
@@ -348,11 +652,55 @@ func (s *Server) handleStreamProxy(w http.ResponseWriter, r *http.Request) {
 			writeAPIError(w, http.StatusBadRequest, "InvalidArgument", "BAD_REQUEST", map[string]any{"message": "invalid json"})
 			return
 		}
+		idem := streamPublishKey{streamRID, branch, strings.TrimSpace(r.Header.Get(foundry.IdempotencyKeyHeader))}
+		status := "ok"
 		s.mu.Lock()
-		if s.streams[streamRID] == nil {
-			s.streams[streamRID] = make(map[string][]map[string]any)
+		if idem.idempotencyKey != "" && s.publishedIdempotencyKeys[idem] {
+			status = "duplicate"
+		} else if s.droppedPublishes > 0 {
+			s.droppedPublishes--
+		} else {
+			s.appendStreamRecordsLocked(streamRID, branch, strings.TrimSpace(r.Header.Get(foundry.PartitionKeyHeader)), rec)
+			if idem.idempotencyKey != "" {
+				s.publishedIdempotencyKeys[idem] = true
+			}
+		}
+		loseAck := s.lostPublishAcks > 0
+		if loseAck {
+			s.lostPublishAcks--
 		}
-		s.streams[streamRID][branch] = append(s.streams[streamRID][branch], rec)
+		s.mu.Unlock()
+
+		if loseAck {
+			writeAPIError(w, http.StatusServiceUnavailable, "Default:Internal", "INTERNAL", map[string]any{
+				"message": "publish acknowledgement lost",
+			})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, `{"status":%q}`, status)
+		return
+	case "jsonRecords":
+		if r.Method != http.MethodPost {
+			writeAPIError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "METHOD_NOT_ALLOWED", nil)
+			return
+		}
+		if s.rejectDeniedWrite(w, streamRID) {
+			return
+		}
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "InvalidArgument", "BAD_REQUEST", map[string]any{"message": "read body"})
+			return
+		}
+		var recs []map[string]any
+		if err := json.Unmarshal(b, &recs); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "InvalidArgument", "BAD_REQUEST", map[string]any{"message": "invalid json"})
+			return
+		}
+		s.mu.Lock()
+		s.appendStreamRecordsLocked(streamRID, branch, strings.TrimSpace(r.Header.Get(foundry.PartitionKeyHeader)), recs...)
 		s.mu.Unlock()
 
 		w.Header().Set("Content-Type", "application/json")
@@ -365,6 +713,34 @@ func (s *Server) handleStreamProxy(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Server) handleGetResourceByPath(w http.ResponseWriter, r *http.Request) {
+	s.recordCall(r)
+	if !s.authorize(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	// Mimic the Foundry docs: this endpoint is preview-gated via preview=true.
+	if strings.TrimSpace(r.URL.Query().Get("preview")) != "true" {
+		writeAPIError(w, http.StatusNotFound, "Default:NotFound", "NOT_FOUND", nil)
+		return
+	}
+
+	path := strings.TrimSpace(r.URL.Query().Get("path"))
+	s.mu.Lock()
+	rid, ok := s.datasetPaths[path]
+	s.mu.Unlock()
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "ResourceNotFound", "NOT_FOUND", map[string]any{"path": path})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"rid": rid, "path": path})
+}
+
 func (s *Server) handleV2Datasets(w http.ResponseWriter, r *http.Request) {
 	s.recordCall(r)
 	if !s.authorize(w, r) {
@@ -373,9 +749,12 @@ func (s *Server) handleV2Datasets(w http.ResponseWriter, r *http.Request) {
 
 	// /api/v2/datasets/{rid}/transactions
 	// /api/v2/datasets/{rid}/transactions/{txn}/commit
+	// /api/v2/datasets/{rid}/transactions/{txn}/abort
 	// /api/v2/datasets/{rid}/readTable
+	// /api/v2/datasets/{rid}/branches
 	// /api/v2/datasets/{rid}/branches/{branchName}
 	// /api/v2/datasets/{rid}/files/{filePath...}/upload?transactionRid={txn}
+	// /api/v2/datasets/{rid}/files/{filePath...}/content?branchName={branch}
 	rest := strings.TrimPrefix(r.URL.Path, "/api/v2/datasets/")
 	parts := strings.Split(rest, "/")
 	if len(parts) < 2 {
@@ -395,6 +774,9 @@ func (s *Server) handleV2Datasets(w http.ResponseWriter, r *http.Request) {
 		case http.MethodGet:
 			s.handleListTransactions(w, r, rid)
 		case http.MethodPost:
+			if s.rejectDeniedWrite(w, rid) {
+				return
+			}
 			s.handleCreateTransaction(w, r, rid)
 		default:
 			w.WriteHeader(http.StatusMethodNotAllowed)
@@ -410,10 +792,30 @@ func (s *Server) handleV2Datasets(w http.ResponseWriter, r *http.Request) {
 		if rejectUnsupportedQueryParam(w, r, "branchId") {
 			return
 		}
+		s.mu.Lock()
+		page := s.readTableHTML
+		s.mu.Unlock()
+		if page != "" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = io.WriteString(w, page)
+			return
+		}
 		s.serveReadTableCSV(w, r, rid)
 		return
 	}
 
+	if len(parts) == 2 && parts[1] == "branches" {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if s.rejectDeniedWrite(w, rid) {
+			return
+		}
+		s.handleCreateBranch(w, r, rid)
+		return
+	}
+
 	if len(parts) == 3 && parts[1] == "branches" {
 		if r.Method != http.MethodGet {
 			w.WriteHeader(http.StatusMethodNotAllowed)
@@ -443,6 +845,15 @@ func (s *Server) handleV2Datasets(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if len(parts) >= 4 && parts[1] == "files" && parts[len(parts)-1] == "content" {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		s.serveFileContent(w, r, rid, strings.Join(parts[2:len(parts)-1], "/"))
+		return
+	}
+
 	if len(parts) >= 4 && parts[1] == "files" && parts[len(parts)-1] == "upload" {
 		if r.Method != http.MethodPost {
 			w.WriteHeader(http.StatusMethodNotAllowed)
@@ -465,11 +876,14 @@ func (s *Server) handleV2Datasets(w http.ResponseWriter, r *http.Request) {
 			})
 			return
 		}
+		if s.rejectDeniedWrite(w, rid) {
+			return
+		}
 		s.handleUpload(w, r, rid, txnID, filePath)
 		return
 	}
 
-	if len(parts) == 4 && parts[1] == "transactions" && parts[3] == "commit" {
+	if len(parts) == 4 && parts[1] == "transactions" && (parts[3] == "commit" || parts[3] == "abort") {
 		if r.Method != http.MethodPost {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
@@ -481,6 +895,13 @@ func (s *Server) handleV2Datasets(w http.ResponseWriter, r *http.Request) {
 			})
 			return
 		}
+		if s.rejectDeniedWrite(w, rid) {
+			return
+		}
+		if parts[3] == "abort" {
+			s.handleAbort(w, rid, txnID)
+			return
+		}
 		s.handleCommit(w, r, rid, txnID)
 		return
 	}
@@ -488,6 +909,49 @@ func (s *Server) handleV2Datasets(w http.ResponseWriter, r *http.Request) {
 	http.NotFound(w, r)
 }
 
+// handleCreateBranch creates a branch whose head is a committed transaction of the dataset, as
+// the v2 create-branch endpoint does. Only branches with a committed head count as existing.
+func (s *Server) handleCreateBranch(w http.ResponseWriter, r *http.Request, datasetRID string) {
+	var req struct {
+		Name           string `json:"name"`
+		TransactionRID string `json:"transactionRid"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		writeAPIError(w, http.StatusBadRequest, "Conjure:InvalidArgument", "INVALID_ARGUMENT", map[string]any{
+			"name": req.Name,
+		})
+		return
+	}
+	name := normalizeBranch(req.Name)
+	txnID := strings.TrimSpace(req.TransactionRID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := datasetBranchKey{datasetRID: datasetRID, branch: name}
+	if _, ok := s.heads[key]; ok {
+		writeAPIError(w, http.StatusConflict, "BranchAlreadyExists", "CONFLICT", map[string]any{
+			"datasetRid": datasetRID,
+			"branchName": name,
+		})
+		return
+	}
+	txn, ok := s.txns[txnID]
+	if !ok || txn.datasetRID != datasetRID || !txn.committed {
+		writeAPIError(w, http.StatusNotFound, "TransactionNotFound", "NOT_FOUND", map[string]any{
+			"datasetRid":     datasetRID,
+			"transactionRid": txnID,
+		})
+		return
+	}
+	s.heads[key] = datasetView{txnID: txnID, csv: append([]byte(nil), txn.snapshot...), files: txn.viewFiles}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"name":           name,
+		"transactionRid": txnID,
+	})
+}
+
 func (s *Server) serveReadTableCSV(w http.ResponseWriter, r *http.Request, datasetRID string) {
 	// Streaming datasets are append-only and written via stream-proxy. In Foundry, they are still
 	// queryable/tabular. For local harnesses, expose a CSV view of the accumulated stream records so
@@ -530,8 +994,7 @@ func (s *Server) serveReadTableCSV(w http.ResponseWriter, r *http.Request, datas
 			return
 		}
 
-		w.Header().Set("Content-Type", "text/csv")
-		_, _ = w.Write(buf.Bytes())
+		s.writeReadTableBody(w, r, buf.Bytes())
 		return
 	}
 
@@ -539,8 +1002,7 @@ func (s *Server) serveReadTableCSV(w http.ResponseWriter, r *http.Request, datas
 	startTxn := strings.TrimSpace(r.URL.Query().Get("startTransactionRid"))
 	endTxn := strings.TrimSpace(r.URL.Query().Get("endTransactionRid"))
 	if b, ok := s.datasetViewCSV(datasetRID, branch, startTxn, endTxn); ok {
-		w.Header().Set("Content-Type", "text/csv")
-		_, _ = w.Write(b)
+		s.writeReadTableBody(w, r, b)
 		return
 	}
 
@@ -552,6 +1014,67 @@ func (s *Server) serveReadTableCSV(w http.ResponseWriter, r *http.Request, datas
 	})
 }
 
+// serveFileContent serves the raw bytes of a committed file in the branch view, or in the view as
+// of endTransactionRid when set. Unlike readTable it serves any file, not just the tabular one.
+func (s *Server) serveFileContent(w http.ResponseWriter, r *http.Request, datasetRID, filePath string) {
+	branch := branchFromReadTableQuery(r)
+	endTxn := strings.TrimSpace(r.URL.Query().Get("endTransactionRid"))
+	s.mu.Lock()
+	var files map[string][]byte
+	if endTxn != "" {
+		if txn, ok := s.txns[endTxn]; ok && txn.datasetRID == datasetRID && txn.committed {
+			files = txn.viewFiles
+		}
+	} else {
+		files = s.heads[datasetBranchKey{datasetRID: datasetRID, branch: branch}].files
+	}
+	b, ok := files[filePath]
+	b = append([]byte(nil), b...)
+	s.mu.Unlock()
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "FileNotFound", "NOT_FOUND", map[string]any{
+			"datasetRid":        datasetRID,
+			"filePath":          filePath,
+			"branchName":        branch,
+			"endTransactionRid": endTxn,
+		})
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(b)
+}
+
+// writeReadTableBody writes a readTable CSV body, in flushed chunks when SetReadTableChunking is set.
+// A chunked write stops early once the client goes away.
+func (s *Server) writeReadTableBody(w http.ResponseWriter, r *http.Request, b []byte) {
+	s.mu.Lock()
+	chunk, delay := s.readTableChunkBytes, s.readTableChunkDelay
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/csv")
+	flusher, ok := w.(http.Flusher)
+	if chunk <= 0 || !ok {
+		_, _ = w.Write(b)
+		return
+	}
+	for len(b) > 0 {
+		n := min(chunk, len(b))
+		if _, err := w.Write(b[:n]); err != nil {
+			return
+		}
+		flusher.Flush()
+		b = b[n:]
+		if len(b) == 0 {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
 func (s *Server) datasetViewCSV(datasetRID, branch, startTxn, endTxn string) ([]byte, bool) {
 	branch = normalizeBranch(branch)
 	startTxn = strings.TrimSpace(startTxn)
@@ -598,10 +1121,14 @@ func (s *Server) committedTransactionCSV(datasetRID, branch, txnID string) ([]by
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	txn, ok := s.txns[txnID]
-	if !ok || txn.datasetRID != datasetRID || normalizeBranch(txn.branch) != branch || !txn.committed {
+	if !ok || txn.datasetRID != datasetRID || !txn.committed || len(txn.snapshot) == 0 {
+		return nil, false
+	}
+	// A branch created at the transaction (see handleCreateBranch) serves it too.
+	if normalizeBranch(txn.branch) != branch && s.heads[datasetBranchKey{datasetRID: datasetRID, branch: branch}].txnID != txnID {
 		return nil, false
 	}
-	return singleTransactionFile(txn)
+	return append([]byte(nil), txn.snapshot...), true
 }
 
 func (s *Server) branchHeadCSV(datasetRID, branch string) ([]byte, bool) {
@@ -627,16 +1154,6 @@ func (s *Server) seedDatasetCSV(datasetRID string) ([]byte, bool) {
 	return readNonEmptyFile(filepath.Join(s.inputDir, datasetRID+".csv"))
 }
 
-func singleTransactionFile(txn txnState) ([]byte, bool) {
-	if len(txn.files) != 1 {
-		return nil, false
-	}
-	for _, b := range txn.files {
-		return append([]byte(nil), b...), true
-	}
-	return nil, false
-}
-
 func readNonEmptyFile(p string) ([]byte, bool) {
 	b, err := os.ReadFile(p)
 	if err != nil || len(b) == 0 {
@@ -699,10 +1216,7 @@ func (s *Server) handleListTransactions(w http.ResponseWriter, r *http.Request,
 			s := st.closedAt.UTC().Format(time.RFC3339Nano)
 			closedTime = &s
 		}
-		status := "OPEN"
-		if st.committed {
-			status = "COMMITTED"
-		}
+		status := st.status()
 		items = append(items, item{
 			resp: transactionResp{
 				RID:             txnID,
@@ -771,7 +1285,7 @@ func (s *Server) handleCreateTransaction(w http.ResponseWriter, r *http.Request,
 	}
 
 	for _, t := range s.txns {
-		if t.datasetRID == datasetRID && !t.committed && strings.TrimSpace(t.branch) == branch {
+		if t.datasetRID == datasetRID && t.open() && strings.TrimSpace(t.branch) == branch {
 			s.mu.Unlock()
 			writeAPIError(w, http.StatusConflict, "OpenTransactionAlreadyExists", "CONFLICT", map[string]any{
 				"datasetRid": datasetRID,
@@ -830,11 +1344,11 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request, datasetRID
 		})
 		return
 	}
-	if txn.committed {
+	if !txn.open() {
 		writeAPIError(w, http.StatusBadRequest, "TransactionNotOpen", "INVALID_ARGUMENT", map[string]any{
 			"datasetRid":        datasetRID,
 			"transactionRid":    txnID,
-			"transactionStatus": "COMMITTED",
+			"transactionStatus": txn.status(),
 		})
 		return
 	}
@@ -872,12 +1386,12 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request, datasetRID
 		})
 		return
 	}
-	if txn.committed {
+	if !txn.open() {
 		s.mu.Unlock()
 		writeAPIError(w, http.StatusBadRequest, "TransactionNotOpen", "INVALID_ARGUMENT", map[string]any{
 			"datasetRid":        datasetRID,
 			"transactionRid":    txnID,
-			"transactionStatus": "COMMITTED",
+			"transactionStatus": txn.status(),
 		})
 		return
 	}
@@ -888,10 +1402,11 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request, datasetRID
 	s.txns[txnID] = txn
 
 	s.uploads = append(s.uploads, Upload{
-		DatasetRID: datasetRID,
-		TxnID:      txnID,
-		FilePath:   filePath,
-		Bytes:      b,
+		DatasetRID:  datasetRID,
+		TxnID:       txnID,
+		FilePath:    filePath,
+		ContentType: r.Header.Get("Content-Type"),
+		Bytes:       b,
 	})
 	s.mu.Unlock()
 
@@ -927,12 +1442,12 @@ func (s *Server) handleCommit(w http.ResponseWriter, _ *http.Request, datasetRID
 		})
 		return
 	}
-	if txn.committed {
+	if !txn.open() {
 		s.mu.Unlock()
 		writeAPIError(w, http.StatusBadRequest, "TransactionNotOpen", "INVALID_ARGUMENT", map[string]any{
 			"datasetRid":        datasetRID,
 			"transactionRid":    txnID,
-			"transactionStatus": "COMMITTED",
+			"transactionStatus": txn.status(),
 		})
 		return
 	}
@@ -945,7 +1460,14 @@ func (s *Server) handleCommit(w http.ResponseWriter, _ *http.Request, datasetRID
 		})
 		return
 	}
-	if len(txn.files) != 1 {
+	// Checksum sidecars (see foundryio.ChecksumSidecar) may accompany the single tabular file.
+	var tabular [][]byte
+	for p, b := range txn.files {
+		if !strings.HasSuffix(p, ".sha256") {
+			tabular = append(tabular, b)
+		}
+	}
+	if len(tabular) != 1 {
 		s.mu.Unlock()
 		writeAPIError(w, http.StatusBadRequest, "Conjure:InvalidArgument", "INVALID_ARGUMENT", map[string]any{
 			"message":        "transaction has multiple uploaded files",
@@ -954,16 +1476,44 @@ func (s *Server) handleCommit(w http.ResponseWriter, _ *http.Request, datasetRID
 		})
 		return
 	}
-
-	var head []byte
-	for _, b := range txn.files {
-		head = append([]byte(nil), b...)
-		break
+	branch := normalizeBranch(txn.branch)
+	head := append([]byte(nil), tabular[0]...)
+	viewFiles := maps.Clone(txn.files)
+	if txn.txType == "APPEND" {
+		// An APPEND transaction adds its file to the branch's current view.
+		if prev, ok := s.heads[datasetBranchKey{datasetRID: datasetRID, branch: branch}]; ok {
+			// Foundry rejects an APPEND that would modify a file already in the view.
+			for _, p := range slices.Sorted(maps.Keys(txn.files)) {
+				if _, exists := prev.files[p]; exists {
+					s.mu.Unlock()
+					writeAPIError(w, http.StatusBadRequest, "Conjure:InvalidArgument", "INVALID_ARGUMENT", map[string]any{
+						"message":        fmt.Sprintf("APPEND transaction cannot modify existing file %q", p),
+						"datasetRid":     datasetRID,
+						"transactionRid": txnID,
+					})
+					return
+				}
+			}
+			viewFiles = maps.Clone(prev.files)
+			if viewFiles == nil {
+				viewFiles = make(map[string][]byte, len(txn.files))
+			}
+			maps.Copy(viewFiles, txn.files)
+			merged, err := appendCSV(prev.csv, head)
+			if err != nil {
+				s.mu.Unlock()
+				writeAPIError(w, http.StatusBadRequest, "Conjure:InvalidArgument", "INVALID_ARGUMENT", map[string]any{
+					"message":        err.Error(),
+					"datasetRid":     datasetRID,
+					"transactionRid": txnID,
+				})
+				return
+			}
+			head = merged
+		}
 	}
 	s.mu.Unlock()
 
-	branch := normalizeBranch(txn.branch)
-
 	// Persist a branch-scoped "dataset head" so downstream consumers can read the
 	// committed state via readTable without cross-branch leakage.
 	committedPath := s.committedTablePath(datasetRID, branch)
@@ -991,22 +1541,25 @@ func (s *Server) handleCommit(w http.ResponseWriter, _ *http.Request, datasetRID
 		})
 		return
 	}
-	if txn.committed {
+	if !txn.open() {
 		s.mu.Unlock()
 		writeAPIError(w, http.StatusBadRequest, "TransactionNotOpen", "INVALID_ARGUMENT", map[string]any{
 			"datasetRid":        datasetRID,
 			"transactionRid":    txnID,
-			"transactionStatus": "COMMITTED",
+			"transactionStatus": txn.status(),
 		})
 		return
 	}
 	closedAt := time.Now().UTC()
 	txn.committed = true
 	txn.closedAt = &closedAt
+	txn.snapshot = append([]byte(nil), head...)
+	txn.viewFiles = viewFiles
 	s.txns[txnID] = txn
 	s.heads[datasetBranchKey{datasetRID: datasetRID, branch: branch}] = datasetView{
 		txnID: txnID,
 		csv:   append([]byte(nil), head...),
+		files: viewFiles,
 	}
 	s.mu.Unlock()
 
@@ -1027,6 +1580,68 @@ func (s *Server) handleCommit(w http.ResponseWriter, _ *http.Request, datasetRID
 	})
 }
 
+func (s *Server) handleAbort(w http.ResponseWriter, datasetRID string, txnID string) {
+	s.mu.Lock()
+	txn, ok := s.txns[txnID]
+	if !ok || txn.datasetRID != datasetRID {
+		s.mu.Unlock()
+		writeAPIError(w, http.StatusNotFound, "TransactionNotFound", "NOT_FOUND", map[string]any{
+			"datasetRid":     datasetRID,
+			"transactionRid": txnID,
+		})
+		return
+	}
+	if !txn.open() {
+		s.mu.Unlock()
+		writeAPIError(w, http.StatusBadRequest, "TransactionNotOpen", "INVALID_ARGUMENT", map[string]any{
+			"datasetRid":        datasetRID,
+			"transactionRid":    txnID,
+			"transactionStatus": txn.status(),
+		})
+		return
+	}
+	closedAt := time.Now().UTC()
+	txn.aborted = true
+	txn.closedAt = &closedAt
+	txn.files = nil
+	s.txns[txnID] = txn
+	s.mu.Unlock()
+
+	closedTime := closedAt.Format(time.RFC3339Nano)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(transactionResp{
+		RID:             txnID,
+		BranchName:      normalizeBranch(txn.branch),
+		TransactionType: txn.txType,
+		Status:          "ABORTED",
+		CreatedTime:     txn.createdAt.UTC().Format(time.RFC3339Nano),
+		ClosedTime:      &closedTime,
+	})
+}
+
+// appendCSV returns the rows of next appended to view. Both must start with the same header line,
+// which is kept once, as readTable serves an appended dataset as a single table. JSONL files have no
+// header and are concatenated.
+func appendCSV(view, next []byte) ([]byte, error) {
+	if bytes.HasPrefix(next, []byte("{")) {
+		out := append([]byte(nil), view...)
+		if len(out) > 0 && out[len(out)-1] != '\n' {
+			out = append(out, '\n')
+		}
+		return append(out, next...), nil
+	}
+	viewHeader, _, _ := bytes.Cut(view, []byte("\n"))
+	nextHeader, rows, _ := bytes.Cut(next, []byte("\n"))
+	if !bytes.Equal(bytes.TrimSuffix(viewHeader, []byte("\r")), bytes.TrimSuffix(nextHeader, []byte("\r"))) {
+		return nil, fmt.Errorf("appended file header %q does not match the dataset header %q", nextHeader, viewHeader)
+	}
+	out := append([]byte(nil), view...)
+	if len(out) > 0 && out[len(out)-1] != '\n' {
+		out = append(out, '\n')
+	}
+	return append(out, rows...), nil
+}
+
 func (s *Server) committedTablePath(datasetRID, branch string) string {
 	// Keep this stable and human-inspectable for local harness use.
 	return filepath.Join(s.uploadDir, datasetRID, "_branches", filesystemName(normalizeBranch(branch)), "_committed", "readTable.csv")
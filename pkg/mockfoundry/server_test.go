@@ -5,13 +5,18 @@ import (
 	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/palantir/palantir-compute-module-pipeline-search/examples/email_enricher/pipeline"
+	"github.com/palantir/palantir-compute-module-pipeline-search/internal/version"
 	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
 	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/mockfoundry"
 )
@@ -143,6 +148,66 @@ func TestMockFoundry_ReadTableCanPinExactCommittedTransaction(t *testing.T) {
 	}
 }
 
+func TestMockFoundry_ReadTableServesEachCommittedVersionByTransaction(t *testing.T) {
+	t.Parallel()
+
+	srv := mockfoundry.New(t.TempDir(), t.TempDir())
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("new foundry client: %v", err)
+	}
+
+	ctx := context.Background()
+	datasetRID := "ri.foundry.main.dataset.56565656-5656-5656-5656-565656565656"
+
+	v1 := []byte("email\nalice@example.com\n")
+	txn1 := createUploadCommit(t, ctx, client, datasetRID, "master", "enriched.csv", v1)
+
+	// The second version carries a checksum sidecar; the pinned view is still the tabular file.
+	v2 := []byte("email\nalice@example.com\nbob@corp.test\n")
+	txn2, err := client.CreateTransaction(ctx, datasetRID, "master")
+	if err != nil {
+		t.Fatalf("create transaction: %v", err)
+	}
+	if err := client.UploadFile(ctx, datasetRID, txn2, "enriched.csv", "text/csv", v2); err != nil {
+		t.Fatalf("upload file: %v", err)
+	}
+	if err := client.UploadFile(ctx, datasetRID, txn2, "enriched.csv.sha256", "text/plain", []byte("deadbeef\n")); err != nil {
+		t.Fatalf("upload sidecar: %v", err)
+	}
+	if err := client.CommitTransaction(ctx, datasetRID, txn2); err != nil {
+		t.Fatalf("commit transaction: %v", err)
+	}
+
+	for _, tc := range []struct {
+		txn  string
+		want []byte
+	}{{txn1, v1}, {txn2, v2}} {
+		got, err := client.ReadTableCSVAtTransaction(ctx, datasetRID, "master", tc.txn)
+		if err != nil {
+			t.Fatalf("read at %s: %v", tc.txn, err)
+		}
+		if !bytes.Equal(got, tc.want) {
+			t.Fatalf("read at %s mismatch:\n--- got ---\n%s\n--- want ---\n%s\n", tc.txn, got, tc.want)
+		}
+	}
+
+	head, err := client.ReadTableCSV(ctx, datasetRID, "master")
+	if err != nil {
+		t.Fatalf("read head: %v", err)
+	}
+	if !bytes.Equal(head, v2) {
+		t.Fatalf("head mismatch:\n--- got ---\n%s\n--- want ---\n%s\n", head, v2)
+	}
+
+	if _, err := client.ReadTableCSVAtTransaction(ctx, datasetRID, "master", "ri.foundry.main.transaction.unknown"); err == nil {
+		t.Fatalf("expected unknown transaction read to fail")
+	}
+}
+
 func TestMockFoundry_OpenTransactionsDoNotAdvanceBranchView(t *testing.T) {
 	t.Parallel()
 
@@ -417,6 +482,85 @@ func TestMockFoundry_StreamReadTableUsesConfiguredHeader(t *testing.T) {
 	}
 }
 
+func TestMockFoundry_StreamRecordsPagination(t *testing.T) {
+	t.Parallel()
+
+	srv := mockfoundry.New(t.TempDir(), t.TempDir())
+	rid := "ri.foundry.main.dataset.bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb"
+	srv.CreateStream(rid)
+	srv.SetStreamPageSize(2)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("new foundry client: %v", err)
+	}
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if err := client.PublishStreamJSONRecord(context.Background(), rid, "master", map[string]any{"email": email}); err != nil {
+			t.Fatalf("publish stream record: %v", err)
+		}
+	}
+
+	type page struct {
+		Values []struct {
+			Record map[string]any `json:"record"`
+		} `json:"values"`
+		NextPageToken string `json:"nextPageToken"`
+	}
+	get := func(query string) (page, int) {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/stream-proxy/api/streams/" + rid + "/branches/master/records" + query)
+		if err != nil {
+			t.Fatalf("read stream records: %v", err)
+		}
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		var p page
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+				t.Fatalf("decode page: %v", err)
+			}
+		}
+		return p, resp.StatusCode
+	}
+	emails := func(p page) []string {
+		var out []string
+		for _, v := range p.Values {
+			email, _ := v.Record["email"].(string)
+			out = append(out, email)
+		}
+		return out
+	}
+
+	first, status := get("")
+	if status != http.StatusOK || !slices.Equal(emails(first), []string{"a@example.com", "b@example.com"}) || first.NextPageToken == "" {
+		t.Fatalf("first page: status=%d emails=%v token=%q", status, emails(first), first.NextPageToken)
+	}
+	if again, _ := get(""); again.NextPageToken != first.NextPageToken {
+		t.Fatalf("expected a stable token, got %q then %q", first.NextPageToken, again.NextPageToken)
+	}
+	if _, err := strconv.Atoi(first.NextPageToken); err == nil {
+		t.Fatalf("expected an opaque token, got %q", first.NextPageToken)
+	}
+	second, status := get("?pageToken=" + first.NextPageToken)
+	if status != http.StatusOK || !slices.Equal(emails(second), []string{"c@example.com"}) || second.NextPageToken != "" {
+		t.Fatalf("second page: status=%d emails=%v token=%q", status, emails(second), second.NextPageToken)
+	}
+
+	small, _ := get("?pageSize=1")
+	if !slices.Equal(emails(small), []string{"a@example.com"}) || small.NextPageToken == "" {
+		t.Fatalf("pageSize=1: emails=%v token=%q", emails(small), small.NextPageToken)
+	}
+	if large, _ := get("?pageSize=10"); len(large.Values) != 2 {
+		t.Fatalf("expected pageSize above the server's to be capped at 2, got %d records", len(large.Values))
+	}
+	if _, status := get("?pageToken=bogus"); status != http.StatusBadRequest {
+		t.Fatalf("invalid pageToken: status=%d want 400", status)
+	}
+}
+
 func TestMockFoundry_RejectUploadDatasetMismatch(t *testing.T) {
 	t.Parallel()
 
@@ -482,6 +626,47 @@ func TestMockFoundry_RejectCommitWithoutUpload(t *testing.T) {
 	}
 }
 
+func TestMockFoundry_RejectAppendModifyingExistingFile(t *testing.T) {
+	t.Parallel()
+
+	srv := mockfoundry.New(t.TempDir(), t.TempDir())
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("new foundry client: %v", err)
+	}
+
+	ctx := context.Background()
+	rid := "ri.foundry.main.dataset.cdcdcdcd-cdcd-cdcd-cdcd-cdcdcdcdcdcd"
+	commit := func(txType, path string) error {
+		txnID, err := client.CreateTransactionOfType(ctx, rid, "master", txType)
+		if err != nil {
+			t.Fatalf("create %s transaction: %v", txType, err)
+		}
+		if err := client.UploadFile(ctx, rid, txnID, path, "text/csv", []byte("email\nalice@example.com\n")); err != nil {
+			t.Fatalf("upload %s: %v", path, err)
+		}
+		if err := client.CommitTransaction(ctx, rid, txnID); err != nil {
+			_ = client.AbortTransaction(ctx, rid, txnID)
+			return err
+		}
+		return nil
+	}
+
+	if err := commit(foundry.TransactionTypeSnapshot, "enriched.csv"); err != nil {
+		t.Fatalf("commit snapshot: %v", err)
+	}
+	err = commit(foundry.TransactionTypeAppend, "enriched.csv")
+	if err == nil || !strings.Contains(err.Error(), "errorName=Conjure:InvalidArgument") {
+		t.Fatalf("expected an APPEND over an existing file to be rejected, got %v", err)
+	}
+	if err := commit(foundry.TransactionTypeAppend, "enriched-2.csv"); err != nil {
+		t.Fatalf("expected an APPEND of a new file to commit, got %v", err)
+	}
+}
+
 func TestMockFoundry_RejectCommitMultipleFiles(t *testing.T) {
 	t.Parallel()
 
@@ -520,3 +705,214 @@ func TestMockFoundry_RejectCommitMultipleFiles(t *testing.T) {
 		t.Fatalf("expected InvalidArgument error, got: %v", err)
 	}
 }
+
+func TestMockFoundry_AbortClosesTransactionAndFreesBranch(t *testing.T) {
+	t.Parallel()
+
+	srv := mockfoundry.New(t.TempDir(), t.TempDir())
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("new foundry client: %v", err)
+	}
+
+	ctx := context.Background()
+	datasetRID := "ri.foundry.main.dataset.67676767-6767-6767-6767-676767676767"
+
+	probeTxn, err := client.CreateTransaction(ctx, datasetRID, "master")
+	if err != nil {
+		t.Fatalf("create probe transaction: %v", err)
+	}
+	if err := client.AbortTransaction(ctx, datasetRID, probeTxn); err != nil {
+		t.Fatalf("abort transaction: %v", err)
+	}
+	if _, ok, err := client.FindLatestOpenTransactionForBranch(ctx, datasetRID, "master"); err != nil || ok {
+		t.Fatalf("expected no OPEN transaction after abort, got ok=%t err=%v", ok, err)
+	}
+	if err := client.UploadFile(ctx, datasetRID, probeTxn, "enriched.csv", "text/csv", []byte("email\n")); err == nil {
+		t.Fatalf("expected upload to an aborted transaction to fail")
+	}
+
+	createUploadCommit(t, ctx, client, datasetRID, "master", "enriched.csv", []byte("email\nalice@example.com\n"))
+}
+
+func TestMockFoundry_DenyWritesRejectsWritesButAllowsReads(t *testing.T) {
+	t.Parallel()
+
+	srv := mockfoundry.New(t.TempDir(), t.TempDir())
+	streamRID := "ri.foundry.main.dataset.78787878-7878-7878-7878-787878787878"
+	srv.CreateStream(streamRID)
+	srv.DenyWrites(streamRID)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("new foundry client: %v", err)
+	}
+
+	ctx := context.Background()
+	if _, err := client.ReadStreamRecords(ctx, streamRID, "master"); err != nil {
+		t.Fatalf("expected reads to succeed, got %v", err)
+	}
+	err = client.ProbeStreamPublish(ctx, streamRID, "master")
+	var he *foundry.HTTPError
+	if !errors.As(err, &he) || he.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 from publish probe, got %v", err)
+	}
+}
+
+func TestMockFoundry_RecordsClientUserAgent(t *testing.T) {
+	t.Parallel()
+
+	srv := mockfoundry.New(t.TempDir(), t.TempDir())
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("new foundry client: %v", err)
+	}
+	datasetRID := "ri.foundry.main.dataset.89898989-8989-8989-8989-898989898989"
+	if _, err := client.CreateTransaction(context.Background(), datasetRID, "master"); err != nil {
+		t.Fatalf("create transaction: %v", err)
+	}
+
+	calls := srv.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 call, got %#v", calls)
+	}
+	want := "palantir-compute-module-pipeline/" + version.Version + " (enricher)"
+	if calls[0].UserAgent != want {
+		t.Fatalf("User-Agent: want %q, got %q", want, calls[0].UserAgent)
+	}
+}
+
+func TestMockFoundry_RecordsClientRequestIDs(t *testing.T) {
+	t.Parallel()
+
+	srv := mockfoundry.New(t.TempDir(), t.TempDir())
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("new foundry client: %v", err)
+	}
+	datasetRID := "ri.foundry.main.dataset.90909090-9090-9090-9090-909090909090"
+	if _, err := client.CreateTransaction(context.Background(), datasetRID, "master"); err != nil {
+		t.Fatalf("create transaction: %v", err)
+	}
+	// The second create conflicts with the open transaction.
+	_, err = client.CreateTransaction(context.Background(), datasetRID, "master")
+	var he *foundry.HTTPError
+	if !errors.As(err, &he) {
+		t.Fatalf("expected an HTTPError, got %v", err)
+	}
+
+	calls := srv.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 calls, got %#v", calls)
+	}
+	first, second := calls[0].RequestID, calls[1].RequestID
+	if first == "" || second == "" || first == second {
+		t.Fatalf("expected a distinct request id per call, got %q and %q", first, second)
+	}
+	if he.RequestID != second || !strings.Contains(err.Error(), "requestId="+second) {
+		t.Fatalf("expected the error to carry request id %q, got %q (%v)", second, he.RequestID, err)
+	}
+	if got := client.LastRequestID(); got != second {
+		t.Fatalf("LastRequestID: want %q, got %q", second, got)
+	}
+}
+
+func TestMockFoundry_ReadTableChunkingStreamsAndSupportsCancellation(t *testing.T) {
+	t.Parallel()
+
+	srv := mockfoundry.New(t.TempDir(), t.TempDir())
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("new foundry client: %v", err)
+	}
+	ctx := context.Background()
+	datasetRID := "ri.foundry.main.dataset.99999999-9999-9999-9999-999999999999"
+	txnID, err := client.CreateTransaction(ctx, datasetRID, "")
+	if err != nil {
+		t.Fatalf("create transaction: %v", err)
+	}
+	want := []byte("email\nalice@example.com\nbob@corp.test\ncarol@new.test\n")
+	if err := client.UploadFile(ctx, datasetRID, txnID, "enriched.csv", "text/csv", want); err != nil {
+		t.Fatalf("upload file: %v", err)
+	}
+	if err := client.CommitTransaction(ctx, datasetRID, txnID); err != nil {
+		t.Fatalf("commit transaction: %v", err)
+	}
+
+	srv.SetReadTableChunking(8, 5*time.Millisecond)
+	got, err := client.ReadTableCSV(ctx, datasetRID, "")
+	if err != nil {
+		t.Fatalf("readTable: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("chunked readTable output mismatch:\n--- got ---\n%s\n--- want ---\n%s\n", got, want)
+	}
+
+	srv.SetReadTableChunking(8, time.Hour)
+	readCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	body, err := client.OpenTableCSV(readCtx, datasetRID, "")
+	if err != nil {
+		t.Fatalf("open readTable: %v", err)
+	}
+	defer func() {
+		_ = body.Close()
+	}()
+	first := make([]byte, len(want))
+	n, err := body.Read(first)
+	if err != nil || n == 0 || n >= len(want) {
+		t.Fatalf("expected a partial first chunk, got n=%d err=%v", n, err)
+	}
+	if !bytes.HasPrefix(want, first[:n]) {
+		t.Fatalf("unexpected first chunk %q", first[:n])
+	}
+
+	cancel()
+	if _, err := io.ReadAll(body); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled after mid-stream cancellation, got %v", err)
+	}
+}
+
+func TestMockFoundry_ResolveDatasetRIDByPath(t *testing.T) {
+	t.Parallel()
+
+	srv := mockfoundry.New(t.TempDir(), t.TempDir())
+	datasetRID := "ri.foundry.main.dataset.13131313-1313-1313-1313-131313131313"
+	srv.SetDatasetPath("/Org/Project/emails", datasetRID)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("new foundry client: %v", err)
+	}
+
+	ctx := context.Background()
+	got, err := client.ResolveDatasetRID(ctx, " /Org/Project/emails ")
+	if err != nil {
+		t.Fatalf("ResolveDatasetRID: %v", err)
+	}
+	if got != datasetRID {
+		t.Fatalf("resolved rid=%q want %q", got, datasetRID)
+	}
+
+	_, err = client.ResolveDatasetRID(ctx, "/Org/Project/missing")
+	var he *foundry.HTTPError
+	if !errors.As(err, &he) || he.StatusCode != http.StatusNotFound || he.ErrorName != "ResourceNotFound" {
+		t.Fatalf("expected ResourceNotFound for unknown path, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"time"
 )
 
 // InputAdapter loads input records for pipeline processing.
@@ -29,6 +30,10 @@ func (f ProcessFunc[In, Out]) Process(ctx context.Context, in In) (Out, error) {
 // TransientError marks an error as retryable by worker implementations.
 type TransientError struct {
 	Err error
+
+	// RetryAfter optionally carries a provider-requested cooldown (for example from a 429).
+	// Workers pause the whole pool for this long rather than only retrying this item later.
+	RetryAfter time.Duration
 }
 
 func (e *TransientError) Error() string {
@@ -45,6 +50,14 @@ func (e *TransientError) Unwrap() error {
 	return e.Err
 }
 
+// RetryAfterDuration returns the requested cooldown, or 0 when none was given.
+func (e *TransientError) RetryAfterDuration() time.Duration {
+	if e == nil {
+		return 0
+	}
+	return e.RetryAfter
+}
+
 // LimitedTransientError marks an error as retryable, but caps extra retries.
 //
 // ExtraRetries is the number of retries after the first failed attempt.
@@ -3,6 +3,8 @@ package foundryio
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"strings"
@@ -15,20 +17,145 @@ const (
 	OutputModeAuto    = "auto"
 	OutputModeDataset = "dataset"
 	OutputModeStream  = "stream"
+
+	// OutputModeBoth writes each row to a stream as it completes and the full output to a dataset
+	// at the end. It is resolved by the caller; ResolveOutputMode only handles single-target modes.
+	OutputModeBoth = "both"
 )
 
 // ReadInputEmails reads input rows from a Foundry dataset and extracts the email column.
 func ReadInputEmails(ctx context.Context, client *foundry.Client, inputRef foundry.DatasetRef) ([]string, error) {
+	return ReadInputEmailsWithLimits(ctx, client, inputRef, localio.CSVLimits{})
+}
+
+// ReadInputEmailsWithLimits is ReadInputEmails with explicit CSV field and record size limits.
+func ReadInputEmailsWithLimits(ctx context.Context, client *foundry.Client, inputRef foundry.DatasetRef, limits localio.CSVLimits) ([]string, error) {
+	return ReadInputEmailsWithPolicy(ctx, client, inputRef, localio.CSVReadOptions{CSVLimits: limits}, ReadRetryPolicy{})
+}
+
+// ReadInputEmailsWithPolicy is ReadInputEmails with explicit CSV read options and read retry
+// policy.
+func ReadInputEmailsWithPolicy(
+	ctx context.Context,
+	client *foundry.Client,
+	inputRef foundry.DatasetRef,
+	opts localio.CSVReadOptions,
+	policy ReadRetryPolicy,
+) ([]string, error) {
+	return ReadInputColumnWithPolicy(ctx, client, inputRef, localio.DefaultEmailColumn, opts, policy)
+}
+
+// ReadInputColumnWithPolicy is ReadInputEmailsWithPolicy reading the named column; see
+// localio.ReadColumnCSV.
+func ReadInputColumnWithPolicy(
+	ctx context.Context,
+	client *foundry.Client,
+	inputRef foundry.DatasetRef,
+	column string,
+	opts localio.CSVReadOptions,
+	policy ReadRetryPolicy,
+) ([]string, error) {
+	inputBytes, err := ReadInputCSVWithPolicy(ctx, client, inputRef, policy)
+	if err != nil {
+		return nil, err
+	}
+	return localio.ReadColumnCSVWithOptions(bytes.NewReader(inputBytes), column, opts)
+}
+
+// ReadInputEmailItems reads input rows from a Foundry dataset and fans out the named email columns.
+func ReadInputEmailItems(ctx context.Context, client *foundry.Client, inputRef foundry.DatasetRef, columns []string) ([]localio.EmailItem, error) {
+	inputBytes, err := ReadInputCSV(ctx, client, inputRef)
+	if err != nil {
+		return nil, err
+	}
+	return localio.ReadEmailColumnsCSV(bytes.NewReader(inputBytes), columns)
+}
+
+// ReadInputEmailItemsWithPassthrough is ReadInputEmailItems that also carries the named passthrough
+// columns on each item; see localio.ReadEmailItemsCSV.
+func ReadInputEmailItemsWithPassthrough(
+	ctx context.Context,
+	client *foundry.Client,
+	inputRef foundry.DatasetRef,
+	emailColumns []string,
+	passthrough []string,
+) ([]localio.EmailItem, error) {
+	return ReadInputEmailItemsWithLimits(ctx, client, inputRef, emailColumns, passthrough, localio.CSVLimits{})
+}
+
+// ReadInputEmailItemsWithLimits is ReadInputEmailItemsWithPassthrough with explicit CSV field and
+// record size limits.
+func ReadInputEmailItemsWithLimits(
+	ctx context.Context,
+	client *foundry.Client,
+	inputRef foundry.DatasetRef,
+	emailColumns []string,
+	passthrough []string,
+	limits localio.CSVLimits,
+) ([]localio.EmailItem, error) {
+	return ReadInputEmailItemsWithPolicy(ctx, client, inputRef, emailColumns, passthrough, localio.CSVReadOptions{CSVLimits: limits}, ReadRetryPolicy{})
+}
+
+// ReadInputEmailItemsWithPolicy is ReadInputEmailItemsWithPassthrough with explicit CSV read
+// options and read retry policy.
+func ReadInputEmailItemsWithPolicy(
+	ctx context.Context,
+	client *foundry.Client,
+	inputRef foundry.DatasetRef,
+	emailColumns []string,
+	passthrough []string,
+	opts localio.CSVReadOptions,
+	policy ReadRetryPolicy,
+) ([]localio.EmailItem, error) {
+	inputBytes, err := ReadInputCSVWithPolicy(ctx, client, inputRef, policy)
+	if err != nil {
+		return nil, err
+	}
+	return localio.ReadEmailItemsCSVWithOptions(bytes.NewReader(inputBytes), emailColumns, passthrough, opts)
+}
+
+// ReadInputColumnItemsWithPolicy is ReadInputEmailItemsWithPolicy without email columns, reading
+// the named column in place of "email"; see localio.ReadColumnItemsCSVWithOptions.
+func ReadInputColumnItemsWithPolicy(
+	ctx context.Context,
+	client *foundry.Client,
+	inputRef foundry.DatasetRef,
+	column string,
+	passthrough []string,
+	opts localio.CSVReadOptions,
+	policy ReadRetryPolicy,
+) ([]localio.EmailItem, error) {
+	inputBytes, err := ReadInputCSVWithPolicy(ctx, client, inputRef, policy)
+	if err != nil {
+		return nil, err
+	}
+	return localio.ReadColumnItemsCSVWithOptions(bytes.NewReader(inputBytes), column, passthrough, opts)
+}
+
+// ReadInputCSV reads the raw CSV table of an input dataset, retrying transient failures.
+func ReadInputCSV(ctx context.Context, client *foundry.Client, inputRef foundry.DatasetRef) ([]byte, error) {
+	return ReadInputCSVWithPolicy(ctx, client, inputRef, ReadRetryPolicy{})
+}
+
+// ReadInputCSVWithPolicy is ReadInputCSV with an explicit read retry policy. Each attempt re-reads
+// the whole table.
+func ReadInputCSVWithPolicy(ctx context.Context, client *foundry.Client, inputRef foundry.DatasetRef, policy ReadRetryPolicy) ([]byte, error) {
 	var inputBytes []byte
-	err := RetryTransient(ctx, DefaultRetryPolicy, func() error {
+	err := RetryTransient(ctx, policy.retryPolicy(), func() error {
+		attemptCtx := ctx
+		if policy.AttemptTimeout > 0 {
+			var cancel context.CancelFunc
+			attemptCtx, cancel = context.WithTimeout(ctx, policy.AttemptTimeout)
+			defer cancel()
+		}
 		var err error
-		inputBytes, err = client.ReadTableCSV(ctx, inputRef.RID, inputRef.Branch)
+		inputBytes, err = client.ReadTableCSV(attemptCtx, inputRef.RID, inputRef.Branch)
 		return err
 	})
 	if err != nil {
 		return nil, err
 	}
-	return localio.ReadEmailsCSV(bytes.NewReader(inputBytes))
+	return inputBytes, nil
 }
 
 // ResolveOutputMode resolves whether output should be written to stream-proxy.
@@ -78,51 +205,186 @@ func PublishJSONRecord(ctx context.Context, client *foundry.Client, outputRef fo
 	return NewLegacyStreamProxyBackend(client).PublishRecord(ctx, outputRef, record)
 }
 
-// UploadDatasetCSV uploads CSV bytes to a dataset transaction and commits when appropriate.
+// UploadDatasetCSV uploads CSV bytes to a dataset transaction and commits when appropriate. A
+// transaction it creates has outputRef.TransactionType, so an APPEND ref adds the file to the
+// dataset instead of replacing it.
 func UploadDatasetCSV(ctx context.Context, client *foundry.Client, outputRef foundry.DatasetRef, outputFilename string, csv []byte) error {
+	return UploadDatasetCSVWithPolicy(ctx, client, outputRef, outputFilename, csv, DefaultWriteRetryPolicy)
+}
+
+// UploadDatasetCSVWithPolicy is UploadDatasetCSV with the whole create/upload/commit sequence
+// bounded by writePolicy. Zero fields in writePolicy fall back to DefaultWriteRetryPolicy.
+func UploadDatasetCSVWithPolicy(
+	ctx context.Context,
+	client *foundry.Client,
+	outputRef foundry.DatasetRef,
+	outputFilename string,
+	csv []byte,
+	writePolicy WriteRetryPolicy,
+) error {
 	if strings.TrimSpace(outputFilename) == "" {
 		outputFilename = "enriched.csv"
 	}
+	return UploadDatasetFilesWithPolicy(ctx, client, outputRef, []DatasetFile{{Path: outputFilename, Bytes: csv}}, writePolicy)
+}
+
+// DatasetFile is one file uploaded into a dataset output transaction. An empty ContentType uploads
+// as application/octet-stream.
+type DatasetFile struct {
+	Path        string
+	Bytes       []byte
+	ContentType string
+}
+
+// ChecksumSidecar returns a "<path>.sha256" file holding the hex SHA-256 of b, for consumers to
+// verify the committed output against.
+func ChecksumSidecar(path string, b []byte) DatasetFile {
+	sum := sha256.Sum256(b)
+	return DatasetFile{
+		Path:  path + ".sha256",
+		Bytes: []byte(hex.EncodeToString(sum[:]) + "\n"),
+	}
+}
+
+// UploadResult records where UploadDatasetFilesWithResult wrote.
+type UploadResult struct {
+	// TransactionRID is the transaction the files were uploaded into.
+	TransactionRID string
+	// Files lists the uploaded file paths in upload order.
+	Files []string
+	// Committed reports that the transaction was created and committed here. It is false when the
+	// files went into an OPEN transaction Foundry opened for the build, which Foundry commits.
+	Committed bool
+}
+
+// UploadDatasetFilesWithPolicy uploads files into a single dataset transaction and commits when
+// appropriate, bounded by writePolicy like UploadDatasetCSVWithPolicy.
+func UploadDatasetFilesWithPolicy(
+	ctx context.Context,
+	client *foundry.Client,
+	outputRef foundry.DatasetRef,
+	files []DatasetFile,
+	writePolicy WriteRetryPolicy,
+) error {
+	_, err := UploadDatasetFilesWithResult(ctx, client, outputRef, files, writePolicy)
+	return err
+}
+
+// UploadDatasetFilesWithResult is UploadDatasetFilesWithPolicy, also reporting the transaction and
+// files written. When the upload or commit fails, a transaction created here is aborted before the
+// error is returned; a failed abort is joined to the error.
+func UploadDatasetFilesWithResult(
+	ctx context.Context,
+	client *foundry.Client,
+	outputRef foundry.DatasetRef,
+	files []DatasetFile,
+	writePolicy WriteRetryPolicy,
+) (UploadResult, error) {
+	if len(files) == 0 {
+		return UploadResult{}, fmt.Errorf("no files to upload")
+	}
+	budget := newWriteBudget(writePolicy)
+	policy := DefaultRetryPolicy
+	policy.ReadOnlyErrorNames = writePolicy.ReadOnlyErrorNames
 
 	var txnID string
 	createdTxn := true
-	err := RetryTransient(ctx, DefaultRetryPolicy, func() error {
+	err := retryTransient(ctx, policy, budget, func() error {
 		var err error
-		txnID, err = client.CreateTransaction(ctx, outputRef.RID, outputRef.Branch)
+		txnID, err = client.CreateTransactionOfType(ctx, outputRef.RID, outputRef.Branch, outputRef.TransactionType)
 		return err
 	})
 	if err != nil {
 		if !isOpenTransactionAlreadyExists(err) {
-			return err
+			return UploadResult{}, err
 		}
 		createdTxn = false
 
-		var ok bool
-		err = RetryTransient(ctx, DefaultRetryPolicy, func() error {
+		var (
+			open foundry.Transaction
+			ok   bool
+		)
+		err = retryTransient(ctx, policy, budget, func() error {
 			var err error
-			txnID, ok, err = client.FindLatestOpenTransactionForBranch(ctx, outputRef.RID, outputRef.Branch)
+			open, ok, err = client.LatestOpenTransactionForBranch(ctx, outputRef.RID, outputRef.Branch)
 			return err
 		})
 		if err != nil {
-			return err
+			return UploadResult{}, err
 		}
+		txnID = strings.TrimSpace(open.RID)
 		if !ok || txnID == "" {
-			return fmt.Errorf("output dataset has an open transaction but no OPEN transaction was returned by listTransactions (preview endpoint)")
+			return UploadResult{}, fmt.Errorf("output dataset has an open transaction but no OPEN transaction was returned by listTransactions (preview endpoint)")
+		}
+		// The reused transaction commits with its own type; writing APPEND files into a SNAPSHOT (or the
+		// reverse) would silently change what the commit keeps.
+		want := strings.TrimSpace(outputRef.TransactionType)
+		got := strings.TrimSpace(open.TransactionType)
+		if want != "" && got != "" && !strings.EqualFold(want, got) {
+			return UploadResult{}, fmt.Errorf("output dataset has an open %s transaction %s, but a %s transaction was requested", strings.ToUpper(got), txnID, strings.ToUpper(want))
 		}
 	}
 
-	if err := RetryTransient(ctx, DefaultRetryPolicy, func() error {
-		return client.UploadFile(ctx, outputRef.RID, txnID, outputFilename, "application/octet-stream", csv)
-	}); err != nil {
+	// A transaction created here and left OPEN by a failed upload or commit would be picked up by
+	// the next run as if Foundry had opened it for the build, and never committed; abort it.
+	abortCreated := func(err error) error {
+		if !createdTxn {
+			return err
+		}
+		if aerr := client.AbortTransaction(context.WithoutCancel(ctx), outputRef.RID, txnID); aerr != nil {
+			return errors.Join(err, fmt.Errorf("abort transaction %s: %w", txnID, aerr))
+		}
 		return err
 	}
 
+	for _, f := range files {
+		if err := retryTransient(ctx, policy, budget, func() error {
+			contentType := f.ContentType
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+			return client.UploadFile(ctx, outputRef.RID, txnID, f.Path, contentType, f.Bytes)
+		}); err != nil {
+			return UploadResult{}, abortCreated(err)
+		}
+	}
+
+	res := UploadResult{TransactionRID: txnID, Files: make([]string, 0, len(files))}
+	for _, f := range files {
+		res.Files = append(res.Files, f.Path)
+	}
 	if createdTxn {
-		if err := RetryTransient(ctx, DefaultRetryPolicy, func() error {
+		if err := retryTransient(ctx, policy, budget, func() error {
 			return client.CommitTransaction(ctx, outputRef.RID, txnID)
 		}); err != nil {
-			return err
+			return UploadResult{}, abortCreated(err)
 		}
+		res.Committed = true
+	}
+	return res, nil
+}
+
+// CheckDatasetWritePermission verifies the caller can open a transaction on the dataset by creating
+// one and aborting it straight away. An OpenTransactionAlreadyExists conflict means Foundry already
+// opened the build's output transaction, which implies write access, and counts as success.
+func CheckDatasetWritePermission(ctx context.Context, client *foundry.Client, ref foundry.DatasetRef) error {
+	var txnID string
+	err := RetryTransient(ctx, DefaultRetryPolicy, func() error {
+		var err error
+		txnID, err = client.CreateTransaction(ctx, ref.RID, ref.Branch)
+		return err
+	})
+	if err != nil {
+		if isOpenTransactionAlreadyExists(err) {
+			return nil
+		}
+		return err
+	}
+	// A probe transaction left OPEN would be picked up (and never committed) by the real write.
+	if err := RetryTransient(ctx, DefaultRetryPolicy, func() error {
+		return client.AbortTransaction(ctx, ref.RID, txnID)
+	}); err != nil {
+		return fmt.Errorf("abort write-permission probe transaction %s: %w", txnID, err)
 	}
 	return nil
 }
@@ -0,0 +1,612 @@
+package foundryio_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/mockfoundry"
+	foundryio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/foundry"
+	localio "github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/local"
+)
+
+func TestUploadDatasetCSV_RetriesCommitContention(t *testing.T) {
+	t.Parallel()
+
+	outputRID := "ri.foundry.main.dataset.22222222-2222-2222-2222-222222222222"
+
+	mock := mockfoundry.New(t.TempDir(), t.TempDir())
+	base := mock.Handler()
+
+	// Reject the first two commits with lock contention, then let the commit through.
+	var contended atomic.Int32
+	wrapped := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/commit") && contended.Add(1) <= 2 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"errorCode":       "CONFLICT",
+				"errorName":       "OptimisticConcurrency",
+				"errorInstanceId": "00000000-0000-0000-0000-000000000000",
+			})
+			return
+		}
+		base.ServeHTTP(w, r)
+	})
+	ts := httptest.NewServer(wrapped)
+	defer ts.Close()
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	outputRef := foundry.DatasetRef{RID: outputRID, Branch: "master"}
+	if err := foundryio.UploadDatasetCSV(context.Background(), client, outputRef, "enriched.csv", []byte("email\nalice@example.com\n")); err != nil {
+		t.Fatalf("UploadDatasetCSV failed: %v", err)
+	}
+	if got := contended.Load(); got != 3 {
+		t.Fatalf("expected 3 commit attempts, got %d", got)
+	}
+
+	commits := 0
+	for _, c := range mock.Calls() {
+		if c.Method == http.MethodPost && strings.HasSuffix(c.Path, "/commit") {
+			commits++
+		}
+	}
+	if commits != 1 {
+		t.Fatalf("expected 1 commit to reach the mock, got %d (calls=%#v)", commits, mock.Calls())
+	}
+}
+
+func TestUploadDatasetFiles_RejectsReusedTransactionOfAnotherType(t *testing.T) {
+	t.Parallel()
+
+	outputRID := "ri.foundry.main.dataset.24242424-2424-2424-2424-242424242424"
+	mock := mockfoundry.New(t.TempDir(), t.TempDir())
+	ts := httptest.NewServer(mock.Handler())
+	defer ts.Close()
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	// A pipeline build pre-creates a SNAPSHOT transaction.
+	open := mock.AddOpenTransaction(outputRID, "master")
+	file := []foundryio.DatasetFile{{Path: "enriched.checkpoint.csv", Bytes: []byte("email\nalice@example.com\n")}}
+
+	appendRef := foundry.DatasetRef{RID: outputRID, Branch: "master", TransactionType: foundry.TransactionTypeAppend}
+	_, err = foundryio.UploadDatasetFilesWithResult(context.Background(), client, appendRef, file, foundryio.WriteRetryPolicy{})
+	if err == nil || !strings.Contains(err.Error(), open) {
+		t.Fatalf("expected an APPEND write into the open SNAPSHOT transaction to fail, got %v", err)
+	}
+	if uploads := mock.Uploads(); len(uploads) != 0 {
+		t.Fatalf("expected no files uploaded into the reused transaction, got %#v", uploads)
+	}
+
+	// Without an explicit type the write takes whatever Foundry opened.
+	res, err := foundryio.UploadDatasetFilesWithResult(context.Background(), client, foundry.DatasetRef{RID: outputRID, Branch: "master"}, file, foundryio.WriteRetryPolicy{})
+	if err != nil || res.TransactionRID != open {
+		t.Fatalf("expected the write to reuse %s, got %+v err=%v", open, res, err)
+	}
+}
+
+func TestUploadDatasetCSV_AbortsCreatedTransactionWhenCommitFails(t *testing.T) {
+	t.Parallel()
+
+	outputRID := "ri.foundry.main.dataset.23232323-2323-2323-2323-232323232323"
+	mock := mockfoundry.New(t.TempDir(), t.TempDir())
+	base := mock.Handler()
+
+	// Reject the first commit with a non-retryable error.
+	var commits atomic.Int32
+	wrapped := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/commit") && commits.Add(1) == 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"errorCode":       "INVALID_ARGUMENT",
+				"errorName":       "CommitTransactionFailed",
+				"errorInstanceId": "00000000-0000-0000-0000-000000000000",
+			})
+			return
+		}
+		base.ServeHTTP(w, r)
+	})
+	ts := httptest.NewServer(wrapped)
+	defer ts.Close()
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	ctx := context.Background()
+	outputRef := foundry.DatasetRef{RID: outputRID, Branch: "master"}
+	csv := []byte("email\nalice@example.com\n")
+
+	err = foundryio.UploadDatasetCSV(ctx, client, outputRef, "enriched.csv", csv)
+	var he *foundry.HTTPError
+	if !errors.As(err, &he) || he.ErrorName != "CommitTransactionFailed" {
+		t.Fatalf("expected the commit error, got %v", err)
+	}
+	aborts := 0
+	for _, c := range mock.Calls() {
+		if c.Method == http.MethodPost && strings.HasSuffix(c.Path, "/abort") {
+			aborts++
+		}
+	}
+	if aborts != 1 {
+		t.Fatalf("expected 1 abort after the failed commit, got %d (calls=%#v)", aborts, mock.Calls())
+	}
+	if _, ok, err := client.FindLatestOpenTransactionForBranch(ctx, outputRID, "master"); err != nil || ok {
+		t.Fatalf("expected no dangling OPEN transaction, got ok=%t err=%v", ok, err)
+	}
+
+	// The next upload opens and commits a fresh transaction instead of reusing a dangling one.
+	res, err := foundryio.UploadDatasetFilesWithResult(ctx, client, outputRef, []foundryio.DatasetFile{{Path: "enriched.csv", Bytes: csv}}, foundryio.WriteRetryPolicy{})
+	if err != nil || !res.Committed {
+		t.Fatalf("expected the retry to commit its own transaction, got %+v err=%v", res, err)
+	}
+	// A committed transaction can no longer be aborted.
+	if err := client.AbortTransaction(ctx, outputRID, res.TransactionRID); !errors.As(err, &he) || he.ErrorName != "TransactionNotOpen" {
+		t.Fatalf("expected aborting a committed transaction to fail with TransactionNotOpen, got %v", err)
+	}
+}
+
+// newFlakyWriteServer fails the first failuresPerStep create/upload/commit calls of each step with a
+// 503 and counts every write call it sees.
+func newFlakyWriteServer(t *testing.T, failuresPerStep int32) (*foundry.Client, *mockfoundry.Server, *atomic.Int32) {
+	t.Helper()
+
+	mock := mockfoundry.New(t.TempDir(), t.TempDir())
+	base := mock.Handler()
+
+	var writeCalls atomic.Int32
+	var creates, uploads, commits atomic.Int32
+	wrapped := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var step *atomic.Int32
+		switch {
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/transactions"):
+			step = &creates
+		case r.Method == http.MethodPost && strings.Contains(r.URL.Path, "/files/"):
+			step = &uploads
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/commit"):
+			step = &commits
+		}
+		if step != nil {
+			writeCalls.Add(1)
+			if step.Add(1) <= failuresPerStep {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		base.ServeHTTP(w, r)
+	})
+	ts := httptest.NewServer(wrapped)
+	t.Cleanup(ts.Close)
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client, mock, &writeCalls
+}
+
+func TestUploadDatasetCSV_AppendTransactionsAccumulateRows(t *testing.T) {
+	t.Parallel()
+
+	outputRID := "ri.foundry.main.dataset.24242424-2424-2424-2424-242424242424"
+	mock := mockfoundry.New(t.TempDir(), t.TempDir())
+	ts := httptest.NewServer(mock.Handler())
+	defer ts.Close()
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	ctx := context.Background()
+	snapshotRef := foundry.DatasetRef{RID: outputRID, Branch: "master"}
+	appendRef := snapshotRef
+	appendRef.TransactionType = foundry.TransactionTypeAppend
+	readTable := func() string {
+		t.Helper()
+		b, err := client.ReadTableCSV(ctx, outputRID, "master")
+		if err != nil {
+			t.Fatalf("ReadTableCSV: %v", err)
+		}
+		return string(b)
+	}
+
+	if err := foundryio.UploadDatasetCSV(ctx, client, snapshotRef, "chunk-1.csv", []byte("email\nalice@example.com\n")); err != nil {
+		t.Fatalf("snapshot upload: %v", err)
+	}
+	if err := foundryio.UploadDatasetCSV(ctx, client, appendRef, "chunk-2.csv", []byte("email\nbob@corp.test\n")); err != nil {
+		t.Fatalf("append upload: %v", err)
+	}
+	if got, want := readTable(), "email\nalice@example.com\nbob@corp.test\n"; got != want {
+		t.Fatalf("expected the appended rows after the snapshot, got %q want %q", got, want)
+	}
+
+	// A file whose header does not match the dataset cannot be appended.
+	err = foundryio.UploadDatasetCSV(ctx, client, appendRef, "chunk-3.csv", []byte("name\ncarol\n"))
+	var he *foundry.HTTPError
+	if !errors.As(err, &he) || he.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected a 400 for a mismatched header, got %v", err)
+	}
+
+	// The default SNAPSHOT type still replaces the view.
+	if err := foundryio.UploadDatasetCSV(ctx, client, snapshotRef, "chunk-4.csv", []byte("email\ncarol@example.com\n")); err != nil {
+		t.Fatalf("second snapshot upload: %v", err)
+	}
+	if got, want := readTable(), "email\ncarol@example.com\n"; got != want {
+		t.Fatalf("expected the snapshot to replace the view, got %q want %q", got, want)
+	}
+}
+
+func TestUploadDatasetCSVWithPolicy_GivesUpWhenAttemptBudgetIsSpent(t *testing.T) {
+	t.Parallel()
+
+	client, mock, writeCalls := newFlakyWriteServer(t, 2)
+	outputRef := foundry.DatasetRef{RID: "ri.foundry.main.dataset.22222222-2222-2222-2222-222222222222", Branch: "master"}
+
+	// Each step fails twice before succeeding, so the full sequence needs 9 calls; 5 runs out during upload.
+	err := foundryio.UploadDatasetCSVWithPolicy(context.Background(), client, outputRef, "enriched.csv",
+		[]byte("email\nalice@example.com\n"), foundryio.WriteRetryPolicy{MaxAttempts: 5, MaxElapsed: time.Minute})
+	if !errors.Is(err, foundryio.ErrWriteRetryBudgetExhausted) {
+		t.Fatalf("expected ErrWriteRetryBudgetExhausted, got %v", err)
+	}
+	var he *foundry.HTTPError
+	if !errors.As(err, &he) || he.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected the last step error to be wrapped, got %v", err)
+	}
+	if got := writeCalls.Load(); got != 5 {
+		t.Fatalf("expected 5 write calls within the budget, got %d", got)
+	}
+	if uploads := mock.Uploads(); len(uploads) != 0 {
+		t.Fatalf("expected no successful upload, got %d", len(uploads))
+	}
+}
+
+func TestUploadDatasetCSVWithPolicy_SucceedsWithinBudget(t *testing.T) {
+	t.Parallel()
+
+	client, mock, writeCalls := newFlakyWriteServer(t, 1)
+	outputRef := foundry.DatasetRef{RID: "ri.foundry.main.dataset.22222222-2222-2222-2222-222222222222", Branch: "master"}
+
+	if err := foundryio.UploadDatasetCSVWithPolicy(context.Background(), client, outputRef, "enriched.csv",
+		[]byte("email\nalice@example.com\n"), foundryio.WriteRetryPolicy{MaxAttempts: 6, MaxElapsed: time.Minute}); err != nil {
+		t.Fatalf("UploadDatasetCSVWithPolicy failed: %v", err)
+	}
+	if got := writeCalls.Load(); got != 6 {
+		t.Fatalf("expected 6 write calls, got %d", got)
+	}
+	if uploads := mock.Uploads(); len(uploads) != 1 {
+		t.Fatalf("expected 1 upload, got %d", len(uploads))
+	}
+}
+
+func TestUploadDatasetCSVWithPolicy_GivesUpWhenElapsedBudgetIsSpent(t *testing.T) {
+	t.Parallel()
+
+	client, _, writeCalls := newFlakyWriteServer(t, 1000)
+	outputRef := foundry.DatasetRef{RID: "ri.foundry.main.dataset.22222222-2222-2222-2222-222222222222", Branch: "master"}
+
+	start := time.Now()
+	err := foundryio.UploadDatasetCSVWithPolicy(context.Background(), client, outputRef, "enriched.csv",
+		[]byte("email\nalice@example.com\n"), foundryio.WriteRetryPolicy{MaxAttempts: 100, MaxElapsed: 300 * time.Millisecond})
+	if !errors.Is(err, foundryio.ErrWriteRetryBudgetExhausted) {
+		t.Fatalf("expected ErrWriteRetryBudgetExhausted, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected to give up within the elapsed budget, took %s", elapsed)
+	}
+	if got := writeCalls.Load(); got >= 100 {
+		t.Fatalf("expected the elapsed budget to stop retries early, got %d calls", got)
+	}
+}
+
+func TestPublishJSONRecord_SendsPartitionKey(t *testing.T) {
+	t.Parallel()
+
+	streamRID := "ri.foundry.main.dataset.33333333-3333-3333-3333-333333333333"
+	mock := mockfoundry.New(t.TempDir(), t.TempDir())
+	mock.CreateStream(streamRID)
+	ts := httptest.NewServer(mock.Handler())
+	defer ts.Close()
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	ref := foundry.DatasetRef{RID: streamRID, Branch: "master"}
+	ctx := context.Background()
+
+	if err := foundryio.PublishJSONRecord(ctx, client, ref, map[string]any{"email": "alice@example.com", "status": "ok"}); err != nil {
+		t.Fatalf("PublishJSONRecord: %v", err)
+	}
+	companyKeyed := foundryio.NewLegacyStreamProxyBackend(client).WithPartitionKey(foundryio.PartitionKeyFromField("company"))
+	if err := companyKeyed.PublishRecord(ctx, ref, map[string]any{"email": "bob@corp.test", "company": "Corp"}); err != nil {
+		t.Fatalf("PublishRecord (company key): %v", err)
+	}
+	unkeyed := foundryio.NewLegacyStreamProxyBackend(client).WithPartitionKey(nil)
+	if err := unkeyed.PublishRecord(ctx, ref, map[string]any{"email": "carol@new.test"}); err != nil {
+		t.Fatalf("PublishRecord (unkeyed): %v", err)
+	}
+
+	got := mock.StreamPartitionKeys(streamRID, "master")
+	want := []string{"alice@example.com", "Corp", ""}
+	if !slices.Equal(got, want) {
+		t.Fatalf("partition keys: want %q, got %q", want, got)
+	}
+}
+
+func TestUploadDatasetCSVWithPolicy_FailsFastWhenStackIsReadOnly(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name      string
+		errorName string
+		policy    foundryio.WriteRetryPolicy
+	}{
+		{name: "default names", errorName: "ReadOnlyMode"},
+		{name: "configured names", errorName: "StackFrozen", policy: foundryio.WriteRetryPolicy{ReadOnlyErrorNames: []string{"StackFrozen"}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock := mockfoundry.New(t.TempDir(), t.TempDir())
+			base := mock.Handler()
+			var creates atomic.Int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/transactions") {
+					creates.Add(1)
+					// A 503 would normally be retried; the maintenance error name must win.
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusServiceUnavailable)
+					_ = json.NewEncoder(w).Encode(map[string]any{
+						"errorCode": "SERVICE_UNAVAILABLE",
+						"errorName": tc.errorName,
+					})
+					return
+				}
+				base.ServeHTTP(w, r)
+			}))
+			t.Cleanup(ts.Close)
+
+			client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+			if err != nil {
+				t.Fatalf("NewClient: %v", err)
+			}
+			outputRef := foundry.DatasetRef{RID: "ri.foundry.main.dataset.22222222-2222-2222-2222-222222222222", Branch: "master"}
+
+			err = foundryio.UploadDatasetCSVWithPolicy(context.Background(), client, outputRef, "enriched.csv",
+				[]byte("email\nalice@example.com\n"), tc.policy)
+			if !errors.Is(err, foundryio.ErrStackReadOnly) {
+				t.Fatalf("expected ErrStackReadOnly, got %v", err)
+			}
+			if !strings.Contains(err.Error(), "stack appears read-only") || !strings.Contains(err.Error(), tc.errorName) {
+				t.Fatalf("expected a read-only message naming %s, got %q", tc.errorName, err)
+			}
+			if got := creates.Load(); got != 1 {
+				t.Fatalf("expected no retries after the read-only error, got %d create calls", got)
+			}
+		})
+	}
+}
+
+// newFlakyReadServer serves testInputRID from the mock and runs fail (when non-nil) before each
+// readTable call; fail reports whether it already answered the request.
+func newFlakyReadServer(t *testing.T, fail func(w http.ResponseWriter, call int32) bool) (*foundry.Client, *atomic.Int32) {
+	t.Helper()
+
+	inputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, readTestInputRID+".csv"), []byte("email\nalice@example.com\n"), 0644); err != nil {
+		t.Fatalf("write input csv: %v", err)
+	}
+	base := mockfoundry.New(inputDir, t.TempDir()).Handler()
+
+	var reads atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/readTable") {
+			if fail(w, reads.Add(1)) {
+				return
+			}
+		}
+		base.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client, &reads
+}
+
+const readTestInputRID = "ri.foundry.main.dataset.11111111-1111-1111-1111-111111111111"
+
+func TestReadInputEmailsWithPolicy_AttemptsBoundRetries(t *testing.T) {
+	t.Parallel()
+
+	failTwice := func(w http.ResponseWriter, call int32) bool {
+		if call <= 2 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return true
+		}
+		return false
+	}
+	inputRef := foundry.DatasetRef{RID: readTestInputRID, Branch: "master"}
+
+	client, reads := newFlakyReadServer(t, failTwice)
+	_, err := foundryio.ReadInputEmailsWithPolicy(context.Background(), client, inputRef, localio.CSVReadOptions{}, foundryio.ReadRetryPolicy{Attempts: 2})
+	var he *foundry.HTTPError
+	if !errors.As(err, &he) || he.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected the read to give up with the 503, got %v", err)
+	}
+	if got := reads.Load(); got != 2 {
+		t.Fatalf("expected 2 read attempts, got %d", got)
+	}
+
+	client, reads = newFlakyReadServer(t, failTwice)
+	emails, err := foundryio.ReadInputEmailsWithPolicy(context.Background(), client, inputRef, localio.CSVReadOptions{}, foundryio.ReadRetryPolicy{Attempts: 3})
+	if err != nil {
+		t.Fatalf("ReadInputEmailsWithPolicy failed: %v", err)
+	}
+	if !slices.Equal(emails, []string{"alice@example.com"}) || reads.Load() != 3 {
+		t.Fatalf("unexpected result: emails=%v reads=%d", emails, reads.Load())
+	}
+}
+
+func TestReadInputCSVWithPolicy_RetriesTimedOutAttempt(t *testing.T) {
+	t.Parallel()
+
+	client, reads := newFlakyReadServer(t, func(_ http.ResponseWriter, call int32) bool {
+		if call == 1 {
+			time.Sleep(500 * time.Millisecond)
+		}
+		return false
+	})
+	inputRef := foundry.DatasetRef{RID: readTestInputRID, Branch: "master"}
+
+	start := time.Now()
+	b, err := foundryio.ReadInputCSVWithPolicy(context.Background(), client, inputRef, foundryio.ReadRetryPolicy{Attempts: 2, AttemptTimeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("ReadInputCSVWithPolicy failed: %v", err)
+	}
+	if !strings.Contains(string(b), "alice@example.com") {
+		t.Fatalf("unexpected input: %q", b)
+	}
+	if got := reads.Load(); got != 2 {
+		t.Fatalf("expected the slow attempt to time out and be retried, got %d reads", got)
+	}
+	if elapsed := time.Since(start); elapsed >= 500*time.Millisecond {
+		t.Fatalf("expected the attempt timeout to cut the slow read short, took %s", elapsed)
+	}
+}
+
+func TestLegacyStreamProxyBackend_PublishRetryPolicyIsIndependent(t *testing.T) {
+	t.Parallel()
+
+	streamRID := "ri.foundry.main.dataset.33333333-3333-3333-3333-333333333333"
+	var publishes, reads atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/jsonRecord"):
+			publishes.Add(1)
+		case strings.HasSuffix(r.URL.Path, "/records"):
+			reads.Add(1)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	ref := foundry.DatasetRef{RID: streamRID, Branch: "master"}
+	record := map[string]any{"email": "alice@example.com", "status": "ok"}
+	base := foundryio.NewLegacyStreamProxyBackend(client).
+		WithRetryPolicy(foundryio.RetryPolicy{Attempts: 2, InitialSleep: time.Millisecond, MaxSleep: time.Millisecond})
+
+	cases := []struct {
+		name    string
+		backend *foundryio.LegacyStreamProxyBackend
+		want    int32
+	}{
+		{
+			name:    "publish attempts apart from reads",
+			backend: base.WithPublishRetryPolicy(foundryio.PublishRetryPolicy{Attempts: 5, InitialSleep: time.Millisecond, MaxSleep: time.Millisecond}),
+			want:    5,
+		},
+		{
+			name:    "at-least-once without unkeyed retries",
+			backend: base.WithPublishRetryPolicy(foundryio.PublishRetryPolicy{Attempts: 5, NoUnkeyedRetries: true}),
+			want:    1,
+		},
+		{
+			// Keyed publishes still retry, but stop before sleeping past the window:
+			// 1ms+2ms+4ms fits in 10ms, and the next 4ms sleep would not.
+			name: "exactly-once capped by the idempotency window",
+			backend: base.WithExactlyOnce().WithPublishRetryPolicy(foundryio.PublishRetryPolicy{
+				Attempts:          20,
+				InitialSleep:      time.Millisecond,
+				MaxSleep:          4 * time.Millisecond,
+				NoUnkeyedRetries:  true,
+				IdempotencyWindow: 10 * time.Millisecond,
+			}),
+			want: 4,
+		},
+	}
+	for _, tc := range cases {
+		publishes.Store(0)
+		if err := tc.backend.PublishRecord(context.Background(), ref, record); err == nil {
+			t.Fatalf("%s: expected the publish to fail", tc.name)
+		}
+		if got := publishes.Load(); got != tc.want {
+			t.Fatalf("%s: expected %d publish attempts, got %d", tc.name, tc.want, got)
+		}
+	}
+
+	// Reads keep the backend's own policy.
+	if _, err := base.WithPublishRetryPolicy(foundryio.PublishRetryPolicy{Attempts: 5}).ReadRecords(context.Background(), ref); err == nil {
+		t.Fatalf("expected the read to fail")
+	}
+	if got := reads.Load(); got != 2 {
+		t.Fatalf("expected 2 read attempts, got %d", got)
+	}
+}
+
+// baseStreamBackend hides the optional extensions of the backend it wraps.
+type baseStreamBackend struct {
+	foundryio.StreamBackend
+}
+
+func TestReadRecordsLimit_FallsBackToReadRecordsKeepingNewest(t *testing.T) {
+	t.Parallel()
+
+	const streamRID = "ri.foundry.main.dataset.33333333-3333-3333-3333-333333333333"
+	mock := mockfoundry.New(t.TempDir(), t.TempDir())
+	mock.CreateStream(streamRID)
+	ts := httptest.NewServer(mock.Handler())
+	t.Cleanup(ts.Close)
+	client, err := foundry.NewClient(ts.URL+"/api", ts.URL+"/stream-proxy/api", "dummy-token", "")
+	if err != nil {
+		t.Fatalf("new foundry client: %v", err)
+	}
+	ref := foundry.DatasetRef{RID: streamRID, Branch: "master"}
+	ctx := context.Background()
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if err := client.PublishStreamJSONRecord(ctx, streamRID, "master", map[string]any{"email": email}); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	backend := baseStreamBackend{foundryio.NewLegacyStreamProxyBackend(client)}
+	if _, ok := foundryio.StreamBackend(backend).(foundryio.LimitedRecordReader); ok {
+		t.Fatalf("expected the wrapped backend to hide ReadRecordsLimit")
+	}
+	recs, truncated, err := foundryio.ReadRecordsLimit(ctx, backend, ref, 2)
+	if err != nil {
+		t.Fatalf("ReadRecordsLimit: %v", err)
+	}
+	if len(recs) != 2 || !truncated || recs[0]["email"] != "b@example.com" || recs[1]["email"] != "c@example.com" {
+		t.Fatalf("expected the newest 2 records truncated, got %v truncated=%t", recs, truncated)
+	}
+	recs, err = foundryio.WaitForRecords(ctx, backend, ref, 10, time.Minute)
+	if err != nil || len(recs) != 3 {
+		t.Fatalf("expected WaitForRecords to read once without waiting, got %d records (err=%v)", len(recs), err)
+	}
+}
@@ -3,7 +3,10 @@ package foundryio
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
@@ -15,8 +18,38 @@ type RetryPolicy struct {
 	Attempts     int
 	InitialSleep time.Duration
 	MaxSleep     time.Duration
+
+	// RetryableErrorNames lists Foundry error names that are retried regardless of HTTP status
+	// (for example commit lock contention surfaced as a 409). Nil uses DefaultRetryableErrorNames;
+	// an empty non-nil slice disables name-based retries.
+	RetryableErrorNames []string
+
+	// ReadOnlyErrorNames lists Foundry error names that mean the stack is in read-only maintenance.
+	// They are never retried and fail with ErrStackReadOnly, even when the status is a 5xx. Nil uses
+	// DefaultReadOnlyErrorNames; an empty non-nil slice disables the check.
+	ReadOnlyErrorNames []string
 }
 
+// DefaultRetryableErrorNames are Foundry error names that indicate short-lived contention rather
+// than a permanent failure.
+var DefaultRetryableErrorNames = []string{
+	"OptimisticConcurrency",
+	"OptimisticLockFailure",
+	"ConcurrentModification",
+}
+
+// DefaultReadOnlyErrorNames are Foundry error names returned on writes while a stack is in
+// read-only maintenance.
+var DefaultReadOnlyErrorNames = []string{
+	"ReadOnlyMode",
+	"StackReadOnly",
+	"MaintenanceMode",
+}
+
+// ErrStackReadOnly is returned (wrapping the Foundry error) when a call fails with one of the
+// policy's ReadOnlyErrorNames. Retrying is futile until maintenance ends, so it is not retried.
+var ErrStackReadOnly = errors.New("stack appears read-only (maintenance); not retrying")
+
 // DefaultRetryPolicy is intentionally shared by dataset and legacy stream-proxy
 // calls so retryability stays consistent across Foundry I/O surfaces.
 var DefaultRetryPolicy = RetryPolicy{
@@ -25,19 +58,177 @@ var DefaultRetryPolicy = RetryPolicy{
 	MaxSleep:     2 * time.Second,
 }
 
+// ReadRetryPolicy configures retries of the input dataset read, independently of WriteRetryPolicy:
+// a read is idempotent but re-reads the whole table on each attempt, so large inputs may want fewer
+// attempts with a per-attempt timeout, where writes want a shared budget.
+type ReadRetryPolicy struct {
+	// Attempts caps the read attempts, including the first. Zero uses DefaultRetryPolicy.Attempts.
+	Attempts int
+	// AttemptTimeout bounds each attempt; a timed-out attempt is retried. Zero means no per-attempt
+	// timeout beyond the caller's context.
+	AttemptTimeout time.Duration
+}
+
+// retryPolicy returns the per-call RetryPolicy the read runs under.
+func (p ReadRetryPolicy) retryPolicy() RetryPolicy {
+	policy := DefaultRetryPolicy
+	if p.Attempts > 0 {
+		policy.Attempts = p.Attempts
+	}
+	return policy
+}
+
+// PublishRetryPolicy configures retries of stream publishes, independently of the RetryPolicy that
+// probes and reads use. A publish without an idempotency key is not idempotent: under at-least-once
+// delivery a retry whose earlier attempt was stored but not acknowledged stores the record twice, so
+// duplicate-averse or latency-sensitive outputs may want fewer attempts, or none.
+type PublishRetryPolicy struct {
+	// Attempts caps publish attempts, including the first. Zero uses DefaultRetryPolicy.Attempts.
+	Attempts int
+	// InitialSleep and MaxSleep bound the backoff between attempts. Zero uses DefaultRetryPolicy's.
+	InitialSleep time.Duration
+	MaxSleep     time.Duration
+
+	// NoUnkeyedRetries makes a publish without an idempotency key (at-least-once delivery) a single
+	// attempt, so a failure fails the run rather than risking a duplicate record.
+	NoUnkeyedRetries bool
+
+	// IdempotencyWindow is how long the stack is assumed to remember idempotency keys. Keyed
+	// (exactly-once) publishes only retry while the backoff slept so far stays within it, since a
+	// retry after the key is forgotten is stored again. Zero uses DefaultPublishIdempotencyWindow.
+	IdempotencyWindow time.Duration
+}
+
+// DefaultPublishIdempotencyWindow is the idempotency key lifetime assumed for exactly-once
+// publishes when PublishRetryPolicy.IdempotencyWindow is zero.
+const DefaultPublishIdempotencyWindow = time.Minute
+
+// retryPolicy returns the per-publish RetryPolicy, keeping base's error-name lists. keyed reports
+// that the publish carries an idempotency key.
+func (p PublishRetryPolicy) retryPolicy(base RetryPolicy, keyed bool) RetryPolicy {
+	policy := base
+	if p.Attempts > 0 {
+		policy.Attempts = p.Attempts
+	}
+	if p.InitialSleep > 0 {
+		policy.InitialSleep = p.InitialSleep
+	}
+	if p.MaxSleep > 0 {
+		policy.MaxSleep = p.MaxSleep
+	}
+	policy = normalizeRetryPolicy(policy)
+	switch {
+	case !keyed && p.NoUnkeyedRetries:
+		policy.Attempts = 1
+	case keyed:
+		window := p.IdempotencyWindow
+		if window <= 0 {
+			window = DefaultPublishIdempotencyWindow
+		}
+		policy.Attempts = min(policy.Attempts, attemptsWithin(policy, window))
+	}
+	return policy
+}
+
+// attemptsWithin returns how many attempts policy makes before the backoff slept between them
+// would exceed window. The first attempt never sleeps, so it is at least one.
+func attemptsWithin(policy RetryPolicy, window time.Duration) int {
+	attempts := 1
+	var slept time.Duration
+	for sleep := policy.InitialSleep; attempts < policy.Attempts; attempts++ {
+		if slept += sleep; slept > window {
+			break
+		}
+		sleep = min(sleep*2, policy.MaxSleep)
+	}
+	return attempts
+}
+
+// WriteRetryPolicy bounds the whole dataset write sequence (create transaction -> upload -> commit)
+// rather than each step. Each step still retries under its RetryPolicy, but attempts and elapsed
+// time are counted across all steps so a flaky stack cannot cycle through them indefinitely.
+type WriteRetryPolicy struct {
+	// MaxAttempts caps the total number of Foundry calls across the sequence.
+	MaxAttempts int
+	// MaxElapsed caps the wall-clock time spent on the sequence, including backoff sleeps.
+	MaxElapsed time.Duration
+
+	// ReadOnlyErrorNames overrides RetryPolicy.ReadOnlyErrorNames for the steps of the sequence.
+	ReadOnlyErrorNames []string
+}
+
+// DefaultWriteRetryPolicy leaves room for a few transient failures on each step.
+var DefaultWriteRetryPolicy = WriteRetryPolicy{
+	MaxAttempts: 16,
+	MaxElapsed:  2 * time.Minute,
+}
+
+// ErrWriteRetryBudgetExhausted is returned (wrapping the last step error) when a dataset write
+// gives up because its WriteRetryPolicy budget ran out.
+var ErrWriteRetryBudgetExhausted = errors.New("dataset write retry budget exhausted")
+
+// writeBudget tracks attempts and elapsed time shared by the steps of one dataset write.
+type writeBudget struct {
+	policy   WriteRetryPolicy
+	start    time.Time
+	attempts int
+}
+
+func newWriteBudget(policy WriteRetryPolicy) *writeBudget {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = DefaultWriteRetryPolicy.MaxAttempts
+	}
+	if policy.MaxElapsed <= 0 {
+		policy.MaxElapsed = DefaultWriteRetryPolicy.MaxElapsed
+	}
+	return &writeBudget{policy: policy, start: time.Now()}
+}
+
+// allow reports whether another attempt may start after sleeping for wait.
+func (b *writeBudget) allow(wait time.Duration) bool {
+	if b == nil {
+		return true
+	}
+	return b.attempts < b.policy.MaxAttempts && time.Since(b.start)+wait < b.policy.MaxElapsed
+}
+
+func (b *writeBudget) exhausted(lastErr error) error {
+	return fmt.Errorf(
+		"%w after %d attempts in %s: %w",
+		ErrWriteRetryBudgetExhausted,
+		b.attempts,
+		time.Since(b.start).Round(time.Millisecond),
+		lastErr,
+	)
+}
+
 // RetryTransient retries f when it returns an error classified as transient.
 func RetryTransient(ctx context.Context, policy RetryPolicy, f func() error) error {
+	return retryTransient(ctx, policy, nil, f)
+}
+
+// retryTransient is RetryTransient with an optional budget shared across several calls.
+func retryTransient(ctx context.Context, policy RetryPolicy, budget *writeBudget, f func() error) error {
 	policy = normalizeRetryPolicy(policy)
 	sleep := policy.InitialSleep
 	var lastErr error
 	for i := 0; i < policy.Attempts; i++ {
+		if budget != nil {
+			budget.attempts++
+		}
 		if err := f(); err == nil {
 			return nil
 		} else {
 			lastErr = err
-			if !IsTransient(err) || i == policy.Attempts-1 {
+			if policy.IsReadOnly(err) {
+				return fmt.Errorf("%w: %w", ErrStackReadOnly, err)
+			}
+			if !policy.IsTransient(err) || i == policy.Attempts-1 {
 				return err
 			}
+			if !budget.allow(sleep) {
+				return budget.exhausted(err)
+			}
 		}
 
 		t := time.NewTimer(sleep)
@@ -55,14 +246,45 @@ func RetryTransient(ctx context.Context, policy RetryPolicy, f func() error) err
 	return lastErr
 }
 
-// IsTransient classifies retryable Foundry I/O failures.
+// IsTransient classifies retryable Foundry I/O failures using DefaultRetryableErrorNames.
 func IsTransient(err error) bool {
+	return isTransient(err, DefaultRetryableErrorNames)
+}
+
+// IsTransient classifies retryable Foundry I/O failures using the policy's error-name allow-list.
+func (p RetryPolicy) IsTransient(err error) bool {
+	names := p.RetryableErrorNames
+	if names == nil {
+		names = DefaultRetryableErrorNames
+	}
+	return isTransient(err, names)
+}
+
+// IsReadOnly reports whether err carries one of the policy's read-only maintenance error names.
+func (p RetryPolicy) IsReadOnly(err error) bool {
+	names := p.ReadOnlyErrorNames
+	if names == nil {
+		names = DefaultReadOnlyErrorNames
+	}
+	var he *foundry.HTTPError
+	if !errors.As(err, &he) {
+		return false
+	}
+	name := strings.TrimSpace(he.ErrorName)
+	return name != "" && slices.Contains(names, name)
+}
+
+func isTransient(err error, retryableErrorNames []string) bool {
 	if err == nil {
 		return false
 	}
 	var he *foundry.HTTPError
 	if errors.As(err, &he) {
-		return he.StatusCode == 429 || he.StatusCode/100 == 5
+		if he.StatusCode == 429 || he.StatusCode/100 == 5 {
+			return true
+		}
+		name := strings.TrimSpace(he.ErrorName)
+		return name != "" && slices.Contains(retryableErrorNames, name)
 	}
 	if errors.Is(err, context.DeadlineExceeded) {
 		return true
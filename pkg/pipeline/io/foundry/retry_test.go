@@ -25,6 +25,8 @@ func TestIsTransient(t *testing.T) {
 		{name: "not found", err: &foundry.HTTPError{StatusCode: 404}, want: false},
 		{name: "deadline", err: context.DeadlineExceeded, want: true},
 		{name: "plain error", err: errors.New("boom"), want: false},
+		{name: "commit contention", err: &foundry.HTTPError{StatusCode: 409, ErrorName: "OptimisticConcurrency"}, want: true},
+		{name: "open transaction conflict", err: &foundry.HTTPError{StatusCode: 409, ErrorName: "OpenTransactionAlreadyExists"}, want: false},
 	}
 
 	for _, tt := range tests {
@@ -60,3 +62,36 @@ func TestRetryTransient(t *testing.T) {
 		t.Fatalf("attempts=%d want 3", attempts)
 	}
 }
+
+func TestRetryPolicy_IsTransientUsesErrorNameAllowList(t *testing.T) {
+	t.Parallel()
+
+	err := &foundry.HTTPError{StatusCode: 409, ErrorName: "DatasetLocked"}
+	if foundryio.DefaultRetryPolicy.IsTransient(err) {
+		t.Fatalf("expected %v to be non-transient under the default allow-list", err)
+	}
+	policy := foundryio.RetryPolicy{RetryableErrorNames: []string{"DatasetLocked"}}
+	if !policy.IsTransient(err) {
+		t.Fatalf("expected %v to be transient with a custom allow-list", err)
+	}
+	disabled := foundryio.RetryPolicy{RetryableErrorNames: []string{}}
+	if disabled.IsTransient(&foundry.HTTPError{StatusCode: 409, ErrorName: "OptimisticConcurrency"}) {
+		t.Fatalf("expected an empty allow-list to disable name-based retries")
+	}
+}
+
+func TestRetryPolicy_IsReadOnlyUsesErrorNameList(t *testing.T) {
+	t.Parallel()
+
+	err := &foundry.HTTPError{StatusCode: 503, ErrorName: "MaintenanceMode"}
+	if !foundryio.DefaultRetryPolicy.IsReadOnly(err) {
+		t.Fatalf("expected %v to be read-only under the default names", err)
+	}
+	disabled := foundryio.RetryPolicy{ReadOnlyErrorNames: []string{}}
+	if disabled.IsReadOnly(err) {
+		t.Fatalf("expected an empty list to disable the read-only check")
+	}
+	if foundryio.DefaultRetryPolicy.IsReadOnly(&foundry.HTTPError{StatusCode: 503}) {
+		t.Fatalf("expected an unnamed 503 not to be read-only")
+	}
+}
@@ -2,8 +2,12 @@ package foundryio
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/foundry"
 )
@@ -19,19 +23,97 @@ type StreamBackend interface {
 	PublishRecord(ctx context.Context, ref foundry.DatasetRef, record map[string]any) error
 }
 
-// LegacyStreamProxyBackend implements StreamBackend using the legacy
-// /streams/{rid}/branches/{branch}/records and /jsonRecord endpoints.
+// PublishProber is an optional StreamBackend extension for write-permission preflights.
+// ProbePublish checks that records can be published to ref without writing any.
+type PublishProber interface {
+	ProbePublish(ctx context.Context, ref foundry.DatasetRef) error
+}
+
+// LimitedRecordReader is an optional StreamBackend extension that reads records with a memory
+// bound. ReadRecordsLimit is ReadRecords keeping the newest maxRecords records (maxRecords <= 0
+// keeps all); truncated reports that older records were dropped.
+type LimitedRecordReader interface {
+	ReadRecordsLimit(ctx context.Context, ref foundry.DatasetRef, maxRecords int) (recs []map[string]any, truncated bool, err error)
+}
+
+// RecordWaiter is an optional StreamBackend extension for stacks where a read right after a
+// publish may not show it yet. WaitForRecords polls until ref shows at least want records or
+// timeout elapses, returning the last records read (see foundry.Client.WaitForStreamRecords).
+type RecordWaiter interface {
+	WaitForRecords(ctx context.Context, ref foundry.DatasetRef, want int, timeout time.Duration) ([]map[string]any, error)
+}
+
+// ReadRecordsLimit reads ref through backend's LimitedRecordReader when it has one. Otherwise it
+// reads every record with ReadRecords and keeps the newest maxRecords.
+func ReadRecordsLimit(ctx context.Context, backend StreamBackend, ref foundry.DatasetRef, maxRecords int) ([]map[string]any, bool, error) {
+	if r, ok := backend.(LimitedRecordReader); ok {
+		return r.ReadRecordsLimit(ctx, ref, maxRecords)
+	}
+	recs, err := backend.ReadRecords(ctx, ref)
+	if err != nil {
+		return nil, false, err
+	}
+	if maxRecords > 0 && len(recs) > maxRecords {
+		return recs[len(recs)-maxRecords:], true, nil
+	}
+	return recs, false, nil
+}
+
+// WaitForRecords waits for ref through backend's RecordWaiter when it has one. Otherwise it reads
+// the records once with ReadRecords and does not wait.
+func WaitForRecords(ctx context.Context, backend StreamBackend, ref foundry.DatasetRef, want int, timeout time.Duration) ([]map[string]any, error) {
+	if w, ok := backend.(RecordWaiter); ok {
+		return w.WaitForRecords(ctx, ref, want, timeout)
+	}
+	return backend.ReadRecords(ctx, ref)
+}
+
+// LegacyStreamProxyBackend implements StreamBackend, LimitedRecordReader, RecordWaiter, and
+// PublishProber using the legacy /streams/{rid}/branches/{branch}/records and /jsonRecord endpoints.
 type LegacyStreamProxyBackend struct {
-	client *foundry.Client
-	retry  RetryPolicy
+	client       *foundry.Client
+	retry        RetryPolicy
+	publish      PublishRetryPolicy
+	partitionKey PartitionKeyFunc
+	exactlyOnce  bool
+}
+
+// Stream delivery semantics. Retried publishes are at-least-once: a publish whose acknowledgement
+// is lost is stored again on retry. Exactly-once attaches an idempotency key derived from the
+// record to every publish, so a retry is deduplicated, and requires an explicit acknowledgement; it
+// only holds on stacks (or mocks) that deduplicate by foundry.IdempotencyKeyHeader, and only for
+// retries within PublishRetryPolicy.IdempotencyWindow.
+const (
+	StreamDeliveryAtLeastOnce = "at-least-once"
+	StreamDeliveryExactlyOnce = "exactly-once"
+)
+
+// PartitionKeyFunc derives the stream partition key for a record. An empty key publishes the
+// record unkeyed.
+type PartitionKeyFunc func(record map[string]any) string
+
+// PartitionKeyFromField returns a PartitionKeyFunc that keys records by the named field.
+func PartitionKeyFromField(field string) PartitionKeyFunc {
+	return func(record map[string]any) string {
+		v, ok := record[field]
+		if !ok || v == nil {
+			return ""
+		}
+		return strings.TrimSpace(fmt.Sprint(v))
+	}
 }
 
+// DefaultPartitionKey keys records by email so every record for one email lands on the same
+// partition and keeps its publish order.
+var DefaultPartitionKey = PartitionKeyFromField("email")
+
 // NewLegacyStreamProxyBackend constructs a stream backend for the current
 // compute-module-compatible stream-proxy surface.
 func NewLegacyStreamProxyBackend(client *foundry.Client) *LegacyStreamProxyBackend {
 	return &LegacyStreamProxyBackend{
-		client: client,
-		retry:  DefaultRetryPolicy,
+		client:       client,
+		retry:        DefaultRetryPolicy,
+		partitionKey: DefaultPartitionKey,
 	}
 }
 
@@ -42,6 +124,30 @@ func (b *LegacyStreamProxyBackend) WithRetryPolicy(policy RetryPolicy) *LegacySt
 	return &cp
 }
 
+// WithPublishRetryPolicy returns a copy of the backend whose publishes retry under policy instead
+// of the backend's RetryPolicy; only the RetryPolicy's error-name lists still apply to them.
+func (b *LegacyStreamProxyBackend) WithPublishRetryPolicy(policy PublishRetryPolicy) *LegacyStreamProxyBackend {
+	cp := *b
+	cp.publish = policy
+	return &cp
+}
+
+// WithPartitionKey returns a copy of the backend that derives partition keys with fn. A nil fn
+// publishes records unkeyed.
+func (b *LegacyStreamProxyBackend) WithPartitionKey(fn PartitionKeyFunc) *LegacyStreamProxyBackend {
+	cp := *b
+	cp.partitionKey = fn
+	return &cp
+}
+
+// WithExactlyOnce returns a copy of the backend that publishes with idempotency keys and verifies
+// acknowledgements; see StreamDeliveryExactlyOnce.
+func (b *LegacyStreamProxyBackend) WithExactlyOnce() *LegacyStreamProxyBackend {
+	cp := *b
+	cp.exactlyOnce = true
+	return &cp
+}
+
 func (b *LegacyStreamProxyBackend) Probe(ctx context.Context, ref foundry.DatasetRef) (bool, error) {
 	if b == nil || b.client == nil {
 		return false, fmt.Errorf("legacy stream-proxy backend requires a foundry client")
@@ -76,13 +182,71 @@ func (b *LegacyStreamProxyBackend) ReadRecords(ctx context.Context, ref foundry.
 	return records, nil
 }
 
+func (b *LegacyStreamProxyBackend) ReadRecordsLimit(ctx context.Context, ref foundry.DatasetRef, maxRecords int) ([]map[string]any, bool, error) {
+	if b == nil || b.client == nil {
+		return nil, false, fmt.Errorf("legacy stream-proxy backend requires a foundry client")
+	}
+	branch := defaultBranch(ref.Branch)
+	var records []map[string]any
+	truncated := false
+	err := RetryTransient(ctx, b.retry, func() error {
+		var err error
+		records, truncated, err = b.client.ReadStreamRecordsLimit(ctx, ref.RID, branch, maxRecords)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return records, truncated, nil
+}
+
+func (b *LegacyStreamProxyBackend) WaitForRecords(ctx context.Context, ref foundry.DatasetRef, want int, timeout time.Duration) ([]map[string]any, error) {
+	if b == nil || b.client == nil {
+		return nil, fmt.Errorf("legacy stream-proxy backend requires a foundry client")
+	}
+	return b.client.WaitForStreamRecords(ctx, ref.RID, defaultBranch(ref.Branch), want, timeout)
+}
+
 func (b *LegacyStreamProxyBackend) PublishRecord(ctx context.Context, ref foundry.DatasetRef, record map[string]any) error {
+	if b == nil || b.client == nil {
+		return fmt.Errorf("legacy stream-proxy backend requires a foundry client")
+	}
+	branch := defaultBranch(ref.Branch)
+	opts := foundry.StreamPublishOptions{VerifyAck: b.exactlyOnce}
+	if b.partitionKey != nil {
+		opts.PartitionKey = b.partitionKey(record)
+	}
+	if b.exactlyOnce {
+		// The key must stay the same across retries of this record, so derive it from the content.
+		key, err := recordIdempotencyKey(record)
+		if err != nil {
+			return err
+		}
+		opts.IdempotencyKey = key
+	}
+	return RetryTransient(ctx, b.publish.retryPolicy(b.retry, opts.IdempotencyKey != ""), func() error {
+		return b.client.PublishStreamJSONRecordWithOptions(ctx, ref.RID, branch, record, opts)
+	})
+}
+
+// recordIdempotencyKey is the hex SHA-256 of the record's JSON encoding (map keys are sorted, so
+// equal records get equal keys).
+func recordIdempotencyKey(record map[string]any) (string, error) {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (b *LegacyStreamProxyBackend) ProbePublish(ctx context.Context, ref foundry.DatasetRef) error {
 	if b == nil || b.client == nil {
 		return fmt.Errorf("legacy stream-proxy backend requires a foundry client")
 	}
 	branch := defaultBranch(ref.Branch)
 	return RetryTransient(ctx, b.retry, func() error {
-		return b.client.PublishStreamJSONRecord(ctx, ref.RID, branch, record)
+		return b.client.ProbeStreamPublish(ctx, ref.RID, branch)
 	})
 }
 
@@ -1,30 +1,172 @@
 package local
 
 import (
-	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"unicode"
 )
 
-// ReadEmailsCSV reads a CSV file and returns the values from the "email" column.
+// DefaultEmailColumnAliases are the header names accepted for the email column when no "email"
+// column exists.
+var DefaultEmailColumnAliases = []string{"e-mail", "email_address", "mail"}
+
+// CSVReadOptions configures how the input readers pick emails out of a CSV: the size limits, how
+// the email column is found in the header, and which rows are skipped.
+type CSVReadOptions struct {
+	CSVLimits
+
+	// SkipBlankRows makes ReadEmailsCSV, and ReadEmailItemsCSV without explicit email columns, drop
+	// rows whose fields are all empty or whitespace instead of returning them as empty emails (which
+	// the pipeline turns into error rows). A row with other values but an empty email is still
+	// returned. encoding/csv already drops lines that are completely empty; this covers lines such
+	// as " " or ",,".
+	SkipBlankRows bool
+	// SkipCommentRows makes the readers drop emails whose cell starts with "#" (after trimming
+	// space), so a "# note" line in a hand-edited input is not enriched.
+	SkipCommentRows bool
+
+	// EmailColumnAliases replaces DefaultEmailColumnAliases as the header names accepted for the
+	// email column when no "email" column exists. Aliases match after normalization (see
+	// normalizeColumnName), so "e-mail" also accepts "E-Mail" and "E Mail". Nil uses the defaults.
+	EmailColumnAliases []string
+	// NoEmailColumnAliases disables the alias fallback: the input must have an "email" column.
+	NoEmailColumnAliases bool
+}
+
+// emailColumnAliases returns the aliases the email column falls back to.
+func (o CSVReadOptions) emailColumnAliases() []string {
+	switch {
+	case o.NoEmailColumnAliases:
+		return nil
+	case o.EmailColumnAliases != nil:
+		return o.EmailColumnAliases
+	default:
+		return DefaultEmailColumnAliases
+	}
+}
+
+// blankRow reports whether every field of rec is empty or whitespace.
+func blankRow(rec []string) bool {
+	for _, v := range rec {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// commentEmail reports whether o.SkipCommentRows drops the email cell v.
+func (o CSVReadOptions) commentEmail(v string) bool {
+	return o.SkipCommentRows && strings.HasPrefix(strings.TrimSpace(v), "#")
+}
+
+// ErrAmbiguousEmailColumn is returned (wrapped) when no "email" column exists and several columns
+// match an email column alias.
+var ErrAmbiguousEmailColumn = errors.New("ambiguous email column")
+
+// DefaultEmailColumn is the input column emails are read from unless another is named.
+const DefaultEmailColumn = "email"
+
+// emailColumnIndex returns the index of column, matched case-insensitively after trimming space. An
+// empty column means DefaultEmailColumn, which falls back to the single column matching one of
+// aliases when absent; other columns must exist as named.
+func emailColumnIndex(header []string, column string, aliases []string) (int, error) {
+	column = strings.TrimSpace(column)
+	if column == "" {
+		column = DefaultEmailColumn
+	}
+	if !strings.EqualFold(column, DefaultEmailColumn) {
+		idxs, err := columnIndexes(header, []string{column})
+		if err != nil {
+			return -1, err
+		}
+		return idxs[0], nil
+	}
+	if idxs, err := columnIndexes(header, []string{DefaultEmailColumn}); err == nil {
+		return idxs[0], nil
+	}
+	normalized := make(map[string]bool, len(aliases))
+	for _, alias := range aliases {
+		if n := normalizeColumnName(alias); n != "" {
+			normalized[n] = true
+		}
+	}
+	var matches []int
+	for i, col := range header {
+		if i == 0 {
+			col = strings.TrimPrefix(col, "\uFEFF")
+		}
+		if normalized[normalizeColumnName(col)] {
+			matches = append(matches, i)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return -1, fmt.Errorf("missing required column %q (or one of the aliases %q)", "email", aliases)
+	case 1:
+		return matches[0], nil
+	default:
+		names := make([]string, len(matches))
+		for i, idx := range matches {
+			names[i] = strings.TrimPrefix(header[idx], "\uFEFF")
+		}
+		return -1, fmt.Errorf("%w: columns %q all match an email alias; rename all but one or name the column explicitly", ErrAmbiguousEmailColumn, names)
+	}
+}
+
+// normalizeColumnName lowercases name and drops everything but letters and digits, so "Email
+// Address", "email_address", and "E-mail-address" compare equal.
+func normalizeColumnName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// ReadEmailsCSV reads a CSV file and returns the values from the "email" column (or a
+// DefaultEmailColumnAliases match), with default CSVReadOptions.
 func ReadEmailsCSV(r io.Reader) ([]string, error) {
-	cr := csv.NewReader(r)
-	cr.FieldsPerRecord = -1
+	return ReadEmailsCSVWithOptions(r, CSVReadOptions{})
+}
+
+// ReadEmailsCSVWithLimits is ReadEmailsCSV with explicit field and record size limits.
+func ReadEmailsCSVWithLimits(r io.Reader, limits CSVLimits) ([]string, error) {
+	return ReadEmailsCSVWithOptions(r, CSVReadOptions{CSVLimits: limits})
+}
+
+// ReadEmailsCSVWithOptions is ReadEmailsCSV with explicit read options.
+func ReadEmailsCSVWithOptions(r io.Reader, opts CSVReadOptions) ([]string, error) {
+	return ReadColumnCSVWithOptions(r, DefaultEmailColumn, opts)
+}
+
+// ReadColumnCSV is ReadEmailsCSV reading the named column instead, for inputs whose email column
+// is called, for example, "contact_email". The column is matched case-insensitively after trimming
+// space, and values are trimmed. An empty column or "email" keeps the email column alias fallback.
+func ReadColumnCSV(r io.Reader, column string) ([]string, error) {
+	return ReadColumnCSVWithOptions(r, column, CSVReadOptions{})
+}
+
+// ReadColumnCSVWithLimits is ReadColumnCSV with explicit field and record size limits.
+func ReadColumnCSVWithLimits(r io.Reader, column string, limits CSVLimits) ([]string, error) {
+	return ReadColumnCSVWithOptions(r, column, CSVReadOptions{CSVLimits: limits})
+}
+
+// ReadColumnCSVWithOptions is ReadColumnCSV with explicit read options.
+func ReadColumnCSVWithOptions(r io.Reader, column string, opts CSVReadOptions) ([]string, error) {
+	cr := NewCSVReader(r, opts.CSVLimits)
 
 	header, err := cr.Read()
 	if err != nil {
 		return nil, fmt.Errorf("read header: %w", err)
 	}
-	emailIdx := -1
-	for i, col := range header {
-		if strings.EqualFold(strings.TrimSpace(col), "email") {
-			emailIdx = i
-			break
-		}
-	}
-	if emailIdx < 0 {
-		return nil, fmt.Errorf("missing required column %q", "email")
+	emailIdx, err := emailColumnIndex(header, column, opts.emailColumnAliases())
+	if err != nil {
+		return nil, err
 	}
 
 	var emails []string
@@ -36,10 +178,139 @@ func ReadEmailsCSV(r io.Reader) ([]string, error) {
 		if err != nil {
 			return nil, fmt.Errorf("read row: %w", err)
 		}
+		if opts.SkipBlankRows && blankRow(rec) {
+			continue
+		}
 		if emailIdx >= len(rec) {
 			return nil, fmt.Errorf("row has %d columns, want at least %d", len(rec), emailIdx+1)
 		}
-		emails = append(emails, rec[emailIdx])
+		if opts.commentEmail(rec[emailIdx]) {
+			continue
+		}
+		emails = append(emails, strings.TrimSpace(rec[emailIdx]))
 	}
 	return emails, nil
 }
+
+// EmailItem is one email read from an input row.
+type EmailItem struct {
+	Email string
+	// SourceRow is the 0-based index of the data row the email was read from.
+	SourceRow int
+	// Passthrough holds the values of the requested passthrough columns from the same row, in
+	// request order. It is nil when no passthrough columns were requested.
+	Passthrough []string
+}
+
+// ReadEmailColumnsCSV reads the named email columns and returns one item per non-empty email,
+// in row order and then column order. Column names are matched case-insensitively.
+func ReadEmailColumnsCSV(r io.Reader, columns []string) ([]EmailItem, error) {
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("at least one email column is required")
+	}
+	return ReadEmailItemsCSV(r, columns, nil)
+}
+
+// ReadEmailItemsCSV is ReadEmailColumnsCSV that also carries the named passthrough columns on each
+// item. With no email columns it reads the single "email" column (or an email column alias match)
+// and, like ReadEmailsCSV, keeps rows whose email is empty unless CSVReadOptions.SkipBlankRows drops them.
+func ReadEmailItemsCSV(r io.Reader, emailColumns, passthrough []string) ([]EmailItem, error) {
+	return ReadEmailItemsCSVWithOptions(r, emailColumns, passthrough, CSVReadOptions{})
+}
+
+// ReadEmailItemsCSVWithLimits is ReadEmailItemsCSV with explicit field and record size limits.
+func ReadEmailItemsCSVWithLimits(r io.Reader, emailColumns, passthrough []string, limits CSVLimits) ([]EmailItem, error) {
+	return ReadEmailItemsCSVWithOptions(r, emailColumns, passthrough, CSVReadOptions{CSVLimits: limits})
+}
+
+// ReadEmailItemsCSVWithOptions is ReadEmailItemsCSV with explicit read options.
+func ReadEmailItemsCSVWithOptions(r io.Reader, emailColumns, passthrough []string, opts CSVReadOptions) ([]EmailItem, error) {
+	return readEmailItems(r, DefaultEmailColumn, emailColumns, passthrough, opts)
+}
+
+// ReadColumnItemsCSVWithOptions is ReadEmailItemsCSVWithOptions without explicit email columns,
+// reading the named column (see ReadColumnCSV) in place of "email".
+func ReadColumnItemsCSVWithOptions(r io.Reader, column string, passthrough []string, opts CSVReadOptions) ([]EmailItem, error) {
+	return readEmailItems(r, column, nil, passthrough, opts)
+}
+
+// readEmailItems reads emailColumns, or the single column when there are none.
+func readEmailItems(r io.Reader, column string, emailColumns, passthrough []string, opts CSVReadOptions) ([]EmailItem, error) {
+	keepEmpty := len(emailColumns) == 0
+
+	cr := NewCSVReader(r, opts.CSVLimits)
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	var idxs []int
+	if keepEmpty {
+		idx, err := emailColumnIndex(header, column, opts.emailColumnAliases())
+		if err != nil {
+			return nil, err
+		}
+		idxs = []int{idx}
+	} else if idxs, err = columnIndexes(header, emailColumns); err != nil {
+		return nil, err
+	}
+	passIdxs, err := columnIndexes(header, passthrough)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []EmailItem
+	for row := 0; ; row++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read row: %w", err)
+		}
+		if keepEmpty && opts.SkipBlankRows && blankRow(rec) {
+			continue
+		}
+		var values []string
+		if len(passIdxs) > 0 {
+			values = make([]string, len(passIdxs))
+			for i, idx := range passIdxs {
+				if idx < len(rec) {
+					values[i] = rec[idx]
+				}
+			}
+		}
+		for _, idx := range idxs {
+			email := ""
+			if idx < len(rec) {
+				email = strings.TrimSpace(rec[idx])
+			}
+			if (email == "" && !keepEmpty) || opts.commentEmail(email) {
+				continue
+			}
+			items = append(items, EmailItem{Email: email, SourceRow: row, Passthrough: values})
+		}
+	}
+}
+
+// columnIndexes returns the header index of each named column, matched case-insensitively.
+func columnIndexes(header, columns []string) ([]int, error) {
+	idxs := make([]int, 0, len(columns))
+	for _, want := range columns {
+		idx := -1
+		for i, col := range header {
+			if i == 0 {
+				col = strings.TrimPrefix(col, "\uFEFF")
+			}
+			if strings.EqualFold(strings.TrimSpace(col), strings.TrimSpace(want)) {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return nil, fmt.Errorf("missing required column %q", want)
+		}
+		idxs = append(idxs, idx)
+	}
+	return idxs, nil
+}
@@ -1,6 +1,8 @@
 package local_test
 
 import (
+	"errors"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -38,3 +40,204 @@ func TestReadEmailsCSV(t *testing.T) {
 		}
 	})
 }
+
+func TestReadEmailColumnsCSV(t *testing.T) {
+	t.Run("fans out non-empty emails with source rows", func(t *testing.T) {
+		in := "id,Primary_Email,secondary_email\n1,alice@example.com,alice@home.test\n2,bob@corp.test,\n3,,carol@new.test\n"
+		got, err := local.ReadEmailColumnsCSV(strings.NewReader(in), []string{"primary_email", "secondary_email"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []local.EmailItem{
+			{Email: "alice@example.com", SourceRow: 0},
+			{Email: "alice@home.test", SourceRow: 0},
+			{Email: "bob@corp.test", SourceRow: 1},
+			{Email: "carol@new.test", SourceRow: 2},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("unexpected items:\nwant=%#v\ngot=%#v", want, got)
+		}
+	})
+
+	t.Run("missing column errors", func(t *testing.T) {
+		in := "primary_email\nalice@example.com\n"
+		_, err := local.ReadEmailColumnsCSV(strings.NewReader(in), []string{"primary_email", "secondary_email"})
+		if err == nil || !strings.Contains(err.Error(), "secondary_email") {
+			t.Fatalf("expected missing column error, got %v", err)
+		}
+	})
+
+	t.Run("carries passthrough columns", func(t *testing.T) {
+		in := "customer_id,email,region\nc-1,alice@example.com,eu\nc-2,,us\n"
+		got, err := local.ReadEmailItemsCSV(strings.NewReader(in), nil, []string{"Customer_ID"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []local.EmailItem{
+			{Email: "alice@example.com", SourceRow: 0, Passthrough: []string{"c-1"}},
+			{Email: "", SourceRow: 1, Passthrough: []string{"c-2"}},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("unexpected items:\nwant=%#v\ngot=%#v", want, got)
+		}
+	})
+}
+
+func TestReadEmailsCSV_ColumnAliases(t *testing.T) {
+	for _, header := range []string{"e-mail", "E-Mail", "email_address", "Email Address", "\uFEFFEmail-Address", "mail", " MAIL "} {
+		t.Run(header, func(t *testing.T) {
+			in := "id," + header + "\n1,alice@example.com\n"
+			got, err := local.ReadEmailsCSV(strings.NewReader(in))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != 1 || got[0] != "alice@example.com" {
+				t.Fatalf("unexpected emails: %#v", got)
+			}
+		})
+	}
+
+	t.Run("email column is primary", func(t *testing.T) {
+		in := "mail,e-mail,Email\nx,y,alice@example.com\n"
+		got, err := local.ReadEmailsCSV(strings.NewReader(in))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 1 || got[0] != "alice@example.com" {
+			t.Fatalf("unexpected emails: %#v", got)
+		}
+	})
+
+	t.Run("several alias matches are ambiguous", func(t *testing.T) {
+		in := "mail,Email Address\nx,alice@example.com\n"
+		_, err := local.ReadEmailsCSV(strings.NewReader(in))
+		if !errors.Is(err, local.ErrAmbiguousEmailColumn) {
+			t.Fatalf("expected ErrAmbiguousEmailColumn, got %v", err)
+		}
+		if !strings.Contains(err.Error(), `"mail"`) || !strings.Contains(err.Error(), `"Email Address"`) {
+			t.Fatalf("expected the error to name both columns, got %v", err)
+		}
+	})
+
+	t.Run("items use aliases without explicit email columns", func(t *testing.T) {
+		in := "E-mail,customer_id\nalice@example.com,c1\n"
+		got, err := local.ReadEmailItemsCSV(strings.NewReader(in), nil, []string{"customer_id"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []local.EmailItem{{Email: "alice@example.com", SourceRow: 0, Passthrough: []string{"c1"}}}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %#v, want %#v", got, want)
+		}
+	})
+
+	t.Run("limits replace or disable the aliases", func(t *testing.T) {
+		in := "contact,mail\nalice@example.com,x\n"
+		got, err := local.ReadEmailsCSVWithOptions(strings.NewReader(in), local.CSVReadOptions{EmailColumnAliases: []string{"Contact"}})
+		if err != nil || len(got) != 1 || got[0] != "alice@example.com" {
+			t.Fatalf("expected the replaced alias to match, got %q, %v", got, err)
+		}
+		if _, err := local.ReadEmailsCSVWithOptions(strings.NewReader(in), local.CSVReadOptions{NoEmailColumnAliases: true}); err == nil {
+			t.Fatalf("expected disabled aliases to require an email column")
+		}
+	})
+}
+
+func TestReadColumnCSV(t *testing.T) {
+	in := "id, Contact_Email ,email\n1, alice@example.com ,x@other.test\n2,bob@example.com,\n"
+	got, err := local.ReadColumnCSV(strings.NewReader(in), " contact_email ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"alice@example.com", "bob@example.com"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	items, err := local.ReadColumnItemsCSVWithOptions(strings.NewReader(in), "CONTACT_EMAIL", []string{"id"}, local.CSVReadOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 2 || items[1].Email != "bob@example.com" || items[1].Passthrough[0] != "2" {
+		t.Fatalf("unexpected items: %#v", items)
+	}
+
+	// A named column does not fall back to the email aliases.
+	_, err = local.ReadColumnCSV(strings.NewReader("id,email_address\n1,alice@example.com\n"), "contact_email")
+	if err == nil || !strings.Contains(err.Error(), `missing required column "contact_email"`) {
+		t.Fatalf("expected missing column error, got %v", err)
+	}
+
+	// An empty column reads "email", as ReadEmailsCSV does.
+	got, err = local.ReadColumnCSV(strings.NewReader("id,email_address\n1,alice@example.com\n"), "")
+	if err != nil || len(got) != 1 || got[0] != "alice@example.com" {
+		t.Fatalf("unexpected result: %q, %v", got, err)
+	}
+}
+
+func TestReadEmailsCSV_SkipBlankAndCommentRows(t *testing.T) {
+	// A whitespace-only line, a row of empty fields, a comment line, and a populated row with an
+	// empty email; encoding/csv already drops the fully empty line.
+	in := "email,name\nalice@example.com,Alice\n\n   \n,\n# imported 2024-01-01\n ,Bob\nbob@corp.test,Bob\n"
+
+	cases := []struct {
+		name          string
+		skipBlank     bool
+		skipComments  bool
+		want          []string
+		wantItemsRows []int
+	}{
+		{
+			name:          "defaults keep every row",
+			want:          []string{"alice@example.com", "", "", "# imported 2024-01-01", "", "bob@corp.test"},
+			wantItemsRows: []int{0, 1, 2, 3, 4, 5},
+		},
+		{
+			name:          "blank rows skipped",
+			skipBlank:     true,
+			want:          []string{"alice@example.com", "# imported 2024-01-01", "", "bob@corp.test"},
+			wantItemsRows: []int{0, 3, 4, 5},
+		},
+		{
+			name:          "blank and comment rows skipped",
+			skipBlank:     true,
+			skipComments:  true,
+			want:          []string{"alice@example.com", "", "bob@corp.test"},
+			wantItemsRows: []int{0, 4, 5},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := local.CSVReadOptions{SkipBlankRows: tc.skipBlank, SkipCommentRows: tc.skipComments}
+
+			got, err := local.ReadEmailsCSVWithOptions(strings.NewReader(in), opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+
+			items, err := local.ReadEmailItemsCSVWithOptions(strings.NewReader(in), nil, []string{"name"}, opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var rows []int
+			for _, item := range items {
+				rows = append(rows, item.SourceRow)
+			}
+			if !reflect.DeepEqual(rows, tc.wantItemsRows) {
+				t.Fatalf("got item rows %v, want %v", rows, tc.wantItemsRows)
+			}
+		})
+	}
+
+	t.Run("comment emails in explicit columns", func(t *testing.T) {
+		got, err := local.ReadEmailItemsCSVWithOptions(strings.NewReader("work,home\n#work@corp.test,home@example.com\n"), []string{"work", "home"}, nil, local.CSVReadOptions{SkipCommentRows: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 1 || got[0].Email != "home@example.com" {
+			t.Fatalf("expected only the uncommented email, got %#v", got)
+		}
+	})
+}
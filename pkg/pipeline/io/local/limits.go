@@ -0,0 +1,106 @@
+package local
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// DefaultMaxFieldBytes is the default cap on a single CSV field.
+	DefaultMaxFieldBytes = 1 << 20
+	// DefaultMaxRecordBytes is the default cap on a single CSV record, including separators and
+	// quoting.
+	DefaultMaxRecordBytes = 4 << 20
+)
+
+// ErrCSVLimitExceeded is returned (wrapped) when a CSV field or record exceeds its CSVLimits cap.
+var ErrCSVLimitExceeded = errors.New("csv size limit exceeded")
+
+// CSVLimits caps field and record sizes when reading untrusted CSV. encoding/csv buffers a whole
+// record before returning it, so without a cap one malformed field can exhaust memory. Zero values
+// use DefaultMaxFieldBytes and DefaultMaxRecordBytes.
+type CSVLimits struct {
+	MaxFieldBytes  int
+	MaxRecordBytes int
+}
+
+func (l CSVLimits) withDefaults() CSVLimits {
+	if l.MaxFieldBytes <= 0 {
+		l.MaxFieldBytes = DefaultMaxFieldBytes
+	}
+	if l.MaxRecordBytes <= 0 {
+		l.MaxRecordBytes = DefaultMaxRecordBytes
+	}
+	return l
+}
+
+// csvReadAhead is the slack allowed for encoding/csv's internal bufio read-ahead past the current
+// record (bufio's default buffer size).
+const csvReadAhead = 4096
+
+// CSVReader is a csv.Reader (FieldsPerRecord = -1) that enforces CSVLimits.
+type CSVReader struct {
+	cr     *csv.Reader
+	src    *countingReader
+	limits CSVLimits
+	record int
+}
+
+// NewCSVReader returns a CSVReader over r.
+func NewCSVReader(r io.Reader, limits CSVLimits) *CSVReader {
+	limits = limits.withDefaults()
+	src := &countingReader{r: r}
+	cr := csv.NewReader(src)
+	cr.FieldsPerRecord = -1
+	return &CSVReader{cr: cr, src: src, limits: limits}
+}
+
+// Read returns the next record. It fails with an error wrapping ErrCSVLimitExceeded once the record
+// being read outgrows MaxRecordBytes (before it is fully buffered), or when a field exceeds
+// MaxFieldBytes.
+func (c *CSVReader) Read() ([]string, error) {
+	c.record++
+	start := c.cr.InputOffset()
+	c.src.limit = start + int64(c.limits.MaxRecordBytes) + csvReadAhead
+	c.src.exceeded = func() error { return c.recordTooLarge() }
+
+	rec, err := c.cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	if size := c.cr.InputOffset() - start; size > int64(c.limits.MaxRecordBytes) {
+		return nil, c.recordTooLarge()
+	}
+	for i, field := range rec {
+		if len(field) > c.limits.MaxFieldBytes {
+			return nil, fmt.Errorf("%w: record %d field %d is %d bytes (max-field-bytes=%d)", ErrCSVLimitExceeded, c.record, i+1, len(field), c.limits.MaxFieldBytes)
+		}
+	}
+	return rec, nil
+}
+
+func (c *CSVReader) recordTooLarge() error {
+	return fmt.Errorf("%w: record %d exceeds %d bytes (max-record-bytes)", ErrCSVLimitExceeded, c.record, c.limits.MaxRecordBytes)
+}
+
+// countingReader fails once more than limit bytes have been read in total.
+type countingReader struct {
+	r        io.Reader
+	n        int64
+	limit    int64
+	exceeded func() error
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	if c.n >= c.limit {
+		return 0, c.exceeded()
+	}
+	if rem := c.limit - c.n; int64(len(p)) > rem {
+		p = p[:rem]
+	}
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,75 @@
+package local_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/io/local"
+)
+
+// endlessReader yields an unterminated quoted field that never ends.
+type endlessReader struct{ started bool }
+
+func (r *endlessReader) Read(p []byte) (int, error) {
+	if !r.started {
+		r.started = true
+		return copy(p, "email\n\""), nil
+	}
+	for i := range p {
+		p[i] = 'x'
+	}
+	return len(p), nil
+}
+
+func TestReadEmailsCSVWithLimits(t *testing.T) {
+	limits := local.CSVLimits{MaxFieldBytes: 32, MaxRecordBytes: 64}
+
+	t.Run("normal input passes", func(t *testing.T) {
+		got, err := local.ReadEmailsCSVWithLimits(strings.NewReader("email,note\nalice@example.com,hi\n"), limits)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 1 || got[0] != "alice@example.com" {
+			t.Fatalf("unexpected emails: %#v", got)
+		}
+	})
+
+	cases := []struct {
+		name    string
+		in      io.Reader
+		wantErr string
+	}{
+		{
+			name:    "oversized field",
+			in:      strings.NewReader("email,note\nalice@example.com," + strings.Repeat("x", 33) + "\n"),
+			wantErr: "record 2 field 2 is 33 bytes (max-field-bytes=32)",
+		},
+		{
+			name:    "oversized record",
+			in:      strings.NewReader("email,a,b,c\nalice@example.com," + strings.Repeat("y", 24) + "," + strings.Repeat("y", 24) + ",z\n"),
+			wantErr: "record 2 exceeds 64 bytes (max-record-bytes)",
+		},
+		{
+			name:    "unterminated quoted field stops reading",
+			in:      &endlessReader{},
+			wantErr: "record 2 exceeds 64 bytes (max-record-bytes)",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := local.ReadEmailsCSVWithLimits(tc.in, limits)
+			if !errors.Is(err, local.ErrCSVLimitExceeded) || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected %q limit error, got %v", tc.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("defaults allow large fields", func(t *testing.T) {
+		in := "email,note\nalice@example.com," + strings.Repeat("x", 64<<10) + "\n"
+		if _, err := local.ReadEmailItemsCSV(strings.NewReader(in), nil, []string{"note"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+// Package output resolves URI-like output destinations to named core.OutputAdapter factories.
+package output
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/core"
+)
+
+// Target is a parsed output destination of the form "<scheme>://<location>".
+type Target struct {
+	Scheme   string
+	Location string
+}
+
+func (t Target) String() string {
+	return t.Scheme + "://" + t.Location
+}
+
+var schemePrefix = regexp.MustCompile(`^([a-z][a-z0-9+.-]*)://`)
+
+// ParseTarget parses v as "<scheme>://<location>". A value without a scheme prefix (for example a
+// plain file path) is a location for defaultScheme.
+func ParseTarget(v, defaultScheme string) Target {
+	v = strings.TrimSpace(v)
+	if m := schemePrefix.FindStringSubmatch(v); m != nil {
+		return Target{Scheme: m[1], Location: v[len(m[0]):]}
+	}
+	return Target{Scheme: defaultScheme, Location: v}
+}
+
+// Factory opens an output adapter for a target location.
+type Factory[Out any] func(location string) (core.OutputAdapter[Out], error)
+
+// Registry maps output schemes to adapter factories.
+type Registry[Out any] struct {
+	factories map[string]Factory[Out]
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry[Out any]() *Registry[Out] {
+	return &Registry[Out]{factories: make(map[string]Factory[Out])}
+}
+
+// Register adds a factory for scheme. It panics if scheme is already registered.
+func (r *Registry[Out]) Register(scheme string, f Factory[Out]) {
+	if _, ok := r.factories[scheme]; ok {
+		panic(fmt.Sprintf("output: scheme %q registered twice", scheme))
+	}
+	r.factories[scheme] = f
+}
+
+// Open returns the adapter for t.
+func (r *Registry[Out]) Open(t Target) (core.OutputAdapter[Out], error) {
+	f, ok := r.factories[t.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("invalid output scheme %q (expected %s)", t.Scheme, strings.Join(r.Schemes(), "|"))
+	}
+	return f(t.Location)
+}
+
+// Schemes returns the registered schemes in sorted order.
+func (r *Registry[Out]) Schemes() []string {
+	out := make([]string, 0, len(r.factories))
+	for scheme := range r.factories {
+		out = append(out, scheme)
+	}
+	sort.Strings(out)
+	return out
+}
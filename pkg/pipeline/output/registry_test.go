@@ -0,0 +1,57 @@
+package output_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/core"
+	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/output"
+)
+
+func TestParseTarget(t *testing.T) {
+	cases := []struct {
+		in   string
+		want output.Target
+	}{
+		{"out/enriched.csv", output.Target{Scheme: "local-csv", Location: "out/enriched.csv"}},
+		{"local-csv:///tmp/out.csv", output.Target{Scheme: "local-csv", Location: "/tmp/out.csv"}},
+		{"stdout-ndjson://", output.Target{Scheme: "stdout-ndjson", Location: ""}},
+		{" foundry-stream://output ", output.Target{Scheme: "foundry-stream", Location: "output"}},
+	}
+	for _, tc := range cases {
+		if got := output.ParseTarget(tc.in, "local-csv"); got != tc.want {
+			t.Fatalf("ParseTarget(%q) = %#v, want %#v", tc.in, got, tc.want)
+		}
+	}
+}
+
+type sliceOutput struct{ rows *[]string }
+
+func (o sliceOutput) Store(_ context.Context, rows []string) error {
+	*o.rows = append(*o.rows, rows...)
+	return nil
+}
+
+func TestRegistryOpen(t *testing.T) {
+	var stored []string
+	r := output.NewRegistry[string]()
+	r.Register("memory", func(string) (core.OutputAdapter[string], error) {
+		return sliceOutput{rows: &stored}, nil
+	})
+
+	out, err := r.Open(output.ParseTarget("memory://", ""))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := out.Store(context.Background(), []string{"a", "b"}); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if len(stored) != 2 {
+		t.Fatalf("expected 2 stored rows, got %v", stored)
+	}
+
+	if _, err := r.Open(output.ParseTarget("s3://bucket/key", "")); err == nil || !strings.Contains(err.Error(), `invalid output scheme "s3" (expected memory)`) {
+		t.Fatalf("expected invalid scheme error, got %v", err)
+	}
+}
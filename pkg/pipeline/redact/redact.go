@@ -1,6 +1,9 @@
 package redact
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"regexp"
 	"strings"
 )
@@ -23,3 +26,12 @@ func Secrets(s string) string {
 	out = apiKeyKVRe.ReplaceAllString(out, "<redacted_kv>")
 	return strings.TrimSpace(out)
 }
+
+// HashEmail pseudonymises email as the hex HMAC-SHA256 of its trimmed, lowercased form under key.
+// The same key links one email across records and runs; without the key, a known email cannot be
+// confirmed by hashing it, as it could with a plain SHA-256.
+func HashEmail(key []byte, email string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -25,6 +25,37 @@ type DatasetContract struct {
 	Fields []Field
 }
 
+// Field returns the contract field named name.
+func (c DatasetContract) Field(name string) (Field, bool) {
+	for _, f := range c.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// Zero returns the zero value of the field's type as it is encoded in a JSON record: "" for
+// strings, 0 for numeric types, false for booleans, an empty list for arrays, and an empty object
+// for maps and structs. Types without a zero value (dates, timestamps, binary) and unknown types
+// report false. Type names match case-insensitively.
+func (f Field) Zero() (any, bool) {
+	switch strings.ToUpper(strings.TrimSpace(f.Type)) {
+	case "STRING", "CHAR", "VARCHAR":
+		return "", true
+	case "BYTE", "SHORT", "INTEGER", "LONG", "FLOAT", "DOUBLE", "DECIMAL":
+		return 0, true
+	case "BOOLEAN":
+		return false, true
+	case "ARRAY":
+		return []any{}, true
+	case "MAP", "STRUCT":
+		return map[string]any{}, true
+	default:
+		return nil, false
+	}
+}
+
 func NormalizeMode(raw string) DatasetMode {
 	s := strings.TrimSpace(strings.ToLower(raw))
 	switch s {
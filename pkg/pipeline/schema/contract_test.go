@@ -26,3 +26,41 @@ func TestNormalizeMode(t *testing.T) {
 		})
 	}
 }
+
+func TestFieldZero(t *testing.T) {
+	tests := []struct {
+		typ    string
+		want   any
+		wantOK bool
+	}{
+		{typ: "STRING", want: "", wantOK: true},
+		{typ: "string", want: "", wantOK: true},
+		{typ: "LONG", want: 0, wantOK: true},
+		{typ: "DOUBLE", want: 0, wantOK: true},
+		{typ: "BOOLEAN", want: false, wantOK: true},
+		{typ: "TIMESTAMP"},
+		{typ: "GEOHASH"},
+	}
+	for _, tt := range tests {
+		got, ok := schema.Field{Name: "f", Type: tt.typ}.Zero()
+		if ok != tt.wantOK || (ok && got != tt.want) {
+			t.Fatalf("Zero(%s)=%#v,%t want=%#v,%t", tt.typ, got, ok, tt.want, tt.wantOK)
+		}
+	}
+	if got, ok := (schema.Field{Type: "ARRAY"}).Zero(); !ok || len(got.([]any)) != 0 {
+		t.Fatalf("Zero(ARRAY)=%#v,%t want an empty list", got, ok)
+	}
+}
+
+func TestDatasetContractField(t *testing.T) {
+	c := schema.DatasetContract{Fields: []schema.Field{
+		{Name: "email", Type: "string"},
+		{Name: "company", Type: "string", Nullable: true},
+	}}
+	if f, ok := c.Field("company"); !ok || !f.Nullable {
+		t.Fatalf("Field(company)=%+v,%t want nullable field", f, ok)
+	}
+	if _, ok := c.Field("title"); ok {
+		t.Fatalf("Field(title) found an undeclared field")
+	}
+}
@@ -0,0 +1,73 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrItemCanceled is the error of an item canceled through a Canceler.
+var ErrItemCanceled = errors.New("item canceled")
+
+// Canceler cancels individual in-flight items of a ProcessAll run, for example to skip one stuck
+// request without stopping the rest. Items are keyed by fmt.Sprint(item), passed through the
+// normalize function given to NewCanceler. It is safe for concurrent use, and one Canceler may be
+// shared by consecutive runs.
+type Canceler struct {
+	normalize func(string) string
+
+	mu      sync.Mutex
+	nextID  uint64
+	running map[string]map[uint64]context.CancelCauseFunc
+}
+
+// NewCanceler returns a Canceler whose keys are normalized with normalize (nil keeps them as is).
+func NewCanceler(normalize func(string) string) *Canceler {
+	if normalize == nil {
+		normalize = func(s string) string { return s }
+	}
+	return &Canceler{
+		normalize: normalize,
+		running:   make(map[string]map[uint64]context.CancelCauseFunc),
+	}
+}
+
+// Cancel cancels every in-flight item with key, including its pending retries, and reports whether
+// any was running. Items not yet started, or already finished, are unaffected.
+func (c *Canceler) Cancel(key string) bool {
+	key = c.normalize(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	items := c.running[key]
+	for _, cancel := range items {
+		cancel(ErrItemCanceled)
+	}
+	return len(items) > 0
+}
+
+// register derives a cancelable context for item. The returned release must be called once the
+// item finishes; it unregisters the item and releases its context.
+func (c *Canceler) register(ctx context.Context, item any) (context.Context, func()) {
+	key := c.normalize(fmt.Sprint(item))
+	itemCtx, cancel := context.WithCancelCause(ctx)
+
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	if c.running[key] == nil {
+		c.running[key] = make(map[uint64]context.CancelCauseFunc)
+	}
+	c.running[key][id] = cancel
+	c.mu.Unlock()
+
+	return itemCtx, func() {
+		c.mu.Lock()
+		delete(c.running[key], id)
+		if len(c.running[key]) == 0 {
+			delete(c.running, key)
+		}
+		c.mu.Unlock()
+		cancel(nil)
+	}
+}
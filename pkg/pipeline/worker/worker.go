@@ -3,9 +3,12 @@ package worker
 import (
 	"context"
 	"errors"
+	"io"
+	"math"
 	"math/rand/v2"
 	"net"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/palantir/palantir-compute-module-pipeline-search/pkg/pipeline/core"
@@ -17,12 +20,19 @@ type FailurePolicy int
 const (
 	FailurePolicyPartialOutput FailurePolicy = iota
 	FailurePolicyFailFast
+	// FailurePolicyFailFastKeepPartial stops dispatching new items on the first error but lets
+	// in-flight items finish, and returns the completed results alongside the error.
+	FailurePolicyFailFastKeepPartial
 )
 
 type Options struct {
 	Workers        int
 	MaxRetries     int
 	RequestTimeout time.Duration
+	// RequestTimeoutFactor scales RequestTimeout per attempt: attempt n (0-based) gets
+	// RequestTimeout * RequestTimeoutFactor^n, so retries get progressively longer (> 1) or shorter
+	// (< 1) deadlines. Zero (or a negative value) uses 1, a constant timeout.
+	RequestTimeoutFactor float64
 
 	// RateLimitRPS is a global limit across all workers. Set to <=0 to disable.
 	RateLimitRPS float64
@@ -33,8 +43,38 @@ type Options struct {
 	BackoffInitial time.Duration
 	// BackoffMax caps exponential backoff.
 	BackoffMax time.Duration
-	// BackoffJitterFrac applies +/- jitter to backoff sleeps (0.2 = +/-20%).
+	// BackoffJitterFrac applies +/- jitter to backoff sleeps (0.2 = +/-20%). Zero uses the default
+	// of 0.2; a negative value disables jitter.
 	BackoffJitterFrac float64
+
+	// RampInterval, when positive, starts one worker and adds another every RampInterval up to
+	// Workers, so load increases gently instead of in one burst. Zero starts all workers at once.
+	RampInterval time.Duration
+	// RampAfter waits for one ramp step. Nil uses time.After; tests inject a fake clock.
+	RampAfter func(time.Duration) <-chan time.Time
+
+	// OnRetry, when set, is called before each backoff sleep with the 1-based attempt that failed,
+	// the jittered delay about to be slept, and the attempt's error. It runs on the worker goroutine,
+	// so it must be quick and safe for concurrent use. A provider cooldown (retry-after) pauses the
+	// pool separately and is not included in delay.
+	OnRetry func(attempt int, delay time.Duration, err error)
+
+	// Canceler, when set, registers each in-flight item so Canceler.Cancel can stop that item alone.
+	// A canceled item ends with ErrItemCanceled and, being a deliberate skip, does not trip
+	// FailurePolicyFailFast.
+	Canceler *Canceler
+}
+
+// DeterministicOptions returns Options for reproducible runs such as golden tests of ordering: a
+// single worker, so results complete in input order; no global rate limiter; and jitter-free
+// backoff. Other fields keep their defaults and can be overridden on the returned value.
+func DeterministicOptions() Options {
+	return Options{
+		Workers:           1,
+		RateLimitRPS:      0,
+		FailurePolicy:     FailurePolicyPartialOutput,
+		BackoffJitterFrac: -1,
+	}
 }
 
 // Result holds the output for one input item.
@@ -54,13 +94,16 @@ func (o Options) withDefaults() Options {
 	if o.RequestTimeout <= 0 {
 		o.RequestTimeout = 30 * time.Second
 	}
+	if o.RequestTimeoutFactor <= 0 {
+		o.RequestTimeoutFactor = 1
+	}
 	if o.BackoffInitial <= 0 {
 		o.BackoffInitial = 200 * time.Millisecond
 	}
 	if o.BackoffMax <= 0 {
 		o.BackoffMax = 2 * time.Second
 	}
-	if o.BackoffJitterFrac <= 0 {
+	if o.BackoffJitterFrac == 0 {
 		o.BackoffJitterFrac = 0.2
 	}
 	return o
@@ -78,6 +121,10 @@ func ProcessAll[In any, Out any](
 
 // ProcessAllWithCallback runs the processor over all input items and invokes onResult
 // as each item completes. The callback receives completion-order results.
+//
+// On failure it returns nil results, except under FailurePolicyFailFastKeepPartial, where the
+// results of the items that completed (the failed one included) are returned in input order with
+// the first error. Items never started are left out.
 func ProcessAllWithCallback[In any, Out any](
 	ctx context.Context,
 	items []In,
@@ -94,8 +141,10 @@ func ProcessAllWithCallback[In any, Out any](
 	if opts.RateLimitRPS > 0 {
 		limiter = rate.NewLimiter(rate.Limit(opts.RateLimitRPS), 1)
 	}
+	gate := &pauseGate{}
 
 	out := make([]Result[In, Out], len(items))
+	completed := make([]bool, len(items))
 
 	type job struct {
 		idx int
@@ -111,6 +160,11 @@ func ProcessAllWithCallback[In any, Out any](
 
 	var wg sync.WaitGroup
 
+	keepPartial := opts.FailurePolicy == FailurePolicyFailFastKeepPartial
+	// stopDispatch closes on the first error under FailurePolicyFailFastKeepPartial, which stops
+	// new items without cancelling the in-flight ones.
+	stopDispatch := make(chan struct{})
+
 	var mu sync.Mutex
 	var firstErr error
 	fail := func(err error) {
@@ -120,7 +174,9 @@ func ProcessAllWithCallback[In any, Out any](
 		mu.Lock()
 		if firstErr == nil {
 			firstErr = err
-			if cancel != nil {
+			if keepPartial {
+				close(stopDispatch)
+			} else if cancel != nil {
 				cancel()
 			}
 		}
@@ -130,34 +186,61 @@ func ProcessAllWithCallback[In any, Out any](
 	workerFn := func() {
 		defer wg.Done()
 		for j := range jobs {
-			if runCtx.Err() != nil {
+			if runCtx.Err() != nil || isClosed(stopDispatch) {
+				return
+			}
+			res := processOne(runCtx, j.in, processor, limiter, gate, opts)
+			failed := res.Err != nil && opts.FailurePolicy != FailurePolicyPartialOutput && !errors.Is(res.Err, ErrItemCanceled)
+			if failed && keepPartial {
+				// Stop dispatch before the result is delivered, so onResult never sees the failure
+				// while new items can still start.
+				fail(res.Err)
+			}
+			// Once runCtx is cancelled, a result is still delivered only if it is a success and the
+			// parent ctx was cancelled (a shutdown), so onResult can drain it; the main loop reads
+			// done until every worker has returned. After fail(), in-flight results are dropped.
+			deliver := func() bool { return runCtx.Err() == nil || (res.Err == nil && ctx.Err() != nil) }
+			if !deliver() {
 				return
 			}
-			res := processOne(runCtx, j.in, processor, limiter, opts)
 			select {
 			case done <- completion{idx: j.idx, res: res}:
 			case <-runCtx.Done():
-				return
+				if !deliver() {
+					return
+				}
+				done <- completion{idx: j.idx, res: res}
 			}
-			if res.Err != nil && opts.FailurePolicy == FailurePolicyFailFast {
+			if failed {
 				fail(res.Err)
 				return
 			}
 		}
 	}
 
-	for i := 0; i < opts.Workers; i++ {
-		wg.Add(1)
+	// fed closes once every item has been handed to a worker; ramping stops then, since workers
+	// started later would find no work.
+	fed := make(chan struct{})
+	wg.Add(opts.Workers)
+	if opts.RampInterval > 0 {
 		go workerFn()
+		go rampWorkers(runCtx, opts, fed, workerFn, wg.Done)
+	} else {
+		for i := 0; i < opts.Workers; i++ {
+			go workerFn()
+		}
 	}
 
 	go func() {
 		defer close(jobs)
+		defer close(fed)
 		for i, item := range items {
 			select {
 			case jobs <- job{idx: i, in: item}:
 			case <-runCtx.Done():
 				return
+			case <-stopDispatch:
+				return
 			}
 		}
 	}()
@@ -169,6 +252,7 @@ func ProcessAllWithCallback[In any, Out any](
 
 	for item := range done {
 		out[item.idx] = item.res
+		completed[item.idx] = true
 		if onResult != nil {
 			if err := onResult(item.res); err != nil {
 				fail(err)
@@ -180,6 +264,9 @@ func ProcessAllWithCallback[In any, Out any](
 	err := firstErr
 	mu.Unlock()
 	if err != nil {
+		if keepPartial && ctx.Err() == nil {
+			return completedResults(out, completed), err
+		}
 		return nil, err
 	}
 	if err := ctx.Err(); err != nil {
@@ -188,14 +275,66 @@ func ProcessAllWithCallback[In any, Out any](
 	return out, nil
 }
 
+// completedResults returns the results marked completed, in input order.
+func completedResults[In any, Out any](out []Result[In, Out], completed []bool) []Result[In, Out] {
+	kept := make([]Result[In, Out], 0, len(out))
+	for i, res := range out {
+		if completed[i] {
+			kept = append(kept, res)
+		}
+	}
+	return kept
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// rampWorkers starts the remaining opts.Workers-1 workers one per RampInterval. When ctx ends or
+// fed closes first, the workers not yet started are released with skip.
+func rampWorkers(ctx context.Context, opts Options, fed <-chan struct{}, start func(), skip func()) {
+	after := opts.RampAfter
+	if after == nil {
+		after = time.After
+	}
+	for started := 1; started < opts.Workers; started++ {
+		select {
+		case <-after(opts.RampInterval):
+			go start()
+			continue
+		case <-ctx.Done():
+		case <-fed:
+		}
+		for ; started < opts.Workers; started++ {
+			skip()
+		}
+		return
+	}
+}
+
 func processOne[In any, Out any](
 	ctx context.Context,
 	item In,
 	processor func(context.Context, In) (Out, error),
 	limiter *rate.Limiter,
+	gate *pauseGate,
 	opts Options,
 ) Result[In, Out] {
-	res, err := processWithRetry(ctx, item, processor, limiter, opts)
+	itemCtx := ctx
+	if opts.Canceler != nil {
+		var release func()
+		itemCtx, release = opts.Canceler.register(ctx, item)
+		defer release()
+	}
+	res, err := processWithRetry(itemCtx, item, processor, limiter, gate, opts)
+	if err != nil && ctx.Err() == nil && errors.Is(context.Cause(itemCtx), ErrItemCanceled) {
+		err = ErrItemCanceled
+	}
 	return Result[In, Out]{
 		Input:  item,
 		Output: res,
@@ -208,6 +347,7 @@ func processWithRetry[In any, Out any](
 	item In,
 	processor func(context.Context, In) (Out, error),
 	limiter *rate.Limiter,
+	gate *pauseGate,
 	opts Options,
 ) (Out, error) {
 	var lastOut Out
@@ -216,6 +356,10 @@ func processWithRetry[In any, Out any](
 			return lastOut, err
 		}
 
+		if err := gate.wait(ctx); err != nil {
+			return lastOut, err
+		}
+
 		if limiter != nil {
 			if err := limiter.Wait(ctx); err != nil {
 				return lastOut, err
@@ -225,7 +369,7 @@ func processWithRetry[In any, Out any](
 		reqCtx := ctx
 		var cancel context.CancelFunc
 		if opts.RequestTimeout > 0 {
-			reqCtx, cancel = context.WithTimeout(ctx, opts.RequestTimeout)
+			reqCtx, cancel = context.WithTimeout(ctx, attemptTimeout(opts.RequestTimeout, opts.RequestTimeoutFactor, attempt))
 		}
 		result, err := processor(reqCtx, item)
 		lastOut = result
@@ -238,12 +382,18 @@ func processWithRetry[In any, Out any](
 		if errors.Is(err, context.Canceled) && ctx.Err() != nil {
 			return lastOut, ctx.Err()
 		}
+		if d := retryAfter(err); d > 0 {
+			gate.pause(d)
+		}
 		maxRetries := maxExtraRetries(opts.MaxRetries, err)
 		if !isTransient(err) || attempt >= maxRetries {
 			return lastOut, err
 		}
 
 		sleep := backoffSleep(opts.BackoffInitial, opts.BackoffMax, opts.BackoffJitterFrac, attempt)
+		if opts.OnRetry != nil {
+			opts.OnRetry(attempt+1, sleep, err)
+		}
 		t := time.NewTimer(sleep)
 		select {
 		case <-t.C:
@@ -254,6 +404,76 @@ func processWithRetry[In any, Out any](
 	}
 }
 
+// attemptTimeout returns base scaled by factor once per prior attempt, clamped to [1ns, max
+// Duration]. A non-positive factor keeps base.
+func attemptTimeout(base time.Duration, factor float64, attempt int) time.Duration {
+	if factor <= 0 || factor == 1 || attempt == 0 {
+		return base
+	}
+	d := float64(base) * math.Pow(factor, float64(attempt))
+	switch {
+	case d >= math.MaxInt64:
+		return math.MaxInt64
+	case d < 1:
+		return 1
+	default:
+		return time.Duration(d)
+	}
+}
+
+// pauseGate pauses new attempts across the whole worker pool, so a provider cooldown reported by one
+// worker (e.g. a 429 with a retry-after) is honored by all of them. A nil gate never pauses.
+type pauseGate struct {
+	mu    sync.Mutex
+	until time.Time
+}
+
+func (g *pauseGate) pause(d time.Duration) {
+	if g == nil || d <= 0 {
+		return
+	}
+	until := time.Now().Add(d)
+	g.mu.Lock()
+	if until.After(g.until) {
+		g.until = until
+	}
+	g.mu.Unlock()
+}
+
+// wait blocks until the gate is open. The pause may be extended while waiting.
+func (g *pauseGate) wait(ctx context.Context) error {
+	if g == nil {
+		return nil
+	}
+	for {
+		g.mu.Lock()
+		d := time.Until(g.until)
+		g.mu.Unlock()
+		if d <= 0 {
+			return nil
+		}
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+type retryAfterer interface {
+	RetryAfterDuration() time.Duration
+}
+
+func retryAfter(err error) time.Duration {
+	var ra retryAfterer
+	if errors.As(err, &ra) {
+		return ra.RetryAfterDuration()
+	}
+	return 0
+}
+
 type retryCap interface {
 	MaxExtraRetries() int
 }
@@ -290,11 +510,20 @@ func isTransient(err error) bool {
 	if errors.Is(err, context.DeadlineExceeded) {
 		return true
 	}
+	// Checked explicitly rather than via the deprecated net.Error.Temporary.
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && (dnsErr.IsTimeout || dnsErr.IsTemporary) {
+		return true
+	}
 	var ne net.Error
-	if errors.As(err, &ne) {
-		return ne.Timeout() || ne.Temporary()
+	if errors.As(err, &ne) && (ne.Timeout() || ne.Temporary()) {
+		return true
+	}
+	// Dropped connections mid-request; kept in line with foundryio's classification.
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
 	}
-	return false
+	return errors.Is(err, io.ErrUnexpectedEOF)
 }
 
 func backoffSleep(initial, max time.Duration, jitterFrac float64, attempt int) time.Duration {
@@ -3,9 +3,15 @@ package worker_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
 	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
@@ -172,6 +178,59 @@ func TestProcessAll_FailFastStops(t *testing.T) {
 	}
 }
 
+func TestProcessAllWithCallback_FailFastKeepPartialReturnsCompletedResults(t *testing.T) {
+	t.Parallel()
+
+	// slow@ is in flight when bad@ fails; it finishes once the failure has been delivered. The items
+	// after them must never start.
+	slowStarted, release := make(chan struct{}), make(chan struct{})
+	var started sync.Map
+	fn := func(_ context.Context, email string) (string, error) {
+		started.Store(email, true)
+		switch email {
+		case "slow@example.com":
+			close(slowStarted)
+			<-release
+			return "ok", nil
+		case "bad@example.com":
+			<-slowStarted
+			return "", errors.New("boom")
+		default:
+			return "ok", nil
+		}
+	}
+	onResult := func(res worker.Result[string, string]) error {
+		if res.Err != nil {
+			close(release)
+		}
+		return nil
+	}
+
+	items := []string{"slow@example.com", "bad@example.com", "later1@example.com", "later2@example.com"}
+	out, err := worker.ProcessAllWithCallback(context.Background(), items, fn, onResult, worker.Options{
+		Workers:       2,
+		MaxRetries:    0,
+		FailurePolicy: worker.FailurePolicyFailFastKeepPartial,
+	})
+	if err == nil || err.Error() != "boom" {
+		t.Fatalf("expected boom error, got %v", err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("expected the 2 completed results, got %#v", out)
+	}
+	if out[0].Input != "slow@example.com" || out[0].Err != nil || out[0].Output != "ok" {
+		t.Fatalf("expected the in-flight success to be kept, got %#v", out[0])
+	}
+	if out[1].Input != "bad@example.com" || out[1].Err == nil {
+		t.Fatalf("expected the failed result, got %#v", out[1])
+	}
+	for _, email := range items[2:] {
+		if _, ok := started.Load(email); ok {
+			t.Fatalf("expected %s not to be started after the first error", email)
+		}
+	}
+}
+
 func TestProcessAll_PartialOutputContinues(t *testing.T) {
 	t.Parallel()
 
@@ -276,6 +335,69 @@ func TestProcessAllWithCallback_CompletesInCompletionOrder(t *testing.T) {
 	}
 }
 
+func TestProcessAllWithCallback_InFlightSuccessesAfterCancellation(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name string
+		// parentCancel cancels the caller's context once the slow item is in flight; otherwise the
+		// run is cancelled by fail-fast on the bad item.
+		parentCancel bool
+		wantSlow     bool
+	}{
+		{name: "fail-fast drops", parentCancel: false, wantSlow: false},
+		{name: "parent cancel drains", parentCancel: true, wantSlow: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			started := make(chan struct{})
+			items := []string{"slow@example.com"}
+			if !tc.parentCancel {
+				items = append(items, "bad@example.com")
+			}
+			fn := func(ctx context.Context, email string) (string, error) {
+				if email == "bad@example.com" {
+					<-started
+					return "", errors.New("boom")
+				}
+				close(started)
+				// The in-flight call completes despite the cancellation.
+				<-ctx.Done()
+				return email, nil
+			}
+			if tc.parentCancel {
+				go func() {
+					<-started
+					cancel()
+				}()
+			}
+
+			var mu sync.Mutex
+			var delivered []string
+			_, err := worker.ProcessAllWithCallback(ctx, items, fn, func(res worker.Result[string, string]) error {
+				mu.Lock()
+				defer mu.Unlock()
+				if res.Err == nil {
+					delivered = append(delivered, res.Output)
+				}
+				return nil
+			}, worker.Options{Workers: 2, FailurePolicy: worker.FailurePolicyFailFast})
+			if err == nil {
+				t.Fatalf("expected the run to fail")
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if got := slices.Contains(delivered, "slow@example.com"); got != tc.wantSlow {
+				t.Fatalf("expected the in-flight success delivered=%v, got %v", tc.wantSlow, delivered)
+			}
+		})
+	}
+}
+
 func TestProcessAllWithCallback_CallbackErrorStopsRun(t *testing.T) {
 	t.Parallel()
 
@@ -295,3 +417,398 @@ func TestProcessAllWithCallback_CallbackErrorStopsRun(t *testing.T) {
 		t.Fatalf("expected callback error, got %v", err)
 	}
 }
+
+func TestProcessAll_RetryAfterPausesAllWorkers(t *testing.T) {
+	t.Parallel()
+
+	const cooldown = 500 * time.Millisecond
+	start := time.Now()
+
+	var mu sync.Mutex
+	attempts := map[string][]time.Duration{}
+	fn := func(_ context.Context, item string) (string, error) {
+		mu.Lock()
+		attempts[item] = append(attempts[item], time.Since(start))
+		n := len(attempts[item])
+		mu.Unlock()
+
+		if n > 1 {
+			return "ok", nil
+		}
+		if item == "limited" {
+			return "", &core.TransientError{Err: errors.New("429"), RetryAfter: cooldown}
+		}
+		// Others fail transiently slightly later, while the cooldown is active.
+		time.Sleep(50 * time.Millisecond)
+		return "", &core.TransientError{Err: errors.New("try again")}
+	}
+
+	out, err := worker.ProcessAll(context.Background(), []string{"limited", "b", "c"}, fn, worker.Options{
+		Workers:           3,
+		MaxRetries:        2,
+		RequestTimeout:    time.Second,
+		BackoffInitial:    time.Millisecond,
+		BackoffMax:        time.Millisecond,
+		BackoffJitterFrac: 0,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, r := range out {
+		if r.Err != nil {
+			t.Fatalf("unexpected error for %q: %v", r.Input, r.Err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, item := range []string{"limited", "b", "c"} {
+		if len(attempts[item]) != 2 {
+			t.Fatalf("expected 2 attempts for %q, got %v", item, attempts[item])
+		}
+		// Allow slack for timer granularity; without the gate b/c would retry at ~50ms.
+		if retryAt := attempts[item][1]; retryAt < cooldown-50*time.Millisecond {
+			t.Fatalf("retry for %q at %s, expected to wait for the %s cooldown", item, retryAt, cooldown)
+		}
+	}
+}
+
+func TestProcessAll_RetriesDroppedConnectionErrors(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		err  error
+	}{
+		{name: "ECONNRESET", err: &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}},
+		{name: "ECONNREFUSED", err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}},
+		{name: "EPIPE", err: fmt.Errorf("post: %w", &net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)})},
+		{name: "unexpected EOF", err: fmt.Errorf("read body: %w", io.ErrUnexpectedEOF)},
+		{name: "DNS temporary", err: &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "server misbehaving", Name: "api.test", IsTemporary: true}}},
+		{name: "DNS timeout", err: fmt.Errorf("lookup: %w", &net.DNSError{Err: "i/o timeout", Name: "api.test", IsTimeout: true})},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var calls atomic.Int32
+			fn := func(_ context.Context, _ string) (string, error) {
+				if calls.Add(1) == 1 {
+					return "", tc.err
+				}
+				return "ok", nil
+			}
+			out, err := worker.ProcessAll(context.Background(), []string{"alice@example.com"}, fn, worker.Options{
+				Workers:           1,
+				MaxRetries:        1,
+				FailurePolicy:     worker.FailurePolicyPartialOutput,
+				RequestTimeout:    time.Second,
+				BackoffInitial:    time.Millisecond,
+				BackoffMax:        time.Millisecond,
+				BackoffJitterFrac: 0,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if out[0].Err != nil || out[0].Output != "ok" {
+				t.Fatalf("expected %v to be retried, got %#v", tc.err, out[0])
+			}
+			if got := calls.Load(); got != 2 {
+				t.Fatalf("expected 2 calls, got %d", got)
+			}
+		})
+	}
+}
+
+func TestProcessAll_DoesNotRetryDNSNotFound(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	fn := func(_ context.Context, _ string) (string, error) {
+		calls.Add(1)
+		return "", &net.DNSError{Err: "no such host", Name: "missing.test", IsNotFound: true}
+	}
+	out, err := worker.ProcessAll(context.Background(), []string{"alice@example.com"}, fn, worker.Options{
+		Workers:        1,
+		MaxRetries:     2,
+		FailurePolicy:  worker.FailurePolicyPartialOutput,
+		RequestTimeout: time.Second,
+		BackoffInitial: time.Millisecond,
+		BackoffMax:     time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out[0].Err == nil {
+		t.Fatalf("expected DNS not-found error to be recorded")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("expected a permanent DNS failure not to be retried, got %d calls", got)
+	}
+}
+
+func TestDeterministicOptions_CompletesInInputOrder(t *testing.T) {
+	t.Parallel()
+
+	items := []string{"slow@example.com", "retry@example.com", "fast@example.com", "medium@example.com"}
+	delays := map[string]time.Duration{
+		"slow@example.com":   30 * time.Millisecond,
+		"medium@example.com": 10 * time.Millisecond,
+	}
+	var retried atomic.Bool
+	fn := func(_ context.Context, email string) (string, error) {
+		time.Sleep(delays[email])
+		if email == "retry@example.com" && !retried.Swap(true) {
+			return "", &core.TransientError{Err: errors.New("try again")}
+		}
+		return email, nil
+	}
+
+	opts := worker.DeterministicOptions()
+	opts.MaxRetries = 1
+	opts.BackoffInitial = time.Millisecond
+
+	var seen []string
+	out, err := worker.ProcessAllWithCallback(context.Background(), items, fn, func(res worker.Result[string, string]) error {
+		seen = append(seen, res.Input)
+		return nil
+	}, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(seen, items) {
+		t.Fatalf("expected completion order %v, got %v", items, seen)
+	}
+	for i, res := range out {
+		if res.Err != nil || res.Output != items[i] {
+			t.Fatalf("out[%d]: unexpected result %#v", i, res)
+		}
+	}
+}
+
+func TestProcessAll_RampIntervalStartsWorkersOverTime(t *testing.T) {
+	t.Parallel()
+
+	ticks := make(chan chan time.Time)
+	fakeAfter := func(time.Duration) <-chan time.Time {
+		c := make(chan time.Time, 1)
+		ticks <- c
+		return c
+	}
+
+	entered := make(chan string)
+	release := make(chan struct{})
+	fn := func(_ context.Context, s string) (string, error) {
+		entered <- s
+		<-release
+		return s, nil
+	}
+
+	items := []string{"a", "b", "c", "d", "e"}
+	type outcome struct {
+		res []worker.Result[string, string]
+		err error
+	}
+	finished := make(chan outcome, 1)
+	go func() {
+		res, err := worker.ProcessAll(context.Background(), items, fn, worker.Options{
+			Workers:      3,
+			RampInterval: time.Second,
+			RampAfter:    fakeAfter,
+		})
+		finished <- outcome{res, err}
+	}()
+
+	active := 0
+	for step := 1; step <= 3; step++ {
+		<-entered
+		active++
+		// Every started worker is blocked in fn, so no other worker can pick up work until the ramp
+		// adds one.
+		select {
+		case s := <-entered:
+			t.Fatalf("step %d: item %q started before the ramp added a worker (active=%d)", step, s, active)
+		case tick := <-ticks:
+			if step == 3 {
+				t.Fatalf("ramp waited for a fourth worker")
+			}
+			tick <- time.Now()
+		case <-time.After(20 * time.Millisecond):
+			if step < 3 {
+				t.Fatalf("step %d: ramp did not wait for the next interval", step)
+			}
+		}
+	}
+	if active != 3 {
+		t.Fatalf("expected 3 active workers, got %d", active)
+	}
+
+	close(release)
+	go func() {
+		for range entered {
+		}
+	}()
+	out := <-finished
+	if out.err != nil {
+		t.Fatalf("ProcessAll: %v", out.err)
+	}
+	for i, r := range out.res {
+		if r.Err != nil || r.Output != items[i] {
+			t.Fatalf("result[%d] = %#v", i, r)
+		}
+	}
+}
+
+func TestProcessAll_RampStopsOnceAllItemsAreDispatched(t *testing.T) {
+	t.Parallel()
+
+	// With fewer items than workers the run must not wait out the remaining ramp steps.
+	never := func(time.Duration) <-chan time.Time { return nil }
+	res, err := worker.ProcessAll(context.Background(), []string{"a"}, func(_ context.Context, s string) (string, error) {
+		return s, nil
+	}, worker.Options{Workers: 10, RampInterval: time.Hour, RampAfter: never})
+	if err != nil {
+		t.Fatalf("ProcessAll: %v", err)
+	}
+	if len(res) != 1 || res[0].Output != "a" {
+		t.Fatalf("unexpected results: %#v", res)
+	}
+}
+
+func TestProcessAll_RequestTimeoutFactorScalesRetryDeadlines(t *testing.T) {
+	t.Parallel()
+
+	const base = 10 * time.Second
+	for _, factor := range []float64{0, 1, 3, 0.5} {
+		t.Run(fmt.Sprint(factor), func(t *testing.T) {
+			t.Parallel()
+
+			var mu sync.Mutex
+			var remaining []time.Duration
+			fn := func(ctx context.Context, _ string) (string, error) {
+				deadline, ok := ctx.Deadline()
+				if !ok {
+					return "", errors.New("attempt has no deadline")
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				remaining = append(remaining, time.Until(deadline))
+				if len(remaining) == 1 {
+					return "", &core.TransientError{Err: errors.New("try again")}
+				}
+				return "ok", nil
+			}
+			out, err := worker.ProcessAll(context.Background(), []string{"alice@example.com"}, fn, worker.Options{
+				Workers:              1,
+				MaxRetries:           1,
+				RequestTimeout:       base,
+				RequestTimeoutFactor: factor,
+				BackoffInitial:       time.Millisecond,
+				BackoffJitterFrac:    -1,
+			})
+			if err != nil || out[0].Err != nil {
+				t.Fatalf("unexpected result: out=%#v err=%v", out, err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			scale := factor
+			if scale == 0 {
+				scale = 1
+			}
+			for i, want := range []time.Duration{base, time.Duration(float64(base) * scale)} {
+				if got := remaining[i]; got > want || got < want-time.Second {
+					t.Fatalf("attempt %d: expected a deadline about %s away, got %s", i+1, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessAll_OnRetryReportsIncreasingBackoff(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	fn := func(_ context.Context, _ string) (string, error) {
+		if calls.Add(1) <= 3 {
+			return "", &core.TransientError{Err: errors.New("try again")}
+		}
+		return "ok", nil
+	}
+
+	type retry struct {
+		attempt int
+		delay   time.Duration
+		err     string
+	}
+	var mu sync.Mutex
+	var retries []retry
+	out, err := worker.ProcessAll(context.Background(), []string{"alice@example.com"}, fn, worker.Options{
+		Workers:           1,
+		MaxRetries:        5,
+		RequestTimeout:    time.Second,
+		BackoffInitial:    time.Millisecond,
+		BackoffMax:        time.Second,
+		BackoffJitterFrac: -1,
+		OnRetry: func(attempt int, delay time.Duration, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			retries = append(retries, retry{attempt, delay, err.Error()})
+		},
+	})
+	if err != nil || out[0].Err != nil || out[0].Output != "ok" {
+		t.Fatalf("unexpected result: out=%#v err=%v", out, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []retry{
+		{1, time.Millisecond, "try again"},
+		{2, 2 * time.Millisecond, "try again"},
+		{3, 4 * time.Millisecond, "try again"},
+	}
+	if !slices.Equal(retries, want) {
+		t.Fatalf("unexpected retries:\nwant=%v\ngot=%v", want, retries)
+	}
+}
+
+func TestProcessAll_CancelerCancelsOneInFlightItem(t *testing.T) {
+	t.Parallel()
+
+	canceler := worker.NewCanceler(strings.TrimSpace)
+	started := make(chan struct{})
+	fn := func(ctx context.Context, in string) (string, error) {
+		if in != " stuck " {
+			return in + "-done", nil
+		}
+		close(started)
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+
+	go func() {
+		<-started
+		if !canceler.Cancel("stuck") {
+			t.Errorf("expected Cancel to find the in-flight item")
+		}
+	}()
+	out, err := worker.ProcessAll(context.Background(), []string{"a", " stuck ", "b"}, fn, worker.Options{
+		Workers:        2,
+		MaxRetries:     3,
+		RequestTimeout: 10 * time.Second,
+		FailurePolicy:  worker.FailurePolicyFailFast,
+		Canceler:       canceler,
+	})
+	if err != nil {
+		t.Fatalf("a canceled item must not fail the run: %v", err)
+	}
+	if !errors.Is(out[1].Err, worker.ErrItemCanceled) {
+		t.Fatalf("expected ErrItemCanceled for the stuck item, got %v", out[1].Err)
+	}
+	if out[0].Output != "a-done" || out[2].Output != "b-done" {
+		t.Fatalf("expected other items to complete: %#v", out)
+	}
+	if canceler.Cancel("stuck") {
+		t.Fatalf("expected Cancel to report nothing running after the run")
+	}
+}